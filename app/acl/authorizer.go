@@ -0,0 +1,168 @@
+package acl
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/request/operation"
+)
+
+// Authorizer decides if a principal connecting from a host may perform an operation on a resource.
+// Handlers consult it before touching any resource so that it can be swapped for other implementations
+type Authorizer interface {
+	Authorize(principal string, host string, op Operation, resource Resource) bool
+}
+
+// Manager is implemented by authorizers whose ACLs can be managed through the CreateAcls, DescribeAcls and DeleteAcls APIs.
+// The ACLs are metadata records, the authorizer loads them from every metadata image
+type Manager interface {
+	Acls(filter BindingFilter) []Binding
+	Load(bindings []Binding)
+}
+
+// AclAuthorizer is the built-in authorizer which keeps the ACL bindings of the metadata image in memory.
+// DENY bindings always take precedence over ALLOW bindings
+type AclAuthorizer struct {
+	mutex    sync.RWMutex
+	bindings []Binding
+	// Super users are allowed to perform every operation regardless of the ACLs
+	superUsers []string
+	// When no binding applies to a resource the operation is allowed only if this is set
+	allowEveryoneIfNoAclFound bool
+}
+
+func NewAclAuthorizer(superUsers []string, allowEveryoneIfNoAclFound bool) *AclAuthorizer {
+	return &AclAuthorizer{
+		bindings:                  []Binding{},
+		superUsers:                superUsers,
+		allowEveryoneIfNoAclFound: allowEveryoneIfNoAclFound,
+	}
+}
+
+func (a *AclAuthorizer) Authorize(principal string, host string, op Operation, resource Resource) bool {
+	if slices.Contains(a.superUsers, principal) {
+		return true
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	resourceBindings := []Binding{}
+	for _, binding := range a.bindings {
+		if binding.matchesResource(resource) {
+			resourceBindings = append(resourceBindings, binding)
+		}
+	}
+
+	if len(resourceBindings) == 0 {
+		return a.allowEveryoneIfNoAclFound
+	}
+
+	for _, binding := range resourceBindings {
+		if binding.PermissionType == DENY && matchesIdentity(binding, principal, host) && (binding.Operation == op || binding.Operation == ALL) {
+			return false
+		}
+	}
+
+	for _, binding := range resourceBindings {
+		if binding.PermissionType == ALLOW && matchesIdentity(binding, principal, host) && allowedOperations(binding.Operation)[op] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load replaces the bindings with the ones of the metadata image, where CreateAcls and DeleteAcls change them
+func (a *AclAuthorizer) Load(bindings []Binding) {
+	bindings = slices.Clone(bindings)
+	slices.SortFunc(bindings, CompareBindings)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.bindings = bindings
+}
+
+func (a *AclAuthorizer) Acls(filter BindingFilter) []Binding {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	matched := []Binding{}
+	for _, binding := range a.bindings {
+		if filter.Matches(binding) {
+			matched = append(matched, binding)
+		}
+	}
+
+	return matched
+}
+
+func matchesIdentity(binding Binding, principal string, host string) bool {
+	principalMatches := binding.Principal == principal || binding.Principal == WildcardPrincipal
+	hostMatches := binding.Host == host || binding.Host == WildcardHost
+
+	return principalMatches && hostMatches
+}
+
+// allowedOperations returns the operations granted by an ALLOW binding.
+// Being allowed to read, write, delete or alter a resource implies being allowed to describe it,
+// and being allowed to alter configs implies being allowed to describe them
+func allowedOperations(op Operation) map[Operation]bool {
+	switch op {
+	case ALL:
+		allowed := make(map[Operation]bool)
+		for code := READ; code <= DESCRIBE_TOKENS; code++ {
+			allowed[code] = true
+		}
+
+		return allowed
+	case READ, WRITE, DELETE, ALTER:
+		return map[Operation]bool{op: true, DESCRIBE: true}
+	case ALTER_CONFIGS:
+		return map[Operation]bool{op: true, DESCRIBE_CONFIGS: true}
+	default:
+		return map[Operation]bool{op: true}
+	}
+}
+
+// The operations that apply to each resource type
+var supportedOperations = map[ResourceType][]Operation{
+	TOPIC:            {READ, WRITE, CREATE, DESCRIBE, DELETE, ALTER, DESCRIBE_CONFIGS, ALTER_CONFIGS},
+	GROUP:            {READ, DESCRIBE, DELETE, DESCRIBE_CONFIGS, ALTER_CONFIGS},
+	CLUSTER:          {CREATE, CLUSTER_ACTION, DESCRIBE_CONFIGS, ALTER_CONFIGS, IDEMPOTENT_WRITE, ALTER, DESCRIBE},
+	TRANSACTIONAL_ID: {DESCRIBE, WRITE},
+	DELEGATION_TOKEN: {DESCRIBE},
+	USER:             {CREATE_TOKENS, DESCRIBE_TOKENS},
+}
+
+var operationPermissions = map[Operation]int32{
+	UNKNOWN_OPERATION: operation.UNKNOWN,
+	ANY_OPERATION:     operation.ANY,
+	ALL:               operation.ALL,
+	READ:              operation.READ,
+	WRITE:             operation.WRITE,
+	CREATE:            operation.CREATE,
+	DELETE:            operation.DELETE,
+	ALTER:             operation.ALTER,
+	DESCRIBE:          operation.DESCRIBE,
+	CLUSTER_ACTION:    operation.CLUSTER_ACTION,
+	DESCRIBE_CONFIGS:  operation.DESCRIBE_CONFIGS,
+	ALTER_CONFIGS:     operation.ALTER_CONFIGS,
+	IDEMPOTENT_WRITE:  operation.IDEMPOTENT_WRITE,
+	CREATE_TOKENS:     operation.CREATE_TOKENS,
+	DESCRIBE_TOKENS:   operation.DESCRIBE_TOKENS,
+}
+
+// AuthorizedOperations builds the operation permission bitmask returned in fields such as TopicAuthorizedOperations
+func AuthorizedOperations(authorizer Authorizer, principal string, host string, resource Resource) int32 {
+	var permissions int32
+
+	for _, op := range supportedOperations[resource.Type] {
+		if authorizer.Authorize(principal, host, op, resource) {
+			permissions |= operationPermissions[op]
+		}
+	}
+
+	return permissions
+}
@@ -0,0 +1,208 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/request/operation"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name      string
+		bindings  []Binding
+		principal string
+		host      string
+		op        Operation
+		resource  Resource
+		want      bool
+	}{
+		{
+			name:      "No ACLs uses the default",
+			bindings:  []Binding{},
+			principal: "User:alice",
+			host:      "127.0.0.1",
+			op:        READ,
+			resource:  Resource{Type: TOPIC, Name: "foo"},
+			want:      true,
+		},
+		{
+			name: "Literal allow",
+			bindings: []Binding{
+				{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+			},
+			principal: "User:alice",
+			host:      "127.0.0.1",
+			op:        READ,
+			resource:  Resource{Type: TOPIC, Name: "foo"},
+			want:      true,
+		},
+		{
+			name: "Other principal is not allowed once ACLs exist",
+			bindings: []Binding{
+				{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+			},
+			principal: "User:bob",
+			host:      "127.0.0.1",
+			op:        READ,
+			resource:  Resource{Type: TOPIC, Name: "foo"},
+			want:      false,
+		},
+		{
+			name: "Prefixed allow",
+			bindings: []Binding{
+				{ResourceType: TOPIC, ResourceName: "orders-", PatternType: PREFIXED, Principal: "User:alice", Host: "*", Operation: WRITE, PermissionType: ALLOW},
+			},
+			principal: "User:alice",
+			host:      "127.0.0.1",
+			op:        WRITE,
+			resource:  Resource{Type: TOPIC, Name: "orders-eu"},
+			want:      true,
+		},
+		{
+			name: "Deny takes precedence over allow",
+			bindings: []Binding{
+				{ResourceType: TOPIC, ResourceName: "*", PatternType: LITERAL, Principal: "User:*", Host: "*", Operation: ALL, PermissionType: ALLOW},
+				{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "10.0.0.1", Operation: READ, PermissionType: DENY},
+			},
+			principal: "User:alice",
+			host:      "10.0.0.1",
+			op:        READ,
+			resource:  Resource{Type: TOPIC, Name: "foo"},
+			want:      false,
+		},
+		{
+			name: "Deny applies only to its host",
+			bindings: []Binding{
+				{ResourceType: TOPIC, ResourceName: "*", PatternType: LITERAL, Principal: "User:*", Host: "*", Operation: ALL, PermissionType: ALLOW},
+				{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "10.0.0.1", Operation: READ, PermissionType: DENY},
+			},
+			principal: "User:alice",
+			host:      "10.0.0.2",
+			op:        READ,
+			resource:  Resource{Type: TOPIC, Name: "foo"},
+			want:      true,
+		},
+		{
+			name: "Read implies describe",
+			bindings: []Binding{
+				{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+			},
+			principal: "User:alice",
+			host:      "127.0.0.1",
+			op:        DESCRIBE,
+			resource:  Resource{Type: TOPIC, Name: "foo"},
+			want:      true,
+		},
+		{
+			name: "Super user bypasses deny",
+			bindings: []Binding{
+				{ResourceType: CLUSTER, ResourceName: ClusterResourceName, PatternType: LITERAL, Principal: "User:*", Host: "*", Operation: ALL, PermissionType: DENY},
+			},
+			principal: "User:admin",
+			host:      "127.0.0.1",
+			op:        ALTER,
+			resource:  Resource{Type: CLUSTER, Name: ClusterResourceName},
+			want:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer := NewAclAuthorizer([]string{"User:admin"}, true)
+			authorizer.Load(tt.bindings)
+
+			got := authorizer.Authorize(tt.principal, tt.host, tt.op, tt.resource)
+			if got != tt.want {
+				t.Errorf("Authorize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		binding Binding
+		wantErr bool
+	}{
+		{
+			name:    "Valid binding",
+			binding: Binding{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+			wantErr: false,
+		},
+		{
+			name:    "Match pattern is only valid in filters",
+			binding: Binding{ResourceType: TOPIC, ResourceName: "foo", PatternType: MATCH, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+			wantErr: true,
+		},
+		{
+			name:    "Principal without type",
+			binding: Binding{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+			wantErr: true,
+		},
+		{
+			name:    "Any operation",
+			binding: Binding{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: ANY_OPERATION, PermissionType: ALLOW},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid cluster name",
+			binding: Binding{ResourceType: CLUSTER, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: ALTER, PermissionType: ALLOW},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.binding.Validate()
+
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAclsMatchesFilter(t *testing.T) {
+	name := "foo"
+	authorizer := NewAclAuthorizer(nil, false)
+	bindings := []Binding{
+		{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+		{ResourceType: TOPIC, ResourceName: "f", PatternType: PREFIXED, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+		{ResourceType: TOPIC, ResourceName: "bar", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: READ, PermissionType: ALLOW},
+	}
+	authorizer.Load(bindings)
+
+	matched := authorizer.Acls(BindingFilter{
+		ResourceType:   TOPIC,
+		ResourceName:   &name,
+		PatternType:    MATCH,
+		Operation:      ANY_OPERATION,
+		PermissionType: ANY_PERMISSION,
+	})
+	if len(matched) != 2 {
+		t.Errorf("expected the literal and prefixed bindings to match, got %v", matched)
+	}
+
+	// The loaded bindings replace the previous ones
+	authorizer.Load(bindings[2:])
+	remaining := authorizer.Acls(BindingFilter{ResourceType: ANY_RESOURCE, PatternType: ANY_PATTERN, Operation: ANY_OPERATION, PermissionType: ANY_PERMISSION})
+	if len(remaining) != 1 || remaining[0].ResourceName != "bar" {
+		t.Errorf("expected only the bar binding to remain, got %v", remaining)
+	}
+}
+
+func TestAuthorizedOperations(t *testing.T) {
+	authorizer := NewAclAuthorizer(nil, false)
+	authorizer.Load([]Binding{{ResourceType: TOPIC, ResourceName: "foo", PatternType: LITERAL, Principal: "User:alice", Host: "*", Operation: WRITE, PermissionType: ALLOW}})
+
+	got := AuthorizedOperations(authorizer, "User:alice", "127.0.0.1", Resource{Type: TOPIC, Name: "foo"})
+	want := int32(operation.WRITE | operation.DESCRIBE)
+
+	if got != want {
+		t.Errorf("AuthorizedOperations() = %b, want %b", got, want)
+	}
+}
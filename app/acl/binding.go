@@ -0,0 +1,208 @@
+package acl
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Operation is the code of an ACL operation as sent in the Kafka protocol
+type Operation int8
+
+const (
+	UNKNOWN_OPERATION Operation = iota
+	ANY_OPERATION
+	ALL
+	READ
+	WRITE
+	CREATE
+	DELETE
+	ALTER
+	DESCRIBE
+	CLUSTER_ACTION
+	DESCRIBE_CONFIGS
+	ALTER_CONFIGS
+	IDEMPOTENT_WRITE
+	CREATE_TOKENS
+	DESCRIBE_TOKENS
+)
+
+type PermissionType int8
+
+const (
+	UNKNOWN_PERMISSION PermissionType = iota
+	ANY_PERMISSION
+	DENY
+	ALLOW
+)
+
+type ResourceType int8
+
+const (
+	UNKNOWN_RESOURCE ResourceType = iota
+	ANY_RESOURCE
+	TOPIC
+	GROUP
+	CLUSTER
+	TRANSACTIONAL_ID
+	DELEGATION_TOKEN
+	USER
+)
+
+type PatternType int8
+
+const (
+	UNKNOWN_PATTERN PatternType = iota
+	ANY_PATTERN
+	// MATCH is only valid in filters and matches every pattern that applies to the resource name
+	MATCH
+	LITERAL
+	PREFIXED
+)
+
+const (
+	// WildcardResource matches every resource of a type when used with a LITERAL pattern
+	WildcardResource  = "*"
+	WildcardPrincipal = "User:*"
+	WildcardHost      = "*"
+	// ClusterResourceName is the only valid name for CLUSTER resources
+	ClusterResourceName = "kafka-cluster"
+)
+
+type Resource struct {
+	Type ResourceType
+	Name string
+}
+
+// Binding is a single ACL: it allows or denies an operation on the resources matched by a pattern to a principal connecting from a host
+type Binding struct {
+	ResourceType   ResourceType
+	ResourceName   string
+	PatternType    PatternType
+	Principal      string
+	Host           string
+	Operation      Operation
+	PermissionType PermissionType
+}
+
+// Validate checks that a binding is concrete enough to be stored
+func (b Binding) Validate() error {
+	switch {
+	case b.ResourceType <= ANY_RESOURCE || b.ResourceType > USER:
+		return fmt.Errorf("invalid resource type %d", b.ResourceType)
+	case b.PatternType != LITERAL && b.PatternType != PREFIXED:
+		return fmt.Errorf("invalid pattern type %d", b.PatternType)
+	case b.ResourceName == "":
+		return fmt.Errorf("resource name must not be empty")
+	case b.ResourceType == CLUSTER && b.ResourceName != ClusterResourceName:
+		return fmt.Errorf("the only valid name for the CLUSTER resource is %s", ClusterResourceName)
+	case !strings.Contains(b.Principal, ":"):
+		return fmt.Errorf("invalid principal %q: expected the format <type>:<name>", b.Principal)
+	case b.Host == "":
+		return fmt.Errorf("host must not be empty")
+	case b.Operation <= ANY_OPERATION || b.Operation > DESCRIBE_TOKENS:
+		return fmt.Errorf("invalid operation %d", b.Operation)
+	case b.PermissionType != ALLOW && b.PermissionType != DENY:
+		return fmt.Errorf("invalid permission type %d", b.PermissionType)
+	}
+
+	return nil
+}
+
+// CompareBindings orders bindings by resource pattern, then by principal, host, operation and permission
+func CompareBindings(a, b Binding) int {
+	return cmp.Or(
+		cmp.Compare(a.ResourceType, b.ResourceType),
+		cmp.Compare(a.ResourceName, b.ResourceName),
+		cmp.Compare(a.PatternType, b.PatternType),
+		cmp.Compare(a.Principal, b.Principal),
+		cmp.Compare(a.Host, b.Host),
+		cmp.Compare(a.Operation, b.Operation),
+		cmp.Compare(a.PermissionType, b.PermissionType),
+	)
+}
+
+// matchesResource tells if the binding pattern applies to a concrete resource
+func (b Binding) matchesResource(resource Resource) bool {
+	if b.ResourceType != resource.Type {
+		return false
+	}
+
+	switch b.PatternType {
+	case LITERAL:
+		return b.ResourceName == WildcardResource || b.ResourceName == resource.Name
+	case PREFIXED:
+		return strings.HasPrefix(resource.Name, b.ResourceName)
+	default:
+		return false
+	}
+}
+
+// BindingFilter selects bindings in DescribeAcls and DeleteAcls. Nil names and ANY values match everything
+type BindingFilter struct {
+	ResourceType   ResourceType
+	ResourceName   *string
+	PatternType    PatternType
+	Principal      *string
+	Host           *string
+	Operation      Operation
+	PermissionType PermissionType
+}
+
+func (f BindingFilter) Matches(binding Binding) bool {
+	if f.ResourceType != ANY_RESOURCE && f.ResourceType != binding.ResourceType {
+		return false
+	}
+
+	if !f.matchesPattern(binding) {
+		return false
+	}
+
+	if f.Principal != nil && *f.Principal != binding.Principal {
+		return false
+	}
+
+	if f.Host != nil && *f.Host != binding.Host {
+		return false
+	}
+
+	if f.Operation != ANY_OPERATION && f.Operation != binding.Operation {
+		return false
+	}
+
+	return f.PermissionType == ANY_PERMISSION || f.PermissionType == binding.PermissionType
+}
+
+func (f BindingFilter) matchesPattern(binding Binding) bool {
+	switch f.PatternType {
+	case ANY_PATTERN:
+		return f.ResourceName == nil || *f.ResourceName == binding.ResourceName
+	case MATCH:
+		// A MATCH filter selects every binding that would apply to the named resource
+		if f.ResourceName == nil {
+			return true
+		}
+
+		return binding.matchesResource(Resource{Type: binding.ResourceType, Name: *f.ResourceName})
+	case LITERAL, PREFIXED:
+		return f.PatternType == binding.PatternType && (f.ResourceName == nil || *f.ResourceName == binding.ResourceName)
+	default:
+		return false
+	}
+}
+
+// Validate checks that a filter can be used to delete bindings
+func (f BindingFilter) Validate() error {
+	switch {
+	case f.ResourceType == UNKNOWN_RESOURCE || f.ResourceType > USER:
+		return fmt.Errorf("invalid resource type filter %d", f.ResourceType)
+	case f.PatternType == UNKNOWN_PATTERN || f.PatternType > PREFIXED:
+		return fmt.Errorf("invalid pattern type filter %d", f.PatternType)
+	case f.Operation == UNKNOWN_OPERATION || f.Operation > DESCRIBE_TOKENS:
+		return fmt.Errorf("invalid operation filter %d", f.Operation)
+	case f.PermissionType == UNKNOWN_PERMISSION || f.PermissionType > ALLOW:
+		return fmt.Errorf("invalid permission type filter %d", f.PermissionType)
+	}
+
+	return nil
+}
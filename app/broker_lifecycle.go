@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/request"
+)
+
+// How often a broker in controlled shutdown asks the controller whether it may stop
+const controlledShutdownPollInterval = 100 * time.Millisecond
+
+// brokerLifecycle registers the broker with the active controller and heartbeats to keep it unfenced. The requests
+// go through the controller channel, to whichever controller is active, even when it runs in the same process
+type brokerLifecycle struct {
+	channel           *request.ControllerChannel
+	loader            *metadata.Loader
+	registration      controller.BrokerRegistration
+	heartbeatInterval time.Duration
+	logger            *slog.Logger
+
+	mutex sync.Mutex
+	// The epoch of the registration, -1 until the broker is registered
+	epoch int64
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// startBrokerLifecycle registers the broker with the directory ids of its online log dirs, and with the epoch of the
+// registration it shut down cleanly from, -1 after a crash
+func startBrokerLifecycle(serverConfig *config.ServerConfig, channel *request.ControllerChannel, loader *metadata.Loader, directoryIds []string, previousBrokerEpoch int64, logger *slog.Logger) *brokerLifecycle {
+	registration := controller.BrokerRegistration{
+		BrokerId: serverConfig.NodeId,
+		// A new incarnation for every start of the process
+		IncarnationId:       metadata.NewTopicId(),
+		Endpoints:           []metadata.BrokerEndpoint{},
+		LogDirs:             directoryIds,
+		PreviousBrokerEpoch: previousBrokerEpoch,
+	}
+	for _, listener := range serverConfig.Listeners {
+		if slices.Contains(serverConfig.Quorum.ListenerNames, listener.Name) {
+			continue
+		}
+		registration.Endpoints = append(registration.Endpoints, metadata.BrokerEndpoint{
+			Name:             listener.Name,
+			Host:             listener.AdvertisedHost,
+			Port:             uint16(listener.AdvertisedPort),
+			SecurityProtocol: listener.SecurityProtocol.Id(),
+		})
+	}
+
+	l := &brokerLifecycle{
+		channel:           channel,
+		loader:            loader,
+		registration:      registration,
+		heartbeatInterval: serverConfig.Quorum.HeartbeatInterval,
+		logger:            logger,
+		epoch:             -1,
+		stop:              make(chan struct{}),
+	}
+
+	l.stopped.Add(1)
+	go func() {
+		defer l.stopped.Done()
+
+		ticker := time.NewTicker(l.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				if _, err := l.heartbeat(false); err != nil && !errors.Is(err, controller.ErrNotController) {
+					l.logger.Warn("Failed to heartbeat to the controller", "error", err)
+				}
+			}
+		}
+	}()
+
+	return l
+}
+
+// heartbeat registers the broker first if needed. A registration the controller no longer knows is made again
+func (l *brokerLifecycle) heartbeat(wantShutDown bool) (controller.HeartbeatResult, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.epoch < 0 {
+		epoch, err := l.channel.RegisterBroker(l.registration)
+		if err != nil {
+			return controller.HeartbeatResult{}, err
+		}
+		l.epoch = epoch
+		l.logger.Info("Registered the broker", "epoch", epoch)
+	}
+
+	result, err := l.channel.Heartbeat(controller.BrokerHeartbeat{
+		BrokerId:    l.registration.BrokerId,
+		BrokerEpoch: l.epoch,
+		// The image offset is the end of the last record the broker loaded
+		CurrentMetadataOffset: l.loader.Image().Offset - 1,
+		WantShutDown:          wantShutDown,
+	})
+	if errors.Is(err, metadata.ErrUnknownBroker) || errors.Is(err, metadata.ErrStaleBrokerEpoch) {
+		l.epoch = -1
+	}
+
+	return result, err
+}
+
+// brokerEpoch is the epoch of the registration of the broker, -1 when it is not registered
+func (l *brokerLifecycle) brokerEpoch() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.epoch
+}
+
+// controlledShutdown stops the heartbeats and asks the controller to move the leadership of the partitions of the
+// broker away, until it allows the broker to stop or timeout elapses. A broker that never registered leads nothing
+// and stops right away
+func (l *brokerLifecycle) controlledShutdown(timeout time.Duration) error {
+	close(l.stop)
+	l.stopped.Wait()
+
+	if l.brokerEpoch() < 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := l.heartbeat(true)
+		if err == nil && result.ShouldShutDown {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = errors.New("the controller did not allow the shutdown in time")
+			}
+			return err
+		}
+		time.Sleep(controlledShutdownPollInterval)
+	}
+}
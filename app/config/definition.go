@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Type is the value type of a config, using the same numbering as the config_type field of DescribeConfigs
+type Type int8
+
+const (
+	UNKNOWN_TYPE Type = iota
+	BOOLEAN
+	STRING
+	INT
+	SHORT
+	LONG
+	DOUBLE
+	LIST
+	CLASS
+	PASSWORD
+)
+
+// Validator checks a value that has already been parsed successfully for the type of its config
+type Validator func(name string, value string) error
+
+type Definition struct {
+	Name          string
+	Type          Type
+	Default       string
+	Validator     Validator
+	Documentation string
+	// ReadOnly configs can only be set statically in the broker properties and cannot be altered at runtime
+	ReadOnly bool
+	// BrokerSynonym is the broker config that provides the default value of a topic config (e.g. log.retention.ms for retention.ms)
+	BrokerSynonym string
+}
+
+func (d Definition) IsSensitive() bool {
+	return d.Type == PASSWORD
+}
+
+// Validate checks that the value can be parsed as the type of the config and then runs the config validator
+func (d Definition) Validate(value string) error {
+	var err error
+
+	switch d.Type {
+	case BOOLEAN:
+		_, err = strconv.ParseBool(strings.TrimSpace(value))
+	case INT:
+		_, err = strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	case SHORT:
+		_, err = strconv.ParseInt(strings.TrimSpace(value), 10, 16)
+	case LONG:
+		_, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	case DOUBLE:
+		_, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: invalid value %q for configuration %s: expected %s", ErrInvalidConfig, value, d.Name, typeNames[d.Type])
+	}
+
+	if d.Validator != nil {
+		return d.Validator(d.Name, value)
+	}
+
+	return nil
+}
+
+var typeNames = map[Type]string{
+	UNKNOWN_TYPE: "unknown",
+	BOOLEAN:      "boolean",
+	STRING:       "string",
+	INT:          "int",
+	SHORT:        "short",
+	LONG:         "long",
+	DOUBLE:       "double",
+	LIST:         "list",
+	CLASS:        "class",
+	PASSWORD:     "password",
+}
+
+// AtLeast accepts any integer value greater than or equal to min
+func AtLeast(min int64) Validator {
+	return func(name string, value string) error {
+		number, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if number < min {
+			return fmt.Errorf("%w: invalid value %s for configuration %s: value must be at least %d", ErrInvalidConfig, value, name, min)
+		}
+
+		return nil
+	}
+}
+
+// ValidString accepts a single value out of the allowed ones
+func ValidString(allowed ...string) Validator {
+	return func(name string, value string) error {
+		if !slices.Contains(allowed, value) {
+			return fmt.Errorf("%w: invalid value %s for configuration %s: string must be one of: %s", ErrInvalidConfig, value, name, strings.Join(allowed, ", "))
+		}
+
+		return nil
+	}
+}
+
+// ValidList accepts a comma separated list where every item is one of the allowed values
+func ValidList(allowed ...string) Validator {
+	return func(name string, value string) error {
+		for _, item := range SplitList(value) {
+			if !slices.Contains(allowed, item) {
+				return fmt.Errorf("%w: invalid value %s for configuration %s: list items must be one of: %s", ErrInvalidConfig, item, name, strings.Join(allowed, ", "))
+			}
+		}
+
+		return nil
+	}
+}
+
+// ValidThrottledReplicas accepts the wildcard * or a list of [PartitionId]:[BrokerId] entries
+func ValidThrottledReplicas(name string, value string) error {
+	if strings.TrimSpace(value) == "*" {
+		return nil
+	}
+
+	for _, item := range SplitList(value) {
+		partitionId, brokerId, ok := strings.Cut(item, ":")
+		if !ok {
+			return fmt.Errorf("%w: invalid value %s for configuration %s: entries must be [PartitionId]:[BrokerId] or *", ErrInvalidConfig, item, name)
+		}
+		for _, id := range []string{partitionId, brokerId} {
+			if _, err := strconv.ParseInt(strings.TrimSpace(id), 10, 32); err != nil {
+				return fmt.Errorf("%w: invalid value %s for configuration %s: entries must be [PartitionId]:[BrokerId] or *", ErrInvalidConfig, item, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SplitList splits a comma separated list config value, dropping empty items
+func SplitList(value string) []string {
+	items := []string{}
+
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
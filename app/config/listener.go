@@ -0,0 +1,184 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SecurityProtocol is the transport and authentication used by the clients of a listener
+type SecurityProtocol string
+
+const (
+	PLAINTEXT      SecurityProtocol = "PLAINTEXT"
+	SSL            SecurityProtocol = "SSL"
+	SASL_PLAINTEXT SecurityProtocol = "SASL_PLAINTEXT"
+	SASL_SSL       SecurityProtocol = "SASL_SSL"
+)
+
+// UsesSsl tells if connections are wrapped in TLS
+func (p SecurityProtocol) UsesSsl() bool {
+	return p == SSL || p == SASL_SSL
+}
+
+// UsesSasl tells if clients must authenticate with SASL before sending other requests than ApiVersions
+func (p SecurityProtocol) UsesSasl() bool {
+	return p == SASL_PLAINTEXT || p == SASL_SSL
+}
+
+// Id is the number of the protocol in the Kafka protocol, as in the endpoints of BrokerRegistration
+func (p SecurityProtocol) Id() int16 {
+	switch p {
+	case SSL:
+		return 1
+	case SASL_PLAINTEXT:
+		return 2
+	case SASL_SSL:
+		return 3
+	default:
+		return 0
+	}
+}
+
+var ErrInvalidListeners = errors.New("invalid listeners")
+
+// Listener is a named endpoint the broker accepts connections on, along with the endpoint advertised to clients for it
+type Listener struct {
+	Name             string
+	SecurityProtocol SecurityProtocol
+	// Host is empty to bind every interface
+	Host           string
+	Port           int32
+	AdvertisedHost string
+	AdvertisedPort int32
+}
+
+// Address is the address the listener binds
+func (l Listener) Address() string {
+	return net.JoinHostPort(l.Host, strconv.Itoa(int(l.Port)))
+}
+
+// ParseListeners parses listener lists such as PLAINTEXT://:9092,SASL_PLAINTEXT://0.0.0.0:9093.
+// Listeners missing from the advertised ones advertise the address they bind, localhost when they bind every interface
+func ParseListeners(listeners string, advertisedListeners string, protocolMap string) ([]Listener, error) {
+	protocols := make(map[string]SecurityProtocol)
+	for _, entry := range SplitList(protocolMap) {
+		name, protocol, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("%w: invalid listener.security.protocol.map entry %q", ErrInvalidListeners, entry)
+		}
+
+		switch SecurityProtocol(strings.ToUpper(protocol)) {
+		case PLAINTEXT, SSL, SASL_PLAINTEXT, SASL_SSL:
+			protocols[strings.ToUpper(name)] = SecurityProtocol(strings.ToUpper(protocol))
+		default:
+			return nil, fmt.Errorf("%w: unknown security protocol %s", ErrInvalidListeners, protocol)
+		}
+	}
+
+	endpoints, err := parseEndpoints(listeners)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("%w: at least one listener is required", ErrInvalidListeners)
+	}
+
+	advertised, err := parseEndpoints(advertisedListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Listener, 0, len(endpoints))
+	ports := make(map[int32]string)
+
+	for _, endpoint := range endpoints {
+		protocol, ok := protocols[endpoint.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: no security protocol defined for listener %s", ErrInvalidListeners, endpoint.Name)
+		}
+
+		if other, ok := ports[endpoint.Port]; ok {
+			return nil, fmt.Errorf("%w: listeners %s and %s use the same port %d", ErrInvalidListeners, other, endpoint.Name, endpoint.Port)
+		}
+		ports[endpoint.Port] = endpoint.Name
+
+		listener := Listener{
+			Name:             endpoint.Name,
+			SecurityProtocol: protocol,
+			Host:             endpoint.Host,
+			Port:             endpoint.Port,
+			AdvertisedHost:   endpoint.Host,
+			AdvertisedPort:   endpoint.Port,
+		}
+
+		if advertisedEndpoint, ok := findEndpoint(advertised, endpoint.Name); ok {
+			listener.AdvertisedHost = advertisedEndpoint.Host
+			listener.AdvertisedPort = advertisedEndpoint.Port
+		}
+
+		if listener.AdvertisedHost == "" || listener.AdvertisedHost == "0.0.0.0" {
+			listener.AdvertisedHost = "localhost"
+		}
+
+		result = append(result, listener)
+	}
+
+	for _, endpoint := range advertised {
+		if _, ok := findEndpoint(endpoints, endpoint.Name); !ok {
+			return nil, fmt.Errorf("%w: advertised listener %s is not in listeners", ErrInvalidListeners, endpoint.Name)
+		}
+	}
+
+	return result, nil
+}
+
+// endpoint is a single NAME://host:port item of a listener list
+type endpoint struct {
+	Name string
+	Host string
+	Port int32
+}
+
+func parseEndpoints(value string) ([]endpoint, error) {
+	endpoints := []endpoint{}
+
+	for _, item := range SplitList(value) {
+		name, address, found := strings.Cut(item, "://")
+		if !found || name == "" {
+			return nil, fmt.Errorf("%w: %q is not NAME://host:port", ErrInvalidListeners, item)
+		}
+
+		host, portValue, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidListeners, item, err)
+		}
+
+		port, err := strconv.ParseInt(portValue, 10, 32)
+		if err != nil || port < 0 || port > 65535 {
+			return nil, fmt.Errorf("%w: invalid port in %q", ErrInvalidListeners, item)
+		}
+
+		name = strings.ToUpper(name)
+		if _, ok := findEndpoint(endpoints, name); ok {
+			return nil, fmt.Errorf("%w: listener %s is defined twice", ErrInvalidListeners, name)
+		}
+
+		endpoints = append(endpoints, endpoint{Name: name, Host: host, Port: int32(port)})
+	}
+
+	return endpoints, nil
+}
+
+func findEndpoint(endpoints []endpoint, name string) (endpoint, bool) {
+	for _, endpoint := range endpoints {
+		if endpoint.Name == name {
+			return endpoint, true
+		}
+	}
+
+	return endpoint{}, false
+}
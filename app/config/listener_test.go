@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseListeners(t *testing.T) {
+	defaultProtocolMap := "PLAINTEXT:PLAINTEXT,SSL:SSL,SASL_PLAINTEXT:SASL_PLAINTEXT,SASL_SSL:SASL_SSL"
+
+	tests := []struct {
+		name        string
+		listeners   string
+		advertised  string
+		protocolMap string
+		want        []Listener
+		wantErr     bool
+	}{
+		{
+			name:        "Default listener",
+			listeners:   "PLAINTEXT://:9092",
+			protocolMap: defaultProtocolMap,
+			want: []Listener{
+				{Name: "PLAINTEXT", SecurityProtocol: PLAINTEXT, Host: "", Port: 9092, AdvertisedHost: "localhost", AdvertisedPort: 9092},
+			},
+		},
+		{
+			name:        "Advertised listeners",
+			listeners:   "PLAINTEXT://0.0.0.0:9092, sasl_plaintext://127.0.0.1:9093",
+			advertised:  "PLAINTEXT://broker.example:19092",
+			protocolMap: defaultProtocolMap,
+			want: []Listener{
+				{Name: "PLAINTEXT", SecurityProtocol: PLAINTEXT, Host: "0.0.0.0", Port: 9092, AdvertisedHost: "broker.example", AdvertisedPort: 19092},
+				{Name: "SASL_PLAINTEXT", SecurityProtocol: SASL_PLAINTEXT, Host: "127.0.0.1", Port: 9093, AdvertisedHost: "127.0.0.1", AdvertisedPort: 9093},
+			},
+		},
+		{
+			name:        "Custom listener names",
+			listeners:   "INTERNAL://:9092,EXTERNAL://:9094",
+			protocolMap: "INTERNAL:PLAINTEXT,EXTERNAL:SASL_SSL",
+			want: []Listener{
+				{Name: "INTERNAL", SecurityProtocol: PLAINTEXT, Host: "", Port: 9092, AdvertisedHost: "localhost", AdvertisedPort: 9092},
+				{Name: "EXTERNAL", SecurityProtocol: SASL_SSL, Host: "", Port: 9094, AdvertisedHost: "localhost", AdvertisedPort: 9094},
+			},
+		},
+		{
+			name:        "Listener without a security protocol",
+			listeners:   "INTERNAL://:9092",
+			protocolMap: defaultProtocolMap,
+			wantErr:     true,
+		},
+		{
+			name:        "Same port twice",
+			listeners:   "PLAINTEXT://:9092,SSL://:9092",
+			protocolMap: defaultProtocolMap,
+			wantErr:     true,
+		},
+		{
+			name:        "Same name twice",
+			listeners:   "PLAINTEXT://:9092,PLAINTEXT://:9093",
+			protocolMap: defaultProtocolMap,
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown advertised listener",
+			listeners:   "PLAINTEXT://:9092",
+			advertised:  "SSL://localhost:9093",
+			protocolMap: defaultProtocolMap,
+			wantErr:     true,
+		},
+		{
+			name:        "Missing port",
+			listeners:   "PLAINTEXT://localhost",
+			protocolMap: defaultProtocolMap,
+			wantErr:     true,
+		},
+		{
+			name:        "No listener",
+			listeners:   "",
+			protocolMap: defaultProtocolMap,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseListeners(tt.listeners, tt.advertised, tt.protocolMap)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidListeners) {
+					t.Errorf("expected ErrInvalidListeners, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Listeners mismatch:\ngot  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
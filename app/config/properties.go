@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadProperties reads a Java properties file such as server.properties
+func LoadProperties(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseProperties(file)
+}
+
+// ParseProperties parses the Java properties format: key=value, key: value or key value lines,
+// # and ! comments, backslash escapes and lines continued with a trailing backslash
+func ParseProperties(reader io.Reader) (map[string]string, error) {
+	properties := make(map[string]string)
+	scanner := bufio.NewScanner(reader)
+	logical := ""
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		// An odd number of trailing backslashes continues the entry on the next line
+		trailing := len(line) - len(strings.TrimRight(line, `\`))
+		if trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		properties[key] = value
+		logical = ""
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if logical != "" {
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		properties[key] = value
+	}
+
+	return properties, nil
+}
+
+// splitProperty splits an entry at the first unescaped '=', ':' or whitespace
+func splitProperty(entry string) (string, string, error) {
+	end := len(entry)
+	for i := 0; i < len(entry); i++ {
+		if entry[i] == '\\' {
+			i++
+			continue
+		}
+
+		if strings.IndexByte("=: \t\f", entry[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+
+	rest := strings.TrimLeft(entry[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	key, err := unescapeProperty(entry[:end])
+	if err != nil {
+		return "", "", err
+	}
+
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			builder.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			builder.WriteRune(rune(code))
+			i += 4
+		default:
+			builder.WriteByte(s[i])
+		}
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Separators and comments",
+			input: "# Broker settings\n" +
+				"! also a comment\n" +
+				"\n" +
+				"node.id=1\n" +
+				"log.dirs = /tmp/kraft-combined-logs\n" +
+				"process.roles: broker,controller\n" +
+				"controller.listener.names CONTROLLER\n" +
+				"empty.value=\n",
+			want: map[string]string{
+				"node.id":                   "1",
+				"log.dirs":                  "/tmp/kraft-combined-logs",
+				"process.roles":             "broker,controller",
+				"controller.listener.names": "CONTROLLER",
+				"empty.value":               "",
+			},
+		},
+		{
+			name: "Continued lines",
+			input: "listeners=PLAINTEXT://:9092,\\\n" +
+				"    CONTROLLER://:9093\n" +
+				"sasl.jaas.config=org.apache.kafka.common.security.plain.PlainLoginModule required \\\n" +
+				"  user_alice=\"alice-secret\";\n",
+			want: map[string]string{
+				"listeners":        "PLAINTEXT://:9092,CONTROLLER://:9093",
+				"sasl.jaas.config": "org.apache.kafka.common.security.plain.PlainLoginModule required user_alice=\"alice-secret\";",
+			},
+		},
+		{
+			name:  "Escapes",
+			input: "key\\=with\\:separators=tab\\there \\u00e9\nwindows.path=C:\\\\kafka\n",
+			want: map[string]string{
+				"key=with:separators": "tab\there é",
+				"windows.path":        "C:\\kafka",
+			},
+		},
+		{
+			name:    "Malformed unicode escape",
+			input:   "key=\\u00zz\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProperties(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("properties mismatch:\ngot  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,668 @@
+package config
+
+// ResourceType identifies the kind of resource a config belongs to, using the same numbering as the Kafka protocol
+type ResourceType int8
+
+const (
+	UNKNOWN_RESOURCE ResourceType = 0
+	TOPIC            ResourceType = 2
+	BROKER           ResourceType = 4
+	BROKER_LOGGER    ResourceType = 8
+)
+
+type Resource struct {
+	Type ResourceType
+	Name string
+}
+
+var topicDefinitions = []Definition{
+	{
+		Name:          "cleanup.policy",
+		Type:          LIST,
+		Default:       "delete",
+		Validator:     ValidList("compact", "delete"),
+		Documentation: "The retention policy to use on log segments. The \"delete\" policy discards old segments when their retention time or size limit has been reached. The \"compact\" policy removes the records that a later record with the same key replaced.",
+		BrokerSynonym: "log.cleanup.policy",
+	},
+	{
+		Name:          "compression.type",
+		Type:          STRING,
+		Default:       "producer",
+		Validator:     ValidString("producer"),
+		Documentation: "Specify the final compression type for a given topic. Only \"producer\" is supported: the batches are stored with the compression codec set by the producer.",
+		BrokerSynonym: "compression.type",
+	},
+	{
+		Name:          "follower.replication.throttled.replicas",
+		Type:          LIST,
+		Default:       "",
+		Validator:     ValidThrottledReplicas,
+		Documentation: "A list of replicas for which log replication should be throttled on the follower side. The list should describe a set of replicas in the form [PartitionId]:[BrokerId],[PartitionId]:[BrokerId]:... or alternatively the wildcard '*' can be used to throttle all replicas for this topic.",
+	},
+	{
+		Name:          "leader.replication.throttled.replicas",
+		Type:          LIST,
+		Default:       "",
+		Validator:     ValidThrottledReplicas,
+		Documentation: "A list of replicas for which log replication should be throttled on the leader side. The list should describe a set of replicas in the form [PartitionId]:[BrokerId],[PartitionId]:[BrokerId]:... or alternatively the wildcard '*' can be used to throttle all replicas for this topic.",
+	},
+	{
+		Name:          "max.message.bytes",
+		Type:          INT,
+		Default:       "1048588",
+		Validator:     AtLeast(0),
+		Documentation: "The largest record batch size allowed by Kafka (after compression if compression is enabled).",
+		BrokerSynonym: "message.max.bytes",
+	},
+	{
+		Name:          "message.timestamp.type",
+		Type:          STRING,
+		Default:       "CreateTime",
+		Validator:     ValidString("CreateTime", "LogAppendTime"),
+		Documentation: "Define whether the timestamp in the message is message create time or log append time.",
+		BrokerSynonym: "log.message.timestamp.type",
+	},
+	{
+		Name:          "min.insync.replicas",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(1),
+		Documentation: "When a producer sets acks to \"all\", this specifies the minimum number of replicas that must acknowledge a write for the write to be considered successful.",
+		BrokerSynonym: "min.insync.replicas",
+	},
+	{
+		Name:          "retention.bytes",
+		Type:          LONG,
+		Default:       "-1",
+		Documentation: "The maximum size a partition can grow to before old log segments are discarded when using the \"delete\" retention policy. -1 means no size limit.",
+		BrokerSynonym: "log.retention.bytes",
+	},
+	{
+		Name:          "retention.ms",
+		Type:          LONG,
+		Default:       "604800000",
+		Validator:     AtLeast(-1),
+		Documentation: "The maximum time a log is retained before old log segments are discarded when using the \"delete\" retention policy. -1 means no time limit.",
+		BrokerSynonym: "log.retention.ms",
+	},
+	{
+		Name:          "segment.bytes",
+		Type:          INT,
+		Default:       "1073741824",
+		Validator:     AtLeast(14),
+		Documentation: "The segment file size for the log.",
+		BrokerSynonym: "log.segment.bytes",
+	},
+	{
+		Name:          "segment.ms",
+		Type:          LONG,
+		Default:       "604800000",
+		Validator:     AtLeast(1),
+		Documentation: "The period of time after which Kafka will force the log to roll even if the segment file isn't full.",
+		BrokerSynonym: "log.roll.ms",
+	},
+	{
+		Name:          "unclean.leader.election.enable",
+		Type:          BOOLEAN,
+		Default:       "false",
+		Documentation: "Indicates whether to enable replicas not in the ISR set to be elected as leader as a last resort, even though doing so may result in data loss.",
+		BrokerSynonym: "unclean.leader.election.enable",
+	},
+}
+
+var brokerDefinitions = []Definition{
+	{
+		Name:          "advertised.listeners",
+		Type:          STRING,
+		Default:       "",
+		Documentation: "The endpoints given to clients for each listener, in the same NAME://host:port format as listeners. Listeners missing from the list advertise the address they bind.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "allow.everyone.if.no.acl.found",
+		Type:          BOOLEAN,
+		Default:       "true",
+		Documentation: "Whether operations on a resource without any ACL are allowed. The broker always runs its ACL authorizer, so unlike Kafka the default is true: a cluster without ACLs is open, like a Kafka cluster without an authorizer.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "auto.leader.rebalance.enable",
+		Type:          BOOLEAN,
+		Default:       "true",
+		Documentation: "Enables auto leader balancing. The controller checks the leader balance every leader.imbalance.check.interval.seconds and moves the leadership back to the preferred replicas of the brokers above leader.imbalance.per.broker.percentage.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "broker.heartbeat.interval.ms",
+		Type:          INT,
+		Default:       "2000",
+		Validator:     AtLeast(1),
+		Documentation: "The length of time in milliseconds between broker heartbeats.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "broker.session.timeout.ms",
+		Type:          INT,
+		Default:       "9000",
+		Validator:     AtLeast(1),
+		Documentation: "The length of time in milliseconds that a broker lease lasts if no heartbeats are made.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "compression.type",
+		Type:          STRING,
+		Default:       "producer",
+		Validator:     ValidString("producer"),
+		Documentation: "Specify the final compression type for topics that do not override it. Only \"producer\" is supported.",
+	},
+	{
+		Name:          "connections.max.idle.ms",
+		Type:          LONG,
+		Default:       "600000",
+		Validator:     AtLeast(1),
+		Documentation: "Idle connections timeout: connections that send nothing for this many milliseconds are closed.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "connections.max.reauth.ms",
+		Type:          LONG,
+		Default:       "0",
+		Validator:     AtLeast(0),
+		Documentation: "When set to a positive number, authenticated sessions expire after this many milliseconds and clients must re-authenticate before sending other requests. 0 means sessions never expire.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "controller.listener.names",
+		Type:          LIST,
+		Default:       "CONTROLLER",
+		Documentation: "A comma-separated list of the names of the listeners used by the controller, the first one is advertised to the other voters.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "controller.quorum.election.timeout.ms",
+		Type:          INT,
+		Default:       "1000",
+		Validator:     AtLeast(1),
+		Documentation: "Maximum time in milliseconds to wait without being able to fetch from the leader before triggering a new election.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "controller.quorum.fetch.timeout.ms",
+		Type:          INT,
+		Default:       "2000",
+		Validator:     AtLeast(1),
+		Documentation: "Maximum time without a successful fetch from the current leader before becoming a candidate and triggering an election for voters.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "controller.quorum.request.timeout.ms",
+		Type:          INT,
+		Default:       "2000",
+		Validator:     AtLeast(0),
+		Documentation: "The maximum amount of time in milliseconds a voter waits for the response of another voter to a quorum request. A voter that did not answer is retried after controller.quorum.retry.backoff.ms.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "controller.quorum.retry.backoff.ms",
+		Type:          INT,
+		Default:       "20",
+		Validator:     AtLeast(0),
+		Documentation: "The amount of time in milliseconds to wait before sending a quorum request again to a voter that could not be reached.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "controller.quorum.voters",
+		Type:          LIST,
+		Default:       "",
+		Validator:     validQuorumVoters,
+		Documentation: "Map of id/endpoint information for the set of voters in a comma-separated list of {id}@{host}:{port} entries.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "default.replication.factor",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(1),
+		Documentation: "The replication factor of the topics created without one.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "follower.replication.throttled.rate",
+		Type:          LONG,
+		Default:       "9223372036854775807",
+		Validator:     AtLeast(0),
+		Documentation: "The maximum rate in bytes per second of the replication of the replicas in follower.replication.throttled.replicas, on the follower side. While it or leader.replication.throttled.rate is set, reassignments throttle the replication of the partitions they move.",
+	},
+	{
+		Name:          "inter.broker.listener.name",
+		Type:          STRING,
+		Default:       "PLAINTEXT",
+		Documentation: "The listener the brokers use to reach each other, such as the followers fetching from the leaders. It must be a PLAINTEXT listener of every broker.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "leader.imbalance.check.interval.seconds",
+		Type:          LONG,
+		Default:       "300",
+		Validator:     AtLeast(1),
+		Documentation: "The frequency with which the partition rebalance check is triggered by the controller.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "leader.imbalance.per.broker.percentage",
+		Type:          INT,
+		Default:       "10",
+		Validator:     AtLeast(0),
+		Documentation: "The ratio of leader imbalance allowed per broker, as the percentage of the partitions preferring the broker that it does not lead.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "leader.replication.throttled.rate",
+		Type:          LONG,
+		Default:       "9223372036854775807",
+		Validator:     AtLeast(0),
+		Documentation: "The maximum rate in bytes per second of the replication of the replicas in leader.replication.throttled.replicas, on the leader side. While it or follower.replication.throttled.rate is set, reassignments throttle the replication of the partitions they move.",
+	},
+	{
+		Name:          "listener.security.protocol.map",
+		Type:          STRING,
+		Default:       "PLAINTEXT:PLAINTEXT,SSL:SSL,SASL_PLAINTEXT:SASL_PLAINTEXT,SASL_SSL:SASL_SSL",
+		Documentation: "Map between listener names and security protocols, as NAME:PROTOCOL pairs separated by commas.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "listeners",
+		Type:          STRING,
+		Default:       "PLAINTEXT://:9092",
+		Documentation: "The listeners the broker accepts connections on, as NAME://host:port items separated by commas. An empty host binds every interface.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "log.cleanup.policy",
+		Type:          LIST,
+		Default:       "delete",
+		Validator:     ValidList("compact", "delete"),
+		Documentation: "The default cleanup policy for segments beyond the retention window. The \"compact\" policy removes the records that a later record with the same key replaced.",
+	},
+	{
+		Name:          "log.dir",
+		Type:          STRING,
+		Default:       "/tmp/kafka-logs",
+		Documentation: "The directory in which the log data is kept, used when log.dirs is not set.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "log.dirs",
+		Type:          LIST,
+		Default:       "",
+		Documentation: "A comma-separated list of the directories where the log data is stored. If not set, the value in log.dir is used.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "log.message.timestamp.type",
+		Type:          STRING,
+		Default:       "CreateTime",
+		Validator:     ValidString("CreateTime", "LogAppendTime"),
+		Documentation: "Define whether the timestamp in the message is message create time or log append time.",
+	},
+	{
+		Name:          "log.retention.bytes",
+		Type:          LONG,
+		Default:       "-1",
+		Documentation: "The maximum size of the log before deleting it.",
+	},
+	{
+		Name:          "log.retention.check.interval.ms",
+		Type:          LONG,
+		Default:       "300000",
+		Validator:     AtLeast(1),
+		Documentation: "The frequency in milliseconds that the logs are checked for segments to delete.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "log.retention.ms",
+		Type:          LONG,
+		Default:       "604800000",
+		Validator:     AtLeast(-1),
+		Documentation: "The number of milliseconds to keep a log file before deleting it. If set to -1, no time limit is applied.",
+	},
+	{
+		Name:          "log.roll.ms",
+		Type:          LONG,
+		Default:       "604800000",
+		Validator:     AtLeast(1),
+		Documentation: "The maximum time before a new log segment is rolled out.",
+	},
+	{
+		Name:          "log.segment.bytes",
+		Type:          INT,
+		Default:       "1073741824",
+		Validator:     AtLeast(14),
+		Documentation: "The maximum size of a single log file.",
+	},
+	{
+		Name:          "logger.level",
+		Type:          STRING,
+		Default:       "INFO",
+		Validator:     ValidString("DEBUG", "INFO", "WARN", "ERROR"),
+		Documentation: "The level of the broker logs. At DEBUG the request log also includes the hex dump of every request and response.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "max.connections",
+		Type:          INT,
+		Default:       "2147483647",
+		Validator:     AtLeast(0),
+		Documentation: "The maximum number of connections the broker accepts across all listeners. Listeners stop accepting new connections while the limit is reached.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "max.connections.per.ip",
+		Type:          INT,
+		Default:       "2147483647",
+		Validator:     AtLeast(0),
+		Documentation: "The maximum number of connections allowed from each ip address, new connections over the limit are closed.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "max.connections.per.ip.overrides",
+		Type:          STRING,
+		Default:       "",
+		Validator:     validConnectionOverrides,
+		Documentation: "A comma-separated list of per-ip overrides to the default maximum number of connections, for example \"127.0.0.1:200,10.0.0.1:50\".",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "message.max.bytes",
+		Type:          INT,
+		Default:       "1048588",
+		Validator:     AtLeast(0),
+		Documentation: "The largest record batch size allowed by Kafka (after compression if compression is enabled).",
+	},
+	{
+		Name:          "metrics.listener",
+		Type:          STRING,
+		Default:       "",
+		Validator:     validMetricsListener,
+		Documentation: "The host:port of the HTTP endpoint serving the broker metrics at /metrics in the Prometheus text format. Empty disables the endpoint.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "min.insync.replicas",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(1),
+		Documentation: "The minimum number of replicas that must acknowledge a write when a producer sets acks to \"all\".",
+	},
+	{
+		Name:          "node.id",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(0),
+		Documentation: "The node id of the broker.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "num.io.threads",
+		Type:          INT,
+		Default:       "8",
+		Validator:     AtLeast(1),
+		Documentation: "The number of threads that the server uses for processing requests.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "num.partitions",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(1),
+		Documentation: "The number of partitions of the topics created without one.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "offsets.topic.num.partitions",
+		Type:          INT,
+		Default:       "50",
+		Validator:     AtLeast(1),
+		Documentation: "The number of partitions for the offset commit topic.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "offsets.topic.replication.factor",
+		Type:          SHORT,
+		Default:       "3",
+		Validator:     AtLeast(1),
+		Documentation: "The replication factor for the offsets topic. Its creation fails until the cluster has that many brokers.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "process.roles",
+		Type:          LIST,
+		Default:       "broker",
+		Validator:     ValidList("broker", "controller"),
+		Documentation: "The roles that this process plays: 'broker', 'controller', or 'broker,controller' if it is both.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "producer.id.expiration.check.interval.ms",
+		Type:          INT,
+		Default:       "600000",
+		Validator:     AtLeast(1),
+		Documentation: "The interval at which to remove producer IDs that have expired due to producer.id.expiration.ms passing.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "producer.id.expiration.ms",
+		Type:          INT,
+		Default:       "86400000",
+		Validator:     AtLeast(1),
+		Documentation: "The time in ms that a topic partition leader will wait before expiring producer IDs. Producer IDs will not expire while a transaction associated to them is still ongoing.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "quota.window.num",
+		Type:          INT,
+		Default:       "11",
+		Validator:     AtLeast(1),
+		Documentation: "The number of samples to retain in memory for client quotas.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "quota.window.size.seconds",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(1),
+		Documentation: "The time span of each sample for client quotas.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "queued.max.requests",
+		Type:          INT,
+		Default:       "500",
+		Validator:     AtLeast(1),
+		Documentation: "The number of queued requests allowed before connections stop reading.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "replica.fetch.backoff.ms",
+		Type:          INT,
+		Default:       "1000",
+		Validator:     AtLeast(0),
+		Documentation: "The amount of time to sleep when fetch partition error occurs.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "replica.fetch.max.bytes",
+		Type:          INT,
+		Default:       "1048576",
+		Validator:     AtLeast(0),
+		Documentation: "The number of bytes of messages to attempt to fetch for each partition.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "replica.fetch.wait.max.ms",
+		Type:          INT,
+		Default:       "500",
+		Validator:     AtLeast(0),
+		Documentation: "The maximum wait time for each fetcher request issued by follower replicas. This value should always be less than the replica.lag.time.max.ms at all times to prevent frequent shrinking of ISR for low throughput topics.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "replica.lag.time.max.ms",
+		Type:          LONG,
+		Default:       "30000",
+		Validator:     AtLeast(0),
+		Documentation: "If a follower hasn't sent any fetch requests or hasn't consumed up to the leaders log end offset for at least this time, the leader will remove the follower from isr.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "replication.quota.window.num",
+		Type:          INT,
+		Default:       "11",
+		Validator:     AtLeast(1),
+		Documentation: "The number of samples to retain in memory for replication quotas.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "replication.quota.window.size.seconds",
+		Type:          INT,
+		Default:       "1",
+		Validator:     AtLeast(1),
+		Documentation: "The time span of each sample for replication quotas.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "request.logger.slow.threshold.ms",
+		Type:          LONG,
+		Default:       "1000",
+		Validator:     AtLeast(0),
+		Documentation: "Requests that take longer than this are logged at WARN instead of INFO.",
+	},
+	{
+		Name:          "sasl.enabled.mechanisms",
+		Type:          LIST,
+		Default:       "",
+		Validator:     ValidList("PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"),
+		Documentation: "The SASL mechanisms clients of SASL_PLAINTEXT and SASL_SSL listeners may authenticate with.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "sasl.jaas.config",
+		Type:          PASSWORD,
+		Default:       "",
+		Documentation: "JAAS login context parameters. The user_<name>=\"<password>\" options of the PlainLoginModule declare the users that can authenticate with PLAIN.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "socket.request.max.bytes",
+		Type:          INT,
+		Default:       "104857600",
+		Validator:     AtLeast(1),
+		Documentation: "The maximum number of bytes in a socket request.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "ssl.client.auth",
+		Type:          STRING,
+		Default:       "none",
+		Validator:     ValidString("none", "requested", "required"),
+		Documentation: "Whether SSL clients must present a certificate. With \"requested\" a certificate is optional, clients without one are ANONYMOUS.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "ssl.keystore.location",
+		Type:          STRING,
+		Default:       "",
+		Documentation: "The PEM file holding the private key and the certificate chain of the broker, required by SSL and SASL_SSL listeners.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "ssl.principal.mapping.rules",
+		Type:          STRING,
+		Default:       "DEFAULT",
+		Documentation: "Rules mapping the distinguished name of client certificates to user names, written RULE:pattern/replacement/[LU] and separated by commas. DEFAULT keeps the distinguished name.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "ssl.truststore.location",
+		Type:          STRING,
+		Default:       "",
+		Documentation: "The PEM file holding the CA certificates used to verify client certificates.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "super.users",
+		Type:          STRING,
+		Default:       "",
+		Documentation: "The principals allowed to perform every operation regardless of the ACLs, separated by semicolons, such as User:admin;User:broker.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "transaction.abort.timed.out.transaction.cleanup.interval.ms",
+		Type:          INT,
+		Default:       "10000",
+		Validator:     AtLeast(1),
+		Documentation: "The interval at which to rollback transactions that have timed out.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "transaction.max.timeout.ms",
+		Type:          INT,
+		Default:       "900000",
+		Validator:     AtLeast(1),
+		Documentation: "The maximum allowed timeout for transactions. A producer asking for a longer timeout is rejected by InitProducerId.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "transaction.state.log.min.isr",
+		Type:          INT,
+		Default:       "2",
+		Validator:     AtLeast(1),
+		Documentation: "The min.insync.replicas of the transaction topic.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "transaction.state.log.num.partitions",
+		Type:          INT,
+		Default:       "50",
+		Validator:     AtLeast(1),
+		Documentation: "The number of partitions for the transaction topic.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "transaction.state.log.replication.factor",
+		Type:          SHORT,
+		Default:       "3",
+		Validator:     AtLeast(1),
+		Documentation: "The replication factor for the transaction topic. Its creation fails until the cluster has that many brokers.",
+		ReadOnly:      true,
+	},
+	{
+		Name:          "unclean.leader.election.enable",
+		Type:          BOOLEAN,
+		Default:       "false",
+		Documentation: "Indicates whether to enable replicas not in the ISR set to be elected as leader as a last resort.",
+	},
+}
+
+// Definitions returns the configs that can be set on the given resource type, in a stable order
+func Definitions(resourceType ResourceType) []Definition {
+	switch resourceType {
+	case TOPIC:
+		return topicDefinitions
+	case BROKER:
+		return brokerDefinitions
+	default:
+		return nil
+	}
+}
+
+// Lookup returns the definition of a config for the given resource type
+func Lookup(resourceType ResourceType, name string) (Definition, bool) {
+	for _, definition := range Definitions(resourceType) {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+
+	return Definition{}, false
+}
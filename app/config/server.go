@@ -0,0 +1,398 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SslConfig holds the settings of SSL and SASL_SSL listeners
+type SslConfig struct {
+	KeystoreLocation      string
+	TruststoreLocation    string
+	ClientAuth            string
+	PrincipalMappingRules string
+}
+
+// NetworkConfig holds the settings of connections and request processing
+type NetworkConfig struct {
+	NumIoThreads          int
+	QueuedMaxRequests     int
+	SocketRequestMaxBytes int32
+
+	MaxConnections      int
+	MaxConnectionsPerIp int
+	// Limits of specific ip addresses, replacing MaxConnectionsPerIp
+	MaxConnectionsPerIpOverrides map[string]int
+	ConnectionsMaxIdle           time.Duration
+}
+
+// ParseConnectionOverrides parses max.connections.per.ip.overrides, a list of host:count items
+func ParseConnectionOverrides(value string) (map[string]int, error) {
+	overrides := make(map[string]int)
+
+	for _, item := range SplitList(value) {
+		separator := strings.LastIndex(item, ":")
+		if separator <= 0 {
+			return nil, fmt.Errorf("%w: invalid max.connections.per.ip.overrides item %q: expected host:count", ErrInvalidConfig, item)
+		}
+
+		count, err := strconv.Atoi(strings.TrimSpace(item[separator+1:]))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%w: invalid max.connections.per.ip.overrides count in %q", ErrInvalidConfig, item)
+		}
+
+		overrides[strings.TrimSpace(item[:separator])] = count
+	}
+
+	return overrides, nil
+}
+
+func validConnectionOverrides(name string, value string) error {
+	_, err := ParseConnectionOverrides(value)
+	return err
+}
+
+func validMetricsListener(name string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		return fmt.Errorf("%w: invalid value %s for configuration %s: expected host:port: %w", ErrInvalidConfig, value, name, err)
+	}
+	return nil
+}
+
+// QuorumVoter is a voter of the metadata quorum as configured in controller.quorum.voters
+type QuorumVoter struct {
+	Id   int32
+	Host string
+	Port uint16
+}
+
+// QuorumConfig holds the settings of the metadata quorum
+type QuorumConfig struct {
+	Voters          []QuorumVoter
+	ListenerNames   []string
+	ElectionTimeout time.Duration
+	FetchTimeout    time.Duration
+	// A voter waits RequestTimeout for the response of another voter, and RetryBackoff before retrying one that failed
+	RequestTimeout time.Duration
+	RetryBackoff   time.Duration
+	// Brokers heartbeat to the active controller every HeartbeatInterval and are fenced after SessionTimeout without one
+	HeartbeatInterval time.Duration
+	SessionTimeout    time.Duration
+	// UncleanLeaderElection and MinInsyncReplicas are the static unclean.leader.election.enable and
+	// min.insync.replicas, which dynamic configs override
+	UncleanLeaderElection bool
+	MinInsyncReplicas     int
+	// The active controller moves leaders back to their preferred replica every LeaderImbalanceCheckInterval, for
+	// the brokers leading LeaderImbalancePerBrokerPercentage percent fewer partitions than they prefer
+	AutoLeaderRebalance                bool
+	LeaderImbalanceCheckInterval       time.Duration
+	LeaderImbalancePerBrokerPercentage int
+	// The number of partitions and the replication factor of the topics created without them
+	NumPartitions            int
+	DefaultReplicationFactor int
+}
+
+// ParseQuorumVoters parses controller.quorum.voters, a list of id@host:port items
+func ParseQuorumVoters(value string) ([]QuorumVoter, error) {
+	voters := []QuorumVoter{}
+
+	for _, item := range SplitList(value) {
+		id, address, found := strings.Cut(item, "@")
+		if !found {
+			return nil, fmt.Errorf("%w: invalid controller.quorum.voters item %q: expected id@host:port", ErrInvalidConfig, item)
+		}
+
+		voterId, err := strconv.ParseInt(id, 10, 32)
+		if err != nil || voterId < 0 {
+			return nil, fmt.Errorf("%w: invalid controller.quorum.voters id in %q", ErrInvalidConfig, item)
+		}
+
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid controller.quorum.voters address in %q: %w", ErrInvalidConfig, item, err)
+		}
+
+		portNumber, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid controller.quorum.voters port in %q", ErrInvalidConfig, item)
+		}
+
+		voters = append(voters, QuorumVoter{Id: int32(voterId), Host: host, Port: uint16(portNumber)})
+	}
+
+	return voters, nil
+}
+
+func validQuorumVoters(name string, value string) error {
+	_, err := ParseQuorumVoters(value)
+	return err
+}
+
+// ServerConfig is the validated static configuration of the broker.
+// The typed fields are what the broker needs to start, Properties keeps every property for the config store
+type ServerConfig struct {
+	NodeId int32
+	// broker, controller or both
+	ProcessRoles []string
+	Quorum       QuorumConfig
+	LogDirs      []string
+	Listeners    []Listener
+	// The name of the listener the brokers reach each other on
+	InterBrokerListenerName string
+	Ssl                     SslConfig
+	Network                 NetworkConfig
+	// The address of the /metrics endpoint, empty when it is disabled
+	MetricsListener string
+	LoggerLevel     slog.Level
+	Properties      map[string]string
+}
+
+// NewServerConfig validates static properties against the broker config definitions.
+// Properties the broker does not know are kept but otherwise ignored, like Kafka does
+func NewServerConfig(properties map[string]string) (*ServerConfig, error) {
+	for name, value := range properties {
+		definition, ok := Lookup(BROKER, name)
+		if !ok {
+			continue
+		}
+
+		if err := definition.Validate(value); err != nil {
+			return nil, err
+		}
+	}
+
+	value := func(name string) string {
+		if value, ok := properties[name]; ok {
+			return value
+		}
+
+		definition, _ := Lookup(BROKER, name)
+		return definition.Default
+	}
+
+	nodeId, err := strconv.ParseInt(strings.TrimSpace(value("node.id")), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: node.id: %w", ErrInvalidConfig, err)
+	}
+
+	network := NetworkConfig{}
+	for name, setting := range map[string]*int{
+		"num.io.threads":         &network.NumIoThreads,
+		"queued.max.requests":    &network.QueuedMaxRequests,
+		"max.connections":        &network.MaxConnections,
+		"max.connections.per.ip": &network.MaxConnectionsPerIp,
+	} {
+		parsed, err := strconv.Atoi(strings.TrimSpace(value(name)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, name, err)
+		}
+		*setting = parsed
+	}
+
+	socketRequestMaxBytes, err := strconv.ParseInt(strings.TrimSpace(value("socket.request.max.bytes")), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: socket.request.max.bytes: %w", ErrInvalidConfig, err)
+	}
+	network.SocketRequestMaxBytes = int32(socketRequestMaxBytes)
+
+	network.MaxConnectionsPerIpOverrides, err = ParseConnectionOverrides(value("max.connections.per.ip.overrides"))
+	if err != nil {
+		return nil, err
+	}
+
+	idleMs, err := strconv.ParseInt(strings.TrimSpace(value("connections.max.idle.ms")), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: connections.max.idle.ms: %w", ErrInvalidConfig, err)
+	}
+	network.ConnectionsMaxIdle = time.Duration(idleMs) * time.Millisecond
+
+	var loggerLevel slog.Level
+	if err := loggerLevel.UnmarshalText([]byte(value("logger.level"))); err != nil {
+		return nil, fmt.Errorf("%w: logger.level: %w", ErrInvalidConfig, err)
+	}
+
+	// log.dirs takes precedence over log.dir
+	logDirs := SplitList(value("log.dirs"))
+	if len(logDirs) == 0 {
+		logDirs = SplitList(value("log.dir"))
+	}
+
+	processRoles := SplitList(value("process.roles"))
+	quorum := QuorumConfig{ListenerNames: SplitList(value("controller.listener.names"))}
+	quorum.Voters, err = ParseQuorumVoters(value("controller.quorum.voters"))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, setting := range map[string]*time.Duration{
+		"controller.quorum.election.timeout.ms": &quorum.ElectionTimeout,
+		"controller.quorum.fetch.timeout.ms":    &quorum.FetchTimeout,
+		"controller.quorum.request.timeout.ms":  &quorum.RequestTimeout,
+		"controller.quorum.retry.backoff.ms":    &quorum.RetryBackoff,
+		"broker.heartbeat.interval.ms":          &quorum.HeartbeatInterval,
+		"broker.session.timeout.ms":             &quorum.SessionTimeout,
+	} {
+		ms, err := strconv.ParseInt(strings.TrimSpace(value(name)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, name, err)
+		}
+		*setting = time.Duration(ms) * time.Millisecond
+	}
+
+	for name, setting := range map[string]*bool{
+		"unclean.leader.election.enable": &quorum.UncleanLeaderElection,
+		"auto.leader.rebalance.enable":   &quorum.AutoLeaderRebalance,
+	} {
+		*setting, err = strconv.ParseBool(strings.TrimSpace(value(name)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, name, err)
+		}
+	}
+
+	checkIntervalSeconds, err := strconv.ParseInt(strings.TrimSpace(value("leader.imbalance.check.interval.seconds")), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: leader.imbalance.check.interval.seconds: %w", ErrInvalidConfig, err)
+	}
+	quorum.LeaderImbalanceCheckInterval = time.Duration(checkIntervalSeconds) * time.Second
+
+	for name, setting := range map[string]*int{
+		"leader.imbalance.per.broker.percentage": &quorum.LeaderImbalancePerBrokerPercentage,
+		"min.insync.replicas":                    &quorum.MinInsyncReplicas,
+		"num.partitions":                         &quorum.NumPartitions,
+		"default.replication.factor":             &quorum.DefaultReplicationFactor,
+	} {
+		*setting, err = strconv.Atoi(strings.TrimSpace(value(name)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, name, err)
+		}
+	}
+
+	// Controllers are voters of the quorum they run
+	isVoter := slices.ContainsFunc(quorum.Voters, func(voter QuorumVoter) bool {
+		return voter.Id == int32(nodeId)
+	})
+	if slices.Contains(processRoles, "controller") && !isVoter {
+		return nil, fmt.Errorf("%w: controller.quorum.voters must contain node.id %d when process.roles contains controller", ErrInvalidConfig, nodeId)
+	}
+
+	listeners, err := ParseListeners(value("listeners"), value("advertised.listeners"), value("listener.security.protocol.map"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The brokers replicate over PLAINTEXT connections only
+	interBrokerListenerName := strings.TrimSpace(value("inter.broker.listener.name"))
+	if slices.Contains(processRoles, "broker") && !slices.ContainsFunc(listeners, func(listener Listener) bool {
+		return listener.Name == interBrokerListenerName && listener.SecurityProtocol == PLAINTEXT
+	}) {
+		return nil, fmt.Errorf("%w: inter.broker.listener.name %s must be a PLAINTEXT listener", ErrInvalidConfig, interBrokerListenerName)
+	}
+
+	return &ServerConfig{
+		NodeId:                  int32(nodeId),
+		ProcessRoles:            processRoles,
+		Quorum:                  quorum,
+		LogDirs:                 logDirs,
+		Listeners:               listeners,
+		InterBrokerListenerName: interBrokerListenerName,
+		Ssl: SslConfig{
+			KeystoreLocation:      value("ssl.keystore.location"),
+			TruststoreLocation:    value("ssl.truststore.location"),
+			ClientAuth:            value("ssl.client.auth"),
+			PrincipalMappingRules: value("ssl.principal.mapping.rules"),
+		},
+		Network:         network,
+		MetricsListener: value("metrics.listener"),
+		LoggerLevel:     loggerLevel,
+		Properties:      maps.Clone(properties),
+	}, nil
+}
+
+// overrideFlag collects the repeated --override name=value flags
+type overrideFlag map[string]string
+
+func (o overrideFlag) String() string {
+	return fmt.Sprint(map[string]string(o))
+}
+
+func (o overrideFlag) Set(value string) error {
+	name, propertyValue, found := strings.Cut(value, "=")
+	if !found || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+
+	o[name] = propertyValue
+	return nil
+}
+
+var ErrInvalidArguments = errors.New("invalid arguments")
+
+// LoadServerConfig builds the config from the command line, as in `kafka server.properties --override node.id=2`.
+// Properties come from the file, then KAFKA_* environment variables, then --override flags, each overriding the previous ones
+func LoadServerConfig(args []string, environ []string) (*ServerConfig, error) {
+	properties := make(map[string]string)
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		fileProperties, err := LoadProperties(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", args[0], err)
+		}
+
+		maps.Copy(properties, fileProperties)
+		args = args[1:]
+	}
+
+	maps.Copy(properties, environmentProperties(environ))
+
+	overrides := overrideFlag{}
+	flags := flag.NewFlagSet("kafka", flag.ContinueOnError)
+	flags.Var(overrides, "override", "Set a broker property, as name=value. May be repeated")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArguments, err)
+	}
+
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %s", ErrInvalidArguments, flags.Arg(0))
+	}
+
+	maps.Copy(properties, overrides)
+
+	return NewServerConfig(properties)
+}
+
+// environmentProperties maps variables such as KAFKA_NODE_ID to node.id, the convention of the Kafka container images:
+// "_" becomes ".", "__" becomes "_" and "___" becomes "-". Variables that do not name a broker config, like KAFKA_OPTS, are skipped
+func environmentProperties(environ []string) map[string]string {
+	properties := make(map[string]string)
+
+	for _, variable := range environ {
+		name, value, found := strings.Cut(variable, "=")
+		if !found || !strings.HasPrefix(name, "KAFKA_") {
+			continue
+		}
+
+		name = strings.ToLower(strings.TrimPrefix(name, "KAFKA_"))
+		name = strings.ReplaceAll(name, "___", "-")
+		name = strings.ReplaceAll(name, "__", "\x00")
+		name = strings.ReplaceAll(name, "_", ".")
+		name = strings.ReplaceAll(name, "\x00", "_")
+
+		if _, ok := Lookup(BROKER, name); ok {
+			properties[name] = value
+		}
+	}
+
+	return properties
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadServerConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.properties")
+	properties := "node.id=2\n" +
+		"log.dirs=/tmp/kraft-combined-logs\n" +
+		"listeners=PLAINTEXT://:9092,SSL://:9094\n" +
+		"message.max.bytes=1000\n" +
+		"process.roles=broker,controller\n" +
+		"controller.quorum.voters=2@localhost:9093,3@localhost:9095\n"
+	if err := os.WriteFile(path, []byte(properties), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		args           []string
+		environ        []string
+		wantNodeId     int32
+		wantLogDirs    []string
+		wantListeners  []string
+		wantNetwork    *NetworkConfig
+		wantLogLevel   slog.Level
+		wantProperties map[string]string
+		wantErr        error
+	}{
+		{
+			name:          "Defaults",
+			args:          []string{},
+			wantNodeId:    1,
+			wantLogDirs:   []string{"/tmp/kafka-logs"},
+			wantListeners: []string{"PLAINTEXT"},
+			wantNetwork: &NetworkConfig{
+				NumIoThreads:                 8,
+				QueuedMaxRequests:            500,
+				SocketRequestMaxBytes:        104857600,
+				MaxConnections:               2147483647,
+				MaxConnectionsPerIp:          2147483647,
+				MaxConnectionsPerIpOverrides: map[string]int{},
+				ConnectionsMaxIdle:           10 * time.Minute,
+			},
+		},
+		{
+			name:          "Properties file",
+			args:          []string{path},
+			wantNodeId:    2,
+			wantLogDirs:   []string{"/tmp/kraft-combined-logs"},
+			wantListeners: []string{"PLAINTEXT", "SSL"},
+			wantProperties: map[string]string{
+				"message.max.bytes": "1000",
+				"process.roles":     "broker,controller",
+			},
+		},
+		{
+			name:          "Environment and flag overrides",
+			args:          []string{path, "--override", "node.id=3", "-override", "log.dirs=/data/a,/data/b", "--override", "num.io.threads=2", "--override", "max.connections.per.ip.overrides=127.0.0.1:5, ::1:3"},
+			environ:       []string{"KAFKA_NODE_ID=4", "KAFKA_MESSAGE_MAX_BYTES=2000", "KAFKA_OPTS=-Xmx1G", "PATH=/usr/bin"},
+			wantNodeId:    3,
+			wantLogDirs:   []string{"/data/a", "/data/b"},
+			wantListeners: []string{"PLAINTEXT", "SSL"},
+			wantNetwork: &NetworkConfig{
+				NumIoThreads:                 2,
+				QueuedMaxRequests:            500,
+				SocketRequestMaxBytes:        104857600,
+				MaxConnections:               2147483647,
+				MaxConnectionsPerIp:          2147483647,
+				MaxConnectionsPerIpOverrides: map[string]int{"127.0.0.1": 5, "::1": 3},
+				ConnectionsMaxIdle:           10 * time.Minute,
+			},
+			wantProperties: map[string]string{
+				"message.max.bytes": "2000",
+			},
+		},
+		{
+			name:          "Logger level",
+			args:          []string{"--override", "logger.level=DEBUG"},
+			wantNodeId:    1,
+			wantLogDirs:   []string{"/tmp/kafka-logs"},
+			wantListeners: []string{"PLAINTEXT"},
+			wantLogLevel:  slog.LevelDebug,
+		},
+		{
+			name:    "Invalid logger level",
+			args:    []string{"--override", "logger.level=TRACE"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Invalid value",
+			args:    []string{"--override", "node.id=broker"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Invalid connection overrides",
+			args:    []string{"--override", "max.connections.per.ip.overrides=127.0.0.1"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Invalid metrics listener",
+			args:    []string{"--override", "metrics.listener=9404"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Invalid listeners",
+			args:    []string{"--override", "listeners=INTERNAL://:9092"},
+			wantErr: ErrInvalidListeners,
+		},
+		{
+			name:    "Inter-broker listener not PLAINTEXT",
+			args:    []string{path, "--override", "inter.broker.listener.name=SSL"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Controller missing from the voters",
+			args:    []string{path, "--override", "node.id=4"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Invalid quorum voters",
+			args:    []string{"--override", "controller.quorum.voters=1@localhost"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Unexpected argument",
+			args:    []string{path, "other.properties"},
+			wantErr: ErrInvalidArguments,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadServerConfig(tt.args, tt.environ)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got.NodeId != tt.wantNodeId {
+				t.Errorf("NodeId mismatch: got %d, want %d", got.NodeId, tt.wantNodeId)
+			}
+
+			if !reflect.DeepEqual(got.LogDirs, tt.wantLogDirs) {
+				t.Errorf("LogDirs mismatch: got %v, want %v", got.LogDirs, tt.wantLogDirs)
+			}
+
+			if tt.wantNetwork != nil && !reflect.DeepEqual(got.Network, *tt.wantNetwork) {
+				t.Errorf("Network mismatch: got %+v, want %+v", got.Network, *tt.wantNetwork)
+			}
+
+			if got.LoggerLevel != tt.wantLogLevel {
+				t.Errorf("LoggerLevel mismatch: got %v, want %v", got.LoggerLevel, tt.wantLogLevel)
+			}
+
+			listeners := []string{}
+			for _, listener := range got.Listeners {
+				listeners = append(listeners, listener.Name)
+			}
+			if !reflect.DeepEqual(listeners, tt.wantListeners) {
+				t.Errorf("Listeners mismatch: got %v, want %v", listeners, tt.wantListeners)
+			}
+
+			for name, want := range tt.wantProperties {
+				if got.Properties[name] != want {
+					t.Errorf("property %s mismatch: got %q, want %q", name, got.Properties[name], want)
+				}
+			}
+
+			if _, ok := got.Properties["opts"]; ok {
+				t.Errorf("KAFKA_OPTS must not become a property")
+			}
+		})
+	}
+}
+
+func TestParseQuorumVoters(t *testing.T) {
+	got, err := ParseQuorumVoters("1@localhost:9093, 2@[::1]:9094")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []QuorumVoter{{Id: 1, Host: "localhost", Port: 9093}, {Id: 2, Host: "::1", Port: 9094}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("voters mismatch: got %+v, want %+v", got, want)
+	}
+
+	for _, value := range []string{"localhost:9093", "-1@localhost:9093", "1@localhost:99999"} {
+		if _, err := ParseQuorumVoters(value); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %q, got %v", value, err)
+		}
+	}
+}
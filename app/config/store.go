@@ -0,0 +1,477 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidConfig  = errors.New("invalid config")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Source tells where the value of a config comes from, using the same numbering as the config_source field of DescribeConfigs
+type Source int8
+
+const (
+	UNKNOWN_SOURCE Source = iota
+	TOPIC_CONFIG
+	DYNAMIC_BROKER_CONFIG
+	DYNAMIC_DEFAULT_BROKER_CONFIG
+	STATIC_BROKER_CONFIG
+	DEFAULT_CONFIG
+	DYNAMIC_BROKER_LOGGER_CONFIG
+)
+
+// OpType is the operation applied to a single config by IncrementalAlterConfigs
+type OpType int8
+
+const (
+	SET OpType = iota
+	DELETE
+	APPEND
+	SUBTRACT
+)
+
+type Synonym struct {
+	Name   string
+	Value  *string
+	Source Source
+}
+
+type Entry struct {
+	Definition Definition
+	// Value is nil for sensitive configs so that secrets never leave the broker
+	Value    *string
+	Source   Source
+	Synonyms []Synonym
+}
+
+type AlterOp struct {
+	Name  string
+	Op    OpType
+	Value *string
+}
+
+// Store keeps the dynamic configs of topics and brokers, as loaded from the metadata image, on top of the static
+// broker configs.
+// The effective value of a config is resolved in the same order as Kafka does:
+// topic override -> dynamic per-broker -> dynamic cluster-wide default -> static broker config -> default value
+type Store struct {
+	mutex                sync.RWMutex
+	nodeId               string
+	static               map[string]string
+	dynamicDefaultBroker map[string]string
+	// The dynamic configs of every broker by id, the controller alters those of the other brokers
+	dynamicBrokers map[string]map[string]string
+	topics         map[string]map[string]string
+	watchers       []func(Resource)
+}
+
+func NewStore(nodeId int32, static map[string]string) *Store {
+	return &Store{
+		nodeId:               strconv.Itoa(int(nodeId)),
+		static:               maps.Clone(static),
+		dynamicDefaultBroker: make(map[string]string),
+		dynamicBrokers:       make(map[string]map[string]string),
+		topics:               make(map[string]map[string]string),
+	}
+}
+
+// Watch registers a callback that is invoked after the configs of a resource have changed
+// so that running components (e.g. the log layer) can pick up the new values without a restart
+func (s *Store) Watch(callback func(Resource)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.watchers = append(s.watchers, callback)
+}
+
+// Describe returns the configs of a resource. When names is nil every known config is returned. Only the configs of
+// this broker can be described, the static configs of the others are unknown
+func (s *Store) Describe(resource Resource, names []string) ([]Entry, error) {
+	if err := s.checkResource(resource); err != nil {
+		return nil, err
+	}
+	if resource.Type == BROKER && resource.Name != "" && resource.Name != s.nodeId {
+		return nil, fmt.Errorf("%w: unexpected broker id, expected %s or empty string, but received %s", ErrInvalidRequest, s.nodeId, resource.Name)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries := []Entry{}
+
+	for _, definition := range Definitions(resource.Type) {
+		if names != nil && !slices.Contains(names, definition.Name) {
+			continue
+		}
+
+		synonyms := s.synonyms(resource, definition)
+		if definition.IsSensitive() {
+			for i := range synonyms {
+				synonyms[i].Value = nil
+			}
+		}
+
+		entry := Entry{
+			Definition: definition,
+			Value:      synonyms[0].Value,
+			Source:     synonyms[0].Source,
+			Synonyms:   synonyms,
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Value returns the effective value of a config of a resource
+func (s *Store) Value(resource Resource, name string) (string, error) {
+	definition, ok := Lookup(resource.Type, name)
+	if !ok {
+		return "", fmt.Errorf("%w: unknown config %s", ErrInvalidConfig, name)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.effectiveValue(resource, definition), nil
+}
+
+func (s *Store) Int64(resource Resource, name string) (int64, error) {
+	value, err := s.Value(resource, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+}
+
+func (s *Store) Bool(resource Resource, name string) (bool, error) {
+	value, err := s.Value(resource, name)
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
+
+// Alter returns the changes that replace all the dynamic configs of a resource with the given ones (AlterConfigs
+// semantics), a nil value deletes a config. The store does not change, the changes are committed to the metadata log
+// by the controller and reach the store once Load is called with the configs of the new metadata image
+func (s *Store) Alter(resource Resource, configs map[string]string) (map[string]*string, error) {
+	if err := s.checkResource(resource); err != nil {
+		return nil, err
+	}
+
+	for name, value := range configs {
+		if err := validate(resource, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return configChanges(s.dynamicConfigs(resource), configs), nil
+}
+
+// IncrementalAlter returns the changes that apply a list of SET/DELETE/APPEND/SUBTRACT operations to the dynamic
+// configs of a resource, like Alter. Either all the operations are valid or no change is returned
+func (s *Store) IncrementalAlter(resource Resource, ops []AlterOp) (map[string]*string, error) {
+	if err := s.checkResource(resource); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		if seen[op.Name] {
+			return nil, fmt.Errorf("%w: duplicate config key %s", ErrInvalidRequest, op.Name)
+		}
+
+		seen[op.Name] = true
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	configs, err := s.applyOps(resource, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	return configChanges(s.dynamicConfigs(resource), configs), nil
+}
+
+// Load replaces the dynamic configs with the ones of the metadata image, by resource. The watchers are called for
+// every resource whose configs changed
+func (s *Store) Load(dynamic map[Resource]map[string]string) {
+	s.mutex.Lock()
+	changed := []Resource{}
+	topics := make(map[string]map[string]string)
+	for resource, configs := range dynamic {
+		if resource.Type == TOPIC {
+			topics[resource.Name] = maps.Clone(configs)
+		}
+	}
+	for name := range topics {
+		if !maps.Equal(s.topics[name], topics[name]) {
+			changed = append(changed, Resource{Type: TOPIC, Name: name})
+		}
+	}
+	for name := range s.topics {
+		if _, ok := topics[name]; !ok {
+			changed = append(changed, Resource{Type: TOPIC, Name: name})
+		}
+	}
+	s.topics = topics
+
+	brokers := []Resource{{Type: BROKER, Name: ""}}
+	for resource := range dynamic {
+		if resource.Type == BROKER && resource.Name != "" {
+			brokers = append(brokers, resource)
+		}
+	}
+	for name := range s.dynamicBrokers {
+		if _, ok := dynamic[Resource{Type: BROKER, Name: name}]; !ok {
+			brokers = append(brokers, Resource{Type: BROKER, Name: name})
+		}
+	}
+
+	for _, resource := range brokers {
+		configs := maps.Clone(dynamic[resource])
+		if configs == nil {
+			configs = make(map[string]string)
+		}
+		if !maps.Equal(s.dynamicConfigs(resource), configs) {
+			s.setDynamicConfigs(resource, configs)
+			changed = append(changed, resource)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, resource := range changed {
+		s.notify(resource)
+	}
+}
+
+// Apply changes the dynamic configs of a resource right away, a nil value deletes a config. It is only used without a
+// metadata quorum, where no metadata image ever loads the configs
+func (s *Store) Apply(resource Resource, changes map[string]*string) {
+	s.mutex.Lock()
+	configs := maps.Clone(s.dynamicConfigs(resource))
+	if configs == nil {
+		configs = make(map[string]string)
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(configs, name)
+		} else {
+			configs[name] = *value
+		}
+	}
+	s.setDynamicConfigs(resource, configs)
+	s.mutex.Unlock()
+
+	s.notify(resource)
+}
+
+// configChanges returns the configs to set and, with a nil value, to delete to go from current to configs
+func configChanges(current map[string]string, configs map[string]string) map[string]*string {
+	changes := make(map[string]*string)
+	for name, value := range configs {
+		if currentValue, ok := current[name]; !ok || currentValue != value {
+			changes[name] = &value
+		}
+	}
+	for name := range current {
+		if _, ok := configs[name]; !ok {
+			changes[name] = nil
+		}
+	}
+	return changes
+}
+
+// applyOps returns the dynamic configs of the resource as they would be after applying the operations
+func (s *Store) applyOps(resource Resource, ops []AlterOp) (map[string]string, error) {
+	configs := maps.Clone(s.dynamicConfigs(resource))
+	if configs == nil {
+		configs = make(map[string]string)
+	}
+
+	for _, op := range ops {
+		definition, ok := Lookup(resource.Type, op.Name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown config %s", ErrInvalidConfig, op.Name)
+		}
+
+		switch op.Op {
+		case SET:
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: null value not supported for config %s", ErrInvalidRequest, op.Name)
+			}
+
+			configs[op.Name] = *op.Value
+		case DELETE:
+			delete(configs, op.Name)
+		case APPEND, SUBTRACT:
+			if definition.Type != LIST {
+				return nil, fmt.Errorf("%w: config %s is not a list so it does not support append or subtract", ErrInvalidConfig, op.Name)
+			}
+
+			current, ok := configs[op.Name]
+			if !ok {
+				current = s.effectiveValue(resource, definition)
+			}
+
+			items := SplitList(current)
+			var values []string
+			if op.Value != nil {
+				values = SplitList(*op.Value)
+			}
+
+			for _, value := range values {
+				if op.Op == APPEND && !slices.Contains(items, value) {
+					items = append(items, value)
+				}
+
+				if op.Op == SUBTRACT {
+					items = slices.DeleteFunc(items, func(item string) bool { return item == value })
+				}
+			}
+
+			configs[op.Name] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("%w: unknown config operation %d", ErrInvalidRequest, op.Op)
+		}
+	}
+
+	for name, value := range configs {
+		if err := validate(resource, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return configs, nil
+}
+
+func (s *Store) checkResource(resource Resource) error {
+	switch resource.Type {
+	case TOPIC:
+		if resource.Name == "" {
+			return fmt.Errorf("%w: topic name must not be empty", ErrInvalidRequest)
+		}
+	case BROKER:
+		// An empty name refers to the cluster-wide default of all brokers
+		if resource.Name == "" {
+			break
+		}
+		if brokerId, err := strconv.ParseInt(resource.Name, 10, 32); err != nil || brokerId < 0 {
+			return fmt.Errorf("%w: invalid broker id %s", ErrInvalidRequest, resource.Name)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported resource type %d", ErrInvalidRequest, resource.Type)
+	}
+
+	return nil
+}
+
+func validate(resource Resource, name string, value string) error {
+	definition, ok := Lookup(resource.Type, name)
+	if !ok {
+		return fmt.Errorf("%w: unknown config %s", ErrInvalidConfig, name)
+	}
+
+	if definition.ReadOnly {
+		return fmt.Errorf("%w: cannot update config %s dynamically", ErrInvalidRequest, name)
+	}
+
+	return definition.Validate(value)
+}
+
+func (s *Store) dynamicConfigs(resource Resource) map[string]string {
+	switch {
+	case resource.Type == TOPIC:
+		return s.topics[resource.Name]
+	case resource.Name == "":
+		return s.dynamicDefaultBroker
+	default:
+		return s.dynamicBrokers[resource.Name]
+	}
+}
+
+func (s *Store) setDynamicConfigs(resource Resource, configs map[string]string) {
+	switch {
+	case resource.Type == TOPIC:
+		s.topics[resource.Name] = configs
+	case resource.Name == "":
+		s.dynamicDefaultBroker = configs
+	default:
+		s.dynamicBrokers[resource.Name] = configs
+	}
+}
+
+func (s *Store) notify(resource Resource) {
+	s.mutex.RLock()
+	watchers := slices.Clone(s.watchers)
+	s.mutex.RUnlock()
+
+	for _, watcher := range watchers {
+		watcher(resource)
+	}
+}
+
+func (s *Store) effectiveValue(resource Resource, definition Definition) string {
+	return *s.synonyms(resource, definition)[0].Value
+}
+
+// synonyms lists every level that provides a value for the config, from the highest to the lowest precedence
+// The last synonym is always the default value so the list is never empty
+func (s *Store) synonyms(resource Resource, definition Definition) []Synonym {
+	synonyms := []Synonym{}
+	brokerName := definition.Name
+
+	if resource.Type == TOPIC {
+		if value, ok := s.topics[resource.Name][definition.Name]; ok {
+			synonyms = append(synonyms, Synonym{Name: definition.Name, Value: &value, Source: TOPIC_CONFIG})
+		}
+
+		// A topic config without broker synonym only has its topic and default values
+		if definition.BrokerSynonym == "" {
+			defaultValue := definition.Default
+			return append(synonyms, Synonym{Name: definition.Name, Value: &defaultValue, Source: DEFAULT_CONFIG})
+		}
+		brokerName = definition.BrokerSynonym
+	}
+
+	// The cluster-wide default resource does not see the configs of the individual broker, topics see the ones of this
+	// broker
+	brokerId := resource.Name
+	if resource.Type == TOPIC {
+		brokerId = s.nodeId
+	}
+	if brokerId != "" {
+		if value, ok := s.dynamicBrokers[brokerId][brokerName]; ok {
+			synonyms = append(synonyms, Synonym{Name: brokerName, Value: &value, Source: DYNAMIC_BROKER_CONFIG})
+		}
+	}
+
+	if value, ok := s.dynamicDefaultBroker[brokerName]; ok {
+		synonyms = append(synonyms, Synonym{Name: brokerName, Value: &value, Source: DYNAMIC_DEFAULT_BROKER_CONFIG})
+	}
+
+	if value, ok := s.static[brokerName]; ok {
+		synonyms = append(synonyms, Synonym{Name: brokerName, Value: &value, Source: STATIC_BROKER_CONFIG})
+	}
+
+	defaultValue := definition.Default
+	synonyms = append(synonyms, Synonym{Name: brokerName, Value: &defaultValue, Source: DEFAULT_CONFIG})
+
+	return synonyms
+}
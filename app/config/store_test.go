@@ -0,0 +1,433 @@
+package config
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"testing"
+)
+
+// commit applies changes to the dynamic configs of store the way the metadata image does, and loads the result
+func commit(store *Store, resource Resource, changes map[string]*string) {
+	dynamic := map[Resource]map[string]string{
+		{Type: BROKER, Name: ""}: maps.Clone(store.dynamicDefaultBroker),
+	}
+	for id, configs := range store.dynamicBrokers {
+		dynamic[Resource{Type: BROKER, Name: id}] = maps.Clone(configs)
+	}
+	for name, configs := range store.topics {
+		dynamic[Resource{Type: TOPIC, Name: name}] = maps.Clone(configs)
+	}
+	if dynamic[resource] == nil {
+		dynamic[resource] = make(map[string]string)
+	}
+
+	for name, value := range changes {
+		if value == nil {
+			delete(dynamic[resource], name)
+		} else {
+			dynamic[resource][name] = *value
+		}
+	}
+	store.Load(dynamic)
+}
+
+// alter replaces the dynamic configs of a resource and commits the changes
+func alter(t *testing.T, store *Store, resource Resource, configs map[string]string) {
+	t.Helper()
+
+	changes, err := store.Alter(resource, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(store, resource, changes)
+}
+
+func TestStoreDescribe(t *testing.T) {
+	store := NewStore(1, map[string]string{"log.retention.ms": "1000"})
+
+	alter(t, store, Resource{Type: BROKER, Name: "1"}, map[string]string{"log.segment.bytes": "2048"})
+	alter(t, store, Resource{Type: TOPIC, Name: "foo"}, map[string]string{"cleanup.policy": "compact"})
+
+	tests := []struct {
+		name       string
+		resource   Resource
+		config     string
+		wantValue  string
+		wantSource Source
+		wantErr    error
+	}{
+		{
+			name:       "Topic override",
+			resource:   Resource{Type: TOPIC, Name: "foo"},
+			config:     "cleanup.policy",
+			wantValue:  "compact",
+			wantSource: TOPIC_CONFIG,
+		},
+		{
+			name:       "Topic config inherited from dynamic broker config",
+			resource:   Resource{Type: TOPIC, Name: "foo"},
+			config:     "segment.bytes",
+			wantValue:  "2048",
+			wantSource: DYNAMIC_BROKER_CONFIG,
+		},
+		{
+			name:       "Topic config inherited from static broker config",
+			resource:   Resource{Type: TOPIC, Name: "foo"},
+			config:     "retention.ms",
+			wantValue:  "1000",
+			wantSource: STATIC_BROKER_CONFIG,
+		},
+		{
+			name:       "Topic config default",
+			resource:   Resource{Type: TOPIC, Name: "bar"},
+			config:     "cleanup.policy",
+			wantValue:  "delete",
+			wantSource: DEFAULT_CONFIG,
+		},
+		{
+			name:       "Topic config without broker synonym",
+			resource:   Resource{Type: TOPIC, Name: "bar"},
+			config:     "leader.replication.throttled.replicas",
+			wantValue:  "",
+			wantSource: DEFAULT_CONFIG,
+		},
+		{
+			name:       "Cluster default does not see per-broker configs",
+			resource:   Resource{Type: BROKER, Name: ""},
+			config:     "log.segment.bytes",
+			wantValue:  "1073741824",
+			wantSource: DEFAULT_CONFIG,
+		},
+		{
+			name:     "Other broker id",
+			resource: Resource{Type: BROKER, Name: "2"},
+			config:   "log.segment.bytes",
+			wantErr:  ErrInvalidRequest,
+		},
+		{
+			name:     "Unsupported resource type",
+			resource: Resource{Type: BROKER_LOGGER, Name: "1"},
+			config:   "log.segment.bytes",
+			wantErr:  ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := store.Describe(tt.resource, []string{tt.config})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected error %v but got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(entries))
+			}
+
+			if *entries[0].Value != tt.wantValue {
+				t.Errorf("Value mismatch: got %s, want %s", *entries[0].Value, tt.wantValue)
+			}
+
+			if entries[0].Source != tt.wantSource {
+				t.Errorf("Source mismatch: got %d, want %d", entries[0].Source, tt.wantSource)
+			}
+
+			for _, synonym := range entries[0].Synonyms {
+				if synonym.Name == "" {
+					t.Errorf("expected every synonym to be named, got %+v", entries[0].Synonyms)
+				}
+			}
+
+			lastSynonym := entries[0].Synonyms[len(entries[0].Synonyms)-1]
+			if lastSynonym.Source != DEFAULT_CONFIG {
+				t.Errorf("last synonym should be the default, got source %d", lastSynonym.Source)
+			}
+		})
+	}
+}
+
+func TestStoreAlter(t *testing.T) {
+	tests := []struct {
+		name      string
+		configs   map[string]string
+		wantValue string
+		wantErr   error
+	}{
+		{
+			name:      "Valid value",
+			configs:   map[string]string{"retention.ms": "5000"},
+			wantValue: "5000",
+		},
+		{
+			name:      "A config left out falls back to the default",
+			configs:   map[string]string{},
+			wantValue: "604800000",
+		},
+		{
+			name:    "Unknown config",
+			configs: map[string]string{"retention.foo": "5000"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Value of the wrong type",
+			configs: map[string]string{"retention.ms": "forever"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Value rejected by the validator",
+			configs: map[string]string{"retention.ms": "-2"},
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(1, map[string]string{})
+			resource := Resource{Type: TOPIC, Name: "foo"}
+			alter(t, store, resource, map[string]string{"retention.ms": "1"})
+
+			changes, err := store.Alter(resource, tt.configs)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected error %v but got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			commit(store, resource, changes)
+
+			value, err := store.Value(resource, "retention.ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != tt.wantValue {
+				t.Errorf("Value mismatch: got %s, want %s", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestStoreIncrementalAlter(t *testing.T) {
+	stringPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name      string
+		initial   map[string]string
+		ops       []AlterOp
+		wantValue string
+		wantErr   error
+	}{
+		{
+			name:      "Set",
+			initial:   map[string]string{},
+			ops:       []AlterOp{{Name: "cleanup.policy", Op: SET, Value: stringPtr("compact")}},
+			wantValue: "compact",
+		},
+		{
+			name:      "Delete falls back to the default",
+			initial:   map[string]string{"cleanup.policy": "compact"},
+			ops:       []AlterOp{{Name: "cleanup.policy", Op: DELETE}},
+			wantValue: "delete",
+		},
+		{
+			name:      "Append to the default value",
+			initial:   map[string]string{},
+			ops:       []AlterOp{{Name: "cleanup.policy", Op: APPEND, Value: stringPtr("compact")}},
+			wantValue: "delete,compact",
+		},
+		{
+			name:      "Append an existing item",
+			initial:   map[string]string{"cleanup.policy": "compact"},
+			ops:       []AlterOp{{Name: "cleanup.policy", Op: APPEND, Value: stringPtr("compact")}},
+			wantValue: "compact",
+		},
+		{
+			name:      "Subtract",
+			initial:   map[string]string{"cleanup.policy": "compact,delete"},
+			ops:       []AlterOp{{Name: "cleanup.policy", Op: SUBTRACT, Value: stringPtr("delete")}},
+			wantValue: "compact",
+		},
+		{
+			name:    "Append to a non list config",
+			initial: map[string]string{},
+			ops:     []AlterOp{{Name: "retention.ms", Op: APPEND, Value: stringPtr("1")}},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Set with a null value",
+			initial: map[string]string{},
+			ops:     []AlterOp{{Name: "retention.ms", Op: SET}},
+			wantErr: ErrInvalidRequest,
+		},
+		{
+			name:    "Duplicate config",
+			initial: map[string]string{},
+			ops: []AlterOp{
+				{Name: "cleanup.policy", Op: SET, Value: stringPtr("compact")},
+				{Name: "cleanup.policy", Op: DELETE},
+			},
+			wantErr: ErrInvalidRequest,
+		},
+		{
+			name:    "Invalid list item",
+			initial: map[string]string{},
+			ops:     []AlterOp{{Name: "cleanup.policy", Op: APPEND, Value: stringPtr("archive")}},
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(1, map[string]string{})
+			resource := Resource{Type: TOPIC, Name: "foo"}
+
+			alter(t, store, resource, tt.initial)
+
+			changes, err := store.IncrementalAlter(resource, tt.ops)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected error %v but got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			commit(store, resource, changes)
+
+			value, err := store.Value(resource, "cleanup.policy")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != tt.wantValue {
+				t.Errorf("Value mismatch: got %s, want %s", value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestStoreWatch(t *testing.T) {
+	store := NewStore(1, map[string]string{})
+
+	var changed []Resource
+	store.Watch(func(resource Resource) {
+		changed = append(changed, resource)
+	})
+
+	resource := Resource{Type: TOPIC, Name: "foo"}
+
+	changes, err := store.Alter(resource, map[string]string{"retention.ms": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 0 {
+		t.Errorf("changes that are not committed must not notify watchers, got %v", changed)
+	}
+
+	commit(store, resource, changes)
+	// Loading an image that does not change the configs notifies no one
+	commit(store, resource, nil)
+
+	if len(changed) != 1 || changed[0] != resource {
+		t.Errorf("expected a single notification for %v, got %v", resource, changed)
+	}
+
+	retention, err := store.Int64(resource, "retention.ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if retention != 1 {
+		t.Errorf("retention.ms mismatch: got %d, want 1", retention)
+	}
+}
+
+func TestStoreAlterChanges(t *testing.T) {
+	store := NewStore(1, map[string]string{})
+	resource := Resource{Type: TOPIC, Name: "foo"}
+	alter(t, store, resource, map[string]string{"retention.ms": "1", "cleanup.policy": "compact"})
+
+	// Only the configs that differ are changed, the ones left out are deleted
+	changes, err := store.Alter(resource, map[string]string{"retention.ms": "1", "segment.bytes": "2048"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segmentBytes := "2048"
+	want := map[string]*string{"segment.bytes": &segmentBytes, "cleanup.policy": nil}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes mismatch: got %v, want %v", changes, want)
+	}
+}
+
+func TestStoreAlterOtherBroker(t *testing.T) {
+	store := NewStore(1, map[string]string{})
+	other := Resource{Type: BROKER, Name: "2"}
+
+	// The controller alters the configs of every broker, against the ones the metadata image holds for them
+	alter(t, store, other, map[string]string{"log.retention.ms": "1000"})
+	changes, err := store.Alter(other, map[string]string{"log.retention.ms": "1000", "log.segment.bytes": "2048"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segmentBytes := "2048"
+	if want := map[string]*string{"log.segment.bytes": &segmentBytes}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes mismatch: got %v, want %v", changes, want)
+	}
+
+	// The configs of another broker do not apply to this one
+	if value, _ := store.Value(Resource{Type: BROKER, Name: "1"}, "log.retention.ms"); value != "604800000" {
+		t.Errorf("log.retention.ms mismatch: got %s, want the default", value)
+	}
+
+	if _, err := store.Alter(Resource{Type: BROKER, Name: "broker-2"}, map[string]string{}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest for an invalid broker id, got %v", err)
+	}
+}
+
+func TestStoreApply(t *testing.T) {
+	store := NewStore(1, map[string]string{})
+
+	var changed []Resource
+	store.Watch(func(resource Resource) {
+		changed = append(changed, resource)
+	})
+
+	// Without a metadata quorum the changes are applied right away
+	resource := Resource{Type: BROKER, Name: "1"}
+	changes, err := store.Alter(resource, map[string]string{"log.retention.ms": "1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Apply(resource, changes)
+
+	if value, _ := store.Value(resource, "log.retention.ms"); value != "1000" {
+		t.Errorf("log.retention.ms mismatch: got %s, want 1000", value)
+	}
+	if len(changed) != 1 || changed[0] != resource {
+		t.Errorf("expected a single notification for %v, got %v", resource, changed)
+	}
+
+	store.Apply(resource, map[string]*string{"log.retention.ms": nil})
+	if value, _ := store.Value(resource, "log.retention.ms"); value != "604800000" {
+		t.Errorf("log.retention.ms mismatch: got %s, want the default", value)
+	}
+}
@@ -1,61 +1,173 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"runtime/debug"
+	"time"
 
+	"github.com/codecrafters-io/kafka-starter-go/app/network"
 	"github.com/codecrafters-io/kafka-starter-go/app/request"
 )
 
-func listenForConnections(listener net.Listener) {
+// Delay before accepting again after an accept error, so that e.g. running out of file descriptors does not spin
+const acceptRetryDelay = 100 * time.Millisecond
+
+// Requests of a connection read ahead of their response being written, clients default to 5 (max.in.flight.requests.per.connection)
+const maxInFlightRequestsPerConnection = 100
+
+// listenForConnections accepts connections until the listener is closed
+func (s *server) listenForConnections(listener *brokerListener) {
+	logger := s.logger.With("listener", listener.config.Name)
+
 	for {
+		// Like Kafka, listeners stop accepting while max.connections connections are open
+		if !s.connectionQuotas.WaitForSlot() {
+			return
+		}
+
 		connection, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
 		if err != nil {
-			fmt.Println("Error accepting connection: ", err.Error())
-			os.Exit(1)
+			logger.Error("Error accepting connection", "error", err)
+			time.Sleep(acceptRetryDelay)
+			continue
+		}
+
+		clientHost := remoteHost(connection)
+		if err := s.connectionQuotas.Acquire(clientHost); err != nil {
+			logger.Warn("Refusing connection", "client", connection.RemoteAddr().String(), "error", err)
+			connection.Close()
+			continue
 		}
 
-		go handleConnection(connection)
+		if !s.track(connection) {
+			s.connectionQuotas.Release(clientHost)
+			connection.Close()
+			return
+		}
+
+		go s.handleConnection(connection, listener, clientHost)
+	}
+}
+
+func remoteHost(connection net.Conn) string {
+	host, _, err := net.SplitHostPort(connection.RemoteAddr().String())
+	if err != nil {
+		return connection.RemoteAddr().String()
 	}
+	return host
 }
 
-func handleConnection(connection net.Conn) {
-	buffer := make([]byte, 1024)
-	broker := request.NewKafkaBroker()
+// armIdleTimeout closes connections that send nothing for connections.max.idle.ms. It returns false once the server is
+// shutting down, it is checked after the deadline is set so that it can never replace the one set by shutdown
+func (s *server) armIdleTimeout(connection net.Conn) bool {
+	connection.SetReadDeadline(time.Now().Add(s.connectionsMaxIdle))
+	return !s.isShuttingDown()
+}
+
+// The broker is shared by every connection so that state such as the config store is the same for all clients
+func (s *server) handleConnection(connection net.Conn, listener *brokerListener, clientHost string) {
+	defer s.untrack(connection)
+	defer s.connectionQuotas.Release(clientHost)
+
+	session := request.NewSession(clientHost)
+	session.Listener = listener.config
+	session.ConnectionId = fmt.Sprintf("%s-%s-%d", connection.LocalAddr(), connection.RemoteAddr(), s.connectionIndex.Add(1))
+	// The same attributes as the request log, so that the lines of a connection can be correlated
+	logger := s.logger.With("connection", session.ConnectionId, "listener", listener.config.Name)
+	logger.Debug("Accepted connection")
 
 	defer func() {
-		if err := connection.Close(); err != nil {
-			fmt.Println("Error closing connection:", err)
+		if err := connection.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Warn("Error closing connection", "error", err)
 		}
 	}()
 
-	for {
-		numberOfBytesRead, err := connection.Read(buffer)
+	// SSL clients are identified by their certificate until they authenticate with SASL
+	if tlsConnection, ok := connection.(*tls.Conn); ok {
+		if !s.armIdleTimeout(connection) {
+			return
+		}
+
+		if err := tlsConnection.Handshake(); err != nil {
+			logger.Warn("SSL handshake failed", "error", err)
+			return
+		}
+
+		principal, err := listener.principalMapper.Principal(tlsConnection.ConnectionState())
 		if err != nil {
-			fmt.Println("Error reading from connection: ", err.Error())
-			break
+			logger.Warn("Failed to build the principal", "error", err)
+			return
 		}
+		session.SetPrincipal(principal)
+	}
 
-		if numberOfBytesRead == 0 {
+	// The writer closes the connection once a response asks for it, the reader then stops on its read error
+	pipeline := network.NewPipeline(s.pool, connection, maxInFlightRequestsPerConnection)
+
+	for {
+		pipeline.WaitWhileMuted()
+
+		if !s.armIdleTimeout(connection) {
 			break
 		}
 
-		response, err := broker.ProcessRequest(buffer[:numberOfBytesRead])
-		if err != nil {
-			fmt.Println("Error processing request: ", err.Error())
+		// The size prefix is checked against socket.request.max.bytes before the request buffer is allocated
+		frame, err := network.ReadFrame(connection, s.socketRequestMaxBytes)
+		if err != nil && (s.isShuttingDown() || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)) {
+			break
+		}
 
-			_, err = connection.Write([]byte(err.Error()))
-			if err != nil {
-				fmt.Println("Error writing to connection: ", err.Error())
-				continue
-			}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			logger.Info("Closing idle connection")
+			break
 		}
 
-		_, err = connection.Write(response)
 		if err != nil {
-			fmt.Println("Error writing to connection: ", err.Error())
-			continue
+			logger.Warn("Error reading from connection", "error", err)
+			break
 		}
+
+		pipeline.SubmitAsync(func(done func(network.Result)) {
+			// A request that crashes its handler closes its connection rather than the broker
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("Request processing panicked", "panic", r, "stack", string(debug.Stack()))
+					done(network.Result{Close: true})
+				}
+			}()
+
+			// The response of a request parked in a purgatory comes once the operation completes or expires
+			s.broker.ProcessRequestAsync(session, frame, func(response []byte, throttle time.Duration, err error) {
+				if errors.Is(err, request.ErrAuthenticationRequired) {
+					logger.Info("Closing unauthenticated connection")
+					done(network.Result{Close: true})
+					return
+				}
+
+				// The request log already has the error
+				if err != nil {
+					done(network.Result{Response: []byte(err.Error())})
+					return
+				}
+
+				// A failed authentication closes the connection once its error is written
+				done(network.Result{Response: response, Throttle: throttle, Close: session.Closing()})
+			})
+		}, request.ChangesSession(frame))
+	}
+
+	// Requests already read are still answered, which is what drains the connection on shutdown
+	pipeline.Close()
+	if err := pipeline.Err(); err != nil {
+		logger.Warn("Error writing to connection", "error", err)
 	}
 }
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+var ErrInvalidAcl = errors.New("invalid ACL")
+
+// AclDeletion is the outcome of a filter of DeleteAcls: the bindings it deleted, or why it is invalid
+type AclDeletion struct {
+	Deleted []acl.Binding
+	Err     error
+}
+
+// CreateAcls creates ACL bindings and returns the error of each binding, and the offset of the last record, -1 when
+// no binding was created. Like Kafka, creating a binding that exists already succeeds without a new record
+func (c *Controller) CreateAcls(bindings []acl.Binding) ([]error, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, -1, ErrNotController
+	}
+
+	existing := make(map[acl.Binding]bool)
+	for _, binding := range c.image.Acls() {
+		existing[binding] = true
+	}
+
+	results := make([]error, len(bindings))
+	records := []metadata.Record{}
+	for i, binding := range bindings {
+		if err := binding.Validate(); err != nil {
+			results[i] = fmt.Errorf("%w: %w", ErrInvalidAcl, err)
+			continue
+		}
+		if existing[binding] {
+			continue
+		}
+
+		existing[binding] = true
+		records = append(records, metadata.NewAccessControlEntryRecord(metadata.NewTopicId(), binding))
+	}
+
+	offset := int64(-1)
+	if len(records) > 0 {
+		var err error
+		offset, err = c.appendRecords(records)
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return results, offset, nil
+}
+
+// DeleteAcls deletes the ACL bindings matched by each filter and returns them per filter, in the order of
+// acl.CompareBindings, and the offset of the last record, -1 when no binding was deleted. A binding matched by several
+// filters is returned for each of them
+func (c *Controller) DeleteAcls(filters []acl.BindingFilter) ([]AclDeletion, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, -1, ErrNotController
+	}
+
+	acls := c.image.Acls()
+	ids := slices.SortedFunc(maps.Keys(acls), func(a, b string) int { return acl.CompareBindings(acls[a], acls[b]) })
+
+	results := make([]AclDeletion, len(filters))
+	deleted := make(map[string]bool)
+	records := []metadata.Record{}
+	for i, filter := range filters {
+		if err := filter.Validate(); err != nil {
+			results[i].Err = fmt.Errorf("%w: %w", ErrInvalidAcl, err)
+			continue
+		}
+
+		results[i].Deleted = []acl.Binding{}
+		for _, id := range ids {
+			if !filter.Matches(acls[id]) {
+				continue
+			}
+
+			results[i].Deleted = append(results[i].Deleted, acls[id])
+			if !deleted[id] {
+				deleted[id] = true
+				records = append(records, &metadata.RemoveAccessControlEntryRecord{Id: id})
+			}
+		}
+	}
+
+	offset := int64(-1)
+	if len(records) > 0 {
+		var err error
+		offset, err = c.appendRecords(records)
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return results, offset, nil
+}
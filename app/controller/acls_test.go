@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+)
+
+func TestCreateAndDeleteAcls(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	readFoo := acl.Binding{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW}
+	writeFoo := acl.Binding{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:alice", Host: "*", Operation: acl.WRITE, PermissionType: acl.ALLOW}
+	readBar := acl.Binding{ResourceType: acl.TOPIC, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW}
+	invalid := acl.Binding{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.MATCH, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW}
+
+	errs, offset, err := active.CreateAcls([]acl.Binding{readFoo, writeFoo, readBar, invalid, readFoo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < 0 {
+		t.Fatalf("expected the records to be appended, got offset %d", offset)
+	}
+	if errs[0] != nil || errs[1] != nil || errs[2] != nil || errs[4] != nil || !errors.Is(errs[3], ErrInvalidAcl) {
+		t.Errorf("expected only the MATCH binding to be invalid, got %v", errs)
+	}
+	if acls := active.image.Acls(); len(acls) != 3 {
+		t.Errorf("expected the duplicate binding to be created once, got %v", acls)
+	}
+
+	// Creating the existing bindings again appends nothing
+	if _, offset, err := active.CreateAcls([]acl.Binding{readFoo}); err != nil || offset != -1 {
+		t.Errorf("expected no record for an existing binding, got offset %d and %v", offset, err)
+	}
+
+	name := "foo"
+	deletions, _, err := active.DeleteAcls([]acl.BindingFilter{
+		{ResourceType: acl.TOPIC, ResourceName: &name, PatternType: acl.ANY_PATTERN, Operation: acl.ANY_OPERATION, PermissionType: acl.ANY_PERMISSION},
+		{ResourceType: acl.ANY_RESOURCE, PatternType: acl.ANY_PATTERN, Operation: acl.READ, PermissionType: acl.ANY_PERMISSION},
+		{ResourceType: acl.UNKNOWN_RESOURCE, PatternType: acl.ANY_PATTERN, Operation: acl.ANY_OPERATION, PermissionType: acl.ANY_PERMISSION},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bindings come in the order of acl.CompareBindings, and a binding matched by two filters is in both
+	if !reflect.DeepEqual(deletions[0].Deleted, []acl.Binding{writeFoo, readFoo}) {
+		t.Errorf("expected the foo bindings to be deleted, got %v", deletions[0].Deleted)
+	}
+	if !reflect.DeepEqual(deletions[1].Deleted, []acl.Binding{readBar, readFoo}) {
+		t.Errorf("expected the READ bindings to be deleted, got %v", deletions[1].Deleted)
+	}
+	if !errors.Is(deletions[2].Err, ErrInvalidAcl) {
+		t.Errorf("expected ErrInvalidAcl for the UNKNOWN filter, got %v", deletions[2].Err)
+	}
+	if acls := active.image.Acls(); len(acls) != 0 {
+		t.Errorf("expected every binding to be deleted, got %v", acls)
+	}
+
+	// The committed image of a follower has the same bindings once the quorum replicated the records
+	quorum.poll(time.Second)
+	for _, controller := range quorum.controllers {
+		if acls := controller.Image().Acls(); len(acls) != 0 {
+			t.Errorf("expected no bindings on controller %d, got %v", controller.nodeId, acls)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+var ErrDuplicateBrokerRegistration = errors.New("another broker is registered with this id")
+
+// BrokerRegistration is what a broker sends to join the cluster. The incarnation id is new every time the broker
+// process starts, it tells a restarted broker from another one configured with the same id
+type BrokerRegistration struct {
+	BrokerId      int32
+	IncarnationId string
+	Endpoints     []metadata.BrokerEndpoint
+	Rack          *string
+	LogDirs       []string
+	// The epoch of the registration the broker shut down cleanly from, -1 when it did not
+	PreviousBrokerEpoch int64
+}
+
+type BrokerHeartbeat struct {
+	BrokerId    int32
+	BrokerEpoch int64
+	// The offset of the last metadata record the broker applied
+	CurrentMetadataOffset int64
+	// The broker is not ready to serve and asks to stay fenced
+	WantFence bool
+	// The broker is stopping and asks for a controlled shutdown
+	WantShutDown bool
+}
+
+type HeartbeatResult struct {
+	IsCaughtUp     bool
+	IsFenced       bool
+	ShouldShutDown bool
+}
+
+// RegisterBroker registers a broker, which starts fenced, and returns its epoch. The epoch is the offset of the
+// registration record, so that every registration has a larger one. A broker registering again with the same
+// incarnation keeps its epoch, another incarnation is rejected while the current one heartbeats to this controller,
+// unless it completed a controlled shutdown. A new
+// incarnation that did not shut down cleanly from the current registration may have lost records, so it leaves the
+// ELRs
+func (c *Controller) RegisterBroker(registration BrokerRegistration, now time.Time) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return 0, ErrNotController
+	}
+
+	if existing, ok := c.image.Broker(registration.BrokerId); ok {
+		if existing.IncarnationId == registration.IncarnationId {
+			c.heartbeats[registration.BrokerId] = now
+			return existing.Epoch, nil
+		}
+
+		stopped := existing.Fenced && existing.InControlledShutdown
+		if last, ok := c.heartbeats[registration.BrokerId]; ok && !stopped && now.Sub(last) <= c.config.SessionTimeout {
+			return 0, fmt.Errorf("%w: broker %d with incarnation %s", ErrDuplicateBrokerRegistration, existing.Id, existing.IncarnationId)
+		}
+
+		if records := c.removeFromIsrs(registration.BrokerId); len(records) > 0 {
+			if _, err := c.appendRecords(records); err != nil {
+				return 0, err
+			}
+		}
+		if registration.PreviousBrokerEpoch != existing.Epoch {
+			if records := c.removeFromElrs(registration.BrokerId, true); len(records) > 0 {
+				if _, err := c.appendRecords(records); err != nil {
+					return 0, err
+				}
+			}
+			c.logger.Warn("A broker registered after an unclean shutdown", "broker", registration.BrokerId, "epoch", existing.Epoch)
+		}
+		if err := c.appendOfflineLeaderElections(); err != nil {
+			return 0, err
+		}
+	}
+
+	epoch := c.node.LogEndOffset()
+	records := []metadata.Record{&metadata.RegisterBrokerRecord{
+		BrokerId:      registration.BrokerId,
+		IncarnationId: registration.IncarnationId,
+		BrokerEpoch:   epoch,
+		Endpoints:     slices.Clone(registration.Endpoints),
+		Rack:          registration.Rack,
+		Fenced:        true,
+		LogDirs:       slices.Clone(registration.LogDirs),
+	}}
+	if _, err := c.appendRecords(records); err != nil {
+		return 0, err
+	}
+
+	c.heartbeats[registration.BrokerId] = now
+	delete(c.shutdownOffsets, registration.BrokerId)
+	c.logger.Info("Registered a broker", "broker", registration.BrokerId, "epoch", epoch)
+
+	return epoch, nil
+}
+
+// Heartbeat keeps the session of a broker alive. A broker is unfenced once it applied its own registration, and
+// a controlled shutdown moves the leadership of its partitions away: it should shut down once it applied that
+func (c *Controller) Heartbeat(heartbeat BrokerHeartbeat, now time.Time) (HeartbeatResult, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return HeartbeatResult{}, ErrNotController
+	}
+
+	broker, err := c.registeredBroker(heartbeat.BrokerId, heartbeat.BrokerEpoch)
+	if err != nil {
+		return HeartbeatResult{}, err
+	}
+	c.heartbeats[heartbeat.BrokerId] = now
+
+	result := HeartbeatResult{IsCaughtUp: heartbeat.CurrentMetadataOffset >= broker.Epoch}
+	records := []metadata.Record{}
+
+	switch {
+	case heartbeat.WantShutDown:
+		if !broker.InControlledShutdown {
+			records = append(records, &metadata.BrokerRegistrationChangeRecord{BrokerId: broker.Id, BrokerEpoch: broker.Epoch, InControlledShutdown: true})
+			records = append(records, c.removeFromIsrs(broker.Id)...)
+
+			offset, err := c.appendRecords(records)
+			if err != nil {
+				return HeartbeatResult{}, err
+			}
+			c.shutdownOffsets[broker.Id] = offset
+			c.logger.Info("Started the controlled shutdown of a broker", "broker", broker.Id, "partitions", len(records)-1)
+			records = []metadata.Record{}
+		} else if _, ok := c.shutdownOffsets[broker.Id]; !ok {
+			// The controller that started the shutdown is gone, the broker waits for what this one appended
+			c.shutdownOffsets[broker.Id] = c.node.LogEndOffset() - 1
+		}
+
+		result.ShouldShutDown = heartbeat.CurrentMetadataOffset >= c.shutdownOffsets[broker.Id]
+		if result.ShouldShutDown && !broker.Fenced {
+			records = append(records, &metadata.FenceBrokerRecord{Id: broker.Id, Epoch: broker.Epoch})
+		}
+
+	case heartbeat.WantFence:
+		if !broker.Fenced {
+			records = append(records, &metadata.FenceBrokerRecord{Id: broker.Id, Epoch: broker.Epoch})
+			records = append(records, c.removeFromIsrs(broker.Id)...)
+		}
+
+	case broker.Fenced && result.IsCaughtUp && !broker.InControlledShutdown:
+		records = append(records, &metadata.UnfenceBrokerRecord{Id: broker.Id, Epoch: broker.Epoch})
+		c.logger.Info("Unfenced a broker", "broker", broker.Id, "epoch", broker.Epoch)
+	}
+
+	if len(records) > 0 {
+		if _, err := c.appendRecords(records); err != nil {
+			return HeartbeatResult{}, err
+		}
+		// An unfenced broker may lead the partitions left without a leader
+		if err := c.appendOfflineLeaderElections(); err != nil {
+			return HeartbeatResult{}, err
+		}
+	}
+
+	broker, _ = c.image.Broker(heartbeat.BrokerId)
+	result.IsFenced = broker.Fenced
+	return result, nil
+}
+
+// UnregisterBroker removes a broker that was decommissioned, taking it out of the ISRs and the ELRs first
+func (c *Controller) UnregisterBroker(brokerId int32) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return ErrNotController
+	}
+
+	broker, ok := c.image.Broker(brokerId)
+	if !ok {
+		return fmt.Errorf("%w: %d", metadata.ErrUnknownBroker, brokerId)
+	}
+
+	if records := c.removeFromIsrs(brokerId); len(records) > 0 {
+		if _, err := c.appendRecords(records); err != nil {
+			return err
+		}
+	}
+
+	records := c.removeFromElrs(brokerId, false)
+	records = append(records, &metadata.UnregisterBrokerRecord{BrokerId: brokerId, BrokerEpoch: broker.Epoch})
+	if _, err := c.appendRecords(records); err != nil {
+		return err
+	}
+	if err := c.appendOfflineLeaderElections(); err != nil {
+		return err
+	}
+
+	delete(c.heartbeats, brokerId)
+	delete(c.shutdownOffsets, brokerId)
+	c.logger.Info("Unregistered a broker", "broker", brokerId, "epoch", broker.Epoch)
+
+	return nil
+}
+
+// Tick fences the brokers that did not heartbeat within the session timeout and, with AutoLeaderRebalance, checks the
+// leader imbalance every LeaderImbalanceCheckInterval. It runs with the polls of the quorum. A broker that did not
+// heartbeat to this controller yet has a session from the first tick after the activation
+func (c *Controller) Tick(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return
+	}
+	if c.activatedAt.IsZero() {
+		c.activatedAt = now
+	}
+
+	for _, brokerId := range c.image.BrokerIds() {
+		broker, _ := c.image.Broker(brokerId)
+
+		last, ok := c.heartbeats[brokerId]
+		if !ok {
+			last = c.activatedAt
+		}
+		if broker.Fenced || now.Sub(last) <= c.config.SessionTimeout {
+			continue
+		}
+
+		records := []metadata.Record{&metadata.FenceBrokerRecord{Id: brokerId, Epoch: broker.Epoch}}
+		records = append(records, c.removeFromIsrs(brokerId)...)
+		if _, err := c.appendRecords(records); err != nil {
+			c.logger.Error("Failed to fence a broker", "broker", brokerId, "error", err)
+			return
+		}
+		c.logger.Warn("Fenced a broker whose session expired", "broker", brokerId, "last_heartbeat", last)
+
+		// The partitions it led may only have leaders out of their ISR
+		if err := c.appendOfflineLeaderElections(); err != nil {
+			c.logger.Error("Failed to elect leaders", "error", err)
+			return
+		}
+	}
+
+	if !c.config.AutoLeaderRebalance {
+		return
+	}
+	if c.lastRebalance.IsZero() {
+		c.lastRebalance = now
+	}
+	if now.Sub(c.lastRebalance) < c.config.LeaderImbalanceCheckInterval {
+		return
+	}
+	c.lastRebalance = now
+
+	if records := c.rebalanceLeaders(); len(records) > 0 {
+		if _, err := c.appendRecords(records); err != nil {
+			c.logger.Error("Failed to rebalance the leaders", "error", err)
+		}
+	}
+}
+
+func (c *Controller) registeredBroker(brokerId int32, brokerEpoch int64) (*metadata.BrokerImage, error) {
+	broker, ok := c.image.Broker(brokerId)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", metadata.ErrUnknownBroker, brokerId)
+	}
+	if broker.Epoch != brokerEpoch {
+		return nil, fmt.Errorf("%w: broker %d has epoch %d, not %d", metadata.ErrStaleBrokerEpoch, brokerId, broker.Epoch, brokerEpoch)
+	}
+	return broker, nil
+}
+
+// removeFromIsrs returns the changes that take a broker out of the ISRs, moving the leadership of the partitions it
+// leads to their first replica in the ISR that is active. No record is committed while the ISR is smaller than
+// min.insync.replicas, so a broker leaving it then has every committed record: it becomes an eligible leader replica.
+// A partition left without an active replica in its ISR has no leader
+func (c *Controller) removeFromIsrs(brokerId int32) []metadata.Record {
+	records := []metadata.Record{}
+
+	for _, name := range c.image.TopicNames() {
+		topic, _ := c.image.Topic(name)
+
+		for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+			partition := topic.Partitions[partitionId]
+			if !slices.Contains(partition.Isr, brokerId) {
+				continue
+			}
+
+			change := &metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topic.Id, Leader: metadata.NO_LEADER_CHANGE}
+			change.Isr = slices.DeleteFunc(slices.Clone(partition.Isr), func(replica int32) bool { return replica == brokerId })
+			if len(change.Isr) < c.minInsyncReplicas(name) {
+				change.Elr = append(slices.Clone(partition.Elr), brokerId)
+			}
+			if partition.Leader == brokerId {
+				change.Leader = c.electLeader(partition.Replicas, change.Isr)
+			}
+
+			records = append(records, change)
+		}
+	}
+
+	return records
+}
+
+// removeFromElrs returns the changes that take a broker out of the ELRs and the last known ELRs. After an unclean
+// shutdown, lastKnown keeps the broker in the last known ELR of the partitions it was eligible to lead: it may have
+// lost records, but fewer than the other replicas
+func (c *Controller) removeFromElrs(brokerId int32, lastKnown bool) []metadata.Record {
+	records := []metadata.Record{}
+	isBroker := func(replica int32) bool { return replica == brokerId }
+
+	for _, name := range c.image.TopicNames() {
+		topic, _ := c.image.Topic(name)
+
+		for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+			partition := topic.Partitions[partitionId]
+			inElr := slices.Contains(partition.Elr, brokerId)
+			inLastKnownElr := slices.Contains(partition.LastKnownElr, brokerId)
+
+			change := &metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topic.Id, Isr: nil, Leader: metadata.NO_LEADER_CHANGE}
+			switch {
+			case lastKnown && inElr:
+				change.Elr = slices.DeleteFunc(slices.Clone(partition.Elr), isBroker)
+				if !inLastKnownElr {
+					change.LastKnownElr = append(slices.Clone(partition.LastKnownElr), brokerId)
+				}
+			case !lastKnown && (inElr || inLastKnownElr):
+				change.Elr = slices.DeleteFunc(slices.Clone(partition.Elr), isBroker)
+				change.LastKnownElr = slices.DeleteFunc(slices.Clone(partition.LastKnownElr), isBroker)
+			default:
+				continue
+			}
+
+			records = append(records, change)
+		}
+	}
+
+	return records
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+// registerBrokers registers brokers with the active controller and heartbeats until they are unfenced
+func registerBrokers(t *testing.T, active *Controller, now time.Time, brokerIds ...int32) map[int32]int64 {
+	t.Helper()
+
+	epochs := make(map[int32]int64)
+	for _, brokerId := range brokerIds {
+		epoch, err := active.RegisterBroker(BrokerRegistration{BrokerId: brokerId, IncarnationId: incarnationId(brokerId)}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		epochs[brokerId] = epoch
+
+		result, err := active.Heartbeat(BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsFenced {
+			t.Fatalf("broker %d is still fenced", brokerId)
+		}
+	}
+	return epochs
+}
+
+func incarnationId(brokerId int32) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", brokerId)
+}
+
+func TestRegisterBrokerAndHeartbeat(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epoch, err := active.RegisterBroker(BrokerRegistration{BrokerId: 4, IncarnationId: incarnationId(4)}, quorum.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, err := active.RegisterBroker(BrokerRegistration{BrokerId: 4, IncarnationId: incarnationId(4)}, quorum.now); err != nil || again != epoch {
+		t.Errorf("registering the same incarnation again must keep epoch %d, got %d, %v", epoch, again, err)
+	}
+	if _, err := active.RegisterBroker(BrokerRegistration{BrokerId: 4, IncarnationId: incarnationId(5)}, quorum.now); !errors.Is(err, ErrDuplicateBrokerRegistration) {
+		t.Errorf("expected ErrDuplicateBrokerRegistration, got %v", err)
+	}
+
+	if _, err := active.Heartbeat(BrokerHeartbeat{BrokerId: 4, BrokerEpoch: epoch - 1}, quorum.now); !errors.Is(err, metadata.ErrStaleBrokerEpoch) {
+		t.Errorf("expected ErrStaleBrokerEpoch, got %v", err)
+	}
+	if _, err := active.Heartbeat(BrokerHeartbeat{BrokerId: 5, BrokerEpoch: epoch}, quorum.now); !errors.Is(err, metadata.ErrUnknownBroker) {
+		t.Errorf("expected ErrUnknownBroker, got %v", err)
+	}
+
+	// The broker stays fenced until it applied its registration
+	result, err := active.Heartbeat(BrokerHeartbeat{BrokerId: 4, BrokerEpoch: epoch, CurrentMetadataOffset: epoch - 1}, quorum.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (HeartbeatResult{IsCaughtUp: false, IsFenced: true}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+
+	result, err = active.Heartbeat(BrokerHeartbeat{BrokerId: 4, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, quorum.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (HeartbeatResult{IsCaughtUp: true, IsFenced: false}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+
+	quorum.poll(time.Second)
+	if broker, ok := quorum.loader.Image().Broker(4); !ok || broker.Fenced || broker.Epoch != epoch {
+		t.Errorf("the broker image must have broker 4 unfenced with epoch %d, got %+v", epoch, broker)
+	}
+}
+
+func TestBrokersAreFencedWhenTheirSessionExpires(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2, 3)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2, 3}, {1, 2}, {1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Brokers 2 and 3 keep heartbeating, broker 1 stopped
+	for range 5 {
+		quorum.poll(time.Second)
+		for _, brokerId := range []int32{2, 3} {
+			if _, err := active.Heartbeat(BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epochs[brokerId], CurrentMetadataOffset: epochs[brokerId]}, quorum.now); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	quorum.poll(time.Second)
+
+	image := quorum.loader.Image()
+	if broker, _ := image.Broker(1); !broker.Fenced {
+		t.Errorf("broker 1 must be fenced")
+	}
+	if broker, _ := image.Broker(2); broker.Fenced {
+		t.Errorf("broker 2 must not be fenced")
+	}
+
+	topic, _ := image.Topic("foo")
+	want := map[int32]metadata.PartitionImage{
+		0: {Replicas: []int32{1, 2, 3}, Isr: []int32{2, 3}, Leader: 2, LeaderEpoch: 1, PartitionEpoch: 1},
+		1: {Replicas: []int32{1, 2}, Isr: []int32{2}, Leader: 2, LeaderEpoch: 1, PartitionEpoch: 1},
+		// The last member of the ISR leaves it for the ELR, it has every committed record
+		2: {Replicas: []int32{1}, Isr: []int32{}, Leader: metadata.NO_LEADER, LeaderEpoch: 1, PartitionEpoch: 1, Elr: []int32{1}},
+	}
+	for partitionId, partition := range want {
+		if got := *topic.Partitions[partitionId]; !reflect.DeepEqual(got, partition) {
+			t.Errorf("partition %d: got %+v, want %+v", partitionId, got, partition)
+		}
+	}
+}
+
+func TestControlledShutdown(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {2, 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	shutdown := BrokerHeartbeat{BrokerId: 1, BrokerEpoch: epochs[1], CurrentMetadataOffset: epochs[1], WantShutDown: true}
+	result, err := active.Heartbeat(shutdown, quorum.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ShouldShutDown {
+		t.Errorf("the broker must not shut down before it applied the leadership changes")
+	}
+
+	image := active.image
+	topic, _ := image.Topic("foo")
+	for partitionId, partition := range topic.Partitions {
+		if partition.Leader != 2 || !reflect.DeepEqual(partition.Isr, []int32{2}) {
+			t.Errorf("partition %d must be led by broker 2 alone, got %+v", partitionId, partition)
+		}
+	}
+
+	// A broker in controlled shutdown is not elected again
+	if broker, _ := image.Broker(1); !broker.InControlledShutdown || broker.IsActive() {
+		t.Errorf("broker 1 must be in controlled shutdown, got %+v", broker)
+	}
+
+	shutdown.CurrentMetadataOffset = active.Node().LogEndOffset()
+	result, err = active.Heartbeat(shutdown, quorum.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ShouldShutDown || !result.IsFenced {
+		t.Errorf("expected the broker to shut down fenced, got %+v", result)
+	}
+}
+
+func TestUnregisterBroker(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	registerBrokers(t, active, quorum.now, 1, 2)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := active.UnregisterBroker(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := active.UnregisterBroker(1); !errors.Is(err, metadata.ErrUnknownBroker) {
+		t.Errorf("expected ErrUnknownBroker, got %v", err)
+	}
+	quorum.poll(time.Second)
+
+	image := quorum.loader.Image()
+	if got := image.BrokerIds(); !reflect.DeepEqual(got, []int32{2}) {
+		t.Errorf("expected brokers [2], got %v", got)
+	}
+	topic, _ := image.Topic("foo")
+	if partition := topic.Partitions[0]; partition.Leader != 2 || !reflect.DeepEqual(partition.Isr, []int32{2}) {
+		t.Errorf("partition 0 must be led by broker 2 alone, got %+v", partition)
+	}
+
+	for nodeId, controller := range quorum.controllers {
+		if controller == active {
+			continue
+		}
+		if err := controller.UnregisterBroker(2); !errors.Is(err, ErrNotController) {
+			t.Errorf("controller %d: expected ErrNotController, got %v", nodeId, err)
+		}
+	}
+}
+
+func TestRegisterBrokerAfterARestart(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	first := quorum.activeController()
+
+	epochs := registerBrokers(t, first, quorum.now, 1, 2, 3)
+	quorum.poll(time.Second)
+
+	// Broker 1 completed a controlled shutdown, its new incarnation does not wait for its session to expire
+	result, err := first.Heartbeat(BrokerHeartbeat{BrokerId: 1, BrokerEpoch: epochs[1], CurrentMetadataOffset: math.MaxInt64, WantShutDown: true}, quorum.now)
+	if err != nil || !result.ShouldShutDown {
+		t.Fatalf("expected broker 1 to shut down, got %+v, %v", result, err)
+	}
+	if _, err := first.RegisterBroker(BrokerRegistration{BrokerId: 1, IncarnationId: incarnationId(11)}, quorum.now); err != nil {
+		t.Errorf("expected the new incarnation of a stopped broker to register, got %v", err)
+	}
+
+	// The next active controller only loaded brokers 2 and 3, they have no session on it yet
+	quorum.transport.Disconnect(first.nodeId)
+	var second *Controller
+	for second == nil {
+		quorum.poll(100 * time.Millisecond)
+		for _, controller := range quorum.controllers {
+			if controller != first && controller.IsActive() {
+				second = controller
+			}
+		}
+	}
+	if broker, _ := second.Image().Broker(2); broker.Fenced {
+		t.Fatalf("broker 2 must still be unfenced")
+	}
+	if _, err := second.RegisterBroker(BrokerRegistration{BrokerId: 2, IncarnationId: incarnationId(12)}, quorum.now); err != nil {
+		t.Errorf("expected the new incarnation of a loaded broker to register, got %v", err)
+	}
+
+	// A loaded broker that never heartbeats is fenced once its session from the activation expired
+	quorum.poll(testSessionTimeout + time.Second)
+	if broker, _ := second.Image().Broker(3); !broker.Fenced {
+		t.Errorf("broker 3 must be fenced")
+	}
+}
@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+var (
+	// ErrNotController is returned by the controllers that are not the leader of the metadata quorum
+	ErrNotController   = errors.New("not the active controller")
+	ErrInvalidReplicas = errors.New("invalid replica assignment")
+)
+
+// Config holds the settings of a controller
+type Config struct {
+	// A snapshot of the image replaces the log every SnapshotInterval records
+	SnapshotInterval int64
+	// Brokers that do not heartbeat for SessionTimeout are fenced
+	SessionTimeout time.Duration
+	// The static defaults of unclean.leader.election.enable and min.insync.replicas, for the topics and the cluster
+	// that do not set them
+	UncleanLeaderElection bool
+	MinInsyncReplicas     int
+	// Every LeaderImbalanceCheckInterval, the leadership moves back to the brokers that do not lead more than
+	// LeaderImbalancePerBrokerPercentage percent of the partitions they are the preferred replica of
+	AutoLeaderRebalance                bool
+	LeaderImbalanceCheckInterval       time.Duration
+	LeaderImbalancePerBrokerPercentage int
+	// The number of partitions and the replication factor of the topics created without them
+	NumPartitions            int
+	DefaultReplicationFactor int
+}
+
+// Controller is a voter of the metadata quorum. The active controller, the leader of the quorum, validates the changes
+// to the cluster metadata and appends them as records, every controller replays the committed records into its image
+type Controller struct {
+	nodeId int32
+	node   *raft.Node
+	logger *slog.Logger
+	config Config
+
+	mutex     sync.Mutex
+	committed *metadata.Image
+	// The committed image and, on the active controller, the records it appended that are not committed yet
+	image *metadata.Image
+	// The epoch in which this controller is active, or -1
+	activeEpoch    int32
+	snapshotOffset int64
+	// The last heartbeat of each broker and the offset a broker in controlled shutdown must apply before it
+	// stops, only known to the active controller
+	heartbeats      map[int32]time.Time
+	shutdownOffsets map[int32]int64
+	// When the active controller first ticked, the brokers that did not heartbeat to it yet are fenced from there
+	activatedAt time.Time
+	// When the active controller last checked the leader imbalance
+	lastRebalance time.Time
+}
+
+// NewController creates a controller and its member of the quorum. listeners, such as the metadata loader of the
+// broker of a combined process, are delivered the committed records after the controller
+func NewController(controllerConfig Config, raftConfig raft.Config, transport raft.Transport, logger *slog.Logger, now time.Time, listeners ...raft.Listener) *Controller {
+	controller := &Controller{
+		nodeId:          raftConfig.NodeId,
+		logger:          logger.With("controller", raftConfig.NodeId),
+		config:          controllerConfig,
+		committed:       metadata.NewImage(),
+		image:           metadata.NewImage(),
+		activeEpoch:     -1,
+		heartbeats:      make(map[int32]time.Time),
+		shutdownOffsets: make(map[int32]int64),
+	}
+	controller.node = raft.NewNode(raftConfig, transport, append(raft.Listeners{controller}, listeners...), now)
+	return controller
+}
+
+// Node is the member of the metadata quorum of the controller
+func (c *Controller) Node() *raft.Node {
+	return c.node
+}
+
+func (c *Controller) IsActive() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.activeEpoch >= 0
+}
+
+// Image returns the committed metadata
+func (c *Controller) Image() *metadata.Image {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.committed.Clone()
+}
+
+// AlterConfig sets a config of a resource, a nil value deletes it
+func (c *Controller) AlterConfig(resource config.Resource, name string, value *string) error {
+	return c.AlterConfigs(resource, map[string]*string{name: value}, false)
+}
+
+// AlterConfigs sets configs of a resource at once, a nil value deletes a config. With validateOnly the resource is
+// checked but nothing changes
+func (c *Controller) AlterConfigs(resource config.Resource, configs map[string]*string, validateOnly bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return ErrNotController
+	}
+	if resource.Type == config.TOPIC {
+		if _, ok := c.image.Topic(resource.Name); !ok {
+			return fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, resource.Name)
+		}
+	}
+	if validateOnly || len(configs) == 0 {
+		return nil
+	}
+
+	records := make([]metadata.Record, 0, len(configs))
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		records = append(records, &metadata.ConfigRecord{ResourceType: resource.Type, ResourceName: resource.Name, Name: name, Value: configs[name]})
+	}
+	if _, err := c.appendRecords(records); err != nil {
+		return err
+	}
+	if _, ok := configs["unclean.leader.election.enable"]; !ok {
+		return nil
+	}
+
+	// Enabling unclean leader election elects leaders for the partitions without an active replica in their ISR
+	return c.appendOfflineLeaderElections()
+}
+
+// appendRecords appends validated records to the metadata log and applies them to the image, so that the next
+// changes are validated against them before they are committed. It returns the offset of the last record
+func (c *Controller) appendRecords(records []metadata.Record) (int64, error) {
+	data := make([][]byte, 0, len(records))
+	for _, record := range records {
+		encoded, err := metadata.EncodeRecord(record)
+		if err != nil {
+			return 0, err
+		}
+		data = append(data, encoded)
+	}
+
+	offset, err := c.node.Append(c.activeEpoch, data)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrNotController, err)
+	}
+
+	for _, record := range records {
+		if err := c.image.Apply(record); err != nil {
+			c.logger.Error("Failed to apply an appended metadata record", "error", err)
+		}
+	}
+
+	return offset, nil
+}
+
+func (c *Controller) HandleCommit(entries []raft.Entry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, entry := range entries {
+		metadata.ApplyEntry(c.committed, entry, c.logger)
+	}
+
+	// The active controller applied its records when it appended them
+	if c.activeEpoch < 0 {
+		c.image = c.committed.Clone()
+	}
+
+	c.maybeSnapshot()
+}
+
+func (c *Controller) HandleLoadSnapshot(snapshot raft.Snapshot) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	image, err := metadata.DecodeSnapshot(snapshot.Data, snapshot.Id.EndOffset)
+	if err != nil {
+		c.logger.Error("Failed to load the metadata snapshot", "end_offset", snapshot.Id.EndOffset, "error", err)
+		return
+	}
+
+	c.committed = image
+	c.image = image.Clone()
+	c.snapshotOffset = snapshot.Id.EndOffset
+}
+
+// HandleLeaderChange activates the controller once it leads the quorum. Leaving the leadership drops the records
+// that were not committed, the next leader truncates them. A new active controller gives every broker a full
+// session to heartbeat to it
+func (c *Controller) HandleLeaderChange(leader raft.LeaderAndEpoch) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	wasActive := c.activeEpoch >= 0
+	c.activeEpoch = -1
+	if leader.LeaderId == c.nodeId {
+		c.activeEpoch = leader.Epoch
+	}
+
+	if wasActive || c.activeEpoch >= 0 {
+		c.image = c.committed.Clone()
+		clear(c.heartbeats)
+		clear(c.shutdownOffsets)
+		c.activatedAt = time.Time{}
+		c.lastRebalance = time.Time{}
+		c.logger.Info("Changed the active controller", "leader", leader.LeaderId, "epoch", leader.Epoch)
+	}
+}
+
+// maybeSnapshot replaces the log with a snapshot of the committed image after SnapshotInterval records
+func (c *Controller) maybeSnapshot() {
+	if c.config.SnapshotInterval <= 0 || c.committed.Offset-c.snapshotOffset < c.config.SnapshotInterval {
+		return
+	}
+
+	data, err := metadata.EncodeSnapshot(c.committed)
+	if err == nil {
+		err = c.node.CreateSnapshot(c.committed.Offset, data)
+	}
+	if err != nil {
+		c.logger.Error("Failed to snapshot the metadata", "end_offset", c.committed.Offset, "error", err)
+		return
+	}
+
+	c.snapshotOffset = c.committed.Offset
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+const testSessionTimeout = 3 * time.Second
+
+// testQuorum runs three controllers and a broker in-process, the broker replicates the metadata log as an observer
+type testQuorum struct {
+	t           *testing.T
+	now         time.Time
+	transport   *raft.MemoryTransport
+	controllers map[int32]*Controller
+	broker      *raft.Node
+	loader      *metadata.Loader
+}
+
+func newTestQuorum(t *testing.T, snapshotInterval int64) *testQuorum {
+	quorum := &testQuorum{
+		t:           t,
+		now:         time.UnixMilli(1_000_000),
+		controllers: make(map[int32]*Controller),
+	}
+	quorum.transport = raft.NewMemoryTransport(func() time.Time { return quorum.now })
+	logger := slog.New(slog.DiscardHandler)
+
+	voters := []int32{1, 2, 3}
+	raftConfig := func(nodeId int32) raft.Config {
+		return raft.Config{
+			NodeId:          nodeId,
+			Voters:          raft.StaticVoters(voters...),
+			ElectionTimeout: time.Second,
+			FetchTimeout:    2 * time.Second,
+			FetchMaxEntries: 100,
+			Seed:            7,
+		}
+	}
+
+	for _, nodeId := range voters {
+		controller := NewController(Config{SnapshotInterval: snapshotInterval, SessionTimeout: testSessionTimeout}, raftConfig(nodeId), quorum.transport.Endpoint(nodeId), logger, quorum.now)
+		quorum.transport.Register(controller.Node())
+		quorum.controllers[nodeId] = controller
+	}
+
+	quorum.loader = metadata.NewLoader(logger)
+	quorum.broker = raft.NewNode(raftConfig(100), quorum.transport.Endpoint(100), quorum.loader, quorum.now)
+	quorum.transport.Register(quorum.broker)
+
+	return quorum
+}
+
+func (q *testQuorum) poll(duration time.Duration) {
+	for end := q.now.Add(duration); q.now.Before(end); q.now = q.now.Add(10 * time.Millisecond) {
+		for _, controller := range q.controllers {
+			controller.Node().Poll(q.now)
+			controller.Tick(q.now)
+		}
+		q.broker.Poll(q.now)
+	}
+}
+
+func (q *testQuorum) activeController() *Controller {
+	q.t.Helper()
+
+	var active *Controller
+	for _, controller := range q.controllers {
+		if controller.IsActive() {
+			if active != nil {
+				q.t.Fatalf("both %d and %d are active", active.nodeId, controller.nodeId)
+			}
+			active = controller
+		}
+	}
+
+	if active == nil {
+		q.t.Fatalf("no active controller")
+	}
+	return active
+}
+
+func TestControllersReplicateMetadataToBrokers(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+
+	active := quorum.activeController()
+	registerBrokers(t, active, quorum.now, 1, 2, 3)
+	topicId, err := active.CreateTopic("foo", [][]int32{{1, 2}, {2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compression := "zstd"
+	if err := active.AlterConfig(config.Resource{Type: config.TOPIC, Name: "foo"}, "compression.type", &compression); err != nil {
+		t.Fatal(err)
+	}
+
+	// The topic exists for the active controller before its records are committed
+	if _, err := active.CreateTopic("foo", [][]int32{{1}}); !errors.Is(err, metadata.ErrTopicExists) {
+		t.Errorf("expected ErrTopicExists, got %v", err)
+	}
+
+	quorum.poll(time.Second)
+
+	images := map[string]*metadata.Image{"broker": quorum.loader.Image()}
+	for nodeId, controller := range quorum.controllers {
+		images[fmt.Sprintf("controller %d", nodeId)] = controller.Image()
+	}
+
+	for node, image := range images {
+		topic, ok := image.Topic("foo")
+		if !ok || topic.Id != topicId {
+			t.Errorf("node %s does not have topic foo", node)
+			continue
+		}
+
+		want := metadata.PartitionImage{Replicas: []int32{2, 3}, Isr: []int32{2, 3}, Leader: 2}
+		if got := *topic.Partitions[1]; !reflect.DeepEqual(got, want) {
+			t.Errorf("node %s has partition %+v, want %+v", node, got, want)
+		}
+
+		if got := image.Configs(config.Resource{Type: config.TOPIC, Name: "foo"}); got["compression.type"] != "zstd" {
+			t.Errorf("node %s has configs %v", node, got)
+		}
+	}
+
+	if got := quorum.loader.Leader().LeaderId; got != active.nodeId {
+		t.Errorf("the broker must know the active controller %d, got %d", active.nodeId, got)
+	}
+}
+
+func TestStandbyControllersRejectChanges(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+
+	active := quorum.activeController()
+	for nodeId, controller := range quorum.controllers {
+		if nodeId == active.nodeId {
+			continue
+		}
+		if _, err := controller.CreateTopic("foo", [][]int32{{1}}); !errors.Is(err, ErrNotController) {
+			t.Errorf("controller %d: expected ErrNotController, got %v", nodeId, err)
+		}
+	}
+
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 1}}); !errors.Is(err, ErrInvalidReplicas) {
+		t.Errorf("expected ErrInvalidReplicas for duplicated replicas, got %v", err)
+	}
+}
+
+func TestNewActiveControllerKeepsCommittedMetadata(t *testing.T) {
+	quorum := newTestQuorum(t, 3)
+	quorum.poll(5 * time.Second)
+
+	first := quorum.activeController()
+	registerBrokers(t, first, quorum.now, 1, 2, 3)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if _, err := first.CreateTopic(name, [][]int32{{1, 2, 3}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	quorum.poll(time.Second)
+
+	quorum.transport.Disconnect(first.nodeId)
+	quorum.poll(10 * time.Second)
+
+	second := quorum.activeController()
+	if second == first {
+		t.Fatalf("the partitioned controller must not stay active")
+	}
+	if _, err := second.CreateTopic("e", [][]int32{{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.CreateTopic("a", [][]int32{{1}}); !errors.Is(err, metadata.ErrTopicExists) {
+		t.Errorf("the new active controller must know the committed topics, got %v", err)
+	}
+	quorum.poll(time.Second)
+
+	quorum.transport.Connect(first.nodeId)
+	quorum.poll(10 * time.Second)
+
+	want := []string{"a", "b", "c", "d", "e"}
+	for nodeId, controller := range quorum.controllers {
+		if got := controller.Image().TopicNames(); !reflect.DeepEqual(got, want) {
+			t.Errorf("controller %d has topics %v, want %v", nodeId, got, want)
+		}
+		// The committed records were replaced with snapshots
+		if controller.Node().LogStartOffset() == 0 {
+			t.Errorf("controller %d did not snapshot its log", nodeId)
+		}
+	}
+	if got := quorum.loader.Image().TopicNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("the broker has topics %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+// ElectionType is the kind of leader election of ElectLeaders, with the values of the Kafka protocol
+type ElectionType int8
+
+const (
+	PREFERRED ElectionType = 0
+	UNCLEAN   ElectionType = 1
+)
+
+var (
+	ErrInvalidElectionType         = errors.New("invalid election type")
+	ErrElectionNotNeeded           = errors.New("leader election not needed")
+	ErrPreferredLeaderNotAvailable = errors.New("preferred leader not available")
+	ErrEligibleLeadersNotAvailable = errors.New("eligible leaders not available")
+)
+
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// ElectLeaders elects the leaders of partitions, of every partition when partitions is nil. A preferred election
+// moves the leadership to the first replica of the assignment, an unclean one elects a leader for a partition
+// without one, out of the ISR when no replica of the ISR is active. When partitions is nil, the partitions that did
+// not need an election are left out of the results. It also returns the offset of the last record of the elections,
+// -1 when no leader changed
+func (c *Controller) ElectLeaders(electionType ElectionType, partitions []TopicPartition) (map[TopicPartition]error, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, -1, ErrNotController
+	}
+	if electionType != PREFERRED && electionType != UNCLEAN {
+		return nil, -1, fmt.Errorf("%w: %d", ErrInvalidElectionType, electionType)
+	}
+
+	everyPartition := partitions == nil
+	if everyPartition {
+		partitions = c.partitions()
+	}
+
+	results := make(map[TopicPartition]error)
+	records := []metadata.Record{}
+	for _, partition := range partitions {
+		change, err := c.electPartition(electionType, partition)
+		if everyPartition && errors.Is(err, ErrElectionNotNeeded) {
+			continue
+		}
+
+		results[partition] = err
+		if change != nil {
+			records = append(records, change)
+		}
+	}
+
+	offset := int64(-1)
+	if len(records) > 0 {
+		var err error
+		offset, err = c.appendRecords(records)
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return results, offset, nil
+}
+
+func (c *Controller) electPartition(electionType ElectionType, topicPartition TopicPartition) (*metadata.PartitionChangeRecord, error) {
+	topic, ok := c.image.Topic(topicPartition.Topic)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, topicPartition.Topic)
+	}
+	partition, ok := topic.Partitions[topicPartition.Partition]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s-%d", metadata.ErrUnknownPartition, topicPartition.Topic, topicPartition.Partition)
+	}
+
+	if electionType == PREFERRED {
+		preferred := partition.Replicas[0]
+		if partition.Leader == preferred {
+			return nil, ErrElectionNotNeeded
+		}
+		if !slices.Contains(partition.Isr, preferred) || !c.isActiveBroker(preferred) {
+			return nil, fmt.Errorf("%w: replica %d of %s-%d", ErrPreferredLeaderNotAvailable, preferred, topic.Name, topicPartition.Partition)
+		}
+		return &metadata.PartitionChangeRecord{PartitionId: topicPartition.Partition, TopicId: topic.Id, Isr: nil, Leader: preferred}, nil
+	}
+
+	if partition.Leader != metadata.NO_LEADER {
+		return nil, ErrElectionNotNeeded
+	}
+	change := c.electOfflineLeader(topic, topicPartition.Partition, true)
+	if change == nil {
+		return nil, fmt.Errorf("%w: no replica of %s-%d is active", ErrEligibleLeadersNotAvailable, topic.Name, topicPartition.Partition)
+	}
+	return change, nil
+}
+
+// electOfflineLeaders elects a leader for the partitions without one, out of the ISR and the ELR only for the topics
+// with unclean leader election enabled
+func (c *Controller) electOfflineLeaders() []metadata.Record {
+	records := []metadata.Record{}
+
+	for _, name := range c.image.TopicNames() {
+		topic, _ := c.image.Topic(name)
+		unclean := c.uncleanLeaderElection(name)
+
+		for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+			if topic.Partitions[partitionId].Leader != metadata.NO_LEADER {
+				continue
+			}
+			if change := c.electOfflineLeader(topic, partitionId, unclean); change != nil {
+				records = append(records, change)
+			}
+		}
+	}
+
+	return records
+}
+
+// electOfflineLeader returns the change electing a leader for a partition without one: the first active replica of
+// the ISR, then of the ELR, which is as safe. When unclean is set, it falls back to the first active replica of the
+// last known ELR, then of the assignment. A leader out of the ISR becomes its only member. It is nil when no replica
+// can lead
+func (c *Controller) electOfflineLeader(topic *metadata.TopicImage, partitionId int32, unclean bool) *metadata.PartitionChangeRecord {
+	partition := topic.Partitions[partitionId]
+
+	if leader := c.electLeader(partition.Replicas, partition.Isr); leader != metadata.NO_LEADER {
+		return &metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topic.Id, Isr: nil, Leader: leader}
+	}
+
+	if leader := c.electLeader(partition.Replicas, partition.Elr); leader != metadata.NO_LEADER {
+		// The other eligible replicas stay so while the ISR is below min.insync.replicas
+		elr := slices.DeleteFunc(slices.Clone(partition.Elr), func(replica int32) bool { return replica == leader })
+		if c.minInsyncReplicas(topic.Name) <= 1 {
+			elr = []int32{}
+		}
+
+		c.logger.Info("Elected an eligible leader replica", "topic", topic.Name, "partition", partitionId, "leader", leader, "elr", partition.Elr)
+		return &metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topic.Id, Isr: []int32{leader}, Leader: leader, Elr: elr, LastKnownElr: []int32{}}
+	}
+
+	if !unclean {
+		return nil
+	}
+
+	for _, replica := range slices.Concat(partition.LastKnownElr, partition.Replicas) {
+		if c.isActiveBroker(replica) {
+			c.logger.Warn("Elected a leader out of the ISR, committed records may be lost", "topic", topic.Name, "partition", partitionId, "leader", replica, "isr", partition.Isr)
+			return &metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topic.Id, Isr: []int32{replica}, Leader: replica, Elr: []int32{}, LastKnownElr: []int32{}}
+		}
+	}
+	return nil
+}
+
+// appendOfflineLeaderElections elects leaders for the partitions that the last changes left without one
+func (c *Controller) appendOfflineLeaderElections() error {
+	records := c.electOfflineLeaders()
+	if len(records) == 0 {
+		return nil
+	}
+
+	_, err := c.appendRecords(records)
+	return err
+}
+
+// rebalanceLeaders moves the leadership back to the preferred replica, the first of the assignment, for the brokers
+// that lead more than LeaderImbalancePerBrokerPercentage percent fewer partitions than they prefer
+func (c *Controller) rebalanceLeaders() []metadata.Record {
+	preferred := make(map[int32]int)
+	notLed := make(map[int32][]*metadata.PartitionChangeRecord)
+
+	for _, name := range c.image.TopicNames() {
+		topic, _ := c.image.Topic(name)
+
+		for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+			partition := topic.Partitions[partitionId]
+			replica := partition.Replicas[0]
+
+			preferred[replica]++
+			if partition.Leader != replica && slices.Contains(partition.Isr, replica) {
+				notLed[replica] = append(notLed[replica], &metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topic.Id, Isr: nil, Leader: replica})
+			}
+		}
+	}
+
+	records := []metadata.Record{}
+	for _, brokerId := range slices.Sorted(maps.Keys(notLed)) {
+		if !c.isActiveBroker(brokerId) || len(notLed[brokerId])*100 <= c.config.LeaderImbalancePerBrokerPercentage*preferred[brokerId] {
+			continue
+		}
+
+		for _, change := range notLed[brokerId] {
+			records = append(records, change)
+		}
+		c.logger.Info("Moved the leadership back to a preferred replica", "broker", brokerId, "partitions", len(notLed[brokerId]))
+	}
+
+	return records
+}
+
+func (c *Controller) uncleanLeaderElection(topic string) bool {
+	value, ok := c.topicConfig(topic, "unclean.leader.election.enable")
+	if !ok {
+		return c.config.UncleanLeaderElection
+	}
+
+	enabled, _ := strconv.ParseBool(strings.TrimSpace(value))
+	return enabled
+}
+
+func (c *Controller) minInsyncReplicas(topic string) int {
+	value, ok := c.topicConfig(topic, "min.insync.replicas")
+	if !ok {
+		return max(c.config.MinInsyncReplicas, 1)
+	}
+
+	minIsr, _ := strconv.Atoi(strings.TrimSpace(value))
+	return max(minIsr, 1)
+}
+
+// topicConfig resolves a dynamic config of a topic: the topic config, then the cluster default. The static config of
+// the controller applies when neither is set
+func (c *Controller) topicConfig(topic string, name string) (string, bool) {
+	value, ok := c.image.Configs(config.Resource{Type: config.TOPIC, Name: topic})[name]
+	if !ok {
+		value, ok = c.image.Configs(config.Resource{Type: config.BROKER, Name: ""})[name]
+	}
+	return value, ok
+}
+
+// partitions returns every partition, in a stable order
+func (c *Controller) partitions() []TopicPartition {
+	partitions := []TopicPartition{}
+	for _, name := range c.image.TopicNames() {
+		topic, _ := c.image.Topic(name)
+		for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+			partitions = append(partitions, TopicPartition{Topic: name, Partition: partitionId})
+		}
+	}
+	return partitions
+}
+
+func (c *Controller) isActiveBroker(brokerId int32) bool {
+	broker, ok := c.image.Broker(brokerId)
+	return ok && broker.IsActive()
+}
+
+// electLeader returns the first replica, in the order of the assignment, that is in the ISR and active
+func (c *Controller) electLeader(replicas []int32, isr []int32) int32 {
+	for _, replica := range replicas {
+		if slices.Contains(isr, replica) && c.isActiveBroker(replica) {
+			return replica
+		}
+	}
+	return metadata.NO_LEADER
+}
@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+// changeLeader moves the leadership of a partition away from where the elections put it
+func changeLeader(t *testing.T, active *Controller, topic string, partitionId int32, isr []int32, leader int32) {
+	t.Helper()
+
+	topicImage, _ := active.image.Topic(topic)
+	if _, err := active.appendRecords([]metadata.Record{
+		&metadata.PartitionChangeRecord{PartitionId: partitionId, TopicId: topicImage.Id, Isr: isr, Leader: leader},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fenceBroker(t *testing.T, active *Controller, now time.Time, brokerId int32, epoch int64) {
+	t.Helper()
+
+	if _, err := active.Heartbeat(BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch, WantFence: true}, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestElectLeaders(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2, 3)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {2, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.CreateTopic("bar", [][]int32{{3, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	changeLeader(t, active, "foo", 0, nil, 2)
+	// bar-0 has no leader once broker 3, the last member of its ISR, is fenced
+	changeLeader(t, active, "bar", 0, []int32{3}, 3)
+	fenceBroker(t, active, quorum.now, 3, epochs[3])
+
+	tests := []struct {
+		name         string
+		electionType ElectionType
+		partitions   []TopicPartition
+		want         map[TopicPartition]error
+	}{
+		{"Preferred", PREFERRED, []TopicPartition{{"foo", 0}, {"foo", 1}, {"baz", 0}, {"foo", 2}}, map[TopicPartition]error{
+			{"foo", 0}: nil,
+			{"foo", 1}: ErrElectionNotNeeded,
+			{"baz", 0}: metadata.ErrUnknownTopic,
+			{"foo", 2}: metadata.ErrUnknownPartition,
+		}},
+		// Broker 3 is fenced
+		{"Every partition preferred", PREFERRED, nil, map[TopicPartition]error{
+			{"bar", 0}: ErrPreferredLeaderNotAvailable,
+		}},
+		{"Unclean", UNCLEAN, []TopicPartition{{"bar", 0}, {"foo", 0}}, map[TopicPartition]error{
+			{"bar", 0}: nil,
+			{"foo", 0}: ErrElectionNotNeeded,
+		}},
+		{"Every partition unclean", UNCLEAN, nil, map[TopicPartition]error{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _, err := active.ElectLeaders(tt.electionType, tt.partitions)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(results) != len(tt.want) {
+				t.Errorf("got %d results, want %d: %v", len(results), len(tt.want), results)
+			}
+			for partition, want := range tt.want {
+				if got, ok := results[partition]; !ok || !errors.Is(got, want) {
+					t.Errorf("%v: got %v, want %v", partition, got, want)
+				}
+			}
+		})
+	}
+
+	quorum.poll(time.Second)
+	image := quorum.loader.Image()
+
+	want := map[string]metadata.PartitionImage{
+		"foo": {Replicas: []int32{1, 2}, Isr: []int32{1, 2}, Leader: 1, LeaderEpoch: 2, PartitionEpoch: 2},
+		// The unclean election made the leader the only member of the ISR
+		"bar": {Replicas: []int32{3, 2}, Isr: []int32{2}, Leader: 2, LeaderEpoch: 3, PartitionEpoch: 3, Elr: []int32{}, LastKnownElr: []int32{}},
+	}
+	for name, partition := range want {
+		topic, _ := image.Topic(name)
+		if got := *topic.Partitions[0]; !reflect.DeepEqual(got, partition) {
+			t.Errorf("%s-0: got %+v, want %+v", name, got, partition)
+		}
+	}
+
+	if _, _, err := active.ElectLeaders(ElectionType(2), nil); !errors.Is(err, ErrInvalidElectionType) {
+		t.Errorf("expected ErrInvalidElectionType, got %v", err)
+	}
+	for nodeId, controller := range quorum.controllers {
+		if controller == active {
+			continue
+		}
+		if _, _, err := controller.ElectLeaders(PREFERRED, nil); !errors.Is(err, ErrNotController) {
+			t.Errorf("controller %d: expected ErrNotController, got %v", nodeId, err)
+		}
+	}
+}
+
+func TestUncleanLeaderElectionEnabledByConfig(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	changeLeader(t, active, "foo", 0, []int32{1}, 1)
+	fenceBroker(t, active, quorum.now, 1, epochs[1])
+
+	topic, _ := active.image.Topic("foo")
+	if leader := topic.Partitions[0].Leader; leader != metadata.NO_LEADER {
+		t.Fatalf("foo-0 must have no leader without unclean leader election, got %d", leader)
+	}
+
+	enabled := "true"
+	if err := active.AlterConfig(config.Resource{Type: config.TOPIC, Name: "foo"}, "unclean.leader.election.enable", &enabled); err != nil {
+		t.Fatal(err)
+	}
+
+	topic, _ = active.image.Topic("foo")
+	if partition := topic.Partitions[0]; partition.Leader != 2 || !reflect.DeepEqual(partition.Isr, []int32{2}) {
+		t.Errorf("foo-0 must be led by broker 2 alone, got %+v", partition)
+	}
+}
+
+func TestAutoLeaderRebalance(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	for _, controller := range quorum.controllers {
+		controller.config.AutoLeaderRebalance = true
+		controller.config.LeaderImbalanceCheckInterval = 2 * time.Second
+		controller.config.LeaderImbalancePerBrokerPercentage = 10
+	}
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {1, 2}, {2, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	changeLeader(t, active, "foo", 0, nil, 2)
+
+	for range 3 {
+		quorum.poll(time.Second)
+		for brokerId, epoch := range epochs {
+			if _, err := active.Heartbeat(BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, quorum.now); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	topic, _ := quorum.loader.Image().Topic("foo")
+	for partitionId, partition := range topic.Partitions {
+		if partition.Leader != partition.Replicas[0] {
+			t.Errorf("partition %d must be led by its preferred replica, got %+v", partitionId, partition)
+		}
+	}
+}
+
+func TestEligibleLeaderReplicas(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2, 3)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	minIsr := "2"
+	if err := active.AlterConfig(config.Resource{Type: config.TOPIC, Name: "foo"}, "min.insync.replicas", &minIsr); err != nil {
+		t.Fatal(err)
+	}
+
+	partition := func() metadata.PartitionImage {
+		topic, _ := active.image.Topic("foo")
+		return *topic.Partitions[0]
+	}
+	restart := func(brokerId int32, previousEpoch int64) {
+		registration := BrokerRegistration{BrokerId: brokerId, IncarnationId: incarnationId(brokerId + 10), PreviousBrokerEpoch: previousEpoch}
+		epoch, err := active.RegisterBroker(registration, quorum.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, quorum.now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the replicas leaving an ISR smaller than min.insync.replicas are eligible
+	for _, brokerId := range []int32{1, 2, 3} {
+		fenceBroker(t, active, quorum.now, brokerId, epochs[brokerId])
+	}
+	want := metadata.PartitionImage{Replicas: []int32{1, 2, 3}, Isr: []int32{}, Leader: metadata.NO_LEADER, LeaderEpoch: 3, PartitionEpoch: 3, Elr: []int32{2, 3}}
+	if got := partition(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// Their sessions expire before they restart
+	quorum.poll(testSessionTimeout + time.Second)
+
+	// Broker 3 may have lost records, it is not elected without unclean leader election
+	restart(3, -1)
+	want.PartitionEpoch, want.Elr, want.LastKnownElr = 4, []int32{2}, []int32{3}
+	if got := partition(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	restart(2, epochs[2])
+	want = metadata.PartitionImage{Replicas: []int32{1, 2, 3}, Isr: []int32{2}, Leader: 2, LeaderEpoch: 4, PartitionEpoch: 5, Elr: []int32{}, LastKnownElr: []int32{}}
+	if got := partition(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package controller
+
+import "github.com/codecrafters-io/kafka-starter-go/app/metadata"
+
+// PRODUCER_ID_BLOCK_SIZE is the number of producer ids allocated to a broker at once, like Kafka
+const PRODUCER_ID_BLOCK_SIZE = 1000
+
+// ProducerIdsBlock is a range of producer ids allocated to a broker, which its transaction coordinator hands out
+type ProducerIdsBlock struct {
+	FirstProducerId int64
+	Size            int32
+}
+
+// AllocateProducerIds allocates the next block of producer ids to a registered broker, and returns it with the offset
+// of its record
+func (c *Controller) AllocateProducerIds(brokerId int32, brokerEpoch int64) (ProducerIdsBlock, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return ProducerIdsBlock{}, -1, ErrNotController
+	}
+
+	if _, err := c.registeredBroker(brokerId, brokerEpoch); err != nil {
+		return ProducerIdsBlock{}, -1, err
+	}
+
+	block := ProducerIdsBlock{FirstProducerId: c.image.NextProducerId(), Size: PRODUCER_ID_BLOCK_SIZE}
+	offset, err := c.appendRecords([]metadata.Record{&metadata.ProducerIdsRecord{
+		BrokerId:       brokerId,
+		BrokerEpoch:    brokerEpoch,
+		NextProducerId: block.FirstProducerId + int64(block.Size),
+	}})
+	if err != nil {
+		return ProducerIdsBlock{}, -1, err
+	}
+
+	return block, offset, nil
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+func TestAllocateProducerIds(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+	epochs := registerBrokers(t, active, quorum.now, 1, 2)
+
+	first, _, err := active.AllocateProducerIds(1, epochs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, offset, err := active.AllocateProducerIds(2, epochs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != (ProducerIdsBlock{FirstProducerId: 0, Size: PRODUCER_ID_BLOCK_SIZE}) || second.FirstProducerId != PRODUCER_ID_BLOCK_SIZE {
+		t.Errorf("expected consecutive blocks, got %v and %v", first, second)
+	}
+	if offset < 0 {
+		t.Errorf("expected the offset of the allocation, got %d", offset)
+	}
+
+	if _, _, err := active.AllocateProducerIds(1, epochs[1]-1); !errors.Is(err, metadata.ErrStaleBrokerEpoch) {
+		t.Errorf("expected ErrStaleBrokerEpoch, got %v", err)
+	}
+	if _, _, err := active.AllocateProducerIds(3, epochs[1]); !errors.Is(err, metadata.ErrUnknownBroker) {
+		t.Errorf("expected ErrUnknownBroker, got %v", err)
+	}
+
+	// Every controller knows the allocated blocks once they are replicated, a new active controller continues after them
+	quorum.poll(time.Second)
+	for _, controller := range quorum.controllers {
+		if next := controller.Image().NextProducerId(); next != 2*PRODUCER_ID_BLOCK_SIZE {
+			t.Errorf("expected the next producer id %d on controller %d, got %d", 2*PRODUCER_ID_BLOCK_SIZE, controller.nodeId, next)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"maps"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+)
+
+// ClientQuotaAlteration sets or removes quotas of a client quota entity
+type ClientQuotaAlteration struct {
+	Entity quota.Entity
+	Ops    []quota.Op
+}
+
+// AlterClientQuotas applies each valid alteration on its own, and returns the error of each alteration, nil for the
+// applied ones, and the offset of the last record, -1 when no quota changed
+func (c *Controller) AlterClientQuotas(alterations []ClientQuotaAlteration) ([]error, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, -1, ErrNotController
+	}
+
+	quotas := c.image.ClientQuotas()
+	records := []metadata.Record{}
+	results := make([]error, len(alterations))
+
+	for i, alteration := range alterations {
+		if err := quota.Validate(alteration.Entity, alteration.Ops); err != nil {
+			results[i] = err
+			continue
+		}
+
+		values := maps.Clone(quotas[alteration.Entity])
+		if values == nil {
+			values = make(map[string]float64)
+		}
+		for _, op := range alteration.Ops {
+			// Removing a quota that is not set changes nothing
+			if _, ok := values[op.Key]; op.Remove && !ok {
+				continue
+			}
+			if op.Remove {
+				delete(values, op.Key)
+			} else {
+				values[op.Key] = op.Value
+			}
+			records = append(records, &metadata.ClientQuotaRecord{Entity: alteration.Entity, Key: op.Key, Value: op.Value, Remove: op.Remove})
+		}
+		quotas[alteration.Entity] = values
+	}
+
+	offset := int64(-1)
+	if len(records) > 0 {
+		var err error
+		offset, err = c.appendRecords(records)
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return results, offset, nil
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+)
+
+func TestAlterClientQuotas(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	alice := quota.Entity{User: quota.Specific("alice")}
+	clients := quota.Entity{ClientId: quota.Default}
+
+	errs, offset, err := active.AlterClientQuotas([]ClientQuotaAlteration{
+		{Entity: alice, Ops: []quota.Op{{Key: quota.PRODUCER_BYTE_RATE, Value: 1024}, {Key: quota.CONSUMER_BYTE_RATE, Value: 2048}}},
+		{Entity: clients, Ops: []quota.Op{{Key: quota.REQUEST_PERCENTAGE, Value: 25}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < 0 || !reflect.DeepEqual(errs, []error{nil, nil}) {
+		t.Fatalf("expected the quotas to be appended, got offset %d and %v", offset, errs)
+	}
+
+	// An invalid alteration fails on its own
+	errs, _, err = active.AlterClientQuotas([]ClientQuotaAlteration{
+		{Entity: alice, Ops: []quota.Op{{Key: quota.PRODUCER_BYTE_RATE, Remove: true}}},
+		{Entity: clients, Ops: []quota.Op{{Key: "unknown_rate", Value: 1}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], quota.ErrInvalidQuota) {
+		t.Errorf("expected only the second alteration to fail, got %v", errs)
+	}
+
+	// Removing a quota that is not set appends nothing
+	_, offset, err = active.AlterClientQuotas([]ClientQuotaAlteration{
+		{Entity: alice, Ops: []quota.Op{{Key: quota.PRODUCER_BYTE_RATE, Remove: true}}},
+	})
+	if err != nil || offset != -1 {
+		t.Errorf("expected no record, got offset %d and %v", offset, err)
+	}
+
+	// The committed image of a follower has the same quotas once the quorum replicated the records
+	want := map[quota.Entity]map[string]float64{
+		alice:   {quota.CONSUMER_BYTE_RATE: 2048},
+		clients: {quota.REQUEST_PERCENTAGE: 25},
+	}
+	quorum.poll(time.Second)
+	for nodeId, controller := range quorum.controllers {
+		if got := controller.Image().ClientQuotas(); !reflect.DeepEqual(got, want) {
+			t.Errorf("controller %d: expected the quotas %v, got %v", nodeId, want, got)
+		}
+	}
+
+	for nodeId, controller := range quorum.controllers {
+		if controller == active {
+			continue
+		}
+		if _, _, err := controller.AlterClientQuotas(nil); !errors.Is(err, ErrNotController) {
+			t.Errorf("controller %d: expected ErrNotController, got %v", nodeId, err)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+var (
+	ErrNoReassignmentInProgress = errors.New("no reassignment in progress")
+	// ErrFencedLeaderEpoch and ErrInvalidUpdateVersion reject the ISR changes of a leader that does not know the
+	// latest leader or partition epoch
+	ErrFencedLeaderEpoch    = errors.New("fenced leader epoch")
+	ErrInvalidUpdateVersion = errors.New("stale partition epoch")
+	ErrIneligibleReplica    = errors.New("ineligible replica")
+)
+
+// Reassignment is a reassignment in progress: the replicas are both the target and the original replicas
+type Reassignment struct {
+	Replicas         []int32
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+}
+
+// IsrChange is the ISR that the leader of a partition proposes, with the leader and partition epochs it knows
+type IsrChange struct {
+	Topic          string
+	Partition      int32
+	LeaderId       int32
+	LeaderEpoch    int32
+	PartitionEpoch int32
+	Isr            []int32
+}
+
+// AlterPartitionReassignments reassigns partitions to their target replicas, or cancels their reassignment in progress
+// when the target is nil. The adding replicas join the replicas right away, the removing ones leave once every target
+// replica is in the ISR, see AlterPartition. A reassignment replaces the one in progress and a canceled one goes back
+// to the original replicas
+func (c *Controller) AlterPartitionReassignments(targets map[TopicPartition][]int32) (map[TopicPartition]error, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, ErrNotController
+	}
+
+	// The records of each partition are appended before the next one updates the throttles of the same topic
+	partitions := slices.SortedFunc(maps.Keys(targets), compareTopicPartitions)
+	results := make(map[TopicPartition]error)
+	for _, partition := range partitions {
+		records, err := c.reassignPartition(partition, targets[partition])
+		results[partition] = err
+		if len(records) == 0 {
+			continue
+		}
+		if _, err := c.appendRecords(records); err != nil {
+			return nil, err
+		}
+	}
+
+	// The reassignments without replicas to catch up complete right away
+	if err := c.appendCompletedReassignments(partitions); err != nil {
+		return nil, err
+	}
+	return results, c.appendOfflineLeaderElections()
+}
+
+// ListPartitionReassignments returns the reassignments in progress of partitions, of every partition when partitions
+// is nil. The partitions without a reassignment are left out
+func (c *Controller) ListPartitionReassignments(partitions []TopicPartition) (map[TopicPartition]Reassignment, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, ErrNotController
+	}
+
+	if partitions == nil {
+		partitions = c.partitions()
+	}
+
+	reassignments := make(map[TopicPartition]Reassignment)
+	for _, topicPartition := range partitions {
+		topic, ok := c.image.Topic(topicPartition.Topic)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, topicPartition.Topic)
+		}
+
+		partition, ok := topic.Partitions[topicPartition.Partition]
+		if !ok || !partition.IsReassigning() {
+			continue
+		}
+		reassignments[topicPartition] = Reassignment{
+			Replicas:         slices.Clone(partition.Replicas),
+			AddingReplicas:   slices.Clone(partition.AddingReplicas),
+			RemovingReplicas: slices.Clone(partition.RemovingReplicas),
+		}
+	}
+
+	return reassignments, nil
+}
+
+// AlterPartition applies an ISR change of the leader of a partition: followers join the ISR once they caught up and
+// leave it when they lag, for the ELR when the ISR gets smaller than min.insync.replicas. A reassignment completes
+// once every target replica is in the ISR. It returns the partition epoch after the change
+func (c *Controller) AlterPartition(change IsrChange) (int32, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return 0, ErrNotController
+	}
+
+	topic, ok := c.image.Topic(change.Topic)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, change.Topic)
+	}
+	partition, ok := topic.Partitions[change.Partition]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s-%d", metadata.ErrUnknownPartition, change.Topic, change.Partition)
+	}
+
+	if change.LeaderId != partition.Leader || change.LeaderEpoch != partition.LeaderEpoch {
+		return 0, fmt.Errorf("%w: %s-%d is led by %d in epoch %d", ErrFencedLeaderEpoch, change.Topic, change.Partition, partition.Leader, partition.LeaderEpoch)
+	}
+	if change.PartitionEpoch != partition.PartitionEpoch {
+		return 0, fmt.Errorf("%w: %s-%d is in epoch %d", ErrInvalidUpdateVersion, change.Topic, change.Partition, partition.PartitionEpoch)
+	}
+
+	if !slices.Contains(change.Isr, change.LeaderId) || len(slices.Compact(slices.Sorted(slices.Values(change.Isr)))) != len(change.Isr) {
+		return 0, fmt.Errorf("%w: ISR %v of %s-%d", ErrIneligibleReplica, change.Isr, change.Topic, change.Partition)
+	}
+	for _, replica := range change.Isr {
+		if !slices.Contains(partition.Replicas, replica) || (!slices.Contains(partition.Isr, replica) && !c.isActiveBroker(replica)) {
+			return 0, fmt.Errorf("%w: replica %d cannot join the ISR of %s-%d", ErrIneligibleReplica, replica, change.Topic, change.Partition)
+		}
+	}
+
+	record := &metadata.PartitionChangeRecord{PartitionId: change.Partition, TopicId: topic.Id, Isr: slices.Clone(change.Isr), Leader: metadata.NO_LEADER_CHANGE}
+	if len(change.Isr) >= c.minInsyncReplicas(change.Topic) {
+		record.Elr, record.LastKnownElr = []int32{}, []int32{}
+	} else {
+		record.Elr = slices.DeleteFunc(slices.Clone(partition.Elr), func(replica int32) bool { return slices.Contains(change.Isr, replica) })
+		for _, replica := range partition.Isr {
+			if !slices.Contains(change.Isr, replica) {
+				record.Elr = append(record.Elr, replica)
+			}
+		}
+	}
+
+	if _, err := c.appendRecords([]metadata.Record{record}); err != nil {
+		return 0, err
+	}
+	if err := c.appendCompletedReassignments([]TopicPartition{{Topic: change.Topic, Partition: change.Partition}}); err != nil {
+		return 0, err
+	}
+
+	// The records changed the partition in place
+	return partition.PartitionEpoch, nil
+}
+
+// reassignPartition returns the records that start or cancel the reassignment of a partition
+func (c *Controller) reassignPartition(topicPartition TopicPartition, target []int32) ([]metadata.Record, error) {
+	topic, ok := c.image.Topic(topicPartition.Topic)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, topicPartition.Topic)
+	}
+	partition, ok := topic.Partitions[topicPartition.Partition]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s-%d", metadata.ErrUnknownPartition, topicPartition.Topic, topicPartition.Partition)
+	}
+
+	if target == nil {
+		if !partition.IsReassigning() {
+			return nil, fmt.Errorf("%w: %s-%d", ErrNoReassignmentInProgress, topic.Name, topicPartition.Partition)
+		}
+
+		c.logger.Info("Canceled a reassignment", "topic", topic.Name, "partition", topicPartition.Partition, "replicas", partition.OriginalReplicas())
+		return c.revertReassignment(topic, topicPartition.Partition), nil
+	}
+
+	if len(target) == 0 || len(slices.Compact(slices.Sorted(slices.Values(target)))) != len(target) {
+		return nil, fmt.Errorf("%w: %s-%d cannot be reassigned to %v", ErrInvalidReplicas, topic.Name, topicPartition.Partition, target)
+	}
+	for _, replica := range target {
+		if _, ok := c.image.Broker(replica); !ok {
+			return nil, fmt.Errorf("%w: broker %d of %s-%d is not registered", ErrInvalidReplicas, replica, topic.Name, topicPartition.Partition)
+		}
+	}
+
+	original := partition.OriginalReplicas()
+	if !partition.IsReassigning() && slices.Equal(original, target) {
+		return nil, nil
+	}
+
+	adding := slices.DeleteFunc(slices.Clone(target), func(replica int32) bool { return slices.Contains(original, replica) })
+	removing := slices.DeleteFunc(slices.Clone(original), func(replica int32) bool { return slices.Contains(target, replica) })
+	change := &metadata.PartitionChangeRecord{
+		PartitionId:      topicPartition.Partition,
+		TopicId:          topic.Id,
+		Leader:           metadata.NO_LEADER_CHANGE,
+		Replicas:         slices.Concat(target, removing),
+		AddingReplicas:   adding,
+		RemovingReplicas: removing,
+	}
+
+	// The replicas that the replaced reassignment was adding and that this one does not add leave the partition
+	isDropped := func(replica int32) bool {
+		return slices.Contains(partition.AddingReplicas, replica) && !slices.Contains(target, replica)
+	}
+	if slices.ContainsFunc(partition.Replicas, isDropped) {
+		change.Isr = slices.DeleteFunc(slices.Clone(partition.Isr), isDropped)
+		change.Elr = slices.DeleteFunc(slices.Clone(partition.Elr), isDropped)
+		change.LastKnownElr = slices.DeleteFunc(slices.Clone(partition.LastKnownElr), isDropped)
+		if isDropped(partition.Leader) {
+			change.Leader = c.electLeader(change.Replicas, change.Isr)
+		}
+	}
+
+	c.logger.Info("Started a reassignment", "topic", topic.Name, "partition", topicPartition.Partition, "replicas", original, "target", target)
+	records := []metadata.Record{change}
+	return append(records, c.throttleReassignment(topic, topicPartition.Partition, original, adding)...), nil
+}
+
+// revertReassignment returns the records that move a partition back to its original replicas
+func (c *Controller) revertReassignment(topic *metadata.TopicImage, partitionId int32) []metadata.Record {
+	partition := topic.Partitions[partitionId]
+	isAdding := func(replica int32) bool { return slices.Contains(partition.AddingReplicas, replica) }
+
+	change := &metadata.PartitionChangeRecord{
+		PartitionId:      partitionId,
+		TopicId:          topic.Id,
+		Isr:              slices.DeleteFunc(slices.Clone(partition.Isr), isAdding),
+		Leader:           metadata.NO_LEADER_CHANGE,
+		Elr:              slices.DeleteFunc(slices.Clone(partition.Elr), isAdding),
+		LastKnownElr:     slices.DeleteFunc(slices.Clone(partition.LastKnownElr), isAdding),
+		Replicas:         partition.OriginalReplicas(),
+		AddingReplicas:   []int32{},
+		RemovingReplicas: []int32{},
+	}
+	if isAdding(partition.Leader) {
+		change.Leader = c.electLeader(change.Replicas, change.Isr)
+	}
+
+	records := []metadata.Record{change}
+	return append(records, c.throttleReassignment(topic, partitionId, nil, nil)...)
+}
+
+// appendCompletedReassignments completes the reassignments of the partitions whose target replicas are all in the
+// ISR: the removing replicas leave the partition, and the leadership moves to a target replica when one of them led it
+func (c *Controller) appendCompletedReassignments(partitions []TopicPartition) error {
+	for _, topicPartition := range partitions {
+		topic, ok := c.image.Topic(topicPartition.Topic)
+		if !ok {
+			continue
+		}
+		partition, ok := topic.Partitions[topicPartition.Partition]
+		if !ok || !partition.IsReassigning() {
+			continue
+		}
+
+		target := partition.TargetReplicas()
+		if slices.ContainsFunc(target, func(replica int32) bool { return !slices.Contains(partition.Isr, replica) }) {
+			continue
+		}
+
+		isRemoving := func(replica int32) bool { return slices.Contains(partition.RemovingReplicas, replica) }
+		change := &metadata.PartitionChangeRecord{
+			PartitionId:      topicPartition.Partition,
+			TopicId:          topic.Id,
+			Isr:              slices.DeleteFunc(slices.Clone(partition.Isr), isRemoving),
+			Leader:           metadata.NO_LEADER_CHANGE,
+			Elr:              slices.DeleteFunc(slices.Clone(partition.Elr), isRemoving),
+			LastKnownElr:     slices.DeleteFunc(slices.Clone(partition.LastKnownElr), isRemoving),
+			Replicas:         target,
+			AddingReplicas:   []int32{},
+			RemovingReplicas: []int32{},
+		}
+		if isRemoving(partition.Leader) {
+			change.Leader = c.electLeader(target, change.Isr)
+		}
+
+		records := []metadata.Record{change}
+		records = append(records, c.throttleReassignment(topic, topicPartition.Partition, nil, nil)...)
+		if _, err := c.appendRecords(records); err != nil {
+			return err
+		}
+		c.logger.Info("Completed a reassignment", "topic", topic.Name, "partition", topicPartition.Partition, "replicas", target)
+	}
+
+	return nil
+}
+
+// throttleReassignment returns the config records that throttle the replication of a partition during its
+// reassignment, with [PartitionId]:[BrokerId] entries in leader.replication.throttled.replicas for the leaders and
+// follower.replication.throttled.replicas for the followers. Without leaders and followers, the entries of the
+// partition are removed. The replication is only throttled when a throttled rate is set for the cluster or a replica
+func (c *Controller) throttleReassignment(topic *metadata.TopicImage, partitionId int32, leaders []int32, followers []int32) []metadata.Record {
+	if len(leaders)+len(followers) > 0 && !c.isReplicationThrottled(slices.Concat(leaders, followers)) {
+		return nil
+	}
+
+	records := []metadata.Record{}
+	configs := c.image.Configs(config.Resource{Type: config.TOPIC, Name: topic.Name})
+	for name, replicas := range map[string][]int32{
+		"leader.replication.throttled.replicas":   leaders,
+		"follower.replication.throttled.replicas": followers,
+	} {
+		current, ok := configs[name]
+		if strings.TrimSpace(current) == "*" {
+			continue
+		}
+
+		prefix := strconv.Itoa(int(partitionId)) + ":"
+		entries := slices.DeleteFunc(config.SplitList(current), func(entry string) bool { return strings.HasPrefix(entry, prefix) })
+		for _, replica := range replicas {
+			entries = append(entries, prefix+strconv.Itoa(int(replica)))
+		}
+
+		var value *string
+		if len(entries) > 0 {
+			joined := strings.Join(entries, ",")
+			value = &joined
+		}
+		if (value == nil && !ok) || (value != nil && ok && *value == current) {
+			continue
+		}
+		records = append(records, &metadata.ConfigRecord{ResourceType: config.TOPIC, ResourceName: topic.Name, Name: name, Value: value})
+	}
+
+	// The records of a partition are in a stable order
+	slices.SortFunc(records, func(a, b metadata.Record) int {
+		return cmp.Compare(a.(*metadata.ConfigRecord).Name, b.(*metadata.ConfigRecord).Name)
+	})
+	return records
+}
+
+// isReplicationThrottled tells if a leader or follower throttled rate is set for the cluster or one of the brokers
+func (c *Controller) isReplicationThrottled(brokerIds []int32) bool {
+	resources := []config.Resource{{Type: config.BROKER, Name: ""}}
+	for _, brokerId := range brokerIds {
+		resources = append(resources, config.Resource{Type: config.BROKER, Name: strconv.Itoa(int(brokerId))})
+	}
+
+	for _, resource := range resources {
+		configs := c.image.Configs(resource)
+		if _, ok := configs["leader.replication.throttled.rate"]; ok {
+			return true
+		}
+		if _, ok := configs["follower.replication.throttled.rate"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func compareTopicPartitions(a, b TopicPartition) int {
+	return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+}
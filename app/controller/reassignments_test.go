@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+func TestPartitionReassignment(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	registerBrokers(t, active, quorum.now, 1, 2, 3)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	foo := TopicPartition{"foo", 0}
+
+	results, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{foo: {2, 3}})
+	if err != nil || results[foo] != nil {
+		t.Fatalf("expected the reassignment to start, got %v, %v", results, err)
+	}
+
+	want := Reassignment{Replicas: []int32{2, 3, 1}, AddingReplicas: []int32{3}, RemovingReplicas: []int32{1}}
+	reassignments, err := active.ListPartitionReassignments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reassignments, map[TopicPartition]Reassignment{foo: want}) {
+		t.Errorf("got %+v, want %+v", reassignments, want)
+	}
+	if _, err := active.ListPartitionReassignments([]TopicPartition{{"bar", 0}}); !errors.Is(err, metadata.ErrUnknownTopic) {
+		t.Errorf("expected ErrUnknownTopic, got %v", err)
+	}
+
+	topic, _ := active.image.Topic("foo")
+	partition := *topic.Partitions[0]
+	if partition.Leader != 1 || !reflect.DeepEqual(partition.Isr, []int32{1, 2}) {
+		t.Errorf("the leader and ISR must not change before broker 3 caught up, got %+v", partition)
+	}
+
+	change := IsrChange{Topic: "foo", Partition: 0, LeaderId: 1, LeaderEpoch: partition.LeaderEpoch, PartitionEpoch: partition.PartitionEpoch - 1, Isr: []int32{1, 2, 3}}
+	if _, err := active.AlterPartition(change); !errors.Is(err, ErrInvalidUpdateVersion) {
+		t.Errorf("expected ErrInvalidUpdateVersion, got %v", err)
+	}
+	change.PartitionEpoch, change.LeaderEpoch = partition.PartitionEpoch, partition.LeaderEpoch+1
+	if _, err := active.AlterPartition(change); !errors.Is(err, ErrFencedLeaderEpoch) {
+		t.Errorf("expected ErrFencedLeaderEpoch, got %v", err)
+	}
+	change.LeaderEpoch = partition.LeaderEpoch
+	if _, err := active.AlterPartition(IsrChange{Topic: "foo", Partition: 0, LeaderId: 1, LeaderEpoch: partition.LeaderEpoch, PartitionEpoch: partition.PartitionEpoch, Isr: []int32{1, 4}}); !errors.Is(err, ErrIneligibleReplica) {
+		t.Errorf("expected ErrIneligibleReplica, got %v", err)
+	}
+
+	// Broker 3 caught up, broker 1 leaves the partition and the leadership moves to a target replica
+	epoch, err := active.AlterPartition(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quorum.poll(time.Second)
+
+	topic, _ = quorum.loader.Image().Topic("foo")
+	got := *topic.Partitions[0]
+	wantPartition := metadata.PartitionImage{
+		Replicas:         []int32{2, 3},
+		Isr:              []int32{2, 3},
+		Leader:           2,
+		LeaderEpoch:      1,
+		PartitionEpoch:   3,
+		Elr:              []int32{},
+		LastKnownElr:     []int32{},
+		AddingReplicas:   []int32{},
+		RemovingReplicas: []int32{},
+	}
+	if !reflect.DeepEqual(got, wantPartition) {
+		t.Errorf("got %+v, want %+v", got, wantPartition)
+	}
+	if epoch != wantPartition.PartitionEpoch {
+		t.Errorf("expected partition epoch %d, got %d", wantPartition.PartitionEpoch, epoch)
+	}
+
+	if reassignments, err := active.ListPartitionReassignments(nil); err != nil || len(reassignments) != 0 {
+		t.Errorf("expected no reassignment in progress, got %v, %v", reassignments, err)
+	}
+}
+
+func TestAlterPartitionReassignments(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	registerBrokers(t, active, quorum.now, 1, 2, 3)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {1, 2}, {1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{
+		{"foo", 0}: {3},
+		{"foo", 1}: nil,
+		{"foo", 2}: {2},
+		{"foo", 3}: {1},
+		{"bar", 0}: {1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[TopicPartition]error{
+		{"foo", 0}: nil,
+		{"foo", 1}: ErrNoReassignmentInProgress,
+		{"foo", 2}: nil,
+		{"foo", 3}: metadata.ErrUnknownPartition,
+		{"bar", 0}: metadata.ErrUnknownTopic,
+	}
+	for partition, wantErr := range want {
+		if !errors.Is(results[partition], wantErr) || (wantErr == nil && results[partition] != nil) {
+			t.Errorf("%v: expected %v, got %v", partition, wantErr, results[partition])
+		}
+	}
+
+	for _, target := range [][]int32{{}, {2, 2}, {4}} {
+		results, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 1}: target})
+		if err != nil || !errors.Is(results[TopicPartition{"foo", 1}], ErrInvalidReplicas) {
+			t.Errorf("target %v: expected ErrInvalidReplicas, got %v, %v", target, results, err)
+		}
+	}
+
+	topic, _ := active.image.Topic("foo")
+	// Without a replica to catch up, the reassignment completes right away
+	if partition := topic.Partitions[2]; partition.IsReassigning() || partition.Leader != 2 || !reflect.DeepEqual(partition.Replicas, []int32{2}) || !reflect.DeepEqual(partition.Isr, []int32{2}) {
+		t.Errorf("foo-2 must be reassigned to [2], got %+v", partition)
+	}
+
+	// A reassignment replaces the one in progress, the replicas it no longer adds leave the partition
+	changeLeader(t, active, "foo", 0, []int32{1, 2, 3}, metadata.NO_LEADER_CHANGE)
+	if results, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 0}: {2}}); err != nil || results[TopicPartition{"foo", 0}] != nil {
+		t.Fatalf("expected the reassignment to be replaced, got %v, %v", results, err)
+	}
+	if partition := topic.Partitions[0]; partition.IsReassigning() || !reflect.DeepEqual(partition.Replicas, []int32{2}) || !reflect.DeepEqual(partition.Isr, []int32{2}) || partition.Leader != 2 {
+		t.Errorf("foo-0 must be reassigned to [2], got %+v", partition)
+	}
+
+	// A canceled reassignment goes back to the original replicas, in the order of the replicas during the reassignment
+	if _, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 1}: {3, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	changeLeader(t, active, "foo", 1, []int32{1, 2, 3}, 3)
+	results, err = active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 1}: nil})
+	if err != nil || results[TopicPartition{"foo", 1}] != nil {
+		t.Fatalf("expected the reassignment to be canceled, got %v, %v", results, err)
+	}
+	if partition := topic.Partitions[1]; partition.IsReassigning() || !reflect.DeepEqual(partition.Replicas, []int32{2, 1}) || !reflect.DeepEqual(partition.Isr, []int32{1, 2}) || partition.Leader != 2 {
+		t.Errorf("foo-1 must be back on [2 1] led by 2, got %+v", partition)
+	}
+
+	for nodeId, controller := range quorum.controllers {
+		if controller == active {
+			continue
+		}
+		if _, err := controller.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 1}: {2}}); !errors.Is(err, ErrNotController) {
+			t.Errorf("controller %d: expected ErrNotController, got %v", nodeId, err)
+		}
+	}
+}
+
+func TestReassignmentReplicationThrottles(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	registerBrokers(t, active, quorum.now, 1, 2, 3)
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	resource := config.Resource{Type: config.TOPIC, Name: "foo"}
+
+	// Without a throttled rate, the replication is not throttled
+	if _, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 0}: {2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if configs := active.image.Configs(resource); len(configs) != 0 {
+		t.Errorf("expected no throttled replicas, got %v", configs)
+	}
+
+	rate := "1048576"
+	if err := active.AlterConfig(config.Resource{Type: config.BROKER, Name: "3"}, "follower.replication.throttled.rate", &rate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 0}: {2, 3}, {"foo", 1}: {3, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"leader.replication.throttled.replicas":   "0:2,0:1,1:1,1:2",
+		"follower.replication.throttled.replicas": "0:3,1:3",
+	}
+	if got := active.image.Configs(resource); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The throttles of a partition are removed once its reassignment is over
+	if _, err := active.AlterPartitionReassignments(map[TopicPartition][]int32{{"foo", 0}: nil}); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{
+		"leader.replication.throttled.replicas":   "1:1,1:2",
+		"follower.replication.throttled.replicas": "1:3",
+	}
+	if got := active.image.Configs(resource); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	topic, _ := active.image.Topic("foo")
+	partition := topic.Partitions[1]
+	if _, err := active.AlterPartition(IsrChange{Topic: "foo", Partition: 1, LeaderId: 1, LeaderEpoch: partition.LeaderEpoch, PartitionEpoch: partition.PartitionEpoch, Isr: []int32{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := active.image.Configs(resource); len(got) != 0 {
+		t.Errorf("expected no throttled replicas, got %v", got)
+	}
+}
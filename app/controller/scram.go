@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+// ScramAlteration sets the SCRAM credential of a user for a mechanism code, or deletes it when the credential is nil
+type ScramAlteration struct {
+	User       string
+	Mechanism  int8
+	Credential *sasl.ScramCredential
+}
+
+// AlterUserScramCredentials applies the alterations of each user only when all of them are valid, and returns the
+// error of the users that were left unchanged and the offset of the last record, -1 when no credential changed
+func (c *Controller) AlterUserScramCredentials(alterations []ScramAlteration) (map[string]error, int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return nil, -1, ErrNotController
+	}
+
+	credentials := c.image.ScramCredentials()
+	users := []string{}
+	userRecords := make(map[string][]metadata.Record)
+	results := make(map[string]error)
+
+	for _, alteration := range alterations {
+		if _, seen := userRecords[alteration.User]; !seen {
+			users = append(users, alteration.User)
+			userRecords[alteration.User] = []metadata.Record{}
+		}
+		if results[alteration.User] != nil {
+			continue
+		}
+
+		if alteration.Credential != nil {
+			userRecords[alteration.User] = append(userRecords[alteration.User], metadata.NewUserScramCredentialRecord(alteration.User, alteration.Mechanism, *alteration.Credential))
+			continue
+		}
+
+		if _, ok := credentials[alteration.User][alteration.Mechanism]; !ok {
+			results[alteration.User] = fmt.Errorf("%w: attempt to delete the credential of %s for the mechanism %d, which does not exist", metadata.ErrUnknownScramCredential, alteration.User, alteration.Mechanism)
+			continue
+		}
+		userRecords[alteration.User] = append(userRecords[alteration.User], &metadata.RemoveUserScramCredentialRecord{Name: alteration.User, Mechanism: alteration.Mechanism})
+	}
+
+	records := []metadata.Record{}
+	for _, user := range users {
+		if results[user] == nil {
+			records = append(records, userRecords[user]...)
+		}
+	}
+
+	offset := int64(-1)
+	if len(records) > 0 {
+		var err error
+		offset, err = c.appendRecords(records)
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	return results, offset, nil
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+func TestAlterUserScramCredentials(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+
+	credential := sasl.ScramCredential{Salt: []byte("salt"), StoredKey: []byte("stored"), ServerKey: []byte("server"), Iterations: 4096}
+
+	errs, offset, err := active.AlterUserScramCredentials([]ScramAlteration{
+		{User: "alice", Mechanism: 1, Credential: &credential},
+		{User: "alice", Mechanism: 2, Credential: &credential},
+		{User: "bob", Mechanism: 1, Credential: &credential},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < 0 || len(errs) != 0 {
+		t.Fatalf("expected the credentials to be appended, got offset %d and %v", offset, errs)
+	}
+
+	// The deletion of a missing credential leaves every alteration of its user unchanged
+	errs, _, err = active.AlterUserScramCredentials([]ScramAlteration{
+		{User: "alice", Mechanism: 1},
+		{User: "bob", Mechanism: 2},
+		{User: "bob", Mechanism: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs["alice"] != nil || !errors.Is(errs["bob"], metadata.ErrUnknownScramCredential) {
+		t.Errorf("expected only bob's alterations to fail, got %v", errs)
+	}
+
+	credentials := active.image.ScramCredentials()
+	if _, ok := credentials["alice"][1]; ok || len(credentials["alice"]) != 1 {
+		t.Errorf("expected alice to keep only the mechanism 2, got %v", credentials["alice"])
+	}
+	if len(credentials["bob"]) != 1 {
+		t.Errorf("expected bob to keep the mechanism 1, got %v", credentials["bob"])
+	}
+
+	// The committed image of a follower has the same credentials once the quorum replicated the records
+	quorum.poll(time.Second)
+	for _, controller := range quorum.controllers {
+		if credentials := controller.Image().ScramCredentials(); len(credentials) != 2 {
+			t.Errorf("expected the credentials of alice and bob on controller %d, got %v", controller.nodeId, credentials)
+		}
+	}
+}
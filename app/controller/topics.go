@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+var (
+	ErrInvalidTopic             = errors.New("invalid topic")
+	ErrInvalidPartitions        = errors.New("invalid number of partitions")
+	ErrInvalidReplicationFactor = errors.New("invalid replication factor")
+)
+
+const (
+	maxTopicNameLength = 249
+	// The partitions of a topic are placed in memory before they are created, the number requested by a client is
+	// bounded
+	maxTopicPartitions = 100_000
+)
+
+// NewTopic is a topic to create. A topic without assignments gets NumPartitions partitions of ReplicationFactor
+// replicas, -1 for the configured defaults, placed on the active brokers
+type NewTopic struct {
+	Name              string
+	NumPartitions     int32
+	ReplicationFactor int16
+	Assignments       [][]int32
+	// The dynamic configs of the topic, validated by the caller
+	Configs map[string]string
+}
+
+// CreateTopic creates a topic with one partition per assignment, led by their first replica, and returns its id.
+// The topic is known to the other nodes once its records are committed
+func (c *Controller) CreateTopic(name string, assignments [][]int32) (string, error) {
+	topicId, _, err := c.CreateTopics(NewTopic{Name: name, NumPartitions: -1, ReplicationFactor: -1, Assignments: assignments}, false)
+	return topicId, err
+}
+
+// CreateTopics creates a topic and its configs in one batch of records, and returns its id and the replicas of its
+// partitions. With validateOnly the topic is checked and placed but not created
+func (c *Controller) CreateTopics(topic NewTopic, validateOnly bool) (string, [][]int32, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.activeEpoch < 0 {
+		return "", nil, ErrNotController
+	}
+	if err := c.validateTopicName(topic.Name); err != nil {
+		return "", nil, err
+	}
+
+	assignments := topic.Assignments
+	if assignments == nil {
+		var err error
+		assignments, err = c.placeReplicas(topic.NumPartitions, topic.ReplicationFactor)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if len(assignments) == 0 {
+		return "", nil, fmt.Errorf("%w: topic %s has no partitions", ErrInvalidReplicas, topic.Name)
+	}
+	for partitionId, replicas := range assignments {
+		if len(replicas) == 0 || len(slices.Compact(slices.Sorted(slices.Values(replicas)))) != len(replicas) {
+			return "", nil, fmt.Errorf("%w: partition %d of %s has replicas %v", ErrInvalidReplicas, partitionId, topic.Name, replicas)
+		}
+		for _, replica := range replicas {
+			if _, ok := c.image.Broker(replica); !ok {
+				return "", nil, fmt.Errorf("%w: broker %d of %s-%d is not registered", ErrInvalidReplicas, replica, topic.Name, partitionId)
+			}
+		}
+	}
+	if validateOnly {
+		return "", assignments, nil
+	}
+
+	topicId := metadata.NewTopicId()
+	records := []metadata.Record{&metadata.TopicRecord{Name: topic.Name, TopicId: topicId}}
+	for partitionId, replicas := range assignments {
+		records = append(records, &metadata.PartitionRecord{
+			PartitionId:    int32(partitionId),
+			TopicId:        topicId,
+			Replicas:       slices.Clone(replicas),
+			Isr:            slices.Clone(replicas),
+			Leader:         replicas[0],
+			LeaderEpoch:    0,
+			PartitionEpoch: 0,
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(topic.Configs)) {
+		value := topic.Configs[name]
+		records = append(records, &metadata.ConfigRecord{ResourceType: config.TOPIC, ResourceName: topic.Name, Name: name, Value: &value})
+	}
+
+	if _, err := c.appendRecords(records); err != nil {
+		return "", nil, err
+	}
+	return topicId, assignments, nil
+}
+
+// validateTopicName checks that a new topic has a legal name that no topic has, even once its periods are replaced
+// by underscores, which the names of the metrics of a topic do
+func (c *Controller) validateTopicName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("%w: topic name %q is illegal", ErrInvalidTopic, name)
+	}
+	if len(name) > maxTopicNameLength {
+		return fmt.Errorf("%w: topic name %s is longer than %d characters", ErrInvalidTopic, name, maxTopicNameLength)
+	}
+	for _, char := range name {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || char == '.' || char == '_' || char == '-') {
+			return fmt.Errorf("%w: topic name %s contains %q, only ASCII alphanumerics, '.', '_' and '-' are legal", ErrInvalidTopic, name, char)
+		}
+	}
+
+	if _, ok := c.image.Topic(name); ok {
+		return fmt.Errorf("%w: %s", metadata.ErrTopicExists, name)
+	}
+	metricName := strings.ReplaceAll(name, ".", "_")
+	for _, existing := range c.image.TopicNames() {
+		if strings.ReplaceAll(existing, ".", "_") == metricName {
+			return fmt.Errorf("%w: topic %s collides with topic %s", ErrInvalidTopic, name, existing)
+		}
+	}
+
+	return nil
+}
+
+// placeReplicas assigns the replicas of each partition round robin over the active brokers. Every topic starts from
+// another broker, so that the leaders of the topics spread across the cluster
+func (c *Controller) placeReplicas(numPartitions int32, replicationFactor int16) ([][]int32, error) {
+	if numPartitions == -1 {
+		numPartitions = int32(c.config.NumPartitions)
+	}
+	if replicationFactor == -1 {
+		replicationFactor = int16(c.config.DefaultReplicationFactor)
+	}
+	if numPartitions <= 0 || numPartitions > maxTopicPartitions {
+		return nil, fmt.Errorf("%w: %d partitions, expected between 1 and %d", ErrInvalidPartitions, numPartitions, maxTopicPartitions)
+	}
+	if replicationFactor <= 0 {
+		return nil, fmt.Errorf("%w: %d, expected at least 1", ErrInvalidReplicationFactor, replicationFactor)
+	}
+
+	brokers := slices.DeleteFunc(c.image.BrokerIds(), func(brokerId int32) bool { return !c.isActiveBroker(brokerId) })
+	if int(replicationFactor) > len(brokers) {
+		return nil, fmt.Errorf("%w: %d is larger than the %d active brokers", ErrInvalidReplicationFactor, replicationFactor, len(brokers))
+	}
+
+	start := len(c.image.TopicNames())
+	assignments := make([][]int32, numPartitions)
+	for partitionId := range assignments {
+		replicas := make([]int32, replicationFactor)
+		for i := range replicas {
+			replicas[i] = brokers[(start+partitionId+i)%len(brokers)]
+		}
+		assignments[partitionId] = replicas
+	}
+	return assignments, nil
+}
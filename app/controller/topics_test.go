@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+func TestCreateTopics(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+	active.config.NumPartitions = 2
+	active.config.DefaultReplicationFactor = 1
+
+	epochs := registerBrokers(t, active, quorum.now, 1, 2, 3)
+	fenceBroker(t, active, quorum.now, 3, epochs[3])
+	if _, err := active.CreateTopic("foo.bar", [][]int32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		topic NewTopic
+		want  [][]int32
+		err   error
+	}{
+		// The placement of the second topic starts from the second active broker
+		{"Placed", NewTopic{Name: "baz", NumPartitions: 3, ReplicationFactor: 2}, [][]int32{{2, 1}, {1, 2}, {2, 1}}, nil},
+		{"Defaults", NewTopic{Name: "qux", NumPartitions: -1, ReplicationFactor: -1}, [][]int32{{1}, {2}}, nil},
+		{"Assigned", NewTopic{Name: "quux", Assignments: [][]int32{{3, 1}}}, [][]int32{{3, 1}}, nil},
+		{"Existing topic", NewTopic{Name: "foo.bar", NumPartitions: 1, ReplicationFactor: 1}, nil, metadata.ErrTopicExists},
+		{"Colliding topic", NewTopic{Name: "foo_bar", NumPartitions: 1, ReplicationFactor: 1}, nil, ErrInvalidTopic},
+		{"Illegal name", NewTopic{Name: "foo/bar", NumPartitions: 1, ReplicationFactor: 1}, nil, ErrInvalidTopic},
+		{"No partitions", NewTopic{Name: "a", NumPartitions: 0, ReplicationFactor: 1}, nil, ErrInvalidPartitions},
+		{"Too many partitions", NewTopic{Name: "a", NumPartitions: 1 << 30, ReplicationFactor: 1}, nil, ErrInvalidPartitions},
+		{"Fenced brokers", NewTopic{Name: "a", NumPartitions: 1, ReplicationFactor: 3}, nil, ErrInvalidReplicationFactor},
+		{"Unregistered broker", NewTopic{Name: "a", Assignments: [][]int32{{1, 4}}}, nil, ErrInvalidReplicas},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topicId, got, err := active.CreateTopics(tt.topic, false)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected assignments %v, got %v", tt.want, got)
+			}
+			if topic, ok := active.image.Topic(tt.topic.Name); !ok || topic.Id != topicId || len(topic.Partitions) != len(tt.want) {
+				t.Errorf("expected topic %s with %d partitions, got %+v", topicId, len(tt.want), topic)
+			}
+		})
+	}
+}
+
+func TestCreateTopicsWithConfigs(t *testing.T) {
+	quorum := newTestQuorum(t, 0)
+	quorum.poll(5 * time.Second)
+	active := quorum.activeController()
+	registerBrokers(t, active, quorum.now, 1)
+
+	topic := NewTopic{Name: "foo", NumPartitions: 1, ReplicationFactor: 1, Configs: map[string]string{"retention.ms": "1000"}}
+	if _, _, err := active.CreateTopics(topic, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := active.image.Topic("foo"); ok {
+		t.Fatalf("validating a topic must not create it")
+	}
+
+	if _, _, err := active.CreateTopics(topic, false); err != nil {
+		t.Fatal(err)
+	}
+	quorum.poll(time.Second)
+
+	if got := quorum.loader.Image().Configs(config.Resource{Type: config.TOPIC, Name: "foo"}); !reflect.DeepEqual(got, topic.Configs) {
+		t.Errorf("expected configs %v, got %v", topic.Configs, got)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/ssl"
+)
+
+// brokerListener is a bound listener, each one runs its own accept loop
+type brokerListener struct {
+	net.Listener
+	config config.Listener
+	// principalMapper is nil unless the listener uses SSL
+	principalMapper *ssl.PrincipalMapper
+}
+
+// newListener binds a listener, wrapping its connections in TLS for SSL and SASL_SSL
+func newListener(listenerConfig config.Listener, sslConfig config.SslConfig) (*brokerListener, error) {
+	listener, err := net.Listen("tcp", listenerConfig.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	if !listenerConfig.SecurityProtocol.UsesSsl() {
+		return &brokerListener{Listener: listener, config: listenerConfig}, nil
+	}
+
+	tlsConfig, err := ssl.NewServerConfig(sslConfig.KeystoreLocation, sslConfig.TruststoreLocation, sslConfig.ClientAuth)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	mapper, err := ssl.NewPrincipalMapper(sslConfig.PrincipalMappingRules)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &brokerListener{
+		Listener:        tls.NewListener(listener, tlsConfig),
+		config:          listenerConfig,
+		principalMapper: mapper,
+	}, nil
+}
@@ -1,19 +1,230 @@
 package main
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/metrics"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/request"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// How often and how much of the logs moving to another log dir are copied
+const (
+	futureLogCopyInterval = 100 * time.Millisecond
+	futureLogCopyBytes    = 1024 * 1024
 )
 
 func main() {
-	listener, err := net.Listen("tcp", "0.0.0.0:9092")
+	serverConfig, err := config.LoadServerConfig(os.Args[1:], os.Environ())
+	if err != nil {
+		slog.Error("Invalid broker configuration", "error", err)
+		os.Exit(1)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: serverConfig.LoggerLevel}))
+
+	// The logs of a broker that stopped cleanly are not checked again
+	cleanRestart, previousBrokerEpoch, err := storage.ConsumeCleanShutdown(serverConfig.LogDirs)
 	if err != nil {
-		fmt.Println("Failed to bind to port 9092")
+		logger.Error("Failed to read the log dirs", "error", err)
+		os.Exit(1)
+	}
+	if !cleanRestart {
+		logger.Warn("The previous shutdown was not clean, recovering the logs")
+	}
+
+	// A log dir that cannot be read starts offline, the broker serves the partitions of the others
+	logs, err := storage.LoadLogManager(serverConfig.LogDirs, serverConfig.NodeId, cleanRestart)
+	if err != nil {
+		logger.Error("Failed to load the log dirs", "error", err)
+		os.Exit(1)
+	}
+	logs.WatchOfflineDirs(func(dir string, partitions []storage.TopicPartition) {
+		logger.Error("Log dir went offline", "dir", dir, "partitions", len(partitions))
+		// Like Kafka, the broker stops once no log dir is left
+		if len(logs.OnlineDirs()) == 0 {
+			logger.Error("Every log dir is offline, stopping the broker")
+			os.Exit(1)
+		}
+	})
+
+	// The metadata log lives in the first log dir, its directory id tells the quorum which disk the node has. The
+	// broker serves the metadata its loader builds from the committed records, fetched by the controller of a
+	// combined process or by an observer of the quorum on a broker. A broker without controller.quorum.voters has no
+	// metadata
+	loader := metadata.NewLoader(logger)
+	var quorum *quorumController
+	var observer *quorumObserver
+	var metadataController *controller.Controller
+	var channel *request.ControllerChannel
+	var lifecycle *brokerLifecycle
+	if len(serverConfig.Quorum.Voters) > 0 {
+		meta, err := storage.EnsureMetaProperties(serverConfig.LogDirs[0], serverConfig.NodeId)
+		if err != nil {
+			logger.Error("Failed to read the metadata log dir", "error", err)
+			os.Exit(1)
+		}
+
+		if slices.Contains(serverConfig.ProcessRoles, "controller") {
+			quorum, err = startQuorumController(serverConfig, meta.DirectoryId, loader, logger)
+			if err == nil {
+				metadataController = quorum.controller
+			}
+		} else {
+			observer, err = startQuorumObserver(serverConfig, meta.DirectoryId, loader, logger)
+		}
+		if err != nil {
+			logger.Error("Failed to load the metadata log", "error", err)
+			os.Exit(1)
+		}
+
+		// Every broker registers with the active controller, the one of its own process included
+		if slices.Contains(serverConfig.ProcessRoles, "broker") {
+			// The node of the process tells which controller leads the quorum
+			var node *raft.Node
+			if quorum != nil {
+				node = quorum.controller.Node()
+			} else {
+				node = observer.node
+			}
+			channel = request.NewControllerChannel(serverConfig.NodeId, quorumListenerName(serverConfig), node, serverConfig.Quorum.RequestTimeout, serverConfig.Quorum.RetryBackoff)
+			lifecycle = startBrokerLifecycle(serverConfig, channel, loader, logs.DirectoryIds(), previousBrokerEpoch, logger)
+		}
+	}
+
+	registry := metrics.NewRegistry()
+	broker := request.NewKafkaBroker(serverConfig, registry, logger, metadataController, loader, logs, channel)
+
+	stopCopies := make(chan struct{})
+	var copies sync.WaitGroup
+	copies.Add(1)
+	go func() {
+		defer copies.Done()
+		copyFutureLogs(logs, logger, stopCopies)
+	}()
+
+	listeners := make([]*brokerListener, 0, len(serverConfig.Listeners))
+	for _, listenerConfig := range serverConfig.Listeners {
+		listener, err := newListener(listenerConfig, serverConfig.Ssl)
+		if err != nil {
+			logger.Error("Failed to bind listener", "listener", listenerConfig.Name, "address", listenerConfig.Address(), "error", err)
+			os.Exit(1)
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	var metricsServer *http.Server
+	if serverConfig.MetricsListener != "" {
+		metricsServer, err = serveMetrics(serverConfig.MetricsListener, registry, logger)
+		if err != nil {
+			logger.Error("Failed to bind the metrics endpoint", "address", serverConfig.MetricsListener, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	server := newServer(broker, listeners, serverConfig.Network, registry, logger)
+	server.serve()
+	logger.Info("Started broker", "node_id", serverConfig.NodeId, "listeners", len(listeners))
+
+	received := <-signals
+	logger.Info("Shutting down", "signal", received.String())
+
+	// The partitions the broker leads move to other replicas while it still serves, a broker whose session lapses
+	// is fenced anyway
+	if lifecycle != nil {
+		if err := lifecycle.controlledShutdown(serverConfig.Quorum.SessionTimeout); err != nil {
+			logger.Warn("Controlled shutdown did not complete", "error", err)
+		}
+		channel.Close()
+	}
+
+	if err := server.shutdown(shutdownTimeout); err != nil {
+		logger.Error("Shutdown did not complete", "error", err)
+		os.Exit(1)
+	}
+	broker.Shutdown()
+
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+
+	if quorum != nil {
+		quorum.shutdown()
+	}
+	if observer != nil {
+		observer.shutdown()
+	}
+
+	close(stopCopies)
+	copies.Wait()
+
+	// The logs are only trusted on the next start when every one of them reached the disk
+	if err := logs.Close(); err != nil {
+		logger.Error("Failed to close the logs", "error", err)
 		os.Exit(1)
 	}
 
+	// The next registration tells the controller the broker stopped cleanly from this one
+	brokerEpoch := int64(-1)
+	if lifecycle != nil {
+		brokerEpoch = lifecycle.brokerEpoch()
+	}
+	if err := storage.MarkCleanShutdown(logs.OnlineDirs(), brokerEpoch); err != nil {
+		logger.Error("Failed to mark the clean shutdown", "error", err)
+		os.Exit(1)
+	}
+}
+
+// copyFutureLogs moves the logs that AlterReplicaLogDirs asked for in the background, a few batches at a time
+func copyFutureLogs(logs *storage.LogManager, logger *slog.Logger, stop <-chan struct{}) {
+	ticker := time.NewTicker(futureLogCopyInterval)
+	defer ticker.Stop()
+
 	for {
-		listenForConnections(listener)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, partition := range logs.CopyFutureLogs(futureLogCopyBytes) {
+				logger.Info("Moved a log to its new log dir", "partition", partition.String())
+			}
+		}
 	}
 }
+
+// serveMetrics binds the /metrics endpoint, so that a port already in use fails the startup like the broker listeners
+func serveMetrics(address string, registry *metrics.Registry, logger *slog.Logger) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	metricsServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics endpoint stopped", "error", err)
+		}
+	}()
+
+	return metricsServer, nil
+}
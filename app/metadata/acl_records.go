@@ -0,0 +1,168 @@
+package metadata
+
+import (
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// AccessControlEntryRecord creates an ACL binding, the id identifies it in the RemoveAccessControlEntryRecord that
+// deletes it
+type AccessControlEntryRecord struct {
+	Id             string
+	ResourceType   acl.ResourceType
+	ResourceName   string
+	PatternType    acl.PatternType
+	Principal      string
+	Host           string
+	Operation      acl.Operation
+	PermissionType acl.PermissionType
+}
+
+type RemoveAccessControlEntryRecord struct {
+	Id string
+}
+
+func (r *AccessControlEntryRecord) Type() RecordType       { return ACCESS_CONTROL_ENTRY_RECORD }
+func (r *RemoveAccessControlEntryRecord) Type() RecordType { return REMOVE_ACCESS_CONTROL_ENTRY_RECORD }
+
+// NewAccessControlEntryRecord returns the record creating binding with the id id
+func NewAccessControlEntryRecord(id string, binding acl.Binding) *AccessControlEntryRecord {
+	return &AccessControlEntryRecord{
+		Id:             id,
+		ResourceType:   binding.ResourceType,
+		ResourceName:   binding.ResourceName,
+		PatternType:    binding.PatternType,
+		Principal:      binding.Principal,
+		Host:           binding.Host,
+		Operation:      binding.Operation,
+		PermissionType: binding.PermissionType,
+	}
+}
+
+// Binding returns the binding the record creates
+func (r *AccessControlEntryRecord) Binding() acl.Binding {
+	return acl.Binding{
+		ResourceType:   r.ResourceType,
+		ResourceName:   r.ResourceName,
+		PatternType:    r.PatternType,
+		Principal:      r.Principal,
+		Host:           r.Host,
+		Operation:      r.Operation,
+		PermissionType: r.PermissionType,
+	}
+}
+
+func (r *AccessControlEntryRecord) size() int {
+	return 16 + 1 + 10 + len(r.ResourceName) + 1 + 10 + len(r.Principal) + 10 + len(r.Host) + 1 + 1
+}
+
+func (r *AccessControlEntryRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeUUID(buffer, index, r.Id)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt8(buffer, index, int8(r.ResourceType))
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.ResourceName)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt8(buffer, index, int8(r.PatternType))
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.Principal)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.Host)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt8(buffer, index, int8(r.Operation))
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeInt8(buffer, index, int8(r.PermissionType))
+}
+
+func parseAccessControlEntryRecord(buffer []byte, index int) (Record, int, error) {
+	record := &AccessControlEntryRecord{}
+	var err error
+
+	record.Id, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	resourceType, index, err := parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	record.ResourceType = acl.ResourceType(resourceType)
+
+	record.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	patternType, index, err := parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	record.PatternType = acl.PatternType(patternType)
+
+	record.Principal, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Host, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	operation, index, err := parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	record.Operation = acl.Operation(operation)
+
+	permissionType, index, err := parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	record.PermissionType = acl.PermissionType(permissionType)
+
+	return record, index, nil
+}
+
+func (r *RemoveAccessControlEntryRecord) size() int {
+	return 16
+}
+
+func (r *RemoveAccessControlEntryRecord) serialize(buffer []byte, index int) (int, error) {
+	return serializer.SerializeUUID(buffer, index, r.Id)
+}
+
+func parseRemoveAccessControlEntryRecord(buffer []byte, index int) (Record, int, error) {
+	record := &RemoveAccessControlEntryRecord{}
+	var err error
+
+	record.Id, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
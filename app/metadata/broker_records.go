@@ -0,0 +1,327 @@
+package metadata
+
+import (
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// BrokerEndpoint is a listener a broker registered, with the security protocol id of the Kafka protocol
+type BrokerEndpoint struct {
+	Name             string
+	Host             string
+	Port             uint16
+	SecurityProtocol int16
+}
+
+// RegisterBrokerRecord registers a broker, replacing its previous registration. The epoch identifies the registration
+// in the heartbeats of the broker
+type RegisterBrokerRecord struct {
+	BrokerId             int32
+	IncarnationId        string
+	BrokerEpoch          int64
+	Endpoints            []BrokerEndpoint
+	Rack                 *string
+	Fenced               bool
+	InControlledShutdown bool
+	LogDirs              []string
+}
+
+type UnregisterBrokerRecord struct {
+	BrokerId    int32
+	BrokerEpoch int64
+}
+
+// FenceBrokerRecord fences a broker, which then leads no partition and is in no ISR
+type FenceBrokerRecord struct {
+	Id    int32
+	Epoch int64
+}
+
+type UnfenceBrokerRecord struct {
+	Id    int32
+	Epoch int64
+}
+
+// BrokerRegistrationChangeRecord records that a broker started a controlled shutdown
+type BrokerRegistrationChangeRecord struct {
+	BrokerId             int32
+	BrokerEpoch          int64
+	InControlledShutdown bool
+}
+
+func (r *RegisterBrokerRecord) Type() RecordType           { return REGISTER_BROKER_RECORD }
+func (r *UnregisterBrokerRecord) Type() RecordType         { return UNREGISTER_BROKER_RECORD }
+func (r *FenceBrokerRecord) Type() RecordType              { return FENCE_BROKER_RECORD }
+func (r *UnfenceBrokerRecord) Type() RecordType            { return UNFENCE_BROKER_RECORD }
+func (r *BrokerRegistrationChangeRecord) Type() RecordType { return BROKER_REGISTRATION_CHANGE_RECORD }
+
+func (r *RegisterBrokerRecord) size() int {
+	size := 4 + 16 + 8 + 10 + 2 + 10 + 16*len(r.LogDirs)
+	for _, endpoint := range r.Endpoints {
+		size += 10 + len(endpoint.Name) + 10 + len(endpoint.Host) + 2 + 2
+	}
+	if r.Rack != nil {
+		size += len(*r.Rack)
+	}
+	return size + 10
+}
+
+func (r *RegisterBrokerRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeInt32(buffer, index, r.BrokerId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeUUID(buffer, index, r.IncarnationId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.BrokerEpoch)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Endpoints)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, endpoint := range r.Endpoints {
+		index, err = serializer.SerializeCompactString(buffer, index, endpoint.Name)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, endpoint.Host)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, int16(endpoint.Port))
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, endpoint.SecurityProtocol)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.Rack)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeBoolean(buffer, index, r.Fenced)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeBoolean(buffer, index, r.InControlledShutdown)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.LogDirs)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, logDir := range r.LogDirs {
+		index, err = serializer.SerializeUUID(buffer, index, logDir)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	return index, nil
+}
+
+func parseRegisterBrokerRecord(buffer []byte, index int) (Record, int, error) {
+	record := &RegisterBrokerRecord{}
+	var err error
+
+	record.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.IncarnationId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.BrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Endpoints = make([]BrokerEndpoint, 0, max(length, 0))
+	for i := 0; i < length; i++ {
+		endpoint := BrokerEndpoint{}
+
+		endpoint.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		endpoint.Host, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		port, newIndex, err := parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		endpoint.Port = uint16(port)
+		index = newIndex
+
+		endpoint.SecurityProtocol, index, err = parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		record.Endpoints = append(record.Endpoints, endpoint)
+	}
+
+	record.Rack, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Fenced, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.InControlledShutdown, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	length, index, err = parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.LogDirs = make([]string, 0, max(length, 0))
+	for i := 0; i < length; i++ {
+		var logDir string
+		logDir, index, err = parser.ExtractUUID(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		record.LogDirs = append(record.LogDirs, logDir)
+	}
+
+	return record, index, nil
+}
+
+func (r *UnregisterBrokerRecord) size() int {
+	return 4 + 8
+}
+
+func (r *UnregisterBrokerRecord) serialize(buffer []byte, index int) (int, error) {
+	return serializeBrokerIdAndEpoch(buffer, index, r.BrokerId, r.BrokerEpoch)
+}
+
+func parseUnregisterBrokerRecord(buffer []byte, index int) (Record, int, error) {
+	brokerId, brokerEpoch, index, err := extractBrokerIdAndEpoch(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	return &UnregisterBrokerRecord{BrokerId: brokerId, BrokerEpoch: brokerEpoch}, index, nil
+}
+
+func (r *FenceBrokerRecord) size() int {
+	return 4 + 8
+}
+
+func (r *FenceBrokerRecord) serialize(buffer []byte, index int) (int, error) {
+	return serializeBrokerIdAndEpoch(buffer, index, r.Id, r.Epoch)
+}
+
+func parseFenceBrokerRecord(buffer []byte, index int) (Record, int, error) {
+	brokerId, brokerEpoch, index, err := extractBrokerIdAndEpoch(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	return &FenceBrokerRecord{Id: brokerId, Epoch: brokerEpoch}, index, nil
+}
+
+func (r *UnfenceBrokerRecord) size() int {
+	return 4 + 8
+}
+
+func (r *UnfenceBrokerRecord) serialize(buffer []byte, index int) (int, error) {
+	return serializeBrokerIdAndEpoch(buffer, index, r.Id, r.Epoch)
+}
+
+func parseUnfenceBrokerRecord(buffer []byte, index int) (Record, int, error) {
+	brokerId, brokerEpoch, index, err := extractBrokerIdAndEpoch(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	return &UnfenceBrokerRecord{Id: brokerId, Epoch: brokerEpoch}, index, nil
+}
+
+func (r *BrokerRegistrationChangeRecord) size() int {
+	return 4 + 8 + 1
+}
+
+func (r *BrokerRegistrationChangeRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializeBrokerIdAndEpoch(buffer, index, r.BrokerId, r.BrokerEpoch)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeBoolean(buffer, index, r.InControlledShutdown)
+}
+
+func parseBrokerRegistrationChangeRecord(buffer []byte, index int) (Record, int, error) {
+	record := &BrokerRegistrationChangeRecord{}
+	var err error
+
+	record.BrokerId, record.BrokerEpoch, index, err = extractBrokerIdAndEpoch(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.InControlledShutdown, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+func serializeBrokerIdAndEpoch(buffer []byte, index int, brokerId int32, brokerEpoch int64) (int, error) {
+	index, err := serializer.SerializeInt32(buffer, index, brokerId)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeInt64(buffer, index, brokerEpoch)
+}
+
+func extractBrokerIdAndEpoch(buffer []byte, index int) (int32, int64, int, error) {
+	brokerId, index, err := parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return 0, 0, index, err
+	}
+
+	brokerEpoch, index, err := parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return 0, 0, index, err
+	}
+
+	return brokerId, brokerEpoch, index, nil
+}
@@ -0,0 +1,494 @@
+package metadata
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+var (
+	ErrUnknownTopic           = errors.New("unknown topic")
+	ErrUnknownPartition       = errors.New("unknown partition")
+	ErrTopicExists            = errors.New("topic already exists")
+	ErrUnknownBroker          = errors.New("broker not registered")
+	ErrStaleBrokerEpoch       = errors.New("stale broker epoch")
+	ErrUnknownAcl             = errors.New("unknown ACL")
+	ErrUnknownScramCredential = errors.New("unknown SCRAM credential")
+)
+
+// PartitionImage is the state of a partition. The eligible leader replicas (ELR) left the ISR while it was smaller
+// than min.insync.replicas, when no record could be committed: they hold every committed record and can lead safely.
+// The last known ELR are the replicas that left the ELR after an unclean shutdown, the best candidates of an unclean
+// election. While a reassignment is in progress, the replicas are both the target and the original replicas: the
+// adding replicas are in the target only and the removing replicas in the original replicas only
+type PartitionImage struct {
+	Replicas         []int32
+	Isr              []int32
+	Leader           int32
+	LeaderEpoch      int32
+	PartitionEpoch   int32
+	Elr              []int32
+	LastKnownElr     []int32
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+}
+
+// IsReassigning tells if a reassignment of the partition is in progress
+func (p *PartitionImage) IsReassigning() bool {
+	return len(p.AddingReplicas) > 0 || len(p.RemovingReplicas) > 0
+}
+
+// TargetReplicas returns the replicas of the partition once its reassignment completes
+func (p *PartitionImage) TargetReplicas() []int32 {
+	return slices.DeleteFunc(slices.Clone(p.Replicas), func(replica int32) bool { return slices.Contains(p.RemovingReplicas, replica) })
+}
+
+// OriginalReplicas returns the replicas of the partition before its reassignment started
+func (p *PartitionImage) OriginalReplicas() []int32 {
+	return slices.DeleteFunc(slices.Clone(p.Replicas), func(replica int32) bool { return slices.Contains(p.AddingReplicas, replica) })
+}
+
+type TopicImage struct {
+	Name       string
+	Id         string
+	Partitions map[int32]*PartitionImage
+}
+
+// BrokerImage is the registration of a broker. A fenced broker leads no partition and is in no ISR, brokers start
+// fenced and are unfenced once they caught up with the metadata log
+type BrokerImage struct {
+	Id                   int32
+	IncarnationId        string
+	Epoch                int64
+	Endpoints            []BrokerEndpoint
+	Rack                 *string
+	Fenced               bool
+	InControlledShutdown bool
+	LogDirs              []string
+}
+
+// IsActive tells if the broker can lead partitions and join ISRs
+func (b *BrokerImage) IsActive() bool {
+	return !b.Fenced && !b.InControlledShutdown
+}
+
+// Image is the cluster metadata built by applying the records of the metadata log in order. The images published
+// by a Loader are never modified, a new one is published for every change
+type Image struct {
+	// Offset is the end of the last record applied, the image is the state of the log up to it
+	Offset int64
+
+	topics   map[string]*TopicImage
+	topicIds map[string]string
+	configs  map[config.Resource]map[string]string
+	brokers  map[int32]*BrokerImage
+	// The ACL bindings by the id of the record that created them
+	acls map[string]acl.Binding
+	// The SCRAM credentials by user and mechanism code
+	scram map[string]map[int8]sasl.ScramCredential
+	// The client quotas by entity and key
+	quotas map[quota.Entity]map[string]float64
+	// The first producer id that was not allocated yet
+	nextProducerId int64
+}
+
+func NewImage() *Image {
+	return &Image{
+		topics:   make(map[string]*TopicImage),
+		topicIds: make(map[string]string),
+		configs:  make(map[config.Resource]map[string]string),
+		brokers:  make(map[int32]*BrokerImage),
+		acls:     make(map[string]acl.Binding),
+		scram:    make(map[string]map[int8]sasl.ScramCredential),
+		quotas:   make(map[quota.Entity]map[string]float64),
+	}
+}
+
+// Topic returns the topic named name, the returned image must not be modified
+func (i *Image) Topic(name string) (*TopicImage, bool) {
+	topicId, ok := i.topicIds[name]
+	if !ok {
+		return nil, false
+	}
+	return i.topics[topicId], true
+}
+
+// TopicById returns the topic of the given id, the returned image must not be modified
+func (i *Image) TopicById(topicId string) (*TopicImage, bool) {
+	topic, ok := i.topics[topicId]
+	return topic, ok
+}
+
+func (i *Image) TopicNames() []string {
+	return slices.Sorted(maps.Keys(i.topicIds))
+}
+
+// Configs returns the configs set on a resource
+func (i *Image) Configs(resource config.Resource) map[string]string {
+	return maps.Clone(i.configs[resource])
+}
+
+// AllConfigs returns the configs set on every resource
+func (i *Image) AllConfigs() map[config.Resource]map[string]string {
+	configs := make(map[config.Resource]map[string]string, len(i.configs))
+	for resource, resourceConfigs := range i.configs {
+		configs[resource] = maps.Clone(resourceConfigs)
+	}
+	return configs
+}
+
+// Broker returns the registration of a broker, the returned image must not be modified
+func (i *Image) Broker(brokerId int32) (*BrokerImage, bool) {
+	broker, ok := i.brokers[brokerId]
+	return broker, ok
+}
+
+func (i *Image) BrokerIds() []int32 {
+	return slices.Sorted(maps.Keys(i.brokers))
+}
+
+// Acls returns the ACL bindings by the id of the record that created them
+func (i *Image) Acls() map[string]acl.Binding {
+	return maps.Clone(i.acls)
+}
+
+// ScramCredentials returns the SCRAM credentials by user and mechanism code
+func (i *Image) ScramCredentials() map[string]map[int8]sasl.ScramCredential {
+	credentials := make(map[string]map[int8]sasl.ScramCredential, len(i.scram))
+	for user, mechanisms := range i.scram {
+		credentials[user] = maps.Clone(mechanisms)
+	}
+	return credentials
+}
+
+// ClientQuotas returns the client quotas by entity and key
+func (i *Image) ClientQuotas() map[quota.Entity]map[string]float64 {
+	quotas := make(map[quota.Entity]map[string]float64, len(i.quotas))
+	for entity, values := range i.quotas {
+		quotas[entity] = maps.Clone(values)
+	}
+	return quotas
+}
+
+// NextProducerId returns the first producer id that was not allocated to a broker yet
+func (i *Image) NextProducerId() int64 {
+	return i.nextProducerId
+}
+
+// Apply changes the image with a record, the records must be applied in the order of the log
+func (i *Image) Apply(record Record) error {
+	switch record := record.(type) {
+	case *TopicRecord:
+		if _, ok := i.topicIds[record.Name]; ok {
+			return fmt.Errorf("%w: %s", ErrTopicExists, record.Name)
+		}
+		i.topics[record.TopicId] = &TopicImage{Name: record.Name, Id: record.TopicId, Partitions: make(map[int32]*PartitionImage)}
+		i.topicIds[record.Name] = record.TopicId
+
+	case *PartitionRecord:
+		topic, ok := i.topics[record.TopicId]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownTopic, record.TopicId)
+		}
+		topic.Partitions[record.PartitionId] = &PartitionImage{
+			Replicas:         slices.Clone(record.Replicas),
+			Isr:              slices.Clone(record.Isr),
+			Leader:           record.Leader,
+			LeaderEpoch:      record.LeaderEpoch,
+			PartitionEpoch:   record.PartitionEpoch,
+			Elr:              slices.Clone(record.Elr),
+			LastKnownElr:     slices.Clone(record.LastKnownElr),
+			AddingReplicas:   slices.Clone(record.AddingReplicas),
+			RemovingReplicas: slices.Clone(record.RemovingReplicas),
+		}
+
+	case *PartitionChangeRecord:
+		partition, err := i.partition(record.TopicId, record.PartitionId)
+		if err != nil {
+			return err
+		}
+		if record.Isr != nil {
+			partition.Isr = slices.Clone(record.Isr)
+		}
+		if record.Elr != nil {
+			partition.Elr = slices.Clone(record.Elr)
+		}
+		if record.LastKnownElr != nil {
+			partition.LastKnownElr = slices.Clone(record.LastKnownElr)
+		}
+		if record.Replicas != nil {
+			partition.Replicas = slices.Clone(record.Replicas)
+		}
+		if record.AddingReplicas != nil {
+			partition.AddingReplicas = slices.Clone(record.AddingReplicas)
+		}
+		if record.RemovingReplicas != nil {
+			partition.RemovingReplicas = slices.Clone(record.RemovingReplicas)
+		}
+		if record.Leader != NO_LEADER_CHANGE {
+			partition.Leader = record.Leader
+			partition.LeaderEpoch++
+		}
+		partition.PartitionEpoch++
+
+	case *ConfigRecord:
+		resource := config.Resource{Type: record.ResourceType, Name: record.ResourceName}
+		if record.Value == nil {
+			delete(i.configs[resource], record.Name)
+			if len(i.configs[resource]) == 0 {
+				delete(i.configs, resource)
+			}
+			break
+		}
+		if i.configs[resource] == nil {
+			i.configs[resource] = make(map[string]string)
+		}
+		i.configs[resource][record.Name] = *record.Value
+
+	case *RemoveTopicRecord:
+		topic, ok := i.topics[record.TopicId]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownTopic, record.TopicId)
+		}
+		delete(i.topics, record.TopicId)
+		delete(i.topicIds, topic.Name)
+		delete(i.configs, config.Resource{Type: config.TOPIC, Name: topic.Name})
+
+	case *RegisterBrokerRecord:
+		i.brokers[record.BrokerId] = &BrokerImage{
+			Id:                   record.BrokerId,
+			IncarnationId:        record.IncarnationId,
+			Epoch:                record.BrokerEpoch,
+			Endpoints:            slices.Clone(record.Endpoints),
+			Rack:                 record.Rack,
+			Fenced:               record.Fenced,
+			InControlledShutdown: record.InControlledShutdown,
+			LogDirs:              slices.Clone(record.LogDirs),
+		}
+
+	case *UnregisterBrokerRecord:
+		if _, err := i.broker(record.BrokerId, record.BrokerEpoch); err != nil {
+			return err
+		}
+		delete(i.brokers, record.BrokerId)
+
+	case *FenceBrokerRecord:
+		broker, err := i.broker(record.Id, record.Epoch)
+		if err != nil {
+			return err
+		}
+		broker.Fenced = true
+
+	case *UnfenceBrokerRecord:
+		broker, err := i.broker(record.Id, record.Epoch)
+		if err != nil {
+			return err
+		}
+		broker.Fenced = false
+
+	case *BrokerRegistrationChangeRecord:
+		broker, err := i.broker(record.BrokerId, record.BrokerEpoch)
+		if err != nil {
+			return err
+		}
+		broker.InControlledShutdown = record.InControlledShutdown
+
+	case *AccessControlEntryRecord:
+		i.acls[record.Id] = record.Binding()
+
+	case *RemoveAccessControlEntryRecord:
+		if _, ok := i.acls[record.Id]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownAcl, record.Id)
+		}
+		delete(i.acls, record.Id)
+
+	case *UserScramCredentialRecord:
+		if i.scram[record.Name] == nil {
+			i.scram[record.Name] = make(map[int8]sasl.ScramCredential)
+		}
+		i.scram[record.Name][record.Mechanism] = record.Credential()
+
+	case *RemoveUserScramCredentialRecord:
+		if _, ok := i.scram[record.Name][record.Mechanism]; !ok {
+			return fmt.Errorf("%w: %s %d", ErrUnknownScramCredential, record.Name, record.Mechanism)
+		}
+		delete(i.scram[record.Name], record.Mechanism)
+		if len(i.scram[record.Name]) == 0 {
+			delete(i.scram, record.Name)
+		}
+
+	case *ClientQuotaRecord:
+		if record.Remove {
+			delete(i.quotas[record.Entity], record.Key)
+			if len(i.quotas[record.Entity]) == 0 {
+				delete(i.quotas, record.Entity)
+			}
+		} else {
+			if i.quotas[record.Entity] == nil {
+				i.quotas[record.Entity] = make(map[string]float64)
+			}
+			i.quotas[record.Entity][record.Key] = record.Value
+		}
+
+	case *ProducerIdsRecord:
+		i.nextProducerId = record.NextProducerId
+
+	default:
+		return fmt.Errorf("%w: cannot apply record type %d", ErrInvalidRecord, record.Type())
+	}
+
+	return nil
+}
+
+func (i *Image) partition(topicId string, partitionId int32) (*PartitionImage, error) {
+	topic, ok := i.topics[topicId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, topicId)
+	}
+
+	partition, ok := topic.Partitions[partitionId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s-%d", ErrUnknownPartition, topic.Name, partitionId)
+	}
+	return partition, nil
+}
+
+func (i *Image) broker(brokerId int32, brokerEpoch int64) (*BrokerImage, error) {
+	broker, ok := i.brokers[brokerId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownBroker, brokerId)
+	}
+	if broker.Epoch != brokerEpoch {
+		return nil, fmt.Errorf("%w: broker %d has epoch %d, not %d", ErrStaleBrokerEpoch, brokerId, broker.Epoch, brokerEpoch)
+	}
+	return broker, nil
+}
+
+// Clone returns a deep copy of the image, which can be changed without affecting the original
+func (i *Image) Clone() *Image {
+	clone := NewImage()
+	clone.Offset = i.Offset
+	clone.nextProducerId = i.nextProducerId
+
+	for topicId, topic := range i.topics {
+		partitions := make(map[int32]*PartitionImage, len(topic.Partitions))
+		for partitionId, partition := range topic.Partitions {
+			copied := *partition
+			copied.Replicas = slices.Clone(partition.Replicas)
+			copied.Isr = slices.Clone(partition.Isr)
+			copied.Elr = slices.Clone(partition.Elr)
+			copied.LastKnownElr = slices.Clone(partition.LastKnownElr)
+			copied.AddingReplicas = slices.Clone(partition.AddingReplicas)
+			copied.RemovingReplicas = slices.Clone(partition.RemovingReplicas)
+			partitions[partitionId] = &copied
+		}
+		clone.topics[topicId] = &TopicImage{Name: topic.Name, Id: topic.Id, Partitions: partitions}
+	}
+	maps.Copy(clone.topicIds, i.topicIds)
+
+	for resource, configs := range i.configs {
+		clone.configs[resource] = maps.Clone(configs)
+	}
+
+	for brokerId, broker := range i.brokers {
+		copied := *broker
+		copied.Endpoints = slices.Clone(broker.Endpoints)
+		copied.LogDirs = slices.Clone(broker.LogDirs)
+		clone.brokers[brokerId] = &copied
+	}
+
+	maps.Copy(clone.acls, i.acls)
+
+	for user, mechanisms := range i.scram {
+		clone.scram[user] = maps.Clone(mechanisms)
+	}
+
+	for entity, values := range i.quotas {
+		clone.quotas[entity] = maps.Clone(values)
+	}
+
+	return clone
+}
+
+// Records returns the records that build the image from scratch, in a stable order, which is what a snapshot contains
+func (i *Image) Records() []Record {
+	records := []Record{}
+
+	for _, brokerId := range i.BrokerIds() {
+		broker := i.brokers[brokerId]
+		records = append(records, &RegisterBrokerRecord{
+			BrokerId:             broker.Id,
+			IncarnationId:        broker.IncarnationId,
+			BrokerEpoch:          broker.Epoch,
+			Endpoints:            slices.Clone(broker.Endpoints),
+			Rack:                 broker.Rack,
+			Fenced:               broker.Fenced,
+			InControlledShutdown: broker.InControlledShutdown,
+			LogDirs:              slices.Clone(broker.LogDirs),
+		})
+	}
+
+	for _, name := range i.TopicNames() {
+		topic := i.topics[i.topicIds[name]]
+		records = append(records, &TopicRecord{Name: topic.Name, TopicId: topic.Id})
+
+		for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+			partition := topic.Partitions[partitionId]
+			records = append(records, &PartitionRecord{
+				PartitionId:      partitionId,
+				TopicId:          topic.Id,
+				Replicas:         slices.Clone(partition.Replicas),
+				Isr:              slices.Clone(partition.Isr),
+				Leader:           partition.Leader,
+				LeaderEpoch:      partition.LeaderEpoch,
+				PartitionEpoch:   partition.PartitionEpoch,
+				Elr:              slices.Clone(partition.Elr),
+				LastKnownElr:     slices.Clone(partition.LastKnownElr),
+				AddingReplicas:   slices.Clone(partition.AddingReplicas),
+				RemovingReplicas: slices.Clone(partition.RemovingReplicas),
+			})
+		}
+	}
+
+	resources := slices.SortedFunc(maps.Keys(i.configs), func(a config.Resource, b config.Resource) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Name, b.Name))
+	})
+	for _, resource := range resources {
+		for _, name := range slices.Sorted(maps.Keys(i.configs[resource])) {
+			value := i.configs[resource][name]
+			records = append(records, &ConfigRecord{ResourceType: resource.Type, ResourceName: resource.Name, Name: name, Value: &value})
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(i.acls)) {
+		records = append(records, NewAccessControlEntryRecord(id, i.acls[id]))
+	}
+
+	for _, user := range slices.Sorted(maps.Keys(i.scram)) {
+		for _, mechanism := range slices.Sorted(maps.Keys(i.scram[user])) {
+			records = append(records, NewUserScramCredentialRecord(user, mechanism, i.scram[user][mechanism]))
+		}
+	}
+
+	entities := slices.SortedFunc(maps.Keys(i.quotas), func(a quota.Entity, b quota.Entity) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	for _, entity := range entities {
+		for _, key := range slices.Sorted(maps.Keys(i.quotas[entity])) {
+			records = append(records, &ClientQuotaRecord{Entity: entity, Key: key, Value: i.quotas[entity][key]})
+		}
+	}
+
+	if i.nextProducerId > 0 {
+		records = append(records, &ProducerIdsRecord{BrokerId: -1, BrokerEpoch: -1, NextProducerId: i.nextProducerId})
+	}
+
+	return records
+}
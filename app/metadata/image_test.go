@@ -0,0 +1,250 @@
+package metadata
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+func TestImageApply(t *testing.T) {
+	topicId := "550e8400-e29b-41d4-a716-446655440000"
+	retention := "1000"
+
+	image := NewImage()
+	records := []Record{
+		&TopicRecord{Name: "foo", TopicId: topicId},
+		&PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2, 3}, Isr: []int32{1, 2, 3}, Leader: 1},
+		&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{2, 3}, Leader: 2},
+		&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{1, 2, 3}, Leader: NO_LEADER_CHANGE},
+		&ConfigRecord{ResourceType: config.TOPIC, ResourceName: "foo", Name: "retention.ms", Value: &retention},
+	}
+	for _, record := range records {
+		if err := image.Apply(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topic, ok := image.Topic("foo")
+	if !ok {
+		t.Fatalf("expected topic foo")
+	}
+
+	want := PartitionImage{Replicas: []int32{1, 2, 3}, Isr: []int32{1, 2, 3}, Leader: 2, LeaderEpoch: 1, PartitionEpoch: 2}
+	if got := *topic.Partitions[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("partition mismatch: got %+v, want %+v", got, want)
+	}
+
+	if got := image.Configs(config.Resource{Type: config.TOPIC, Name: "foo"}); !reflect.DeepEqual(got, map[string]string{"retention.ms": "1000"}) {
+		t.Errorf("configs mismatch: got %v", got)
+	}
+
+	// The ELR changes only when the record sets it
+	for _, record := range []Record{
+		&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{}, Leader: NO_LEADER, Elr: []int32{1, 2, 3}},
+		&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: nil, Leader: NO_LEADER_CHANGE, Elr: []int32{1, 2}, LastKnownElr: []int32{3}},
+		&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{2}, Leader: 2, Elr: nil, LastKnownElr: []int32{}},
+	} {
+		if err := image.Apply(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want = PartitionImage{Replicas: []int32{1, 2, 3}, Isr: []int32{2}, Leader: 2, LeaderEpoch: 3, PartitionEpoch: 5, Elr: []int32{1, 2}, LastKnownElr: []int32{}}
+	if got := *topic.Partitions[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("partition mismatch: got %+v, want %+v", got, want)
+	}
+
+	// A reassignment moves the partition from the original replicas to the target ones
+	if err := image.Apply(&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Leader: NO_LEADER_CHANGE, Replicas: []int32{4, 2, 1, 3}, AddingReplicas: []int32{4}, RemovingReplicas: []int32{1, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	partition := topic.Partitions[0]
+	if !partition.IsReassigning() || !reflect.DeepEqual(partition.TargetReplicas(), []int32{4, 2}) || !reflect.DeepEqual(partition.OriginalReplicas(), []int32{2, 1, 3}) {
+		t.Errorf("expected a reassignment from [2 1 3] to [4 2], got %+v", partition)
+	}
+	if err := image.Apply(&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Leader: NO_LEADER_CHANGE, Replicas: []int32{4, 2}, AddingReplicas: []int32{}, RemovingReplicas: []int32{}}); err != nil {
+		t.Fatal(err)
+	}
+	if partition.IsReassigning() || !reflect.DeepEqual(partition.Replicas, []int32{4, 2}) {
+		t.Errorf("expected the reassignment to complete with replicas [4 2], got %+v", partition)
+	}
+
+	if err := image.Apply(&TopicRecord{Name: "foo", TopicId: "00000000-0000-0000-0000-000000000001"}); !errors.Is(err, ErrTopicExists) {
+		t.Errorf("expected ErrTopicExists, got %v", err)
+	}
+	if err := image.Apply(&PartitionChangeRecord{PartitionId: 5, TopicId: topicId, Leader: 1}); !errors.Is(err, ErrUnknownPartition) {
+		t.Errorf("expected ErrUnknownPartition, got %v", err)
+	}
+
+	if err := image.Apply(&RemoveTopicRecord{TopicId: topicId}); err != nil {
+		t.Fatal(err)
+	}
+	if len(image.TopicNames()) != 0 || len(image.Configs(config.Resource{Type: config.TOPIC, Name: "foo"})) != 0 {
+		t.Errorf("expected the topic and its configs to be removed")
+	}
+}
+
+func TestImageCloneIsIndependent(t *testing.T) {
+	topicId := "550e8400-e29b-41d4-a716-446655440000"
+	image := NewImage()
+	image.Apply(&TopicRecord{Name: "foo", TopicId: topicId})
+	image.Apply(&PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1}, Isr: []int32{1}, Leader: 1})
+
+	clone := image.Clone()
+	clone.Apply(&PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{}, Leader: NO_LEADER})
+
+	topic, _ := image.Topic("foo")
+	if topic.Partitions[0].Leader != 1 || len(topic.Partitions[0].Isr) != 1 {
+		t.Errorf("changing the clone changed the original: %+v", topic.Partitions[0])
+	}
+}
+
+func TestImageApplyBrokerRecords(t *testing.T) {
+	incarnationId := "550e8400-e29b-41d4-a716-446655440000"
+	image := NewImage()
+
+	records := []Record{
+		&RegisterBrokerRecord{BrokerId: 1, IncarnationId: incarnationId, BrokerEpoch: 10, Fenced: true},
+		&RegisterBrokerRecord{BrokerId: 2, IncarnationId: incarnationId, BrokerEpoch: 11, Fenced: true},
+		&UnfenceBrokerRecord{Id: 1, Epoch: 10},
+		&BrokerRegistrationChangeRecord{BrokerId: 2, BrokerEpoch: 11, InControlledShutdown: true},
+	}
+	for _, record := range records {
+		if err := image.Apply(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if broker, _ := image.Broker(1); broker.Fenced || !broker.IsActive() {
+		t.Errorf("expected broker 1 to be active, got %+v", broker)
+	}
+	if broker, _ := image.Broker(2); !broker.InControlledShutdown || broker.IsActive() {
+		t.Errorf("expected broker 2 to be in controlled shutdown, got %+v", broker)
+	}
+
+	if err := image.Apply(&FenceBrokerRecord{Id: 1, Epoch: 9}); !errors.Is(err, ErrStaleBrokerEpoch) {
+		t.Errorf("expected ErrStaleBrokerEpoch, got %v", err)
+	}
+	if err := image.Apply(&FenceBrokerRecord{Id: 3, Epoch: 9}); !errors.Is(err, ErrUnknownBroker) {
+		t.Errorf("expected ErrUnknownBroker, got %v", err)
+	}
+
+	if err := image.Apply(&UnregisterBrokerRecord{BrokerId: 2, BrokerEpoch: 11}); err != nil {
+		t.Fatal(err)
+	}
+	if got := image.BrokerIds(); !reflect.DeepEqual(got, []int32{1}) {
+		t.Errorf("expected brokers [1], got %v", got)
+	}
+}
+
+func TestImageApplyAclRecords(t *testing.T) {
+	aclId := "550e8400-e29b-41d4-a716-446655440000"
+	binding := acl.Binding{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:alice", Host: "*", Operation: acl.WRITE, PermissionType: acl.ALLOW}
+	image := NewImage()
+
+	if err := image.Apply(NewAccessControlEntryRecord(aclId, binding)); err != nil {
+		t.Fatal(err)
+	}
+	if got := image.Acls(); !reflect.DeepEqual(got, map[string]acl.Binding{aclId: binding}) {
+		t.Errorf("expected the binding %s, got %v", aclId, got)
+	}
+
+	if err := image.Apply(&RemoveAccessControlEntryRecord{Id: aclId}); err != nil {
+		t.Fatal(err)
+	}
+	if got := image.Acls(); len(got) != 0 {
+		t.Errorf("expected no bindings, got %v", got)
+	}
+
+	if err := image.Apply(&RemoveAccessControlEntryRecord{Id: aclId}); !errors.Is(err, ErrUnknownAcl) {
+		t.Errorf("expected ErrUnknownAcl, got %v", err)
+	}
+}
+
+func TestImageApplyUserScramCredentialRecords(t *testing.T) {
+	credential := sasl.ScramCredential{Salt: []byte("salt"), StoredKey: []byte("stored"), ServerKey: []byte("server"), Iterations: 4096}
+	image := NewImage()
+
+	if err := image.Apply(NewUserScramCredentialRecord("alice", 1, credential)); err != nil {
+		t.Fatal(err)
+	}
+	if got := image.ScramCredentials(); !reflect.DeepEqual(got, map[string]map[int8]sasl.ScramCredential{"alice": {1: credential}}) {
+		t.Errorf("expected the credential of alice, got %v", got)
+	}
+
+	if err := image.Apply(&RemoveUserScramCredentialRecord{Name: "alice", Mechanism: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := image.ScramCredentials(); len(got) != 0 {
+		t.Errorf("expected no credentials, got %v", got)
+	}
+
+	if err := image.Apply(&RemoveUserScramCredentialRecord{Name: "alice", Mechanism: 1}); !errors.Is(err, ErrUnknownScramCredential) {
+		t.Errorf("expected ErrUnknownScramCredential, got %v", err)
+	}
+}
+
+func TestImageApplyClientQuotaRecords(t *testing.T) {
+	entity := quota.Entity{User: quota.Specific("alice"), ClientId: quota.Default}
+	image := NewImage()
+
+	image.Apply(&ClientQuotaRecord{Entity: entity, Key: quota.PRODUCER_BYTE_RATE, Value: 1024})
+	image.Apply(&ClientQuotaRecord{Entity: entity, Key: quota.CONSUMER_BYTE_RATE, Value: 2048})
+	want := map[quota.Entity]map[string]float64{entity: {quota.PRODUCER_BYTE_RATE: 1024, quota.CONSUMER_BYTE_RATE: 2048}}
+	if got := image.ClientQuotas(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the quotas %v, got %v", want, got)
+	}
+
+	// An entity left without quotas is removed
+	image.Apply(&ClientQuotaRecord{Entity: entity, Key: quota.PRODUCER_BYTE_RATE, Remove: true})
+	image.Apply(&ClientQuotaRecord{Entity: entity, Key: quota.CONSUMER_BYTE_RATE, Remove: true})
+	if got := image.ClientQuotas(); len(got) != 0 {
+		t.Errorf("expected no quotas, got %v", got)
+	}
+}
+
+func TestSnapshotRebuildsImage(t *testing.T) {
+	topicId := "550e8400-e29b-41d4-a716-446655440000"
+	compression := "zstd"
+
+	image := NewImage()
+	image.Apply(&TopicRecord{Name: "foo", TopicId: topicId})
+	image.Apply(&PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1, 2}, Leader: 1})
+	image.Apply(&PartitionRecord{PartitionId: 1, TopicId: topicId, Replicas: []int32{2, 1}, Isr: []int32{2}, Leader: 2, LeaderEpoch: 3, PartitionEpoch: 5})
+	image.Apply(&PartitionRecord{PartitionId: 2, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{}, Leader: NO_LEADER, Elr: []int32{1}, LastKnownElr: []int32{2}})
+	image.Apply(&PartitionRecord{PartitionId: 3, TopicId: topicId, Replicas: []int32{3, 1}, Isr: []int32{1}, Leader: 1, AddingReplicas: []int32{3}, RemovingReplicas: []int32{}})
+	image.Apply(&ConfigRecord{ResourceType: config.BROKER, ResourceName: "", Name: "compression.type", Value: &compression})
+	image.Apply(&RegisterBrokerRecord{BrokerId: 1, IncarnationId: topicId, BrokerEpoch: 3, Endpoints: []BrokerEndpoint{{Name: "PLAINTEXT", Host: "localhost", Port: 9092}}, LogDirs: []string{}})
+	image.Apply(&RegisterBrokerRecord{BrokerId: 2, IncarnationId: topicId, BrokerEpoch: 4, Endpoints: []BrokerEndpoint{}, Fenced: true, InControlledShutdown: true, LogDirs: []string{}})
+	image.Apply(NewAccessControlEntryRecord(topicId, acl.Binding{ResourceType: acl.GROUP, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.DENY}))
+	image.Apply(NewUserScramCredentialRecord("alice", 2, sasl.ScramCredential{Salt: []byte("salt"), StoredKey: []byte("stored"), ServerKey: []byte("server"), Iterations: 8192}))
+	image.Apply(&ClientQuotaRecord{Entity: quota.Entity{User: quota.Default}, Key: quota.CONSUMER_BYTE_RATE, Value: 4096})
+	image.Apply(&ClientQuotaRecord{Entity: quota.Entity{ClientId: quota.Specific("foo")}, Key: quota.REQUEST_PERCENTAGE, Value: 12.5})
+	image.Apply(&ProducerIdsRecord{BrokerId: 1, BrokerEpoch: 3, NextProducerId: 3000})
+
+	data, err := EncodeSnapshot(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeSnapshot(data, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Offset != 42 {
+		t.Errorf("expected the image at offset 42, got %d", got.Offset)
+	}
+	if !reflect.DeepEqual(got.Records(), image.Records()) {
+		t.Errorf("records mismatch:\ngot  %+v\nwant %+v", got.Records(), image.Records())
+	}
+
+	if _, err := DecodeSnapshot(data[:len(data)-3], 42); err == nil {
+		t.Errorf("expected an error for a truncated snapshot")
+	}
+}
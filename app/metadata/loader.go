@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+// Loader builds the metadata image of a node from the committed records of the metadata quorum, it is the raft
+// listener of brokers. Every batch of records publishes a new image
+type Loader struct {
+	logger *slog.Logger
+
+	mutex       sync.Mutex
+	image       *Image
+	subscribers []func(image *Image)
+
+	published atomic.Pointer[Image]
+	leader    atomic.Pointer[raft.LeaderAndEpoch]
+}
+
+func NewLoader(logger *slog.Logger) *Loader {
+	loader := &Loader{logger: logger, image: NewImage()}
+	loader.published.Store(loader.image.Clone())
+	loader.leader.Store(&raft.LeaderAndEpoch{LeaderId: raft.NO_LEADER, Epoch: 0})
+	return loader
+}
+
+// Image returns the latest published image
+func (l *Loader) Image() *Image {
+	return l.published.Load()
+}
+
+// Leader returns the leader of the metadata quorum, the active controller
+func (l *Loader) Leader() raft.LeaderAndEpoch {
+	return *l.leader.Load()
+}
+
+// Subscribe calls publish with the latest image and then with every new image, in order
+func (l *Loader) Subscribe(publish func(image *Image)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	publish(l.published.Load())
+	l.subscribers = append(l.subscribers, publish)
+}
+
+func (l *Loader) HandleCommit(entries []raft.Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, entry := range entries {
+		ApplyEntry(l.image, entry, l.logger)
+	}
+	l.publish()
+}
+
+func (l *Loader) HandleLoadSnapshot(snapshot raft.Snapshot) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	image, err := DecodeSnapshot(snapshot.Data, snapshot.Id.EndOffset)
+	if err != nil {
+		l.logger.Error("Failed to load the metadata snapshot", "end_offset", snapshot.Id.EndOffset, "error", err)
+		return
+	}
+
+	l.image = image
+	l.publish()
+}
+
+func (l *Loader) HandleLeaderChange(leader raft.LeaderAndEpoch) {
+	l.leader.Store(&leader)
+}
+
+func (l *Loader) publish() {
+	image := l.image.Clone()
+	l.published.Store(image)
+
+	for _, subscriber := range l.subscribers {
+		subscriber(image)
+	}
+}
+
+// ApplyEntry applies the record of a committed entry to image. A record that cannot be applied is skipped and
+// logged, like a metadata fault of Kafka, so that a single bad record does not stop the node
+func ApplyEntry(image *Image, entry raft.Entry, logger *slog.Logger) {
+	record, err := DecodeRecord(entry.Data)
+	if err == nil {
+		err = image.Apply(record)
+	}
+	if err != nil {
+		logger.Error("Failed to apply a metadata record", "offset", entry.Offset, "error", err)
+	}
+
+	image.Offset = entry.Offset + 1
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// ProducerIdsRecord allocates the producer ids below NextProducerId, the last block going to a broker. The producer
+// ids of the cluster are never reused
+type ProducerIdsRecord struct {
+	BrokerId       int32
+	BrokerEpoch    int64
+	NextProducerId int64
+}
+
+func (r *ProducerIdsRecord) Type() RecordType {
+	return PRODUCER_IDS_RECORD
+}
+
+func (r *ProducerIdsRecord) size() int {
+	return 4 + 8 + 8
+}
+
+func (r *ProducerIdsRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeInt32(buffer, index, r.BrokerId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.BrokerEpoch)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeInt64(buffer, index, r.NextProducerId)
+}
+
+func parseProducerIdsRecord(buffer []byte, index int) (Record, int, error) {
+	record := &ProducerIdsRecord{}
+	var err error
+
+	record.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.BrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.NextProducerId, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// ClientQuotaRecord sets a quota of a client quota entity, or removes it. Like Kafka the entity is written as its
+// components, a default component has a null name
+type ClientQuotaRecord struct {
+	Entity quota.Entity
+	Key    string
+	Value  float64
+	Remove bool
+}
+
+func (r *ClientQuotaRecord) Type() RecordType { return CLIENT_QUOTA_RECORD }
+
+// entityComponent is a component of the entity of a ClientQuotaRecord
+type entityComponent struct {
+	entityType string
+	name       quota.Name
+}
+
+func (r *ClientQuotaRecord) components() []entityComponent {
+	components := []entityComponent{}
+	if r.Entity.User.Kind != quota.ABSENT {
+		components = append(components, entityComponent{entityType: quota.USER, name: r.Entity.User})
+	}
+	if r.Entity.ClientId.Kind != quota.ABSENT {
+		components = append(components, entityComponent{entityType: quota.CLIENT_ID, name: r.Entity.ClientId})
+	}
+	return components
+}
+
+func (r *ClientQuotaRecord) size() int {
+	size := 10
+	for _, component := range r.components() {
+		size += 10 + len(component.entityType) + 10 + len(component.name.Value)
+	}
+	return size + 10 + len(r.Key) + 8 + 1
+}
+
+func (r *ClientQuotaRecord) serialize(buffer []byte, index int) (int, error) {
+	components := r.components()
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(components)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, component := range components {
+		index, err = serializer.SerializeCompactString(buffer, index, component.entityType)
+		if err != nil {
+			return index, err
+		}
+
+		var name *string
+		if component.name.Kind == quota.SPECIFIC {
+			name = &component.name.Value
+		}
+		index, err = serializer.SerializeCompactNullableString(buffer, index, name)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.Key)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeFloat64(buffer, index, r.Value)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeBoolean(buffer, index, r.Remove)
+}
+
+func parseClientQuotaRecord(buffer []byte, index int) (Record, int, error) {
+	record := &ClientQuotaRecord{}
+
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	for i := 0; i < length; i++ {
+		var entityType string
+		entityType, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		var entityName *string
+		entityName, index, err = parser.ExtractCompactNullableString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		name := quota.Default
+		if entityName != nil {
+			name = quota.Specific(*entityName)
+		}
+		switch entityType {
+		case quota.USER:
+			record.Entity.User = name
+		case quota.CLIENT_ID:
+			record.Entity.ClientId = name
+		default:
+			return nil, index, fmt.Errorf("unknown quota entity type %s", entityType)
+		}
+	}
+
+	record.Key, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Value, index, err = parser.ExtractFloat64(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Remove, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
@@ -0,0 +1,590 @@
+package metadata
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+var ErrInvalidRecord = errors.New("invalid metadata record")
+
+// RecordType identifies a metadata record, using the api keys of Kafka's metadata records
+type RecordType uint64
+
+const (
+	REGISTER_BROKER_RECORD              RecordType = 0
+	UNREGISTER_BROKER_RECORD            RecordType = 1
+	TOPIC_RECORD                        RecordType = 2
+	PARTITION_RECORD                    RecordType = 3
+	CONFIG_RECORD                       RecordType = 4
+	PARTITION_CHANGE_RECORD             RecordType = 5
+	FENCE_BROKER_RECORD                 RecordType = 7
+	UNFENCE_BROKER_RECORD               RecordType = 8
+	REMOVE_TOPIC_RECORD                 RecordType = 9
+	USER_SCRAM_CREDENTIAL_RECORD        RecordType = 11
+	CLIENT_QUOTA_RECORD                 RecordType = 14
+	PRODUCER_IDS_RECORD                 RecordType = 15
+	BROKER_REGISTRATION_CHANGE_RECORD   RecordType = 17
+	ACCESS_CONTROL_ENTRY_RECORD         RecordType = 18
+	REMOVE_ACCESS_CONTROL_ENTRY_RECORD  RecordType = 19
+	REMOVE_USER_SCRAM_CREDENTIAL_RECORD RecordType = 22
+)
+
+// Records are framed like Kafka's: frame version, record type and record version, then the fields
+const (
+	recordFrameVersion = 1
+	recordVersion      = 0
+)
+
+// NO_LEADER_CHANGE is the leader of a PartitionChangeRecord that keeps the current leader
+const NO_LEADER_CHANGE int32 = -2
+
+// NO_LEADER is the leader of a partition without one
+const NO_LEADER int32 = -1
+
+// Record is a change to the cluster metadata, replicated through the metadata log
+type Record interface {
+	Type() RecordType
+	size() int
+	serialize(buffer []byte, index int) (int, error)
+}
+
+type TopicRecord struct {
+	Name    string
+	TopicId string
+}
+
+type PartitionRecord struct {
+	PartitionId    int32
+	TopicId        string
+	Replicas       []int32
+	Isr            []int32
+	Leader         int32
+	LeaderEpoch    int32
+	PartitionEpoch int32
+	// The eligible leader replicas (ELR) and the last known ELR, see PartitionImage
+	Elr          []int32
+	LastKnownElr []int32
+	// The replicas that a reassignment adds and removes, see PartitionImage
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+}
+
+// PartitionChangeRecord changes the replicas, the ISR, the ELR and the leader of a partition. Nil replica lists and
+// NO_LEADER_CHANGE keep the current values, an empty list clears them
+type PartitionChangeRecord struct {
+	PartitionId      int32
+	TopicId          string
+	Isr              []int32
+	Leader           int32
+	Elr              []int32
+	LastKnownElr     []int32
+	Replicas         []int32
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+}
+
+// ConfigRecord sets a config of a resource, a nil value deletes it
+type ConfigRecord struct {
+	ResourceType config.ResourceType
+	ResourceName string
+	Name         string
+	Value        *string
+}
+
+type RemoveTopicRecord struct {
+	TopicId string
+}
+
+func (r *TopicRecord) Type() RecordType           { return TOPIC_RECORD }
+func (r *PartitionRecord) Type() RecordType       { return PARTITION_RECORD }
+func (r *PartitionChangeRecord) Type() RecordType { return PARTITION_CHANGE_RECORD }
+func (r *ConfigRecord) Type() RecordType          { return CONFIG_RECORD }
+func (r *RemoveTopicRecord) Type() RecordType     { return REMOVE_TOPIC_RECORD }
+
+// NewTopicId returns a random version 4 uuid
+func NewTopicId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	value := hex.EncodeToString(id)
+	return value[0:8] + "-" + value[8:12] + "-" + value[12:16] + "-" + value[16:20] + "-" + value[20:32]
+}
+
+// EncodeRecord serializes a record for the metadata log
+func EncodeRecord(record Record) ([]byte, error) {
+	buffer := make([]byte, 3*10+record.size()+10)
+	index := 0
+	var err error
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, recordFrameVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(record.Type()))
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, recordVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = record.serialize(buffer, index)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer[:index], nil
+}
+
+// DecodeRecord parses a record of the metadata log
+func DecodeRecord(data []byte) (Record, error) {
+	frameVersion, index, err := parser.ExtractUnsignedVarInt(data, 0)
+	if err != nil || frameVersion != recordFrameVersion {
+		return nil, fmt.Errorf("%w: unsupported frame version", ErrInvalidRecord)
+	}
+
+	recordType, index, err := parser.ExtractUnsignedVarInt(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing record type", ErrInvalidRecord)
+	}
+
+	version, index, err := parser.ExtractUnsignedVarInt(data, index)
+	if err != nil || version != recordVersion {
+		return nil, fmt.Errorf("%w: unsupported version of record type %d", ErrInvalidRecord, recordType)
+	}
+
+	var record Record
+	switch RecordType(recordType) {
+	case TOPIC_RECORD:
+		record, index, err = parseTopicRecord(data, index)
+	case PARTITION_RECORD:
+		record, index, err = parsePartitionRecord(data, index)
+	case PARTITION_CHANGE_RECORD:
+		record, index, err = parsePartitionChangeRecord(data, index)
+	case CONFIG_RECORD:
+		record, index, err = parseConfigRecord(data, index)
+	case REMOVE_TOPIC_RECORD:
+		record, index, err = parseRemoveTopicRecord(data, index)
+	case REGISTER_BROKER_RECORD:
+		record, index, err = parseRegisterBrokerRecord(data, index)
+	case UNREGISTER_BROKER_RECORD:
+		record, index, err = parseUnregisterBrokerRecord(data, index)
+	case FENCE_BROKER_RECORD:
+		record, index, err = parseFenceBrokerRecord(data, index)
+	case UNFENCE_BROKER_RECORD:
+		record, index, err = parseUnfenceBrokerRecord(data, index)
+	case BROKER_REGISTRATION_CHANGE_RECORD:
+		record, index, err = parseBrokerRegistrationChangeRecord(data, index)
+	case ACCESS_CONTROL_ENTRY_RECORD:
+		record, index, err = parseAccessControlEntryRecord(data, index)
+	case REMOVE_ACCESS_CONTROL_ENTRY_RECORD:
+		record, index, err = parseRemoveAccessControlEntryRecord(data, index)
+	case USER_SCRAM_CREDENTIAL_RECORD:
+		record, index, err = parseUserScramCredentialRecord(data, index)
+	case REMOVE_USER_SCRAM_CREDENTIAL_RECORD:
+		record, index, err = parseRemoveUserScramCredentialRecord(data, index)
+	case CLIENT_QUOTA_RECORD:
+		record, index, err = parseClientQuotaRecord(data, index)
+	case PRODUCER_IDS_RECORD:
+		record, index, err = parseProducerIdsRecord(data, index)
+	default:
+		return nil, fmt.Errorf("%w: unknown record type %d", ErrInvalidRecord, recordType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: record type %d: %w", ErrInvalidRecord, recordType, err)
+	}
+
+	if _, _, err := parser.ExtractTagFields(data, index); err != nil {
+		return nil, fmt.Errorf("%w: record type %d: %w", ErrInvalidRecord, recordType, err)
+	}
+
+	return record, nil
+}
+
+func (r *TopicRecord) size() int {
+	return 10 + len(r.Name) + 16
+}
+
+func (r *TopicRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeCompactString(buffer, index, r.Name)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeUUID(buffer, index, r.TopicId)
+}
+
+func parseTopicRecord(buffer []byte, index int) (Record, int, error) {
+	record := &TopicRecord{}
+	var err error
+
+	record.Name, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.TopicId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+func (r *PartitionRecord) size() int {
+	return 4 + 16 + 4*(len(r.Replicas)+len(r.Isr)+len(r.Elr)+len(r.LastKnownElr)+len(r.AddingReplicas)+len(r.RemovingReplicas)) + 60 + 12
+}
+
+func (r *PartitionRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeInt32(buffer, index, r.PartitionId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeUUID(buffer, index, r.TopicId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.Replicas)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.Isr)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.Leader)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.LeaderEpoch)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.PartitionEpoch)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.Elr)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.LastKnownElr)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.AddingReplicas)
+	if err != nil {
+		return index, err
+	}
+
+	return serializeInt32Array(buffer, index, r.RemovingReplicas)
+}
+
+func parsePartitionRecord(buffer []byte, index int) (Record, int, error) {
+	record := &PartitionRecord{}
+	var err error
+
+	record.PartitionId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.TopicId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Replicas, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Isr, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Leader, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.PartitionEpoch, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Elr, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.LastKnownElr, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.AddingReplicas, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.RemovingReplicas, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+func (r *PartitionChangeRecord) size() int {
+	return 4 + 16 + 4*(len(r.Isr)+len(r.Elr)+len(r.LastKnownElr)+len(r.Replicas)+len(r.AddingReplicas)+len(r.RemovingReplicas)) + 60 + 4
+}
+
+func (r *PartitionChangeRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeInt32(buffer, index, r.PartitionId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeUUID(buffer, index, r.TopicId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.Isr)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.Leader)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.Elr)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.LastKnownElr)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.Replicas)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializeInt32Array(buffer, index, r.AddingReplicas)
+	if err != nil {
+		return index, err
+	}
+
+	return serializeInt32Array(buffer, index, r.RemovingReplicas)
+}
+
+func parsePartitionChangeRecord(buffer []byte, index int) (Record, int, error) {
+	record := &PartitionChangeRecord{}
+	var err error
+
+	record.PartitionId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.TopicId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Isr, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Leader, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Elr, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.LastKnownElr, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Replicas, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.AddingReplicas, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.RemovingReplicas, index, err = extractInt32Array(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+func (r *ConfigRecord) size() int {
+	valueLength := 0
+	if r.Value != nil {
+		valueLength = len(*r.Value)
+	}
+	return 1 + 30 + len(r.ResourceName) + len(r.Name) + valueLength
+}
+
+func (r *ConfigRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeInt8(buffer, index, int8(r.ResourceType))
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.ResourceName)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.Name)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeCompactNullableString(buffer, index, r.Value)
+}
+
+func parseConfigRecord(buffer []byte, index int) (Record, int, error) {
+	record := &ConfigRecord{}
+
+	resourceType, index, err := parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	record.ResourceType = config.ResourceType(resourceType)
+
+	record.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Name, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Value, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+func (r *RemoveTopicRecord) size() int {
+	return 16
+}
+
+func (r *RemoveTopicRecord) serialize(buffer []byte, index int) (int, error) {
+	return serializer.SerializeUUID(buffer, index, r.TopicId)
+}
+
+func parseRemoveTopicRecord(buffer []byte, index int) (Record, int, error) {
+	record := &RemoveTopicRecord{}
+	var err error
+
+	record.TopicId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+// serializeInt32Array writes a compact array, nil is the null array
+func serializeInt32Array(buffer []byte, index int, values []int32) (int, error) {
+	if values == nil {
+		return serializer.SerializeUnsignedVarInt(buffer, index, 0)
+	}
+
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(values)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, value := range values {
+		index, err = serializer.SerializeInt32(buffer, index, value)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	return index, nil
+}
+
+func extractInt32Array(buffer []byte, index int) ([]int32, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	if length < 0 {
+		return nil, index, nil
+	}
+
+	if 4*length > len(buffer)-index {
+		return nil, index, fmt.Errorf("failed to extract int32 array - buffer too small")
+	}
+
+	values := make([]int32, 0, length)
+	for range length {
+		var value int32
+		value, index, err = parser.ExtractInt32(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		values = append(values, value)
+	}
+
+	return values, index, nil
+}
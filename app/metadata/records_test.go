@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+)
+
+func TestRecordsRoundTrip(t *testing.T) {
+	value := "compact"
+	topicId := "550e8400-e29b-41d4-a716-446655440000"
+
+	tests := []struct {
+		name   string
+		record Record
+	}{
+		{"Topic", &TopicRecord{Name: "foo", TopicId: topicId}},
+		{"Partition", &PartitionRecord{PartitionId: 1, TopicId: topicId, Replicas: []int32{1, 2, 3}, Isr: []int32{1, 2}, Leader: 1, LeaderEpoch: 4, PartitionEpoch: 7}},
+		{"Partition change", &PartitionChangeRecord{PartitionId: 1, TopicId: topicId, Isr: []int32{2}, Leader: 2}},
+		{"Partition change keeping the ISR", &PartitionChangeRecord{PartitionId: 1, TopicId: topicId, Isr: nil, Leader: NO_LEADER_CHANGE}},
+		{"Partition with an ELR", &PartitionRecord{PartitionId: 1, TopicId: topicId, Replicas: []int32{1, 2, 3}, Isr: []int32{}, Leader: NO_LEADER, Elr: []int32{1, 2}, LastKnownElr: []int32{3}}},
+		{"Partition change of the ELR", &PartitionChangeRecord{PartitionId: 1, TopicId: topicId, Isr: []int32{}, Leader: NO_LEADER, Elr: []int32{2}, LastKnownElr: []int32{}}},
+		{"Partition being reassigned", &PartitionRecord{PartitionId: 1, TopicId: topicId, Replicas: []int32{3, 1, 2}, Isr: []int32{1, 2}, Leader: 1, AddingReplicas: []int32{3}, RemovingReplicas: []int32{2}}},
+		{"Partition change of the replicas", &PartitionChangeRecord{PartitionId: 1, TopicId: topicId, Leader: NO_LEADER_CHANGE, Replicas: []int32{3, 1}, AddingReplicas: []int32{}, RemovingReplicas: []int32{}}},
+		{"Config", &ConfigRecord{ResourceType: config.TOPIC, ResourceName: "foo", Name: "cleanup.policy", Value: &value}},
+		{"Config deletion", &ConfigRecord{ResourceType: config.BROKER, ResourceName: "", Name: "log.retention.ms", Value: nil}},
+		{"Remove topic", &RemoveTopicRecord{TopicId: topicId}},
+		{"Register broker", &RegisterBrokerRecord{
+			BrokerId:      1,
+			IncarnationId: topicId,
+			BrokerEpoch:   42,
+			Endpoints:     []BrokerEndpoint{{Name: "PLAINTEXT", Host: "localhost", Port: 9092, SecurityProtocol: 0}},
+			Rack:          &value,
+			Fenced:        true,
+			LogDirs:       []string{"00000000-0000-0000-0000-000000000001"},
+		}},
+		{"Register broker without rack", &RegisterBrokerRecord{BrokerId: 2, IncarnationId: topicId, BrokerEpoch: 7, Endpoints: []BrokerEndpoint{}, LogDirs: []string{}}},
+		{"Unregister broker", &UnregisterBrokerRecord{BrokerId: 1, BrokerEpoch: 42}},
+		{"Fence broker", &FenceBrokerRecord{Id: 1, Epoch: 42}},
+		{"Unfence broker", &UnfenceBrokerRecord{Id: 1, Epoch: 42}},
+		{"Broker registration change", &BrokerRegistrationChangeRecord{BrokerId: 1, BrokerEpoch: 42, InControlledShutdown: true}},
+		{"Access control entry", &AccessControlEntryRecord{Id: topicId, ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.PREFIXED, Principal: "User:alice", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW}},
+		{"Remove access control entry", &RemoveAccessControlEntryRecord{Id: topicId}},
+		{"User SCRAM credential", &UserScramCredentialRecord{Name: "alice", Mechanism: 2, Salt: []byte("salt"), StoredKey: []byte("stored"), ServerKey: []byte("server"), Iterations: 4096}},
+		{"Remove user SCRAM credential", &RemoveUserScramCredentialRecord{Name: "alice", Mechanism: 2}},
+		{"Client quota", &ClientQuotaRecord{Entity: quota.Entity{User: quota.Specific("alice"), ClientId: quota.Default}, Key: quota.PRODUCER_BYTE_RATE, Value: 1024}},
+		{"Remove client quota", &ClientQuotaRecord{Entity: quota.Entity{ClientId: quota.Specific("foo")}, Key: quota.REQUEST_PERCENTAGE, Remove: true}},
+		{"Producer ids", &ProducerIdsRecord{BrokerId: 1, BrokerEpoch: 12, NextProducerId: 2000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeRecord(tt.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := DecodeRecord(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.record) {
+				t.Errorf("record mismatch: got %+v, want %+v", got, tt.record)
+			}
+		})
+	}
+}
+
+func TestEncodeTopicRecord(t *testing.T) {
+	data, err := EncodeRecord(&TopicRecord{Name: "foo", TopicId: "00000000-0000-0000-0000-000000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{
+		0x01,                // Frame version: 1
+		0x02,                // Record type: 2 (TopicRecord)
+		0x00,                // Record version: 0
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // TopicId
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, // Tagged fields
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Errorf("record mismatch:\ngot  %v\nwant %v", data, expected)
+	}
+}
+
+func TestDecodeInvalidRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", []byte{}},
+		{"Unknown frame version", []byte{0x02, 0x02, 0x00}},
+		{"Unknown record type", []byte{0x01, 0x7F, 0x00, 0x00}},
+		{"Truncated", []byte{0x01, 0x02, 0x00, 0x04, 'f', 'o'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeRecord(tt.data); !errors.Is(err, ErrInvalidRecord) {
+				t.Errorf("expected ErrInvalidRecord, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package metadata
+
+import (
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// UserScramCredentialRecord creates or replaces the SCRAM credential of a user for a mechanism, which is identified by
+// its code in the user SCRAM credentials APIs
+type UserScramCredentialRecord struct {
+	Name       string
+	Mechanism  int8
+	Salt       []byte
+	StoredKey  []byte
+	ServerKey  []byte
+	Iterations int32
+}
+
+type RemoveUserScramCredentialRecord struct {
+	Name      string
+	Mechanism int8
+}
+
+func (r *UserScramCredentialRecord) Type() RecordType {
+	return USER_SCRAM_CREDENTIAL_RECORD
+}
+
+func (r *RemoveUserScramCredentialRecord) Type() RecordType {
+	return REMOVE_USER_SCRAM_CREDENTIAL_RECORD
+}
+
+// NewUserScramCredentialRecord returns the record setting the credential of a user for a mechanism
+func NewUserScramCredentialRecord(name string, mechanism int8, credential sasl.ScramCredential) *UserScramCredentialRecord {
+	return &UserScramCredentialRecord{
+		Name:       name,
+		Mechanism:  mechanism,
+		Salt:       credential.Salt,
+		StoredKey:  credential.StoredKey,
+		ServerKey:  credential.ServerKey,
+		Iterations: int32(credential.Iterations),
+	}
+}
+
+// Credential returns the credential the record sets
+func (r *UserScramCredentialRecord) Credential() sasl.ScramCredential {
+	return sasl.ScramCredential{
+		Salt:       r.Salt,
+		StoredKey:  r.StoredKey,
+		ServerKey:  r.ServerKey,
+		Iterations: int(r.Iterations),
+	}
+}
+
+func (r *UserScramCredentialRecord) size() int {
+	return 10 + len(r.Name) + 1 + 10 + len(r.Salt) + 10 + len(r.StoredKey) + 10 + len(r.ServerKey) + 4
+}
+
+func (r *UserScramCredentialRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeCompactString(buffer, index, r.Name)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt8(buffer, index, r.Mechanism)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactBytes(buffer, index, r.Salt)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactBytes(buffer, index, r.StoredKey)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeCompactBytes(buffer, index, r.ServerKey)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeInt32(buffer, index, r.Iterations)
+}
+
+func parseUserScramCredentialRecord(buffer []byte, index int) (Record, int, error) {
+	record := &UserScramCredentialRecord{}
+	var err error
+
+	record.Name, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Mechanism, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Salt, index, err = parser.ExtractCompactBytes(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.StoredKey, index, err = parser.ExtractCompactBytes(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.ServerKey, index, err = parser.ExtractCompactBytes(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Iterations, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
+
+func (r *RemoveUserScramCredentialRecord) size() int {
+	return 10 + len(r.Name) + 1
+}
+
+func (r *RemoveUserScramCredentialRecord) serialize(buffer []byte, index int) (int, error) {
+	index, err := serializer.SerializeCompactString(buffer, index, r.Name)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeInt8(buffer, index, r.Mechanism)
+}
+
+func parseRemoveUserScramCredentialRecord(buffer []byte, index int) (Record, int, error) {
+	record := &RemoveUserScramCredentialRecord{}
+	var err error
+
+	record.Name, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	record.Mechanism, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	return record, index, nil
+}
@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// EncodeSnapshot serializes the records of an image for a snapshot of the metadata log: their count,
+// then each record prefixed by its length
+func EncodeSnapshot(image *Image) ([]byte, error) {
+	records := image.Records()
+
+	encoded := make([][]byte, 0, len(records))
+	bufferSize := 10
+	for _, record := range records {
+		data, err := EncodeRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, data)
+		bufferSize += 10 + len(data)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializer.SerializeUnsignedVarInt(buffer, 0, uint64(len(encoded)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, data := range encoded {
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		index += copy(buffer[index:], data)
+	}
+
+	return buffer[:index], nil
+}
+
+// DecodeSnapshot rebuilds the image a snapshot was taken of, up to endOffset
+func DecodeSnapshot(data []byte, endOffset int64) (*Image, error) {
+	count, index, err := parser.ExtractUnsignedVarInt(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing snapshot record count", ErrInvalidRecord)
+	}
+
+	image := NewImage()
+	for i := range count {
+		length, newIndex, err := parser.ExtractUnsignedVarInt(data, index)
+		if err != nil || length > uint64(len(data)-newIndex) {
+			return nil, fmt.Errorf("%w: snapshot record %d is truncated", ErrInvalidRecord, i)
+		}
+		index = newIndex
+
+		record, err := DecodeRecord(data[index : index+int(length)])
+		if err != nil {
+			return nil, err
+		}
+		index += int(length)
+
+		if err := image.Apply(record); err != nil {
+			return nil, err
+		}
+	}
+
+	image.Offset = endOffset
+	return image, nil
+}
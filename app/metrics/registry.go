@@ -0,0 +1,300 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default latency buckets in seconds, from 1ms to 10s
+var DEFAULT_BUCKETS = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics of the broker and writes them in the Prometheus text exposition format
+type Registry struct {
+	mutex    sync.Mutex
+	families []family
+}
+
+type family interface {
+	write(builder *strings.Builder)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.families = append(r.families, f)
+}
+
+// NewCounter registers a counter, it must be given one value per label name when it is incremented
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Counter {
+	counter := &Counter{
+		header: header{name: name, help: help, kind: "counter", labelNames: labelNames},
+		series: make(map[string]*counterSeries),
+	}
+	r.register(counter)
+	return counter
+}
+
+// NewGaugeFunc registers a gauge whose value is read from value each time the metrics are written
+func (r *Registry) NewGaugeFunc(name string, help string, value func() float64) {
+	r.register(&gaugeFunc{header: header{name: name, help: help, kind: "gauge"}, value: value})
+}
+
+// Sample is the value of one series of a gauge read by NewGaugeVecFunc, with one value per label name
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// NewGaugeVecFunc registers a gauge whose series are read from samples each time the metrics are written, such as
+// the size of the log of every partition
+func (r *Registry) NewGaugeVecFunc(name string, help string, samples func() []Sample, labelNames ...string) {
+	r.register(&gaugeVecFunc{header: header{name: name, help: help, kind: "gauge", labelNames: labelNames}, samples: samples})
+}
+
+// NewHistogram registers a histogram with the given upper bounds, a +Inf bucket is always added
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	histogram := &Histogram{
+		header:  header{name: name, help: help, kind: "histogram", labelNames: labelNames},
+		buckets: slices.Sorted(slices.Values(buckets)),
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(histogram)
+	return histogram
+}
+
+// WriteTo writes every metric in the order they were registered
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	families := slices.Clone(r.families)
+	r.mutex.Unlock()
+
+	builder := &strings.Builder{}
+	for _, f := range families {
+		f.write(builder)
+	}
+
+	written, err := io.WriteString(w, builder.String())
+	return int64(written), err
+}
+
+// ServeHTTP serves the /metrics endpoint
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+type header struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+}
+
+func (h *header) writeHeader(builder *strings.Builder) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(h.help)
+	fmt.Fprintf(builder, "# HELP %s %s\n# TYPE %s %s\n", h.name, help, h.name, h.kind)
+}
+
+// seriesKey identifies the series of a set of label values
+func (h *header) seriesKey(labelValues []string) string {
+	if len(labelValues) != len(h.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", h.name, len(h.labelNames), len(labelValues)))
+	}
+
+	return strings.Join(labelValues, "\xff")
+}
+
+// writeSample writes one line, extra is an additional label such as the le of histogram buckets
+func (h *header) writeSample(builder *strings.Builder, suffix string, labelValues []string, extraName string, extraValue string, value float64) {
+	builder.WriteString(h.name)
+	builder.WriteString(suffix)
+
+	labels := make([]string, 0, len(labelValues)+1)
+	for i, labelValue := range labelValues {
+		labels = append(labels, h.labelNames[i]+`="`+escapeLabelValue(labelValue)+`"`)
+	}
+	if extraName != "" {
+		labels = append(labels, extraName+`="`+extraValue+`"`)
+	}
+	if len(labels) > 0 {
+		builder.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+
+	builder.WriteString(" " + formatValue(value) + "\n")
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// Counter is a value that only increases, such as the number of requests
+type Counter struct {
+	header
+
+	mutex  sync.Mutex
+	series map[string]*counterSeries
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	key := c.seriesKey(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.series[key]
+	if s == nil {
+		s = &counterSeries{labelValues: slices.Clone(labelValues)}
+		c.series[key] = s
+	}
+	s.value += value
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the value of a series, zero if it was never incremented
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.seriesKey(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if s := c.series[key]; s != nil {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(builder *strings.Builder) {
+	c.writeHeader(builder)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range slices.Sorted(maps.Keys(c.series)) {
+		s := c.series[key]
+		c.writeSample(builder, "", s.labelValues, "", "", s.value)
+	}
+}
+
+type gaugeFunc struct {
+	header
+	value func() float64
+}
+
+func (g *gaugeFunc) write(builder *strings.Builder) {
+	g.writeHeader(builder)
+	g.writeSample(builder, "", nil, "", "", g.value())
+}
+
+type gaugeVecFunc struct {
+	header
+	samples func() []Sample
+}
+
+func (g *gaugeVecFunc) write(builder *strings.Builder) {
+	g.writeHeader(builder)
+
+	series := map[string]Sample{}
+	for _, sample := range g.samples() {
+		series[g.seriesKey(sample.LabelValues)] = sample
+	}
+	for _, key := range slices.Sorted(maps.Keys(series)) {
+		g.writeSample(builder, "", series[key].LabelValues, "", "", series[key].Value)
+	}
+}
+
+type histogramSeries struct {
+	labelValues []string
+	// Observations per bucket, the last one is +Inf. They are made cumulative when written
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram counts observations, such as request latencies, in buckets
+type Histogram struct {
+	header
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*histogramSeries
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.seriesKey(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := h.series[key]
+	if s == nil {
+		s = &histogramSeries{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+
+	bucket, _ := slices.BinarySearch(h.buckets, value)
+	s.counts[bucket]++
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) write(builder *strings.Builder) {
+	h.writeHeader(builder)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+
+		cumulative := uint64(0)
+		for i, count := range s.counts {
+			cumulative += count
+			upperBound := math.Inf(1)
+			if i < len(h.buckets) {
+				upperBound = h.buckets[i]
+			}
+			h.writeSample(builder, "_bucket", s.labelValues, "le", formatValue(upperBound), float64(cumulative))
+		}
+
+		h.writeSample(builder, "_sum", s.labelValues, "", "", s.sum)
+		h.writeSample(builder, "_count", s.labelValues, "", "", float64(s.count))
+	}
+}
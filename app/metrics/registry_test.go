@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWritesPrometheusText(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.NewCounter("kafka_requests_total", "Requests received.", "request", "version")
+	requests.Inc("Metadata", "12")
+	requests.Inc("ApiVersions", "4")
+	requests.Add(2, "ApiVersions", "4")
+
+	registry.NewGaugeFunc("kafka_connections", "Open connections.", func() float64 { return 3 })
+	registry.NewGaugeVecFunc("kafka_log_size_bytes", "Log sizes.", func() []Sample {
+		return []Sample{{LabelValues: []string{"foo", "1"}, Value: 10}, {LabelValues: []string{"bar", "0"}, Value: 0}}
+	}, "topic", "partition")
+
+	latency := registry.NewHistogram("kafka_request_seconds", "Request latency.", []float64{0.5, 0.1}, "request")
+	latency.Observe(0.05, "Metadata")
+	latency.Observe(0.1, "Metadata")
+	latency.Observe(2, "Metadata")
+
+	builder := &strings.Builder{}
+	if _, err := registry.WriteTo(builder); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP kafka_requests_total Requests received.
+# TYPE kafka_requests_total counter
+kafka_requests_total{request="ApiVersions",version="4"} 3
+kafka_requests_total{request="Metadata",version="12"} 1
+# HELP kafka_connections Open connections.
+# TYPE kafka_connections gauge
+kafka_connections 3
+# HELP kafka_log_size_bytes Log sizes.
+# TYPE kafka_log_size_bytes gauge
+kafka_log_size_bytes{topic="bar",partition="0"} 0
+kafka_log_size_bytes{topic="foo",partition="1"} 10
+# HELP kafka_request_seconds Request latency.
+# TYPE kafka_request_seconds histogram
+kafka_request_seconds_bucket{request="Metadata",le="0.1"} 2
+kafka_request_seconds_bucket{request="Metadata",le="0.5"} 2
+kafka_request_seconds_bucket{request="Metadata",le="+Inf"} 3
+kafka_request_seconds_sum{request="Metadata"} 2.15
+kafka_request_seconds_count{request="Metadata"} 3
+`
+	if builder.String() != expected {
+		t.Errorf("output mismatch:\ngot\n%s\nwant\n%s", builder.String(), expected)
+	}
+}
+
+func TestRegistryEscapesLabelValues(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("clients_total", "Clients by\nid.", "client_id")
+	counter.Inc("a\"b\\c\nd")
+
+	builder := &strings.Builder{}
+	registry.WriteTo(builder)
+
+	expected := `# HELP clients_total Clients by\nid.
+# TYPE clients_total counter
+clients_total{client_id="a\"b\\c\nd"} 1
+`
+	if builder.String() != expected {
+		t.Errorf("output mismatch:\ngot\n%s\nwant\n%s", builder.String(), expected)
+	}
+}
+
+func TestCounterPanicsOnWrongLabelCount(t *testing.T) {
+	counter := NewRegistry().NewCounter("requests_total", "Requests.", "request")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	counter.Inc()
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewGaugeFunc("up", "Whether the broker is up.", func() float64 { return 1 })
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", recorder.Header().Get("Content-Type"))
+	}
+	if !strings.HasSuffix(recorder.Body.String(), "up 1\n") {
+		t.Errorf("unexpected body %q", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", recorder.Code)
+	}
+}
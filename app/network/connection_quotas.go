@@ -0,0 +1,91 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrTooManyConnections = errors.New("too many connections")
+
+// ConnectionQuotas counts open connections against max.connections and the per-ip limits.
+// Listeners wait for a free slot before accepting, while connections over their ip's limit are refused
+type ConnectionQuotas struct {
+	maxConnections int
+	maxPerIp       int
+	overrides      map[string]int
+
+	mutex  sync.Mutex
+	freed  *sync.Cond
+	total  int
+	perIp  map[string]int
+	closed bool
+}
+
+func NewConnectionQuotas(maxConnections int, maxPerIp int, overrides map[string]int) *ConnectionQuotas {
+	quotas := &ConnectionQuotas{
+		maxConnections: maxConnections,
+		maxPerIp:       maxPerIp,
+		overrides:      overrides,
+		perIp:          make(map[string]int),
+	}
+	quotas.freed = sync.NewCond(&quotas.mutex)
+
+	return quotas
+}
+
+// WaitForSlot blocks while max.connections connections are open. It returns false once the quotas are closed
+func (q *ConnectionQuotas) WaitForSlot() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for !q.closed && q.total >= q.maxConnections {
+		q.freed.Wait()
+	}
+
+	return !q.closed
+}
+
+// Acquire counts a new connection from ip. Release must be called once it is closed
+func (q *ConnectionQuotas) Acquire(ip string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.total >= q.maxConnections {
+		return fmt.Errorf("%w: the broker already has %d connections", ErrTooManyConnections, q.total)
+	}
+
+	limit, ok := q.overrides[ip]
+	if !ok {
+		limit = q.maxPerIp
+	}
+	if q.perIp[ip] >= limit {
+		return fmt.Errorf("%w: %s already has %d connections", ErrTooManyConnections, ip, q.perIp[ip])
+	}
+
+	q.total++
+	q.perIp[ip]++
+	return nil
+}
+
+func (q *ConnectionQuotas) Release(ip string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.total--
+	q.perIp[ip]--
+	if q.perIp[ip] <= 0 {
+		delete(q.perIp, ip)
+	}
+
+	q.freed.Broadcast()
+}
+
+// Close wakes up the listeners waiting for a slot
+func (q *ConnectionQuotas) Close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.closed = true
+	q.freed.Broadcast()
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectionQuotasPerIp(t *testing.T) {
+	quotas := NewConnectionQuotas(100, 2, map[string]int{"10.0.0.1": 1, "10.0.0.2": 3})
+
+	tests := []struct {
+		ip      string
+		wantErr error
+	}{
+		{ip: "127.0.0.1"},
+		{ip: "127.0.0.1"},
+		{ip: "127.0.0.1", wantErr: ErrTooManyConnections},
+		{ip: "10.0.0.1"},
+		{ip: "10.0.0.1", wantErr: ErrTooManyConnections},
+		{ip: "10.0.0.2"},
+		{ip: "10.0.0.2"},
+		{ip: "10.0.0.2"},
+		{ip: "10.0.0.2", wantErr: ErrTooManyConnections},
+	}
+
+	for i, tt := range tests {
+		err := quotas.Acquire(tt.ip)
+		if tt.wantErr == nil && err != nil {
+			t.Errorf("connection %d from %s: unexpected error: %v", i, tt.ip, err)
+		}
+		if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("connection %d from %s: expected %v, got %v", i, tt.ip, tt.wantErr, err)
+		}
+	}
+
+	// Closing a connection frees a slot for its ip
+	quotas.Release("127.0.0.1")
+	if err := quotas.Acquire("127.0.0.1"); err != nil {
+		t.Errorf("unexpected error after a release: %v", err)
+	}
+}
+
+func TestConnectionQuotasWaitForSlot(t *testing.T) {
+	quotas := NewConnectionQuotas(1, 10, map[string]int{})
+
+	if !quotas.WaitForSlot() {
+		t.Fatal("expected a free slot")
+	}
+	if err := quotas.Acquire("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := quotas.Acquire("127.0.0.2"); !errors.Is(err, ErrTooManyConnections) {
+		t.Errorf("expected ErrTooManyConnections over max.connections, got %v", err)
+	}
+
+	slot := make(chan bool)
+	go func() { slot <- quotas.WaitForSlot() }()
+
+	select {
+	case <-slot:
+		t.Fatal("listener must wait while max.connections is reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	quotas.Release("127.0.0.1")
+	if !<-slot {
+		t.Errorf("expected a free slot after a release")
+	}
+
+	// Closing wakes up the waiting listeners
+	if err := quotas.Acquire("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	go func() { slot <- quotas.WaitForSlot() }()
+	quotas.Close()
+	if <-slot {
+		t.Errorf("expected no slot once closed")
+	}
+}
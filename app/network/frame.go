@@ -0,0 +1,32 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidFrameSize is returned for requests that are larger than socket.request.max.bytes or have a negative size
+var ErrInvalidFrameSize = errors.New("invalid request size")
+
+// ReadFrame reads one size-prefixed request. The returned frame starts with the 4-byte size, as the request parser expects
+func ReadFrame(reader io.Reader, maxSize int32) ([]byte, error) {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(reader, size); err != nil {
+		return nil, err
+	}
+
+	length := int32(binary.BigEndian.Uint32(size))
+	if length < 0 || length > maxSize {
+		return nil, fmt.Errorf("%w: %d, the maximum is %d", ErrInvalidFrameSize, length, maxSize)
+	}
+
+	frame := make([]byte, 4+int(length))
+	copy(frame, size)
+	if _, err := io.ReadFull(reader, frame[4:]); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
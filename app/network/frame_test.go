@@ -0,0 +1,94 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     []byte
+		maxSize   int32
+		wantFrame []byte
+		wantErr   error
+	}{
+		{
+			name:      "Complete frame",
+			input:     []byte{0x00, 0x00, 0x00, 0x02, 0xAA, 0xBB, 0xCC},
+			maxSize:   100,
+			wantFrame: []byte{0x00, 0x00, 0x00, 0x02, 0xAA, 0xBB},
+		},
+		{
+			name:      "Empty frame",
+			input:     []byte{0x00, 0x00, 0x00, 0x00},
+			maxSize:   100,
+			wantFrame: []byte{0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:    "Truncated body",
+			input:   []byte{0x00, 0x00, 0x00, 0x04, 0xAA},
+			maxSize: 100,
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "Closed before the size",
+			input:   []byte{},
+			maxSize: 100,
+			wantErr: io.EOF,
+		},
+		{
+			name:    "Larger than the maximum",
+			input:   []byte{0x00, 0x00, 0x01, 0x00},
+			maxSize: 100,
+			wantErr: ErrInvalidFrameSize,
+		},
+		{
+			name:    "Negative size",
+			input:   []byte{0xFF, 0xFF, 0xFF, 0xFF},
+			maxSize: 100,
+			wantErr: ErrInvalidFrameSize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := ReadFrame(bytes.NewReader(tt.input), tt.maxSize)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(frame, tt.wantFrame) {
+				t.Errorf("frame mismatch: got %v, want %v", frame, tt.wantFrame)
+			}
+		})
+	}
+}
+
+func TestReadFrameSequence(t *testing.T) {
+	reader := bytes.NewReader([]byte{
+		0x00, 0x00, 0x00, 0x01, 0x01, // first request
+		0x00, 0x00, 0x00, 0x02, 0x02, 0x02, // second request
+	})
+
+	for _, want := range [][]byte{{0x00, 0x00, 0x00, 0x01, 0x01}, {0x00, 0x00, 0x00, 0x02, 0x02, 0x02}} {
+		frame, err := ReadFrame(reader, 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(frame, want) {
+			t.Errorf("frame mismatch: got %v, want %v", frame, want)
+		}
+	}
+}
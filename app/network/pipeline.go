@@ -0,0 +1,141 @@
+package network
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of a request: the response to write, if any, and whether the connection must be closed afterwards
+type Result struct {
+	Response []byte
+	Close    bool
+	// Throttle mutes the connection once the response is written, for clients over their quota
+	Throttle time.Duration
+}
+
+// Pipeline processes the requests of a connection concurrently on a worker pool and writes their responses
+// in the order the requests were received, as clients expect. Submit must be called from a single goroutine
+type Pipeline struct {
+	pool   *WorkerPool
+	writer io.WriteCloser
+	// Responses waiting to be written, in request order. The capacity bounds the requests in flight
+	pending chan chan Result
+	// Requests that change the session, such as SASL ones, run alone: they wait for the requests before them
+	// and the requests after them wait for them
+	session sync.RWMutex
+	stopped chan struct{}
+	err     error
+
+	mutex      sync.Mutex
+	mutedUntil time.Time
+}
+
+// NewPipeline starts the writer of a connection, the writer is closed once a result asks for it or a write fails
+func NewPipeline(pool *WorkerPool, writer io.WriteCloser, maxInFlight int) *Pipeline {
+	pipeline := &Pipeline{
+		pool:    pool,
+		writer:  writer,
+		pending: make(chan chan Result, maxInFlight),
+		stopped: make(chan struct{}),
+	}
+
+	go pipeline.write()
+
+	return pipeline
+}
+
+// Submit queues a request, blocking while too many requests of the connection are in flight
+func (p *Pipeline) Submit(process func() Result, exclusive bool) {
+	p.SubmitAsync(func(done func(Result)) { done(process()) }, exclusive)
+}
+
+// SubmitAsync queues a request whose result may come after process returned, such as a response parked in a
+// purgatory. process calls done from any goroutine, only its first result counts. The worker is free again once
+// process returned, the responses after a parked one wait for it to be written
+func (p *Pipeline) SubmitAsync(process func(done func(Result)), exclusive bool) {
+	result := make(chan Result, 1)
+	p.pending <- result
+
+	if exclusive {
+		p.session.Lock()
+	} else {
+		p.session.RLock()
+	}
+
+	var once sync.Once
+	done := func(processed Result) {
+		once.Do(func() { result <- processed })
+	}
+
+	p.pool.Submit(func() {
+		defer func() {
+			if exclusive {
+				p.session.Unlock()
+			} else {
+				p.session.RUnlock()
+			}
+		}()
+
+		process(done)
+	})
+}
+
+// WaitWhileMuted blocks while the connection is throttled, so that no more requests are read from it.
+// Throttling never exceeds one quota window, so this does not hold up a shutdown for long
+func (p *Pipeline) WaitWhileMuted() {
+	for {
+		p.mutex.Lock()
+		remaining := time.Until(p.mutedUntil)
+		p.mutex.Unlock()
+
+		if remaining <= 0 {
+			return
+		}
+
+		time.Sleep(remaining)
+	}
+}
+
+// Close waits for the responses of the submitted requests to be written. Nothing may be submitted afterwards
+func (p *Pipeline) Close() {
+	close(p.pending)
+	<-p.stopped
+}
+
+// Err is the write error that stopped the pipeline, if any. It is only meaningful after Close
+func (p *Pipeline) Err() error {
+	return p.err
+}
+
+func (p *Pipeline) write() {
+	defer close(p.stopped)
+	closed := false
+
+	for pending := range p.pending {
+		result := <-pending
+
+		// Once closed the remaining results are only drained so that Submit never blocks forever
+		if closed {
+			continue
+		}
+
+		if result.Response != nil {
+			if _, err := p.writer.Write(result.Response); err != nil {
+				p.err = err
+				result.Close = true
+			}
+		}
+
+		if result.Throttle > 0 {
+			p.mutex.Lock()
+			p.mutedUntil = time.Now().Add(result.Throttle)
+			p.mutex.Unlock()
+		}
+
+		if result.Close {
+			closed = true
+			p.writer.Close()
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingWriter collects the responses written by a pipeline
+type recordingWriter struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	closed bool
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.buffer.Write(p)
+}
+
+func (w *recordingWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.closed = true
+	return nil
+}
+
+func TestPipelineWritesResponsesInRequestOrder(t *testing.T) {
+	pool := NewWorkerPool(4, 10)
+	defer pool.Close()
+
+	writer := &recordingWriter{}
+	pipeline := NewPipeline(pool, writer, 10)
+
+	// Earlier requests take longer, so they complete last
+	for i := range 4 {
+		pipeline.Submit(func() Result {
+			time.Sleep(time.Duration(4-i) * 10 * time.Millisecond)
+			return Result{Response: []byte{byte(i)}}
+		}, false)
+	}
+	pipeline.Close()
+
+	if got := writer.buffer.Bytes(); !bytes.Equal(got, []byte{0, 1, 2, 3}) {
+		t.Errorf("responses out of order: got %v", got)
+	}
+
+	if err := pipeline.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPipelineProcessesRequestsConcurrently(t *testing.T) {
+	pool := NewWorkerPool(2, 10)
+	defer pool.Close()
+
+	pipeline := NewPipeline(pool, &recordingWriter{}, 10)
+
+	// A parked request must not keep the next one from being processed
+	parked := make(chan struct{})
+	pipeline.Submit(func() Result {
+		<-parked
+		return Result{}
+	}, false)
+
+	processed := make(chan struct{})
+	pipeline.Submit(func() Result {
+		close(processed)
+		return Result{}
+	}, false)
+
+	select {
+	case <-processed:
+	case <-time.After(time.Second):
+		t.Fatal("second request was not processed while the first one was parked")
+	}
+
+	close(parked)
+	pipeline.Close()
+}
+
+func TestPipelineRunsExclusiveRequestsAlone(t *testing.T) {
+	pool := NewWorkerPool(4, 10)
+	defer pool.Close()
+
+	pipeline := NewPipeline(pool, &recordingWriter{}, 10)
+
+	var running atomic.Int32
+	var overlapped atomic.Bool
+	process := func() Result {
+		running.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return Result{}
+	}
+	exclusive := func() Result {
+		if running.Add(1) != 1 {
+			overlapped.Store(true)
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return Result{}
+	}
+
+	pipeline.Submit(process, false)
+	pipeline.Submit(process, false)
+	pipeline.Submit(exclusive, true)
+	pipeline.Submit(process, false)
+	pipeline.Close()
+
+	if overlapped.Load() {
+		t.Errorf("exclusive request ran concurrently with another request")
+	}
+}
+
+func TestPipelineStopsWritingOnClose(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	defer pool.Close()
+
+	writer := &recordingWriter{}
+	pipeline := NewPipeline(pool, writer, 10)
+
+	pipeline.Submit(func() Result { return Result{Response: []byte{1}} }, false)
+	pipeline.Submit(func() Result { return Result{Close: true} }, false)
+	pipeline.Submit(func() Result { return Result{Response: []byte{3}} }, false)
+	pipeline.Close()
+
+	if got := writer.buffer.Bytes(); !bytes.Equal(got, []byte{1}) {
+		t.Errorf("expected only the responses before the close, got %v", got)
+	}
+
+	if !writer.closed {
+		t.Errorf("expected the writer to be closed")
+	}
+}
+
+func TestPipelineWritesAsyncResultsInRequestOrder(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	defer pool.Close()
+
+	writer := &recordingWriter{}
+	pipeline := NewPipeline(pool, writer, 10)
+
+	// The first result comes from another goroutine once the second request was processed, on the only worker
+	parked := make(chan func(Result), 1)
+	pipeline.SubmitAsync(func(done func(Result)) { parked <- done }, false)
+	processed := make(chan struct{})
+	pipeline.Submit(func() Result {
+		close(processed)
+		return Result{Response: []byte{2}}
+	}, false)
+
+	select {
+	case <-processed:
+	case <-time.After(time.Second):
+		t.Fatal("the worker was not freed by the parked request")
+	}
+
+	done := <-parked
+	done(Result{Response: []byte{1}})
+	// Only the first result of a request counts
+	done(Result{Response: []byte{9}})
+	pipeline.Close()
+
+	if got := writer.buffer.Bytes(); !bytes.Equal(got, []byte{1, 2}) {
+		t.Errorf("responses out of order: got %v", got)
+	}
+}
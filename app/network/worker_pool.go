@@ -0,0 +1,42 @@
+package network
+
+import "sync"
+
+// WorkerPool runs the requests of every connection on a fixed number of goroutines (num.io.threads),
+// taking them from a bounded queue (queued.max.requests)
+type WorkerPool struct {
+	tasks   chan func()
+	workers sync.WaitGroup
+}
+
+func NewWorkerPool(workers int, queueSize int) *WorkerPool {
+	pool := &WorkerPool{tasks: make(chan func(), queueSize)}
+
+	for range workers {
+		pool.workers.Add(1)
+		go func() {
+			defer pool.workers.Done()
+			for task := range pool.tasks {
+				task()
+			}
+		}()
+	}
+
+	return pool
+}
+
+// Submit queues a task, blocking while the queue is full so that connections stop reading requests the broker cannot keep up with
+func (p *WorkerPool) Submit(task func()) {
+	p.tasks <- task
+}
+
+// Queued is the number of tasks waiting for a worker
+func (p *WorkerPool) Queued() int {
+	return len(p.tasks)
+}
+
+// Close waits for the queued tasks to complete. Nothing may be submitted afterwards
+func (p *WorkerPool) Close() {
+	close(p.tasks)
+	p.workers.Wait()
+}
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"math"
 )
 
 func ExtractInt8(buffer []byte, index int) (int8, int, error) {
@@ -14,6 +16,15 @@ func ExtractInt8(buffer []byte, index int) (int8, int, error) {
 	return value, index + 1, nil
 }
 
+func ExtractBoolean(buffer []byte, index int) (bool, int, error) {
+	if index+1 > len(buffer) {
+		return false, index, fmt.Errorf("failed to extract boolean - buffer too small")
+	}
+
+	value := buffer[index] != 0
+	return value, index + 1, nil
+}
+
 func ExtractInt16(buffer []byte, index int) (int16, int, error) {
 	if index+2 > len(buffer) {
 		return 0, index, fmt.Errorf("failed to extract int16 - buffer too small")
@@ -32,6 +43,24 @@ func ExtractInt32(buffer []byte, index int) (int32, int, error) {
 	return value, index + 4, nil
 }
 
+func ExtractInt64(buffer []byte, index int) (int64, int, error) {
+	if index+8 > len(buffer) {
+		return 0, index, fmt.Errorf("failed to extract int64 - buffer too small")
+	}
+
+	value := int64(binary.BigEndian.Uint64(buffer[index : index+8]))
+	return value, index + 8, nil
+}
+
+func ExtractFloat64(buffer []byte, index int) (float64, int, error) {
+	if index+8 > len(buffer) {
+		return 0, index, fmt.Errorf("failed to extract float64 - buffer too small")
+	}
+
+	value := math.Float64frombits(binary.BigEndian.Uint64(buffer[index : index+8]))
+	return value, index + 8, nil
+}
+
 func ExtractNullableString(buffer []byte, index int) (string, int, error) {
 	length, index, err := ExtractInt16(buffer, index)
 	if err != nil {
@@ -42,6 +71,10 @@ func ExtractNullableString(buffer []byte, index int) (string, int, error) {
 		return "", index, nil
 	}
 
+	if length < 0 {
+		return "", index, fmt.Errorf("invalid nullable string length %d", length)
+	}
+
 	if index+int(length) > len(buffer) {
 		return "", index, fmt.Errorf("failed to extract nullable string - buffer too small")
 	}
@@ -51,6 +84,10 @@ func ExtractNullableString(buffer []byte, index int) (string, int, error) {
 }
 
 func ExtractUnsignedVarInt(buffer []byte, index int) (uint64, int, error) {
+	if index > len(buffer) {
+		return 0, index, fmt.Errorf("failed to extract unsigned varint - buffer too small")
+	}
+
 	value, bytesRead := binary.Uvarint(buffer[index:])
 
 	if bytesRead == 0 {
@@ -64,6 +101,25 @@ func ExtractUnsignedVarInt(buffer []byte, index int) (uint64, int, error) {
 	return value, index + bytesRead, nil
 }
 
+// ExtractCompactArrayLength reads the N+1 length of a compact array, returning -1 for a null array. Every element takes
+// at least one byte, so a length beyond the end of the buffer is rejected before anything is allocated for it
+func ExtractCompactArrayLength(buffer []byte, index int) (int, int, error) {
+	length, index, err := ExtractUnsignedVarInt(buffer, index)
+	if err != nil {
+		return 0, index, err
+	}
+
+	if length == 0 {
+		return -1, index, nil
+	}
+
+	if length-1 > uint64(len(buffer)-index) {
+		return 0, index, fmt.Errorf("failed to extract compact array - %d elements do not fit in %d bytes", length-1, len(buffer)-index)
+	}
+
+	return int(length - 1), index, nil
+}
+
 func ExtractCompactString(buffer []byte, index int) (string, int, error) {
 	length, index, err := ExtractUnsignedVarInt(buffer, index)
 	if err != nil {
@@ -80,10 +136,62 @@ func ExtractCompactString(buffer []byte, index int) (string, int, error) {
 
 	numberOfBytesToRead := length - 1 // Unsigned varint represents the length N + 1 bytes for the following string
 
-	if index+int(numberOfBytesToRead) > len(buffer) {
+	if numberOfBytesToRead > uint64(len(buffer)-index) {
 		return "", index, fmt.Errorf("failed to extract compact string - buffer too small")
 	}
 
 	value := string(buffer[index : index+int(numberOfBytesToRead)])
 	return value, index + int(numberOfBytesToRead), nil
 }
+
+func ExtractCompactNullableString(buffer []byte, index int) (*string, int, error) {
+	length, index, err := ExtractUnsignedVarInt(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	// A length of 0 represents a null string
+	if length == 0 {
+		return nil, index, nil
+	}
+
+	numberOfBytesToRead := length - 1
+
+	if numberOfBytesToRead > uint64(len(buffer)-index) {
+		return nil, index, fmt.Errorf("failed to extract compact nullable string - buffer too small")
+	}
+
+	value := string(buffer[index : index+int(numberOfBytesToRead)])
+	return &value, index + int(numberOfBytesToRead), nil
+}
+
+func ExtractCompactBytes(buffer []byte, index int) ([]byte, int, error) {
+	length, index, err := ExtractUnsignedVarInt(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	if length == 0 {
+		return nil, index, fmt.Errorf("invalid compact bytes length")
+	}
+
+	if length-1 > uint64(len(buffer)-index) {
+		return nil, index, fmt.Errorf("failed to extract compact bytes - buffer too small")
+	}
+
+	numberOfBytesToRead := int(length - 1)
+
+	value := make([]byte, numberOfBytesToRead)
+	copy(value, buffer[index:index+numberOfBytesToRead])
+	return value, index + numberOfBytesToRead, nil
+}
+
+// ExtractUUID reads 16 raw bytes and formats them like "550e8400-e29b-41d4-a716-446655440000", the form SerializeUUID expects
+func ExtractUUID(buffer []byte, index int) (string, int, error) {
+	if index+16 > len(buffer) {
+		return "", index, fmt.Errorf("failed to extract uuid - buffer too small")
+	}
+
+	value := hex.EncodeToString(buffer[index : index+16])
+	return value[0:8] + "-" + value[8:12] + "-" + value[12:16] + "-" + value[16:20] + "-" + value[20:32], index + 16, nil
+}
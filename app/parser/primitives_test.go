@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -204,6 +205,130 @@ func TestExtractInt32(t *testing.T) {
 	}
 }
 
+func TestExtractInt64(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    int64
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "Valid int64",
+			buffer:  []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF, 0x00},
+			index:   0,
+			want:    0x0123456789ABCDEF,
+			wantIdx: 8,
+			wantErr: false,
+		},
+		{
+			name:    "Negative int64 from starting index",
+			buffer:  []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			index:   1,
+			want:    -1,
+			wantIdx: 9,
+			wantErr: false,
+		},
+		{
+			name:    "Buffer too small",
+			buffer:  []byte{0x01, 0x23, 0x45, 0x67},
+			index:   0,
+			want:    0,
+			wantIdx: 0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractInt64(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("extractInt64() got = %v, want %v", got, tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("extractInt64() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestExtractFloat64(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    float64
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "Valid float64",
+			buffer:  []byte{0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF},
+			index:   0,
+			want:    1.5,
+			wantIdx: 8,
+			wantErr: false,
+		},
+		{
+			name:    "Valid float64 from starting index",
+			buffer:  []byte{0x00, 0x40, 0x59, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			index:   1,
+			want:    100,
+			wantIdx: 9,
+			wantErr: false,
+		},
+		{
+			name:    "Buffer too small",
+			buffer:  []byte{0x3F, 0xF8, 0x00},
+			index:   0,
+			want:    0,
+			wantIdx: 0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractFloat64(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("extractFloat64() got = %v, want %v", got, tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("extractFloat64() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
 func TestExtractNullableString(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -253,6 +378,14 @@ func TestExtractNullableString(t *testing.T) {
 			wantIdx: 2,
 			wantErr: true,
 		},
+		{
+			name:    "Negative length",
+			buffer:  []byte{0xFF, 0xFE, 't', 'e', 's'},
+			index:   0,
+			want:    "",
+			wantIdx: 2,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -363,6 +496,14 @@ func TestExtractUnsignedVarInt(t *testing.T) {
 			wantIdx: 1,
 			wantErr: true,
 		},
+		{
+			name:    "Index past the end of the buffer",
+			buffer:  []byte{0x7F},
+			index:   3,
+			want:    0,
+			wantIdx: 3,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -481,6 +622,14 @@ func TestExtractCompactString(t *testing.T) {
 			wantIdx: 1,
 			wantErr: true,
 		},
+		{
+			name:    "Length overflowing an int",
+			buffer:  []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01, 't'},
+			index:   0,
+			want:    "",
+			wantIdx: 10,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -509,3 +658,398 @@ func TestExtractCompactString(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractCompactArrayLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    int
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "Null array (length 0)",
+			buffer:  []byte{0x00},
+			index:   0,
+			want:    -1,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Empty array (length 1)",
+			buffer:  []byte{0x01},
+			index:   0,
+			want:    0,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Array of 2 (length 3)",
+			buffer:  []byte{0x03, 0x00, 0x00},
+			index:   0,
+			want:    2,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "More elements than bytes left",
+			buffer:  []byte{0x04, 0x00, 0x00},
+			index:   0,
+			want:    0,
+			wantIdx: 1,
+			wantErr: true,
+		},
+		{
+			name:    "Huge length (2^42)",
+			buffer:  []byte{0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 0x00},
+			index:   0,
+			want:    0,
+			wantIdx: 7,
+			wantErr: true,
+		},
+		{
+			name:    "Buffer too small for varint",
+			buffer:  []byte{},
+			index:   0,
+			want:    0,
+			wantIdx: 0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractCompactArrayLength(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ExtractCompactArrayLength() got = %v, want %v", got, tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("ExtractCompactArrayLength() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestExtractBoolean(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    bool
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "False",
+			buffer:  []byte{0x00},
+			index:   0,
+			want:    false,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "True",
+			buffer:  []byte{0x01},
+			index:   0,
+			want:    true,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Any non-zero byte is true",
+			buffer:  []byte{0x7F},
+			index:   0,
+			want:    true,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Boolean is read from starting index",
+			buffer:  []byte{0x00, 0x01},
+			index:   1,
+			want:    true,
+			wantIdx: 2,
+			wantErr: false,
+		},
+		{
+			name:    "Empty buffer",
+			buffer:  []byte{},
+			index:   0,
+			want:    false,
+			wantIdx: 0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractBoolean(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ExtractBoolean() got = %v, want %v", got, tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("ExtractBoolean() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestExtractCompactNullableString(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    *string
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "Null string (length 0)",
+			buffer:  []byte{0x00},
+			index:   0,
+			want:    nil,
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Empty string (length 1)",
+			buffer:  []byte{0x01},
+			index:   0,
+			want:    stringPtr(""),
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Valid string 'test' (length 5)",
+			buffer:  []byte{0x05, 't', 'e', 's', 't'},
+			index:   0,
+			want:    stringPtr("test"),
+			wantIdx: 5,
+			wantErr: false,
+		},
+		{
+			name:    "String is read from starting index",
+			buffer:  []byte{0xFF, 0x03, 'h', 'i'},
+			index:   1,
+			want:    stringPtr("hi"),
+			wantIdx: 4,
+			wantErr: false,
+		},
+		{
+			name:    "Buffer too small for varint",
+			buffer:  []byte{},
+			index:   0,
+			want:    nil,
+			wantIdx: 0,
+			wantErr: true,
+		},
+		{
+			name:    "Buffer too small for string content",
+			buffer:  []byte{0x05, 't', 'e'},
+			index:   0,
+			want:    nil,
+			wantIdx: 1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractCompactNullableString(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if (got == nil) != (tt.want == nil) {
+				t.Errorf("ExtractCompactNullableString() got = %v, want %v", got, tt.want)
+				return
+			}
+
+			if got != nil && *got != *tt.want {
+				t.Errorf("ExtractCompactNullableString() got = %q, want %q", *got, *tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("ExtractCompactNullableString() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestExtractCompactBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    []byte
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "Empty bytes",
+			buffer:  []byte{0x01},
+			index:   0,
+			want:    []byte{},
+			wantIdx: 1,
+			wantErr: false,
+		},
+		{
+			name:    "Bytes with NUL separators",
+			buffer:  []byte{0xFF, 0x04, 0x00, 'a', 0x00},
+			index:   1,
+			want:    []byte{0x00, 'a', 0x00},
+			wantIdx: 5,
+			wantErr: false,
+		},
+		{
+			name:    "Null length is invalid",
+			buffer:  []byte{0x00},
+			index:   0,
+			want:    nil,
+			wantIdx: 1,
+			wantErr: true,
+		},
+		{
+			name:    "Buffer too small for content",
+			buffer:  []byte{0x05, 'a'},
+			index:   0,
+			want:    nil,
+			wantIdx: 1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractCompactBytes(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ExtractCompactBytes() got = %v, want %v", got, tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("ExtractCompactBytes() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestExtractUUID(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		index   int
+		want    string
+		wantIdx int
+		wantErr bool
+	}{
+		{
+			name:    "Zero UUID",
+			buffer:  make([]byte, 16),
+			index:   0,
+			want:    "00000000-0000-0000-0000-000000000000",
+			wantIdx: 16,
+			wantErr: false,
+		},
+		{
+			name: "UUID from starting index",
+			buffer: []byte{
+				0xFF,
+				0x55, 0x0E, 0x84, 0x00, 0xE2, 0x9B, 0x41, 0xD4,
+				0xA7, 0x16, 0x44, 0x66, 0x55, 0x44, 0x00, 0x00,
+			},
+			index:   1,
+			want:    "550e8400-e29b-41d4-a716-446655440000",
+			wantIdx: 17,
+			wantErr: false,
+		},
+		{
+			name:    "Buffer too small",
+			buffer:  make([]byte, 15),
+			index:   0,
+			want:    "",
+			wantIdx: 0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotIdx, err := ExtractUUID(tt.buffer, tt.index)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ExtractUUID() got = %v, want %v", got, tt.want)
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("ExtractUUID() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
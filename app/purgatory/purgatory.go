@@ -0,0 +1,240 @@
+package purgatory
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DelayedOperation is an operation that waits for a condition, such as enough bytes to answer a Fetch or
+// every in-sync replica acknowledging a Produce, until its timeout
+type DelayedOperation struct {
+	timeout time.Duration
+	// tryComplete checks the condition, it is never called concurrently for the same operation
+	tryComplete func() bool
+	// onComplete builds the response once the condition is met or the operation is completed by force
+	onComplete func()
+	// onExpiration builds the timeout response, it is called instead of onComplete
+	onExpiration func()
+
+	mutex     sync.Mutex
+	completed atomic.Bool
+	timer     *Timer
+	task      *TimerTask
+}
+
+func NewDelayedOperation(timeout time.Duration, tryComplete func() bool, onComplete func(), onExpiration func()) *DelayedOperation {
+	return &DelayedOperation{
+		timeout:      timeout,
+		tryComplete:  tryComplete,
+		onComplete:   onComplete,
+		onExpiration: onExpiration,
+	}
+}
+
+// ForceComplete completes the operation whatever its condition, it returns false when it was already completed
+func (o *DelayedOperation) ForceComplete() bool {
+	if !o.complete() {
+		return false
+	}
+
+	o.onComplete()
+	return true
+}
+
+func (o *DelayedOperation) IsCompleted() bool {
+	return o.completed.Load()
+}
+
+// complete marks the operation completed exactly once and cancels its expiration
+func (o *DelayedOperation) complete() bool {
+	if !o.completed.CompareAndSwap(false, true) {
+		return false
+	}
+
+	o.mutex.Lock()
+	if o.task != nil {
+		o.timer.Cancel(o.task)
+	}
+	o.mutex.Unlock()
+	return true
+}
+
+func (o *DelayedOperation) safeTryComplete() bool {
+	o.mutex.Lock()
+	ready := !o.IsCompleted() && o.tryComplete()
+	o.mutex.Unlock()
+
+	return ready && o.ForceComplete()
+}
+
+// Purgatory holds the delayed operations of one kind until they complete or expire.
+// Operations watch keys such as a topic-partition or a group id, and events on a key complete the operations watching it
+type Purgatory struct {
+	Name  string
+	timer *Timer
+	// Completed operations are only removed from the watch lists of other keys when this many completed since the last purge
+	purgeInterval int
+
+	mutex    sync.Mutex
+	watchers map[string][]*DelayedOperation
+	// Operations that completed while still watching other keys
+	completedSincePurge int
+}
+
+// Kafka's defaults for the timer of a purgatory, a 1ms tick on a 20 slot wheel
+const (
+	DEFAULT_TICK           = time.Millisecond
+	DEFAULT_WHEEL_SIZE     = 20
+	DEFAULT_PURGE_INTERVAL = 1000
+)
+
+// New starts a purgatory, Shutdown must be called to stop its timer
+func New(name string, purgeInterval int) *Purgatory {
+	timer := NewTimer(DEFAULT_TICK, DEFAULT_WHEEL_SIZE)
+	timer.Start()
+
+	return newPurgatory(name, timer, purgeInterval)
+}
+
+func newPurgatory(name string, timer *Timer, purgeInterval int) *Purgatory {
+	return &Purgatory{
+		Name:          name,
+		timer:         timer,
+		purgeInterval: purgeInterval,
+		watchers:      make(map[string][]*DelayedOperation),
+	}
+}
+
+// TryCompleteElseWatch completes the operation right away when its condition is met. Otherwise it parks the operation
+// under every key until an event on one of them completes it or it expires. It returns true when completed right away
+func (p *Purgatory) TryCompleteElseWatch(operation *DelayedOperation, keys []string) bool {
+	if operation.safeTryComplete() {
+		return true
+	}
+
+	for _, key := range keys {
+		if operation.IsCompleted() {
+			return false
+		}
+		p.watch(key, operation)
+	}
+
+	// The condition may have been met by an event between the first check and the watch
+	if operation.safeTryComplete() {
+		return true
+	}
+
+	if !operation.IsCompleted() {
+		operation.mutex.Lock()
+		operation.timer = p.timer
+		operation.mutex.Unlock()
+
+		task := p.timer.Add(operation.timeout, func() { p.expire(operation) })
+
+		operation.mutex.Lock()
+		operation.task = task
+		operation.mutex.Unlock()
+
+		// Completed while the task was being added, complete() could not cancel it
+		if operation.IsCompleted() {
+			p.timer.Cancel(task)
+		}
+	}
+
+	return false
+}
+
+// CheckAndComplete is called on events such as a log append or a high watermark change,
+// it completes the operations watching key whose condition is now met and returns how many did
+func (p *Purgatory) CheckAndComplete(key string) int {
+	p.mutex.Lock()
+	operations := p.watchers[key]
+	p.mutex.Unlock()
+
+	completed := 0
+	for _, operation := range operations {
+		if operation.safeTryComplete() {
+			completed++
+		}
+	}
+
+	p.mutex.Lock()
+	p.removeCompletedLocked(key)
+	p.completedSincePurge += completed
+	if p.completedSincePurge >= p.purgeInterval {
+		p.purgeLocked()
+	}
+	p.mutex.Unlock()
+
+	return completed
+}
+
+// Watched is the number of watch list entries, an operation watching several keys counts once per key
+func (p *Purgatory) Watched() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	watched := 0
+	for _, operations := range p.watchers {
+		watched += len(operations)
+	}
+	return watched
+}
+
+// Delayed is the number of operations waiting to expire
+func (p *Purgatory) Delayed() int {
+	return p.timer.Size()
+}
+
+// Shutdown stops the timer, parked operations are neither completed nor expired
+func (p *Purgatory) Shutdown() {
+	p.timer.Shutdown()
+}
+
+func (p *Purgatory) watch(key string, operation *DelayedOperation) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.watchers[key] = append(p.watchers[key], operation)
+}
+
+func (p *Purgatory) expire(operation *DelayedOperation) {
+	if !operation.complete() {
+		return
+	}
+
+	operation.onExpiration()
+
+	p.mutex.Lock()
+	p.completedSincePurge++
+	if p.completedSincePurge >= p.purgeInterval {
+		p.purgeLocked()
+	}
+	p.mutex.Unlock()
+}
+
+// purgeLocked removes completed and expired operations from every watch list
+func (p *Purgatory) purgeLocked() {
+	for key := range p.watchers {
+		p.removeCompletedLocked(key)
+	}
+
+	p.completedSincePurge = 0
+}
+
+// removeCompletedLocked builds a new watch list, CheckAndComplete may still be iterating over the previous one
+func (p *Purgatory) removeCompletedLocked(key string) {
+	var remaining []*DelayedOperation
+	for _, operation := range p.watchers[key] {
+		if !operation.IsCompleted() {
+			remaining = append(remaining, operation)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(p.watchers, key)
+	} else {
+		p.watchers[key] = remaining
+	}
+}
@@ -0,0 +1,129 @@
+package purgatory
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testOperation completes once enough bytes are available, like a Fetch waiting for fetch.min.bytes
+type testOperation struct {
+	available *atomic.Int32
+	minBytes  int32
+	completed atomic.Bool
+	expired   atomic.Bool
+}
+
+func (o *testOperation) delayed(timeout time.Duration) *DelayedOperation {
+	return NewDelayedOperation(timeout,
+		func() bool { return o.available.Load() >= o.minBytes },
+		func() { o.completed.Store(true) },
+		func() { o.expired.Store(true) },
+	)
+}
+
+func TestPurgatoryCompletesRightAway(t *testing.T) {
+	clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+	purgatory := newPurgatory("Fetch", newTimer(time.Millisecond, 20, clock.Now), DEFAULT_PURGE_INTERVAL)
+
+	available := &atomic.Int32{}
+	available.Store(10)
+	operation := &testOperation{available: available, minBytes: 1}
+
+	if !purgatory.TryCompleteElseWatch(operation.delayed(time.Second), []string{"topic-0"}) {
+		t.Fatalf("expected the operation to complete right away")
+	}
+
+	if !operation.completed.Load() {
+		t.Errorf("onComplete was not called")
+	}
+	if purgatory.Watched() != 0 || purgatory.Delayed() != 0 {
+		t.Errorf("completed operation was parked: watched %d, delayed %d", purgatory.Watched(), purgatory.Delayed())
+	}
+}
+
+func TestPurgatoryCompletesOnEvent(t *testing.T) {
+	clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+	purgatory := newPurgatory("Fetch", newTimer(time.Millisecond, 20, clock.Now), DEFAULT_PURGE_INTERVAL)
+
+	available := &atomic.Int32{}
+	operation := &testOperation{available: available, minBytes: 100}
+	delayed := operation.delayed(time.Second)
+
+	if purgatory.TryCompleteElseWatch(delayed, []string{"topic-0", "topic-1"}) {
+		t.Fatalf("operation must wait for enough bytes")
+	}
+	if purgatory.Watched() != 2 || purgatory.Delayed() != 1 {
+		t.Fatalf("expected the operation to watch 2 keys and wait to expire, got watched %d, delayed %d", purgatory.Watched(), purgatory.Delayed())
+	}
+
+	// An append that is not enough does not complete it
+	available.Store(50)
+	if completed := purgatory.CheckAndComplete("topic-0"); completed != 0 {
+		t.Errorf("expected nothing to complete, got %d", completed)
+	}
+
+	available.Store(150)
+	if completed := purgatory.CheckAndComplete("topic-1"); completed != 1 {
+		t.Errorf("expected 1 completion, got %d", completed)
+	}
+
+	if !operation.completed.Load() || operation.expired.Load() {
+		t.Errorf("expected completion without expiration: completed %v, expired %v", operation.completed.Load(), operation.expired.Load())
+	}
+	if purgatory.Delayed() != 0 {
+		t.Errorf("expiration of the completed operation was not cancelled")
+	}
+	// The other key still lists it until it is purged or checked
+	if purgatory.Watched() != 1 {
+		t.Errorf("expected 1 watch entry left, got %d", purgatory.Watched())
+	}
+	if purgatory.CheckAndComplete("topic-0") != 0 || purgatory.Watched() != 0 {
+		t.Errorf("completed operation must leave the watch lists once checked")
+	}
+}
+
+func TestPurgatoryExpiresOperations(t *testing.T) {
+	clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+	timer := newTimer(time.Millisecond, 20, clock.Now)
+	// Purging after every expiration empties the watch lists right away
+	purgatory := newPurgatory("Produce", timer, 1)
+
+	operation := &testOperation{available: &atomic.Int32{}, minBytes: 1}
+	delayed := operation.delayed(500 * time.Millisecond)
+	purgatory.TryCompleteElseWatch(delayed, []string{"topic-0"})
+
+	clock.now = clock.now.Add(499 * time.Millisecond)
+	timer.advance()
+	if operation.expired.Load() {
+		t.Fatalf("operation expired early")
+	}
+
+	clock.now = clock.now.Add(time.Millisecond)
+	timer.advance()
+
+	if !operation.expired.Load() || operation.completed.Load() {
+		t.Errorf("expected expiration without completion: completed %v, expired %v", operation.completed.Load(), operation.expired.Load())
+	}
+	if delayed.ForceComplete() {
+		t.Errorf("expired operation must not complete again")
+	}
+	if purgatory.Watched() != 0 || purgatory.Delayed() != 0 {
+		t.Errorf("expired operation is still parked: watched %d, delayed %d", purgatory.Watched(), purgatory.Delayed())
+	}
+}
+
+func TestPurgatoryWithRunningTimer(t *testing.T) {
+	purgatory := New("Heartbeat", DEFAULT_PURGE_INTERVAL)
+	defer purgatory.Shutdown()
+
+	expired := make(chan struct{})
+	delayed := NewDelayedOperation(20*time.Millisecond, func() bool { return false }, func() {}, func() { close(expired) })
+	purgatory.TryCompleteElseWatch(delayed, []string{"group"})
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("operation did not expire")
+	}
+}
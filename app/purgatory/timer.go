@@ -0,0 +1,255 @@
+package purgatory
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// TimerTask is a callback scheduled on a Timer
+type TimerTask struct {
+	expiration int64
+	run        func()
+	// The bucket holding the task, nil once it ran or was cancelled
+	bucket *bucket
+}
+
+// bucket holds the tasks of one slot of a timing wheel, they all expire within the slot's tick
+type bucket struct {
+	// Start of the slot in milliseconds, -1 while the bucket is not queued
+	expiration int64
+	tasks      map[*TimerTask]struct{}
+	// Position in the bucket queue
+	index int
+}
+
+func newBucket() *bucket {
+	return &bucket{expiration: -1, tasks: make(map[*TimerTask]struct{}), index: -1}
+}
+
+// timingWheel is a hierarchical timing wheel as described in Varghese and Lauck's paper and used by Kafka.
+// Tasks that expire beyond the wheel's interval go to an overflow wheel whose tick is this wheel's interval
+type timingWheel struct {
+	tickMs      int64
+	wheelSize   int64
+	interval    int64
+	currentTime int64
+	buckets     []*bucket
+	overflow    *timingWheel
+}
+
+func newTimingWheel(tickMs int64, wheelSize int64, startMs int64) *timingWheel {
+	buckets := make([]*bucket, wheelSize)
+	for i := range buckets {
+		buckets[i] = newBucket()
+	}
+
+	return &timingWheel{
+		tickMs:      tickMs,
+		wheelSize:   wheelSize,
+		interval:    tickMs * wheelSize,
+		currentTime: startMs - startMs%tickMs,
+		buckets:     buckets,
+	}
+}
+
+// add puts the task in its bucket and returns the bucket when it must be (re)queued.
+// The second value is false when the task already expired and must run right away
+func (w *timingWheel) add(task *TimerTask) (*bucket, bool) {
+	if task.expiration < w.currentTime+w.tickMs {
+		return nil, false
+	}
+
+	if task.expiration < w.currentTime+w.interval {
+		virtualId := task.expiration / w.tickMs
+		b := w.buckets[virtualId%w.wheelSize]
+		b.tasks[task] = struct{}{}
+		task.bucket = b
+
+		// A bucket is reused once the wheel went round, it is only queued when its slot changes
+		expiration := virtualId * w.tickMs
+		if b.expiration == expiration {
+			return nil, true
+		}
+		b.expiration = expiration
+		return b, true
+	}
+
+	if w.overflow == nil {
+		w.overflow = newTimingWheel(w.interval, w.wheelSize, w.currentTime)
+	}
+	return w.overflow.add(task)
+}
+
+func (w *timingWheel) advanceClock(timeMs int64) {
+	if timeMs < w.currentTime+w.tickMs {
+		return
+	}
+
+	w.currentTime = timeMs - timeMs%w.tickMs
+	if w.overflow != nil {
+		w.overflow.advanceClock(w.currentTime)
+	}
+}
+
+// bucketQueue orders the queued buckets by expiration, so that the timer only wakes up for buckets that are due
+type bucketQueue []*bucket
+
+func (q bucketQueue) Len() int           { return len(q) }
+func (q bucketQueue) Less(i, j int) bool { return q[i].expiration < q[j].expiration }
+func (q bucketQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *bucketQueue) Push(x any) {
+	b := x.(*bucket)
+	b.index = len(*q)
+	*q = append(*q, b)
+}
+
+func (q *bucketQueue) Pop() any {
+	old := *q
+	b := old[len(old)-1]
+	old[len(old)-1] = nil
+	b.index = -1
+	*q = old[:len(old)-1]
+	return b
+}
+
+// Timer runs tasks once their delay elapsed, with the precision of its tick.
+// Adding and cancelling tasks is O(1) whatever the number of pending tasks
+type Timer struct {
+	tick  time.Duration
+	now   func() time.Time
+	mutex sync.Mutex
+	wheel *timingWheel
+	queue bucketQueue
+	size  int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewTimer(tick time.Duration, wheelSize int) *Timer {
+	return newTimer(tick, wheelSize, time.Now)
+}
+
+func newTimer(tick time.Duration, wheelSize int, now func() time.Time) *Timer {
+	return &Timer{
+		tick:  tick,
+		now:   now,
+		wheel: newTimingWheel(tick.Milliseconds(), int64(wheelSize), now().UnixMilli()),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start advances the clock every tick until Shutdown
+func (t *Timer) Start() {
+	go func() {
+		defer close(t.done)
+
+		ticker := time.NewTicker(t.tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.advance()
+			}
+		}
+	}()
+}
+
+// Shutdown stops the clock, pending tasks never run
+func (t *Timer) Shutdown() {
+	close(t.stop)
+	<-t.done
+}
+
+// Add schedules run after delay. Tasks that are already due run on the caller's goroutine
+func (t *Timer) Add(delay time.Duration, run func()) *TimerTask {
+	task := &TimerTask{expiration: t.now().Add(delay).UnixMilli(), run: run}
+
+	t.mutex.Lock()
+	due := !t.addLocked(task)
+	t.mutex.Unlock()
+
+	if due {
+		task.run()
+	}
+	return task
+}
+
+// Cancel removes a task that did not run yet, it returns false when the task already ran or was cancelled
+func (t *Timer) Cancel(task *TimerTask) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if task.bucket == nil {
+		return false
+	}
+
+	delete(task.bucket.tasks, task)
+	task.bucket = nil
+	t.size--
+	return true
+}
+
+// Size is the number of tasks waiting to run
+func (t *Timer) Size() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.size
+}
+
+func (t *Timer) addLocked(task *TimerTask) bool {
+	b, added := t.wheel.add(task)
+	if !added {
+		return false
+	}
+
+	t.size++
+	if b != nil {
+		if b.index >= 0 {
+			heap.Fix(&t.queue, b.index)
+		} else {
+			heap.Push(&t.queue, b)
+		}
+	}
+	return true
+}
+
+// advance runs the tasks of every bucket that is due. Tasks of overflow wheels move down to finer wheels instead
+func (t *Timer) advance() {
+	nowMs := t.now().UnixMilli()
+	var due []*TimerTask
+
+	t.mutex.Lock()
+	for len(t.queue) > 0 && t.queue[0].expiration <= nowMs {
+		b := heap.Pop(&t.queue).(*bucket)
+		t.wheel.advanceClock(b.expiration)
+
+		b.expiration = -1
+		tasks := b.tasks
+		b.tasks = make(map[*TimerTask]struct{})
+
+		for task := range tasks {
+			task.bucket = nil
+			t.size--
+			if !t.addLocked(task) {
+				due = append(due, task)
+			}
+		}
+	}
+	t.mutex.Unlock()
+
+	for _, task := range due {
+		task.run()
+	}
+}
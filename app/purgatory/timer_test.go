@@ -0,0 +1,91 @@
+package purgatory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests move time forward by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestTimerRunsTasksWhenDue(t *testing.T) {
+	clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+	timer := newTimer(time.Millisecond, 20, clock.Now)
+
+	var ran []int
+	// Delays beyond the 20ms wheel interval go to overflow wheels
+	for _, delayMs := range []int{5, 19, 20, 150, 1000} {
+		timer.Add(time.Duration(delayMs)*time.Millisecond, func() { ran = append(ran, delayMs) })
+	}
+
+	tests := []struct {
+		advanceMs int
+		wantRan   []int
+	}{
+		{advanceMs: 4, wantRan: nil},
+		{advanceMs: 5, wantRan: []int{5}},
+		{advanceMs: 20, wantRan: []int{5, 19, 20}},
+		{advanceMs: 149, wantRan: []int{5, 19, 20}},
+		{advanceMs: 150, wantRan: []int{5, 19, 20, 150}},
+		{advanceMs: 1000, wantRan: []int{5, 19, 20, 150, 1000}},
+	}
+
+	for _, tt := range tests {
+		clock.now = time.UnixMilli(1_000_000 + int64(tt.advanceMs))
+		timer.advance()
+
+		if !reflect.DeepEqual(ran, tt.wantRan) {
+			t.Errorf("after %dms: got %v, want %v", tt.advanceMs, ran, tt.wantRan)
+		}
+	}
+
+	if timer.Size() != 0 {
+		t.Errorf("expected no pending task, got %d", timer.Size())
+	}
+}
+
+func TestTimerCancel(t *testing.T) {
+	clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+	timer := newTimer(time.Millisecond, 20, clock.Now)
+
+	ran := false
+	task := timer.Add(10*time.Millisecond, func() { ran = true })
+	if timer.Size() != 1 {
+		t.Fatalf("expected 1 pending task, got %d", timer.Size())
+	}
+
+	if !timer.Cancel(task) {
+		t.Errorf("expected the pending task to be cancelled")
+	}
+	if timer.Cancel(task) {
+		t.Errorf("a task must only be cancelled once")
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	timer.advance()
+
+	if ran {
+		t.Errorf("cancelled task ran")
+	}
+	if timer.Size() != 0 {
+		t.Errorf("expected no pending task, got %d", timer.Size())
+	}
+}
+
+func TestTimerRunsDueTasksRightAway(t *testing.T) {
+	timer := newTimer(time.Millisecond, 20, (&fakeClock{now: time.UnixMilli(1_000_000)}).Now)
+
+	ran := false
+	timer.Add(0, func() { ran = true })
+
+	if !ran {
+		t.Errorf("expected a task without delay to run on Add")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/request"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+const (
+	// How often the timers of the quorum run and its requests are sent
+	quorumPollInterval = 10 * time.Millisecond
+	// The metadata log is replaced by a snapshot every metadataSnapshotInterval committed records
+	metadataSnapshotInterval = 10_000
+	// The most entries a Fetch of the metadata log returns
+	quorumFetchMaxEntries = 500
+)
+
+// quorumController runs the controller of a process with the controller role. The voters send their quorum requests
+// to the controller listener of each other, and the metadata log is kept in the first log dir, so that the controller
+// restarts from it
+type quorumController struct {
+	controller *controller.Controller
+	transport  *request.QuorumTransport
+	storage    *raft.Storage
+	stop       chan struct{}
+	stopped    sync.WaitGroup
+}
+
+func startQuorumController(serverConfig *config.ServerConfig, directoryId string, loader *metadata.Loader, logger *slog.Logger) (*quorumController, error) {
+	raftStorage, err := raft.OpenStorage(filepath.Join(serverConfig.LogDirs[0], storage.MetadataLogDirName))
+	if err != nil {
+		return nil, err
+	}
+
+	controllerConfig := controller.Config{
+		SnapshotInterval:                   metadataSnapshotInterval,
+		SessionTimeout:                     serverConfig.Quorum.SessionTimeout,
+		UncleanLeaderElection:              serverConfig.Quorum.UncleanLeaderElection,
+		MinInsyncReplicas:                  serverConfig.Quorum.MinInsyncReplicas,
+		AutoLeaderRebalance:                serverConfig.Quorum.AutoLeaderRebalance,
+		LeaderImbalanceCheckInterval:       serverConfig.Quorum.LeaderImbalanceCheckInterval,
+		LeaderImbalancePerBrokerPercentage: serverConfig.Quorum.LeaderImbalancePerBrokerPercentage,
+		NumPartitions:                      serverConfig.Quorum.NumPartitions,
+		DefaultReplicationFactor:           serverConfig.Quorum.DefaultReplicationFactor,
+	}
+
+	q := &quorumController{storage: raftStorage, stop: make(chan struct{})}
+	// The voters are found in the voter set of the node, which the log may change
+	voterSet := func() []raft.Voter { return q.controller.Node().Voters() }
+	q.transport = request.NewQuorumTransport(serverConfig.NodeId, quorumListenerName(serverConfig), voterSet, serverConfig.Quorum.RequestTimeout, serverConfig.Quorum.RetryBackoff)
+	// The broker of a combined process loads the records the controller commits
+	q.controller = controller.NewController(controllerConfig, quorumRaftConfig(serverConfig, directoryId, raftStorage), q.transport, logger, time.Now(), loader)
+
+	q.stopped.Add(1)
+	go func() {
+		defer q.stopped.Done()
+		pollQuorum(q.controller.Node(), q.controller.Tick, logger, q.stop)
+	}()
+
+	return q, nil
+}
+
+func (q *quorumController) shutdown() {
+	close(q.stop)
+	q.stopped.Wait()
+	q.transport.Close()
+	q.storage.Close()
+}
+
+// quorumObserver follows the metadata quorum on a process with only the broker role. Its node fetches the metadata
+// log from the leader without voting, keeps it in the first log dir like a voter, and hands the committed records
+// to the metadata loader of the broker
+type quorumObserver struct {
+	node      *raft.Node
+	transport *request.QuorumTransport
+	storage   *raft.Storage
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+}
+
+func startQuorumObserver(serverConfig *config.ServerConfig, directoryId string, loader *metadata.Loader, logger *slog.Logger) (*quorumObserver, error) {
+	raftStorage, err := raft.OpenStorage(filepath.Join(serverConfig.LogDirs[0], storage.MetadataLogDirName))
+	if err != nil {
+		return nil, err
+	}
+
+	o := &quorumObserver{storage: raftStorage, stop: make(chan struct{})}
+	voterSet := func() []raft.Voter { return o.node.Voters() }
+	o.transport = request.NewQuorumTransport(serverConfig.NodeId, quorumListenerName(serverConfig), voterSet, serverConfig.Quorum.RequestTimeout, serverConfig.Quorum.RetryBackoff)
+	o.node = raft.NewNode(quorumRaftConfig(serverConfig, directoryId, raftStorage), o.transport, loader, time.Now())
+
+	o.stopped.Add(1)
+	go func() {
+		defer o.stopped.Done()
+		pollQuorum(o.node, func(now time.Time) {}, logger, o.stop)
+	}()
+
+	return o, nil
+}
+
+func (o *quorumObserver) shutdown() {
+	close(o.stop)
+	o.stopped.Wait()
+	o.transport.Close()
+	o.storage.Close()
+}
+
+// quorumListenerName is the controller listener the voters are reached on
+func quorumListenerName(serverConfig *config.ServerConfig) string {
+	if len(serverConfig.Quorum.ListenerNames) > 0 {
+		return serverConfig.Quorum.ListenerNames[0]
+	}
+	return "CONTROLLER"
+}
+
+// quorumRaftConfig is the member of the quorum of the node, a voter when controller.quorum.voters lists it and an
+// observer otherwise
+func quorumRaftConfig(serverConfig *config.ServerConfig, directoryId string, raftStorage *raft.Storage) raft.Config {
+	listenerName := quorumListenerName(serverConfig)
+	voters := make([]raft.Voter, 0, len(serverConfig.Quorum.Voters))
+	for _, voter := range serverConfig.Quorum.Voters {
+		voters = append(voters, raft.Voter{
+			Id:          voter.Id,
+			DirectoryId: raft.ZERO_DIRECTORY_ID,
+			Endpoints:   []raft.Endpoint{{Name: listenerName, Host: voter.Host, Port: voter.Port}},
+		})
+	}
+
+	return raft.Config{
+		NodeId:          serverConfig.NodeId,
+		DirectoryId:     directoryId,
+		Voters:          voters,
+		ElectionTimeout: serverConfig.Quorum.ElectionTimeout,
+		FetchTimeout:    serverConfig.Quorum.FetchTimeout,
+		FetchMaxEntries: quorumFetchMaxEntries,
+		Seed:            uint64(time.Now().UnixNano()),
+		Storage:         raftStorage,
+	}
+}
+
+// pollQuorum runs the timers of node and sends its requests until stop is closed, tick runs after every poll
+func pollQuorum(node *raft.Node, tick func(now time.Time), logger *slog.Logger, stop <-chan struct{}) {
+	ticker := time.NewTicker(quorumPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			node.Poll(now)
+			tick(now)
+
+			if err := node.Err(); err != nil {
+				logger.Error("The metadata log failed, stopping the node", "error", err)
+				os.Exit(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package quota
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Entity types of the client quota APIs
+const (
+	USER      = "user"
+	CLIENT_ID = "client-id"
+)
+
+type NameKind int8
+
+const (
+	// The entity has no component of this type
+	ABSENT NameKind = iota
+	// The component matches every user or client id that has no quota of its own
+	DEFAULT
+	SPECIFIC
+)
+
+// Name is one component of an entity
+type Name struct {
+	Kind  NameKind
+	Value string
+}
+
+var Default = Name{Kind: DEFAULT}
+
+func Specific(value string) Name {
+	return Name{Kind: SPECIFIC, Value: value}
+}
+
+func (n Name) String() string {
+	if n.Kind == DEFAULT {
+		return "<default>"
+	}
+	return n.Value
+}
+
+// Entity is what quotas are set on: a user, a client id or a client id of a user, any of them possibly the default
+type Entity struct {
+	User     Name
+	ClientId Name
+}
+
+func (e Entity) String() string {
+	components := []string{}
+	if e.User.Kind != ABSENT {
+		components = append(components, fmt.Sprintf("%s=%s", USER, e.User))
+	}
+	if e.ClientId.Kind != ABSENT {
+		components = append(components, fmt.Sprintf("%s=%s", CLIENT_ID, e.ClientId))
+	}
+	return "{" + strings.Join(components, ", ") + "}"
+}
+
+// candidates lists the entities whose quotas apply to a client, most specific first, as Kafka resolves them
+func candidates(user string, clientId string) []Entity {
+	return []Entity{
+		{User: Specific(user), ClientId: Specific(clientId)},
+		{User: Specific(user), ClientId: Default},
+		{User: Specific(user)},
+		{User: Default, ClientId: Specific(clientId)},
+		{User: Default, ClientId: Default},
+		{User: Default},
+		{ClientId: Specific(clientId)},
+		{ClientId: Default},
+	}
+}
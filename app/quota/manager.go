@@ -0,0 +1,88 @@
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// sensorKey identifies the clients sharing a quota: a quota set on a user is shared by all its client ids,
+// while a default quota gives every user or client id its own
+type sensorKey struct {
+	user     string
+	clientId string
+}
+
+// Manager measures one quota, such as the produce byte rate or the request percentage, and throttles clients that exceed it
+type Manager struct {
+	key     string
+	store   *Store
+	samples int
+	window  time.Duration
+
+	mutex     sync.Mutex
+	rates     map[sensorKey]*rate
+	lastPurge time.Time
+}
+
+func NewManager(key string, store *Store, samples int, window time.Duration) *Manager {
+	return &Manager{
+		key:     key,
+		store:   store,
+		samples: samples,
+		window:  window,
+		rates:   make(map[sensorKey]*rate),
+	}
+}
+
+// RecordAndGetThrottleTime records value for the client and returns how long it must be throttled,
+// zero while it stays within its quota or when it has none
+func (m *Manager) RecordAndGetThrottleTime(user string, clientId string, value float64, now time.Time) time.Duration {
+	entity, bound, ok := m.store.Lookup(user, clientId, m.key)
+	if !ok {
+		return 0
+	}
+
+	key := sensorKey{}
+	if entity.User.Kind != ABSENT {
+		key.user = user
+	}
+	if entity.ClientId.Kind != ABSENT {
+		key.clientId = clientId
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.purge(now)
+
+	r := m.rates[key]
+	if r == nil {
+		r = newRate(m.samples, m.window)
+		m.rates[key] = r
+	}
+	r.record(value, now)
+
+	measured := r.measure(now)
+	if measured <= bound {
+		return 0
+	}
+
+	// The delay that brings the rate back to the quota over the measured windows, at most one window
+	throttle := time.Duration((measured - bound) / bound * float64(r.elapsed(now)))
+	return min(throttle, m.window)
+}
+
+// purge forgets the clients that have been idle for longer than the measured windows
+func (m *Manager) purge(now time.Time) {
+	span := time.Duration(m.samples) * m.window
+	if now.Sub(m.lastPurge) < span {
+		return
+	}
+	m.lastPurge = now
+
+	for key, r := range m.rates {
+		if now.Sub(r.idleSince()) > span {
+			delete(m.rates, key)
+		}
+	}
+}
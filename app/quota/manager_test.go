@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManagerThrottlesClientsOverQuota(t *testing.T) {
+	store := NewStore()
+	if err := store.Alter(Entity{User: Specific("alice")}, []Op{{Key: PRODUCER_BYTE_RATE, Value: 8}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two 1s windows, a new client is measured over one window
+	manager := NewManager(PRODUCER_BYTE_RATE, store, 2, time.Second)
+	now := time.UnixMilli(1_000_000)
+
+	if throttle := manager.RecordAndGetThrottleTime("alice", "producer", 8, now); throttle != 0 {
+		t.Errorf("expected no throttling within the quota, got %v", throttle)
+	}
+
+	// 10 bytes per second is 25% over the quota of a 1s window
+	if throttle := manager.RecordAndGetThrottleTime("alice", "producer", 2, now); throttle != 250*time.Millisecond {
+		t.Errorf("expected 250ms of throttling, got %v", throttle)
+	}
+
+	// The quota of alice is shared by all her client ids
+	if throttle := manager.RecordAndGetThrottleTime("alice", "consumer", 100, now); throttle != time.Second {
+		t.Errorf("expected throttling capped at one window, got %v", throttle)
+	}
+
+	// Other users have no quota
+	if throttle := manager.RecordAndGetThrottleTime("bob", "producer", 100, now); throttle != 0 {
+		t.Errorf("expected no throttling without a quota, got %v", throttle)
+	}
+
+	// Once the samples are obsolete the client is within its quota again
+	if throttle := manager.RecordAndGetThrottleTime("alice", "producer", 1, now.Add(3*time.Second)); throttle != 0 {
+		t.Errorf("expected no throttling after the windows elapsed, got %v", throttle)
+	}
+}
+
+func TestManagerDefaultQuotaIsPerUser(t *testing.T) {
+	store := NewStore()
+	if err := store.Alter(Entity{User: Default}, []Op{{Key: REQUEST_PERCENTAGE, Value: 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewManager(REQUEST_PERCENTAGE, store, 2, time.Second)
+	now := time.UnixMilli(1_000_000)
+
+	if throttle := manager.RecordAndGetThrottleTime("alice", "", 10, now); throttle != 0 {
+		t.Errorf("expected no throttling within the quota, got %v", throttle)
+	}
+
+	// Each user gets its own default quota
+	if throttle := manager.RecordAndGetThrottleTime("bob", "", 10, now); throttle != 0 {
+		t.Errorf("expected bob not to share the usage of alice, got %v", throttle)
+	}
+}
+
+func TestManagerWithASingleWindow(t *testing.T) {
+	store := NewStore()
+	if err := store.Alter(Entity{User: Default}, []Op{{Key: REQUEST_PERCENTAGE, Value: 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// quota.window.num=1 measures a first request over one window rather than over no time at all
+	manager := NewManager(REQUEST_PERCENTAGE, store, 1, time.Second)
+	now := time.UnixMilli(1_000_000)
+
+	if throttle := manager.RecordAndGetThrottleTime("alice", "", 5, now); throttle != 0 {
+		t.Errorf("expected no throttling within the quota, got %v", throttle)
+	}
+
+	// 15% over one window is 50% over the quota
+	if throttle := manager.RecordAndGetThrottleTime("alice", "", 10, now.Add(100*time.Millisecond)); throttle != 500*time.Millisecond {
+		t.Errorf("expected 500ms of throttling, got %v", throttle)
+	}
+}
+
+func TestReplicationQuota(t *testing.T) {
+	// Two 1s windows, the first bytes are measured over one window
+	replicationQuota := NewReplicationQuota(2, time.Second)
+	now := time.UnixMilli(1_000_000)
+
+	replicationQuota.Record(8, now)
+	if replicationQuota.IsExceeded(8, now) {
+		t.Errorf("expected 8 bytes per second to be within a bound of 8")
+	}
+	replicationQuota.Record(2, now)
+	if !replicationQuota.IsExceeded(8, now) {
+		t.Errorf("expected 10 bytes per second to exceed a bound of 8")
+	}
+
+	// Once the samples are obsolete the replicas are within the bound again
+	if replicationQuota.IsExceeded(8, now.Add(3*time.Second)) {
+		t.Errorf("expected the rate to go down after the windows elapsed")
+	}
+}
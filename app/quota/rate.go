@@ -0,0 +1,80 @@
+package quota
+
+import "time"
+
+type sample struct {
+	// Zero while the sample is unused
+	start time.Time
+	value float64
+}
+
+// rate measures a value per second over sliding windows (quota.window.num samples of quota.window.size.seconds)
+type rate struct {
+	window  time.Duration
+	samples []sample
+	current int
+}
+
+func newRate(samples int, window time.Duration) *rate {
+	return &rate{window: window, samples: make([]sample, samples)}
+}
+
+func (r *rate) record(value float64, now time.Time) {
+	current := &r.samples[r.current]
+
+	switch {
+	case current.start.IsZero():
+		current.start = now
+	case now.Sub(current.start) >= r.window:
+		r.current = (r.current + 1) % len(r.samples)
+		r.samples[r.current] = sample{start: now}
+	}
+
+	r.samples[r.current].value += value
+}
+
+// measure is the value per second over the samples that are not obsolete
+func (r *rate) measure(now time.Time) float64 {
+	r.purge(now)
+
+	total := 0.0
+	for _, s := range r.samples {
+		total += s.value
+	}
+
+	return total / r.elapsed(now).Seconds()
+}
+
+// elapsed is the time the samples cover. A new client is measured as if it had been idle for all but one window,
+// so that a burst on its first request is not mistaken for a high rate. It is never less than one window, which with a
+// single sample is the only thing keeping a first request from being measured over no time at all
+func (r *rate) elapsed(now time.Time) time.Duration {
+	oldest := now
+	for _, s := range r.samples {
+		if !s.start.IsZero() && s.start.Before(oldest) {
+			oldest = s.start
+		}
+	}
+
+	elapsed := now.Sub(oldest)
+	fullWindows := int(elapsed / r.window)
+	if minFullWindows := len(r.samples) - 1; fullWindows < minFullWindows {
+		elapsed += time.Duration(minFullWindows-fullWindows) * r.window
+	}
+
+	return max(elapsed, r.window)
+}
+
+// idleSince is the start of the most recent sample
+func (r *rate) idleSince() time.Time {
+	return r.samples[r.current].start
+}
+
+func (r *rate) purge(now time.Time) {
+	expired := now.Add(-time.Duration(len(r.samples)) * r.window)
+	for i, s := range r.samples {
+		if !s.start.IsZero() && s.start.Before(expired) {
+			r.samples[i] = sample{}
+		}
+	}
+}
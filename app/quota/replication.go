@@ -0,0 +1,34 @@
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplicationQuota measures the bytes replicated by the throttled replicas of a broker on one side of the
+// replication, like the ReplicationQuotaManager of Kafka. The bound is leader.replication.throttled.rate or
+// follower.replication.throttled.rate, passed on every check so that it follows the dynamic broker configs
+type ReplicationQuota struct {
+	mutex sync.Mutex
+	rate  *rate
+}
+
+func NewReplicationQuota(samples int, window time.Duration) *ReplicationQuota {
+	return &ReplicationQuota{rate: newRate(samples, window)}
+}
+
+// Record adds the bytes of throttled replicas that were fetched or sent
+func (q *ReplicationQuota) Record(bytes int, now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.rate.record(float64(bytes), now)
+}
+
+// IsExceeded tells if the measured byte rate is above bound, the throttled replicas then wait for it to go down
+func (q *ReplicationQuota) IsExceeded(bound float64, now time.Time) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.rate.measure(now) > bound
+}
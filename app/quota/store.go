@@ -0,0 +1,151 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"sync"
+)
+
+// Quota keys that can be set on an entity
+const (
+	PRODUCER_BYTE_RATE       = "producer_byte_rate"
+	CONSUMER_BYTE_RATE       = "consumer_byte_rate"
+	REQUEST_PERCENTAGE       = "request_percentage"
+	CONTROLLER_MUTATION_RATE = "controller_mutation_rate"
+)
+
+// Byte rates are longs in Kafka, the other quotas are doubles
+var quotaKeys = map[string]bool{
+	PRODUCER_BYTE_RATE:       true,
+	CONSUMER_BYTE_RATE:       true,
+	REQUEST_PERCENTAGE:       false,
+	CONTROLLER_MUTATION_RATE: false,
+}
+
+var ErrInvalidQuota = errors.New("invalid quota")
+
+// Op sets or removes the value of one quota key
+type Op struct {
+	Key    string
+	Value  float64
+	Remove bool
+}
+
+// Validate checks an alteration of the quotas of an entity
+func Validate(entity Entity, ops []Op) error {
+	if entity.User.Kind == ABSENT && entity.ClientId.Kind == ABSENT {
+		return fmt.Errorf("%w: the entity must have a %s or a %s", ErrInvalidQuota, USER, CLIENT_ID)
+	}
+
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		wholeNumber, ok := quotaKeys[op.Key]
+		if !ok {
+			return fmt.Errorf("%w: unknown quota key %s", ErrInvalidQuota, op.Key)
+		}
+
+		if seen[op.Key] {
+			return fmt.Errorf("%w: duplicate quota key %s", ErrInvalidQuota, op.Key)
+		}
+		seen[op.Key] = true
+
+		if op.Remove {
+			continue
+		}
+
+		if !(op.Value > 0) || math.IsInf(op.Value, 1) {
+			return fmt.Errorf("%w: %s must be a positive number, got %v", ErrInvalidQuota, op.Key, op.Value)
+		}
+
+		if wholeNumber && op.Value != math.Trunc(op.Value) {
+			return fmt.Errorf("%w: %s must be a whole number, got %v", ErrInvalidQuota, op.Key, op.Value)
+		}
+	}
+
+	return nil
+}
+
+// Store holds the client quotas set with AlterClientQuotas, which are metadata records that reach the store once Load
+// is called with the quotas of the new metadata image
+type Store struct {
+	mutex  sync.RWMutex
+	quotas map[Entity]map[string]float64
+}
+
+func NewStore() *Store {
+	return &Store{quotas: make(map[Entity]map[string]float64)}
+}
+
+// Alter applies the ops to the entity, an entity left without quotas is removed
+func (s *Store) Alter(entity Entity, ops []Op) error {
+	if err := Validate(entity, ops); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	quotas := s.quotas[entity]
+	if quotas == nil {
+		quotas = make(map[string]float64)
+	}
+
+	for _, op := range ops {
+		if op.Remove {
+			delete(quotas, op.Key)
+		} else {
+			quotas[op.Key] = op.Value
+		}
+	}
+
+	if len(quotas) == 0 {
+		delete(s.quotas, entity)
+	} else {
+		s.quotas[entity] = quotas
+	}
+
+	return nil
+}
+
+// Load replaces the quotas with the ones of the metadata image, by entity and key
+func (s *Store) Load(quotas map[Entity]map[string]float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.quotas = make(map[Entity]map[string]float64, len(quotas))
+	for entity, values := range quotas {
+		s.quotas[entity] = maps.Clone(values)
+	}
+}
+
+// Entities returns a copy of the quotas of every entity
+func (s *Store) Entities() map[Entity]map[string]float64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entities := make(map[Entity]map[string]float64, len(s.quotas))
+	for entity, quotas := range s.quotas {
+		entities[entity] = maps.Clone(quotas)
+	}
+	return entities
+}
+
+// Lookup finds the quota of a client, along with the entity it was set on. ok is false when the client has no such quota
+func (s *Store) Lookup(user string, clientId string, key string) (Entity, float64, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if len(s.quotas) == 0 {
+		return Entity{}, 0, false
+	}
+
+	for _, entity := range candidates(user, clientId) {
+		if value, ok := s.quotas[entity][key]; ok {
+			return entity, value, true
+		}
+	}
+
+	return Entity{}, 0, false
+}
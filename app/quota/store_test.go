@@ -0,0 +1,146 @@
+package quota
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  Entity
+		ops     []Op
+		wantErr error
+	}{
+		{
+			name:   "User and client id quotas",
+			entity: Entity{User: Specific("alice"), ClientId: Default},
+			ops: []Op{
+				{Key: PRODUCER_BYTE_RATE, Value: 1024},
+				{Key: REQUEST_PERCENTAGE, Value: 12.5},
+				{Key: CONSUMER_BYTE_RATE, Remove: true},
+			},
+		},
+		{
+			name:    "Entity without components",
+			entity:  Entity{},
+			ops:     []Op{{Key: PRODUCER_BYTE_RATE, Value: 1024}},
+			wantErr: ErrInvalidQuota,
+		},
+		{
+			name:    "Unknown key",
+			entity:  Entity{User: Default},
+			ops:     []Op{{Key: "connection_creation_rate", Value: 10}},
+			wantErr: ErrInvalidQuota,
+		},
+		{
+			name:    "Duplicate key",
+			entity:  Entity{User: Default},
+			ops:     []Op{{Key: PRODUCER_BYTE_RATE, Value: 10}, {Key: PRODUCER_BYTE_RATE, Remove: true}},
+			wantErr: ErrInvalidQuota,
+		},
+		{
+			name:    "Negative value",
+			entity:  Entity{ClientId: Specific("app")},
+			ops:     []Op{{Key: REQUEST_PERCENTAGE, Value: -1}},
+			wantErr: ErrInvalidQuota,
+		},
+		{
+			name:    "Fractional byte rate",
+			entity:  Entity{ClientId: Specific("app")},
+			ops:     []Op{{Key: CONSUMER_BYTE_RATE, Value: 10.5}},
+			wantErr: ErrInvalidQuota,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.entity, tt.ops)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStoreAlter(t *testing.T) {
+	store := NewStore()
+	entity := Entity{User: Specific("alice")}
+
+	if err := store.Alter(entity, []Op{{Key: PRODUCER_BYTE_RATE, Value: 1024}, {Key: REQUEST_PERCENTAGE, Value: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Alter(entity, []Op{{Key: PRODUCER_BYTE_RATE, Remove: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[Entity]map[string]float64{entity: {REQUEST_PERCENTAGE: 50}}
+	if got := store.Entities(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Entities() = %v, want %v", got, want)
+	}
+
+	// An entity without quotas is forgotten
+	if err := store.Alter(entity, []Op{{Key: REQUEST_PERCENTAGE, Remove: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Entities(); len(got) != 0 {
+		t.Errorf("expected no entity left, got %v", got)
+	}
+}
+
+func TestStoreLoad(t *testing.T) {
+	store := NewStore()
+	if err := store.Alter(Entity{User: Specific("alice")}, []Op{{Key: PRODUCER_BYTE_RATE, Value: 1024}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The quotas of the image replace all the others
+	quotas := map[Entity]map[string]float64{{ClientId: Default}: {REQUEST_PERCENTAGE: 25}}
+	store.Load(quotas)
+	if got := store.Entities(); !reflect.DeepEqual(got, quotas) {
+		t.Errorf("expected the quotas %v, got %v", quotas, got)
+	}
+}
+
+func TestStoreLookup(t *testing.T) {
+	store := NewStore()
+	quotas := map[Entity]float64{
+		{User: Specific("alice"), ClientId: Specific("producer")}: 1,
+		{User: Specific("alice")}:                                 2,
+		{User: Default, ClientId: Specific("producer")}:           3,
+		{User: Default}:     4,
+		{ClientId: Default}: 5,
+	}
+	for entity, value := range quotas {
+		if err := store.Alter(entity, []Op{{Key: PRODUCER_BYTE_RATE, Value: value}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		user       string
+		clientId   string
+		wantEntity Entity
+		wantValue  float64
+	}{
+		{user: "alice", clientId: "producer", wantEntity: Entity{User: Specific("alice"), ClientId: Specific("producer")}, wantValue: 1},
+		{user: "alice", clientId: "other", wantEntity: Entity{User: Specific("alice")}, wantValue: 2},
+		{user: "bob", clientId: "producer", wantEntity: Entity{User: Default, ClientId: Specific("producer")}, wantValue: 3},
+		{user: "bob", clientId: "other", wantEntity: Entity{User: Default}, wantValue: 4},
+	}
+
+	for _, tt := range tests {
+		entity, value, ok := store.Lookup(tt.user, tt.clientId, PRODUCER_BYTE_RATE)
+		if !ok || entity != tt.wantEntity || value != tt.wantValue {
+			t.Errorf("Lookup(%s, %s) = %v, %v, %v, want %v, %v", tt.user, tt.clientId, entity, value, ok, tt.wantEntity, tt.wantValue)
+		}
+	}
+
+	if _, _, ok := store.Lookup("alice", "producer", CONSUMER_BYTE_RATE); ok {
+		t.Errorf("expected no consumer byte rate quota")
+	}
+}
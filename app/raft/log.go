@@ -0,0 +1,214 @@
+package raft
+
+import "slices"
+
+// Returned by EndOffsetForEpoch for an epoch older than every entry of the log
+const UNDEFINED_EPOCH int32 = -1
+
+// Entry is a record of the replicated log. Control entries are written by the quorum itself, such as the
+// LeaderChange entry that starts every epoch, they are never delivered to the listener
+type Entry struct {
+	Offset  int64
+	Epoch   int32
+	Control bool
+	Data    []byte
+	// Set on the control entries that change the voter set, like the VotersRecord of KIP-853
+	Voters []Voter
+}
+
+// Log is the replicated log of a node. It starts where its latest snapshot ends
+type Log struct {
+	startOffset int64
+	// The epoch of the last entry covered by the snapshot, UNDEFINED_EPOCH without one
+	startEpoch int32
+	// The voter set as of the snapshot, nil while the statically configured voters apply
+	startVoters []Voter
+	entries     []Entry
+	// The latest voter set and the offset of the entry that set it, startOffset-1 when it comes from the snapshot
+	voters       []Voter
+	votersOffset int64
+	// Writes the entries to the metadata log dir before they are added, nil keeps them in memory only
+	storage *Storage
+}
+
+func newLog() *Log {
+	return &Log{startOffset: 0, startEpoch: UNDEFINED_EPOCH, votersOffset: -1}
+}
+
+// newLogFromSnapshot is the empty log following a snapshot fetched from the leader
+func newLogFromSnapshot(snapshot Snapshot) *Log {
+	return &Log{
+		startOffset:  snapshot.Id.EndOffset,
+		startEpoch:   snapshot.Id.Epoch,
+		startVoters:  snapshot.Voters,
+		voters:       snapshot.Voters,
+		votersOffset: snapshot.Id.EndOffset - 1,
+	}
+}
+
+// restoreLog is the log a node persisted in storage, following its snapshot if it has one
+func restoreLog(storage *Storage) *Log {
+	log := newLog()
+	if storage.snapshot != nil {
+		log = newLogFromSnapshot(*storage.snapshot)
+	}
+	log.storage = storage
+
+	log.entries = storage.entries
+	for _, entry := range log.entries {
+		if entry.Voters != nil {
+			log.voters, log.votersOffset = entry.Voters, entry.Offset
+		}
+	}
+	return log
+}
+
+func (l *Log) StartOffset() int64 {
+	return l.startOffset
+}
+
+func (l *Log) EndOffset() int64 {
+	return l.startOffset + int64(len(l.entries))
+}
+
+// LastEpoch is the epoch of the last entry, or of the snapshot while the log is empty
+func (l *Log) LastEpoch() int32 {
+	if len(l.entries) == 0 {
+		return l.startEpoch
+	}
+	return l.entries[len(l.entries)-1].Epoch
+}
+
+// Voters returns the latest voter set of the log, even if it is not committed yet, and the offset of the entry that
+// set it. The voter set is nil while the statically configured voters apply
+func (l *Log) Voters() ([]Voter, int64) {
+	return l.voters, l.votersOffset
+}
+
+func (l *Log) append(epoch int32, control bool, data []byte) (Entry, error) {
+	entry := Entry{Offset: l.EndOffset(), Epoch: epoch, Control: control, Data: data}
+	if err := l.appendEntries([]Entry{entry}); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+func (l *Log) appendVoters(epoch int32, voters []Voter) (Entry, error) {
+	entry := Entry{Offset: l.EndOffset(), Epoch: epoch, Control: true, Voters: voters}
+	if err := l.appendEntries([]Entry{entry}); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// appendEntries appends the entries a follower fetched, they must start at the end of the log. They are on disk
+// before they are added, so that the follower never reports an offset it could lose
+func (l *Log) appendEntries(entries []Entry) error {
+	appended := []Entry{}
+	for _, entry := range entries {
+		if entry.Offset == l.EndOffset()+int64(len(appended)) {
+			appended = append(appended, entry)
+		}
+	}
+	if err := l.storage.appendEntries(appended); err != nil {
+		return err
+	}
+
+	for _, entry := range appended {
+		l.entries = append(l.entries, entry)
+		if entry.Voters != nil {
+			l.voters, l.votersOffset = entry.Voters, entry.Offset
+		}
+	}
+	return nil
+}
+
+// Read returns up to maxEntries entries starting at offset
+func (l *Log) Read(offset int64, maxEntries int) []Entry {
+	if offset < l.startOffset || offset >= l.EndOffset() {
+		return nil
+	}
+
+	from := int(offset - l.startOffset)
+	to := min(from+maxEntries, len(l.entries))
+	return slices.Clone(l.entries[from:to])
+}
+
+// EpochOf returns the epoch of the entry at offset, which may be the last one covered by the snapshot
+func (l *Log) EpochOf(offset int64) int32 {
+	if offset == l.startOffset-1 {
+		return l.startEpoch
+	}
+	if offset < l.startOffset || offset >= l.EndOffset() {
+		return UNDEFINED_EPOCH
+	}
+	return l.entries[offset-l.startOffset].Epoch
+}
+
+// EndOffsetForEpoch returns the largest epoch up to the requested one and the offset where it ends, the same answer
+// as OffsetForLeaderEpoch. An epoch older than every entry ends at the start of the log
+func (l *Log) EndOffsetForEpoch(epoch int32) (int32, int64) {
+	if epoch >= l.LastEpoch() {
+		return l.LastEpoch(), l.EndOffset()
+	}
+
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if l.entries[i].Epoch <= epoch {
+			return l.entries[i].Epoch, l.entries[i].Offset + 1
+		}
+	}
+
+	if l.startEpoch != UNDEFINED_EPOCH && l.startEpoch <= epoch {
+		return l.startEpoch, l.startOffset
+	}
+	return UNDEFINED_EPOCH, l.startOffset
+}
+
+// truncateTo removes the entries from offset on, after the follower found where its log diverged from the leader's
+func (l *Log) truncateTo(offset int64) error {
+	offset = max(offset, l.startOffset)
+	if offset >= l.EndOffset() {
+		return nil
+	}
+
+	if err := l.storage.truncateEntries(int(offset - l.startOffset)); err != nil {
+		return err
+	}
+	l.entries = l.entries[:offset-l.startOffset]
+	l.voters, l.votersOffset = l.votersBefore(offset)
+	return nil
+}
+
+// truncatePrefix removes the entries before offset once a snapshot covers them
+func (l *Log) truncatePrefix(offset int64, epoch int32) error {
+	if offset <= l.startOffset {
+		return nil
+	}
+
+	var entries []Entry
+	if offset < l.EndOffset() {
+		entries = slices.Clone(l.entries[offset-l.startOffset:])
+	}
+	if err := l.storage.rewriteEntries(entries); err != nil {
+		return err
+	}
+
+	l.startVoters, _ = l.votersBefore(offset)
+	l.entries = entries
+	l.startOffset = offset
+	l.startEpoch = epoch
+	if l.votersOffset < offset {
+		l.votersOffset = offset - 1
+	}
+	return nil
+}
+
+// votersBefore returns the voter set as of the entries before offset, and the offset of the entry that set it
+func (l *Log) votersBefore(offset int64) ([]Voter, int64) {
+	for i := min(offset-l.startOffset, int64(len(l.entries))) - 1; i >= 0; i-- {
+		if l.entries[i].Voters != nil {
+			return l.entries[i].Voters, l.entries[i].Offset
+		}
+	}
+	return l.startVoters, l.startOffset - 1
+}
@@ -0,0 +1,77 @@
+package raft
+
+import "testing"
+
+func TestLogEndOffsetForEpoch(t *testing.T) {
+	log := newLog()
+	for _, epoch := range []int32{1, 1, 3, 3, 3, 4} {
+		log.append(epoch, false, nil)
+	}
+
+	tests := []struct {
+		name          string
+		epoch         int32
+		wantEpoch     int32
+		wantEndOffset int64
+	}{
+		{"Latest epoch ends at the log end offset", 4, 4, 6},
+		{"Later epoch", 7, 4, 6},
+		{"Epoch ends where the next one starts", 3, 3, 5},
+		{"Missing epoch resolves to the previous one", 2, 1, 2},
+		{"Epoch older than every entry", 0, UNDEFINED_EPOCH, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			epoch, endOffset := log.EndOffsetForEpoch(tt.epoch)
+			if epoch != tt.wantEpoch || endOffset != tt.wantEndOffset {
+				t.Errorf("got epoch %d ending at %d, want %d ending at %d", epoch, endOffset, tt.wantEpoch, tt.wantEndOffset)
+			}
+		})
+	}
+}
+
+func TestLogTruncation(t *testing.T) {
+	log := newLog()
+	for _, epoch := range []int32{1, 1, 2, 2, 3} {
+		log.append(epoch, false, nil)
+	}
+
+	log.truncatePrefix(3, log.EpochOf(2))
+	if log.StartOffset() != 3 || log.EndOffset() != 5 {
+		t.Fatalf("expected the log to span [3, 5), got [%d, %d)", log.StartOffset(), log.EndOffset())
+	}
+	if epoch, endOffset := log.EndOffsetForEpoch(1); epoch != UNDEFINED_EPOCH || endOffset != 3 {
+		t.Errorf("epochs before the snapshot end at its end offset, got %d ending at %d", epoch, endOffset)
+	}
+
+	log.truncateTo(4)
+	if log.EndOffset() != 4 || log.LastEpoch() != 2 {
+		t.Errorf("expected the log to end at 4 in epoch 2, got %d in epoch %d", log.EndOffset(), log.LastEpoch())
+	}
+
+	// The entries covered by the snapshot are never truncated
+	log.truncateTo(1)
+	if log.EndOffset() != 3 || log.LastEpoch() != 2 {
+		t.Errorf("expected the log to end at the snapshot, got %d in epoch %d", log.EndOffset(), log.LastEpoch())
+	}
+}
+
+func TestLogVoters(t *testing.T) {
+	log := newLog()
+	log.append(1, true, nil)
+	log.appendVoters(1, StaticVoters(1, 2))
+	log.append(1, false, nil)
+	log.appendVoters(2, StaticVoters(1, 2, 3))
+
+	if voters, offset := log.Voters(); len(voters) != 3 || offset != 3 {
+		t.Fatalf("expected 3 voters set at offset 3, got %v at %d", voters, offset)
+	}
+
+	// The snapshot keeps the voter set of the entries it replaces
+	log.truncatePrefix(3, 1)
+	log.truncateTo(3)
+	if voters, offset := log.Voters(); len(voters) != 2 || offset != 2 {
+		t.Errorf("expected 2 voters from the snapshot at offset 2, got %v at %d", voters, offset)
+	}
+}
@@ -0,0 +1,96 @@
+package raft
+
+// Error codes of the quorum responses, the same numbers Kafka uses so that they can be sent over the wire as they are
+const (
+	NONE                   int16 = 0
+	NOT_LEADER_OR_FOLLOWER int16 = 6
+	FENCED_LEADER_EPOCH    int16 = 74
+	UNKNOWN_LEADER_EPOCH   int16 = 75
+	SNAPSHOT_NOT_FOUND     int16 = 98
+)
+
+// NO_LEADER is the leader id while none is known, and the vote of a node that has not voted in its epoch
+const NO_LEADER int32 = -1
+
+// VoteRequest is sent by a candidate to every other voter
+type VoteRequest struct {
+	CandidateEpoch       int32
+	CandidateId          int32
+	CandidateDirectoryId string
+	// The epoch and end offset of the candidate's log, voters only vote for logs at least as long as theirs
+	LastOffsetEpoch int32
+	LastOffset      int64
+}
+
+type VoteResponse struct {
+	ErrorCode   int16
+	LeaderEpoch int32
+	LeaderId    int32
+	VoteGranted bool
+}
+
+// BeginQuorumEpochRequest is sent by a new leader to every voter, so that they start fetching from it
+type BeginQuorumEpochRequest struct {
+	LeaderEpoch int32
+	LeaderId    int32
+}
+
+type BeginQuorumEpochResponse struct {
+	ErrorCode   int16
+	LeaderEpoch int32
+	LeaderId    int32
+}
+
+// EndQuorumEpochRequest is sent by a leader that resigns. The preferred successors, the voters with the longest logs
+// first, start the next election sooner than the others
+type EndQuorumEpochRequest struct {
+	LeaderEpoch         int32
+	LeaderId            int32
+	PreferredSuccessors []int32
+}
+
+type EndQuorumEpochResponse struct {
+	ErrorCode   int16
+	LeaderEpoch int32
+	LeaderId    int32
+}
+
+// FetchRequest is sent to the leader by voters and observers alike, the fetch offset is also their log end offset
+type FetchRequest struct {
+	ReplicaId          int32
+	ReplicaDirectoryId string
+	CurrentLeaderEpoch int32
+	FetchOffset        int64
+	LastFetchedEpoch   int32
+}
+
+// DivergingEpoch tells a follower that its log differs from the leader's after EndOffset, the end of Epoch
+type DivergingEpoch struct {
+	Epoch     int32
+	EndOffset int64
+}
+
+type FetchResponse struct {
+	ErrorCode     int16
+	LeaderEpoch   int32
+	LeaderId      int32
+	HighWatermark int64
+	Entries       []Entry
+	// Set when the follower must truncate its log before fetching again
+	DivergingEpoch *DivergingEpoch
+	// Set when the entries at the fetch offset were replaced by a snapshot, which the follower must fetch first
+	SnapshotId *SnapshotId
+}
+
+type FetchSnapshotRequest struct {
+	ReplicaId          int32
+	CurrentLeaderEpoch int32
+	SnapshotId         SnapshotId
+}
+
+type FetchSnapshotResponse struct {
+	ErrorCode   int16
+	LeaderEpoch int32
+	LeaderId    int32
+	Snapshot    *Snapshot
+}
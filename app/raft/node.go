@@ -0,0 +1,845 @@
+package raft
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotLeader       = errors.New("not the leader of the quorum")
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+// Role is the state of a node in its epoch, like the EpochState implementations of KRaft
+type Role int8
+
+const (
+	// UNATTACHED nodes know of no leader in their epoch, voters may still have voted in it
+	UNATTACHED Role = iota
+	FOLLOWER
+	CANDIDATE
+	LEADER
+	// RESIGNED leaders stopped serving their epoch and wait for the election of the next one
+	RESIGNED
+)
+
+var roleNames = map[Role]string{
+	UNATTACHED: "Unattached",
+	FOLLOWER:   "Follower",
+	CANDIDATE:  "Candidate",
+	LEADER:     "Leader",
+	RESIGNED:   "Resigned",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// Config is the configuration of a node of the quorum, like the controller.quorum.* properties of Kafka
+type Config struct {
+	NodeId int32
+	// The id of the metadata log directory of the node, which tells it apart from a node that lost its disk
+	DirectoryId string
+	// Voters elect the leader among themselves, other nodes are observers that only replicate the log.
+	// They are the initial voter set, the leader changes it with AddVoter and RemoveVoter
+	Voters []Voter
+	// A voter without leader becomes a candidate after the election timeout, randomized up to twice its value
+	ElectionTimeout time.Duration
+	// A follower that could not fetch from the leader for the fetch timeout looks for a new one
+	FetchTimeout time.Duration
+	// FetchMaxEntries bounds the entries of a Fetch response
+	FetchMaxEntries int
+	// Seed randomizes the election timeouts, so that the voters do not all become candidates at once
+	Seed uint64
+	// Storage keeps the log and the quorum state of the node across restarts, nil keeps them in memory only
+	Storage *Storage
+}
+
+// ReplicaState is what the leader knows about a voter or an observer from its fetches
+type ReplicaState struct {
+	ReplicaId   int32
+	DirectoryId string
+	// The fetch offset of the replica, -1 until it fetched in the epoch of the leader
+	LogEndOffset  int64
+	LastFetchTime time.Time
+	// The last time the replica had every entry of the leader
+	LastCaughtUpTime time.Time
+	// The log end offset of the leader at the last fetch of the replica
+	lastFetchLeaderEndOffset int64
+}
+
+// Node is a member of a KRaft-style quorum replicating a log: voters elect a leader with Vote requests, the leader
+// announces itself with BeginQuorumEpoch, and followers pull the entries with Fetch. An entry is committed once a
+// majority of the voters have it, the high watermark is the end of the committed entries.
+// The node does nothing on its own, Poll runs its timers and sends its requests
+type Node struct {
+	config    Config
+	transport Transport
+	listener  Listener
+	random    *rand.Rand
+
+	mutex    sync.Mutex
+	role     Role
+	epoch    int32
+	leaderId int32
+	votedId  int32
+	log      *Log
+	snapshot *Snapshot
+	// The end of the committed entries
+	highWatermark int64
+	// When the election timeout of a voter without leader or the fetch timeout of a follower expire
+	deadline time.Time
+	// The votes granted to a candidate and whether it asked for them already
+	votes          map[int32]bool
+	votesRequested bool
+	// The offset of the LeaderChange entry starting the epoch of a leader, the high watermark only moves past it
+	epochStartOffset int64
+	replicas         map[int32]*ReplicaState
+	// The voter an observer without leader asks next
+	nextBootstrapVoter int
+	// The first storage failure, the node stops taking part in the quorum after it
+	err error
+
+	// Deliveries to the listener happen one at a time and in order, outside of mutex so that the listener may call
+	// back into the node
+	deliverMutex   sync.Mutex
+	appliedOffset  int64
+	loadSnapshot   *Snapshot
+	notifiedLeader LeaderAndEpoch
+}
+
+func NewNode(config Config, transport Transport, listener Listener, now time.Time) *Node {
+	node := &Node{
+		config:         config,
+		transport:      transport,
+		listener:       listener,
+		random:         rand.New(rand.NewPCG(config.Seed, uint64(config.NodeId))),
+		role:           UNATTACHED,
+		leaderId:       NO_LEADER,
+		votedId:        NO_LEADER,
+		log:            newLog(),
+		notifiedLeader: LeaderAndEpoch{LeaderId: NO_LEADER, Epoch: 0},
+	}
+
+	// A restarted node keeps its epoch and its vote, and loads its snapshot before the committed entries
+	if storage := config.Storage; storage != nil {
+		node.epoch, node.votedId = storage.state.LeaderEpoch, storage.state.VotedId
+		node.log = restoreLog(storage)
+		if storage.snapshot != nil {
+			node.snapshot = storage.snapshot
+			node.loadSnapshot = storage.snapshot
+			node.highWatermark = storage.snapshot.Id.EndOffset
+		}
+	}
+
+	// A single voter elects itself right away
+	node.deadline = now.Add(node.randomElectionTimeout())
+	if len(config.Voters) == 1 && config.Voters[0].matches(config.NodeId, config.DirectoryId) {
+		node.deadline = now
+	}
+
+	return node
+}
+
+func (n *Node) NodeId() int32 {
+	return n.config.NodeId
+}
+
+func (n *Node) Role() Role {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.role
+}
+
+func (n *Node) LeaderAndEpoch() LeaderAndEpoch {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return LeaderAndEpoch{LeaderId: n.leaderId, Epoch: n.epoch}
+}
+
+func (n *Node) HighWatermark() int64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.highWatermark
+}
+
+func (n *Node) LogEndOffset() int64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.log.EndOffset()
+}
+
+func (n *Node) LogStartOffset() int64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.log.StartOffset()
+}
+
+// Err returns the storage failure that stopped the node, nil while it runs. Like the fatal faults of KRaft, a node
+// that could not persist its log or its vote must not go on, it could acknowledge entries or votes it then forgets
+func (n *Node) Err() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.err
+}
+
+// Append writes records to the log of the leader of epoch and returns the offset of the last one. The records are
+// committed once a majority of the voters fetched them, they are lost if the leader changes before
+func (n *Node) Append(epoch int32, records [][]byte) (int64, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.role != LEADER || n.epoch != epoch || n.err != nil {
+		return 0, fmt.Errorf("%w: node %d is %s in epoch %d", ErrNotLeader, n.config.NodeId, n.role, n.epoch)
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for i, record := range records {
+		entries = append(entries, Entry{Offset: n.log.EndOffset() + int64(i), Epoch: n.epoch, Data: record})
+	}
+	if err := n.log.appendEntries(entries); err != nil {
+		n.fail(err)
+		return 0, err
+	}
+	n.maybeAdvanceHighWatermark()
+
+	return n.log.EndOffset() - 1, nil
+}
+
+// CreateSnapshot replaces the entries before endOffset with data, the state the listener built from them.
+// Only entries already delivered to the listener may be covered
+func (n *Node) CreateSnapshot(endOffset int64, data []byte) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if endOffset > n.appliedOffset || endOffset <= n.log.StartOffset() {
+		return fmt.Errorf("%w: end offset %d must be after %d and up to the applied offset %d", ErrInvalidSnapshot, endOffset, n.log.StartOffset(), n.appliedOffset)
+	}
+
+	epoch := n.log.EpochOf(endOffset - 1)
+	voters, _ := n.log.votersBefore(endOffset)
+	snapshot := &Snapshot{Id: SnapshotId{EndOffset: endOffset, Epoch: epoch}, Data: data, Voters: voters}
+
+	// The snapshot is on disk before the entries it covers are removed
+	err := n.config.Storage.saveSnapshot(*snapshot)
+	if err == nil {
+		err = n.log.truncatePrefix(endOffset, epoch)
+	}
+	if err != nil {
+		n.fail(err)
+		return err
+	}
+	n.snapshot = snapshot
+	return nil
+}
+
+// Poll runs the timers of the node and sends its requests: votes while it is a candidate, fetches while it follows a
+// leader, BeginQuorumEpoch to the voters that did not fetch yet while it leads. Committed entries are then delivered
+func (n *Node) Poll(now time.Time) {
+	for _, send := range n.pollRequests(now) {
+		send()
+	}
+	n.deliver()
+}
+
+func (n *Node) pollRequests(now time.Time) []func() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.err != nil {
+		return nil
+	}
+
+	expired := !now.Before(n.deadline)
+	voter := n.isVoter(n.config.NodeId, n.config.DirectoryId)
+	switch n.role {
+	case UNATTACHED, CANDIDATE, RESIGNED:
+		// A node removed from the voters looks for the leader like the other observers
+		if expired && voter {
+			n.becomeCandidate(now)
+		} else if expired && n.role != UNATTACHED {
+			n.becomeUnattached(n.epoch, now)
+		}
+	case FOLLOWER:
+		// The leader is gone, voters elect a new one and observers look for it
+		if expired && voter {
+			n.becomeCandidate(now)
+		} else if expired {
+			n.becomeUnattached(n.epoch, now)
+		}
+	case LEADER:
+		// A leader that removed itself from the voters resigns once the new voter set is committed
+		if _, votersOffset := n.log.Voters(); !n.hasMajorityFetching(now) || (!voter && n.highWatermark > votersOffset) {
+			return n.resign(now)
+		}
+	}
+
+	switch n.role {
+	case CANDIDATE:
+		if !n.votesRequested {
+			n.votesRequested = true
+			return n.voteRequests(now)
+		}
+	case FOLLOWER:
+		return []func(){n.fetchRequest(n.leaderId, now)}
+	case UNATTACHED:
+		// Observers find the leader by asking the voters in turn
+		if voters := n.voters(); !voter && len(voters) > 0 {
+			destination := voters[n.nextBootstrapVoter%len(voters)].Id
+			n.nextBootstrapVoter++
+			return []func(){n.fetchRequest(destination, now)}
+		}
+	case LEADER:
+		return n.beginQuorumEpochRequests(now)
+	}
+
+	return nil
+}
+
+// HandleVote grants the vote of this node to a candidate of its epoch whose log is at least as long as its own,
+// once per epoch
+func (n *Node) HandleVote(request VoteRequest, now time.Time) VoteResponse {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if request.CandidateEpoch > n.epoch {
+		n.becomeUnattached(request.CandidateEpoch, now)
+	}
+
+	response := VoteResponse{ErrorCode: NONE, LeaderEpoch: n.epoch, LeaderId: n.leaderId}
+	if request.CandidateEpoch < n.epoch {
+		response.ErrorCode = FENCED_LEADER_EPOCH
+		return response
+	}
+
+	canVote := n.role == UNATTACHED && (n.votedId == NO_LEADER || n.votedId == request.CandidateId)
+	logUpToDate := request.LastOffsetEpoch > n.log.LastEpoch() ||
+		(request.LastOffsetEpoch == n.log.LastEpoch() && request.LastOffset >= n.log.EndOffset())
+
+	if canVote && logUpToDate && n.isVoter(request.CandidateId, request.CandidateDirectoryId) && n.err == nil {
+		n.votedId = request.CandidateId
+		n.deadline = now.Add(n.randomElectionTimeout())
+		// The vote is only granted once the node will remember it
+		response.VoteGranted = n.saveQuorumState()
+	}
+
+	return response
+}
+
+// HandleBeginQuorumEpoch makes this node follow the new leader
+func (n *Node) HandleBeginQuorumEpoch(request BeginQuorumEpochRequest, now time.Time) BeginQuorumEpochResponse {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if request.LeaderEpoch < n.epoch {
+		return BeginQuorumEpochResponse{ErrorCode: FENCED_LEADER_EPOCH, LeaderEpoch: n.epoch, LeaderId: n.leaderId}
+	}
+
+	if request.LeaderEpoch > n.epoch || n.role != FOLLOWER {
+		n.becomeFollower(request.LeaderEpoch, request.LeaderId, now)
+	}
+
+	return BeginQuorumEpochResponse{ErrorCode: NONE, LeaderEpoch: n.epoch, LeaderId: n.leaderId}
+}
+
+// HandleEndQuorumEpoch starts the next election when the leader resigned. Its preferred successors become candidates
+// first, in order, the other voters wait for their election timeout
+func (n *Node) HandleEndQuorumEpoch(request EndQuorumEpochRequest, now time.Time) EndQuorumEpochResponse {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if request.LeaderEpoch < n.epoch {
+		return EndQuorumEpochResponse{ErrorCode: FENCED_LEADER_EPOCH, LeaderEpoch: n.epoch, LeaderId: n.leaderId}
+	}
+
+	if request.LeaderEpoch > n.epoch || (n.role == FOLLOWER && n.leaderId == request.LeaderId) {
+		n.becomeUnattached(request.LeaderEpoch, now)
+
+		if position := slices.Index(request.PreferredSuccessors, n.config.NodeId); position >= 0 {
+			n.deadline = now.Add(time.Duration(position) * n.config.ElectionTimeout / time.Duration(len(n.voters())))
+		}
+	}
+
+	return EndQuorumEpochResponse{ErrorCode: NONE, LeaderEpoch: n.epoch, LeaderId: n.leaderId}
+}
+
+// HandleFetch serves the entries of the leader from the fetch offset, once it checked that the log of the replica
+// does not diverge from its own. It records the progress of the replica, which may commit entries
+func (n *Node) HandleFetch(request FetchRequest, now time.Time) FetchResponse {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	response := FetchResponse{ErrorCode: NONE, LeaderEpoch: n.epoch, LeaderId: n.leaderId, HighWatermark: n.highWatermark}
+	switch {
+	case n.role != LEADER || n.err != nil:
+		response.ErrorCode = NOT_LEADER_OR_FOLLOWER
+		return response
+	case request.CurrentLeaderEpoch < n.epoch:
+		response.ErrorCode = FENCED_LEADER_EPOCH
+		return response
+	case request.CurrentLeaderEpoch > n.epoch:
+		response.ErrorCode = UNKNOWN_LEADER_EPOCH
+		return response
+	}
+
+	if request.FetchOffset < n.log.StartOffset() {
+		response.SnapshotId = &n.snapshot.Id
+		return response
+	}
+
+	epoch, endOffset := n.log.EndOffsetForEpoch(request.LastFetchedEpoch)
+	if epoch != request.LastFetchedEpoch || endOffset < request.FetchOffset {
+		// The logs diverge before the entries the snapshot replaced, the replica starts over from the snapshot
+		if endOffset <= n.log.StartOffset() && n.snapshot != nil {
+			response.SnapshotId = &n.snapshot.Id
+		} else {
+			response.DivergingEpoch = &DivergingEpoch{Epoch: epoch, EndOffset: endOffset}
+		}
+		return response
+	}
+
+	replica, ok := n.replicas[request.ReplicaId]
+	if !ok {
+		replica = &ReplicaState{ReplicaId: request.ReplicaId}
+		n.replicas[request.ReplicaId] = replica
+	}
+	// A replica that reached the end of the leader's log at its previous fetch was caught up then, as for the ISR
+	switch {
+	case request.FetchOffset >= n.log.EndOffset():
+		replica.LastCaughtUpTime = now
+	case request.FetchOffset >= replica.lastFetchLeaderEndOffset && !replica.LastFetchTime.IsZero():
+		replica.LastCaughtUpTime = replica.LastFetchTime
+	}
+	replica.DirectoryId = request.ReplicaDirectoryId
+	replica.LogEndOffset = request.FetchOffset
+	replica.LastFetchTime = now
+	replica.lastFetchLeaderEndOffset = n.log.EndOffset()
+
+	n.maybeAdvanceHighWatermark()
+	response.HighWatermark = n.highWatermark
+	response.Entries = n.log.Read(request.FetchOffset, n.config.FetchMaxEntries)
+
+	return response
+}
+
+// HandleFetchSnapshot serves the latest snapshot of the leader, at once
+func (n *Node) HandleFetchSnapshot(request FetchSnapshotRequest) FetchSnapshotResponse {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	response := FetchSnapshotResponse{ErrorCode: NONE, LeaderEpoch: n.epoch, LeaderId: n.leaderId}
+	switch {
+	case n.role != LEADER:
+		response.ErrorCode = NOT_LEADER_OR_FOLLOWER
+	case request.CurrentLeaderEpoch != n.epoch:
+		response.ErrorCode = FENCED_LEADER_EPOCH
+	case n.snapshot == nil || n.snapshot.Id != request.SnapshotId:
+		response.ErrorCode = SNAPSHOT_NOT_FOUND
+	default:
+		snapshot := *n.snapshot
+		response.Snapshot = &snapshot
+	}
+
+	return response
+}
+
+func (n *Node) voteRequests(now time.Time) []func() {
+	request := VoteRequest{
+		CandidateEpoch:       n.epoch,
+		CandidateId:          n.config.NodeId,
+		CandidateDirectoryId: n.config.DirectoryId,
+		LastOffsetEpoch:      n.log.LastEpoch(),
+		LastOffset:           n.log.EndOffset(),
+	}
+
+	requests := []func(){}
+	for _, voter := range n.voters() {
+		voter := voter.Id
+		if voter == n.config.NodeId {
+			continue
+		}
+
+		requests = append(requests, func() {
+			response, err := n.transport.Vote(voter, request)
+			if err != nil {
+				return
+			}
+
+			n.mutex.Lock()
+			defer n.mutex.Unlock()
+
+			n.observeEpoch(response.LeaderEpoch, response.LeaderId, now)
+			if n.role == CANDIDATE && n.epoch == request.CandidateEpoch && response.VoteGranted {
+				n.votes[voter] = true
+				n.maybeBecomeLeader(now)
+			}
+		})
+	}
+
+	return requests
+}
+
+func (n *Node) beginQuorumEpochRequests(now time.Time) []func() {
+	request := BeginQuorumEpochRequest{LeaderEpoch: n.epoch, LeaderId: n.config.NodeId}
+
+	requests := []func(){}
+	for _, voter := range n.voters() {
+		voter := voter.Id
+		if replica, ok := n.replicas[voter]; !ok || replica.LogEndOffset >= 0 {
+			continue
+		}
+
+		requests = append(requests, func() {
+			response, err := n.transport.BeginQuorumEpoch(voter, request)
+			if err != nil {
+				return
+			}
+
+			n.mutex.Lock()
+			defer n.mutex.Unlock()
+			n.observeEpoch(response.LeaderEpoch, response.LeaderId, now)
+		})
+	}
+
+	return requests
+}
+
+func (n *Node) fetchRequest(destination int32, now time.Time) func() {
+	request := FetchRequest{
+		ReplicaId:          n.config.NodeId,
+		ReplicaDirectoryId: n.config.DirectoryId,
+		CurrentLeaderEpoch: n.epoch,
+		FetchOffset:        n.log.EndOffset(),
+		LastFetchedEpoch:   n.log.LastEpoch(),
+	}
+
+	return func() {
+		response, err := n.transport.Fetch(destination, request)
+		if err != nil {
+			return
+		}
+
+		if snapshotId, ok := n.handleFetchResponse(destination, request, response, now); ok {
+			n.fetchSnapshot(destination, snapshotId, now)
+		}
+	}
+}
+
+// handleFetchResponse appends the fetched entries, or truncates the log where it diverges from the leader's.
+// It returns the snapshot to fetch when the leader no longer has the entries at the fetch offset
+func (n *Node) handleFetchResponse(leaderId int32, request FetchRequest, response FetchResponse, now time.Time) (SnapshotId, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.observeEpoch(response.LeaderEpoch, response.LeaderId, now)
+
+	// The response is stale when the node moved on or its log changed since the request
+	if response.ErrorCode != NONE || n.role != FOLLOWER || n.leaderId != leaderId || n.epoch != response.LeaderEpoch ||
+		n.log.EndOffset() != request.FetchOffset {
+		return SnapshotId{}, false
+	}
+
+	n.deadline = now.Add(n.config.FetchTimeout)
+
+	switch {
+	case response.SnapshotId != nil:
+		return *response.SnapshotId, true
+	case response.DivergingEpoch != nil:
+		// Truncate to the end of the diverging epoch in both logs, the next fetch checks the epoch before it
+		_, endOffset := n.log.EndOffsetForEpoch(response.DivergingEpoch.Epoch)
+		if err := n.log.truncateTo(min(endOffset, response.DivergingEpoch.EndOffset)); err != nil {
+			n.fail(err)
+		}
+	default:
+		if err := n.log.appendEntries(response.Entries); err != nil {
+			n.fail(err)
+		}
+		n.highWatermark = max(n.highWatermark, min(response.HighWatermark, n.log.EndOffset()))
+	}
+
+	return SnapshotId{}, false
+}
+
+func (n *Node) fetchSnapshot(leaderId int32, snapshotId SnapshotId, now time.Time) {
+	n.mutex.Lock()
+	request := FetchSnapshotRequest{ReplicaId: n.config.NodeId, CurrentLeaderEpoch: n.epoch, SnapshotId: snapshotId}
+	n.mutex.Unlock()
+
+	response, err := n.transport.FetchSnapshot(leaderId, request)
+	if err != nil {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.observeEpoch(response.LeaderEpoch, response.LeaderId, now)
+	if response.ErrorCode != NONE || response.Snapshot == nil || n.role != FOLLOWER || n.leaderId != leaderId {
+		return
+	}
+
+	// The snapshot replaces the whole log, it only covers committed entries
+	snapshot := *response.Snapshot
+	err = n.config.Storage.saveSnapshot(snapshot)
+	if err == nil {
+		err = n.config.Storage.rewriteEntries(nil)
+	}
+	if err != nil {
+		n.fail(err)
+		return
+	}
+	n.log = newLogFromSnapshot(snapshot)
+	n.log.storage = n.config.Storage
+	n.snapshot = &snapshot
+	n.highWatermark = max(n.highWatermark, snapshot.Id.EndOffset)
+	n.loadSnapshot = &snapshot
+}
+
+// resign gives up the leadership when a majority of the voters stopped fetching, the leader may be partitioned
+// from them, or when it is no longer a voter. The voters with the longest logs are asked to become candidates first
+func (n *Node) resign(now time.Time) []func() {
+	successors := []int32{}
+	for _, voter := range n.voters() {
+		if voter.Id != n.config.NodeId {
+			successors = append(successors, voter.Id)
+		}
+	}
+	logEndOffset := func(replicaId int32) int64 {
+		if replica, ok := n.replicas[replicaId]; ok {
+			return replica.LogEndOffset
+		}
+		return -1
+	}
+	slices.SortStableFunc(successors, func(a int32, b int32) int {
+		return cmp.Compare(logEndOffset(b), logEndOffset(a))
+	})
+
+	request := EndQuorumEpochRequest{LeaderEpoch: n.epoch, LeaderId: n.config.NodeId, PreferredSuccessors: successors}
+
+	n.role = RESIGNED
+	n.leaderId = NO_LEADER
+	n.replicas = nil
+	n.deadline = now.Add(n.randomElectionTimeout())
+
+	requests := []func(){}
+	for _, voter := range successors {
+		requests = append(requests, func() {
+			response, err := n.transport.EndQuorumEpoch(voter, request)
+			if err != nil {
+				return
+			}
+
+			n.mutex.Lock()
+			defer n.mutex.Unlock()
+			n.observeEpoch(response.LeaderEpoch, response.LeaderId, now)
+		})
+	}
+
+	return requests
+}
+
+// observeEpoch moves the node to a higher epoch it learned of from a response, or makes it follow the leader of its epoch
+func (n *Node) observeEpoch(epoch int32, leaderId int32, now time.Time) {
+	switch {
+	case epoch > n.epoch && leaderId != NO_LEADER && leaderId != n.config.NodeId:
+		n.becomeFollower(epoch, leaderId, now)
+	case epoch > n.epoch:
+		n.becomeUnattached(epoch, now)
+	case epoch == n.epoch && leaderId != NO_LEADER && leaderId != n.config.NodeId && n.role != FOLLOWER && n.role != LEADER:
+		n.becomeFollower(epoch, leaderId, now)
+	}
+}
+
+func (n *Node) becomeUnattached(epoch int32, now time.Time) {
+	// The vote of a voter holds for its whole epoch
+	if epoch != n.epoch {
+		n.votedId = NO_LEADER
+	}
+
+	n.role = UNATTACHED
+	n.epoch = epoch
+	n.leaderId = NO_LEADER
+	n.replicas = nil
+	n.deadline = now.Add(n.randomElectionTimeout())
+	n.saveQuorumState()
+}
+
+func (n *Node) becomeFollower(epoch int32, leaderId int32, now time.Time) {
+	if epoch != n.epoch {
+		n.votedId = NO_LEADER
+	}
+
+	n.role = FOLLOWER
+	n.epoch = epoch
+	n.leaderId = leaderId
+	n.replicas = nil
+	n.deadline = now.Add(n.config.FetchTimeout)
+	n.saveQuorumState()
+}
+
+// becomeCandidate starts an election in the next epoch, voting for itself
+func (n *Node) becomeCandidate(now time.Time) {
+	n.role = CANDIDATE
+	n.epoch++
+	n.leaderId = NO_LEADER
+	n.votedId = n.config.NodeId
+	n.votes = map[int32]bool{n.config.NodeId: true}
+	n.votesRequested = false
+	n.replicas = nil
+	n.deadline = now.Add(n.randomElectionTimeout())
+
+	// A candidate that could not persist its vote for itself asks for no other vote
+	if n.saveQuorumState() {
+		n.maybeBecomeLeader(now)
+	}
+}
+
+func (n *Node) maybeBecomeLeader(now time.Time) {
+	if len(n.votes) < n.majority() || n.err != nil {
+		return
+	}
+
+	// Entries of previous epochs are only committed along with an entry of this one, as in Raft
+	start, err := n.log.append(n.epoch, true, nil)
+	if err != nil {
+		n.fail(err)
+		return
+	}
+
+	n.role = LEADER
+	n.leaderId = n.config.NodeId
+
+	// The voters count as fetching when the epoch starts, so that they have the fetch timeout to find the new leader
+	n.replicas = make(map[int32]*ReplicaState)
+	for _, voter := range n.voters() {
+		if voter.Id != n.config.NodeId {
+			n.replicas[voter.Id] = &ReplicaState{ReplicaId: voter.Id, DirectoryId: voter.DirectoryId, LogEndOffset: -1, LastFetchTime: now}
+		}
+	}
+
+	n.epochStartOffset = start.Offset
+	n.maybeAdvanceHighWatermark()
+}
+
+// saveQuorumState persists the epoch and the vote of the node, it tells if they are safe
+func (n *Node) saveQuorumState() bool {
+	if err := n.config.Storage.saveQuorumState(n.epoch, n.votedId); err != nil {
+		n.fail(err)
+		return false
+	}
+	return true
+}
+
+// fail stops the node after a storage failure
+func (n *Node) fail(err error) {
+	if n.err == nil {
+		n.err = err
+	}
+}
+
+// hasMajorityFetching tells if a majority of the voters fetched from the leader lately, a leader that lost them
+// may not commit anything anymore
+func (n *Node) hasMajorityFetching(now time.Time) bool {
+	fetching := 0
+	for _, voter := range n.voters() {
+		if voter.Id == n.config.NodeId {
+			fetching++
+		} else if replica := n.voterReplica(voter); replica != nil && now.Sub(replica.LastFetchTime) <= n.config.FetchTimeout*3/2 {
+			fetching++
+		}
+	}
+
+	return fetching >= n.majority()
+}
+
+// maybeAdvanceHighWatermark moves the high watermark of the leader to the largest offset a majority of the voters
+// have, once it covers the start of the leader's epoch. A leader that removed itself from the voters does not count
+func (n *Node) maybeAdvanceHighWatermark() {
+	offsets := []int64{}
+	for _, voter := range n.voters() {
+		if voter.Id == n.config.NodeId {
+			offsets = append(offsets, n.log.EndOffset())
+		} else if replica := n.voterReplica(voter); replica != nil {
+			offsets = append(offsets, replica.LogEndOffset)
+		}
+	}
+	slices.SortFunc(offsets, func(a int64, b int64) int {
+		return cmp.Compare(b, a)
+	})
+
+	if len(offsets) < n.majority() {
+		return
+	}
+
+	highWatermark := offsets[n.majority()-1]
+	if highWatermark > n.highWatermark && highWatermark > n.epochStartOffset {
+		n.highWatermark = highWatermark
+	}
+}
+
+// deliver hands the listener the snapshot it must load, the entries committed since the last delivery,
+// and the latest leader
+func (n *Node) deliver() {
+	n.deliverMutex.Lock()
+	defer n.deliverMutex.Unlock()
+
+	n.mutex.Lock()
+	snapshot := n.loadSnapshot
+	n.loadSnapshot = nil
+	if snapshot != nil {
+		n.appliedOffset = snapshot.Id.EndOffset
+	}
+
+	committed := []Entry{}
+	for _, entry := range n.log.Read(n.appliedOffset, int(n.highWatermark-n.appliedOffset)) {
+		if !entry.Control {
+			committed = append(committed, entry)
+		}
+	}
+	n.appliedOffset = max(n.appliedOffset, n.highWatermark)
+
+	leader := LeaderAndEpoch{LeaderId: n.leaderId, Epoch: n.epoch}
+	// A leader learns of its own election once the entries of the previous epochs were delivered, so that its state is complete
+	if n.role == LEADER && n.appliedOffset <= n.epochStartOffset {
+		leader = n.notifiedLeader
+	}
+	leaderChanged := leader != n.notifiedLeader
+	n.notifiedLeader = leader
+	n.mutex.Unlock()
+
+	if snapshot != nil {
+		n.listener.HandleLoadSnapshot(*snapshot)
+	}
+	if len(committed) > 0 {
+		n.listener.HandleCommit(committed)
+	}
+	if leaderChanged {
+		n.listener.HandleLeaderChange(leader)
+	}
+}
+
+// voterReplica is the state of a voter on the leader, nil when another directory fetches with its id
+func (n *Node) voterReplica(voter Voter) *ReplicaState {
+	if replica, ok := n.replicas[voter.Id]; ok && voter.matches(replica.ReplicaId, replica.DirectoryId) {
+		return replica
+	}
+	return nil
+}
+
+func (n *Node) randomElectionTimeout() time.Duration {
+	if n.config.ElectionTimeout <= 0 {
+		return 0
+	}
+	return n.config.ElectionTimeout + time.Duration(n.random.Int64N(int64(n.config.ElectionTimeout)))
+}
@@ -0,0 +1,296 @@
+package raft
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testListener struct {
+	mutex     sync.Mutex
+	committed []string
+	snapshots []Snapshot
+	leader    LeaderAndEpoch
+}
+
+func (l *testListener) HandleCommit(entries []Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, entry := range entries {
+		l.committed = append(l.committed, string(entry.Data))
+	}
+}
+
+func (l *testListener) HandleLoadSnapshot(snapshot Snapshot) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.snapshots = append(l.snapshots, snapshot)
+	l.committed = []string{string(snapshot.Data)}
+}
+
+func (l *testListener) HandleLeaderChange(leader LeaderAndEpoch) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.leader = leader
+}
+
+func (l *testListener) Committed() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return append([]string{}, l.committed...)
+}
+
+// testCluster runs a quorum in-process, moving a fake clock forward between polls
+type testCluster struct {
+	t         *testing.T
+	now       time.Time
+	transport *MemoryTransport
+	voters    []int32
+	nodes     map[int32]*Node
+	listeners map[int32]*testListener
+}
+
+func newTestCluster(t *testing.T, voters []int32, observers ...int32) *testCluster {
+	cluster := &testCluster{
+		t:         t,
+		now:       time.UnixMilli(1_000_000),
+		voters:    voters,
+		nodes:     make(map[int32]*Node),
+		listeners: make(map[int32]*testListener),
+	}
+	cluster.transport = NewMemoryTransport(func() time.Time { return cluster.now })
+
+	for _, nodeId := range append(append([]int32{}, voters...), observers...) {
+		cluster.addNode(nodeId)
+	}
+
+	return cluster
+}
+
+func (c *testCluster) addNode(nodeId int32) *Node {
+	config := Config{
+		NodeId:          nodeId,
+		DirectoryId:     directoryId(nodeId),
+		Voters:          StaticVoters(c.voters...),
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 2,
+		Seed:            42,
+	}
+
+	listener := &testListener{}
+	node := NewNode(config, c.transport.Endpoint(nodeId), listener, c.now)
+	c.transport.Register(node)
+	c.nodes[nodeId] = node
+	c.listeners[nodeId] = listener
+	return node
+}
+
+// directoryId gives every test node its own metadata log directory
+func directoryId(nodeId int32) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", nodeId)
+}
+
+func (c *testCluster) poll(duration time.Duration) {
+	for end := c.now.Add(duration); c.now.Before(end); c.now = c.now.Add(10 * time.Millisecond) {
+		for _, node := range c.nodes {
+			node.Poll(c.now)
+		}
+	}
+}
+
+// leader returns the only leader among the given voters
+func (c *testCluster) leader(voters ...int32) *Node {
+	c.t.Helper()
+
+	var leader *Node
+	for _, nodeId := range voters {
+		if c.nodes[nodeId].Role() != LEADER {
+			continue
+		}
+		if leader != nil {
+			c.t.Fatalf("both %d and %d are leaders", leader.NodeId(), nodeId)
+		}
+		leader = c.nodes[nodeId]
+	}
+
+	if leader == nil {
+		c.t.Fatalf("no leader among %v", voters)
+	}
+	return leader
+}
+
+func (c *testCluster) append(leader *Node, records ...string) {
+	c.t.Helper()
+
+	data := [][]byte{}
+	for _, record := range records {
+		data = append(data, []byte(record))
+	}
+
+	if _, err := leader.Append(leader.LeaderAndEpoch().Epoch, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func TestQuorumElectsOneLeader(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1, 2, 3}, 4)
+	cluster.poll(5 * time.Second)
+
+	leader := cluster.leader(1, 2, 3)
+	want := leader.LeaderAndEpoch()
+
+	for nodeId, node := range cluster.nodes {
+		if got := node.LeaderAndEpoch(); got != want {
+			t.Errorf("node %d sees %+v, want %+v", nodeId, got, want)
+		}
+		if got := cluster.listeners[nodeId].leader; got != want {
+			t.Errorf("listener of node %d was told %+v, want %+v", nodeId, got, want)
+		}
+	}
+
+	if cluster.nodes[4].Role() != FOLLOWER {
+		t.Errorf("the observer must follow the leader, it is %s", cluster.nodes[4].Role())
+	}
+}
+
+func TestSingleVoterLeadsRightAway(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1})
+	cluster.poll(10 * time.Millisecond)
+
+	leader := cluster.leader(1)
+	cluster.append(leader, "a")
+	cluster.poll(10 * time.Millisecond)
+
+	if got := cluster.listeners[1].Committed(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("committed mismatch: got %v", got)
+	}
+}
+
+func TestQuorumReplicatesCommittedEntries(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1, 2, 3}, 4)
+	cluster.poll(5 * time.Second)
+
+	leader := cluster.leader(1, 2, 3)
+	cluster.append(leader, "a", "b", "c", "d", "e")
+	cluster.poll(time.Second)
+
+	want := []string{"a", "b", "c", "d", "e"}
+	for nodeId, listener := range cluster.listeners {
+		if got := listener.Committed(); !reflect.DeepEqual(got, want) {
+			t.Errorf("node %d committed %v, want %v", nodeId, got, want)
+		}
+		if got := cluster.nodes[nodeId].HighWatermark(); got != leader.LogEndOffset() {
+			t.Errorf("node %d has high watermark %d, want %d", nodeId, got, leader.LogEndOffset())
+		}
+	}
+
+	if _, err := cluster.nodes[4].Append(leader.LeaderAndEpoch().Epoch, [][]byte{[]byte("f")}); err == nil {
+		t.Errorf("only the leader may append")
+	}
+}
+
+func TestQuorumDoesNotCommitWithoutMajority(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1, 2, 3})
+	cluster.poll(5 * time.Second)
+
+	leader := cluster.leader(1, 2, 3)
+	for nodeId := range cluster.nodes {
+		if nodeId != leader.NodeId() {
+			cluster.transport.Disconnect(nodeId)
+		}
+	}
+
+	highWatermark := leader.HighWatermark()
+	cluster.append(leader, "a")
+	cluster.poll(time.Second)
+
+	if leader.HighWatermark() != highWatermark || len(cluster.listeners[leader.NodeId()].Committed()) != 0 {
+		t.Errorf("the entry must not be committed by the leader alone")
+	}
+
+	// Without fetches from a majority the leader resigns
+	cluster.poll(5 * time.Second)
+	if role := leader.Role(); role == LEADER {
+		t.Errorf("the isolated leader must resign")
+	}
+}
+
+func TestQuorumElectsNewLeaderAndTruncatesDivergingLog(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1, 2, 3}, 4)
+	cluster.poll(5 * time.Second)
+
+	oldLeader := cluster.leader(1, 2, 3)
+	cluster.append(oldLeader, "a")
+	cluster.poll(time.Second)
+
+	// The old leader keeps appending while partitioned, these entries are never committed
+	cluster.transport.Disconnect(oldLeader.NodeId())
+	cluster.append(oldLeader, "lost-1", "lost-2", "lost-3")
+
+	others := []int32{}
+	for _, nodeId := range []int32{1, 2, 3} {
+		if nodeId != oldLeader.NodeId() {
+			others = append(others, nodeId)
+		}
+	}
+
+	cluster.poll(10 * time.Second)
+	newLeader := cluster.leader(others...)
+	if newLeader.LeaderAndEpoch().Epoch <= oldLeader.LeaderAndEpoch().Epoch && oldLeader.Role() == LEADER {
+		t.Fatalf("the new leader must be in a later epoch")
+	}
+
+	cluster.append(newLeader, "b")
+	cluster.poll(time.Second)
+
+	cluster.transport.Connect(oldLeader.NodeId())
+	cluster.poll(10 * time.Second)
+
+	leader := cluster.leader(1, 2, 3)
+	want := []string{"a", "b"}
+	for nodeId, listener := range cluster.listeners {
+		if got := listener.Committed(); !reflect.DeepEqual(got, want) {
+			t.Errorf("node %d committed %v, want %v", nodeId, got, want)
+		}
+		if got := cluster.nodes[nodeId].LogEndOffset(); got != leader.LogEndOffset() {
+			t.Errorf("node %d has log end offset %d, want %d", nodeId, got, leader.LogEndOffset())
+		}
+	}
+}
+
+func TestLateObserverLoadsSnapshot(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1, 2, 3})
+	cluster.poll(5 * time.Second)
+
+	leader := cluster.leader(1, 2, 3)
+	cluster.append(leader, "a", "b", "c")
+	cluster.poll(time.Second)
+
+	// Every voter replaced its entries with a snapshot of the state they built
+	for _, node := range cluster.nodes {
+		if err := node.CreateSnapshot(node.HighWatermark(), []byte("a,b,c")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := leader.CreateSnapshot(leader.HighWatermark()+1, nil); err == nil {
+		t.Errorf("a snapshot must not cover entries that were not committed")
+	}
+
+	cluster.append(leader, "d")
+	observer := cluster.addNode(4)
+	cluster.poll(5 * time.Second)
+
+	if got := cluster.listeners[4].Committed(); !reflect.DeepEqual(got, []string{"a,b,c", "d"}) {
+		t.Errorf("the observer must load the snapshot then the following entries, got %v", got)
+	}
+	if observer.LogStartOffset() != leader.LogStartOffset() {
+		t.Errorf("log start offset mismatch: got %d, want %d", observer.LogStartOffset(), leader.LogStartOffset())
+	}
+}
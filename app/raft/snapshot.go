@@ -0,0 +1,55 @@
+package raft
+
+// SnapshotId names a snapshot by the offset it ends at, exclusive, and the epoch of its last entry
+type SnapshotId struct {
+	EndOffset int64
+	Epoch     int32
+}
+
+// Snapshot replaces the entries of the log before its end offset with the state they produced, as encoded by the
+// listener. Followers whose log ends before the start of the leader's log fetch it instead of the entries
+type Snapshot struct {
+	Id   SnapshotId
+	Data []byte
+	// The voter set as of the end of the snapshot, nil while the statically configured voters apply
+	Voters []Voter
+}
+
+type LeaderAndEpoch struct {
+	LeaderId int32
+	Epoch    int32
+}
+
+// Listener is the state machine replicated by the quorum. It is called by one goroutine at a time, in log order,
+// and may call the node back, for instance to append records or to create a snapshot
+type Listener interface {
+	// HandleCommit receives the entries once a majority of the voters have them, they will never be truncated
+	HandleCommit(entries []Entry)
+	// HandleLoadSnapshot replaces the state with the snapshot fetched from the leader, the next committed entries follow it
+	HandleLoadSnapshot(snapshot Snapshot)
+	// HandleLeaderChange is called when the node learns of a new epoch or of the leader of its epoch. A new leader is
+	// told of its own election once every committed entry of the previous epochs was delivered
+	HandleLeaderChange(leader LeaderAndEpoch)
+}
+
+// Listeners delivers the committed entries of a node to several state machines, such as the controller and the
+// metadata loader of the broker running in the same process. Each one is called in order
+type Listeners []Listener
+
+func (l Listeners) HandleCommit(entries []Entry) {
+	for _, listener := range l {
+		listener.HandleCommit(entries)
+	}
+}
+
+func (l Listeners) HandleLoadSnapshot(snapshot Snapshot) {
+	for _, listener := range l {
+		listener.HandleLoadSnapshot(snapshot)
+	}
+}
+
+func (l Listeners) HandleLeaderChange(leader LeaderAndEpoch) {
+	for _, listener := range l {
+		listener.HandleLeaderChange(leader)
+	}
+}
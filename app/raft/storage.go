@@ -0,0 +1,260 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The files of a metadata log dir
+const (
+	quorumStateFile = "quorum-state"
+	entriesFile     = "entries.log"
+	snapshotFile    = "snapshot.json"
+)
+
+var ErrStorage = errors.New("metadata log storage failure")
+
+// quorumState is what a node remembers of its epoch across restarts, like the quorum-state file of KRaft, so that
+// it never votes twice in an epoch
+type quorumState struct {
+	LeaderEpoch int32 `json:"leaderEpoch"`
+	VotedId     int32 `json:"votedId"`
+}
+
+// Storage keeps the quorum state, the entries and the latest snapshot of a node in its metadata log dir, so that
+// they survive a restart. The entries are written a line each and synced before the node acts on them
+type Storage struct {
+	dir  string
+	file *os.File
+	// The position of every entry in the file and the end of the last one
+	positions []int64
+	size      int64
+
+	// The state found when the storage was opened, the node starts from it
+	state    quorumState
+	snapshot *Snapshot
+	entries  []Entry
+}
+
+// OpenStorage reads the state persisted in dir, creating the dir on the first start. The incomplete entry a crash
+// may leave at the end of the file is dropped
+func OpenStorage(dir string) (*Storage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: failed to create %s: %w", ErrStorage, dir, err)
+	}
+
+	s := &Storage{dir: dir, state: quorumState{LeaderEpoch: 0, VotedId: NO_LEADER}}
+	if err := readJsonFile(filepath.Join(dir, quorumStateFile), &s.state); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	snapshot := Snapshot{}
+	switch err := readJsonFile(filepath.Join(dir, snapshotFile), &snapshot); {
+	case err == nil:
+		s.snapshot = &snapshot
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	path := filepath.Join(dir, entriesFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to open %s: %w", ErrStorage, path, err)
+	}
+	s.file = file
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%w: failed to read %s: %w", ErrStorage, path, err)
+	}
+
+	// The entries before the snapshot are left when the node stopped right after writing it
+	nextOffset := int64(0)
+	if s.snapshot != nil {
+		nextOffset = s.snapshot.Id.EndOffset
+	}
+	complete := true
+	for position := 0; position < len(content); {
+		end := bytes.IndexByte(content[position:], '\n')
+		entry := Entry{}
+		if end < 0 || json.Unmarshal(content[position:position+end], &entry) != nil || entry.Offset > nextOffset {
+			complete = false
+			break
+		}
+
+		if entry.Offset == nextOffset {
+			s.entries = append(s.entries, entry)
+			s.positions = append(s.positions, int64(position))
+			nextOffset++
+		} else {
+			complete = false
+		}
+		position += end + 1
+		s.size = int64(position)
+	}
+
+	if !complete {
+		if err := s.rewriteEntries(s.entries); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Storage) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// saveQuorumState replaces the quorum state when the epoch or the vote of the node changed
+func (s *Storage) saveQuorumState(epoch int32, votedId int32) error {
+	state := quorumState{LeaderEpoch: epoch, VotedId: votedId}
+	if s == nil || state == s.state {
+		return nil
+	}
+
+	if err := writeJsonFile(filepath.Join(s.dir, quorumStateFile), state); err != nil {
+		return err
+	}
+	s.state = state
+	return nil
+}
+
+func (s *Storage) saveSnapshot(snapshot Snapshot) error {
+	if s == nil {
+		return nil
+	}
+	return writeJsonFile(filepath.Join(s.dir, snapshotFile), snapshot)
+}
+
+func (s *Storage) appendEntries(entries []Entry) error {
+	if s == nil || len(entries) == 0 {
+		return nil
+	}
+
+	buffer := &bytes.Buffer{}
+	positions := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("%w: failed to encode the entry at offset %d: %w", ErrStorage, entry.Offset, err)
+		}
+		positions = append(positions, s.size+int64(buffer.Len()))
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+	}
+
+	_, err := s.file.WriteAt(buffer.Bytes(), s.size)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// What was written is not trusted, the next write replaces it
+		s.file.Truncate(s.size)
+		return fmt.Errorf("%w: failed to append to %s: %w", ErrStorage, s.file.Name(), err)
+	}
+
+	s.positions = append(s.positions, positions...)
+	s.size += int64(buffer.Len())
+	return nil
+}
+
+// truncateEntries keeps the first count entries of the file
+func (s *Storage) truncateEntries(count int) error {
+	if s == nil || count >= len(s.positions) {
+		return nil
+	}
+
+	size := s.positions[count]
+	err := s.file.Truncate(size)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to truncate %s: %w", ErrStorage, s.file.Name(), err)
+	}
+
+	s.positions = s.positions[:count]
+	s.size = size
+	return nil
+}
+
+// rewriteEntries replaces the file with the entries, once a snapshot covers the ones before them
+func (s *Storage) rewriteEntries(entries []Entry) error {
+	if s == nil {
+		return nil
+	}
+
+	path := filepath.Join(s.dir, entriesFile)
+	temporary := path + ".tmp"
+	file, err := os.OpenFile(temporary, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("%w: failed to create %s: %w", ErrStorage, temporary, err)
+	}
+
+	previous, positions, size := s.file, s.positions, s.size
+	s.file, s.positions, s.size = file, nil, 0
+	if err := s.appendEntries(entries); err != nil {
+		file.Close()
+		s.file, s.positions, s.size = previous, positions, size
+		return err
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		file.Close()
+		s.file, s.positions, s.size = previous, positions, size
+		return fmt.Errorf("%w: failed to replace %s: %w", ErrStorage, path, err)
+	}
+
+	previous.Close()
+	return nil
+}
+
+func readJsonFile(path string, value any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read %s: %w", ErrStorage, path, err)
+	}
+	if err := json.Unmarshal(content, value); err != nil {
+		return fmt.Errorf("%w: failed to read %s: %w", ErrStorage, path, err)
+	}
+	return nil
+}
+
+// writeJsonFile writes value to a temporary file renamed over path, so that a crash never leaves it half written
+func writeJsonFile(path string, value any) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encode %s: %w", ErrStorage, path, err)
+	}
+
+	temporary := path + ".tmp"
+	file, err := os.Create(temporary)
+	if err != nil {
+		return fmt.Errorf("%w: failed to create %s: %w", ErrStorage, temporary, err)
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporary, path)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to write %s: %w", ErrStorage, path, err)
+	}
+	return nil
+}
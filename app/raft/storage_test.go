@@ -0,0 +1,113 @@
+package raft
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newStoredNode(t *testing.T, dir string, now time.Time) (*Node, *testListener) {
+	t.Helper()
+
+	storage, err := OpenStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	config := Config{
+		NodeId:          1,
+		DirectoryId:     directoryId(1),
+		Voters:          StaticVoters(1),
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+		Storage:         storage,
+	}
+	listener := &testListener{}
+	return NewNode(config, NewMemoryTransport(func() time.Time { return now }).Endpoint(1), listener, now), listener
+}
+
+func TestNodeRestartsFromStorage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "__cluster_metadata-0")
+	now := time.UnixMilli(1_000_000)
+
+	node, listener := newStoredNode(t, dir, now)
+	node.Poll(now)
+	for _, record := range []string{"a", "b", "c"} {
+		if _, err := node.Append(node.LeaderAndEpoch().Epoch, [][]byte{[]byte(record)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node.Poll(now)
+	if err := node.CreateSnapshot(node.HighWatermark(), []byte("a,b,c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Append(node.LeaderAndEpoch().Epoch, [][]byte{[]byte("d")}); err != nil {
+		t.Fatal(err)
+	}
+	node.Poll(now)
+	if got := listener.Committed(); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("committed mismatch: got %v", got)
+	}
+	epoch, endOffset := node.LeaderAndEpoch().Epoch, node.LogEndOffset()
+
+	// The node stopped while appending an entry
+	file, err := os.OpenFile(filepath.Join(dir, entriesFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"Offset":`)
+	file.Close()
+
+	restarted, listener := newStoredNode(t, dir, now)
+	if got := restarted.LogEndOffset(); got != endOffset {
+		t.Errorf("expected the log to end at %d, got %d", endOffset, got)
+	}
+	if got := restarted.LogStartOffset(); got != 4 {
+		t.Errorf("expected the log to start after the snapshot at 4, got %d", got)
+	}
+
+	// The node elects itself in the next epoch, and replays the snapshot and the entries after it
+	restarted.Poll(now)
+	if got := restarted.LeaderAndEpoch(); got.LeaderId != 1 || got.Epoch != epoch+1 {
+		t.Errorf("expected node 1 to lead epoch %d, got %+v", epoch+1, got)
+	}
+	if got := listener.Committed(); !reflect.DeepEqual(got, []string{"a,b,c", "d"}) {
+		t.Errorf("committed mismatch: got %v", got)
+	}
+}
+
+func TestNodeRemembersItsVote(t *testing.T) {
+	dir := t.TempDir()
+	now := time.UnixMilli(1_000_000)
+
+	storage, err := OpenStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{NodeId: 2, DirectoryId: directoryId(2), Voters: StaticVoters(1, 2, 3), ElectionTimeout: time.Second, Storage: storage}
+	node := NewNode(config, NewMemoryTransport(func() time.Time { return now }).Endpoint(2), &testListener{}, now)
+
+	request := VoteRequest{CandidateEpoch: 5, CandidateId: 1, CandidateDirectoryId: directoryId(1), LastOffsetEpoch: 0, LastOffset: 0}
+	if response := node.HandleVote(request, now); !response.VoteGranted {
+		t.Fatalf("expected the vote to be granted, got %+v", response)
+	}
+	storage.Close()
+
+	// After a restart, the node does not vote for another candidate of the same epoch
+	storage, err = OpenStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	config.Storage = storage
+	node = NewNode(config, NewMemoryTransport(func() time.Time { return now }).Endpoint(2), &testListener{}, now)
+
+	request.CandidateId, request.CandidateDirectoryId = 3, directoryId(3)
+	if response := node.HandleVote(request, now); response.VoteGranted || response.LeaderEpoch != 5 {
+		t.Errorf("expected no second vote in epoch 5, got %+v", response)
+	}
+}
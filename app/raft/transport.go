@@ -0,0 +1,119 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrUnreachable = errors.New("node is unreachable")
+
+// Transport sends the quorum requests of a node to the other nodes and waits for their responses
+type Transport interface {
+	Vote(destination int32, request VoteRequest) (VoteResponse, error)
+	BeginQuorumEpoch(destination int32, request BeginQuorumEpochRequest) (BeginQuorumEpochResponse, error)
+	EndQuorumEpoch(destination int32, request EndQuorumEpochRequest) (EndQuorumEpochResponse, error)
+	Fetch(destination int32, request FetchRequest) (FetchResponse, error)
+	FetchSnapshot(destination int32, request FetchSnapshotRequest) (FetchSnapshotResponse, error)
+}
+
+// MemoryTransport connects nodes running in the same process, so that a whole quorum can run in tests.
+// Disconnecting a node drops every request to or from it, like a network partition
+type MemoryTransport struct {
+	now func() time.Time
+
+	mutex        sync.Mutex
+	nodes        map[int32]*Node
+	disconnected map[int32]bool
+}
+
+func NewMemoryTransport(now func() time.Time) *MemoryTransport {
+	return &MemoryTransport{
+		now:          now,
+		nodes:        make(map[int32]*Node),
+		disconnected: make(map[int32]bool),
+	}
+}
+
+// Endpoint is the transport of the node source
+func (t *MemoryTransport) Endpoint(source int32) Transport {
+	return &memoryEndpoint{transport: t, source: source}
+}
+
+func (t *MemoryTransport) Register(node *Node) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nodes[node.NodeId()] = node
+}
+
+func (t *MemoryTransport) Disconnect(nodeId int32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.disconnected[nodeId] = true
+}
+
+func (t *MemoryTransport) Connect(nodeId int32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.disconnected, nodeId)
+}
+
+func (t *MemoryTransport) route(source int32, destination int32) (*Node, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	node, ok := t.nodes[destination]
+	if !ok || t.disconnected[source] || t.disconnected[destination] {
+		return nil, fmt.Errorf("%w: %d -> %d", ErrUnreachable, source, destination)
+	}
+	return node, nil
+}
+
+type memoryEndpoint struct {
+	transport *MemoryTransport
+	source    int32
+}
+
+func (e *memoryEndpoint) Vote(destination int32, request VoteRequest) (VoteResponse, error) {
+	node, err := e.transport.route(e.source, destination)
+	if err != nil {
+		return VoteResponse{}, err
+	}
+	return node.HandleVote(request, e.transport.now()), nil
+}
+
+func (e *memoryEndpoint) BeginQuorumEpoch(destination int32, request BeginQuorumEpochRequest) (BeginQuorumEpochResponse, error) {
+	node, err := e.transport.route(e.source, destination)
+	if err != nil {
+		return BeginQuorumEpochResponse{}, err
+	}
+	return node.HandleBeginQuorumEpoch(request, e.transport.now()), nil
+}
+
+func (e *memoryEndpoint) EndQuorumEpoch(destination int32, request EndQuorumEpochRequest) (EndQuorumEpochResponse, error) {
+	node, err := e.transport.route(e.source, destination)
+	if err != nil {
+		return EndQuorumEpochResponse{}, err
+	}
+	return node.HandleEndQuorumEpoch(request, e.transport.now()), nil
+}
+
+func (e *memoryEndpoint) Fetch(destination int32, request FetchRequest) (FetchResponse, error) {
+	node, err := e.transport.route(e.source, destination)
+	if err != nil {
+		return FetchResponse{}, err
+	}
+	return node.HandleFetch(request, e.transport.now()), nil
+}
+
+func (e *memoryEndpoint) FetchSnapshot(destination int32, request FetchSnapshotRequest) (FetchSnapshotResponse, error) {
+	node, err := e.transport.route(e.source, destination)
+	if err != nil {
+		return FetchSnapshotResponse{}, err
+	}
+	return node.HandleFetchSnapshot(request), nil
+}
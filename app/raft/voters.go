@@ -0,0 +1,220 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ZERO_DIRECTORY_ID is the directory id of the statically configured voters, which are only known by their node id
+const ZERO_DIRECTORY_ID = "00000000-0000-0000-0000-000000000000"
+
+var (
+	ErrInvalidVoterKey = errors.New("invalid voter key")
+	ErrDuplicateVoter  = errors.New("duplicate voter")
+	ErrVoterNotFound   = errors.New("voter not found")
+	// ErrLastVoter is returned when removing the only voter, the quorum could not elect a leader anymore
+	ErrLastVoter = errors.New("cannot remove the last voter")
+	// ErrVoterChangePending is returned while the previous change of the voter set is not committed, or while the
+	// leader has not committed anything in its epoch yet
+	ErrVoterChangePending = errors.New("a voter change is pending")
+	// ErrVoterNotCaughtUp is returned when the observer to add as a voter does not replicate the log of the leader
+	ErrVoterNotCaughtUp = errors.New("the replica has not caught up with the leader")
+)
+
+// Endpoint is a listener of a voter
+type Endpoint struct {
+	Name string
+	Host string
+	Port uint16
+}
+
+// Voter is a member of the voter set. The directory id names the metadata log directory of the voter, so that a node
+// that lost its disk, and the entries it acknowledged with it, is not taken for the voter it was
+type Voter struct {
+	Id          int32
+	DirectoryId string
+	Endpoints   []Endpoint
+}
+
+// StaticVoters are voters configured by node id only, like controller.quorum.voters
+func StaticVoters(ids ...int32) []Voter {
+	voters := make([]Voter, 0, len(ids))
+	for _, id := range ids {
+		voters = append(voters, Voter{Id: id, DirectoryId: ZERO_DIRECTORY_ID})
+	}
+	return voters
+}
+
+// matches tells if the replica is this voter, statically configured voters match any directory
+func (v Voter) matches(replicaId int32, directoryId string) bool {
+	return v.Id == replicaId && (v.DirectoryId == ZERO_DIRECTORY_ID || v.DirectoryId == directoryId)
+}
+
+// QuorumInfo is the state of the quorum as its leader sees it, the answer to DescribeQuorum
+type QuorumInfo struct {
+	LeaderId      int32
+	LeaderEpoch   int32
+	HighWatermark int64
+	Voters        []ReplicaState
+	Observers     []ReplicaState
+	// The endpoints of the voters, by voter id
+	Endpoints map[int32][]Endpoint
+}
+
+// DescribeQuorum reports the progress of the voters and observers, the difference between their log end offset and
+// the high watermark is their lag. Only the leader knows it
+func (n *Node) DescribeQuorum(now time.Time) (QuorumInfo, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.role != LEADER {
+		return QuorumInfo{}, ErrNotLeader
+	}
+
+	info := QuorumInfo{
+		LeaderId:      n.config.NodeId,
+		LeaderEpoch:   n.epoch,
+		HighWatermark: n.highWatermark,
+		Voters:        []ReplicaState{},
+		Observers:     []ReplicaState{},
+		Endpoints:     make(map[int32][]Endpoint),
+	}
+
+	voters := n.voters()
+	for _, voter := range voters {
+		info.Endpoints[voter.Id] = voter.Endpoints
+
+		switch replica, ok := n.replicas[voter.Id]; {
+		case voter.Id == n.config.NodeId:
+			info.Voters = append(info.Voters, ReplicaState{
+				ReplicaId:        n.config.NodeId,
+				DirectoryId:      n.config.DirectoryId,
+				LogEndOffset:     n.log.EndOffset(),
+				LastFetchTime:    now,
+				LastCaughtUpTime: now,
+			})
+		case ok:
+			info.Voters = append(info.Voters, *replica)
+		default:
+			info.Voters = append(info.Voters, ReplicaState{ReplicaId: voter.Id, DirectoryId: voter.DirectoryId, LogEndOffset: -1})
+		}
+	}
+
+	for _, replicaId := range slices.Sorted(maps.Keys(n.replicas)) {
+		if !containsVoter(voters, replicaId) {
+			info.Observers = append(info.Observers, *n.replicas[replicaId])
+		}
+	}
+
+	return info, nil
+}
+
+// AddVoter makes an observer a voter. It must fetch from the leader with the directory id of the voter and have
+// caught up with the high watermark, so that it does not hold back the commits of the quorum.
+// The new voter set applies as soon as it is appended, the next change waits for it to be committed
+func (n *Node) AddVoter(voter Voter, now time.Time) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if err := n.checkVoterChange(); err != nil {
+		return err
+	}
+
+	voters := n.voters()
+	if voter.DirectoryId == "" || voter.DirectoryId == ZERO_DIRECTORY_ID {
+		return fmt.Errorf("%w: voter %d must have a directory id", ErrInvalidVoterKey, voter.Id)
+	}
+	if containsVoter(voters, voter.Id) {
+		return fmt.Errorf("%w: %d is already a voter", ErrDuplicateVoter, voter.Id)
+	}
+
+	replica, ok := n.replicas[voter.Id]
+	if !ok || replica.DirectoryId != voter.DirectoryId || replica.LogEndOffset < n.highWatermark ||
+		now.Sub(replica.LastFetchTime) > n.config.FetchTimeout {
+		return fmt.Errorf("%w: replica %d with directory %s", ErrVoterNotCaughtUp, voter.Id, voter.DirectoryId)
+	}
+
+	if _, err := n.log.appendVoters(n.epoch, append(slices.Clone(voters), voter)); err != nil {
+		n.fail(err)
+		return err
+	}
+	n.maybeAdvanceHighWatermark()
+	return nil
+}
+
+// RemoveVoter makes a voter an observer. A leader that removes itself keeps leading until the new voter set is
+// committed, then resigns
+func (n *Node) RemoveVoter(voterId int32, directoryId string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if err := n.checkVoterChange(); err != nil {
+		return err
+	}
+
+	voters := n.voters()
+	index := slices.IndexFunc(voters, func(voter Voter) bool {
+		return voter.matches(voterId, directoryId)
+	})
+	if index < 0 {
+		return fmt.Errorf("%w: %d with directory %s", ErrVoterNotFound, voterId, directoryId)
+	}
+	if len(voters) == 1 {
+		return fmt.Errorf("%w: %d", ErrLastVoter, voterId)
+	}
+
+	if _, err := n.log.appendVoters(n.epoch, slices.Delete(slices.Clone(voters), index, index+1)); err != nil {
+		n.fail(err)
+		return err
+	}
+	n.maybeAdvanceHighWatermark()
+	return nil
+}
+
+// checkVoterChange allows one change of the voter set at a time, so that the majorities of the old and new sets
+// always overlap
+func (n *Node) checkVoterChange() error {
+	if n.role != LEADER {
+		return fmt.Errorf("%w: node %d is %s in epoch %d", ErrNotLeader, n.config.NodeId, n.role, n.epoch)
+	}
+
+	if _, offset := n.log.Voters(); offset >= n.highWatermark || n.highWatermark <= n.epochStartOffset {
+		return ErrVoterChangePending
+	}
+	return nil
+}
+
+// voters is the latest voter set of the log, or the static voters of the config
+// Voters is the latest voter set of the log, the transport reaches the voters at their endpoints
+func (n *Node) Voters() []Voter {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.voters()
+}
+
+func (n *Node) voters() []Voter {
+	if voters, _ := n.log.Voters(); voters != nil {
+		return voters
+	}
+	return n.config.Voters
+}
+
+func (n *Node) isVoter(replicaId int32, directoryId string) bool {
+	return slices.ContainsFunc(n.voters(), func(voter Voter) bool {
+		return voter.matches(replicaId, directoryId)
+	})
+}
+
+func (n *Node) majority() int {
+	return len(n.voters())/2 + 1
+}
+
+func containsVoter(voters []Voter, id int32) bool {
+	return slices.ContainsFunc(voters, func(voter Voter) bool {
+		return voter.Id == id
+	})
+}
@@ -0,0 +1,151 @@
+package raft
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func replicaIds(replicas []ReplicaState) []int32 {
+	ids := []int32{}
+	for _, replica := range replicas {
+		ids = append(ids, replica.ReplicaId)
+	}
+	return ids
+}
+
+func TestDescribeQuorum(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1, 2}, 3)
+	cluster.poll(5 * time.Second)
+
+	leader := cluster.leader(1, 2)
+	cluster.append(leader, "a", "b")
+	cluster.poll(time.Second)
+
+	info, err := leader.DescribeQuorum(cluster.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.LeaderId != leader.NodeId() || info.HighWatermark != leader.LogEndOffset() {
+		t.Errorf("expected leader %d at high watermark %d, got %+v", leader.NodeId(), leader.LogEndOffset(), info)
+	}
+	if got := replicaIds(info.Voters); !reflect.DeepEqual(got, []int32{1, 2}) {
+		t.Errorf("expected voters [1 2], got %v", got)
+	}
+	if got := replicaIds(info.Observers); !reflect.DeepEqual(got, []int32{3}) {
+		t.Fatalf("expected observers [3], got %v", got)
+	}
+
+	observer := info.Observers[0]
+	if observer.DirectoryId != directoryId(3) || observer.LogEndOffset != info.HighWatermark || observer.LastCaughtUpTime.IsZero() {
+		t.Errorf("expected the observer to have caught up, got %+v", observer)
+	}
+
+	follower := cluster.nodes[3-leader.NodeId()]
+	if _, err := follower.DescribeQuorum(cluster.now); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("expected ErrNotLeader from a follower, got %v", err)
+	}
+}
+
+func TestAddAndRemoveVoters(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1}, 2, 3)
+	cluster.poll(time.Second)
+
+	leader := cluster.leader(1)
+	cluster.append(leader, "a")
+	cluster.poll(time.Second)
+
+	if err := leader.RemoveVoter(1, directoryId(1)); !errors.Is(err, ErrLastVoter) {
+		t.Errorf("expected ErrLastVoter, got %v", err)
+	}
+	if err := leader.AddVoter(Voter{Id: 2, DirectoryId: directoryId(2)}, cluster.now); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.AddVoter(Voter{Id: 3, DirectoryId: directoryId(3)}, cluster.now); !errors.Is(err, ErrVoterChangePending) {
+		t.Errorf("expected ErrVoterChangePending before the first change is committed, got %v", err)
+	}
+	cluster.poll(time.Second)
+
+	tests := []struct {
+		name  string
+		voter Voter
+		want  error
+	}{
+		{"Voter already in the set", Voter{Id: 2, DirectoryId: directoryId(2)}, ErrDuplicateVoter},
+		{"Missing directory id", Voter{Id: 3, DirectoryId: ZERO_DIRECTORY_ID}, ErrInvalidVoterKey},
+		{"Another directory", Voter{Id: 3, DirectoryId: directoryId(4)}, ErrVoterNotCaughtUp},
+		{"Unknown replica", Voter{Id: 9, DirectoryId: directoryId(9)}, ErrVoterNotCaughtUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := leader.AddVoter(tt.voter, cluster.now); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if err := leader.AddVoter(Voter{Id: 3, DirectoryId: directoryId(3)}, cluster.now); err != nil {
+		t.Fatal(err)
+	}
+	cluster.poll(time.Second)
+
+	info, err := leader.DescribeQuorum(cluster.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replicaIds(info.Voters); !reflect.DeepEqual(got, []int32{1, 2, 3}) || len(info.Observers) != 0 {
+		t.Fatalf("expected voters [1 2 3] and no observers, got %v and %v", got, replicaIds(info.Observers))
+	}
+
+	if err := leader.RemoveVoter(7, directoryId(7)); !errors.Is(err, ErrVoterNotFound) {
+		t.Errorf("expected ErrVoterNotFound, got %v", err)
+	}
+
+	// The leader removes itself, it resigns once the change is committed and follows the next leader as an observer
+	if err := leader.RemoveVoter(1, directoryId(1)); err != nil {
+		t.Fatal(err)
+	}
+	cluster.poll(10 * time.Second)
+
+	newLeader := cluster.leader(2, 3)
+	if role := leader.Role(); role != FOLLOWER {
+		t.Errorf("the removed leader must follow the new one, it is %s", role)
+	}
+
+	cluster.append(newLeader, "b")
+	cluster.poll(time.Second)
+
+	for nodeId, listener := range cluster.listeners {
+		if got := listener.Committed(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("node %d committed %v", nodeId, got)
+		}
+	}
+}
+
+func TestAddedVoterCountsForTheMajority(t *testing.T) {
+	cluster := newTestCluster(t, []int32{1}, 2)
+	cluster.poll(time.Second)
+
+	leader := cluster.leader(1)
+	if err := leader.AddVoter(Voter{Id: 2, DirectoryId: directoryId(2)}, cluster.now); err != nil {
+		t.Fatal(err)
+	}
+	cluster.poll(time.Second)
+
+	cluster.transport.Disconnect(2)
+	highWatermark := leader.HighWatermark()
+	cluster.append(leader, "a")
+	cluster.poll(time.Second)
+
+	if leader.HighWatermark() != highWatermark {
+		t.Errorf("the entry must wait for the new voter")
+	}
+	if err := leader.RemoveVoter(1, directoryId(1)); err != nil {
+		t.Errorf("the static voter must match any directory, got %v", err)
+	}
+	if err := leader.RemoveVoter(2, directoryId(2)); !errors.Is(err, ErrVoterChangePending) {
+		t.Errorf("expected ErrVoterChangePending, got %v", err)
+	}
+}
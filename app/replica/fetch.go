@@ -0,0 +1,254 @@
+package replica
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/purgatory"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// PartitionFetch is the fetch of a partition from FetchOffset. CurrentLeaderEpoch is the leader epoch the client
+// knows and LastFetchedEpoch the epoch of the last batch it has, -1 when it does not know them
+type PartitionFetch struct {
+	storage.TopicPartition
+	TopicId            string
+	CurrentLeaderEpoch int32
+	LastFetchedEpoch   int32
+	FetchOffset        int64
+	MaxBytes           int
+}
+
+// The isolation levels of the fetches of consumers: READ_COMMITTED consumers only read up to the last stable offset
+const (
+	READ_UNCOMMITTED int8 = 0
+	READ_COMMITTED   int8 = 1
+)
+
+// FetchRequest is a fetch from a consumer, whose ReplicaId is -1, or from the follower ReplicaId. The response waits
+// up to MaxWait for MinBytes, and holds at most MaxBytes, except for the first batch which is always returned whole
+type FetchRequest struct {
+	ReplicaId      int32
+	MaxWait        time.Duration
+	MinBytes       int
+	MaxBytes       int
+	IsolationLevel int8
+	Partitions     []PartitionFetch
+}
+
+// EpochEndOffset is where the log of the leader ends an epoch
+type EpochEndOffset struct {
+	Epoch     int32
+	EndOffset int64
+}
+
+// FetchResult is the outcome of the fetch of a partition. DivergingEpoch is set instead of the records when the log
+// of the client does not end its last fetched epoch where the leader does, the client truncates its log before
+// fetching again. READ_COMMITTED fetches get the aborted transactions of the records, for the others it is nil
+type FetchResult struct {
+	Err                 error
+	HighWatermark       int64
+	LastStableOffset    int64
+	LogStartOffset      int64
+	DivergingEpoch      *EpochEndOffset
+	AbortedTransactions []storage.AbortedTransaction
+	Records             []byte
+}
+
+// FetchMessages answers a fetch with the records of the partitions the broker leads: consumers read up to the high
+// watermark, or up to the last stable offset for READ_COMMITTED, followers up to the log end offset, and their fetch
+// offset moves their replica forward. Without
+// MinBytes to return, the response waits for new records until MaxWait elapsed
+func (m *Manager) FetchMessages(request FetchRequest, respond func(map[storage.TopicPartition]FetchResult)) {
+	if request.ReplicaId >= 0 {
+		m.updateFollowerFetches(request)
+
+		// The records sent to a throttled follower count against the throttled rate of the leader
+		respondToFollower := respond
+		respond = func(results map[storage.TopicPartition]FetchResult) {
+			m.recordLeaderThrottled(results)
+			respondToFollower(results)
+		}
+	}
+
+	results, size, failed := m.readPartitions(request)
+	if request.MaxWait <= 0 || size >= request.MinBytes || failed {
+		respond(results)
+		return
+	}
+
+	keys := make([]string, 0, len(request.Partitions))
+	for _, partition := range request.Partitions {
+		keys = append(keys, partition.TopicPartition.String())
+	}
+
+	operation := purgatory.NewDelayedOperation(request.MaxWait, func() bool {
+		results, size, failed = m.readPartitions(request)
+		return size >= request.MinBytes || failed
+	}, func() {
+		respond(results)
+	}, func() {
+		results, _, _ = m.readPartitions(request)
+		respond(results)
+	})
+	m.fetches.TryCompleteElseWatch(operation, keys)
+}
+
+// LastOffsetForLeaderEpoch answers OffsetForLeaderEpoch for a partition the broker leads: the largest epoch up to
+// leaderEpoch in its log and the offset where that epoch ended. currentLeaderEpoch is checked like in a fetch
+func (m *Manager) LastOffsetForLeaderEpoch(topicPartition storage.TopicPartition, currentLeaderEpoch int32, leaderEpoch int32) (EpochEndOffset, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	hosted, err := m.leaderPartition(topicPartition)
+	if err == nil {
+		err = checkLeaderEpoch(currentLeaderEpoch, hosted.leaderEpoch)
+	}
+	if err != nil {
+		return EpochEndOffset{}, err
+	}
+
+	epoch, endOffset, err := m.logs.EndOffsetForEpoch(topicPartition, leaderEpoch)
+	if err != nil {
+		return EpochEndOffset{}, err
+	}
+	return EpochEndOffset{Epoch: epoch, EndOffset: endOffset}, nil
+}
+
+// updateFollowerFetches records the fetch offsets of a follower, they are its log end offsets. The produces waiting
+// for the high watermark complete, and a follower that joined the ISR is reported to the controller
+func (m *Manager) updateFollowerFetches(request FetchRequest) {
+	now := m.now()
+	moved := []storage.TopicPartition{}
+	joined := false
+
+	m.mutex.RLock()
+	for _, partition := range request.Partitions {
+		hosted, err := m.leaderPartition(partition.TopicPartition)
+		if err != nil || checkLeaderEpoch(partition.CurrentLeaderEpoch, hosted.leaderEpoch) != nil {
+			continue
+		}
+
+		isrSize := len(hosted.leader.Isr())
+		highWatermarkMoved, err := hosted.leader.UpdateFollowerFetch(request.ReplicaId, partition.FetchOffset, now)
+		if err != nil {
+			continue
+		}
+		if highWatermarkMoved {
+			moved = append(moved, partition.TopicPartition)
+		}
+		joined = joined || len(hosted.leader.Isr()) > isrSize
+	}
+	m.mutex.RUnlock()
+
+	for _, topicPartition := range moved {
+		m.checkAndComplete(topicPartition)
+	}
+	if joined {
+		m.notifyIsrChanged()
+	}
+}
+
+// readPartitions reads the records of a fetch, it returns their size and whether a partition failed
+func (m *Manager) readPartitions(request FetchRequest) (map[storage.TopicPartition]FetchResult, int, bool) {
+	results := make(map[storage.TopicPartition]FetchResult, len(request.Partitions))
+	size := 0
+	failed := false
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, partition := range request.Partitions {
+		result, err := m.readPartition(request.ReplicaId, request.IsolationLevel, partition, request.MaxBytes-size, size == 0)
+		if err != nil {
+			// A follower behind the log start offset restarts its log there
+			logStartOffset := int64(-1)
+			if errors.Is(err, ErrOffsetOutOfRange) {
+				logStartOffset = result.LogStartOffset
+			}
+			results[partition.TopicPartition] = FetchResult{Err: err, HighWatermark: -1, LastStableOffset: -1, LogStartOffset: logStartOffset, Records: []byte{}}
+			failed = true
+			continue
+		}
+
+		results[partition.TopicPartition] = result
+		size += len(result.Records)
+	}
+
+	return results, size, failed
+}
+
+// readPartition reads the records of a partition, at most maxBytes of them unless first is set, when the first batch
+// is returned whatever its size. The caller holds the read lock
+func (m *Manager) readPartition(replicaId int32, isolationLevel int8, partition PartitionFetch, maxBytes int, first bool) (FetchResult, error) {
+	hosted, err := m.leaderPartition(partition.TopicPartition)
+	if err != nil {
+		return FetchResult{}, err
+	}
+	if err := checkLeaderEpoch(partition.CurrentLeaderEpoch, hosted.leaderEpoch); err != nil {
+		return FetchResult{}, err
+	}
+
+	logStartOffset, err := m.logs.LogStartOffset(partition.TopicPartition)
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	highWatermark := hosted.leader.HighWatermark()
+	lastStableOffset, err := m.logs.LastStableOffset(partition.TopicPartition, highWatermark)
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	result := FetchResult{HighWatermark: highWatermark, LastStableOffset: lastStableOffset, LogStartOffset: logStartOffset, Records: []byte{}}
+	maxBytes = min(maxBytes, partition.MaxBytes)
+	if maxBytes <= 0 && !first {
+		return result, nil
+	}
+
+	// A client whose last fetched epoch ends before its fetch offset, or which the leader never had, diverged
+	if partition.LastFetchedEpoch >= 0 {
+		epoch, endOffset, err := m.logs.EndOffsetForEpoch(partition.TopicPartition, partition.LastFetchedEpoch)
+		if err != nil {
+			return FetchResult{}, err
+		}
+		if epoch != storage.UNDEFINED_EPOCH && (epoch < partition.LastFetchedEpoch || endOffset < partition.FetchOffset) {
+			result.DivergingEpoch = &EpochEndOffset{Epoch: epoch, EndOffset: endOffset}
+			return result, nil
+		}
+	}
+
+	// A follower catching up on a throttled partition waits while the leader sent too much
+	if replicaId >= 0 && m.shouldLeaderThrottle(partition.TopicPartition, hosted, replicaId) {
+		return result, nil
+	}
+
+	// Consumers only see the records every in-sync replica has, and READ_COMMITTED ones only the complete transactions
+	maxOffset := int64(-1)
+	if replicaId < 0 {
+		maxOffset = result.HighWatermark
+		if isolationLevel == READ_COMMITTED {
+			maxOffset = result.LastStableOffset
+		}
+		if partition.FetchOffset > maxOffset && partition.FetchOffset <= hosted.leader.LogEndOffset() {
+			return result, nil
+		}
+	}
+
+	records, err := m.logs.ReadUpTo(partition.TopicPartition, partition.FetchOffset, max(maxBytes, 0), maxOffset)
+	if errors.Is(err, storage.ErrOffsetOutOfRange) {
+		return result, ErrOffsetOutOfRange
+	}
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	result.Records = records
+	if replicaId < 0 && isolationLevel == READ_COMMITTED {
+		result.AbortedTransactions, err = m.logs.AbortedTransactions(partition.TopicPartition, partition.FetchOffset, maxOffset)
+		if err != nil {
+			return FetchResult{}, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package replica
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// fetcher copies the records of the partitions a leader shares with the broker, like a replica fetcher thread of
+// Kafka. Its partitions are guarded by the lock of the manager
+type fetcher struct {
+	leaderId   int32
+	partitions map[storage.TopicPartition]bool
+	// wake is signalled when a partition is added, stop is closed once the fetcher has no partition left
+	wake chan struct{}
+	stop chan struct{}
+}
+
+// startFollowing adds a partition to the fetcher of its leader, starting the fetcher when needed. The caller holds
+// the write lock
+func (m *Manager) startFollowing(topicPartition storage.TopicPartition, hosted *hostedPartition) {
+	f, ok := m.fetchers[hosted.leaderId]
+	if !ok {
+		f = &fetcher{
+			leaderId:   hosted.leaderId,
+			partitions: make(map[storage.TopicPartition]bool),
+			wake:       make(chan struct{}, 1),
+			stop:       make(chan struct{}),
+		}
+		m.fetchers[hosted.leaderId] = f
+
+		m.stopped.Add(1)
+		go func() {
+			defer m.stopped.Done()
+			m.runFetcher(f)
+		}()
+	}
+
+	f.partitions[topicPartition] = true
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stopFollowing removes a partition from the fetcher of its leader, which stops once it has no partition left. The
+// caller holds the write lock
+func (m *Manager) stopFollowing(topicPartition storage.TopicPartition, hosted *hostedPartition) {
+	f, ok := m.fetchers[hosted.leaderId]
+	if !ok || !f.partitions[topicPartition] {
+		return
+	}
+
+	delete(f.partitions, topicPartition)
+	if len(f.partitions) == 0 {
+		close(f.stop)
+		delete(m.fetchers, hosted.leaderId)
+	}
+}
+
+// followedPartition is a partition of a fetcher, with the leader epoch it was fetched in and whether it is in the
+// throttled replicas of the follower
+type followedPartition struct {
+	hosted      *hostedPartition
+	leaderEpoch int32
+	throttled   bool
+}
+
+// runFetcher fetches the partitions of a fetcher from their leader from their log end offset, and appends the records
+// to their log, until the fetcher or the manager stops. A fetch that failed is retried after FetchBackoff
+func (m *Manager) runFetcher(f *fetcher) {
+	for {
+		request, followed, throttled := m.buildFetch(f)
+		if len(followed) == 0 {
+			// The throttled partitions are fetched again once the rate of the follower went down
+			var retry <-chan time.Time
+			if throttled {
+				retry = time.After(m.config.FetchBackoff)
+			}
+			select {
+			case <-m.stop:
+				return
+			case <-f.stop:
+				return
+			case <-f.wake:
+				continue
+			case <-retry:
+				continue
+			}
+		}
+
+		results, err := m.transport.Fetch(f.leaderId, request)
+		if err != nil {
+			m.logger.Warn("Failed to fetch from the leader", "leader", f.leaderId, "error", err)
+		}
+
+		backoff := time.Duration(0)
+		if err != nil || m.processFetch(f, followed, results) {
+			backoff = m.config.FetchBackoff
+		}
+		select {
+		case <-m.stop:
+			return
+		case <-f.stop:
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// buildFetch is the next fetch of a fetcher, from the log end offset of each of its partitions. The throttled
+// partitions are left out while the follower fetched too much, it then returns true
+func (m *Manager) buildFetch(f *fetcher) (FetchRequest, map[storage.TopicPartition]followedPartition, bool) {
+	request := FetchRequest{
+		ReplicaId:  m.config.NodeId,
+		MaxWait:    m.config.FetchMaxWait,
+		MinBytes:   1,
+		MaxBytes:   m.config.FetchMaxBytes,
+		Partitions: []PartitionFetch{},
+	}
+	followed := map[storage.TopicPartition]followedPartition{}
+	throttled := false
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for topicPartition := range f.partitions {
+		hosted, ok := m.partitions[topicPartition]
+		if !ok || hosted.leader != nil || hosted.leaderId != f.leaderId {
+			continue
+		}
+		if m.shouldFollowerThrottle(topicPartition) {
+			throttled = true
+			continue
+		}
+
+		logEndOffset, err := m.logs.LogEndOffset(topicPartition)
+		if err != nil {
+			continue
+		}
+		epochs, err := m.logs.LeaderEpochs(topicPartition)
+		if err != nil {
+			continue
+		}
+
+		request.Partitions = append(request.Partitions, PartitionFetch{
+			TopicPartition:     topicPartition,
+			TopicId:            hosted.topicId,
+			CurrentLeaderEpoch: hosted.leaderEpoch,
+			LastFetchedEpoch:   epochs.LatestEpoch(),
+			FetchOffset:        logEndOffset,
+			MaxBytes:           m.config.FetchMaxBytes,
+		})
+		followed[topicPartition] = followedPartition{hosted: hosted, leaderEpoch: hosted.leaderEpoch, throttled: m.isThrottled(topicPartition, FOLLOWER_THROTTLED_REPLICAS)}
+	}
+
+	return request, followed, throttled
+}
+
+// processFetch appends the records a leader returned to the logs of the partitions still following it in the same
+// leader epoch, and learns their high watermark. A partition whose log diverged from the leader's is truncated
+// instead, and fetched again from its new log end offset. It returns true when every partition failed
+func (m *Manager) processFetch(f *fetcher, followed map[storage.TopicPartition]followedPartition, results map[storage.TopicPartition]FetchResult) bool {
+	failed := 0
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for topicPartition, partition := range followed {
+		result, ok := results[topicPartition]
+		if !ok || partition.hosted.leader != nil || partition.hosted.leaderId != f.leaderId || partition.hosted.leaderEpoch != partition.leaderEpoch {
+			continue
+		}
+
+		if errors.Is(result.Err, ErrOffsetOutOfRange) && result.LogStartOffset >= 0 {
+			if err := m.resetToLogStart(topicPartition, partition.hosted, result.LogStartOffset); err == nil {
+				continue
+			}
+		}
+		if result.Err != nil {
+			m.logger.Warn("Failed to fetch a partition from the leader", "partition", topicPartition.String(), "leader", f.leaderId, "error", result.Err)
+			failed++
+			continue
+		}
+
+		if result.DivergingEpoch != nil {
+			if err := m.truncate(topicPartition, partition.hosted, *result.DivergingEpoch); err != nil {
+				m.logger.Error("Failed to truncate the log diverging from the leader", "partition", topicPartition.String(), "leader", f.leaderId, "error", err)
+				failed++
+			}
+			continue
+		}
+
+		info, err := m.logs.AppendAsFollower(topicPartition, result.Records)
+		if err != nil {
+			m.logger.Error("Failed to append the records of the leader", "partition", topicPartition.String(), "leader", f.leaderId, "error", err)
+			failed++
+			continue
+		}
+
+		// The follower cannot have committed records it does not have yet
+		partition.hosted.highWatermark.Store(min(result.HighWatermark, info.LastOffset+1))
+		if partition.throttled {
+			m.followerQuota.Record(len(result.Records), m.now())
+		}
+	}
+
+	return failed > 0 && failed == len(followed)
+}
+
+// resetToLogStart restarts the empty log of a follower that fell behind the log start offset of its leader, whose
+// retention deleted the records the follower was about to fetch. It fails when the follower is not behind
+func (m *Manager) resetToLogStart(topicPartition storage.TopicPartition, hosted *hostedPartition, logStartOffset int64) error {
+	logEndOffset, err := m.logs.LogEndOffset(topicPartition)
+	if err != nil {
+		return err
+	}
+	if logEndOffset >= logStartOffset {
+		return ErrOffsetOutOfRange
+	}
+	if err := m.logs.TruncateFullyAndStartAt(topicPartition, logStartOffset); err != nil {
+		return err
+	}
+	hosted.highWatermark.Store(max(hosted.highWatermark.Load(), logStartOffset))
+
+	m.logger.Info("Restarted the log behind the log start offset of the leader", "partition", topicPartition.String(), "leader", hosted.leaderId, "log_start_offset", logStartOffset)
+	return nil
+}
+
+// truncate removes the records of a follower past the end of the epoch its leader has, from the diverging epoch of a
+// fetch. When their histories differ further back, the next fetch sends the epoch the log now ends with and the leader
+// answers with another diverging epoch
+func (m *Manager) truncate(topicPartition storage.TopicPartition, hosted *hostedPartition, diverging EpochEndOffset) error {
+	epochs, err := m.logs.LeaderEpochs(topicPartition)
+	if err != nil {
+		return err
+	}
+	logEndOffset, err := m.logs.LogEndOffset(topicPartition)
+	if err != nil {
+		return err
+	}
+
+	offset, _ := TruncationOffset(diverging.Epoch, diverging.EndOffset, epochs, logEndOffset, hosted.highWatermark.Load())
+	if offset >= logEndOffset {
+		return nil
+	}
+	if err := m.logs.TruncateTo(topicPartition, offset); err != nil {
+		return err
+	}
+	hosted.highWatermark.Store(min(hosted.highWatermark.Load(), offset))
+
+	m.logger.Info("Truncated the log diverging from the leader", "partition", topicPartition.String(), "leader_epoch", diverging.Epoch, "leader_end_offset", diverging.EndOffset, "truncation_offset", offset)
+	return nil
+}
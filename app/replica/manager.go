@@ -0,0 +1,417 @@
+package replica
+
+import (
+	"cmp"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/purgatory"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+var (
+	// ErrNotLeaderOrFollower is returned for a partition the broker does not lead, to the clients that must find its
+	// leader again, or does not replicate at all
+	ErrNotLeaderOrFollower     = errors.New("not the leader or a follower of the partition")
+	ErrUnknownTopicOrPartition = errors.New("unknown topic or partition")
+	// ErrFencedLeaderEpoch and ErrUnknownLeaderEpoch reject the fetches of clients that know an older, or a newer,
+	// leader epoch than the broker
+	ErrFencedLeaderEpoch  = errors.New("fenced leader epoch")
+	ErrUnknownLeaderEpoch = errors.New("unknown leader epoch")
+	// ErrRequestTimedOut answers the acks=-1 produce requests whose records did not reach the ISR before their timeout
+	ErrRequestTimedOut = errors.New("request timed out")
+)
+
+// Transport sends the requests of the replicas of a broker: the fetches of its followers to the leaders of their
+// partitions, and the ISR changes of the partitions it leads to the active controller
+type Transport interface {
+	Fetch(leaderId int32, request FetchRequest) (map[storage.TopicPartition]FetchResult, error)
+	AlterPartition(change controller.IsrChange) (int32, error)
+}
+
+// Config holds the settings of the replicas of a broker
+type Config struct {
+	NodeId int32
+	// Followers leave the ISR once they have not caught up for MaxLag, replica.lag.time.max.ms
+	MaxLag time.Duration
+	// The followers fetch at most FetchMaxBytes per partition, and the leader holds their fetch up to FetchMaxWait
+	// while it has no new records. A fetch that failed is retried after FetchBackoff
+	FetchMaxWait  time.Duration
+	FetchMaxBytes int
+	FetchBackoff  time.Duration
+	// The bytes of the throttled replicas are measured over QuotaSamples windows of QuotaWindow, see
+	// replication.quota.window.num and replication.quota.window.size.seconds
+	QuotaSamples int
+	QuotaWindow  time.Duration
+	// The logs delete their old segments every RetentionCheckInterval, log.retention.check.interval.ms. Zero never
+	// deletes them
+	RetentionCheckInterval time.Duration
+	// The logs forget the producers without transaction whose last batch is ProducerIdExpiration old, checking them
+	// every ProducerIdExpirationCheckInterval, see producer.id.expiration.ms. Zero never forgets them
+	ProducerIdExpiration              time.Duration
+	ProducerIdExpirationCheckInterval time.Duration
+}
+
+// hostedPartition is a partition with a replica on the broker, which leads it or follows its leader
+type hostedPartition struct {
+	topicId     string
+	leaderId    int32
+	leaderEpoch int32
+	// The replication state while the broker leads the partition, nil on a follower
+	leader *Partition
+	// When the leader last sent an ISR change, zero when none is in flight, and the partition epoch the controller
+	// answered with. The next change waits for the image of that epoch, or for MaxLag when it never comes
+	isrChangeSentAt time.Time
+	isrChangeEpoch  int32
+	// The high watermark a follower learned from its leader, where it starts when it becomes the leader
+	highWatermark atomic.Int64
+}
+
+// Manager runs the replicas of the partitions assigned to the broker, like the replica manager of Kafka: it makes
+// them leaders or followers as the metadata image changes, appends the produced records, answers the fetches of the
+// consumers and followers, and reports the ISR changes of the partitions it leads to the controller. The responses
+// that wait for the followers, or for new records, are parked in its purgatories
+type Manager struct {
+	config    Config
+	logs      *storage.LogManager
+	configs   *config.Store
+	transport Transport
+	logger    *slog.Logger
+	now       func() time.Time
+
+	produces *purgatory.Purgatory
+	fetches  *purgatory.Purgatory
+
+	// The bytes sent to the throttled followers of the partitions the broker leads, and fetched by its throttled
+	// followers
+	leaderQuota   *quota.ReplicationQuota
+	followerQuota *quota.ReplicationQuota
+
+	// Appends and reads hold the read lock, the changes of the leadership of the partitions the write lock
+	mutex      sync.RWMutex
+	image      *metadata.Image
+	partitions map[storage.TopicPartition]*hostedPartition
+	fetchers   map[int32]*fetcher
+
+	isrChanged chan struct{}
+	stop       chan struct{}
+	stopped    sync.WaitGroup
+}
+
+// NewManager starts the replicas of a broker, which has none until ApplyImage assigns them. Shutdown stops them
+func NewManager(managerConfig Config, logs *storage.LogManager, configs *config.Store, transport Transport, logger *slog.Logger) *Manager {
+	m := &Manager{
+		config:        managerConfig,
+		logs:          logs,
+		configs:       configs,
+		transport:     transport,
+		logger:        logger,
+		now:           time.Now,
+		produces:      purgatory.New("Produce", purgatory.DEFAULT_PURGE_INTERVAL),
+		fetches:       purgatory.New("Fetch", purgatory.DEFAULT_PURGE_INTERVAL),
+		leaderQuota:   quota.NewReplicationQuota(managerConfig.QuotaSamples, managerConfig.QuotaWindow),
+		followerQuota: quota.NewReplicationQuota(managerConfig.QuotaSamples, managerConfig.QuotaWindow),
+		image:         metadata.NewImage(),
+		partitions:    make(map[storage.TopicPartition]*hostedPartition),
+		fetchers:      make(map[int32]*fetcher),
+		isrChanged:    make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+	}
+
+	m.stopped.Add(1)
+	go func() {
+		defer m.stopped.Done()
+		m.maintainIsr()
+	}()
+
+	if managerConfig.RetentionCheckInterval > 0 {
+		m.stopped.Add(1)
+		go func() {
+			defer m.stopped.Done()
+			m.runRetention()
+		}()
+	}
+
+	if managerConfig.ProducerIdExpirationCheckInterval > 0 {
+		m.stopped.Add(1)
+		go func() {
+			defer m.stopped.Done()
+			m.runProducerExpiration()
+		}()
+	}
+
+	return m
+}
+
+// Purgatories returns the purgatories of the produces waiting for their acks and of the fetches waiting for data
+func (m *Manager) Purgatories() []*purgatory.Purgatory {
+	return []*purgatory.Purgatory{m.produces, m.fetches}
+}
+
+// Shutdown stops the followers, the ISR changes and the retention, and drops the responses still parked
+func (m *Manager) Shutdown() {
+	close(m.stop)
+	m.stopped.Wait()
+
+	m.produces.Shutdown()
+	m.fetches.Shutdown()
+}
+
+// ApplyImage makes the broker the leader or a follower of the partitions the image assigns to it, it is subscribed
+// to the metadata loader. The log of a new replica is created in the log dirs, the replicas the image no longer
+// assigns to the broker stop, their log is kept
+func (m *Manager) ApplyImage(image *metadata.Image) {
+	now := m.now()
+	changed := []storage.TopicPartition{}
+
+	m.mutex.Lock()
+	m.image = image
+	assigned := map[storage.TopicPartition]bool{}
+	for _, name := range image.TopicNames() {
+		topic, _ := image.Topic(name)
+		for index, partition := range topic.Partitions {
+			if !slices.Contains(partition.Replicas, m.config.NodeId) {
+				continue
+			}
+
+			topicPartition := storage.TopicPartition{Topic: name, Partition: index}
+			assigned[topicPartition] = true
+			if m.applyPartition(topicPartition, topic.Id, partition, now) {
+				changed = append(changed, topicPartition)
+			}
+		}
+	}
+
+	for topicPartition, hosted := range m.partitions {
+		if !assigned[topicPartition] {
+			m.stopFollowing(topicPartition, hosted)
+			delete(m.partitions, topicPartition)
+			changed = append(changed, topicPartition)
+			m.logger.Info("Stopped the replica of a partition", "partition", topicPartition.String())
+		}
+	}
+	m.mutex.Unlock()
+
+	// The parked responses of the partitions whose leadership changed, or whose high watermark moved, complete
+	for _, topicPartition := range changed {
+		m.checkAndComplete(topicPartition)
+	}
+}
+
+// applyPartition applies the state of a partition assigned to the broker, the caller holds the write lock. It
+// returns true when the leadership or the high watermark changed
+func (m *Manager) applyPartition(topicPartition storage.TopicPartition, topicId string, partition *metadata.PartitionImage, now time.Time) bool {
+	hosted, ok := m.partitions[topicPartition]
+	if ok && hosted.topicId == topicId && hosted.leaderId == partition.Leader && hosted.leaderEpoch == partition.LeaderEpoch {
+		if hosted.leader == nil {
+			return false
+		}
+		if partition.PartitionEpoch >= hosted.isrChangeEpoch {
+			hosted.isrChangeSentAt = time.Time{}
+		}
+
+		// The leader applies the min.insync.replicas of the topic, and the replicas and the ISR the controller committed
+		moved := hosted.leader.UpdateMinInsyncReplicas(m.minInsyncReplicas(topicPartition.Topic))
+		if partition.PartitionEpoch <= hosted.leader.PartitionEpoch() {
+			return moved
+		}
+		moved = hosted.leader.UpdateReplicas(partition.Replicas) || moved
+		return hosted.leader.UpdateIsr(partition.Isr, partition.PartitionEpoch) || moved
+	}
+
+	if !ok {
+		hosted = &hostedPartition{}
+		m.partitions[topicPartition] = hosted
+	}
+	m.stopFollowing(topicPartition, hosted)
+
+	hosted.topicId, hosted.leaderId, hosted.leaderEpoch = topicId, partition.Leader, partition.LeaderEpoch
+	hosted.leader, hosted.isrChangeSentAt = nil, time.Time{}
+
+	if _, err := m.logs.GetOrCreateLog(topicPartition); err != nil {
+		m.logger.Error("Failed to create the log of a replica", "partition", topicPartition.String(), "error", err)
+		return true
+	}
+	logEndOffset, err := m.logs.LogEndOffset(topicPartition)
+	if err != nil {
+		m.logger.Error("Failed to read the log of a replica", "partition", topicPartition.String(), "error", err)
+		return true
+	}
+
+	switch partition.Leader {
+	case m.config.NodeId:
+		hosted.leader = NewLeaderPartition(topicPartition.Topic, topicPartition.Partition, m.config.NodeId, partition.LeaderEpoch, partition.PartitionEpoch, partition.Replicas, partition.Isr, logEndOffset, hosted.highWatermark.Load(), m.minInsyncReplicas(topicPartition.Topic), now)
+		// A leader without followers in its ISR has every record committed right away, unless min.insync.replicas is larger
+		hosted.leader.UpdateLogEndOffset(logEndOffset)
+		m.logger.Info("Became the leader of a partition", "partition", topicPartition.String(), "leader_epoch", partition.LeaderEpoch, "log_end_offset", logEndOffset)
+	case metadata.NO_LEADER:
+		m.logger.Info("The partition of a replica has no leader", "partition", topicPartition.String())
+	default:
+		m.startFollowing(topicPartition, hosted)
+		m.logger.Info("Became a follower of a partition", "partition", topicPartition.String(), "leader", partition.Leader, "leader_epoch", partition.LeaderEpoch, "log_end_offset", logEndOffset)
+	}
+
+	return true
+}
+
+// ActiveProducers answers DescribeProducers for a partition the broker leads, with the producers of its log
+func (m *Manager) ActiveProducers(topicPartition storage.TopicPartition) ([]storage.ProducerState, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, err := m.leaderPartition(topicPartition); err != nil {
+		return nil, err
+	}
+	return m.logs.ActiveProducers(topicPartition)
+}
+
+// InTransaction tells whether a producer has a transaction ongoing in a partition the broker leads, whose coordinator
+// then already verified that the partition is part of it
+func (m *Manager) InTransaction(topicPartition storage.TopicPartition, producerId int64, producerEpoch int16) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, err := m.leaderPartition(topicPartition); err != nil {
+		return false
+	}
+	ongoing, err := m.logs.InTransaction(topicPartition, producerId, producerEpoch)
+	return err == nil && ongoing
+}
+
+// leaderPartition returns the state of a partition the broker leads. A partition the broker does not lead fails with
+// ErrNotLeaderOrFollower, or with ErrUnknownTopicOrPartition when the metadata does not know it. The caller holds the
+// read lock
+func (m *Manager) leaderPartition(topicPartition storage.TopicPartition) (*hostedPartition, error) {
+	if hosted, ok := m.partitions[topicPartition]; ok && hosted.leader != nil {
+		return hosted, nil
+	}
+
+	topic, ok := m.image.Topic(topicPartition.Topic)
+	if !ok {
+		return nil, ErrUnknownTopicOrPartition
+	}
+	if _, ok := topic.Partitions[topicPartition.Partition]; !ok {
+		return nil, ErrUnknownTopicOrPartition
+	}
+	return nil, ErrNotLeaderOrFollower
+}
+
+// minInsyncReplicas is the min.insync.replicas of a topic
+func (m *Manager) minInsyncReplicas(topic string) int {
+	minInsyncReplicas, _ := m.configs.Int64(config.Resource{Type: config.TOPIC, Name: topic}, "min.insync.replicas")
+	return int(minInsyncReplicas)
+}
+
+// checkAndComplete completes the parked responses of a partition that can now be answered, the caller must not hold
+// the lock as the operations take it
+func (m *Manager) checkAndComplete(topicPartition storage.TopicPartition) {
+	m.produces.CheckAndComplete(topicPartition.String())
+	m.fetches.CheckAndComplete(topicPartition.String())
+}
+
+// notifyIsrChanged wakes the ISR changes up, when a follower caught up and joined the ISR of its leader
+func (m *Manager) notifyIsrChanged() {
+	select {
+	case m.isrChanged <- struct{}{}:
+	default:
+	}
+}
+
+// maintainIsr sends the ISR changes of the partitions the broker leads to the controller: the followers that caught
+// up right away, the ones out of sync every MaxLag/2 like Kafka
+func (m *Manager) maintainIsr() {
+	ticker := time.NewTicker(max(m.config.MaxLag/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		case <-m.isrChanged:
+		}
+
+		m.sendIsrChanges()
+	}
+}
+
+// sendIsrChanges proposes the ISR of every partition whose ISR differs from the committed one, one change in flight
+// per partition. The ISR only changes once the image has the record of the controller
+func (m *Manager) sendIsrChanges() {
+	now := m.now()
+
+	m.mutex.Lock()
+	changes := []controller.IsrChange{}
+	for topicPartition, hosted := range m.partitions {
+		if hosted.leader == nil || (!hosted.isrChangeSentAt.IsZero() && now.Sub(hosted.isrChangeSentAt) < m.config.MaxLag) {
+			continue
+		}
+
+		isr, changed := hosted.leader.ProposedIsr(now, m.config.MaxLag)
+		if !changed {
+			continue
+		}
+
+		hosted.isrChangeSentAt = now
+		changes = append(changes, controller.IsrChange{
+			Topic:          topicPartition.Topic,
+			Partition:      topicPartition.Partition,
+			LeaderId:       m.config.NodeId,
+			LeaderEpoch:    hosted.leaderEpoch,
+			PartitionEpoch: hosted.leader.PartitionEpoch(),
+			Isr:            isr,
+		})
+	}
+	m.mutex.Unlock()
+
+	slices.SortFunc(changes, func(a, b controller.IsrChange) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+
+	for _, change := range changes {
+		partitionEpoch, err := m.transport.AlterPartition(change)
+		topicPartition := storage.TopicPartition{Topic: change.Topic, Partition: change.Partition}
+
+		m.mutex.Lock()
+		hosted, ok := m.partitions[topicPartition]
+		if ok && hosted.leader != nil && hosted.leaderEpoch == change.LeaderEpoch {
+			switch {
+			case err != nil:
+				// Proposed again at the next check, a stale change once the image has the new partition epoch
+				hosted.isrChangeSentAt = time.Time{}
+			case hosted.leader.PartitionEpoch() >= partitionEpoch:
+				hosted.isrChangeSentAt = time.Time{}
+			default:
+				hosted.isrChangeEpoch = partitionEpoch
+			}
+		}
+		m.mutex.Unlock()
+
+		if err != nil {
+			m.logger.Warn("Failed to change the ISR of a partition", "partition", topicPartition.String(), "isr", change.Isr, "error", err)
+			continue
+		}
+		m.logger.Info("Changed the ISR of a partition", "partition", topicPartition.String(), "isr", change.Isr, "partition_epoch", partitionEpoch)
+	}
+}
+
+// checkLeaderEpoch compares the leader epoch a client knows with the one of the broker, a client that does not know
+// it sends -1
+func checkLeaderEpoch(requestEpoch int32, leaderEpoch int32) error {
+	switch {
+	case requestEpoch < 0 || requestEpoch == leaderEpoch:
+		return nil
+	case requestEpoch < leaderEpoch:
+		return ErrFencedLeaderEpoch
+	default:
+		return ErrUnknownLeaderEpoch
+	}
+}
@@ -0,0 +1,468 @@
+package replica
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// newTestBatch returns a record batch header of records records, the log only reads the header
+func newTestBatch(records int32) []byte {
+	batch := make([]byte, 61)
+	binary.BigEndian.PutUint32(batch[8:], uint32(len(batch)-12))
+	batch[16] = 2 // Magic
+	binary.BigEndian.PutUint32(batch[23:], uint32(records-1))
+	copy(batch[43:57], bytes.Repeat([]byte{0xff}, 14)) // No producer
+	binary.BigEndian.PutUint32(batch[57:], uint32(records))
+	return batch
+}
+
+// testCluster connects the replica managers of brokers: their fetches go to the manager of the leader, and their ISR
+// changes are committed to the image of the cluster right away, like a controller would
+type testCluster struct {
+	t        *testing.T
+	mutex    sync.Mutex
+	image    *metadata.Image
+	managers map[int32]*Manager
+	// The followers fetch at most fetchMaxBytes per partition, the dynamic configs are loaded in every broker
+	fetchMaxBytes int
+	configs       map[config.Resource]map[string]string
+}
+
+func newTestCluster(t *testing.T, records ...metadata.Record) *testCluster {
+	image := metadata.NewImage()
+	for _, record := range records {
+		if err := image.Apply(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &testCluster{t: t, image: image, managers: make(map[int32]*Manager), fetchMaxBytes: 1 << 20}
+}
+
+func (c *testCluster) start(nodeId int32) *Manager {
+	return c.startWithLogs(nodeId, newTestLogs(c.t, nodeId))
+}
+
+func newTestLogs(t *testing.T, nodeId int32) *storage.LogManager {
+	logs, err := storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, nodeId, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logs.Close() })
+	return logs
+}
+
+// startWithLogs starts the replicas of a broker over logs that already have records
+func (c *testCluster) startWithLogs(nodeId int32, logs *storage.LogManager) *Manager {
+	c.mutex.Lock()
+	configs := config.NewStore(nodeId, map[string]string{})
+	configs.Load(c.configs)
+	c.mutex.Unlock()
+
+	manager := NewManager(Config{
+		NodeId:        nodeId,
+		MaxLag:        30 * time.Second,
+		FetchMaxWait:  50 * time.Millisecond,
+		FetchMaxBytes: c.fetchMaxBytes,
+		FetchBackoff:  10 * time.Millisecond,
+		QuotaSamples:  11,
+		QuotaWindow:   time.Second,
+	}, logs, configs, &testTransport{cluster: c}, slog.New(slog.DiscardHandler))
+	c.t.Cleanup(func() { c.stop(nodeId) })
+
+	c.mutex.Lock()
+	c.managers[nodeId] = manager
+	image := c.image
+	c.mutex.Unlock()
+
+	manager.ApplyImage(image)
+	return manager
+}
+
+// stop shuts the replicas of a broker down, like a crash: it no longer fetches nor receives the images
+func (c *testCluster) stop(nodeId int32) {
+	c.mutex.Lock()
+	manager, ok := c.managers[nodeId]
+	delete(c.managers, nodeId)
+	c.mutex.Unlock()
+
+	if ok {
+		manager.Shutdown()
+	}
+}
+
+// apply commits a record and publishes the new image to every manager
+func (c *testCluster) apply(record metadata.Record) *metadata.Image {
+	c.mutex.Lock()
+	image := c.image.Clone()
+	if err := image.Apply(record); err != nil {
+		c.mutex.Unlock()
+		c.t.Error(err)
+		return nil
+	}
+	c.image = image
+	managers := []*Manager{}
+	for _, manager := range c.managers {
+		managers = append(managers, manager)
+	}
+	c.mutex.Unlock()
+
+	for _, manager := range managers {
+		manager.ApplyImage(image)
+	}
+	return image
+}
+
+// loadConfigs replaces the dynamic configs of every broker
+func (c *testCluster) loadConfigs(configs map[config.Resource]map[string]string) {
+	c.mutex.Lock()
+	c.configs = configs
+	managers := []*Manager{}
+	for _, manager := range c.managers {
+		managers = append(managers, manager)
+	}
+	c.mutex.Unlock()
+
+	for _, manager := range managers {
+		manager.configs.Load(configs)
+	}
+}
+
+type testTransport struct {
+	cluster *testCluster
+}
+
+func (t *testTransport) Fetch(leaderId int32, request FetchRequest) (map[storage.TopicPartition]FetchResult, error) {
+	t.cluster.mutex.Lock()
+	leader, ok := t.cluster.managers[leaderId]
+	t.cluster.mutex.Unlock()
+	if !ok {
+		return nil, errors.New("unknown broker")
+	}
+
+	results := make(chan map[storage.TopicPartition]FetchResult, 1)
+	leader.FetchMessages(request, func(response map[storage.TopicPartition]FetchResult) { results <- response })
+	return <-results, nil
+}
+
+func (t *testTransport) AlterPartition(change controller.IsrChange) (int32, error) {
+	t.cluster.mutex.Lock()
+	topic, _ := t.cluster.image.Topic(change.Topic)
+	partition := topic.Partitions[change.Partition]
+	if partition.LeaderEpoch != change.LeaderEpoch || partition.PartitionEpoch != change.PartitionEpoch {
+		t.cluster.mutex.Unlock()
+		return 0, controller.ErrInvalidUpdateVersion
+	}
+	t.cluster.mutex.Unlock()
+
+	image := t.cluster.apply(&metadata.PartitionChangeRecord{PartitionId: change.Partition, TopicId: topic.Id, Isr: change.Isr, Leader: metadata.NO_LEADER_CHANGE})
+	topic, _ = image.Topic(change.Topic)
+	return topic.Partitions[change.Partition].PartitionEpoch, nil
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func produce(manager *Manager, timeout time.Duration, requiredAcks int16, records map[storage.TopicPartition][]byte) map[storage.TopicPartition]AppendResult {
+	results := make(chan map[storage.TopicPartition]AppendResult, 1)
+	manager.AppendRecords(timeout, requiredAcks, records, func(response map[storage.TopicPartition]AppendResult) { results <- response })
+	return <-results
+}
+
+func fetch(manager *Manager, request FetchRequest) map[storage.TopicPartition]FetchResult {
+	results := make(chan map[storage.TopicPartition]FetchResult, 1)
+	manager.FetchMessages(request, func(response map[storage.TopicPartition]FetchResult) { results <- response })
+	return <-results
+}
+
+func TestFollowerCatchesUpAndJoinsTheIsr(t *testing.T) {
+	topicId := metadata.NewTopicId()
+	cluster := newTestCluster(t,
+		&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1}, Leader: 1},
+	)
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+
+	leader := cluster.start(1)
+	results := produce(leader, time.Second, ACKS_ALL, map[storage.TopicPartition][]byte{foo: newTestBatch(3)})
+	if want := (AppendResult{BaseOffset: 0, LogAppendTime: -1, LogStartOffset: 0}); results[foo] != want {
+		t.Fatalf("expected %+v, got %+v", want, results[foo])
+	}
+
+	// Once the follower starts, the next acks=-1 produce waits for it as it joins the ISR while catching up
+	follower := cluster.start(2)
+	waitFor(t, func() bool {
+		cluster.mutex.Lock()
+		defer cluster.mutex.Unlock()
+		topic, _ := cluster.image.Topic("foo")
+		return reflect.DeepEqual(topic.Partitions[0].Isr, []int32{1, 2})
+	})
+
+	results = produce(leader, time.Second, ACKS_ALL, map[storage.TopicPartition][]byte{foo: newTestBatch(2)})
+	if want := (AppendResult{BaseOffset: 3, LogAppendTime: -1, LogStartOffset: 0}); results[foo] != want {
+		t.Fatalf("expected %+v, got %+v", want, results[foo])
+	}
+	if logEndOffset, _ := follower.logs.LogEndOffset(foo); logEndOffset != 5 {
+		t.Errorf("expected the follower to have the records of the produce, its log end offset is %d", logEndOffset)
+	}
+
+	consumed := fetch(leader, FetchRequest{ReplicaId: -1, MinBytes: 1, MaxBytes: 1 << 20, Partitions: []PartitionFetch{{TopicPartition: foo, CurrentLeaderEpoch: -1, FetchOffset: 0, MaxBytes: 1 << 20}}})
+	if result := consumed[foo]; result.Err != nil || result.HighWatermark != 5 || len(result.Records) != 2*61 {
+		t.Errorf("expected both batches below the high watermark 5, got %+v", result)
+	}
+}
+
+func TestProduceWaitsForTheIsr(t *testing.T) {
+	topicId := metadata.NewTopicId()
+	cluster := newTestCluster(t,
+		&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1, 2}, Leader: 1},
+	)
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	leader := cluster.start(1)
+
+	results := produce(leader, 50*time.Millisecond, ACKS_ALL, map[storage.TopicPartition][]byte{foo: newTestBatch(1)})
+	if !errors.Is(results[foo].Err, ErrRequestTimedOut) {
+		t.Errorf("expected the produce to time out without the follower, got %v", results[foo].Err)
+	}
+
+	results = produce(leader, 0, 1, map[storage.TopicPartition][]byte{foo: newTestBatch(1)})
+	if results[foo].Err != nil || results[foo].BaseOffset != 1 {
+		t.Errorf("expected acks=1 to be answered after the append, got %+v", results[foo])
+	}
+
+	// Consumers do not see the records the follower does not have
+	consumed := fetch(leader, FetchRequest{ReplicaId: -1, MaxBytes: 1 << 20, Partitions: []PartitionFetch{{TopicPartition: foo, CurrentLeaderEpoch: -1, FetchOffset: 0, MaxBytes: 1 << 20}}})
+	if result := consumed[foo]; result.Err != nil || result.HighWatermark != 0 || len(result.Records) != 0 {
+		t.Errorf("expected no record below the high watermark 0, got %+v", result)
+	}
+}
+
+func TestEligibleLeaderReplicaHasTheCommittedRecords(t *testing.T) {
+	topicId := metadata.NewTopicId()
+	cluster := newTestCluster(t,
+		&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1, 2}, Leader: 1},
+	)
+	cluster.configs = map[config.Resource]map[string]string{{Type: config.TOPIC, Name: "foo"}: {"min.insync.replicas": "2"}}
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	consume := FetchRequest{ReplicaId: -1, MaxBytes: 1 << 20, Partitions: []PartitionFetch{{TopicPartition: foo, CurrentLeaderEpoch: -1, FetchOffset: 0, MaxBytes: 1 << 20}}}
+
+	leader := cluster.start(1)
+	followerLogs := newTestLogs(t, 2)
+	cluster.startWithLogs(2, followerLogs)
+	if results := produce(leader, time.Second, ACKS_ALL, map[storage.TopicPartition][]byte{foo: newTestBatch(3)}); results[foo].Err != nil {
+		t.Fatal(results[foo].Err)
+	}
+
+	// Broker 2 crashes and leaves the ISR, which falls below min.insync.replicas: it becomes an eligible leader replica
+	cluster.stop(2)
+	cluster.apply(&metadata.PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{1}, Leader: metadata.NO_LEADER_CHANGE, Elr: []int32{2}})
+
+	// The leader alone appends acks=1 records, but does not commit them
+	results := produce(leader, 0, 1, map[storage.TopicPartition][]byte{foo: newTestBatch(2)})
+	if results[foo].Err != nil || results[foo].BaseOffset != 3 {
+		t.Fatalf("expected the produce at offset 3, got %+v", results[foo])
+	}
+	if result := fetch(leader, consume)[foo]; result.Err != nil || result.HighWatermark != 3 {
+		t.Fatalf("expected the high watermark to stay at 3, got %d and %v", result.HighWatermark, result.Err)
+	}
+
+	// Broker 1 crashes in turn, the eligible leader replica is elected and has every record below the high watermark
+	cluster.stop(1)
+	cluster.apply(&metadata.PartitionChangeRecord{PartitionId: 0, TopicId: topicId, Isr: []int32{2}, Leader: 2, Elr: []int32{}, LastKnownElr: []int32{}})
+	cluster.startWithLogs(2, followerLogs)
+
+	if logEndOffset, _ := followerLogs.LogEndOffset(foo); logEndOffset != 3 {
+		t.Errorf("expected the new leader to end at the high watermark 3, got %d", logEndOffset)
+	}
+}
+
+func TestRequestsToOtherReplicas(t *testing.T) {
+	topicId := metadata.NewTopicId()
+	cluster := newTestCluster(t,
+		&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1, 2}, Leader: 2, LeaderEpoch: 3},
+		&metadata.PartitionRecord{PartitionId: 1, TopicId: topicId, Replicas: []int32{1}, Isr: []int32{1}, Leader: 1, LeaderEpoch: 3},
+	)
+	manager := cluster.start(1)
+
+	tests := []struct {
+		name           string
+		topicPartition storage.TopicPartition
+		leaderEpoch    int32
+		want           error
+	}{
+		{"Follower", storage.TopicPartition{Topic: "foo", Partition: 0}, -1, ErrNotLeaderOrFollower},
+		{"Unknown partition", storage.TopicPartition{Topic: "foo", Partition: 2}, -1, ErrUnknownTopicOrPartition},
+		{"Unknown topic", storage.TopicPartition{Topic: "bar", Partition: 0}, -1, ErrUnknownTopicOrPartition},
+		{"Fenced leader epoch", storage.TopicPartition{Topic: "foo", Partition: 1}, 2, ErrFencedLeaderEpoch},
+		{"Unknown leader epoch", storage.TopicPartition{Topic: "foo", Partition: 1}, 4, ErrUnknownLeaderEpoch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := fetch(manager, FetchRequest{ReplicaId: -1, MaxBytes: 1 << 20, Partitions: []PartitionFetch{{TopicPartition: tt.topicPartition, CurrentLeaderEpoch: tt.leaderEpoch, MaxBytes: 1 << 20}}})
+			if !errors.Is(results[tt.topicPartition].Err, tt.want) {
+				t.Errorf("expected the fetch to fail with %v, got %v", tt.want, results[tt.topicPartition].Err)
+			}
+
+			if tt.leaderEpoch >= 0 {
+				return
+			}
+			appended := produce(manager, 0, 1, map[storage.TopicPartition][]byte{tt.topicPartition: newTestBatch(1)})
+			if !errors.Is(appended[tt.topicPartition].Err, tt.want) {
+				t.Errorf("expected the produce to fail with %v, got %v", tt.want, appended[tt.topicPartition].Err)
+			}
+		})
+	}
+}
+
+func TestFollowerTruncatesTheDivergedLog(t *testing.T) {
+	topicId := metadata.NewTopicId()
+	cluster := newTestCluster(t,
+		&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1, 2}, Leader: 1, LeaderEpoch: 2},
+	)
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+
+	// Both replicas have the records of epoch 0, then broker 2 appended records in epoch 1 that broker 1 never got
+	leaderLogs, followerLogs := newTestLogs(t, 1), newTestLogs(t, 2)
+	for _, logs := range []*storage.LogManager{leaderLogs, followerLogs} {
+		logs.GetOrCreateLog(foo)
+		if _, err := logs.Append(foo, newTestBatch(3), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	followerLogs.Append(foo, newTestBatch(2), 1)
+
+	leader := cluster.startWithLogs(1, leaderLogs)
+	results := produce(leader, 0, 1, map[storage.TopicPartition][]byte{foo: newTestBatch(4)})
+	if results[foo].Err != nil || results[foo].BaseOffset != 3 {
+		t.Fatalf("expected the produce at offset 3, got %+v", results[foo])
+	}
+
+	// The leader ends epoch 0 at offset 3, where the follower truncates before fetching the records of epoch 2
+	cluster.startWithLogs(2, followerLogs)
+	waitFor(t, func() bool {
+		logEndOffset, _ := followerLogs.LogEndOffset(foo)
+		return logEndOffset == 7
+	})
+
+	epochs, _ := followerLogs.LeaderEpochs(foo)
+	if got, want := epochs.Entries(), []storage.EpochEntry{{Epoch: 0, StartOffset: 0}, {Epoch: 2, StartOffset: 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the follower to have the epochs of the leader %v, got %v", want, got)
+	}
+	if epoch, endOffset, _ := leaderLogs.EndOffsetForEpoch(foo, 1); epoch != 0 || endOffset != 3 {
+		t.Errorf("expected epoch 1 to end with epoch 0 at 3 on the leader, got epoch %d at %d", epoch, endOffset)
+	}
+}
+
+func TestFollowerRestartsAtTheLogStartOfTheLeader(t *testing.T) {
+	topicId := metadata.NewTopicId()
+	cluster := newTestCluster(t,
+		&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1}, Leader: 1},
+	)
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+
+	// Every batch of the leader has its own segment, the retention keeps the last one
+	leaderLogs := newTestLogs(t, 1)
+	logConfig := storage.DefaultLogConfig
+	logConfig.SegmentBytes = 61
+	logConfig.RetentionMs = -1
+	logConfig.RetentionBytes = 61
+	leaderLogs.UpdateConfigs(func(string) storage.LogConfig { return logConfig })
+
+	leader := cluster.startWithLogs(1, leaderLogs)
+	for _, records := range []int32{3, 2, 1} {
+		if results := produce(leader, 0, 1, map[storage.TopicPartition][]byte{foo: newTestBatch(records)}); results[foo].Err != nil {
+			t.Fatal(results[foo].Err)
+		}
+	}
+	leader.deleteOldSegments()
+	if logStartOffset, _ := leaderLogs.LogStartOffset(foo); logStartOffset != 5 {
+		t.Fatalf("expected the leader to start at 5 after the retention, got %d", logStartOffset)
+	}
+
+	// The fetch from offset 0 is out of range, the follower restarts its log where the leader's starts
+	follower := cluster.start(2)
+	waitFor(t, func() bool {
+		logEndOffset, _ := follower.logs.LogEndOffset(foo)
+		return logEndOffset == 6
+	})
+	if logStartOffset, _ := follower.logs.LogStartOffset(foo); logStartOffset != 5 {
+		t.Errorf("expected the follower to start at 5, got %d", logStartOffset)
+	}
+}
+
+func TestReplicationThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs map[config.Resource]map[string]string
+	}{
+		{"Leader", map[config.Resource]map[string]string{
+			{Type: config.BROKER, Name: "1"}:  {LEADER_THROTTLED_RATE: "1"},
+			{Type: config.TOPIC, Name: "foo"}: {LEADER_THROTTLED_REPLICAS: "0:1"},
+		}},
+		{"Follower", map[config.Resource]map[string]string{
+			{Type: config.BROKER, Name: "2"}:  {FOLLOWER_THROTTLED_RATE: "1"},
+			{Type: config.TOPIC, Name: "foo"}: {FOLLOWER_THROTTLED_REPLICAS: "*"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topicId := metadata.NewTopicId()
+			cluster := newTestCluster(t,
+				&metadata.TopicRecord{Name: "foo", TopicId: topicId},
+				&metadata.PartitionRecord{PartitionId: 0, TopicId: topicId, Replicas: []int32{1, 2}, Isr: []int32{1}, Leader: 1},
+			)
+			cluster.fetchMaxBytes = 61
+			cluster.configs = tt.configs
+			foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+
+			leader := cluster.start(1)
+			for range 3 {
+				produce(leader, 0, 1, map[storage.TopicPartition][]byte{foo: newTestBatch(3)})
+			}
+
+			// The first batch is fetched before the rate is measured, the next ones wait for it to go down
+			follower := cluster.start(2)
+			waitFor(t, func() bool {
+				logEndOffset, _ := follower.logs.LogEndOffset(foo)
+				return logEndOffset == 3
+			})
+			time.Sleep(200 * time.Millisecond)
+			if logEndOffset, _ := follower.logs.LogEndOffset(foo); logEndOffset != 3 {
+				t.Errorf("expected the throttled follower to stay at offset 3, got %d", logEndOffset)
+			}
+
+			// Without a throttled rate the follower catches up and joins the ISR
+			cluster.loadConfigs(nil)
+			waitFor(t, func() bool {
+				cluster.mutex.Lock()
+				defer cluster.mutex.Unlock()
+				topic, _ := cluster.image.Topic("foo")
+				return reflect.DeepEqual(topic.Partitions[0].Isr, []int32{1, 2})
+			})
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package replica
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownReplica = errors.New("replica is not assigned to the partition")
+	// ErrOffsetOutOfRange is returned for fetches beyond the log end offset of the leader
+	ErrOffsetOutOfRange = errors.New("fetch offset out of range")
+	// ErrNotEnoughReplicas rejects acks=-1 produce requests while the ISR is smaller than min.insync.replicas
+	ErrNotEnoughReplicas = errors.New("not enough in-sync replicas")
+	// ErrNotEnoughReplicasAfterAppend is returned when the ISR shrank below min.insync.replicas while a produce waited
+	ErrNotEnoughReplicasAfterAppend = errors.New("not enough in-sync replicas after append")
+)
+
+// The produce acks that wait for every in-sync replica
+const ACKS_ALL int16 = -1
+
+// followerState is what the leader knows about a follower from its fetch requests
+type followerState struct {
+	logEndOffset int64
+	// The last time the follower had fetched everything the leader had, it is out of sync once this is older than replica.lag.time.max.ms
+	lastCaughtUpTime time.Time
+	// The log end offset of the leader when the follower last fetched, and when that was
+	lastFetchLeaderLogEndOffset int64
+	lastFetchTime               time.Time
+}
+
+// Partition is the leader's view of the replication of a partition: the log end offsets of its followers, the ISR
+// and the high watermark, the offset up to which every in-sync replica has the records and consumers may read
+type Partition struct {
+	Topic  string
+	Index  int32
+	nodeId int32
+
+	mutex          sync.Mutex
+	leaderEpoch    int32
+	partitionEpoch int32
+	replicas       []int32
+	// The ISR committed by the controller in the partition epoch, and the followers that caught up since. Followers
+	// join right away, as the high watermark only gets safer when it waits for more replicas, but only leave once the
+	// controller committed it, so that a replica it may elect always has the records below the high watermark
+	isr           []int32
+	committedIsr  []int32
+	logEndOffset  int64
+	highWatermark int64
+	// The min.insync.replicas of the topic, the high watermark does not move while the ISR is smaller (KIP-966) so
+	// that the replicas that leave it in the meantime, the ELR, keep every committed record
+	minInsyncReplicas int
+	followers         map[int32]*followerState
+}
+
+// NewLeaderPartition makes nodeId the leader of a partition. Like Kafka, the followers of the ISR are considered
+// caught up when the leadership starts, so that they have replica.lag.time.max.ms to fetch before they are removed
+func NewLeaderPartition(topic string, index int32, nodeId int32, leaderEpoch int32, partitionEpoch int32, replicas []int32, isr []int32, logEndOffset int64, highWatermark int64, minInsyncReplicas int, now time.Time) *Partition {
+	partition := &Partition{
+		Topic:             topic,
+		Index:             index,
+		nodeId:            nodeId,
+		leaderEpoch:       leaderEpoch,
+		partitionEpoch:    partitionEpoch,
+		replicas:          slices.Clone(replicas),
+		isr:               slices.Clone(isr),
+		committedIsr:      slices.Clone(isr),
+		logEndOffset:      logEndOffset,
+		highWatermark:     min(highWatermark, logEndOffset),
+		minInsyncReplicas: minInsyncReplicas,
+		followers:         make(map[int32]*followerState),
+	}
+
+	for _, replicaId := range replicas {
+		if replicaId == nodeId {
+			continue
+		}
+
+		follower := &followerState{logEndOffset: -1}
+		if slices.Contains(isr, replicaId) {
+			follower.lastCaughtUpTime = now
+		}
+		partition.followers[replicaId] = follower
+	}
+
+	return partition
+}
+
+func (p *Partition) String() string {
+	return fmt.Sprintf("%s-%d", p.Topic, p.Index)
+}
+
+func (p *Partition) LeaderEpoch() int32 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.leaderEpoch
+}
+
+func (p *Partition) PartitionEpoch() int32 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.partitionEpoch
+}
+
+func (p *Partition) Isr() []int32 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return slices.Clone(p.isr)
+}
+
+func (p *Partition) HighWatermark() int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.highWatermark
+}
+
+func (p *Partition) LogEndOffset() int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.logEndOffset
+}
+
+// CheckAppend rejects acks=-1 produce requests while the ISR is smaller than min.insync.replicas, before anything is appended
+func (p *Partition) CheckAppend(requiredAcks int16, minInsyncReplicas int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if requiredAcks == ACKS_ALL && len(p.isr) < minInsyncReplicas {
+		return fmt.Errorf("%w: %s has %d in-sync replicas, min.insync.replicas is %d", ErrNotEnoughReplicas, p, len(p.isr), minInsyncReplicas)
+	}
+
+	return nil
+}
+
+// UpdateLogEndOffset records an append to the leader's log, it returns true when the high watermark moved,
+// which happens right away when the leader is the only in-sync replica and min.insync.replicas is 1. Concurrent appends may record their log end
+// offset out of order, the log end offset never moves backwards
+func (p *Partition) UpdateLogEndOffset(logEndOffset int64) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.logEndOffset = max(p.logEndOffset, logEndOffset)
+	return p.maybeIncrementHighWatermark()
+}
+
+// UpdateFollowerFetch records a fetch from a follower at fetchOffset, which is its log end offset. The follower joins the ISR
+// once it has caught up to the high watermark. It returns true when the high watermark moved
+func (p *Partition) UpdateFollowerFetch(replicaId int32, fetchOffset int64, now time.Time) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	follower, ok := p.followers[replicaId]
+	if !ok {
+		return false, fmt.Errorf("%w: replica %d of %s", ErrUnknownReplica, replicaId, p)
+	}
+
+	if fetchOffset > p.logEndOffset {
+		return false, fmt.Errorf("%w: replica %d fetched %s at %d, the log end offset is %d", ErrOffsetOutOfRange, replicaId, p, fetchOffset, p.logEndOffset)
+	}
+
+	// A follower that fetched everything the leader had at its previous fetch was caught up at that time
+	switch {
+	case fetchOffset >= p.logEndOffset:
+		follower.lastCaughtUpTime = laterOf(follower.lastCaughtUpTime, now)
+	case fetchOffset >= follower.lastFetchLeaderLogEndOffset:
+		follower.lastCaughtUpTime = laterOf(follower.lastCaughtUpTime, follower.lastFetchTime)
+	}
+
+	follower.logEndOffset = fetchOffset
+	follower.lastFetchLeaderLogEndOffset = p.logEndOffset
+	follower.lastFetchTime = now
+
+	if !slices.Contains(p.isr, replicaId) && fetchOffset >= p.highWatermark {
+		p.isr = append(p.isr, replicaId)
+	}
+
+	return p.maybeIncrementHighWatermark(), nil
+}
+
+// UpdateReplicas applies a change of the replicas of the partition, such as a reassignment: the replicas it adds are
+// tracked out of the ISR until they catch up, the replicas it removes leave the ISR. It returns true when the high
+// watermark moved, which happens when a removed replica held it back
+func (p *Partition) UpdateReplicas(replicas []int32) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, replicaId := range replicas {
+		if _, ok := p.followers[replicaId]; !ok && replicaId != p.nodeId {
+			p.followers[replicaId] = &followerState{logEndOffset: -1}
+		}
+	}
+	for replicaId := range p.followers {
+		if !slices.Contains(replicas, replicaId) {
+			delete(p.followers, replicaId)
+		}
+	}
+
+	isRemoved := func(replicaId int32) bool { return !slices.Contains(replicas, replicaId) }
+	p.replicas = slices.Clone(replicas)
+	p.isr = slices.DeleteFunc(p.isr, isRemoved)
+	p.committedIsr = slices.DeleteFunc(p.committedIsr, isRemoved)
+	return p.maybeIncrementHighWatermark()
+}
+
+// ProposedIsr returns the ISR the leader proposes to the controller, and true when it differs from the committed
+// one: the followers that caught up join it, the ones that have not caught up within maxLag (replica.lag.time.max.ms)
+// leave it
+func (p *Partition) ProposedIsr(now time.Time, maxLag time.Duration) ([]int32, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	isr := make([]int32, 0, len(p.isr))
+	for _, replicaId := range p.isr {
+		follower, ok := p.followers[replicaId]
+		if ok && now.Sub(follower.lastCaughtUpTime) > maxLag {
+			continue
+		}
+		isr = append(isr, replicaId)
+	}
+
+	changed := len(isr) != len(p.committedIsr) || slices.ContainsFunc(isr, func(replicaId int32) bool {
+		return !slices.Contains(p.committedIsr, replicaId)
+	})
+	return isr, changed
+}
+
+// UpdateIsr applies the ISR the controller committed in partitionEpoch, an older epoch is ignored. It returns true
+// when the high watermark moved, which happens once the replicas that lagged no longer hold it back
+func (p *Partition) UpdateIsr(isr []int32, partitionEpoch int32) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if partitionEpoch < p.partitionEpoch {
+		return false
+	}
+
+	p.partitionEpoch = partitionEpoch
+	p.isr = slices.Clone(isr)
+	p.committedIsr = slices.Clone(isr)
+	return p.maybeIncrementHighWatermark()
+}
+
+// UpdateMinInsyncReplicas applies a change of the min.insync.replicas of the topic, it returns true when the high
+// watermark moved, which happens once the ISR is large enough again
+func (p *Partition) UpdateMinInsyncReplicas(minInsyncReplicas int) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.minInsyncReplicas = minInsyncReplicas
+	return p.maybeIncrementHighWatermark()
+}
+
+// CheckEnoughReplicasReachOffset tells if an acks=-1 produce whose records end at requiredOffset can be answered:
+// it is done once the high watermark reached the offset, with ErrNotEnoughReplicasAfterAppend when the ISR shrank below
+// min.insync.replicas in the meantime
+func (p *Partition) CheckEnoughReplicasReachOffset(requiredOffset int64, minInsyncReplicas int) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.highWatermark < requiredOffset {
+		return false, nil
+	}
+
+	if len(p.isr) < minInsyncReplicas {
+		return true, fmt.Errorf("%w: %s has %d in-sync replicas, min.insync.replicas is %d", ErrNotEnoughReplicasAfterAppend, p, len(p.isr), minInsyncReplicas)
+	}
+
+	return true, nil
+}
+
+// maybeIncrementHighWatermark moves the high watermark to the smallest log end offset of the ISR, it never moves
+// backwards and stays while the ISR is smaller than min.insync.replicas
+func (p *Partition) maybeIncrementHighWatermark() bool {
+	if len(p.isr) < p.minInsyncReplicas {
+		return false
+	}
+
+	highWatermark := p.logEndOffset
+	for _, replicaId := range p.isr {
+		if follower, ok := p.followers[replicaId]; ok {
+			highWatermark = min(highWatermark, follower.logEndOffset)
+		}
+	}
+
+	if highWatermark <= p.highWatermark {
+		return false
+	}
+
+	p.highWatermark = highWatermark
+	return true
+}
+
+func laterOf(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
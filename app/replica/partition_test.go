@@ -0,0 +1,246 @@
+package replica
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHighWatermarkFollowsTheSlowestInSyncReplica(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	partition := NewLeaderPartition("foo", 0, 1, 5, 0, []int32{1, 2, 3}, []int32{1, 2, 3}, 0, 0, 1, now)
+
+	if partition.UpdateLogEndOffset(10) {
+		t.Errorf("the high watermark must wait for the followers")
+	}
+
+	tests := []struct {
+		name              string
+		replicaId         int32
+		fetchOffset       int64
+		wantMoved         bool
+		wantHighWatermark int64
+	}{
+		{"First follower halfway", 2, 5, false, 0},
+		{"Second follower halfway", 3, 4, true, 4},
+		{"First follower caught up", 2, 10, false, 4},
+		{"Second follower caught up", 3, 10, true, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moved, err := partition.UpdateFollowerFetch(tt.replicaId, tt.fetchOffset, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if moved != tt.wantMoved {
+				t.Errorf("expected moved to be %v", tt.wantMoved)
+			}
+			if got := partition.HighWatermark(); got != tt.wantHighWatermark {
+				t.Errorf("high watermark mismatch: got %d, want %d", got, tt.wantHighWatermark)
+			}
+		})
+	}
+}
+
+func TestSingleReplicaAdvancesOnAppend(t *testing.T) {
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1}, []int32{1}, 0, 0, 1, time.Now())
+
+	if !partition.UpdateLogEndOffset(3) || partition.HighWatermark() != 3 {
+		t.Errorf("expected the high watermark to follow the leader, got %d", partition.HighWatermark())
+	}
+}
+
+func TestIsrShrinksAndExpands(t *testing.T) {
+	start := time.UnixMilli(1_000_000)
+	maxLag := 30 * time.Second
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1, 2, 3}, []int32{1, 2, 3}, 0, 0, 1, start)
+	partition.UpdateLogEndOffset(10)
+
+	// Replica 2 keeps up while replica 3 stops fetching
+	if _, err := partition.UpdateFollowerFetch(2, 10, start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partition.UpdateFollowerFetch(3, 2, start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if isr, changed := partition.ProposedIsr(start.Add(maxLag), maxLag); changed {
+		t.Errorf("no replica lags yet, proposed %v", isr)
+	}
+
+	if _, err := partition.UpdateFollowerFetch(2, 10, start.Add(maxLag+time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	isr, changed := partition.ProposedIsr(start.Add(maxLag+2*time.Second), maxLag)
+	if !changed || !reflect.DeepEqual(isr, []int32{1, 2}) {
+		t.Errorf("expected replica 3 to be removed, got %v", isr)
+	}
+	// Replica 3 stays in the ISR until the controller committed its removal
+	if !reflect.DeepEqual(partition.Isr(), []int32{1, 2, 3}) || partition.HighWatermark() != 2 {
+		t.Errorf("the ISR must not shrink before the controller committed it, got %v", partition.Isr())
+	}
+
+	partition.UpdateIsr(isr, 1)
+	if !reflect.DeepEqual(partition.Isr(), []int32{1, 2}) {
+		t.Errorf("ISR mismatch: got %v", partition.Isr())
+	}
+	// Replica 3 no longer holds the high watermark back
+	if partition.HighWatermark() != 10 {
+		t.Errorf("expected the high watermark to reach 10, got %d", partition.HighWatermark())
+	}
+
+	// Below the high watermark replica 3 stays out of the ISR, it joins again once it caught up
+	if _, err := partition.UpdateFollowerFetch(3, 8, start.Add(maxLag+3*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(partition.Isr()) != 2 {
+		t.Errorf("replica 3 must not join before reaching the high watermark, ISR is %v", partition.Isr())
+	}
+
+	if _, err := partition.UpdateFollowerFetch(3, 10, start.Add(maxLag+4*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(partition.Isr(), []int32{1, 2, 3}) {
+		t.Errorf("expected replica 3 back in the ISR, got %v", partition.Isr())
+	}
+	if isr, changed := partition.ProposedIsr(start.Add(maxLag+4*time.Second), maxLag); !changed || !reflect.DeepEqual(isr, []int32{1, 2, 3}) {
+		t.Errorf("expected the leader to propose replica 3 back, got %v", isr)
+	}
+
+	// A committed ISR of an older partition epoch is ignored
+	if partition.UpdateIsr([]int32{1}, 0); !reflect.DeepEqual(partition.Isr(), []int32{1, 2, 3}) {
+		t.Errorf("a stale ISR must be ignored, got %v", partition.Isr())
+	}
+}
+
+func TestUpdateReplicas(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1, 2}, []int32{1, 2}, 0, 0, 1, now)
+	partition.UpdateLogEndOffset(10)
+	if _, err := partition.UpdateFollowerFetch(2, 5, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reassignment adds replica 3, which joins the ISR once it caught up
+	partition.UpdateReplicas([]int32{3, 1, 2})
+	if _, err := partition.UpdateFollowerFetch(3, 4, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := partition.Isr(); !reflect.DeepEqual(got, []int32{1, 2}) {
+		t.Errorf("replica 3 must not join the ISR before it caught up, got %v", got)
+	}
+	if _, err := partition.UpdateFollowerFetch(3, 10, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := partition.Isr(); !reflect.DeepEqual(got, []int32{1, 2, 3}) {
+		t.Errorf("replica 3 must join the ISR once caught up, got %v", got)
+	}
+
+	// Replica 2 held the high watermark back until the reassignment removed it
+	if !partition.UpdateReplicas([]int32{3, 1}) || partition.HighWatermark() != 10 {
+		t.Errorf("expected the high watermark to move to 10, got %d", partition.HighWatermark())
+	}
+	if got := partition.Isr(); !reflect.DeepEqual(got, []int32{1, 3}) {
+		t.Errorf("replica 2 must leave the ISR, got %v", got)
+	}
+	if _, err := partition.UpdateFollowerFetch(2, 10, now); !errors.Is(err, ErrUnknownReplica) {
+		t.Errorf("expected ErrUnknownReplica, got %v", err)
+	}
+}
+
+func TestFollowerCaughtUpAtItsPreviousFetch(t *testing.T) {
+	start := time.UnixMilli(1_000_000)
+	maxLag := 10 * time.Second
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1, 2}, []int32{1, 2}, 0, 0, 1, start)
+
+	// The leader keeps appending, so the follower is never at the log end offset when it fetches,
+	// but each fetch gets everything the leader had at the previous one
+	for i := int64(1); i <= 20; i++ {
+		partition.UpdateLogEndOffset(i * 10)
+		if _, err := partition.UpdateFollowerFetch(2, (i-1)*10, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if isr, changed := partition.ProposedIsr(start.Add(21*time.Second), maxLag); changed {
+		t.Errorf("a follower keeping up with the previous fetch must stay in sync, proposed %v", isr)
+	}
+}
+
+func TestUpdateFollowerFetchErrors(t *testing.T) {
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1, 2}, []int32{1, 2}, 5, 5, 1, time.Now())
+
+	if _, err := partition.UpdateFollowerFetch(4, 0, time.Now()); !errors.Is(err, ErrUnknownReplica) {
+		t.Errorf("expected ErrUnknownReplica, got %v", err)
+	}
+	if _, err := partition.UpdateFollowerFetch(2, 6, time.Now()); !errors.Is(err, ErrOffsetOutOfRange) {
+		t.Errorf("expected ErrOffsetOutOfRange, got %v", err)
+	}
+}
+
+func TestAcksAllWaitsForMinInsyncReplicas(t *testing.T) {
+	start := time.UnixMilli(1_000_000)
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1, 2, 3}, []int32{1, 2, 3}, 0, 0, 1, start)
+
+	if err := partition.CheckAppend(ACKS_ALL, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	partition.UpdateLogEndOffset(5)
+
+	if done, _ := partition.CheckEnoughReplicasReachOffset(5, 2); done {
+		t.Errorf("the produce must wait for the followers")
+	}
+
+	// Replica 2 keeps fetching while replica 3 falls out of sync, leaving the ISR at exactly min.insync.replicas
+	partition.UpdateFollowerFetch(2, 5, start.Add(time.Second))
+	partition.UpdateFollowerFetch(2, 5, start.Add(50*time.Second))
+	isr, _ := partition.ProposedIsr(start.Add(time.Minute), 30*time.Second)
+	partition.UpdateIsr(isr, 1)
+
+	done, err := partition.CheckEnoughReplicasReachOffset(5, 2)
+	if !done || err != nil {
+		t.Errorf("expected the produce to complete, got %v %v", done, err)
+	}
+
+	done, err = partition.CheckEnoughReplicasReachOffset(5, 3)
+	if !done || !errors.Is(err, ErrNotEnoughReplicasAfterAppend) {
+		t.Errorf("expected ErrNotEnoughReplicasAfterAppend, got %v %v", done, err)
+	}
+
+	if err := partition.CheckAppend(ACKS_ALL, 3); !errors.Is(err, ErrNotEnoughReplicas) {
+		t.Errorf("expected ErrNotEnoughReplicas, got %v", err)
+	}
+	if err := partition.CheckAppend(1, 3); err != nil {
+		t.Errorf("acks=1 does not depend on the ISR, got %v", err)
+	}
+}
+
+func TestHighWatermarkWaitsForMinInsyncReplicas(t *testing.T) {
+	now := time.Now()
+	partition := NewLeaderPartition("foo", 0, 1, 0, 0, []int32{1, 2}, []int32{1}, 0, 0, 2, now)
+
+	// The leader alone in the ISR does not commit its records
+	if partition.UpdateLogEndOffset(5) || partition.HighWatermark() != 0 {
+		t.Errorf("expected the high watermark to stay at 0, got %d", partition.HighWatermark())
+	}
+
+	// The follower joins the ISR at the high watermark, which moves once it has the records
+	partition.UpdateFollowerFetch(2, 0, now)
+	if moved, _ := partition.UpdateFollowerFetch(2, 5, now); !moved || partition.HighWatermark() != 5 {
+		t.Errorf("expected the high watermark to move to 5, got %d", partition.HighWatermark())
+	}
+
+	// Until the ISR is large enough again, or min.insync.replicas is lowered
+	partition.UpdateIsr([]int32{1}, 1)
+	partition.UpdateLogEndOffset(8)
+	if partition.HighWatermark() != 5 {
+		t.Errorf("expected the high watermark to stay at 5, got %d", partition.HighWatermark())
+	}
+	if !partition.UpdateMinInsyncReplicas(1) || partition.HighWatermark() != 8 {
+		t.Errorf("expected the high watermark to move to 8, got %d", partition.HighWatermark())
+	}
+}
@@ -0,0 +1,140 @@
+package replica
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/purgatory"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// AppendResult is the outcome of a produce to a partition. BaseOffset is the offset of its first record,
+// LogAppendTime the timestamp the leader gave the records, -1 when they keep their create time, and LogStartOffset the
+// first offset of the log
+type AppendResult struct {
+	Err            error
+	BaseOffset     int64
+	LogAppendTime  int64
+	LogStartOffset int64
+}
+
+// appendedPartition is a partition whose records were appended, and which an acks=-1 produce waits for
+type appendedPartition struct {
+	topicPartition storage.TopicPartition
+	leader         *Partition
+	nextOffset     int64
+	done           bool
+}
+
+// AppendRecords appends the record batches of a produce request to the partitions the broker leads, and calls respond
+// with the result of every partition. With acks=-1 the response waits until the high watermark of every partition
+// passed its records, or fails them with ErrRequestTimedOut once timeout elapsed. Otherwise respond is called right
+// after the append
+func (m *Manager) AppendRecords(timeout time.Duration, requiredAcks int16, records map[storage.TopicPartition][]byte, respond func(map[storage.TopicPartition]AppendResult)) {
+	m.appendRecords(timeout, requiredAcks, records, false, respond)
+}
+
+// AppendAsCoordinator appends the batches of a coordinator to the partitions the broker leads, such as the control
+// batches of transaction markers, and responds like an acks=-1 AppendRecords once they are replicated
+func (m *Manager) AppendAsCoordinator(timeout time.Duration, records map[storage.TopicPartition][]byte, respond func(map[storage.TopicPartition]AppendResult)) {
+	m.appendRecords(timeout, ACKS_ALL, records, true, respond)
+}
+
+func (m *Manager) appendRecords(timeout time.Duration, requiredAcks int16, records map[storage.TopicPartition][]byte, asCoordinator bool, respond func(map[storage.TopicPartition]AppendResult)) {
+	results := make(map[storage.TopicPartition]AppendResult, len(records))
+	waiting := []*appendedPartition{}
+	appended := []storage.TopicPartition{}
+
+	m.mutex.RLock()
+	for topicPartition, batches := range records {
+		hosted, err := m.leaderPartition(topicPartition)
+		if err == nil {
+			err = hosted.leader.CheckAppend(requiredAcks, m.minInsyncReplicas(topicPartition.Topic))
+		}
+		if err != nil {
+			results[topicPartition] = AppendResult{Err: err, BaseOffset: -1, LogAppendTime: -1, LogStartOffset: -1}
+			continue
+		}
+
+		var info storage.AppendInfo
+		if asCoordinator {
+			info, err = m.logs.AppendAsCoordinator(topicPartition, batches, hosted.leaderEpoch)
+		} else {
+			info, err = m.logs.Append(topicPartition, batches, hosted.leaderEpoch)
+		}
+		if err != nil {
+			results[topicPartition] = AppendResult{Err: err, BaseOffset: -1, LogAppendTime: -1, LogStartOffset: -1}
+			continue
+		}
+
+		hosted.leader.UpdateLogEndOffset(info.LastOffset + 1)
+		logStartOffset, _ := m.logs.LogStartOffset(topicPartition)
+		results[topicPartition] = AppendResult{BaseOffset: info.FirstOffset, LogAppendTime: info.LogAppendTime, LogStartOffset: logStartOffset}
+		appended = append(appended, topicPartition)
+		if requiredAcks == ACKS_ALL {
+			waiting = append(waiting, &appendedPartition{topicPartition: topicPartition, leader: hosted.leader, nextOffset: info.LastOffset + 1})
+		}
+	}
+	m.mutex.RUnlock()
+
+	// The fetches waiting for records and the produces waiting for the high watermark of a single leader can complete
+	for _, topicPartition := range appended {
+		m.checkAndComplete(topicPartition)
+	}
+
+	if len(waiting) == 0 {
+		respond(results)
+		return
+	}
+
+	keys := make([]string, 0, len(waiting))
+	for _, partition := range waiting {
+		keys = append(keys, partition.topicPartition.String())
+	}
+
+	operation := purgatory.NewDelayedOperation(timeout, func() bool {
+		for _, partition := range waiting {
+			if !partition.done {
+				partition.done = m.tryCompleteAppend(partition, results)
+			}
+			if !partition.done {
+				return false
+			}
+		}
+		return true
+	}, func() {
+		respond(results)
+	}, func() {
+		for _, partition := range waiting {
+			if !partition.done {
+				result := results[partition.topicPartition]
+				result.Err = ErrRequestTimedOut
+				results[partition.topicPartition] = result
+			}
+		}
+		respond(results)
+	})
+	m.produces.TryCompleteElseWatch(operation, keys)
+}
+
+// tryCompleteAppend tells if the acks=-1 produce to a partition is done: once the high watermark passed its records,
+// or once the broker is no longer the leader that appended them. Its result then goes into results
+func (m *Manager) tryCompleteAppend(partition *appendedPartition, results map[storage.TopicPartition]AppendResult) bool {
+	m.mutex.RLock()
+	hosted, ok := m.partitions[partition.topicPartition]
+	isLeader := ok && hosted.leader == partition.leader
+	m.mutex.RUnlock()
+
+	result := results[partition.topicPartition]
+	if !isLeader {
+		result.Err = ErrNotLeaderOrFollower
+		results[partition.topicPartition] = result
+		return true
+	}
+
+	done, err := partition.leader.CheckEnoughReplicasReachOffset(partition.nextOffset, m.minInsyncReplicas(partition.topicPartition.Topic))
+	if done {
+		result.Err = err
+		results[partition.topicPartition] = result
+	}
+	return done
+}
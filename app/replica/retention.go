@@ -0,0 +1,99 @@
+package replica
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// runRetention deletes the old segments of the logs and compacts them every RetentionCheckInterval, like the log
+// retention task and the log cleaner of Kafka, until the manager stops
+func (m *Manager) runRetention() {
+	ticker := time.NewTicker(m.config.RetentionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+
+		m.deleteOldSegments()
+	}
+}
+
+// deleteOldSegments applies the retention and the compaction of their topic to the logs of the hosted partitions. Only
+// the records below the high watermark can go, a leader keeps the ones its ISR may still need and a follower the ones it may
+// still have to truncate
+func (m *Manager) deleteOldSegments() {
+	highWatermarks := map[storage.TopicPartition]int64{}
+	m.mutex.RLock()
+	for topicPartition, hosted := range m.partitions {
+		if hosted.leader != nil {
+			highWatermarks[topicPartition] = hosted.leader.HighWatermark()
+		} else {
+			highWatermarks[topicPartition] = hosted.highWatermark.Load()
+		}
+	}
+	m.mutex.RUnlock()
+
+	for topicPartition, highWatermark := range highWatermarks {
+		deleted, err := m.logs.DeleteOldSegments(topicPartition, highWatermark)
+		if err != nil {
+			m.logger.Error("Failed to delete the old segments of a log", "partition", topicPartition.String(), "error", err)
+			continue
+		}
+		if deleted > 0 {
+			logStartOffset, _ := m.logs.LogStartOffset(topicPartition)
+			m.logger.Info("Deleted the old segments of a log", "partition", topicPartition.String(), "segments", deleted, "log_start_offset", logStartOffset)
+		}
+
+		removed, err := m.logs.Compact(topicPartition, highWatermark)
+		if err != nil {
+			m.logger.Error("Failed to compact a log", "partition", topicPartition.String(), "error", err)
+			continue
+		}
+		if removed > 0 {
+			m.logger.Info("Compacted a log", "partition", topicPartition.String(), "batches", removed)
+		}
+	}
+}
+
+// runProducerExpiration makes the logs forget their idle producers every ProducerIdExpirationCheckInterval, like the
+// producer expiration task of Kafka, until the manager stops
+func (m *Manager) runProducerExpiration() {
+	ticker := time.NewTicker(m.config.ProducerIdExpirationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+
+		m.expireProducers()
+	}
+}
+
+// expireProducers removes the producers that did not append for ProducerIdExpiration from the logs of the hosted
+// partitions, the ones with a transaction ongoing are kept
+func (m *Manager) expireProducers() {
+	m.mutex.RLock()
+	partitions := slices.Collect(maps.Keys(m.partitions))
+	m.mutex.RUnlock()
+
+	for _, topicPartition := range partitions {
+		expired, err := m.logs.ExpireProducers(topicPartition, m.config.ProducerIdExpiration)
+		if err != nil {
+			m.logger.Error("Failed to expire the producers of a log", "partition", topicPartition.String(), "error", err)
+			continue
+		}
+		if expired > 0 {
+			m.logger.Info("Expired the producers of a log", "partition", topicPartition.String(), "producers", expired)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package replica
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// Both sides of the replication throttle the replicas listed in a topic config, at the rate of a broker config
+const (
+	LEADER_THROTTLED_REPLICAS   = "leader.replication.throttled.replicas"
+	LEADER_THROTTLED_RATE       = "leader.replication.throttled.rate"
+	FOLLOWER_THROTTLED_REPLICAS = "follower.replication.throttled.replicas"
+	FOLLOWER_THROTTLED_RATE     = "follower.replication.throttled.rate"
+)
+
+// isThrottled tells if the replica of the broker is in the throttled replicas of its topic, as a
+// [PartitionId]:[BrokerId] entry or the '*' wildcard
+func (m *Manager) isThrottled(topicPartition storage.TopicPartition, replicas string) bool {
+	value, err := m.configs.Value(config.Resource{Type: config.TOPIC, Name: topicPartition.Topic}, replicas)
+	if err != nil {
+		return false
+	}
+
+	entry := strconv.Itoa(int(topicPartition.Partition)) + ":" + strconv.Itoa(int(m.config.NodeId))
+	return slices.ContainsFunc(config.SplitList(value), func(item string) bool { return item == "*" || item == entry })
+}
+
+// isQuotaExceeded tells if the throttled replicas of one side of the replication went over the throttled rate of the
+// broker, the replicas that are not throttled are never held back
+func (m *Manager) isQuotaExceeded(replicationQuota *quota.ReplicationQuota, rate string, now time.Time) bool {
+	bound, err := m.configs.Int64(config.Resource{Type: config.BROKER, Name: strconv.Itoa(int(m.config.NodeId))}, rate)
+	if err != nil {
+		return false
+	}
+	return replicationQuota.IsExceeded(float64(bound), now)
+}
+
+// shouldLeaderThrottle tells if the leader leaves the records of a throttled partition out of the fetch of a follower,
+// like Kafka it never holds back the followers in the ISR. The caller holds the read lock
+func (m *Manager) shouldLeaderThrottle(topicPartition storage.TopicPartition, hosted *hostedPartition, replicaId int32) bool {
+	return !slices.Contains(hosted.leader.Isr(), replicaId) && m.isThrottled(topicPartition, LEADER_THROTTLED_REPLICAS) &&
+		m.isQuotaExceeded(m.leaderQuota, LEADER_THROTTLED_RATE, m.now())
+}
+
+// recordLeaderThrottled measures the records the leader sends to a follower for its throttled partitions
+func (m *Manager) recordLeaderThrottled(results map[storage.TopicPartition]FetchResult) {
+	size := 0
+	for topicPartition, result := range results {
+		if m.isThrottled(topicPartition, LEADER_THROTTLED_REPLICAS) {
+			size += len(result.Records)
+		}
+	}
+	if size > 0 {
+		m.leaderQuota.Record(size, m.now())
+	}
+}
+
+// shouldFollowerThrottle tells if a follower leaves a throttled partition out of its next fetch, like Kafka it never
+// holds back a partition whose ISR has the follower. The caller holds the read lock
+func (m *Manager) shouldFollowerThrottle(topicPartition storage.TopicPartition) bool {
+	if !m.isThrottled(topicPartition, FOLLOWER_THROTTLED_REPLICAS) {
+		return false
+	}
+	if topic, ok := m.image.Topic(topicPartition.Topic); ok {
+		if partition, ok := topic.Partitions[topicPartition.Partition]; ok && slices.Contains(partition.Isr, m.config.NodeId) {
+			return false
+		}
+	}
+	return m.isQuotaExceeded(m.followerQuota, FOLLOWER_THROTTLED_RATE, m.now())
+}
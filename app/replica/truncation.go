@@ -0,0 +1,31 @@
+package replica
+
+import "github.com/codecrafters-io/kafka-starter-go/app/storage"
+
+// TruncationOffset is where a follower truncates its log once the leader told it the end offset of an epoch, from an
+// OffsetForLeaderEpoch response or the diverging_epoch of a Fetch v12+ response. It is false when the follower must ask
+// again for the epoch it now ends with, because it has no record of the leader's epoch and their histories differ before it
+func TruncationOffset(leaderEpoch int32, leaderEndOffset int64, cache *storage.LeaderEpochCache, logEndOffset int64, highWatermark int64) (int64, bool) {
+	// The leader knows nothing about its epochs, only the high watermark is safe
+	if leaderEndOffset == storage.UNDEFINED_EPOCH_OFFSET {
+		return highWatermark, true
+	}
+
+	if leaderEpoch == storage.UNDEFINED_EPOCH {
+		return min(leaderEndOffset, logEndOffset), true
+	}
+
+	followerEpoch, followerEndOffset := cache.EndOffsetFor(leaderEpoch, logEndOffset)
+	if followerEpoch == storage.UNDEFINED_EPOCH {
+		return min(leaderEndOffset, logEndOffset), true
+	}
+
+	// The follower's log ends the leader's epoch elsewhere, it truncates to its own end of that epoch and asks again
+	if followerEpoch != leaderEpoch {
+		return min(followerEndOffset, leaderEndOffset), false
+	}
+
+	// Both logs have the epoch, they diverge from where the first of them ended it. The follower's end of the epoch is
+	// in its cache and not its log end offset, the records of its later epochs after the leader's end have diverged
+	return min(leaderEndOffset, followerEndOffset), true
+}
@@ -0,0 +1,58 @@
+package replica
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestTruncationOffset(t *testing.T) {
+	// The follower followed epoch 0, then led epoch 2 from offset 10 and appended up to 30 before losing the leadership
+	cache, err := storage.LoadLeaderEpochCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Assign(0, 0)
+	cache.Assign(2, 10)
+
+	tests := []struct {
+		name            string
+		leaderEpoch     int32
+		leaderEndOffset int64
+		want            int64
+		wantCompleted   bool
+	}{
+		{"Leader ended epoch 2 earlier", 2, 20, 20, true},
+		{"Leader ended epoch 2 later", 2, 40, 30, true},
+		{"Leader did not have epoch 2", 0, 8, 8, true},
+		{"Follower did not have epoch 1", 1, 15, 10, false},
+		{"Leader without epochs", storage.UNDEFINED_EPOCH, 25, 25, true},
+		{"Leader without epoch offsets", 2, storage.UNDEFINED_EPOCH_OFFSET, 12, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, completed := TruncationOffset(tt.leaderEpoch, tt.leaderEndOffset, cache, 30, 12)
+			if got != tt.want || completed != tt.wantCompleted {
+				t.Errorf("got (%d, %v), want (%d, %v)", got, completed, tt.want, tt.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestTruncationOffsetEndsAtTheFollowerEpoch(t *testing.T) {
+	// The follower led epoch 3 from offset 10 after ending epoch 1 there, while the leader appended to epoch 1 up to 12
+	cache, err := storage.LoadLeaderEpochCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Assign(0, 0)
+	cache.Assign(1, 5)
+	cache.Assign(3, 10)
+
+	// Records 10 and 11 of the follower are from epoch 3, they diverged from the leader's epoch 1 records
+	got, completed := TruncationOffset(1, 12, cache, 15, 8)
+	if got != 10 || !completed {
+		t.Errorf("got (%d, %v), want (10, true)", got, completed)
+	}
+}
@@ -0,0 +1,196 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type AddOffsetsToTxnRequest struct {
+	Header          RequestHeader
+	TransactionalId string
+	ProducerId      int64
+	ProducerEpoch   int16
+	GroupId         string
+	TaggedFields    map[string]string
+}
+
+func (r *AddOffsetsToTxnRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AddOffsetsToTxnRequest) GetApiKey() KafkaAPIKey {
+	return AddOffsetsToTxn
+}
+
+func (r *AddOffsetsToTxnRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AddOffsetsToTxnRequest) Validate() error {
+	if r.Header.RequestApiVersion != 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AddOffsetsToTxnResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	TaggedFields  map[string]string
+}
+
+func (r *AddOffsetsToTxnResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AddOffsetsToTxnResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AddOffsetsToTxnResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *AddOffsetsToTxnResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, 32)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// AddOffsetsToTxnHandler adds the partition of __consumer_offsets of a consumer group to the transaction of a
+// producer, which then commits the offsets of the group with TxnOffsetCommit
+type AddOffsetsToTxnHandler struct {
+	configs *config.Store
+	loader  *metadata.Loader
+	// nil without partition logs
+	coordinator *transaction.Coordinator
+	authorizer  acl.Authorizer
+}
+
+func (h *AddOffsetsToTxnHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AddOffsetsToTxnRequest{}
+	req.Header = requestHeader
+
+	req.TransactionalId, index, err = parser.ExtractCompactString(buffer, index)
+	if err == nil {
+		req.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+	}
+	if err == nil {
+		req.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse producer from AddOffsetsToTxn request",
+		}
+	}
+
+	req.GroupId, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse group id from AddOffsetsToTxn request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AddOffsetsToTxn request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *AddOffsetsToTxnHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *AddOffsetsToTxnHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*AddOffsetsToTxnRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("AddOffsetsToTxnHandler received %T instead of *AddOffsetsToTxnRequest", req))
+		return
+	}
+
+	response := &AddOffsetsToTxnResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		TaggedFields:  make(map[string]string),
+	}
+
+	switch {
+	case !session.authorize(h.authorizer, acl.WRITE, acl.TRANSACTIONAL_ID, apiReq.TransactionalId):
+		response.ErrorCode = int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED)
+	case !session.authorize(h.authorizer, acl.READ, acl.GROUP, apiReq.GroupId):
+		response.ErrorCode = int16(GROUP_AUTHORIZATION_FAILED)
+	case h.coordinator == nil:
+		response.ErrorCode = int16(COORDINATOR_NOT_AVAILABLE)
+	default:
+		partitions := []storage.TopicPartition{offsetsPartition(h.loader.Image(), h.configs, apiReq.GroupId)}
+		h.coordinator.AddPartitions(apiReq.TransactionalId, apiReq.ProducerId, apiReq.ProducerEpoch, partitions, func(err error) {
+			if err != nil {
+				response.ErrorCode = transactionErrorCode(err)
+			}
+			respond(response, nil)
+		})
+		return
+	}
+
+	respond(response, nil)
+}
+
+// offsetsPartition returns the partition of __consumer_offsets of a consumer group. Like Kafka, the partitions are the
+// ones of the topic once it exists, offsets.topic.num.partitions until then
+func offsetsPartition(image *metadata.Image, configs *config.Store, groupId string) storage.TopicPartition {
+	numPartitions, _ := configs.Int64(config.Resource{Type: config.BROKER, Name: ""}, "offsets.topic.num.partitions")
+	if topic, ok := image.Topic(CONSUMER_OFFSETS_TOPIC); ok {
+		numPartitions = int64(len(topic.Partitions))
+	}
+	return storage.TopicPartition{Topic: CONSUMER_OFFSETS_TOPIC, Partition: transaction.PartitionFor(groupId, int(numPartitions))}
+}
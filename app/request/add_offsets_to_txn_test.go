@@ -0,0 +1,120 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+func TestAddOffsetsToTxnParseRequestBody(t *testing.T) {
+	handler := AddOffsetsToTxnHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x24, // MessageSize: 36
+		0x00, 0x19, // RequestApiKey: 25 (AddOffsetsToTxn)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x00, // ProducerEpoch: 0
+		0x06, 'g', 'r', 'o', 'u', 'p', // GroupId: "group"
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &AddOffsetsToTxnRequest{Header: header, TransactionalId: "txn", ProducerId: 5, ProducerEpoch: 0, GroupId: "group", TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:36], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAddOffsetsToTxnHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+
+	// Anonymous can not read the offsets of group
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.GROUP, ResourceName: "group", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.READ, PermissionType: acl.DENY},
+	})
+
+	tests := []struct {
+		name        string
+		coordinator *transaction.Coordinator
+		authorizer  acl.Authorizer
+		groupId     string
+		want        KafkaErrorCode
+	}{
+		{"Unauthorized transactional id", coordinator, denyAllAuthorizer{}, "other", TRANSACTIONAL_ID_AUTHORIZATION_FAILED},
+		{"Unauthorized group", coordinator, authorizer, "group", GROUP_AUTHORIZATION_FAILED},
+		{"No coordinator", nil, authorizer, "other", COORDINATOR_NOT_AVAILABLE},
+		{"Unknown transactional id", coordinator, authorizer, "other", INVALID_PRODUCER_ID_MAPPING},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &AddOffsetsToTxnHandler{configs: configs, loader: loader, coordinator: tt.coordinator, authorizer: tt.authorizer}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &AddOffsetsToTxnRequest{
+				Header:          RequestHeader{RequestApiKey: 25, RequestApiVersion: 3, CorrelationId: 7},
+				TransactionalId: "txn",
+				ProducerId:      5,
+				ProducerEpoch:   0,
+				GroupId:         tt.groupId,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if errorCode := got.(*AddOffsetsToTxnResponse).ErrorCode; errorCode != int16(tt.want) {
+				t.Errorf("expected %s, got %s", KafkaErrorCodeNames[tt.want], KafkaErrorCodeNames[KafkaErrorCode(errorCode)])
+			}
+		})
+	}
+}
+
+func TestOffsetsPartition(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, _ := newTestConfigs(t, now)
+	configs := config.NewStore(1, map[string]string{"offsets.topic.num.partitions": "7"})
+
+	// The partitions come from offsets.topic.num.partitions until the topic exists
+	want := storage.TopicPartition{Topic: CONSUMER_OFFSETS_TOPIC, Partition: transaction.PartitionFor("group", 7)}
+	if got := offsetsPartition(loader.Image(), configs, "group"); got != want {
+		t.Errorf("expected %v before the topic is created, got %v", want, got)
+	}
+
+	if _, err := active.CreateTopic(CONSUMER_OFFSETS_TOPIC, [][]int32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	want = storage.TopicPartition{Topic: CONSUMER_OFFSETS_TOPIC, Partition: 0}
+	if got := offsetsPartition(loader.Image(), configs, "group"); got != want {
+		t.Errorf("expected %v once the topic has a single partition, got %v", want, got)
+	}
+}
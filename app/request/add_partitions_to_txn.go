@@ -0,0 +1,682 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type AddPartitionsToTxnTopic struct {
+	Name         string
+	Partitions   []int32
+	TaggedFields map[string]string
+}
+
+// AddPartitionsToTxnTransaction is one of the transactions of a version 4 request, which the brokers send to verify
+// the partitions of transactions before appending to them
+type AddPartitionsToTxnTransaction struct {
+	TransactionalId string
+	ProducerId      int64
+	ProducerEpoch   int16
+	// Only verify that the partitions are part of the transaction, without adding them
+	VerifyOnly   bool
+	Topics       []AddPartitionsToTxnTopic
+	TaggedFields map[string]string
+}
+
+type AddPartitionsToTxnRequest struct {
+	Header RequestHeader
+	// The transaction of a producer, up to v3
+	TransactionalId string
+	ProducerId      int64
+	ProducerEpoch   int16
+	Topics          []AddPartitionsToTxnTopic
+	// The transactions of the request, since v4
+	Transactions []AddPartitionsToTxnTransaction
+	TaggedFields map[string]string
+}
+
+func (r *AddPartitionsToTxnRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AddPartitionsToTxnRequest) GetApiKey() KafkaAPIKey {
+	return AddPartitionsToTxn
+}
+
+func (r *AddPartitionsToTxnRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AddPartitionsToTxnRequest) Validate() error {
+	if r.Header.RequestApiVersion < 3 || r.Header.RequestApiVersion > 4 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the version 4 request a broker sends to the transaction coordinator of a producer, to verify the
+// partitions it is about to append to
+func (r *AddPartitionsToTxnRequest) Serialize() ([]byte, error) {
+	bufferSize := 32 + len(r.Header.ClientId)
+	for _, transaction := range r.Transactions {
+		bufferSize += 32 + len(transaction.TransactionalId)
+		for _, topic := range transaction.Topics {
+			bufferSize += 16 + len(topic.Name) + 4*len(topic.Partitions)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Transactions)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transaction := range r.Transactions {
+		index, err = serializer.SerializeCompactString(buffer, index, transaction.TransactionalId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt64(buffer, index, transaction.ProducerId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, transaction.ProducerEpoch)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeBoolean(buffer, index, transaction.VerifyOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(transaction.Topics)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topic := range transaction.Topics {
+			index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeInt32Array(buffer, index, topic.Partitions)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, transaction.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type AddPartitionsToTxnPartitionResult struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	TaggedFields   map[string]string
+}
+
+type AddPartitionsToTxnTopicResult struct {
+	Name         string
+	Results      []AddPartitionsToTxnPartitionResult
+	TaggedFields map[string]string
+}
+
+type AddPartitionsToTxnResult struct {
+	TransactionalId string
+	TopicResults    []AddPartitionsToTxnTopicResult
+	TaggedFields    map[string]string
+}
+
+type AddPartitionsToTxnResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	// The error of the whole request, since v4
+	ErrorCode int16
+	// The results of the transactions, since v4
+	ResultsByTransaction []AddPartitionsToTxnResult
+	// The results of the transaction, up to v3
+	Results      []AddPartitionsToTxnTopicResult
+	TaggedFields map[string]string
+}
+
+func (r *AddPartitionsToTxnResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AddPartitionsToTxnResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AddPartitionsToTxnResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{}
+	if r.ErrorCode != int16(NONE) {
+		counts[r.ErrorCode]++
+	}
+	results := append([]AddPartitionsToTxnTopicResult{}, r.Results...)
+	for _, transaction := range r.ResultsByTransaction {
+		results = append(results, transaction.TopicResults...)
+	}
+	for _, topic := range results {
+		for _, partition := range topic.Results {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+// txnTopicResultsSize bounds the serialized size of the results of the topics of a transaction
+func txnTopicResultsSize(results []AddPartitionsToTxnTopicResult) int {
+	size := 8
+	for _, topic := range results {
+		size += 16 + len(topic.Name) + 8*len(topic.Results)
+	}
+	return size
+}
+
+func (r *AddPartitionsToTxnResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32 + txnTopicResultsSize(r.Results)
+	for _, transaction := range r.ResultsByTransaction {
+		bufferSize += 16 + len(transaction.TransactionalId) + txnTopicResultsSize(transaction.TopicResults)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiVersion >= 4 {
+		index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.ResultsByTransaction)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, transaction := range r.ResultsByTransaction {
+			index, err = serializer.SerializeCompactString(buffer, index, transaction.TransactionalId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeTxnTopicResults(buffer, index, transaction.TopicResults)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, transaction.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		index, err = serializeTxnTopicResults(buffer, index, r.Results)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// serializeTxnTopicResults writes a compact array of topics with the errors of their partitions
+func serializeTxnTopicResults(buffer []byte, index int, results []AddPartitionsToTxnTopicResult) (int, error) {
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(results)+1))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, topic := range results {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return 0, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Results)+1))
+		if err != nil {
+			return 0, err
+		}
+
+		for _, partition := range topic.Results {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return 0, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return 0, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return index, nil
+}
+
+// parseAddPartitionsToTxnResponse reads the version 4 response of a transaction coordinator to a broker
+func parseAddPartitionsToTxnResponse(buffer []byte) (*AddPartitionsToTxnResponse, error) {
+	response := &AddPartitionsToTxnResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	var transactionsLength int
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err == nil {
+		transactionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AddPartitionsToTxn response: %w", err)
+	}
+
+	response.ResultsByTransaction = make([]AddPartitionsToTxnResult, 0, max(transactionsLength, 0))
+	for i := 0; i < transactionsLength; i++ {
+		transaction := AddPartitionsToTxnResult{}
+
+		var topicsLength int
+		transaction.TransactionalId, index, err = parser.ExtractCompactString(buffer, index)
+		if err == nil {
+			topicsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction of AddPartitionsToTxn response: %w", err)
+		}
+
+		transaction.TopicResults = make([]AddPartitionsToTxnTopicResult, 0, max(topicsLength, 0))
+		for j := 0; j < topicsLength; j++ {
+			topic := AddPartitionsToTxnTopicResult{}
+
+			var partitionsLength int
+			topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err == nil {
+				partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse topic of AddPartitionsToTxn response: %w", err)
+			}
+
+			topic.Results = make([]AddPartitionsToTxnPartitionResult, 0, max(partitionsLength, 0))
+			for k := 0; k < partitionsLength; k++ {
+				partition := AddPartitionsToTxnPartitionResult{}
+
+				partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+				if err == nil {
+					partition.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+				}
+				if err == nil {
+					partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse partition of AddPartitionsToTxn response: %w", err)
+				}
+
+				topic.Results = append(topic.Results, partition)
+			}
+
+			topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse topic of AddPartitionsToTxn response: %w", err)
+			}
+
+			transaction.TopicResults = append(transaction.TopicResults, topic)
+		}
+
+		transaction.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction of AddPartitionsToTxn response: %w", err)
+		}
+
+		response.ResultsByTransaction = append(response.ResultsByTransaction, transaction)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AddPartitionsToTxn response: %w", err)
+	}
+
+	return response, nil
+}
+
+// AddPartitionsToTxnHandler adds the partitions a transactional producer is about to write to to its transaction, on
+// the coordinator of its transactional id. None is added when one of them is unauthorized or unknown. Since version 4
+// the leaders of the partitions also send it, to verify that a partition is part of the transaction before the first
+// transactional batch of the producer is appended to it
+type AddPartitionsToTxnHandler struct {
+	loader *metadata.Loader
+	// nil without partition logs
+	coordinator *transaction.Coordinator
+	authorizer  acl.Authorizer
+}
+
+func (h *AddPartitionsToTxnHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AddPartitionsToTxnRequest{}
+	req.Header = requestHeader
+
+	if requestHeader.RequestApiVersion >= 4 {
+		req.Transactions, index, err = parseTxnTransactions(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse transactions from AddPartitionsToTxn request: %v", err),
+			}
+		}
+	} else {
+		req.TransactionalId, index, err = parser.ExtractCompactString(buffer, index)
+		if err == nil {
+			req.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+		}
+		if err == nil {
+			req.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+		}
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse producer from AddPartitionsToTxn request",
+			}
+		}
+
+		req.Topics, index, err = parseTxnTopics(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topics from AddPartitionsToTxn request: %v", err),
+			}
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AddPartitionsToTxn request",
+		}
+	}
+
+	return req, nil
+}
+
+// parseTxnTransactions parses the compact array of the transactions of a version 4 request
+func parseTxnTransactions(buffer []byte, index int) ([]AddPartitionsToTxnTransaction, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	if length < 0 {
+		return nil, index, fmt.Errorf("null transactions")
+	}
+
+	transactions := make([]AddPartitionsToTxnTransaction, 0, length)
+	for i := 0; i < length; i++ {
+		transaction := AddPartitionsToTxnTransaction{}
+
+		transaction.TransactionalId, index, err = parser.ExtractCompactString(buffer, index)
+		if err == nil {
+			transaction.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+		}
+		if err == nil {
+			transaction.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+		}
+		if err == nil {
+			transaction.VerifyOnly, index, err = parser.ExtractBoolean(buffer, index)
+		}
+		if err == nil {
+			transaction.Topics, index, err = parseTxnTopics(buffer, index)
+		}
+		if err == nil {
+			transaction.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		}
+		if err != nil {
+			return nil, index, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, index, nil
+}
+
+// parseTxnTopics parses a compact array of topics with the indexes of their partitions
+func parseTxnTopics(buffer []byte, index int) ([]AddPartitionsToTxnTopic, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	if length < 0 {
+		return nil, index, fmt.Errorf("null topics")
+	}
+
+	topics := make([]AddPartitionsToTxnTopic, 0, length)
+	for i := 0; i < length; i++ {
+		topic := AddPartitionsToTxnTopic{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		topic.Partitions, index, err = parseInt32Array(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		topics = append(topics, topic)
+	}
+
+	return topics, index, nil
+}
+
+func (h *AddPartitionsToTxnHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *AddPartitionsToTxnHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*AddPartitionsToTxnRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("AddPartitionsToTxnHandler received %T instead of *AddPartitionsToTxnRequest", req))
+		return
+	}
+
+	response := &AddPartitionsToTxnResponse{
+		CorrelationId:        apiReq.Header.CorrelationId,
+		ThrottleTime:         0,
+		ErrorCode:            int16(NONE),
+		ResultsByTransaction: []AddPartitionsToTxnResult{},
+		Results:              []AddPartitionsToTxnTopicResult{},
+		TaggedFields:         make(map[string]string),
+	}
+
+	if apiReq.Header.RequestApiVersion < 4 {
+		transaction := AddPartitionsToTxnTransaction{TransactionalId: apiReq.TransactionalId, ProducerId: apiReq.ProducerId, ProducerEpoch: apiReq.ProducerEpoch, Topics: apiReq.Topics}
+		h.addPartitions(session, transaction, true, func(results []AddPartitionsToTxnTopicResult) {
+			response.Results = results
+			respond(response, nil)
+		})
+		return
+	}
+
+	// Like Kafka, only the brokers send version 4, the ACLs of their transactions are not checked again
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		respond(response, nil)
+		return
+	}
+
+	// The response waits for the transactions of every producer
+	var mutex sync.Mutex
+	remaining := len(apiReq.Transactions) + 1
+	done := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		remaining--
+		if remaining == 0 {
+			respond(response, nil)
+		}
+	}
+
+	response.ResultsByTransaction = make([]AddPartitionsToTxnResult, len(apiReq.Transactions))
+	for i, transaction := range apiReq.Transactions {
+		h.addPartitions(session, transaction, false, func(results []AddPartitionsToTxnTopicResult) {
+			mutex.Lock()
+			response.ResultsByTransaction[i] = AddPartitionsToTxnResult{TransactionalId: transaction.TransactionalId, TopicResults: results, TaggedFields: make(map[string]string)}
+			mutex.Unlock()
+			done()
+		})
+	}
+	done()
+}
+
+// addPartitions adds the partitions of a transaction to it, or only verifies that they are part of it, and responds
+// with the error of every partition. The ACLs of the producer are checked when checkAcls is set
+func (h *AddPartitionsToTxnHandler) addPartitions(session *Session, transaction AddPartitionsToTxnTransaction, checkAcls bool, respond func([]AddPartitionsToTxnTopicResult)) {
+	results := make([]AddPartitionsToTxnTopicResult, 0, len(transaction.Topics))
+	// setErrors sets the error of every partition, or of the ones without an error when errorCode is
+	// OPERATION_NOT_ATTEMPTED
+	setErrors := func(errorCode int16) {
+		for i := range results {
+			for j := range results[i].Results {
+				if errorCode != int16(OPERATION_NOT_ATTEMPTED) || results[i].Results[j].ErrorCode == int16(NONE) {
+					results[i].Results[j].ErrorCode = errorCode
+				}
+			}
+		}
+	}
+
+	image := h.loader.Image()
+	partitions := []storage.TopicPartition{}
+	failed := false
+	for _, topic := range transaction.Topics {
+		result := AddPartitionsToTxnTopicResult{Name: topic.Name, Results: make([]AddPartitionsToTxnPartitionResult, 0, len(topic.Partitions)), TaggedFields: make(map[string]string)}
+
+		errorCode := int16(NONE)
+		topicImage, exists := image.Topic(topic.Name)
+		if checkAcls && !session.authorize(h.authorizer, acl.WRITE, acl.TOPIC, topic.Name) {
+			errorCode = int16(TOPIC_AUTHORIZATION_FAILED)
+		}
+		for _, partition := range topic.Partitions {
+			partitionErrorCode := errorCode
+			if errorCode == int16(NONE) && (!exists || topicImage.Partitions[partition] == nil) {
+				partitionErrorCode = int16(UNKNOWN_TOPIC_OR_PARTITION)
+			}
+			failed = failed || partitionErrorCode != int16(NONE)
+			result.Results = append(result.Results, AddPartitionsToTxnPartitionResult{PartitionIndex: partition, ErrorCode: partitionErrorCode, TaggedFields: make(map[string]string)})
+			partitions = append(partitions, storage.TopicPartition{Topic: topic.Name, Partition: partition})
+		}
+
+		results = append(results, result)
+	}
+
+	switch {
+	case checkAcls && !session.authorize(h.authorizer, acl.WRITE, acl.TRANSACTIONAL_ID, transaction.TransactionalId):
+		setErrors(int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED))
+	case failed:
+		setErrors(int16(OPERATION_NOT_ATTEMPTED))
+	case h.coordinator == nil:
+		setErrors(int16(COORDINATOR_NOT_AVAILABLE))
+	case transaction.VerifyOnly:
+		errs, err := h.coordinator.VerifyPartitions(transaction.TransactionalId, transaction.ProducerId, transaction.ProducerEpoch, partitions)
+		if err != nil {
+			setErrors(transactionErrorCode(err))
+			break
+		}
+		for i := range results {
+			for j := range results[i].Results {
+				results[i].Results[j].ErrorCode = transactionErrorCode(errs[storage.TopicPartition{Topic: results[i].Name, Partition: results[i].Results[j].PartitionIndex}])
+			}
+		}
+	default:
+		h.coordinator.AddPartitions(transaction.TransactionalId, transaction.ProducerId, transaction.ProducerEpoch, partitions, func(err error) {
+			if err != nil {
+				setErrors(transactionErrorCode(err))
+			}
+			respond(results)
+		})
+		return
+	}
+
+	respond(results)
+}
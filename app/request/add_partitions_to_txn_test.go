@@ -0,0 +1,270 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+func TestAddPartitionsToTxnParseRequestBody(t *testing.T) {
+	handler := AddPartitionsToTxnHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2D, // MessageSize: 45
+		0x00, 0x18, // RequestApiKey: 24 (AddPartitionsToTxn)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x00, // ProducerEpoch: 0
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x03,                   // Partitions array length (2 partitions + 1)
+		0x00, 0x00, 0x00, 0x00, // Partition: 0
+		0x00, 0x00, 0x00, 0x01, // Partition: 1
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &AddPartitionsToTxnRequest{
+		Header:          header,
+		TransactionalId: "txn",
+		ProducerId:      5,
+		ProducerEpoch:   0,
+		Topics:          []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0, 1}, TaggedFields: map[string]string{}}},
+		TaggedFields:    map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:40], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAddPartitionsToTxnRequestRoundTrip(t *testing.T) {
+	request := &AddPartitionsToTxnRequest{
+		Header: RequestHeader{RequestApiKey: 24, RequestApiVersion: 4, CorrelationId: 7, ClientId: "broker-1", TaggedFields: map[string]string{}},
+		Transactions: []AddPartitionsToTxnTransaction{{
+			TransactionalId: "txn",
+			ProducerId:      5,
+			ProducerEpoch:   1,
+			VerifyOnly:      true,
+			Topics:          []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0, 1}, TaggedFields: map[string]string{}}},
+			TaggedFields:    map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := request.Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header, index, err := ParseRequestHeader(serialized, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request.Header.MessageSize = header.MessageSize
+
+	got, err := (&AddPartitionsToTxnHandler{}).ParseRequestBody(header, serialized, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, request) {
+		t.Errorf("parsed %+v, want %+v", got, request)
+	}
+}
+
+func TestAddPartitionsToTxnResponseRoundTrip(t *testing.T) {
+	response := &AddPartitionsToTxnResponse{
+		CorrelationId: 7,
+		ErrorCode:     int16(NONE),
+		ResultsByTransaction: []AddPartitionsToTxnResult{{
+			TransactionalId: "txn",
+			TopicResults: []AddPartitionsToTxnTopicResult{{
+				Name: "foo",
+				Results: []AddPartitionsToTxnPartitionResult{
+					{PartitionIndex: 0, ErrorCode: int16(NONE), TaggedFields: map[string]string{}},
+					{PartitionIndex: 1, ErrorCode: int16(INVALID_TXN_STATE), TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := response.Serialize(4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := parseAddPartitionsToTxnResponse(serialized)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, response) {
+		t.Errorf("parsed %+v, want %+v", got, response)
+	}
+}
+
+func TestAddPartitionsToTxnHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+
+	initialized, err := (&InitProducerIdHandler{coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(NewSession("127.0.0.1"), &InitProducerIdRequest{
+		Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 6},
+		TransactionalId:      stringPtr("txn"),
+		TransactionTimeoutMs: 60000,
+		ProducerId:           -1,
+		ProducerEpoch:        -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	producerId := initialized.(*InitProducerIdResponse).ProducerId
+
+	// Anonymous can not write to bar
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.TOPIC, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.WRITE, PermissionType: acl.DENY},
+	})
+
+	foo := AddPartitionsToTxnTopic{Name: "foo", Partitions: []int32{0}}
+	tests := []struct {
+		name        string
+		coordinator *transaction.Coordinator
+		authorizer  acl.Authorizer
+		producerId  int64
+		topics      []AddPartitionsToTxnTopic
+		want        map[string][]KafkaErrorCode
+	}{
+		{"Unauthorized transactional id", coordinator, denyAllAuthorizer{}, producerId, []AddPartitionsToTxnTopic{foo}, map[string][]KafkaErrorCode{"foo": {TRANSACTIONAL_ID_AUTHORIZATION_FAILED}}},
+		{"Unauthorized topic", coordinator, authorizer, producerId, []AddPartitionsToTxnTopic{foo, {Name: "bar", Partitions: []int32{0}}}, map[string][]KafkaErrorCode{"foo": {OPERATION_NOT_ATTEMPTED}, "bar": {TOPIC_AUTHORIZATION_FAILED}}},
+		{"Unknown partition", coordinator, authorizer, producerId, []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0, 1}}, {Name: "baz", Partitions: []int32{0}}}, map[string][]KafkaErrorCode{"foo": {OPERATION_NOT_ATTEMPTED, UNKNOWN_TOPIC_OR_PARTITION}, "baz": {UNKNOWN_TOPIC_OR_PARTITION}}},
+		{"No coordinator", nil, authorizer, producerId, []AddPartitionsToTxnTopic{foo}, map[string][]KafkaErrorCode{"foo": {COORDINATOR_NOT_AVAILABLE}}},
+		{"Other producer id", coordinator, authorizer, producerId + 1, []AddPartitionsToTxnTopic{foo}, map[string][]KafkaErrorCode{"foo": {INVALID_PRODUCER_ID_MAPPING}}},
+		{"Partitions added", coordinator, authorizer, producerId, []AddPartitionsToTxnTopic{foo}, map[string][]KafkaErrorCode{"foo": {NONE}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &AddPartitionsToTxnHandler{loader: loader, coordinator: tt.coordinator, authorizer: tt.authorizer}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &AddPartitionsToTxnRequest{
+				Header:          RequestHeader{RequestApiKey: 24, RequestApiVersion: 3, CorrelationId: 7},
+				TransactionalId: "txn",
+				ProducerId:      tt.producerId,
+				ProducerEpoch:   0,
+				Topics:          tt.topics,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			results := map[string][]KafkaErrorCode{}
+			for _, topic := range got.(*AddPartitionsToTxnResponse).Results {
+				for _, partition := range topic.Results {
+					results[topic.Name] = append(results[topic.Name], KafkaErrorCode(partition.ErrorCode))
+				}
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, results)
+			}
+		})
+	}
+}
+
+func TestAddPartitionsToTxnVerifiesTransactions(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+
+	session := NewSession("127.0.0.1")
+	initialized, err := (&InitProducerIdHandler{coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &InitProducerIdRequest{
+		Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 6},
+		TransactionalId:      stringPtr("txn"),
+		TransactionTimeoutMs: 60000,
+		ProducerId:           -1,
+		ProducerEpoch:        -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	producerId := initialized.(*InitProducerIdResponse).ProducerId
+
+	// The transaction of txn has foo-0 only
+	handler := &AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}
+	if _, err := handler.Handle(session, &AddPartitionsToTxnRequest{
+		Header:          RequestHeader{RequestApiKey: 24, RequestApiVersion: 3, CorrelationId: 7},
+		TransactionalId: "txn",
+		ProducerId:      producerId,
+		Topics:          []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := &AddPartitionsToTxnRequest{
+		Header: RequestHeader{RequestApiKey: 24, RequestApiVersion: 4, CorrelationId: 8},
+		Transactions: []AddPartitionsToTxnTransaction{
+			{TransactionalId: "txn", ProducerId: producerId, VerifyOnly: true, Topics: []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0}}, {Name: "bar", Partitions: []int32{0}}}},
+			{TransactionalId: "other", ProducerId: producerId, VerifyOnly: true, Topics: []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0}}}},
+		},
+	}
+
+	// Only the brokers may send version 4
+	got, err := (&AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: denyAllAuthorizer{}}).Handle(session, verify)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if errorCode := got.(*AddPartitionsToTxnResponse).ErrorCode; errorCode != int16(CLUSTER_AUTHORIZATION_FAILED) {
+		t.Errorf("expected CLUSTER_AUTHORIZATION_FAILED, got %s", KafkaErrorCodeNames[KafkaErrorCode(errorCode)])
+	}
+
+	got, err = handler.Handle(session, verify)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results := map[string][]KafkaErrorCode{}
+	for _, transaction := range got.(*AddPartitionsToTxnResponse).ResultsByTransaction {
+		for _, topic := range transaction.TopicResults {
+			for _, partition := range topic.Results {
+				results[transaction.TransactionalId+"/"+topic.Name] = append(results[transaction.TransactionalId+"/"+topic.Name], KafkaErrorCode(partition.ErrorCode))
+			}
+		}
+	}
+	want := map[string][]KafkaErrorCode{"txn/foo": {NONE}, "txn/bar": {INVALID_TXN_STATE}, "other/foo": {INVALID_PRODUCER_ID_MAPPING}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("expected the errors %v, got %v", want, results)
+	}
+
+	// Verifying adds nothing to the transaction
+	described, err := coordinator.DescribeTransaction("txn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(described.Partitions) != 1 {
+		t.Errorf("expected the transaction to keep foo-0 only, got %v", described.Partitions)
+	}
+}
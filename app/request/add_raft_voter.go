@@ -0,0 +1,272 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type AddRaftVoterRequest struct {
+	Header           RequestHeader
+	ClusterId        *string
+	TimeoutMs        int32
+	VoterId          int32
+	VoterDirectoryId string
+	Listeners        []QuorumListener
+	TaggedFields     map[string]string
+}
+
+func (r *AddRaftVoterRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AddRaftVoterRequest) GetApiKey() KafkaAPIKey {
+	return AddRaftVoter
+}
+
+func (r *AddRaftVoterRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AddRaftVoterRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// RaftVoterResponse is the response of AddRaftVoter and RemoveRaftVoter, which have the same fields
+type RaftVoterResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	TaggedFields  map[string]string
+}
+
+func (r *RaftVoterResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *RaftVoterResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *RaftVoterResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *RaftVoterResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// AddRaftVoterHandler makes an observer of the metadata quorum a voter, on the leader of the quorum.
+// The response is sent once the new voter set is appended, it applies from then on
+type AddRaftVoterHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *AddRaftVoterHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AddRaftVoterRequest{}
+	req.Header = requestHeader
+
+	req.ClusterId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse cluster id from AddRaftVoter request",
+		}
+	}
+
+	req.TimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse timeout from AddRaftVoter request",
+		}
+	}
+
+	req.VoterId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse voter id from AddRaftVoter request",
+		}
+	}
+
+	req.VoterDirectoryId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse voter directory id from AddRaftVoter request",
+		}
+	}
+
+	req.Listeners, index, err = parseQuorumListeners(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse listeners from AddRaftVoter request: %v", err),
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AddRaftVoter request",
+		}
+	}
+
+	return req, nil
+}
+
+func parseQuorumListeners(buffer []byte, index int) ([]QuorumListener, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	if length < 0 {
+		return nil, index, fmt.Errorf("null listeners")
+	}
+
+	listeners := make([]QuorumListener, 0, length)
+	for i := 0; i < length; i++ {
+		listener := QuorumListener{}
+
+		listener.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		listener.Host, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		port, newIndex, err := parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		listener.Port = uint16(port)
+		index = newIndex
+
+		listener.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, index, nil
+}
+
+func (h *AddRaftVoterHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*AddRaftVoterRequest)
+	if !ok {
+		return nil, fmt.Errorf("AddRaftVoterHandler received %T instead of *AddRaftVoterRequest", req)
+	}
+
+	response := &RaftVoterResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	voter := raft.Voter{Id: apiReq.VoterId, DirectoryId: apiReq.VoterDirectoryId, Endpoints: []raft.Endpoint{}}
+	for _, listener := range apiReq.Listeners {
+		voter.Endpoints = append(voter.Endpoints, raft.Endpoint{Name: listener.Name, Host: listener.Host, Port: listener.Port})
+	}
+
+	err := raft.ErrNotLeader
+	if h.quorum != nil {
+		err = h.quorum.AddVoter(voter, h.now())
+	}
+	if err != nil {
+		response.ErrorCode, response.ErrorMessage = quorumErrorCode(err)
+	}
+
+	return response, nil
+}
+
+// quorumErrorCode maps the errors of the voter changes to the error codes of KIP-853
+func quorumErrorCode(err error) (int16, *string) {
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, raft.ErrNotLeader):
+		return int16(NOT_LEADER_OR_FOLLOWER), &message
+	case errors.Is(err, raft.ErrInvalidVoterKey):
+		return int16(INVALID_VOTER_KEY), &message
+	case errors.Is(err, raft.ErrDuplicateVoter):
+		return int16(DUPLICATE_VOTER), &message
+	case errors.Is(err, raft.ErrVoterNotFound):
+		return int16(VOTER_NOT_FOUND), &message
+	case errors.Is(err, raft.ErrLastVoter):
+		return int16(INVALID_REQUEST), &message
+	case errors.Is(err, raft.ErrVoterChangePending), errors.Is(err, raft.ErrVoterNotCaughtUp):
+		return int16(REQUEST_TIMED_OUT), &message
+	default:
+		return int16(UNKNOWN), &message
+	}
+}
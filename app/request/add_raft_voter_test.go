@@ -0,0 +1,146 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+func TestAddRaftVoterParseRequestBody(t *testing.T) {
+	handler := AddRaftVoterHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x3A, // MessageSize: 58
+		0x00, 0x50, // RequestApiKey: 80 (AddRaftVoter)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00,                   // ClusterId: null
+		0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+		0x00, 0x00, 0x00, 0x02, // VoterId: 2
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // VoterDirectoryId
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+		0x02,                                                   // Listeners array length: 1
+		0x0B, 'C', 'O', 'N', 'T', 'R', 'O', 'L', 'L', 'E', 'R', // Name: "CONTROLLER"
+		0x02, 'h', // Host: "h"
+		0x23, 0x86, // Port: 9094
+		0x00, // Listener tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 80, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*AddRaftVoterRequest)
+	if !ok {
+		t.Fatalf("expected *AddRaftVoterRequest, got %T", got)
+	}
+
+	want := &AddRaftVoterRequest{
+		Header:           header,
+		ClusterId:        nil,
+		TimeoutMs:        30000,
+		VoterId:          2,
+		VoterDirectoryId: "00000000-0000-0000-0000-000000000002",
+		Listeners:        []QuorumListener{{Name: "CONTROLLER", Host: "h", Port: 9094, TaggedFields: map[string]string{}}},
+		TaggedFields:     map[string]string{},
+	}
+	if !reflect.DeepEqual(gotReq, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", gotReq, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:40], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAddRaftVoterHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	leader := newTestQuorum(now)
+
+	tests := []struct {
+		name          string
+		quorum        *raft.Node
+		authorizer    acl.Authorizer
+		voterId       int32
+		directoryId   string
+		wantErrorCode KafkaErrorCode
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), 2, "00000000-0000-0000-0000-000000000002", NOT_LEADER_OR_FOLLOWER},
+		{"Not authorized", leader, denyAllAuthorizer{}, 2, "00000000-0000-0000-0000-000000000002", CLUSTER_AUTHORIZATION_FAILED},
+		{"Already a voter", leader, acl.NewAclAuthorizer(nil, true), 1, "00000000-0000-0000-0000-000000000001", DUPLICATE_VOTER},
+		{"Missing directory id", leader, acl.NewAclAuthorizer(nil, true), 2, zeroUuid, INVALID_VOTER_KEY},
+		{"Replica not fetching", leader, acl.NewAclAuthorizer(nil, true), 2, "00000000-0000-0000-0000-000000000002", REQUEST_TIMED_OUT},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AddRaftVoterHandler{quorum: tt.quorum, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			request := AddRaftVoterRequest{
+				Header:           RequestHeader{RequestApiKey: 80, RequestApiVersion: 0, CorrelationId: 7},
+				VoterId:          tt.voterId,
+				VoterDirectoryId: tt.directoryId,
+				Listeners:        []QuorumListener{{Name: "CONTROLLER", Host: "localhost", Port: 9094}},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*RaftVoterResponse)
+			if !ok {
+				t.Fatalf("expected *RaftVoterResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if tt.wantErrorCode != NONE && tt.wantErrorCode != CLUSTER_AUTHORIZATION_FAILED && gotResp.ErrorMessage == nil {
+				t.Errorf("expected an error message")
+			}
+		})
+	}
+}
+
+func TestRaftVoterResponseSerialize(t *testing.T) {
+	message := "m"
+	response := RaftVoterResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     126,
+		ErrorMessage:  &message,
+		TaggedFields:  map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x0E, // MessageSize: 14
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x7E, // ErrorCode: 126 (DUPLICATE_VOTER)
+		0x02, 'm', // ErrorMessage: "m"
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
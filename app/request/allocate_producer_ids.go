@@ -0,0 +1,254 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type AllocateProducerIdsRequest struct {
+	Header       RequestHeader
+	BrokerId     int32
+	BrokerEpoch  int64
+	TaggedFields map[string]string
+}
+
+func (r *AllocateProducerIdsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AllocateProducerIdsRequest) GetApiKey() KafkaAPIKey {
+	return AllocateProducerIds
+}
+
+func (r *AllocateProducerIdsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AllocateProducerIdsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the controller channel of a broker sends to the active controller
+func (r *AllocateProducerIdsRequest) Serialize() ([]byte, error) {
+	buffer := make([]byte, 64+len(r.Header.ClientId))
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.BrokerId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.BrokerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type AllocateProducerIdsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	// -1 and 0 when no block was allocated
+	ProducerIdStart int64
+	ProducerIdLen   int32
+	TaggedFields    map[string]string
+}
+
+func (r *AllocateProducerIdsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AllocateProducerIdsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AllocateProducerIdsResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *AllocateProducerIdsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, 40)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.ProducerIdStart)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ProducerIdLen)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseAllocateProducerIdsResponse reads the response of the active controller to the controller channel
+func parseAllocateProducerIdsResponse(buffer []byte) (*AllocateProducerIdsResponse, error) {
+	response := &AllocateProducerIdsResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err == nil {
+		response.ProducerIdStart, index, err = parser.ExtractInt64(buffer, index)
+	}
+	if err == nil {
+		response.ProducerIdLen, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err == nil {
+		response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AllocateProducerIds response: %w", err)
+	}
+
+	return response, nil
+}
+
+// AllocateProducerIdsHandler allocates a block of producer ids to the transaction coordinator of a broker, on the
+// active controller. The response waits for the broker to load the allocation, so that no later block overlaps it
+type AllocateProducerIdsHandler struct {
+	controller *controller.Controller
+	commits    *metadataPurgatory
+	timeout    time.Duration
+	authorizer acl.Authorizer
+}
+
+func (h *AllocateProducerIdsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AllocateProducerIdsRequest{}
+	req.Header = requestHeader
+
+	req.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		req.BrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse broker from AllocateProducerIds request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AllocateProducerIds request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *AllocateProducerIdsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *AllocateProducerIdsHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*AllocateProducerIdsRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("AllocateProducerIdsHandler received %T instead of *AllocateProducerIdsRequest", req))
+		return
+	}
+
+	response := &AllocateProducerIdsResponse{
+		CorrelationId:   apiReq.Header.CorrelationId,
+		ErrorCode:       int16(NONE),
+		ProducerIdStart: -1,
+		TaggedFields:    make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		respond(response, nil)
+		return
+	}
+
+	err := controller.ErrNotController
+	offset := int64(-1)
+	var block controller.ProducerIdsBlock
+	if h.controller != nil {
+		block, offset, err = h.controller.AllocateProducerIds(apiReq.BrokerId, apiReq.BrokerEpoch)
+	}
+	if err != nil {
+		response.ErrorCode, _ = controllerErrorCode(err)
+		respond(response, nil)
+		return
+	}
+	response.ProducerIdStart, response.ProducerIdLen = block.FirstProducerId, block.Size
+
+	if h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	h.commits.await(h.timeout, loadedOffset(offset), func() {
+		respond(response, nil)
+	}, func() {
+		response.ErrorCode, response.ProducerIdStart, response.ProducerIdLen = int16(REQUEST_TIMED_OUT), -1, 0
+		respond(response, nil)
+	})
+}
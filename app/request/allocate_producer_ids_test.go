@@ -0,0 +1,102 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestAllocateProducerIdsParseRequestBody(t *testing.T) {
+	handler := AllocateProducerIdsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x1C, // MessageSize: 28
+		0x00, 0x43, // RequestApiKey: 67 (AllocateProducerIds)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00, 0x00, 0x00, 0x01, // BrokerId: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, // BrokerEpoch: 7
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &AllocateProducerIdsRequest{Header: header, BrokerId: 1, BrokerEpoch: 7, TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	// The request the controller channel sends is the one the controller parses
+	req := &AllocateProducerIdsRequest{Header: RequestHeader{RequestApiKey: 67, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}, BrokerId: 1, BrokerEpoch: 7, TaggedFields: map[string]string{}}
+	serialized, err := req.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(serialized, input) {
+		t.Errorf("expected the serialized request %x, got %x", input, serialized)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:25], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAllocateProducerIdsHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, _ := newTestConfigs(t, now)
+	broker, _ := loader.Image().Broker(1)
+
+	commits := newMetadataPurgatory(loader)
+	defer commits.purgatory.Shutdown()
+
+	tests := []struct {
+		name        string
+		controller  *controller.Controller
+		commits     *metadataPurgatory
+		authorizer  acl.Authorizer
+		brokerEpoch int64
+		want        KafkaErrorCode
+		wantLen     int32
+	}{
+		{"Unauthorized", active, nil, denyAllAuthorizer{}, broker.Epoch, CLUSTER_AUTHORIZATION_FAILED, 0},
+		{"Not controller", nil, nil, acl.NewAclAuthorizer(nil, true), broker.Epoch, NOT_CONTROLLER, 0},
+		{"Stale broker epoch", active, nil, acl.NewAclAuthorizer(nil, true), broker.Epoch - 1, STALE_BROKER_EPOCH, 0},
+		{"Block allocated", active, nil, acl.NewAclAuthorizer(nil, true), broker.Epoch, NONE, controller.PRODUCER_ID_BLOCK_SIZE},
+		{"Block not loaded", active, commits, acl.NewAclAuthorizer(nil, true), broker.Epoch, REQUEST_TIMED_OUT, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &AllocateProducerIdsHandler{controller: tt.controller, commits: tt.commits, timeout: 10 * time.Millisecond, authorizer: tt.authorizer}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &AllocateProducerIdsRequest{
+				Header:      RequestHeader{RequestApiKey: 67, RequestApiVersion: 0, CorrelationId: 7},
+				BrokerId:    1,
+				BrokerEpoch: tt.brokerEpoch,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*AllocateProducerIdsResponse)
+			if response.ErrorCode != int16(tt.want) || response.ProducerIdLen != tt.wantLen {
+				t.Errorf("expected %s and a block of %d producer ids, got %s and %d", KafkaErrorCodeNames[tt.want], tt.wantLen, KafkaErrorCodeNames[KafkaErrorCode(response.ErrorCode)], response.ProducerIdLen)
+			}
+		})
+	}
+}
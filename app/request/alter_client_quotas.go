@@ -0,0 +1,427 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type ClientQuotaOp struct {
+	Key   string
+	Value float64
+	// Remove deletes the quota, Value is ignored
+	Remove       bool
+	TaggedFields map[string]string
+}
+
+type ClientQuotaAlteration struct {
+	Entity       []EntityComponent
+	Ops          []ClientQuotaOp
+	TaggedFields map[string]string
+}
+
+type AlterClientQuotasRequest struct {
+	Header       RequestHeader
+	Entries      []ClientQuotaAlteration
+	ValidateOnly bool
+	TaggedFields map[string]string
+}
+
+func (r *AlterClientQuotasRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AlterClientQuotasRequest) GetApiKey() KafkaAPIKey {
+	return AlterClientQuotas
+}
+
+func (r *AlterClientQuotasRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AlterClientQuotasRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AlterClientQuotasResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	Entity       []EntityComponent
+	TaggedFields map[string]string
+}
+
+type AlterClientQuotasResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Entries       []AlterClientQuotasResult
+	TaggedFields  map[string]string
+}
+
+func (r *AlterClientQuotasResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AlterClientQuotasResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AlterClientQuotasResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.Entries {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *AlterClientQuotasResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, entry := range r.Entries {
+		bufferSize += 16 + entityComponentsSize(entry.Entity)
+		if entry.ErrorMessage != nil {
+			bufferSize += len(*entry.ErrorMessage)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Entries)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range r.Entries {
+		index, err = serializer.SerializeInt16(buffer, index, entry.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, entry.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializeEntityComponents(buffer, index, entry.Entity)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, entry.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type AlterClientQuotasHandler struct {
+	controller *controller.Controller
+	// nil to answer without waiting for the quotas
+	commits    *metadataPurgatory
+	timeout    time.Duration
+	authorizer acl.Authorizer
+}
+
+func (h *AlterClientQuotasHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AlterClientQuotasRequest{}
+	req.Header = requestHeader
+
+	entriesLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || entriesLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse entries length from AlterClientQuotas request",
+		}
+	}
+
+	req.Entries = make([]ClientQuotaAlteration, 0, entriesLength)
+
+	for i := 0; i < entriesLength; i++ {
+		entry := ClientQuotaAlteration{}
+
+		componentsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || componentsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse entity length from AlterClientQuotas request at index %d", i),
+			}
+		}
+		index = newIndex
+
+		entry.Entity = make([]EntityComponent, 0, componentsLength)
+		for j := 0; j < componentsLength; j++ {
+			component := EntityComponent{}
+
+			component.EntityType, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse entity type from AlterClientQuotas request at index %d", i),
+				}
+			}
+
+			component.EntityName, index, err = parser.ExtractCompactNullableString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse entity name from AlterClientQuotas request at index %d", i),
+				}
+			}
+
+			component.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse entity tagged fields from AlterClientQuotas request",
+				}
+			}
+
+			entry.Entity = append(entry.Entity, component)
+		}
+
+		opsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || opsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse ops length from AlterClientQuotas request at index %d", i),
+			}
+		}
+		index = newIndex
+
+		entry.Ops = make([]ClientQuotaOp, 0, opsLength)
+		for j := 0; j < opsLength; j++ {
+			op := ClientQuotaOp{}
+
+			op.Key, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse op key from AlterClientQuotas request at index %d", i),
+				}
+			}
+
+			op.Value, index, err = parser.ExtractFloat64(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse op value from AlterClientQuotas request at index %d", i),
+				}
+			}
+
+			op.Remove, index, err = parser.ExtractBoolean(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse op remove from AlterClientQuotas request at index %d", i),
+				}
+			}
+
+			op.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse op tagged fields from AlterClientQuotas request",
+				}
+			}
+
+			entry.Ops = append(entry.Ops, op)
+		}
+
+		entry.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse entry tagged fields from AlterClientQuotas request",
+			}
+		}
+
+		req.Entries = append(req.Entries, entry)
+	}
+
+	req.ValidateOnly, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse validate only from AlterClientQuotas request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AlterClientQuotas request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *AlterClientQuotasHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *AlterClientQuotasHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*AlterClientQuotasRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("AlterClientQuotasHandler received %T instead of *AlterClientQuotasRequest", req))
+		return
+	}
+
+	// Entries succeed or fail independently, the ones that are valid are validated again by the controller
+	entryErrors := make([]error, len(apiReq.Entries))
+	alterations := []controller.ClientQuotaAlteration{}
+	alterationEntries := []int{}
+	for i, entry := range apiReq.Entries {
+		alteration, err := quotaAlteration(entry)
+		if err == nil {
+			err = quota.Validate(alteration.Entity, alteration.Ops)
+		}
+		if err != nil {
+			entryErrors[i] = err
+			continue
+		}
+		alterations = append(alterations, alteration)
+		alterationEntries = append(alterationEntries, i)
+	}
+
+	// The quotas are metadata records, the active controller appends them
+	err := apiReq.Validate()
+	if err == nil && !session.authorize(h.authorizer, acl.ALTER_CONFIGS, acl.CLUSTER, acl.ClusterResourceName) {
+		err = &RequestParseError{Code: CLUSTER_AUTHORIZATION_FAILED, Message: "Not authorized to alter the client quotas"}
+	}
+	if err == nil && !apiReq.ValidateOnly && h.controller == nil {
+		err = controller.ErrNotController
+	}
+	offset := int64(-1)
+	if err == nil && !apiReq.ValidateOnly {
+		var controllerErrors []error
+		controllerErrors, offset, err = h.controller.AlterClientQuotas(alterations)
+		for i, controllerErr := range controllerErrors {
+			entryErrors[alterationEntries[i]] = controllerErr
+		}
+	}
+
+	response := &AlterClientQuotasResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Entries:       make([]AlterClientQuotasResult, 0, len(apiReq.Entries)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	for i, entry := range apiReq.Entries {
+		result := AlterClientQuotasResult{
+			Entity:       entry.Entity,
+			TaggedFields: make(map[string]string),
+		}
+
+		entryErr := err
+		if entryErr == nil {
+			entryErr = entryErrors[i]
+		}
+		if entryErr != nil {
+			result.ErrorCode, result.ErrorMessage = configErrorCode(entryErr)
+		}
+
+		response.Entries = append(response.Entries, result)
+	}
+
+	if offset < 0 || h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	// The response waits for the broker to load the quotas, so that they apply to the next requests
+	h.commits.await(h.timeout, loadedOffset(offset), func() {
+		respond(response, nil)
+	}, func() {
+		message := "the client quota was not loaded before the timeout"
+		for i := range response.Entries {
+			if response.Entries[i].ErrorCode == int16(NONE) {
+				response.Entries[i].ErrorCode, response.Entries[i].ErrorMessage = int16(REQUEST_TIMED_OUT), &message
+			}
+		}
+		respond(response, nil)
+	})
+}
+
+// quotaAlteration reads the entity and the ops of one entry
+func quotaAlteration(entry ClientQuotaAlteration) (controller.ClientQuotaAlteration, error) {
+	entity, err := quotaEntity(entry.Entity)
+	if err != nil {
+		return controller.ClientQuotaAlteration{}, err
+	}
+
+	ops := make([]quota.Op, 0, len(entry.Ops))
+	for _, op := range entry.Ops {
+		ops = append(ops, quota.Op{Key: op.Key, Value: op.Value, Remove: op.Remove})
+	}
+
+	return controller.ClientQuotaAlteration{Entity: entity, Ops: ops}, nil
+}
+
+func quotaEntity(components []EntityComponent) (quota.Entity, error) {
+	entity := quota.Entity{}
+
+	for _, component := range components {
+		name := quota.Default
+		if component.EntityName != nil {
+			name = quota.Specific(*component.EntityName)
+		}
+
+		var target *quota.Name
+		switch component.EntityType {
+		case quota.USER:
+			target = &entity.User
+		case quota.CLIENT_ID:
+			target = &entity.ClientId
+		default:
+			return quota.Entity{}, &RequestParseError{Code: INVALID_REQUEST, Message: "Unsupported quota entity type: " + component.EntityType}
+		}
+
+		if target.Kind != quota.ABSENT {
+			return quota.Entity{}, &RequestParseError{Code: INVALID_REQUEST, Message: "Duplicate entity type: " + component.EntityType}
+		}
+		*target = name
+	}
+
+	return entity, nil
+}
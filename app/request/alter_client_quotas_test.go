@@ -0,0 +1,219 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+)
+
+func TestAlterClientQuotasParseRequestBody(t *testing.T) {
+	handler := AlterClientQuotasHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x3E, // MessageSize: 62
+		0x00, 0x31, // RequestApiKey: 49 (AlterClientQuotas)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                                              // Entries array length: 1
+		0x02,                                              // Entity array length: 1
+		0x0A, 'c', 'l', 'i', 'e', 'n', 't', '-', 'i', 'd', // EntityType: "client-id"
+		0x00,                                                                                           // EntityName: null (default)
+		0x00,                                                                                           // Entity tagged fields
+		0x02,                                                                                           // Ops array length: 1
+		0x13, 'p', 'r', 'o', 'd', 'u', 'c', 'e', 'r', '_', 'b', 'y', 't', 'e', '_', 'r', 'a', 't', 'e', // Key: "producer_byte_rate"
+		0x40, 0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Value: 1024.0
+		0x00, // Remove: false
+		0x00, // Op tagged fields
+		0x00, // Entry tagged fields
+		0x01, // ValidateOnly: true
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 49, RequestApiVersion: 1, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*AlterClientQuotasRequest)
+	if !ok {
+		t.Fatalf("expected *AlterClientQuotasRequest, got %T", got)
+	}
+
+	want := []ClientQuotaAlteration{
+		{
+			Entity:       []EntityComponent{{EntityType: "client-id", EntityName: nil, TaggedFields: map[string]string{}}},
+			Ops:          []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1024, Remove: false, TaggedFields: map[string]string{}}},
+			TaggedFields: map[string]string{},
+		},
+	}
+	if !reflect.DeepEqual(gotReq.Entries, want) {
+		t.Errorf("Entries mismatch: got %+v, want %+v", gotReq.Entries, want)
+	}
+
+	if !gotReq.ValidateOnly {
+		t.Errorf("expected ValidateOnly")
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAlterClientQuotasHandleRequest(t *testing.T) {
+	now := time.UnixMilli(0)
+	alice := []EntityComponent{{EntityType: "user", EntityName: stringPtr("alice")}}
+
+	tests := []struct {
+		name           string
+		authorizer     acl.Authorizer
+		controller     *controller.Controller
+		entries        []ClientQuotaAlteration
+		validateOnly   bool
+		wantErrorCodes []int16
+		wantQuotas     map[quota.Entity]map[string]float64
+	}{
+		{
+			name:       "Set quotas",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			controller: newTestController(now),
+			entries: []ClientQuotaAlteration{
+				{Entity: alice, Ops: []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1024}}},
+				{Entity: []EntityComponent{{EntityType: "client-id"}}, Ops: []ClientQuotaOp{{Key: "request_percentage", Value: 25}}},
+			},
+			wantErrorCodes: []int16{0, 0},
+			wantQuotas: map[quota.Entity]map[string]float64{
+				{User: quota.Specific("alice")}: {quota.PRODUCER_BYTE_RATE: 1024},
+				{ClientId: quota.Default}:       {quota.REQUEST_PERCENTAGE: 25},
+			},
+		},
+		{
+			name:           "Validate only",
+			authorizer:     acl.NewAclAuthorizer(nil, true),
+			controller:     newTestController(now),
+			entries:        []ClientQuotaAlteration{{Entity: alice, Ops: []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1024}}}},
+			validateOnly:   true,
+			wantErrorCodes: []int16{0},
+			wantQuotas:     map[quota.Entity]map[string]float64{},
+		},
+		{
+			name:       "Invalid entries fail on their own",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			controller: newTestController(now),
+			entries: []ClientQuotaAlteration{
+				{Entity: alice, Ops: []ClientQuotaOp{{Key: "unknown_rate", Value: 1}}},
+				{Entity: []EntityComponent{{EntityType: "ip"}}, Ops: []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1}}},
+				{Entity: append(alice, alice...), Ops: []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1}}},
+				{Entity: alice, Ops: []ClientQuotaOp{{Key: "consumer_byte_rate", Value: 2048}}},
+			},
+			wantErrorCodes: []int16{int16(INVALID_REQUEST), int16(INVALID_REQUEST), int16(INVALID_REQUEST), 0},
+			wantQuotas: map[quota.Entity]map[string]float64{
+				{User: quota.Specific("alice")}: {quota.CONSUMER_BYTE_RATE: 2048},
+			},
+		},
+		{
+			name:           "Not authorized",
+			authorizer:     denyAllAuthorizer{},
+			controller:     newTestController(now),
+			entries:        []ClientQuotaAlteration{{Entity: alice, Ops: []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1024}}}},
+			wantErrorCodes: []int16{int16(CLUSTER_AUTHORIZATION_FAILED)},
+			wantQuotas:     map[quota.Entity]map[string]float64{},
+		},
+		{
+			name:           "Not a controller",
+			authorizer:     acl.NewAclAuthorizer(nil, true),
+			entries:        []ClientQuotaAlteration{{Entity: alice, Ops: []ClientQuotaOp{{Key: "producer_byte_rate", Value: 1024}}}},
+			wantErrorCodes: []int16{int16(NOT_CONTROLLER)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AlterClientQuotasHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := AlterClientQuotasRequest{
+				Header:       RequestHeader{RequestApiKey: 49, RequestApiVersion: 1, CorrelationId: 7},
+				Entries:      tt.entries,
+				ValidateOnly: tt.validateOnly,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*AlterClientQuotasResponse)
+			if !ok {
+				t.Fatalf("expected *AlterClientQuotasResponse, got %T", got)
+			}
+
+			errorCodes := []int16{}
+			for _, entry := range gotResp.Entries {
+				errorCodes = append(errorCodes, entry.ErrorCode)
+			}
+			if !reflect.DeepEqual(errorCodes, tt.wantErrorCodes) {
+				t.Errorf("error codes mismatch: got %v, want %v", errorCodes, tt.wantErrorCodes)
+			}
+
+			// The quotas are the records the controller appended
+			if tt.controller == nil {
+				return
+			}
+			tt.controller.Node().Poll(now)
+			if got := tt.controller.Image().ClientQuotas(); !reflect.DeepEqual(got, tt.wantQuotas) {
+				t.Errorf("quotas mismatch: got %v, want %v", got, tt.wantQuotas)
+			}
+		})
+	}
+}
+
+func TestAlterClientQuotasResponseSerialize(t *testing.T) {
+	response := AlterClientQuotasResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Entries: []AlterClientQuotasResult{
+			{
+				ErrorCode:    0,
+				ErrorMessage: nil,
+				Entity:       []EntityComponent{{EntityType: "client-id", EntityName: nil, TaggedFields: map[string]string{}}},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x1C, // MessageSize: 28
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,       // Entries array length: 1
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                                              // ErrorMessage: null
+		0x02,                                              // Entity array length: 1
+		0x0A, 'c', 'l', 'i', 'e', 'n', 't', '-', 'i', 'd', // EntityType: "client-id"
+		0x00, // EntityName: null (default)
+		0x00, // Entity tagged fields
+		0x00, // Entry tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -0,0 +1,356 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type AlterableConfig struct {
+	Name         string
+	Value        *string
+	TaggedFields map[string]string
+}
+
+type AlterConfigsResource struct {
+	ResourceType int8
+	ResourceName string
+	Configs      []AlterableConfig
+	TaggedFields map[string]string
+}
+
+type AlterConfigsRequest struct {
+	Header       RequestHeader
+	Resources    []AlterConfigsResource
+	ValidateOnly bool
+	TaggedFields map[string]string
+}
+
+func (r *AlterConfigsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AlterConfigsRequest) GetApiKey() KafkaAPIKey {
+	return AlterConfigs
+}
+
+func (r *AlterConfigsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AlterConfigsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 2 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AlterConfigsResourceResponse struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	ResourceType int8
+	ResourceName string
+	TaggedFields map[string]string
+}
+
+type AlterConfigsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Responses     []AlterConfigsResourceResponse
+	TaggedFields  map[string]string
+}
+
+func (r *AlterConfigsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AlterConfigsResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTime = throttleTimeMs }
+
+func (r *AlterConfigsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.Responses {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *AlterConfigsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	return serializeAlterConfigsResponse(r.CorrelationId, r.ThrottleTime, r.Responses, r.TaggedFields)
+}
+
+// AlterConfigs and IncrementalAlterConfigs share the same response layout
+func serializeAlterConfigsResponse(correlationId int32, throttleTime int32, responses []AlterConfigsResourceResponse, taggedFields map[string]string) ([]byte, error) {
+	bufferSize := 64
+	for _, response := range responses {
+		bufferSize += 32 + len(response.ResourceName)
+		if response.ErrorMessage != nil {
+			bufferSize += len(*response.ErrorMessage)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, taggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, throttleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(responses)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, response := range responses {
+		index, err = serializer.SerializeInt16(buffer, index, response.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, response.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt8(buffer, index, response.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, response.ResourceName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, response.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, taggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type AlterConfigsHandler struct {
+	configs    *config.Store
+	controller *controller.Controller
+	// nil when this node is not a broker of a metadata quorum
+	channel    *ControllerChannel
+	authorizer acl.Authorizer
+}
+
+func (h *AlterConfigsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AlterConfigsRequest{}
+	req.Header = requestHeader
+
+	resourcesLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse resources length from AlterConfigs request",
+		}
+	}
+
+	resources := make([]AlterConfigsResource, 0, max(resourcesLength, 0))
+
+	for i := 0; i < resourcesLength; i++ {
+		resource := AlterConfigsResource{}
+
+		resource.ResourceType, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource type from AlterConfigs request at index %d", i),
+			}
+		}
+
+		resource.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource name from AlterConfigs request at index %d", i),
+			}
+		}
+
+		var configsLength int
+		configsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse configs length from AlterConfigs request at index %d", i),
+			}
+		}
+
+		resource.Configs = make([]AlterableConfig, 0, max(configsLength, 0))
+
+		for j := 0; j < configsLength; j++ {
+			alterableConfig := AlterableConfig{}
+
+			alterableConfig.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse config name from AlterConfigs request at index %d", j),
+				}
+			}
+
+			alterableConfig.Value, index, err = parser.ExtractCompactNullableString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse config value from AlterConfigs request at index %d", j),
+				}
+			}
+
+			alterableConfig.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse config tagged fields from AlterConfigs request",
+				}
+			}
+
+			resource.Configs = append(resource.Configs, alterableConfig)
+		}
+
+		resource.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse resource tagged fields from AlterConfigs request",
+			}
+		}
+
+		resources = append(resources, resource)
+	}
+
+	req.Resources = resources
+
+	req.ValidateOnly, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse validate only from AlterConfigs request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AlterConfigs request",
+		}
+	}
+
+	return req, nil
+}
+
+// alterConfigs has the active controller commit the changes to the configs of a resource to the metadata log, the
+// store of every broker loads them from there. A broker whose own controller is not the active one forwards the
+// changes through its controller channel. Without a metadata quorum there is neither, the changes apply to the store
+// of the broker right away
+func alterConfigs(configs *config.Store, metadataController *controller.Controller, channel *ControllerChannel, resource config.Resource, changes map[string]*string, validateOnly bool) error {
+	switch {
+	case metadataController != nil && (channel == nil || metadataController.IsActive()):
+		return metadataController.AlterConfigs(resource, changes, validateOnly)
+	case channel != nil:
+		return channel.AlterConfigs(resource, changes, validateOnly)
+	}
+
+	if !validateOnly {
+		configs.Apply(resource, changes)
+	}
+	return nil
+}
+
+// authorizeAlterConfigs checks that the client may alter the configs of a resource. Brokers forward the requests they
+// authorized to the active controller, a principal allowed the CLUSTER_ACTION of brokers may alter any config
+func authorizeAlterConfigs(authorizer acl.Authorizer, session *Session, resourceType int8, resourceName string) error {
+	if session.authorize(authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		return nil
+	}
+	return authorizeConfigResource(authorizer, session, acl.ALTER_CONFIGS, resourceType, resourceName)
+}
+
+func (h *AlterConfigsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*AlterConfigsRequest)
+	if !ok {
+		return nil, fmt.Errorf("AlterConfigsHandler received %T instead of *AlterConfigsRequest", req)
+	}
+
+	versionErr := apiReq.Validate()
+	responses := make([]AlterConfigsResourceResponse, 0, len(apiReq.Resources))
+
+	for _, resource := range apiReq.Resources {
+		response := AlterConfigsResourceResponse{
+			ResourceType: resource.ResourceType,
+			ResourceName: resource.ResourceName,
+			TaggedFields: make(map[string]string),
+		}
+
+		err := versionErr
+		if err == nil {
+			err = authorizeAlterConfigs(h.authorizer, session, resource.ResourceType, resource.ResourceName)
+		}
+
+		if err == nil {
+			// AlterConfigs replaces the whole set of dynamic configs, so a null value simply leaves the config out
+			configs := make(map[string]string)
+			for _, alterableConfig := range resource.Configs {
+				if alterableConfig.Value != nil {
+					configs[alterableConfig.Name] = *alterableConfig.Value
+				}
+			}
+
+			configResource := config.Resource{Type: config.ResourceType(resource.ResourceType), Name: resource.ResourceName}
+			var changes map[string]*string
+			changes, err = h.configs.Alter(configResource, configs)
+			if err == nil {
+				err = alterConfigs(h.configs, h.controller, h.channel, configResource, changes, apiReq.ValidateOnly)
+			}
+		}
+
+		if err != nil {
+			response.ErrorCode, response.ErrorMessage = configErrorCode(err)
+		}
+
+		responses = append(responses, response)
+	}
+
+	response := &AlterConfigsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Responses:     responses,
+		TaggedFields:  make(map[string]string),
+	}
+
+	return response, nil
+}
@@ -0,0 +1,237 @@
+package request
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+// newTestConfigs returns the active controller of a broker with the topics foo and bar, the loader of the broker and
+// its config store, which loads the configs the controller commits
+func newTestConfigs(t *testing.T, now time.Time) (*controller.Controller, *metadata.Loader, *config.Store) {
+	t.Helper()
+
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+	if _, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 1, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"foo", "bar"} {
+		if _, err := active.CreateTopic(topic, [][]int32{{1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active.Node().Poll(now)
+
+	configs := config.NewStore(1, map[string]string{})
+	loader.Subscribe(func(image *metadata.Image) {
+		configs.Load(image.AllConfigs())
+	})
+	return active, loader, configs
+}
+
+func TestAlterConfigsParseRequestBody(t *testing.T) {
+	handler := AlterConfigsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2D, // MessageSize: 45
+		0x00, 0x21, // RequestApiKey: 33 (AlterConfigs)
+		0x00, 0x02, // RequestApiVersion: 2
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // Resources array length (1 resource + 1)
+		0x02,                // ResourceType: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceName: "foo"
+		0x03,                                                             // Configs array length (2 configs + 1)
+		0x0D, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's', // Name: "retention.ms"
+		0x02, '1', // Value: "1"
+		0x00,      // Config tagged fields
+		0x02, 'x', // Name: "x"
+		0x00, // Value: null
+		0x00, // Config tagged fields
+		0x00, // Resource tagged fields
+		0x01, // ValidateOnly: true
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &AlterConfigsRequest{
+		Header: header,
+		Resources: []AlterConfigsResource{
+			{
+				ResourceType: 2,
+				ResourceName: "foo",
+				Configs: []AlterableConfig{
+					{Name: "retention.ms", Value: stringPtr("1"), TaggedFields: map[string]string{}},
+					{Name: "x", Value: nil, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		ValidateOnly: true,
+		TaggedFields: map[string]string{},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	_, err = handler.ParseRequestBody(header, input[:30], 19)
+	if err == nil {
+		t.Errorf("expected error for truncated request but got nil")
+	}
+}
+
+func TestAlterConfigsHandleRequest(t *testing.T) {
+	header := RequestHeader{RequestApiKey: 33, RequestApiVersion: 2, CorrelationId: 9}
+
+	tests := []struct {
+		name          string
+		request       AlterConfigsRequest
+		wantErrorCode int16
+		wantRetention string
+	}{
+		{
+			name: "Alter topic config",
+			request: AlterConfigsRequest{
+				Header: header,
+				Resources: []AlterConfigsResource{
+					{ResourceType: 2, ResourceName: "foo", Configs: []AlterableConfig{{Name: "retention.ms", Value: stringPtr("1")}}},
+				},
+			},
+			wantErrorCode: 0,
+			wantRetention: "1",
+		},
+		{
+			name: "Validate only",
+			request: AlterConfigsRequest{
+				Header: header,
+				Resources: []AlterConfigsResource{
+					{ResourceType: 2, ResourceName: "foo", Configs: []AlterableConfig{{Name: "retention.ms", Value: stringPtr("1")}}},
+				},
+				ValidateOnly: true,
+			},
+			wantErrorCode: 0,
+			wantRetention: "604800000",
+		},
+		{
+			name: "Invalid value",
+			request: AlterConfigsRequest{
+				Header: header,
+				Resources: []AlterConfigsResource{
+					{ResourceType: 2, ResourceName: "foo", Configs: []AlterableConfig{{Name: "retention.ms", Value: stringPtr("soon")}}},
+				},
+			},
+			wantErrorCode: int16(INVALID_CONFIG),
+			wantRetention: "604800000",
+		},
+		{
+			name: "Unknown topic",
+			request: AlterConfigsRequest{
+				Header: header,
+				Resources: []AlterConfigsResource{
+					{ResourceType: 2, ResourceName: "baz", Configs: []AlterableConfig{{Name: "retention.ms", Value: stringPtr("1")}}},
+				},
+			},
+			wantErrorCode: int16(UNKNOWN_TOPIC_OR_PARTITION),
+			wantRetention: "604800000",
+		},
+		{
+			name: "Unsupported version",
+			request: AlterConfigsRequest{
+				Header: RequestHeader{RequestApiKey: 33, RequestApiVersion: 0, CorrelationId: 9},
+				Resources: []AlterConfigsResource{
+					{ResourceType: 2, ResourceName: "foo", Configs: []AlterableConfig{{Name: "retention.ms", Value: stringPtr("1")}}},
+				},
+			},
+			wantErrorCode: int16(UNSUPPORTED_VERSION),
+			wantRetention: "604800000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.UnixMilli(1_000_000)
+			active, _, configs := newTestConfigs(t, now)
+			handler := AlterConfigsHandler{configs: configs, controller: active, authorizer: acl.NewAclAuthorizer(nil, true)}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &tt.request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// The broker sees the change once the controller committed it
+			active.Node().Poll(now)
+
+			gotResp, ok := got.(*AlterConfigsResponse)
+			if !ok {
+				t.Fatalf("expected *AlterConfigsResponse, got %T", got)
+			}
+
+			if gotResp.CorrelationId != 9 {
+				t.Errorf("CorrelationId mismatch: got %d, want 9", gotResp.CorrelationId)
+			}
+
+			if len(gotResp.Responses) != 1 {
+				t.Fatalf("Responses length mismatch: got %d, want 1", len(gotResp.Responses))
+			}
+
+			if gotResp.Responses[0].ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.Responses[0].ErrorCode, tt.wantErrorCode)
+			}
+
+			retention, err := configs.Value(config.Resource{Type: config.TOPIC, Name: "foo"}, "retention.ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if retention != tt.wantRetention {
+				t.Errorf("retention.ms mismatch: got %s, want %s", retention, tt.wantRetention)
+			}
+		})
+	}
+}
+
+func TestAlterConfigsHandleRequestWithoutQuorum(t *testing.T) {
+	// Without a metadata quorum the broker applies the changes to its own store
+	configs := config.NewStore(1, map[string]string{})
+	handler := AlterConfigsHandler{configs: configs, authorizer: acl.NewAclAuthorizer(nil, true)}
+	request := AlterConfigsRequest{
+		Header: RequestHeader{RequestApiKey: 33, RequestApiVersion: 2, CorrelationId: 9},
+		Resources: []AlterConfigsResource{
+			{ResourceType: 2, ResourceName: "foo", Configs: []AlterableConfig{{Name: "retention.ms", Value: stringPtr("1")}}},
+		},
+	}
+
+	got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotResp := got.(*AlterConfigsResponse)
+	if len(gotResp.Responses) != 1 || gotResp.Responses[0].ErrorCode != int16(NONE) {
+		t.Errorf("expected NONE, got %+v", gotResp.Responses)
+	}
+
+	if retention, _ := configs.Value(config.Resource{Type: config.TOPIC, Name: "foo"}, "retention.ms"); retention != "1" {
+		t.Errorf("retention.ms mismatch: got %s, want 1", retention)
+	}
+}
@@ -0,0 +1,569 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type AlterPartitionPartition struct {
+	PartitionIndex      int32
+	LeaderEpoch         int32
+	NewIsr              []int32
+	LeaderRecoveryState int8
+	PartitionEpoch      int32
+	TaggedFields        map[string]string
+}
+
+type AlterPartitionTopic struct {
+	TopicId      string
+	Partitions   []AlterPartitionPartition
+	TaggedFields map[string]string
+}
+
+type AlterPartitionRequest struct {
+	Header RequestHeader
+	// The leader that proposes the ISR changes, a broker epoch of -1 is not checked
+	BrokerId     int32
+	BrokerEpoch  int64
+	Topics       []AlterPartitionTopic
+	TaggedFields map[string]string
+}
+
+func (r *AlterPartitionRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AlterPartitionRequest) GetApiKey() KafkaAPIKey {
+	return AlterPartition
+}
+
+func (r *AlterPartitionRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AlterPartitionRequest) Validate() error {
+	if r.Header.RequestApiVersion != 2 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the controller channel of a broker sends to the active controller
+func (r *AlterPartitionRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	for _, topic := range r.Topics {
+		bufferSize += 24
+		for _, partition := range topic.Partitions {
+			bufferSize += 24 + 4*len(partition.NewIsr)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.BrokerId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.BrokerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeInt32Array(buffer, index, partition.NewIsr)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, partition.LeaderRecoveryState)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type AlterPartitionPartitionResponse struct {
+	PartitionIndex      int32
+	ErrorCode           int16
+	LeaderId            int32
+	LeaderEpoch         int32
+	Isr                 []int32
+	LeaderRecoveryState int8
+	PartitionEpoch      int32
+	TaggedFields        map[string]string
+}
+
+type AlterPartitionTopicResponse struct {
+	TopicId      string
+	Partitions   []AlterPartitionPartitionResponse
+	TaggedFields map[string]string
+}
+
+type AlterPartitionResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	Topics        []AlterPartitionTopicResponse
+	TaggedFields  map[string]string
+}
+
+func (r *AlterPartitionResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AlterPartitionResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AlterPartitionResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, topic := range r.Topics {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *AlterPartitionResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, topic := range r.Topics {
+		bufferSize += 24
+		for _, partition := range topic.Partitions {
+			bufferSize += 32 + 4*len(partition.Isr)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, value := range []int32{partition.LeaderId, partition.LeaderEpoch} {
+				index, err = serializer.SerializeInt32(buffer, index, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializeInt32Array(buffer, index, partition.Isr)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, partition.LeaderRecoveryState)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseAlterPartitionResponse reads the response of the active controller to the controller channel
+func parseAlterPartitionResponse(buffer []byte) (*AlterPartitionResponse, error) {
+	response := &AlterPartitionResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AlterPartition response: %w", err)
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, fmt.Errorf("failed to parse topics length from AlterPartition response")
+	}
+
+	response.Topics = make([]AlterPartitionTopicResponse, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := AlterPartitionTopicResponse{}
+
+		topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic id from AlterPartition response: %w", err)
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, fmt.Errorf("failed to parse partitions length from AlterPartition response")
+		}
+
+		topic.Partitions = make([]AlterPartitionPartitionResponse, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := AlterPartitionPartitionResponse{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderId, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.Isr, index, err = parseInt32Array(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderRecoveryState, index, err = parser.ExtractInt8(buffer, index)
+			}
+			if err == nil {
+				partition.PartitionEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse partition from AlterPartition response: %w", err)
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic tagged fields from AlterPartition response: %w", err)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tagged fields from AlterPartition response: %w", err)
+	}
+
+	return response, nil
+}
+
+// AlterPartitionHandler applies the ISR changes the leaders of partitions propose, on the active controller
+type AlterPartitionHandler struct {
+	controller *controller.Controller
+	authorizer acl.Authorizer
+}
+
+func (h *AlterPartitionHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AlterPartitionRequest{}
+	req.Header = requestHeader
+
+	req.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		req.BrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse broker from AlterPartition request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from AlterPartition request",
+		}
+	}
+
+	req.Topics = make([]AlterPartitionTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := AlterPartitionTopic{}
+
+		topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic id from AlterPartition request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from AlterPartition request",
+			}
+		}
+
+		topic.Partitions = make([]AlterPartitionPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := AlterPartitionPartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.NewIsr, index, err = parseInt32Array(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderRecoveryState, index, err = parser.ExtractInt8(buffer, index)
+			}
+			if err == nil {
+				partition.PartitionEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition from AlterPartition request at index %d", j),
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from AlterPartition request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AlterPartition request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *AlterPartitionHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*AlterPartitionRequest)
+	if !ok {
+		return nil, fmt.Errorf("AlterPartitionHandler received %T instead of *AlterPartitionRequest", req)
+	}
+
+	response := &AlterPartitionResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Topics:        make([]AlterPartitionTopicResponse, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	if h.controller == nil || !h.controller.IsActive() {
+		response.ErrorCode = int16(NOT_CONTROLLER)
+		return response, nil
+	}
+
+	image := h.controller.Image()
+	if apiReq.BrokerEpoch >= 0 {
+		broker, ok := image.Broker(apiReq.BrokerId)
+		if !ok || broker.Epoch != apiReq.BrokerEpoch {
+			response.ErrorCode = int16(STALE_BROKER_EPOCH)
+			return response, nil
+		}
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := AlterPartitionTopicResponse{TopicId: requestTopic.TopicId, Partitions: []AlterPartitionPartitionResponse{}, TaggedFields: map[string]string{}}
+		topicImage, known := image.TopicById(requestTopic.TopicId)
+
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := AlterPartitionPartitionResponse{
+				PartitionIndex: requestPartition.PartitionIndex,
+				ErrorCode:      int16(NONE),
+				Isr:            []int32{},
+				TaggedFields:   map[string]string{},
+			}
+
+			if !known {
+				partition.ErrorCode = int16(UNKNOWN_TOPIC_ID)
+				topic.Partitions = append(topic.Partitions, partition)
+				continue
+			}
+
+			partitionEpoch, err := h.controller.AlterPartition(controller.IsrChange{
+				Topic:          topicImage.Name,
+				Partition:      requestPartition.PartitionIndex,
+				LeaderId:       apiReq.BrokerId,
+				LeaderEpoch:    requestPartition.LeaderEpoch,
+				PartitionEpoch: requestPartition.PartitionEpoch,
+				Isr:            requestPartition.NewIsr,
+			})
+			if err != nil {
+				partition.ErrorCode, _ = controllerErrorCode(err)
+			} else {
+				partition.LeaderId, partition.LeaderEpoch = apiReq.BrokerId, requestPartition.LeaderEpoch
+				partition.Isr, partition.PartitionEpoch = requestPartition.NewIsr, partitionEpoch
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
+
+// parseInt32Array parses a compact array of int32 that is never null
+func parseInt32Array(buffer []byte, index int) ([]int32, int, error) {
+	values, index, err := parseNullableInt32Array(buffer, index)
+	if err == nil && values == nil {
+		err = fmt.Errorf("unexpected null array")
+	}
+	return values, index, err
+}
@@ -0,0 +1,391 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type ReassignablePartition struct {
+	PartitionIndex int32
+	// The target replicas, nil to cancel the reassignment in progress
+	Replicas     []int32
+	TaggedFields map[string]string
+}
+
+type ReassignableTopic struct {
+	Name         string
+	Partitions   []ReassignablePartition
+	TaggedFields map[string]string
+}
+
+type AlterPartitionReassignmentsRequest struct {
+	Header       RequestHeader
+	TimeoutMs    int32
+	Topics       []ReassignableTopic
+	TaggedFields map[string]string
+}
+
+func (r *AlterPartitionReassignmentsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AlterPartitionReassignmentsRequest) GetApiKey() KafkaAPIKey {
+	return AlterPartitionReassignments
+}
+
+func (r *AlterPartitionReassignmentsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AlterPartitionReassignmentsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type ReassignablePartitionResponse struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	ErrorMessage   *string
+	TaggedFields   map[string]string
+}
+
+type ReassignableTopicResponse struct {
+	Name         string
+	Partitions   []ReassignablePartitionResponse
+	TaggedFields map[string]string
+}
+
+type AlterPartitionReassignmentsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	Responses     []ReassignableTopicResponse
+	TaggedFields  map[string]string
+}
+
+func (r *AlterPartitionReassignmentsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AlterPartitionReassignmentsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AlterPartitionReassignmentsResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, topic := range r.Responses {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *AlterPartitionReassignmentsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+	for _, topic := range r.Responses {
+		bufferSize += 16 + len(topic.Name)
+		for _, partition := range topic.Partitions {
+			bufferSize += 16
+			if partition.ErrorMessage != nil {
+				bufferSize += len(*partition.ErrorMessage)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Responses)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Responses {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, partition.ErrorMessage)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// AlterPartitionReassignmentsHandler starts and cancels the reassignments of partitions on the active controller
+type AlterPartitionReassignmentsHandler struct {
+	controller *controller.Controller
+	authorizer acl.Authorizer
+}
+
+func (h *AlterPartitionReassignmentsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AlterPartitionReassignmentsRequest{}
+	req.Header = requestHeader
+
+	req.TimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse timeout from AlterPartitionReassignments request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from AlterPartitionReassignments request",
+		}
+	}
+
+	req.Topics = make([]ReassignableTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := ReassignableTopic{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from AlterPartitionReassignments request at index %d", i),
+			}
+		}
+
+		partitionsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from AlterPartitionReassignments request",
+			}
+		}
+		index = newIndex
+
+		topic.Partitions = make([]ReassignablePartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := ReassignablePartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition index from AlterPartitionReassignments request",
+				}
+			}
+
+			partition.Replicas, index, err = parseNullableInt32Array(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse replicas from AlterPartitionReassignments request",
+				}
+			}
+
+			partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition tagged fields from AlterPartitionReassignments request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from AlterPartitionReassignments request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AlterPartitionReassignments request",
+		}
+	}
+
+	return req, nil
+}
+
+// parseNullableInt32Array parses a compact array of int32, a null array is nil
+func parseNullableInt32Array(buffer []byte, index int) ([]int32, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || length < 0 {
+		return nil, index, err
+	}
+
+	values := make([]int32, 0, length)
+	for i := 0; i < length; i++ {
+		var value int32
+		value, index, err = parser.ExtractInt32(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		values = append(values, value)
+	}
+
+	return values, index, nil
+}
+
+func (h *AlterPartitionReassignmentsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*AlterPartitionReassignmentsRequest)
+	if !ok {
+		return nil, fmt.Errorf("AlterPartitionReassignmentsHandler received %T instead of *AlterPartitionReassignmentsRequest", req)
+	}
+
+	response := &AlterPartitionReassignmentsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		ErrorMessage:  nil,
+		Responses:     []ReassignableTopicResponse{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+	if h.controller == nil {
+		response.ErrorCode = int16(NOT_CONTROLLER)
+		return response, nil
+	}
+
+	targets := make(map[controller.TopicPartition][]int32)
+	for _, topic := range apiReq.Topics {
+		for _, partition := range topic.Partitions {
+			targets[controller.TopicPartition{Topic: topic.Name, Partition: partition.PartitionIndex}] = partition.Replicas
+		}
+	}
+
+	results, err := h.controller.AlterPartitionReassignments(targets)
+	if err != nil {
+		response.ErrorCode, response.ErrorMessage = controllerErrorCode(err)
+		return response, nil
+	}
+
+	// The results are grouped by topic, in the order of the topic names and of the partitions
+	for _, partition := range slices.SortedFunc(maps.Keys(results), compareTopicPartitions) {
+		last := len(response.Responses) - 1
+		if last < 0 || response.Responses[last].Name != partition.Topic {
+			response.Responses = append(response.Responses, ReassignableTopicResponse{
+				Name:         partition.Topic,
+				Partitions:   []ReassignablePartitionResponse{},
+				TaggedFields: map[string]string{},
+			})
+			last++
+		}
+
+		result := ReassignablePartitionResponse{PartitionIndex: partition.Partition, ErrorCode: int16(NONE), TaggedFields: map[string]string{}}
+		if err := results[partition]; err != nil {
+			result.ErrorCode, result.ErrorMessage = reassignmentErrorCode(err)
+		}
+		response.Responses[last].Partitions = append(response.Responses[last].Partitions, result)
+	}
+
+	return response, nil
+}
+
+// reassignmentErrorCode maps the result of the reassignment of a partition to its error code and message
+func reassignmentErrorCode(err error) (int16, *string) {
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, controller.ErrNoReassignmentInProgress):
+		return int16(NO_REASSIGNMENT_IN_PROGRESS), &message
+	case errors.Is(err, controller.ErrInvalidReplicas):
+		return int16(INVALID_REPLICA_ASSIGNMENT), &message
+	case errors.Is(err, metadata.ErrUnknownTopic), errors.Is(err, metadata.ErrUnknownPartition):
+		return int16(UNKNOWN_TOPIC_OR_PARTITION), &message
+	default:
+		return controllerErrorCode(err)
+	}
+}
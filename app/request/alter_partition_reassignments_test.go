@@ -0,0 +1,220 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+// newReassigningController returns an active controller with brokers 1, 2 and 3 and a topic foo of partitions
+// on brokers 1 and 2
+func newReassigningController(t *testing.T, now time.Time, partitions int) *controller.Controller {
+	t.Helper()
+
+	active := newTestController(now)
+	for _, brokerId := range []int32{1, 2, 3} {
+		epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: brokerId, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assignments := [][]int32{}
+	for range partitions {
+		assignments = append(assignments, []int32{1, 2})
+	}
+	if _, err := active.CreateTopic("foo", assignments); err != nil {
+		t.Fatal(err)
+	}
+	return active
+}
+
+func TestAlterPartitionReassignmentsParseRequestBody(t *testing.T) {
+	handler := AlterPartitionReassignmentsHandler{}
+	header := RequestHeader{RequestApiKey: 45, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2F, // MessageSize: 47
+		0x00, 0x2D, // RequestApiKey: 45 (AlterPartitionReassignments)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x03,                   // Partitions array length: 2
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x03,                   // Replicas array length: 2
+		0x00, 0x00, 0x00, 0x02, // Replica: 2
+		0x00, 0x00, 0x00, 0x03, // Replica: 3
+		0x00,                   // Partition tagged fields
+		0x00, 0x00, 0x00, 0x01, // PartitionIndex: 1
+		0x00, // Replicas: null
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	want := &AlterPartitionReassignmentsRequest{
+		Header:    header,
+		TimeoutMs: 30000,
+		Topics: []ReassignableTopic{
+			{
+				Name: "foo",
+				Partitions: []ReassignablePartition{
+					{PartitionIndex: 0, Replicas: []int32{2, 3}, TaggedFields: map[string]string{}},
+					{PartitionIndex: 1, Replicas: nil, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:len(input)-3], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAlterPartitionReassignmentsHandleRequest(t *testing.T) {
+	active := newReassigningController(t, time.UnixMilli(1_000_000), 3)
+
+	topics := []ReassignableTopic{
+		{Name: "foo", Partitions: []ReassignablePartition{
+			{PartitionIndex: 3, Replicas: []int32{1}},
+			{PartitionIndex: 0, Replicas: []int32{2, 3}},
+			{PartitionIndex: 1, Replicas: nil},
+			{PartitionIndex: 2, Replicas: []int32{4}},
+		}},
+		{Name: "bar", Partitions: []ReassignablePartition{{PartitionIndex: 0, Replicas: []int32{1}}}},
+	}
+
+	tests := []struct {
+		name          string
+		controller    *controller.Controller
+		authorizer    acl.Authorizer
+		wantErrorCode KafkaErrorCode
+		wantResults   map[string][]KafkaErrorCode
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), NOT_CONTROLLER, map[string][]KafkaErrorCode{}},
+		{"Not authorized", active, denyAllAuthorizer{}, CLUSTER_AUTHORIZATION_FAILED, map[string][]KafkaErrorCode{}},
+		{"Reassign", active, acl.NewAclAuthorizer(nil, true), NONE, map[string][]KafkaErrorCode{
+			"bar": {UNKNOWN_TOPIC_OR_PARTITION},
+			"foo": {NONE, NO_REASSIGNMENT_IN_PROGRESS, INVALID_REPLICA_ASSIGNMENT, UNKNOWN_TOPIC_OR_PARTITION},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AlterPartitionReassignmentsHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := AlterPartitionReassignmentsRequest{
+				Header: RequestHeader{RequestApiKey: 45, RequestApiVersion: 0, CorrelationId: 7},
+				Topics: topics,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*AlterPartitionReassignmentsResponse)
+			if !ok {
+				t.Fatalf("expected *AlterPartitionReassignmentsResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+
+			gotResults := map[string][]KafkaErrorCode{}
+			for _, topic := range gotResp.Responses {
+				for i, partition := range topic.Partitions {
+					if partition.PartitionIndex != int32(i) {
+						t.Errorf("%s: expected partition %d at index %d, got %d", topic.Name, i, i, partition.PartitionIndex)
+					}
+					gotResults[topic.Name] = append(gotResults[topic.Name], KafkaErrorCode(partition.ErrorCode))
+				}
+			}
+			if !reflect.DeepEqual(gotResults, tt.wantResults) {
+				t.Errorf("results mismatch: got %v, want %v", gotResults, tt.wantResults)
+			}
+		})
+	}
+
+	reassignments, err := active.ListPartitionReassignments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int32{3}; !reflect.DeepEqual(reassignments[controller.TopicPartition{Topic: "foo", Partition: 0}].AddingReplicas, want) {
+		t.Errorf("expected foo-0 to be adding %v, got %+v", want, reassignments)
+	}
+}
+
+func TestAlterPartitionReassignmentsResponseSerialize(t *testing.T) {
+	message := "none"
+	response := &AlterPartitionReassignmentsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Responses: []ReassignableTopicResponse{
+			{
+				Name: "foo",
+				Partitions: []ReassignablePartitionResponse{
+					{PartitionIndex: 0, ErrorCode: 0, ErrorMessage: nil, TaggedFields: map[string]string{}},
+					{PartitionIndex: 1, ErrorCode: 85, ErrorMessage: &message, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x28, // MessageSize: 40
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                // ErrorMessage: null
+		0x02,                // Responses array length: 1
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x03,                   // Partitions array length: 2
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                   // ErrorMessage: null
+		0x00,                   // Partition tagged fields
+		0x00, 0x00, 0x00, 0x01, // PartitionIndex: 1
+		0x00, 0x55, // ErrorCode: 85 (NO_REASSIGNMENT_IN_PROGRESS)
+		0x05, 'n', 'o', 'n', 'e', // ErrorMessage: "none"
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
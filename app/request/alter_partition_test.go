@@ -0,0 +1,159 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestAlterPartitionRequestRoundTrip(t *testing.T) {
+	request := &AlterPartitionRequest{
+		Header:      RequestHeader{RequestApiKey: 56, RequestApiVersion: 2, CorrelationId: 66, ClientId: "test", TaggedFields: map[string]string{}},
+		BrokerId:    2,
+		BrokerEpoch: 9,
+		Topics: []AlterPartitionTopic{{
+			TopicId:      "00000000-0000-0000-0000-000000000005",
+			Partitions:   []AlterPartitionPartition{{PartitionIndex: 1, LeaderEpoch: 3, NewIsr: []int32{2, 1}, PartitionEpoch: 4, TaggedFields: map[string]string{}}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := request.Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header, index, err := ParseRequestHeader(serialized, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request.Header.MessageSize = header.MessageSize
+
+	got, err := (&AlterPartitionHandler{}).ParseRequestBody(header, serialized, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, request) {
+		t.Errorf("parsed %+v, want %+v", got, request)
+	}
+}
+
+func TestAlterPartitionResponseRoundTrip(t *testing.T) {
+	response := &AlterPartitionResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     int16(NONE),
+		Topics: []AlterPartitionTopicResponse{{
+			TopicId: "00000000-0000-0000-0000-000000000005",
+			Partitions: []AlterPartitionPartitionResponse{
+				{PartitionIndex: 0, ErrorCode: int16(NONE), LeaderId: 2, LeaderEpoch: 3, Isr: []int32{2, 1}, PartitionEpoch: 5, TaggedFields: map[string]string{}},
+				{PartitionIndex: 1, ErrorCode: int16(FENCED_LEADER_EPOCH), Isr: []int32{}, TaggedFields: map[string]string{}},
+			},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := response.Serialize(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := parseAlterPartitionResponse(serialized)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, response) {
+		t.Errorf("parsed %+v, want %+v", got, response)
+	}
+}
+
+func TestAlterPartitionHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active := newTestController(now)
+
+	epochs := map[int32]int64{}
+	for _, brokerId := range []int32{1, 2} {
+		epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: brokerId, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+		epochs[brokerId] = epoch
+	}
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+	foo, _ := active.Image().Topic("foo")
+	partition := foo.Partitions[0]
+
+	tests := []struct {
+		name           string
+		controller     *controller.Controller
+		authorizer     acl.Authorizer
+		brokerEpoch    int64
+		topicId        string
+		leaderEpoch    int32
+		wantErrorCode  KafkaErrorCode
+		wantPartition  KafkaErrorCode
+		partitionEpoch int32
+	}{
+		{"Not authorized", active, denyAllAuthorizer{}, -1, foo.Id, partition.LeaderEpoch, CLUSTER_AUTHORIZATION_FAILED, NONE, partition.PartitionEpoch},
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), -1, foo.Id, partition.LeaderEpoch, NOT_CONTROLLER, NONE, partition.PartitionEpoch},
+		{"Stale broker epoch", active, acl.NewAclAuthorizer(nil, true), epochs[1] + 1, foo.Id, partition.LeaderEpoch, STALE_BROKER_EPOCH, NONE, partition.PartitionEpoch},
+		{"Unknown topic id", active, acl.NewAclAuthorizer(nil, true), -1, zeroUuid, partition.LeaderEpoch, NONE, UNKNOWN_TOPIC_ID, partition.PartitionEpoch},
+		{"Fenced leader epoch", active, acl.NewAclAuthorizer(nil, true), -1, foo.Id, partition.LeaderEpoch + 1, NONE, FENCED_LEADER_EPOCH, partition.PartitionEpoch},
+		{"Stale partition epoch", active, acl.NewAclAuthorizer(nil, true), -1, foo.Id, partition.LeaderEpoch, NONE, INVALID_UPDATE_VERSION, partition.PartitionEpoch - 1},
+		{"Shrink", active, acl.NewAclAuthorizer(nil, true), epochs[1], foo.Id, partition.LeaderEpoch, NONE, NONE, partition.PartitionEpoch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AlterPartitionHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := &AlterPartitionRequest{
+				Header:      RequestHeader{RequestApiKey: 56, RequestApiVersion: 2, CorrelationId: 7},
+				BrokerId:    1,
+				BrokerEpoch: tt.brokerEpoch,
+				Topics: []AlterPartitionTopic{{
+					TopicId:    tt.topicId,
+					Partitions: []AlterPartitionPartition{{PartitionIndex: 0, LeaderEpoch: tt.leaderEpoch, NewIsr: []int32{1}, PartitionEpoch: tt.partitionEpoch}},
+				}},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*AlterPartitionResponse)
+			if response.ErrorCode != int16(tt.wantErrorCode) {
+				t.Fatalf("expected error %d, got %d", tt.wantErrorCode, response.ErrorCode)
+			}
+			if tt.wantErrorCode != NONE {
+				return
+			}
+			if len(response.Topics) != 1 || len(response.Topics[0].Partitions) != 1 {
+				t.Fatalf("expected one partition, got %+v", response.Topics)
+			}
+			result := response.Topics[0].Partitions[0]
+			if result.ErrorCode != int16(tt.wantPartition) {
+				t.Fatalf("expected partition error %d, got %d", tt.wantPartition, result.ErrorCode)
+			}
+			if tt.wantPartition != NONE {
+				return
+			}
+
+			active.Node().Poll(now)
+			foo, _ := active.Image().Topic("foo")
+			if !reflect.DeepEqual(foo.Partitions[0].Isr, []int32{1}) || result.PartitionEpoch != foo.Partitions[0].PartitionEpoch {
+				t.Errorf("expected the ISR to shrink to the leader, got %+v and the response %+v", foo.Partitions[0], result)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+type AlterReplicaLogDirTopic struct {
+	Name         string
+	Partitions   []int32
+	TaggedFields map[string]string
+}
+
+type AlterReplicaLogDir struct {
+	// The absolute path of the log dir the partitions move to
+	Path         string
+	Topics       []AlterReplicaLogDirTopic
+	TaggedFields map[string]string
+}
+
+type AlterReplicaLogDirsRequest struct {
+	Header       RequestHeader
+	Dirs         []AlterReplicaLogDir
+	TaggedFields map[string]string
+}
+
+func (r *AlterReplicaLogDirsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AlterReplicaLogDirsRequest) GetApiKey() KafkaAPIKey {
+	return AlterReplicaLogDirs
+}
+
+func (r *AlterReplicaLogDirsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AlterReplicaLogDirsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 2 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AlterReplicaLogDirPartitionResult struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	TaggedFields   map[string]string
+}
+
+type AlterReplicaLogDirTopicResult struct {
+	TopicName    string
+	Partitions   []AlterReplicaLogDirPartitionResult
+	TaggedFields map[string]string
+}
+
+type AlterReplicaLogDirsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Results       []AlterReplicaLogDirTopicResult
+	TaggedFields  map[string]string
+}
+
+func (r *AlterReplicaLogDirsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AlterReplicaLogDirsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AlterReplicaLogDirsResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{}
+	for _, topic := range r.Results {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *AlterReplicaLogDirsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 16
+	for _, topic := range r.Results {
+		bufferSize += 16 + len(topic.TopicName) + 8*len(topic.Partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Results)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Results {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// AlterReplicaLogDirsHandler moves the replicas of the broker between its log dirs
+type AlterReplicaLogDirsHandler struct {
+	logs       *storage.LogManager
+	authorizer acl.Authorizer
+}
+
+func (h *AlterReplicaLogDirsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AlterReplicaLogDirsRequest{}
+	req.Header = requestHeader
+
+	dirsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || dirsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse dirs length from AlterReplicaLogDirs request",
+		}
+	}
+
+	req.Dirs = make([]AlterReplicaLogDir, 0, dirsLength)
+	for i := 0; i < dirsLength; i++ {
+		dir := AlterReplicaLogDir{}
+
+		dir.Path, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse path from AlterReplicaLogDirs request at index %d", i),
+			}
+		}
+
+		var topicsLength int
+		topicsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || topicsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topics length from AlterReplicaLogDirs request",
+			}
+		}
+
+		dir.Topics = make([]AlterReplicaLogDirTopic, 0, topicsLength)
+		for j := 0; j < topicsLength; j++ {
+			topic := AlterReplicaLogDirTopic{}
+
+			topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse topic name from AlterReplicaLogDirs request at index %d", j),
+				}
+			}
+
+			topic.Partitions, index, err = parseNullableInt32Array(buffer, index)
+			if err != nil || topic.Partitions == nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partitions from AlterReplicaLogDirs request",
+				}
+			}
+
+			topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse topic tagged fields from AlterReplicaLogDirs request",
+				}
+			}
+
+			dir.Topics = append(dir.Topics, topic)
+		}
+
+		dir.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse dir tagged fields from AlterReplicaLogDirs request",
+			}
+		}
+
+		req.Dirs = append(req.Dirs, dir)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AlterReplicaLogDirs request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *AlterReplicaLogDirsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*AlterReplicaLogDirsRequest)
+	if !ok {
+		return nil, fmt.Errorf("AlterReplicaLogDirsHandler received %T instead of *AlterReplicaLogDirsRequest", req)
+	}
+
+	response := &AlterReplicaLogDirsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		Results:       []AlterReplicaLogDirTopicResult{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	authorized := session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName)
+
+	// The results are grouped by topic, in the order the topics first appear in the request
+	topicIndexes := map[string]int{}
+	for _, dir := range apiReq.Dirs {
+		for _, topic := range dir.Topics {
+			index, exists := topicIndexes[topic.Name]
+			if !exists {
+				index = len(response.Results)
+				topicIndexes[topic.Name] = index
+				response.Results = append(response.Results, AlterReplicaLogDirTopicResult{
+					TopicName:    topic.Name,
+					Partitions:   []AlterReplicaLogDirPartitionResult{},
+					TaggedFields: map[string]string{},
+				})
+			}
+
+			for _, partition := range topic.Partitions {
+				errorCode := CLUSTER_AUTHORIZATION_FAILED
+				if authorized {
+					errorCode = logDirErrorCode(h.logs.AlterReplicaLogDir(storage.TopicPartition{Topic: topic.Name, Partition: partition}, dir.Path))
+				}
+
+				response.Results[index].Partitions = append(response.Results[index].Partitions, AlterReplicaLogDirPartitionResult{
+					PartitionIndex: partition,
+					ErrorCode:      int16(errorCode),
+					TaggedFields:   map[string]string{},
+				})
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// logDirErrorCode maps the errors of the log manager to their error code
+func logDirErrorCode(err error) KafkaErrorCode {
+	switch {
+	case err == nil:
+		return NONE
+	case errors.Is(err, storage.ErrLogDirNotFound):
+		return LOG_DIR_NOT_FOUND
+	case errors.Is(err, storage.ErrKafkaStorage):
+		return KAFKA_STORAGE_ERROR
+	case errors.Is(err, storage.ErrUnknownLog):
+		return REPLICA_NOT_AVAILABLE
+	default:
+		return UNKNOWN
+	}
+}
@@ -0,0 +1,175 @@
+package request
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestAlterReplicaLogDirsParseRequestBody(t *testing.T) {
+	handler := AlterReplicaLogDirsHandler{}
+	header := RequestHeader{RequestApiKey: 34, RequestApiVersion: 2, CorrelationId: 66, ClientId: "test"}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x25, // MessageSize: 37
+		0x00, 0x22, // RequestApiKey: 34 (AlterReplicaLogDirs)
+		0x00, 0x02, // RequestApiVersion: 2
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,           // Dirs array length: 1
+		0x03, '/', 'b', // Path: "/b"
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x03,                   // Partitions array length: 2
+		0x00, 0x00, 0x00, 0x00, // Partition: 0
+		0x00, 0x00, 0x00, 0x01, // Partition: 1
+		0x00, // Topic tagged fields
+		0x00, // Dir tagged fields
+		0x00, // Request tagged fields
+	}
+
+	want := &AlterReplicaLogDirsRequest{
+		Header: header,
+		Dirs: []AlterReplicaLogDir{
+			{
+				Path: "/b",
+				Topics: []AlterReplicaLogDirTopic{
+					{Name: "foo", Partitions: []int32{0, 1}, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:len(input)-3], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestAlterReplicaLogDirsHandleRequest(t *testing.T) {
+	logs, dirs := newTestLogManager(t)
+
+	tests := []struct {
+		name        string
+		authorizer  acl.Authorizer
+		dirs        []AlterReplicaLogDir
+		wantResults map[string][]KafkaErrorCode
+	}{
+		{
+			"Not authorized",
+			denyAllAuthorizer{},
+			[]AlterReplicaLogDir{{Path: dirs[1], Topics: []AlterReplicaLogDirTopic{{Name: "bar", Partitions: []int32{0}}}}},
+			map[string][]KafkaErrorCode{"bar": {CLUSTER_AUTHORIZATION_FAILED}},
+		},
+		{
+			"Move",
+			acl.NewAclAuthorizer(nil, true),
+			[]AlterReplicaLogDir{
+				{Path: dirs[1], Topics: []AlterReplicaLogDirTopic{{Name: "bar", Partitions: []int32{0, 1}}}},
+				{Path: filepath.Join(dirs[1], "c"), Topics: []AlterReplicaLogDirTopic{{Name: "foo", Partitions: []int32{1}}}},
+				{Path: dirs[0], Topics: []AlterReplicaLogDirTopic{{Name: "bar", Partitions: []int32{2}}}},
+			},
+			map[string][]KafkaErrorCode{
+				"bar": {NONE, REPLICA_NOT_AVAILABLE, REPLICA_NOT_AVAILABLE},
+				"foo": {LOG_DIR_NOT_FOUND},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AlterReplicaLogDirsHandler{logs: logs, authorizer: tt.authorizer}
+			request := AlterReplicaLogDirsRequest{
+				Header: RequestHeader{RequestApiKey: 34, RequestApiVersion: 2, CorrelationId: 7},
+				Dirs:   tt.dirs,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*AlterReplicaLogDirsResponse)
+			if !ok {
+				t.Fatalf("expected *AlterReplicaLogDirsResponse, got %T", got)
+			}
+
+			gotResults := map[string][]KafkaErrorCode{}
+			for _, topic := range gotResp.Results {
+				for _, partition := range topic.Partitions {
+					gotResults[topic.TopicName] = append(gotResults[topic.TopicName], KafkaErrorCode(partition.ErrorCode))
+				}
+			}
+			if !reflect.DeepEqual(gotResults, tt.wantResults) {
+				t.Errorf("results mismatch: got %v, want %v", gotResults, tt.wantResults)
+			}
+		})
+	}
+
+	// bar-0 has a future log in the second dir, until it catches up
+	descriptions := logs.DescribeLogDirs()
+	want := storage.ReplicaDescription{TopicPartition: storage.TopicPartition{Topic: "bar", Partition: 0}, IsFuture: true}
+	if replicas := descriptions[1].Replicas; len(replicas) == 0 || replicas[0] != want {
+		t.Errorf("expected %+v in %s, got %+v", want, dirs[1], replicas)
+	}
+}
+
+func TestAlterReplicaLogDirsResponseSerialize(t *testing.T) {
+	response := &AlterReplicaLogDirsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Results: []AlterReplicaLogDirTopicResult{
+			{
+				TopicName: "foo",
+				Partitions: []AlterReplicaLogDirPartitionResult{
+					{PartitionIndex: 0, ErrorCode: 0, TaggedFields: map[string]string{}},
+					{PartitionIndex: 1, ErrorCode: 57, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := response.Serialize(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x1F, // MessageSize: 31
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,                // Results array length: 1
+		0x04, 'f', 'o', 'o', // TopicName: "foo"
+		0x03,                   // Partitions array length: 2
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                   // Partition tagged fields
+		0x00, 0x00, 0x00, 0x01, // PartitionIndex: 1
+		0x00, 0x39, // ErrorCode: 57 (LOG_DIR_NOT_FOUND)
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
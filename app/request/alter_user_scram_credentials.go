@@ -0,0 +1,435 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type ScramCredentialDeletion struct {
+	Name         string
+	Mechanism    int8
+	TaggedFields map[string]string
+}
+
+// ScramCredentialUpsertion carries the salted password computed by the client, the broker derives the stored and server keys from it
+type ScramCredentialUpsertion struct {
+	Name           string
+	Mechanism      int8
+	Iterations     int32
+	Salt           []byte
+	SaltedPassword []byte
+	TaggedFields   map[string]string
+}
+
+type AlterUserScramCredentialsRequest struct {
+	Header       RequestHeader
+	Deletions    []ScramCredentialDeletion
+	Upsertions   []ScramCredentialUpsertion
+	TaggedFields map[string]string
+}
+
+func (r *AlterUserScramCredentialsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *AlterUserScramCredentialsRequest) GetApiKey() KafkaAPIKey {
+	return AlterUserScramCredentials
+}
+
+func (r *AlterUserScramCredentialsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *AlterUserScramCredentialsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AlterUserScramCredentialsResult struct {
+	User         string
+	ErrorCode    int16
+	ErrorMessage *string
+	TaggedFields map[string]string
+}
+
+type AlterUserScramCredentialsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Results       []AlterUserScramCredentialsResult
+	TaggedFields  map[string]string
+}
+
+func (r *AlterUserScramCredentialsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *AlterUserScramCredentialsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *AlterUserScramCredentialsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.Results {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *AlterUserScramCredentialsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, result := range r.Results {
+		bufferSize += 32 + len(result.User)
+		if result.ErrorMessage != nil {
+			bufferSize += len(*result.ErrorMessage)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Results)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range r.Results {
+		index, err = serializer.SerializeCompactString(buffer, index, result.User)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, result.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, result.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, result.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// AlterUserScramCredentialsHandler alters credentials through the active controller, its responses wait for the broker
+// to load them
+type AlterUserScramCredentialsHandler struct {
+	controller *controller.Controller
+	// nil to answer without waiting for the credentials
+	commits    *metadataPurgatory
+	timeout    time.Duration
+	authorizer acl.Authorizer
+}
+
+func (h *AlterUserScramCredentialsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &AlterUserScramCredentialsRequest{}
+	req.Header = requestHeader
+
+	deletionsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse deletions length from AlterUserScramCredentials request",
+		}
+	}
+
+	req.Deletions = make([]ScramCredentialDeletion, 0, max(deletionsLength, 0))
+
+	for i := 0; i < deletionsLength; i++ {
+		deletion := ScramCredentialDeletion{}
+
+		deletion.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse deletion name from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		deletion.Mechanism, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse deletion mechanism from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		deletion.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse deletion tagged fields from AlterUserScramCredentials request",
+			}
+		}
+
+		req.Deletions = append(req.Deletions, deletion)
+	}
+
+	var upsertionsLength int
+	upsertionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse upsertions length from AlterUserScramCredentials request",
+		}
+	}
+
+	req.Upsertions = make([]ScramCredentialUpsertion, 0, max(upsertionsLength, 0))
+
+	for i := 0; i < upsertionsLength; i++ {
+		upsertion := ScramCredentialUpsertion{}
+
+		upsertion.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse upsertion name from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		upsertion.Mechanism, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse upsertion mechanism from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		upsertion.Iterations, index, err = parser.ExtractInt32(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse upsertion iterations from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		upsertion.Salt, index, err = parser.ExtractCompactBytes(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse upsertion salt from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		upsertion.SaltedPassword, index, err = parser.ExtractCompactBytes(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse upsertion salted password from AlterUserScramCredentials request at index %d", i),
+			}
+		}
+
+		upsertion.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse upsertion tagged fields from AlterUserScramCredentials request",
+			}
+		}
+
+		req.Upsertions = append(req.Upsertions, upsertion)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from AlterUserScramCredentials request",
+		}
+	}
+
+	return req, nil
+}
+
+// scramAlteration is a validated deletion (nil credential) or upsertion of the credential of a user for a mechanism
+type scramAlteration struct {
+	mechanism  string
+	credential *sasl.ScramCredential
+}
+
+func (h *AlterUserScramCredentialsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *AlterUserScramCredentialsHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*AlterUserScramCredentialsRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("AlterUserScramCredentialsHandler received %T instead of *AlterUserScramCredentialsRequest", req))
+		return
+	}
+
+	// Alterations are grouped by user, in the order users first appear, and applied only when all of them are valid. The
+	// controller checks that the deleted credentials exist
+	users := []string{}
+	alterations := make(map[string][]scramAlteration)
+	userErrors := make(map[string]error)
+
+	addAlteration := func(user string, mechanismType int8, credential func(mechanism string) (*sasl.ScramCredential, error)) {
+		if _, seen := alterations[user]; !seen {
+			users = append(users, user)
+			alterations[user] = []scramAlteration{}
+		}
+
+		if userErrors[user] != nil {
+			return
+		}
+
+		mechanism, ok := scramMechanismNames[mechanismType]
+		if !ok {
+			userErrors[user] = &RequestParseError{Code: UNSUPPORTED_SASL_MECHANISM, Message: fmt.Sprintf("Unknown SCRAM mechanism %d", mechanismType)}
+			return
+		}
+
+		for _, alteration := range alterations[user] {
+			if alteration.mechanism == mechanism {
+				userErrors[user] = &RequestParseError{Code: DUPLICATE_RESOURCE, Message: fmt.Sprintf("A user credential cannot be altered twice in the same request: %s %s", user, mechanism)}
+				return
+			}
+		}
+
+		scramCredential, err := credential(mechanism)
+		if err != nil {
+			userErrors[user] = err
+			return
+		}
+
+		alterations[user] = append(alterations[user], scramAlteration{mechanism: mechanism, credential: scramCredential})
+	}
+
+	for _, deletion := range apiReq.Deletions {
+		addAlteration(deletion.Name, deletion.Mechanism, func(mechanism string) (*sasl.ScramCredential, error) {
+			return nil, nil
+		})
+	}
+
+	for _, upsertion := range apiReq.Upsertions {
+		addAlteration(upsertion.Name, upsertion.Mechanism, func(mechanism string) (*sasl.ScramCredential, error) {
+			switch {
+			case upsertion.Name == "":
+				return nil, &RequestParseError{Code: UNACCEPTABLE_CREDENTIAL, Message: "Username must not be empty"}
+			case upsertion.Iterations < sasl.MinScramIterations:
+				return nil, &RequestParseError{Code: UNACCEPTABLE_CREDENTIAL, Message: fmt.Sprintf("Too few iterations: %d < %d", upsertion.Iterations, sasl.MinScramIterations)}
+			case upsertion.Iterations > sasl.MaxScramIterations:
+				return nil, &RequestParseError{Code: UNACCEPTABLE_CREDENTIAL, Message: fmt.Sprintf("Too many iterations: %d > %d", upsertion.Iterations, sasl.MaxScramIterations)}
+			case len(upsertion.Salt) == 0 || len(upsertion.SaltedPassword) == 0:
+				return nil, &RequestParseError{Code: UNACCEPTABLE_CREDENTIAL, Message: "Salt and salted password must not be empty"}
+			}
+
+			credential, err := sasl.NewScramCredentialFromSaltedPassword(mechanism, upsertion.SaltedPassword, upsertion.Salt, int(upsertion.Iterations))
+			if err != nil {
+				return nil, &RequestParseError{Code: UNSUPPORTED_SASL_MECHANISM, Message: err.Error()}
+			}
+
+			return &credential, nil
+		})
+	}
+
+	// The credentials are metadata records, the active controller appends them
+	err := apiReq.Validate()
+	if err == nil && !session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName) {
+		err = &RequestParseError{Code: CLUSTER_AUTHORIZATION_FAILED, Message: "Not authorized to alter the user SCRAM credentials"}
+	}
+	if err == nil && h.controller == nil {
+		err = controller.ErrNotController
+	}
+	offset := int64(-1)
+	if err == nil {
+		valid := []controller.ScramAlteration{}
+		for _, user := range users {
+			if userErrors[user] != nil {
+				continue
+			}
+			for _, alteration := range alterations[user] {
+				valid = append(valid, controller.ScramAlteration{User: user, Mechanism: scramMechanismTypes[alteration.mechanism], Credential: alteration.credential})
+			}
+		}
+
+		var controllerErrors map[string]error
+		controllerErrors, offset, err = h.controller.AlterUserScramCredentials(valid)
+		maps.Copy(userErrors, controllerErrors)
+	}
+
+	results := make([]AlterUserScramCredentialsResult, 0, len(users))
+	for _, user := range users {
+		result := AlterUserScramCredentialsResult{User: user, TaggedFields: make(map[string]string)}
+
+		userErr := err
+		if userErr == nil {
+			userErr = userErrors[user]
+		}
+		if userErr != nil {
+			result.ErrorCode, result.ErrorMessage = configErrorCode(userErr)
+		}
+
+		results = append(results, result)
+	}
+
+	response := &AlterUserScramCredentialsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Results:       results,
+		TaggedFields:  make(map[string]string),
+	}
+
+	if offset < 0 || h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	// The response waits for the broker to load the credentials, so that the user can authenticate right away
+	h.commits.await(h.timeout, loadedOffset(offset), func() {
+		respond(response, nil)
+	}, func() {
+		message := "the credential was not loaded before the timeout"
+		for i := range results {
+			if results[i].ErrorCode == int16(NONE) {
+				results[i].ErrorCode, results[i].ErrorMessage = int16(REQUEST_TIMED_OUT), &message
+			}
+		}
+		respond(response, nil)
+	})
+}
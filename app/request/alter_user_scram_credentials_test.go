@@ -0,0 +1,239 @@
+package request
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+func TestAlterUserScramCredentialsParseRequestBody(t *testing.T) {
+	handler := AlterUserScramCredentialsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x29, // MessageSize: 41
+		0x00, 0x33, // RequestApiKey: 51 (AlterUserScramCredentials)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // Deletions array length: 1
+		0x04, 'b', 'o', 'b', // Name: "bob"
+		0x02,                // Mechanism: 2 (SCRAM-SHA-512)
+		0x00,                // Deletion tagged fields
+		0x02,                // Upsertions array length: 1
+		0x04, 'a', 'm', 'y', // Name: "amy"
+		0x01,                   // Mechanism: 1 (SCRAM-SHA-256)
+		0x00, 0x00, 0x10, 0x00, // Iterations: 4096
+		0x03, 's', 'a', // Salt: "sa"
+		0x04, 'p', 'w', 'd', // SaltedPassword: "pwd"
+		0x00, // Upsertion tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 51, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*AlterUserScramCredentialsRequest)
+	if !ok {
+		t.Fatalf("expected *AlterUserScramCredentialsRequest, got %T", got)
+	}
+
+	if len(gotReq.Deletions) != 1 || gotReq.Deletions[0].Name != "bob" || gotReq.Deletions[0].Mechanism != 2 {
+		t.Errorf("Deletions mismatch: got %v", gotReq.Deletions)
+	}
+
+	if len(gotReq.Upsertions) != 1 {
+		t.Fatalf("Upsertions length mismatch: got %d, want 1", len(gotReq.Upsertions))
+	}
+
+	upsertion := gotReq.Upsertions[0]
+	if upsertion.Name != "amy" || upsertion.Mechanism != 1 || upsertion.Iterations != 4096 ||
+		!bytes.Equal(upsertion.Salt, []byte("sa")) || !bytes.Equal(upsertion.SaltedPassword, []byte("pwd")) {
+		t.Errorf("Upsertions[0] mismatch: got %+v", upsertion)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:36], 19); err == nil {
+		t.Errorf("expected error for a truncated upsertion but got nil")
+	}
+}
+
+func TestAlterUserScramCredentialsHandleRequest(t *testing.T) {
+	now := time.Now()
+	newController := func(t *testing.T) *controller.Controller {
+		t.Helper()
+
+		credential, err := sasl.NewScramCredential(sasl.SCRAM_SHA_256, "bob-secret", []byte("salt"), 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := newTestController(now)
+		if _, _, err := c.AlterUserScramCredentials([]controller.ScramAlteration{{User: "bob", Mechanism: 1, Credential: &credential}}); err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	upsertion := func(name string, mechanism int8, iterations int32) ScramCredentialUpsertion {
+		return ScramCredentialUpsertion{Name: name, Mechanism: mechanism, Iterations: iterations, Salt: []byte("salt"), SaltedPassword: []byte("salted")}
+	}
+
+	tests := []struct {
+		name        string
+		authorizer  acl.Authorizer
+		controller  *controller.Controller
+		deletions   []ScramCredentialDeletion
+		upsertions  []ScramCredentialUpsertion
+		wantResults []AlterUserScramCredentialsResult
+		// User -> mechanisms left in the metadata image
+		wantStored map[string][]string
+	}{
+		{
+			name:       "Upsert and delete",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			controller: newController(t),
+			deletions:  []ScramCredentialDeletion{{Name: "bob", Mechanism: 1}},
+			upsertions: []ScramCredentialUpsertion{upsertion("bob", 2, 8192), upsertion("amy", 1, 4096)},
+			wantResults: []AlterUserScramCredentialsResult{
+				{User: "bob", ErrorCode: 0},
+				{User: "amy", ErrorCode: 0},
+			},
+			wantStored: map[string][]string{"bob": {sasl.SCRAM_SHA_512}, "amy": {sasl.SCRAM_SHA_256}},
+		},
+		{
+			name:       "Invalid alterations leave the user unchanged",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			controller: newController(t),
+			deletions:  []ScramCredentialDeletion{{Name: "bob", Mechanism: 1}, {Name: "carol", Mechanism: 1}},
+			upsertions: []ScramCredentialUpsertion{upsertion("bob", 1, 4096), upsertion("dan", 3, 4096), upsertion("eve", 1, 1024)},
+			wantResults: []AlterUserScramCredentialsResult{
+				{User: "bob", ErrorCode: int16(DUPLICATE_RESOURCE)},
+				{User: "carol", ErrorCode: int16(RESOURCE_NOT_FOUND)},
+				{User: "dan", ErrorCode: int16(UNSUPPORTED_SASL_MECHANISM)},
+				{User: "eve", ErrorCode: int16(UNACCEPTABLE_CREDENTIAL)},
+			},
+			wantStored: map[string][]string{"bob": {sasl.SCRAM_SHA_256}},
+		},
+		{
+			name:       "Not authorized",
+			authorizer: denyAllAuthorizer{},
+			controller: newController(t),
+			upsertions: []ScramCredentialUpsertion{upsertion("amy", 1, 4096)},
+			wantResults: []AlterUserScramCredentialsResult{
+				{User: "amy", ErrorCode: int16(CLUSTER_AUTHORIZATION_FAILED)},
+			},
+			wantStored: map[string][]string{"bob": {sasl.SCRAM_SHA_256}},
+		},
+		{
+			name:       "Not a controller",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			upsertions: []ScramCredentialUpsertion{upsertion("amy", 1, 4096)},
+			wantResults: []AlterUserScramCredentialsResult{
+				{User: "amy", ErrorCode: int16(NOT_CONTROLLER)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := AlterUserScramCredentialsHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := AlterUserScramCredentialsRequest{
+				Header:     RequestHeader{RequestApiKey: 51, RequestApiVersion: 0, CorrelationId: 7},
+				Deletions:  tt.deletions,
+				Upsertions: tt.upsertions,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*AlterUserScramCredentialsResponse)
+			if !ok {
+				t.Fatalf("expected *AlterUserScramCredentialsResponse, got %T", got)
+			}
+
+			if len(gotResp.Results) != len(tt.wantResults) {
+				t.Fatalf("Results length mismatch: got %d, want %d", len(gotResp.Results), len(tt.wantResults))
+			}
+
+			for i, result := range gotResp.Results {
+				if result.User != tt.wantResults[i].User || result.ErrorCode != tt.wantResults[i].ErrorCode {
+					t.Errorf("Results[%d] mismatch: got %s/%d, want %s/%d", i, result.User, result.ErrorCode, tt.wantResults[i].User, tt.wantResults[i].ErrorCode)
+				}
+
+				if (result.ErrorCode != 0) != (result.ErrorMessage != nil) {
+					t.Errorf("Results[%d] ErrorMessage mismatch: got %v", i, result.ErrorMessage)
+				}
+			}
+
+			// The credentials are the records the controller appended
+			if tt.controller == nil {
+				return
+			}
+			tt.controller.Node().Poll(now)
+			credentials := scramCredentialsByName(tt.controller.Image().ScramCredentials())
+			if len(credentials) != len(tt.wantStored) {
+				t.Errorf("stored users mismatch: got %v, want %v", credentials, tt.wantStored)
+			}
+
+			for user, mechanisms := range tt.wantStored {
+				stored := credentials[user]
+				if len(stored) != len(mechanisms) {
+					t.Errorf("stored mechanisms of %s mismatch: got %d, want %v", user, len(stored), mechanisms)
+				}
+
+				for _, mechanism := range mechanisms {
+					if _, ok := stored[mechanism]; !ok {
+						t.Errorf("expected a %s credential for %s", mechanism, user)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAlterUserScramCredentialsResponseSerialize(t *testing.T) {
+	response := AlterUserScramCredentialsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Results: []AlterUserScramCredentialsResult{
+			{User: "bob", ErrorCode: 0, ErrorMessage: nil, TaggedFields: map[string]string{}},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x13, // MessageSize: 19
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,                // Results array length: 1
+		0x04, 'b', 'o', 'b', // User: "bob"
+		0x00, 0x00, // ErrorCode: 0
+		0x00, // ErrorMessage: null
+		0x00, // Result tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -51,13 +51,21 @@ const (
 	AlterClientQuotas            KafkaAPIKey = 49
 	DescribeUserScramCredentials KafkaAPIKey = 50
 	AlterUserScramCredentials    KafkaAPIKey = 51
+	Vote                         KafkaAPIKey = 52
+	BeginQuorumEpoch             KafkaAPIKey = 53
+	EndQuorumEpoch               KafkaAPIKey = 54
 	DescribeQuorum               KafkaAPIKey = 55
+	AlterPartition               KafkaAPIKey = 56
 	UpdateFeatures               KafkaAPIKey = 57
+	FetchSnapshot                KafkaAPIKey = 59
 	DescribeCluster              KafkaAPIKey = 60
 	DescribeProducers            KafkaAPIKey = 61
+	BrokerRegistration           KafkaAPIKey = 62
+	BrokerHeartbeat              KafkaAPIKey = 63
 	UnregisterBroker             KafkaAPIKey = 64
 	DescribeTransactions         KafkaAPIKey = 65
 	ListTransactions             KafkaAPIKey = 66
+	AllocateProducerIds          KafkaAPIKey = 67
 	ConsumerGroupHeartbeat       KafkaAPIKey = 68
 	ConsumerGroupDescribe        KafkaAPIKey = 69
 	GetTelemetrySubscriptions    KafkaAPIKey = 71
@@ -68,9 +76,84 @@ const (
 	RemoveRaftVoter              KafkaAPIKey = 81
 )
 
+// KafkaAPIKeyNames are the names Kafka gives to the APIs, such as in the request label of its request metrics
+var KafkaAPIKeyNames = map[KafkaAPIKey]string{
+	Produce:                      "Produce",
+	Fetch:                        "Fetch",
+	ListOffsets:                  "ListOffsets",
+	Metadata:                     "Metadata",
+	OffsetCommit:                 "OffsetCommit",
+	OffsetFetch:                  "OffsetFetch",
+	FindCoordinator:              "FindCoordinator",
+	JoinGroup:                    "JoinGroup",
+	Heartbeat:                    "Heartbeat",
+	LeaveGroup:                   "LeaveGroup",
+	SyncGroup:                    "SyncGroup",
+	DescribeGroups:               "DescribeGroups",
+	ListGroups:                   "ListGroups",
+	SaslHandshake:                "SaslHandshake",
+	ApiVersions:                  "ApiVersions",
+	CreateTopics:                 "CreateTopics",
+	DeleteTopics:                 "DeleteTopics",
+	DeleteRecords:                "DeleteRecords",
+	InitProducerId:               "InitProducerId",
+	OffsetForLeaderEpoch:         "OffsetForLeaderEpoch",
+	AddPartitionsToTxn:           "AddPartitionsToTxn",
+	AddOffsetsToTxn:              "AddOffsetsToTxn",
+	EndTxn:                       "EndTxn",
+	WriteTxnMarkers:              "WriteTxnMarkers",
+	TxnOffsetCommit:              "TxnOffsetCommit",
+	DescribeAcls:                 "DescribeAcls",
+	CreateAcls:                   "CreateAcls",
+	DeleteAcls:                   "DeleteAcls",
+	DescribeConfigs:              "DescribeConfigs",
+	AlterConfigs:                 "AlterConfigs",
+	AlterReplicaLogDirs:          "AlterReplicaLogDirs",
+	DescribeLogDirs:              "DescribeLogDirs",
+	SaslAuthenticate:             "SaslAuthenticate",
+	CreatePartitions:             "CreatePartitions",
+	CreateDelegationToken:        "CreateDelegationToken",
+	RenewDelegationToken:         "RenewDelegationToken",
+	ExpireDelegationToken:        "ExpireDelegationToken",
+	DescribeDelegationToken:      "DescribeDelegationToken",
+	DeleteGroups:                 "DeleteGroups",
+	ElectLeaders:                 "ElectLeaders",
+	IncrementalAlterConfigs:      "IncrementalAlterConfigs",
+	AlterPartitionReassignments:  "AlterPartitionReassignments",
+	ListPartitionReassignments:   "ListPartitionReassignments",
+	OffsetDelete:                 "OffsetDelete",
+	DescribeClientQuotas:         "DescribeClientQuotas",
+	AlterClientQuotas:            "AlterClientQuotas",
+	DescribeUserScramCredentials: "DescribeUserScramCredentials",
+	AlterUserScramCredentials:    "AlterUserScramCredentials",
+	Vote:                         "Vote",
+	BeginQuorumEpoch:             "BeginQuorumEpoch",
+	EndQuorumEpoch:               "EndQuorumEpoch",
+	DescribeQuorum:               "DescribeQuorum",
+	AlterPartition:               "AlterPartition",
+	UpdateFeatures:               "UpdateFeatures",
+	FetchSnapshot:                "FetchSnapshot",
+	DescribeCluster:              "DescribeCluster",
+	DescribeProducers:            "DescribeProducers",
+	BrokerRegistration:           "BrokerRegistration",
+	BrokerHeartbeat:              "BrokerHeartbeat",
+	UnregisterBroker:             "UnregisterBroker",
+	DescribeTransactions:         "DescribeTransactions",
+	ListTransactions:             "ListTransactions",
+	AllocateProducerIds:          "AllocateProducerIds",
+	ConsumerGroupHeartbeat:       "ConsumerGroupHeartbeat",
+	ConsumerGroupDescribe:        "ConsumerGroupDescribe",
+	GetTelemetrySubscriptions:    "GetTelemetrySubscriptions",
+	PushTelemetry:                "PushTelemetry",
+	ListClientMetricsResources:   "ListClientMetricsResources",
+	DescribeTopicPartitions:      "DescribeTopicPartitions",
+	AddRaftVoter:                 "AddRaftVoter",
+	RemoveRaftVoter:              "RemoveRaftVoter",
+}
+
 var flexibleVersions = map[int16]int16{
-	// Produce: flexible from version 7+
-	0: 7,
+	// Produce: flexible from version 9+
+	0: 9,
 	// Fetch: flexible from version 12+
 	1: 12,
 	// ListOffsets: flexible from version 6+
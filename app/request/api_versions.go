@@ -74,9 +74,16 @@ type ApiVersionsResponse struct {
 
 func (r *ApiVersionsResponse) GetCorrelationId() int32 { return r.CorrelationId }
 
+func (r *ApiVersionsResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTime = throttleTimeMs }
+
+func (r *ApiVersionsResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
 func (r *ApiVersionsResponse) Serialize(apiVersion int16) ([]byte, error) {
 	var err error
-	buffer := make([]byte, 256)
+	// Each api key takes 7 bytes with its empty tagged fields
+	buffer := make([]byte, 32+7*len(r.ApiKeys))
 	index := 0
 
 	// Message size (placeholder)
@@ -190,7 +197,7 @@ func (h *ApiVersionsHandler) ParseRequestBody(requestHeader RequestHeader, buffe
 	return req, nil
 }
 
-func (h *ApiVersionsHandler) Handle(req KafkaRequest) (KafkaResponse, error) {
+func (h *ApiVersionsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
 	apiReq, ok := req.(*ApiVersionsRequest)
 	if !ok {
 		return nil, fmt.Errorf("ApiVersionsHandler received %T instead of *ApiVersionsRequest", req)
@@ -207,6 +214,11 @@ func (h *ApiVersionsHandler) Handle(req KafkaRequest) (KafkaResponse, error) {
 		}
 	}
 
+	if err == nil {
+		session.ClientSoftwareName = apiReq.ClientSoftwareName
+		session.ClientSoftwareVersion = apiReq.ClientSoftwareVersion
+	}
+
 	response := &ApiVersionsResponse{
 		CorrelationId: apiReq.Header.CorrelationId,
 		ErrorCode:     errorCode,
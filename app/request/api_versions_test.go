@@ -430,7 +430,7 @@ func TestHandleRequest(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := handler.Handle(&tt.request)
+			got, err := handler.Handle(NewSession("127.0.0.1"), &tt.request)
 
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
@@ -0,0 +1,312 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type BeginQuorumEpochPartition struct {
+	PartitionIndex   int32
+	VoterDirectoryId string
+	LeaderId         int32
+	LeaderEpoch      int32
+	TaggedFields     map[string]string
+}
+
+type BeginQuorumEpochTopic struct {
+	TopicName    string
+	Partitions   []BeginQuorumEpochPartition
+	TaggedFields map[string]string
+}
+
+type BeginQuorumEpochRequest struct {
+	Header    RequestHeader
+	ClusterId *string
+	VoterId   int32
+	Topics    []BeginQuorumEpochTopic
+	// The listeners of the new leader, the voters reach it there
+	LeaderEndpoints []QuorumListener
+	TaggedFields    map[string]string
+}
+
+func (r *BeginQuorumEpochRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *BeginQuorumEpochRequest) GetApiKey() KafkaAPIKey {
+	return BeginQuorumEpoch
+}
+
+func (r *BeginQuorumEpochRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *BeginQuorumEpochRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the quorum transport sends to a voter
+func (r *BeginQuorumEpochRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	if r.ClusterId != nil {
+		bufferSize += len(*r.ClusterId)
+	}
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.TopicName) + 40*len(topic.Partitions)
+	}
+	for _, listener := range r.LeaderEndpoints {
+		bufferSize += 16 + len(listener.Name) + len(listener.Host)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.VoterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUUID(buffer, index, partition.VoterDirectoryId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializeQuorumListeners(buffer, index, r.LeaderEndpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// BeginQuorumEpochHandler tells the metadata quorum of this controller of a new leader
+type BeginQuorumEpochHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *BeginQuorumEpochHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &BeginQuorumEpochRequest{}
+	req.Header = requestHeader
+
+	req.ClusterId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse cluster id from BeginQuorumEpoch request",
+		}
+	}
+
+	req.VoterId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse voter id from BeginQuorumEpoch request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from BeginQuorumEpoch request",
+		}
+	}
+
+	req.Topics = make([]BeginQuorumEpochTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := BeginQuorumEpochTopic{}
+
+		topic.TopicName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from BeginQuorumEpoch request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from BeginQuorumEpoch request",
+			}
+		}
+
+		topic.Partitions = make([]BeginQuorumEpochPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := BeginQuorumEpochPartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.VoterDirectoryId, index, err = parser.ExtractUUID(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderId, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition from BeginQuorumEpoch request at index %d", j),
+				}
+			}
+
+			partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition tagged fields from BeginQuorumEpoch request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from BeginQuorumEpoch request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.LeaderEndpoints, index, err = parseQuorumListeners(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse leader endpoints from BeginQuorumEpoch request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from BeginQuorumEpoch request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *BeginQuorumEpochHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*BeginQuorumEpochRequest)
+	if !ok {
+		return nil, fmt.Errorf("BeginQuorumEpochHandler received %T instead of *BeginQuorumEpochRequest", req)
+	}
+
+	response := &QuorumEpochResponse{
+		ApiKey:        BeginQuorumEpoch,
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Topics:        make([]QuorumTopicResult, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := QuorumTopicResult{TopicName: requestTopic.TopicName, Partitions: []QuorumPartitionResult{}, TaggedFields: map[string]string{}}
+
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := quorumPartitionResult(h.quorum, requestTopic.TopicName, requestPartition.PartitionIndex)
+			if partition.ErrorCode == int16(NONE) {
+				begun := h.quorum.HandleBeginQuorumEpoch(raft.BeginQuorumEpochRequest{
+					LeaderEpoch: requestPartition.LeaderEpoch,
+					LeaderId:    requestPartition.LeaderId,
+				}, h.now())
+
+				partition.ErrorCode = begun.ErrorCode
+				partition.LeaderId, partition.LeaderEpoch = begun.LeaderId, begun.LeaderEpoch
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
@@ -1,48 +1,529 @@
 package request
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/metrics"
+	"github.com/codecrafters-io/kafka-starter-go/app/purgatory"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
 
 type KafkaBroker struct {
 	handlers map[KafkaAPIKey]RequestHandler
+	// Measures the time spent processing the requests of each client against its request_percentage quota, and the
+	// bytes it produces and consumes against its producer_byte_rate and consumer_byte_rate quotas
+	requestQuota *quota.Manager
+	produceQuota *quota.Manager
+	fetchQuota   *quota.Manager
+	metrics      *requestMetrics
+	requestLog   *requestLogger
+	// Holds the responses waiting for the broker to load the metadata records of their request
+	commits *metadataPurgatory
+	// nil without partition logs or a controller channel
+	replicas             *replica.Manager
+	transport            *ReplicaTransport
+	coordinator          *transaction.Coordinator
+	transactionTransport *TransactionTransport
+}
+
+// throttledResponse is implemented by responses that have a throttle_time_ms field
+type throttledResponse interface {
+	setThrottleTime(throttleTimeMs int32)
+}
+
+// delayedHandler is implemented by the handlers whose response may wait for an event, such as the commit of the
+// metadata records of the request. HandleDelayed calls respond once, possibly from another goroutine after it returned
+type delayedHandler interface {
+	HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error))
+}
+
+// waitForResponse runs a delayed handler and waits for its response, it is the Handle method of delayed handlers
+func waitForResponse(handler delayedHandler, session *Session, req KafkaRequest) (KafkaResponse, error) {
+	type handled struct {
+		response KafkaResponse
+		err      error
+	}
+
+	result := make(chan handled, 1)
+	handler.HandleDelayed(session, req, func(response KafkaResponse, err error) {
+		result <- handled{response, err}
+	})
+
+	r := <-result
+	return r.response, r.err
 }
 
-func (b *KafkaBroker) ProcessRequest(buffer []byte) ([]byte, error) {
-	index := 0
+// ProcessRequest returns the serialized response, and how long the connection must stop reading requests when the client exceeded its quota
+func (b *KafkaBroker) ProcessRequest(session *Session, buffer []byte) ([]byte, time.Duration, error) {
+	type processed struct {
+		response []byte
+		throttle time.Duration
+		err      error
+	}
+
+	result := make(chan processed, 1)
+	b.ProcessRequestAsync(session, buffer, func(response []byte, throttle time.Duration, err error) {
+		result <- processed{response, throttle, err}
+	})
+
+	r := <-result
+	return r.response, r.throttle, r.err
+}
 
-	requestHeader, index, err := ParseRequestHeader(buffer, index)
+// ProcessRequestAsync is ProcessRequest for the connections, whose worker must not wait for a response parked in a
+// purgatory. done is called once with the outcome of ProcessRequest
+func (b *KafkaBroker) ProcessRequestAsync(session *Session, buffer []byte, done func(response []byte, throttle time.Duration, err error)) {
+	start := time.Now()
+	b.requestLog.logFrame(session, "Request frame", buffer)
+
+	requestHeader, index, err := ParseRequestHeader(buffer, 0)
 	if err != nil {
-		return nil, err
+		b.requestLog.logger.LogAttrs(context.Background(), slog.LevelWarn, "Failed to parse request header", append(sessionAttrs(session), slog.String("error", err.Error()))...)
+		done(nil, 0, err)
+		return
+	}
+
+	b.processRequest(session, requestHeader, buffer, index, start, func(response []byte, errorCounts map[int16]int, throttle time.Duration, err error) {
+		if err != nil {
+			errorCounts = failedErrorCounts(err)
+		}
+
+		elapsed := time.Since(start)
+		b.metrics.record(requestHeader, len(buffer), len(response), errorCounts, elapsed)
+		b.requestLog.log(session, requestHeader, errorCounts, err, elapsed)
+		b.requestLog.logFrame(session, "Response frame", response)
+
+		done(response, throttle, err)
+	})
+}
+
+func (b *KafkaBroker) processRequest(session *Session, requestHeader RequestHeader, buffer []byte, index int, start time.Time, done func([]byte, map[int16]int, time.Duration, error)) {
+	apiKey := KafkaAPIKey(requestHeader.RequestApiKey)
+	// On SASL listeners only ApiVersions and the SASL APIs are processed until the session has authenticated
+	if session.Listener.SecurityProtocol.UsesSasl() && apiKey != ApiVersions && apiKey != SaslHandshake && apiKey != SaslAuthenticate && !session.isAuthenticated(time.Now()) {
+		done(nil, nil, 0, ErrAuthenticationRequired)
+		return
 	}
 
-	handler, exists := b.handlers[KafkaAPIKey(requestHeader.RequestApiKey)]
+	handler, exists := b.handlers[apiKey]
 	if !exists {
-		return nil, &RequestParseError{Code: INVALID_REQUEST, Message: fmt.Sprintf("unsupported API key: %d", requestHeader.RequestApiKey)}
+		done(nil, nil, 0, &RequestParseError{Code: INVALID_REQUEST, Message: fmt.Sprintf("unsupported API key: %d", requestHeader.RequestApiKey)})
+		return
 	}
 
 	request, err := handler.ParseRequestBody(requestHeader, buffer, index)
 	if err != nil {
-		return nil, err
+		done(nil, nil, 0, err)
+		return
+	}
+
+	// The request quota counts the time the handler took, not the time its response waited in a purgatory
+	var handled sync.Once
+	var handlerTime time.Duration
+	markHandled := func() {
+		handled.Do(func() { handlerTime = time.Since(start) })
+	}
+
+	respond := func(response KafkaResponse, err error) {
+		markHandled()
+		if err != nil {
+			done(nil, nil, 0, err)
+			return
+		}
+		// Requests such as acks=0 produces have no response, their client is only muted when over its quota
+		if response == nil {
+			done(nil, nil, b.byteRateThrottle(session, requestHeader, request, 0), nil)
+			return
+		}
+
+		serialized, errorCounts, throttle, err := b.completeResponse(session, requestHeader, request, response, handlerTime)
+		done(serialized, errorCounts, throttle, err)
+	}
+
+	delayed, ok := handler.(delayedHandler)
+	if !ok {
+		respond(handler.Handle(session, request))
+		return
 	}
+	delayed.HandleDelayed(session, request, respond)
+	markHandled()
+}
 
-	response, err := handler.Handle(request)
+// completeResponse throttles the response of a client over its request or byte rate quotas and serializes it
+func (b *KafkaBroker) completeResponse(session *Session, requestHeader RequestHeader, request KafkaRequest, response KafkaResponse, handlerTime time.Duration) ([]byte, map[int16]int, time.Duration, error) {
+	// Responses without a throttle_time_ms field, such as SaslHandshake, are never throttled
+	var throttle time.Duration
+	throttled, ok := response.(throttledResponse)
+	if ok {
+		// The request quota is a percentage of one thread's time per second
+		percentage := float64(handlerTime.Nanoseconds()) * 100 / float64(time.Second)
+		throttle = b.requestQuota.RecordAndGetThrottleTime(session.quotaUser(), requestHeader.ClientId, percentage, time.Now())
+		throttled.setThrottleTime(int32(throttle.Milliseconds()))
+	}
+
+	serialized, err := response.Serialize(requestHeader.RequestApiVersion)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 
-	return response.Serialize(requestHeader.RequestApiVersion)
+	// Like Kafka, a client over both quotas is throttled for the longest of them. A fetch response is measured once
+	// serialized, it is serialized again with the new throttle_time_ms
+	if byteRate := b.byteRateThrottle(session, requestHeader, request, len(serialized)); ok && byteRate > throttle {
+		throttle = byteRate
+		throttled.setThrottleTime(int32(throttle.Milliseconds()))
+		if serialized, err = response.Serialize(requestHeader.RequestApiVersion); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	var errorCounts map[int16]int
+	if errored, ok := response.(erroredResponse); ok {
+		errorCounts = errored.errorCounts()
+	}
+
+	return serialized, errorCounts, throttle, nil
 }
 
-func NewKafkaBroker() KafkaBroker {
+// byteRateThrottle records the size of a produce request, or of the response to a consumer fetch, against the byte rate
+// quota of the client and returns how long it must be throttled. The followers are throttled by the replication
+// throttles of the replica manager instead
+func (b *KafkaBroker) byteRateThrottle(session *Session, requestHeader RequestHeader, request KafkaRequest, responseSize int) time.Duration {
+	switch request := request.(type) {
+	case *ProduceRequest:
+		return b.produceQuota.RecordAndGetThrottleTime(session.quotaUser(), requestHeader.ClientId, float64(requestHeader.MessageSize), time.Now())
+	case *FetchRequest:
+		if request.ReplicaId < 0 {
+			return b.fetchQuota.RecordAndGetThrottleTime(session.quotaUser(), requestHeader.ClientId, float64(responseSize), time.Now())
+		}
+	}
+	return 0
+}
+
+// NewKafkaBroker creates a broker from its validated static configuration, its request metrics are added to registry
+// and its request log is written to logger. metadataController is the controller of a process with the controller
+// role, nil otherwise. loader holds the committed metadata the broker serves and logs the partition logs of the log dirs.
+// channel reaches the active controller for the ISR changes of the partitions the broker leads and the config changes it
+// forwards, nil on a broker without a quorum
+func NewKafkaBroker(serverConfig *config.ServerConfig, registry *metrics.Registry, logger *slog.Logger, metadataController *controller.Controller, loader *metadata.Loader, logs *storage.LogManager, channel *ControllerChannel) *KafkaBroker {
+	// The dynamic configs are the ones committed to the metadata log
+	configs := config.NewStore(serverConfig.NodeId, serverConfig.Properties)
+	loader.Subscribe(func(image *metadata.Image) {
+		configs.Load(image.AllConfigs())
+	})
+	brokerResource := config.Resource{Type: config.BROKER, Name: ""}
+
+	// The ACLs are metadata records, the authorizer follows the ones of the committed image
+	superUsers, _ := configs.Value(brokerResource, "super.users")
+	allowEveryoneIfNoAclFound, _ := configs.Bool(brokerResource, "allow.everyone.if.no.acl.found")
+	authorizer := acl.NewAclAuthorizer(splitSuperUsers(superUsers), allowEveryoneIfNoAclFound)
+	loader.Subscribe(func(image *metadata.Image) {
+		authorizer.Load(slices.Collect(maps.Values(image.Acls())))
+	})
+
+	mechanismsValue, _ := configs.Value(brokerResource, "sasl.enabled.mechanisms")
+	mechanisms := config.SplitList(mechanismsValue)
+	maxReauthMs, _ := configs.Int64(brokerResource, "connections.max.reauth.ms")
+	quotaWindowNum, _ := configs.Int64(brokerResource, "quota.window.num")
+	quotaWindowSizeSeconds, _ := configs.Int64(brokerResource, "quota.window.size.seconds")
+	// The client quotas are metadata records, the store follows the ones of the committed image
+	quotas := quota.NewStore()
+	loader.Subscribe(func(image *metadata.Image) {
+		quotas.Load(image.ClientQuotas())
+	})
+
+	credentials := sasl.NewCredentialStore()
+	jaasConfig, _ := configs.Value(brokerResource, "sasl.jaas.config")
+	for username, password := range sasl.ParseJaasUsers(jaasConfig) {
+		credentials.SetPassword(username, password)
+	}
+	// The SCRAM credentials are metadata records, the store follows the ones of the committed image
+	loader.Subscribe(func(image *metadata.Image) {
+		credentials.LoadScramCredentials(scramCredentialsByName(image.ScramCredentials()))
+	})
+
+	commits := newMetadataPurgatory(loader)
+
+	// The handlers get the controller and its quorum node, both nil when this node is not a controller, and check them
+	// before every use
+	var quorum *raft.Node
+	if metadataController != nil {
+		quorum = metadataController.Node()
+	}
+
+	// The replicas of the partitions follow the leaders the metadata image names
+	var replicas *replica.Manager
+	var transport *ReplicaTransport
+	if logs != nil && channel != nil {
+		maxLagMs, _ := configs.Int64(brokerResource, "replica.lag.time.max.ms")
+		fetchWaitMaxMs, _ := configs.Int64(brokerResource, "replica.fetch.wait.max.ms")
+		fetchMaxBytes, _ := configs.Int64(brokerResource, "replica.fetch.max.bytes")
+		fetchBackoffMs, _ := configs.Int64(brokerResource, "replica.fetch.backoff.ms")
+		replicationQuotaWindowNum, _ := configs.Int64(brokerResource, "replication.quota.window.num")
+		replicationQuotaWindowSizeSeconds, _ := configs.Int64(brokerResource, "replication.quota.window.size.seconds")
+		retentionCheckIntervalMs, _ := configs.Int64(brokerResource, "log.retention.check.interval.ms")
+		producerIdExpirationMs, _ := configs.Int64(brokerResource, "producer.id.expiration.ms")
+		producerIdExpirationCheckIntervalMs, _ := configs.Int64(brokerResource, "producer.id.expiration.check.interval.ms")
+		transport = NewReplicaTransport(serverConfig.NodeId, serverConfig.InterBrokerListenerName, loader, channel, serverConfig.Quorum.RequestTimeout, time.Duration(fetchBackoffMs)*time.Millisecond)
+		replicas = replica.NewManager(replica.Config{
+			NodeId:        serverConfig.NodeId,
+			MaxLag:        time.Duration(maxLagMs) * time.Millisecond,
+			FetchMaxWait:  time.Duration(fetchWaitMaxMs) * time.Millisecond,
+			FetchMaxBytes: int(fetchMaxBytes),
+			FetchBackoff:  time.Duration(fetchBackoffMs) * time.Millisecond,
+			QuotaSamples:  int(replicationQuotaWindowNum),
+			QuotaWindow:   time.Duration(replicationQuotaWindowSizeSeconds) * time.Second,
+
+			RetentionCheckInterval:            time.Duration(retentionCheckIntervalMs) * time.Millisecond,
+			ProducerIdExpiration:              time.Duration(producerIdExpirationMs) * time.Millisecond,
+			ProducerIdExpirationCheckInterval: time.Duration(producerIdExpirationCheckIntervalMs) * time.Millisecond,
+		}, logs, configs, transport, logger)
+		loader.Subscribe(replicas.ApplyImage)
+	}
+
+	// The transaction coordinator loads the partitions of the state log the broker leads once the replica manager
+	// opened them, and gets its producer ids from the active controller
+	var coordinator *transaction.Coordinator
+	var transactionTransport *TransactionTransport
+	if replicas != nil {
+		numPartitions, _ := configs.Int64(brokerResource, "transaction.state.log.num.partitions")
+		maxTimeoutMs, _ := configs.Int64(brokerResource, "transaction.max.timeout.ms")
+		abortTimedOutIntervalMs, _ := configs.Int64(brokerResource, "transaction.abort.timed.out.transaction.cleanup.interval.ms")
+		fetchBackoffMs, _ := configs.Int64(brokerResource, "replica.fetch.backoff.ms")
+		transactionTransport = NewTransactionTransport(serverConfig.NodeId, serverConfig.InterBrokerListenerName, loader, replicas, serverConfig.Quorum.RequestTimeout, time.Duration(fetchBackoffMs)*time.Millisecond)
+		brokerEpoch := func() int64 {
+			if broker, ok := loader.Image().Broker(serverConfig.NodeId); ok {
+				return broker.Epoch
+			}
+			return -1
+		}
+		coordinator = transaction.NewCoordinator(transaction.Config{
+			NodeId:                serverConfig.NodeId,
+			NumPartitions:         int(numPartitions),
+			MaxTimeout:            time.Duration(maxTimeoutMs) * time.Millisecond,
+			AbortTimedOutInterval: time.Duration(abortTimedOutIntervalMs) * time.Millisecond,
+			RequestTimeout:        serverConfig.Quorum.RequestTimeout,
+			RetryBackoff:          time.Duration(fetchBackoffMs) * time.Millisecond,
+		}, logs, replicas, transactionTransport, channel, brokerEpoch, logger)
+		transactionTransport.coordinator = coordinator
+		loader.Subscribe(coordinator.ApplyImage)
+	}
+
+	// The logs apply the configs of their topic, and the changes to them without a restart
+	if logs != nil {
+		logConfig := func(topic string) storage.LogConfig { return topicLogConfig(configs, topic) }
+		logs.UpdateConfigs(logConfig)
+		configs.Watch(func(resource config.Resource) {
+			if resource.Type == config.TOPIC || resource.Type == config.BROKER {
+				logs.UpdateConfigs(logConfig)
+			}
+		})
+	}
+
+	topicMetrics := newTopicMetrics(registry)
+	purgatories := []*purgatory.Purgatory{commits.purgatory}
+	if replicas != nil {
+		purgatories = append(purgatories, replicas.Purgatories()...)
+	}
+	registerBrokerGauges(registry, serverConfig.NodeId, loader, logs, purgatories)
+
 	handlers := make(map[KafkaAPIKey]RequestHandler)
 	handlers[ApiVersions] = &ApiVersionsHandler{
 		supportedApis: []ApiVersion{
+			{ApiKey: 0, MinVersion: 9, MaxVersion: 11, TaggedFields: map[string]string{}},
+			{ApiKey: 1, MinVersion: 12, MaxVersion: 17, TaggedFields: map[string]string{}},
+			{ApiKey: 3, MinVersion: 9, MaxVersion: 12, TaggedFields: map[string]string{}},
+			{ApiKey: 10, MinVersion: 4, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 17, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
 			{ApiKey: 18, MinVersion: 0, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 19, MinVersion: 5, MaxVersion: 7, TaggedFields: map[string]string{}},
+			{ApiKey: 22, MinVersion: 4, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 23, MinVersion: 4, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 24, MinVersion: 3, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 25, MinVersion: 3, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 26, MinVersion: 3, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 27, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 28, MinVersion: 3, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 29, MinVersion: 2, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 30, MinVersion: 2, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 31, MinVersion: 2, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 32, MinVersion: 4, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 33, MinVersion: 2, MaxVersion: 2, TaggedFields: map[string]string{}},
+			{ApiKey: 34, MinVersion: 2, MaxVersion: 2, TaggedFields: map[string]string{}},
+			{ApiKey: 35, MinVersion: 4, MaxVersion: 4, TaggedFields: map[string]string{}},
+			{ApiKey: 36, MinVersion: 2, MaxVersion: 2, TaggedFields: map[string]string{}},
+			{ApiKey: 43, MinVersion: 2, MaxVersion: 2, TaggedFields: map[string]string{}},
+			{ApiKey: 44, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 45, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 46, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 48, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 49, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 50, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 51, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 52, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 53, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 54, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 55, MinVersion: 2, MaxVersion: 2, TaggedFields: map[string]string{}},
+			{ApiKey: 56, MinVersion: 2, MaxVersion: 2, TaggedFields: map[string]string{}},
+			{ApiKey: 59, MinVersion: 1, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 61, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 62, MinVersion: 3, MaxVersion: 3, TaggedFields: map[string]string{}},
+			{ApiKey: 63, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 64, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 65, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 66, MinVersion: 0, MaxVersion: 1, TaggedFields: map[string]string{}},
+			{ApiKey: 67, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
 			{ApiKey: 75, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 80, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
+			{ApiKey: 81, MinVersion: 0, MaxVersion: 0, TaggedFields: map[string]string{}},
 		},
 	}
-	handlers[DescribeTopicPartitions] = &DescribeTopicPartitionsHandler{}
+	handlers[Produce] = &ProduceHandler{replicas: replicas, verifier: transactionTransport, metrics: topicMetrics, authorizer: authorizer}
+	handlers[Metadata] = &MetadataHandler{brokerId: serverConfig.NodeId, loader: loader, authorizer: authorizer}
+	handlers[SaslHandshake] = &SaslHandshakeHandler{mechanisms: mechanisms, credentials: credentials}
+	handlers[SaslAuthenticate] = &SaslAuthenticateHandler{maxReauthMs: maxReauthMs, now: time.Now}
+	handlers[DescribeUserScramCredentials] = &DescribeUserScramCredentialsHandler{credentials: credentials, authorizer: authorizer}
+	handlers[AlterUserScramCredentials] = &AlterUserScramCredentialsHandler{controller: metadataController, commits: commits, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer}
+	handlers[DescribeAcls] = &DescribeAclsHandler{authorizer: authorizer}
+	handlers[CreateAcls] = &CreateAclsHandler{controller: metadataController, commits: commits, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer}
+	handlers[DeleteAcls] = &DeleteAclsHandler{controller: metadataController, commits: commits, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer}
+	handlers[DescribeConfigs] = &DescribeConfigsHandler{configs: configs, loader: loader, authorizer: authorizer}
+	handlers[AlterConfigs] = &AlterConfigsHandler{configs: configs, controller: metadataController, channel: channel, authorizer: authorizer}
+	handlers[IncrementalAlterConfigs] = &IncrementalAlterConfigsHandler{configs: configs, controller: metadataController, channel: channel, authorizer: authorizer}
+	handlers[CreateTopics] = &CreateTopicsHandler{configs: configs, controller: metadataController, commits: commits, authorizer: authorizer}
+	handlers[DescribeTopicPartitions] = &DescribeTopicPartitionsHandler{loader: loader, authorizer: authorizer}
+	handlers[OffsetForLeaderEpoch] = &OffsetForLeaderEpochHandler{replicas: replicas, authorizer: authorizer}
+	handlers[DescribeClientQuotas] = &DescribeClientQuotasHandler{quotas: quotas, authorizer: authorizer}
+	handlers[AlterClientQuotas] = &AlterClientQuotasHandler{controller: metadataController, commits: commits, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer}
+	handlers[Vote] = &VoteHandler{quorum: quorum, authorizer: authorizer, now: time.Now}
+	handlers[BeginQuorumEpoch] = &BeginQuorumEpochHandler{quorum: quorum, authorizer: authorizer, now: time.Now}
+	handlers[EndQuorumEpoch] = &EndQuorumEpochHandler{quorum: quorum, authorizer: authorizer, now: time.Now}
+	handlers[Fetch] = &FetchHandler{quorum: quorum, loader: loader, replicas: replicas, metrics: topicMetrics, authorizer: authorizer, now: time.Now}
+	handlers[FetchSnapshot] = &FetchSnapshotHandler{quorum: quorum, authorizer: authorizer}
+	handlers[DescribeQuorum] = &DescribeQuorumHandler{quorum: quorum, authorizer: authorizer, now: time.Now}
+	handlers[AddRaftVoter] = &AddRaftVoterHandler{quorum: quorum, authorizer: authorizer, now: time.Now}
+	handlers[RemoveRaftVoter] = &RemoveRaftVoterHandler{quorum: quorum, authorizer: authorizer}
+	handlers[BrokerRegistration] = &BrokerRegistrationHandler{controller: metadataController, authorizer: authorizer, now: time.Now}
+	handlers[BrokerHeartbeat] = &BrokerHeartbeatHandler{controller: metadataController, authorizer: authorizer, now: time.Now}
+	handlers[UnregisterBroker] = &UnregisterBrokerHandler{controller: metadataController, authorizer: authorizer}
+	handlers[ElectLeaders] = &ElectLeadersHandler{controller: metadataController, commits: commits, authorizer: authorizer}
+	handlers[AlterPartition] = &AlterPartitionHandler{controller: metadataController, authorizer: authorizer}
+	handlers[AlterPartitionReassignments] = &AlterPartitionReassignmentsHandler{controller: metadataController, authorizer: authorizer}
+	handlers[ListPartitionReassignments] = &ListPartitionReassignmentsHandler{controller: metadataController, authorizer: authorizer}
+	handlers[DescribeLogDirs] = &DescribeLogDirsHandler{logs: logs, authorizer: authorizer}
+	handlers[AlterReplicaLogDirs] = &AlterReplicaLogDirsHandler{logs: logs, authorizer: authorizer}
+	handlers[FindCoordinator] = &FindCoordinatorHandler{configs: configs, loader: loader, controller: metadataController, channel: channel, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer}
+	handlers[InitProducerId] = &InitProducerIdHandler{coordinator: coordinator, authorizer: authorizer}
+	handlers[AddPartitionsToTxn] = &AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: authorizer}
+	handlers[AddOffsetsToTxn] = &AddOffsetsToTxnHandler{configs: configs, loader: loader, coordinator: coordinator, authorizer: authorizer}
+	handlers[EndTxn] = &EndTxnHandler{coordinator: coordinator, authorizer: authorizer}
+	handlers[WriteTxnMarkers] = &WriteTxnMarkersHandler{replicas: replicas, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer, now: time.Now}
+	handlers[TxnOffsetCommit] = &TxnOffsetCommitHandler{configs: configs, loader: loader, replicas: replicas, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer, now: time.Now}
+	handlers[DescribeProducers] = &DescribeProducersHandler{replicas: replicas, authorizer: authorizer}
+	handlers[DescribeTransactions] = &DescribeTransactionsHandler{coordinator: coordinator, authorizer: authorizer}
+	handlers[ListTransactions] = &ListTransactionsHandler{coordinator: coordinator, authorizer: authorizer}
+	handlers[AllocateProducerIds] = &AllocateProducerIdsHandler{controller: metadataController, commits: commits, timeout: serverConfig.Quorum.RequestTimeout, authorizer: authorizer}
+
+	// The slow request threshold is a dynamic config, it may be altered on this broker or on the cluster-wide default
+	thisBroker := config.Resource{Type: config.BROKER, Name: strconv.Itoa(int(serverConfig.NodeId))}
+	slowThreshold := func() time.Duration {
+		slowThresholdMs, _ := configs.Int64(thisBroker, "request.logger.slow.threshold.ms")
+		return time.Duration(slowThresholdMs) * time.Millisecond
+	}
+	requestLog := newRequestLogger(logger, slowThreshold())
+	configs.Watch(func(resource config.Resource) {
+		if resource.Type == config.BROKER {
+			requestLog.setSlowThreshold(slowThreshold())
+		}
+	})
+
+	return &KafkaBroker{
+		handlers:     handlers,
+		requestQuota: quota.NewManager(quota.REQUEST_PERCENTAGE, quotas, int(quotaWindowNum), time.Duration(quotaWindowSizeSeconds)*time.Second),
+		produceQuota: quota.NewManager(quota.PRODUCER_BYTE_RATE, quotas, int(quotaWindowNum), time.Duration(quotaWindowSizeSeconds)*time.Second),
+		fetchQuota:   quota.NewManager(quota.CONSUMER_BYTE_RATE, quotas, int(quotaWindowNum), time.Duration(quotaWindowSizeSeconds)*time.Second),
+		metrics:      newRequestMetrics(registry),
+		requestLog:   requestLog,
+		commits:      commits,
+		replicas:     replicas,
+		transport:    transport,
+
+		coordinator:          coordinator,
+		transactionTransport: transactionTransport,
+	}
+}
+
+// splitSuperUsers splits the principals of super.users, which are separated by semicolons like in Kafka
+func splitSuperUsers(value string) []string {
+	superUsers := []string{}
+	for principal := range strings.SplitSeq(value, ";") {
+		if principal = strings.TrimSpace(principal); principal != "" {
+			superUsers = append(superUsers, principal)
+		}
+	}
+	return superUsers
+}
+
+// scramCredentialsByName keys the credentials of the metadata image by mechanism name instead of mechanism code
+func scramCredentialsByName(credentials map[string]map[int8]sasl.ScramCredential) map[string]map[string]sasl.ScramCredential {
+	byName := make(map[string]map[string]sasl.ScramCredential, len(credentials))
+	for user, mechanisms := range credentials {
+		byName[user] = make(map[string]sasl.ScramCredential, len(mechanisms))
+		for mechanism, credential := range mechanisms {
+			if name, ok := scramMechanismNames[mechanism]; ok {
+				byName[user][name] = credential
+			}
+		}
+	}
+	return byName
+}
+
+// topicLogConfig reads the configs of a topic that its logs apply, the ones it does not override come from the broker
+func topicLogConfig(configs *config.Store, topic string) storage.LogConfig {
+	resource := config.Resource{Type: config.TOPIC, Name: topic}
+	segmentBytes, _ := configs.Int64(resource, "segment.bytes")
+	segmentMs, _ := configs.Int64(resource, "segment.ms")
+	retentionMs, _ := configs.Int64(resource, "retention.ms")
+	retentionBytes, _ := configs.Int64(resource, "retention.bytes")
+	maxMessageBytes, _ := configs.Int64(resource, "max.message.bytes")
+	cleanupPolicy, _ := configs.Value(resource, "cleanup.policy")
+	timestampType, _ := configs.Value(resource, "message.timestamp.type")
+
+	return storage.LogConfig{
+		SegmentBytes:    segmentBytes,
+		SegmentMs:       segmentMs,
+		Delete:          slices.Contains(config.SplitList(cleanupPolicy), "delete"),
+		RetentionMs:     retentionMs,
+		RetentionBytes:  retentionBytes,
+		Compact:         slices.Contains(config.SplitList(cleanupPolicy), "compact"),
+		MaxMessageBytes: maxMessageBytes,
+		LogAppendTime:   timestampType == "LogAppendTime",
+	}
+}
 
-	return KafkaBroker{
-		handlers: handlers,
+// Shutdown stops the purgatories and the fetchers of the broker, once no connection waits for the responses they hold
+func (b *KafkaBroker) Shutdown() {
+	b.commits.purgatory.Shutdown()
+	if b.replicas != nil {
+		b.coordinator.Shutdown()
+		b.transactionTransport.Close()
+		b.replicas.Shutdown()
+		b.transport.Close()
 	}
 }
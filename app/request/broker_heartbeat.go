@@ -0,0 +1,283 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type BrokerHeartbeatRequest struct {
+	Header                RequestHeader
+	BrokerId              int32
+	BrokerEpoch           int64
+	CurrentMetadataOffset int64
+	WantFence             bool
+	WantShutDown          bool
+	TaggedFields          map[string]string
+}
+
+func (r *BrokerHeartbeatRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *BrokerHeartbeatRequest) GetApiKey() KafkaAPIKey {
+	return BrokerHeartbeat
+}
+
+func (r *BrokerHeartbeatRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *BrokerHeartbeatRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the controller channel of a broker sends to the active controller
+func (r *BrokerHeartbeatRequest) Serialize() ([]byte, error) {
+	buffer := make([]byte, 64+len(r.Header.ClientId))
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.BrokerId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range []int64{r.BrokerEpoch, r.CurrentMetadataOffset} {
+		index, err = serializer.SerializeInt64(buffer, index, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, flag := range []bool{r.WantFence, r.WantShutDown} {
+		index, err = serializer.SerializeBoolean(buffer, index, flag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type BrokerHeartbeatResponse struct {
+	CorrelationId  int32
+	ThrottleTime   int32
+	ErrorCode      int16
+	IsCaughtUp     bool
+	IsFenced       bool
+	ShouldShutDown bool
+	TaggedFields   map[string]string
+}
+
+func (r *BrokerHeartbeatResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *BrokerHeartbeatResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *BrokerHeartbeatResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *BrokerHeartbeatResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, 32)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, flag := range []bool{r.IsCaughtUp, r.IsFenced, r.ShouldShutDown} {
+		index, err = serializer.SerializeBoolean(buffer, index, flag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseBrokerHeartbeatResponse reads the response of the active controller to the controller channel
+func parseBrokerHeartbeatResponse(buffer []byte) (*BrokerHeartbeatResponse, error) {
+	response := &BrokerHeartbeatResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	for _, flag := range []*bool{&response.IsCaughtUp, &response.IsFenced, &response.ShouldShutDown} {
+		if err == nil {
+			*flag, index, err = parser.ExtractBoolean(buffer, index)
+		}
+	}
+	if err == nil {
+		response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse BrokerHeartbeat response: %w", err)
+	}
+
+	return response, nil
+}
+
+// BrokerHeartbeatHandler keeps the session of a registered broker alive on the active controller, and carries its
+// requests to be fenced or to shut down
+type BrokerHeartbeatHandler struct {
+	controller *controller.Controller
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *BrokerHeartbeatHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &BrokerHeartbeatRequest{}
+	req.Header = requestHeader
+
+	req.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse broker id from BrokerHeartbeat request",
+		}
+	}
+
+	req.BrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse broker epoch from BrokerHeartbeat request",
+		}
+	}
+
+	req.CurrentMetadataOffset, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse current metadata offset from BrokerHeartbeat request",
+		}
+	}
+
+	req.WantFence, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse want fence from BrokerHeartbeat request",
+		}
+	}
+
+	req.WantShutDown, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse want shut down from BrokerHeartbeat request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from BrokerHeartbeat request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *BrokerHeartbeatHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*BrokerHeartbeatRequest)
+	if !ok {
+		return nil, fmt.Errorf("BrokerHeartbeatHandler received %T instead of *BrokerHeartbeatRequest", req)
+	}
+
+	// A broker that is not heard from stays fenced
+	response := &BrokerHeartbeatResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		IsFenced:      true,
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	if h.controller == nil {
+		response.ErrorCode = int16(NOT_CONTROLLER)
+		return response, nil
+	}
+
+	result, err := h.controller.Heartbeat(controller.BrokerHeartbeat{
+		BrokerId:              apiReq.BrokerId,
+		BrokerEpoch:           apiReq.BrokerEpoch,
+		CurrentMetadataOffset: apiReq.CurrentMetadataOffset,
+		WantFence:             apiReq.WantFence,
+		WantShutDown:          apiReq.WantShutDown,
+	}, h.now())
+	if err != nil {
+		response.ErrorCode, _ = controllerErrorCode(err)
+		return response, nil
+	}
+
+	response.IsCaughtUp = result.IsCaughtUp
+	response.IsFenced = result.IsFenced
+	response.ShouldShutDown = result.ShouldShutDown
+
+	return response, nil
+}
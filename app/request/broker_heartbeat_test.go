@@ -0,0 +1,150 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestBrokerHeartbeatParseRequestBody(t *testing.T) {
+	handler := BrokerHeartbeatHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x25, // MessageSize: 37
+		0x00, 0x3F, // RequestApiKey: 63 (BrokerHeartbeat)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00, 0x00, 0x00, 0x02, // BrokerId: 2
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // BrokerEpoch: 5
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09, // CurrentMetadataOffset: 9
+		0x00, // WantFence: false
+		0x01, // WantShutDown: true
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 63, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*BrokerHeartbeatRequest)
+	if !ok {
+		t.Fatalf("expected *BrokerHeartbeatRequest, got %T", got)
+	}
+
+	want := &BrokerHeartbeatRequest{
+		Header:                header,
+		BrokerId:              2,
+		BrokerEpoch:           5,
+		CurrentMetadataOffset: 9,
+		WantFence:             false,
+		WantShutDown:          true,
+		TaggedFields:          map[string]string{},
+	}
+	if !reflect.DeepEqual(gotReq, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", gotReq, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestBrokerHeartbeatHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active := newTestController(now)
+
+	epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 2, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		controller     *controller.Controller
+		authorizer     acl.Authorizer
+		brokerId       int32
+		brokerEpoch    int64
+		metadataOffset int64
+		wantErrorCode  KafkaErrorCode
+		wantFenced     bool
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), 2, epoch, epoch, NOT_CONTROLLER, true},
+		{"Not authorized", active, denyAllAuthorizer{}, 2, epoch, epoch, CLUSTER_AUTHORIZATION_FAILED, true},
+		{"Not registered", active, acl.NewAclAuthorizer(nil, true), 3, epoch, epoch, BROKER_ID_NOT_REGISTERED, true},
+		{"Stale epoch", active, acl.NewAclAuthorizer(nil, true), 2, epoch - 1, epoch, STALE_BROKER_EPOCH, true},
+		{"Behind its registration", active, acl.NewAclAuthorizer(nil, true), 2, epoch, epoch - 1, NONE, true},
+		{"Caught up", active, acl.NewAclAuthorizer(nil, true), 2, epoch, epoch, NONE, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := BrokerHeartbeatHandler{controller: tt.controller, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			request := BrokerHeartbeatRequest{
+				Header:                RequestHeader{RequestApiKey: 63, RequestApiVersion: 0, CorrelationId: 7},
+				BrokerId:              tt.brokerId,
+				BrokerEpoch:           tt.brokerEpoch,
+				CurrentMetadataOffset: tt.metadataOffset,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*BrokerHeartbeatResponse)
+			if !ok {
+				t.Fatalf("expected *BrokerHeartbeatResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if gotResp.IsFenced != tt.wantFenced {
+				t.Errorf("fenced mismatch: got %v, want %v", gotResp.IsFenced, tt.wantFenced)
+			}
+		})
+	}
+}
+
+func TestBrokerHeartbeatResponseSerialize(t *testing.T) {
+	response := &BrokerHeartbeatResponse{
+		CorrelationId:  7,
+		ThrottleTime:   0,
+		ErrorCode:      0,
+		IsCaughtUp:     true,
+		IsFenced:       false,
+		ShouldShutDown: true,
+		TaggedFields:   map[string]string{},
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x0F, // MessageSize: 15
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x01, // IsCaughtUp: true
+		0x00, // IsFenced: false
+		0x01, // ShouldShutDown: true
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
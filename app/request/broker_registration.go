@@ -0,0 +1,563 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type BrokerRegistrationListener struct {
+	Name             string
+	Host             string
+	Port             uint16
+	SecurityProtocol int16
+	TaggedFields     map[string]string
+}
+
+type BrokerRegistrationFeature struct {
+	Name                string
+	MinSupportedVersion int16
+	MaxSupportedVersion int16
+	TaggedFields        map[string]string
+}
+
+type BrokerRegistrationRequest struct {
+	Header              RequestHeader
+	BrokerId            int32
+	ClusterId           string
+	IncarnationId       string
+	Listeners           []BrokerRegistrationListener
+	Features            []BrokerRegistrationFeature
+	Rack                *string
+	IsMigratingZkBroker bool
+	LogDirs             []string
+	PreviousBrokerEpoch int64
+	TaggedFields        map[string]string
+}
+
+func (r *BrokerRegistrationRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *BrokerRegistrationRequest) GetApiKey() KafkaAPIKey {
+	return BrokerRegistration
+}
+
+func (r *BrokerRegistrationRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *BrokerRegistrationRequest) Validate() error {
+	if r.Header.RequestApiVersion != 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the controller channel of a broker sends to the active controller
+func (r *BrokerRegistrationRequest) Serialize() ([]byte, error) {
+	bufferSize := 128 + len(r.Header.ClientId) + len(r.ClusterId) + 16*len(r.LogDirs)
+	for _, listener := range r.Listeners {
+		bufferSize += 16 + len(listener.Name) + len(listener.Host)
+	}
+	for _, feature := range r.Features {
+		bufferSize += 16 + len(feature.Name)
+	}
+	if r.Rack != nil {
+		bufferSize += len(*r.Rack)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.BrokerId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUUID(buffer, index, r.IncarnationId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Listeners)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, listener := range r.Listeners {
+		index, err = serializer.SerializeCompactString(buffer, index, listener.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, listener.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, int16(listener.Port))
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, listener.SecurityProtocol)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, listener.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Features)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, feature := range r.Features {
+		index, err = serializer.SerializeCompactString(buffer, index, feature.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, feature.MinSupportedVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, feature.MaxSupportedVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, feature.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.Rack)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeBoolean(buffer, index, r.IsMigratingZkBroker)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.LogDirs)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, logDir := range r.LogDirs {
+		index, err = serializer.SerializeUUID(buffer, index, logDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.PreviousBrokerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type BrokerRegistrationResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	BrokerEpoch   int64
+	TaggedFields  map[string]string
+}
+
+func (r *BrokerRegistrationResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *BrokerRegistrationResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *BrokerRegistrationResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *BrokerRegistrationResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, 32)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.BrokerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseBrokerRegistrationResponse reads the response of the active controller to the controller channel
+func parseBrokerRegistrationResponse(buffer []byte) (*BrokerRegistrationResponse, error) {
+	response := &BrokerRegistrationResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err == nil {
+		response.BrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	}
+	if err == nil {
+		response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse BrokerRegistration response: %w", err)
+	}
+
+	return response, nil
+}
+
+// BrokerRegistrationHandler registers a broker with the active controller, the broker then heartbeats with the
+// epoch of the response
+type BrokerRegistrationHandler struct {
+	controller *controller.Controller
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *BrokerRegistrationHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &BrokerRegistrationRequest{}
+	req.Header = requestHeader
+
+	req.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse broker id from BrokerRegistration request",
+		}
+	}
+
+	req.ClusterId, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse cluster id from BrokerRegistration request",
+		}
+	}
+
+	req.IncarnationId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse incarnation id from BrokerRegistration request",
+		}
+	}
+
+	req.Listeners, index, err = parseBrokerRegistrationListeners(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse listeners from BrokerRegistration request: %v", err),
+		}
+	}
+
+	req.Features, index, err = parseBrokerRegistrationFeatures(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse features from BrokerRegistration request: %v", err),
+		}
+	}
+
+	req.Rack, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse rack from BrokerRegistration request",
+		}
+	}
+
+	req.IsMigratingZkBroker, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse is migrating zk broker from BrokerRegistration request",
+		}
+	}
+
+	req.LogDirs, index, err = parseUuidArray(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse log dirs from BrokerRegistration request: %v", err),
+		}
+	}
+
+	req.PreviousBrokerEpoch, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse previous broker epoch from BrokerRegistration request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from BrokerRegistration request",
+		}
+	}
+
+	return req, nil
+}
+
+func parseBrokerRegistrationListeners(buffer []byte, index int) ([]BrokerRegistrationListener, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	if length < 0 {
+		return nil, index, fmt.Errorf("null listeners")
+	}
+
+	listeners := make([]BrokerRegistrationListener, 0, length)
+	for i := 0; i < length; i++ {
+		listener := BrokerRegistrationListener{}
+
+		listener.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		listener.Host, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		port, newIndex, err := parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		listener.Port = uint16(port)
+		index = newIndex
+
+		listener.SecurityProtocol, index, err = parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		listener.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, index, nil
+}
+
+func parseBrokerRegistrationFeatures(buffer []byte, index int) ([]BrokerRegistrationFeature, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+	if length < 0 {
+		return nil, index, fmt.Errorf("null features")
+	}
+
+	features := make([]BrokerRegistrationFeature, 0, length)
+	for i := 0; i < length; i++ {
+		feature := BrokerRegistrationFeature{}
+
+		feature.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		feature.MinSupportedVersion, index, err = parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		feature.MaxSupportedVersion, index, err = parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		feature.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+
+		features = append(features, feature)
+	}
+
+	return features, index, nil
+}
+
+// parseUuidArray parses a compact array of uuids, a null array is empty
+func parseUuidArray(buffer []byte, index int) ([]string, int, error) {
+	length, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, index, err
+	}
+
+	values := make([]string, 0, max(length, 0))
+	for i := 0; i < length; i++ {
+		var value string
+		value, index, err = parser.ExtractUUID(buffer, index)
+		if err != nil {
+			return nil, index, err
+		}
+		values = append(values, value)
+	}
+
+	return values, index, nil
+}
+
+func (h *BrokerRegistrationHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*BrokerRegistrationRequest)
+	if !ok {
+		return nil, fmt.Errorf("BrokerRegistrationHandler received %T instead of *BrokerRegistrationRequest", req)
+	}
+
+	response := &BrokerRegistrationResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		BrokerEpoch:   -1,
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	registration := controller.BrokerRegistration{
+		BrokerId:      apiReq.BrokerId,
+		IncarnationId: apiReq.IncarnationId,
+		Endpoints:     make([]metadata.BrokerEndpoint, 0, len(apiReq.Listeners)),
+		Rack:          apiReq.Rack,
+		LogDirs:       apiReq.LogDirs,
+		// -1 when the broker did not shut down cleanly
+		PreviousBrokerEpoch: apiReq.PreviousBrokerEpoch,
+	}
+	for _, listener := range apiReq.Listeners {
+		registration.Endpoints = append(registration.Endpoints, metadata.BrokerEndpoint{
+			Name:             listener.Name,
+			Host:             listener.Host,
+			Port:             listener.Port,
+			SecurityProtocol: listener.SecurityProtocol,
+		})
+	}
+
+	err := controller.ErrNotController
+	if h.controller != nil {
+		response.BrokerEpoch, err = h.controller.RegisterBroker(registration, h.now())
+	}
+	if err != nil {
+		response.ErrorCode, _ = controllerErrorCode(err)
+		response.BrokerEpoch = -1
+	}
+
+	return response, nil
+}
+
+// controllerErrorCode maps the errors of the controller to error codes, with the error as the message
+func controllerErrorCode(err error) (int16, *string) {
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, controller.ErrNotController):
+		return int16(NOT_CONTROLLER), &message
+	case errors.Is(err, controller.ErrDuplicateBrokerRegistration):
+		return int16(DUPLICATE_BROKER_REGISTRATION), &message
+	case errors.Is(err, metadata.ErrUnknownBroker):
+		return int16(BROKER_ID_NOT_REGISTERED), &message
+	case errors.Is(err, metadata.ErrStaleBrokerEpoch):
+		return int16(STALE_BROKER_EPOCH), &message
+	case errors.Is(err, metadata.ErrUnknownTopic), errors.Is(err, metadata.ErrUnknownPartition):
+		return int16(UNKNOWN_TOPIC_OR_PARTITION), &message
+	case errors.Is(err, controller.ErrFencedLeaderEpoch):
+		return int16(FENCED_LEADER_EPOCH), &message
+	case errors.Is(err, controller.ErrInvalidUpdateVersion):
+		return int16(INVALID_UPDATE_VERSION), &message
+	case errors.Is(err, controller.ErrIneligibleReplica):
+		return int16(INELIGIBLE_REPLICA), &message
+	case errors.Is(err, controller.ErrInvalidAcl):
+		return int16(INVALID_REQUEST), &message
+	case errors.Is(err, metadata.ErrUnknownScramCredential):
+		return int16(RESOURCE_NOT_FOUND), &message
+	default:
+		return int16(UNKNOWN), &message
+	}
+}
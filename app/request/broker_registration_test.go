@@ -0,0 +1,178 @@
+package request
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+// newTestController returns the active controller of a quorum of one voter, listeners receive its committed records
+func newTestController(now time.Time, listeners ...raft.Listener) *controller.Controller {
+	transport := raft.NewMemoryTransport(func() time.Time { return now })
+	active := controller.NewController(controller.Config{SessionTimeout: 9 * time.Second}, raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          raft.StaticVoters(1),
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), slog.New(slog.DiscardHandler), now, listeners...)
+	transport.Register(active.Node())
+	active.Node().Poll(now)
+	return active
+}
+
+func TestBrokerRegistrationParseRequestBody(t *testing.T) {
+	handler := BrokerRegistrationHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x74, // MessageSize: 116
+		0x00, 0x3E, // RequestApiKey: 62 (BrokerRegistration)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00, 0x00, 0x00, 0x02, // BrokerId: 2
+		0x04, 'a', 'b', 'c', // ClusterId: "abc"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // IncarnationId
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+		0x02,                                              // Listeners array length: 1
+		0x0A, 'P', 'L', 'A', 'I', 'N', 'T', 'E', 'X', 'T', // Name: "PLAINTEXT"
+		0x0A, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', // Host: "localhost"
+		0x23, 0x84, // Port: 9092
+		0x00, 0x00, // SecurityProtocol: 0 (PLAINTEXT)
+		0x00,                                                                                 // Listener tagged fields
+		0x02,                                                                                 // Features array length: 1
+		0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n', // Name: "metadata.version"
+		0x00, 0x01, // MinSupportedVersion: 1
+		0x00, 0x14, // MaxSupportedVersion: 20
+		0x00,                                           // Feature tagged fields
+		0x00,                                           // Rack: null
+		0x00,                                           // IsMigratingZkBroker: false
+		0x02,                                           // LogDirs array length: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // LogDir
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // PreviousBrokerEpoch: -1
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 62, RequestApiVersion: 3, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*BrokerRegistrationRequest)
+	if !ok {
+		t.Fatalf("expected *BrokerRegistrationRequest, got %T", got)
+	}
+
+	want := &BrokerRegistrationRequest{
+		Header:        header,
+		BrokerId:      2,
+		ClusterId:     "abc",
+		IncarnationId: "00000000-0000-0000-0000-000000000007",
+		Listeners: []BrokerRegistrationListener{
+			{Name: "PLAINTEXT", Host: "localhost", Port: 9092, SecurityProtocol: 0, TaggedFields: map[string]string{}},
+		},
+		Features: []BrokerRegistrationFeature{
+			{Name: "metadata.version", MinSupportedVersion: 1, MaxSupportedVersion: 20, TaggedFields: map[string]string{}},
+		},
+		Rack:                nil,
+		IsMigratingZkBroker: false,
+		LogDirs:             []string{"00000000-0000-0000-0000-000000000008"},
+		PreviousBrokerEpoch: -1,
+		TaggedFields:        map[string]string{},
+	}
+	if !reflect.DeepEqual(gotReq, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", gotReq, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:60], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestBrokerRegistrationHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active := newTestController(now)
+
+	tests := []struct {
+		name          string
+		controller    *controller.Controller
+		authorizer    acl.Authorizer
+		incarnationId string
+		wantErrorCode KafkaErrorCode
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), "00000000-0000-0000-0000-000000000007", NOT_CONTROLLER},
+		{"Not authorized", active, denyAllAuthorizer{}, "00000000-0000-0000-0000-000000000007", CLUSTER_AUTHORIZATION_FAILED},
+		{"Registered", active, acl.NewAclAuthorizer(nil, true), "00000000-0000-0000-0000-000000000007", NONE},
+		{"Another incarnation", active, acl.NewAclAuthorizer(nil, true), "00000000-0000-0000-0000-000000000008", DUPLICATE_BROKER_REGISTRATION},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := BrokerRegistrationHandler{controller: tt.controller, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			request := BrokerRegistrationRequest{
+				Header:        RequestHeader{RequestApiKey: 62, RequestApiVersion: 3, CorrelationId: 7},
+				BrokerId:      2,
+				IncarnationId: tt.incarnationId,
+				Listeners:     []BrokerRegistrationListener{{Name: "PLAINTEXT", Host: "localhost", Port: 9092}},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*BrokerRegistrationResponse)
+			if !ok {
+				t.Fatalf("expected *BrokerRegistrationResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if (gotResp.BrokerEpoch >= 0) != (tt.wantErrorCode == NONE) {
+				t.Errorf("unexpected broker epoch %d", gotResp.BrokerEpoch)
+			}
+		})
+	}
+}
+
+func TestBrokerRegistrationResponseSerialize(t *testing.T) {
+	response := &BrokerRegistrationResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		BrokerEpoch:   42,
+		TaggedFields:  map[string]string{},
+	}
+
+	got, err := response.Serialize(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x14, // MessageSize: 20
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2A, // BrokerEpoch: 42
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
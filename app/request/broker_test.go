@@ -2,7 +2,21 @@ package request
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/metrics"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
 )
 
 func TestProcessRequest(t *testing.T) {
@@ -44,19 +58,329 @@ func TestProcessRequest(t *testing.T) {
 		},
 	}
 	broker := KafkaBroker{
-		handlers: handlers,
+		handlers:     handlers,
+		requestQuota: quota.NewManager(quota.REQUEST_PERCENTAGE, quota.NewStore(), 11, time.Second),
+		metrics:      newRequestMetrics(metrics.NewRegistry()),
+		requestLog:   newRequestLogger(slog.New(slog.DiscardHandler), time.Second),
 	}
 
-	response, err := broker.ProcessRequest(buffer)
+	response, throttle, err := broker.ProcessRequest(NewSession("127.0.0.1"), buffer)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if throttle != 0 {
+		t.Errorf("expected no throttling without quotas, got %v", throttle)
+	}
+
 	if !bytes.Equal(response, expected_response) {
 		t.Errorf("response mismatch:\ngot  %v\nwant %v", response, expected_response)
 	}
 }
 
+// newTestQuotaLoader returns a loader that committed a quota of the client id "test"
+func newTestQuotaLoader(t *testing.T, key string, value float64) *metadata.Loader {
+	t.Helper()
+
+	data, err := metadata.EncodeRecord(&metadata.ClientQuotaRecord{Entity: quota.Entity{ClientId: quota.Specific("test")}, Key: key, Value: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	loader.HandleCommit([]raft.Entry{{Offset: 0, Epoch: 1, Data: data}})
+	return loader
+}
+
+func TestProcessRequestThrottlesClientsOverQuota(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No request can be processed fast enough to stay within this quota
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), nil, newTestQuotaLoader(t, quota.REQUEST_PERCENTAGE, 1e-9), nil, nil)
+
+	apiVersions := []byte{
+		0x00, 0x00, 0x00, 0x0F, // MessageSize: 15
+		0x00, 0x12, // RequestApiKey: 18 (ApiVersions)
+		0x00, 0x04, // RequestApiVersion: 4 (flexible)
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		0x01, // clientSoftwareName: ""
+		0x01, // clientSoftwareVersion: ""
+		0x00, // Request tagged fields
+	}
+
+	response, throttle, err := broker.ProcessRequest(NewSession("127.0.0.1"), apiVersions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Throttling is capped at one quota window
+	if throttle != time.Second {
+		t.Errorf("expected 1s of throttling, got %v", throttle)
+	}
+
+	// ThrottleTime is followed by the response tagged fields
+	throttleTime := response[len(response)-5 : len(response)-1]
+	if !bytes.Equal(throttleTime, []byte{0x00, 0x00, 0x03, 0xE8}) {
+		t.Errorf("ThrottleTime mismatch: got %v, want 1000ms", throttleTime)
+	}
+}
+
+func TestProcessRequestThrottlesProducersOverByteRate(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), nil, newTestQuotaLoader(t, quota.PRODUCER_BYTE_RATE, 1), nil, nil)
+
+	produce := []byte{
+		0x00, 0x00, 0x00, 0x1E, // MessageSize: 30
+		0x00, 0x00, // RequestApiKey: 0 (Produce)
+		0x00, 0x09, // RequestApiVersion: 9
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00,       // Number of header tagged fields (varint, 0)
+		0x00,       // TransactionalId: null
+		0xFF, 0xFF, // Acks: -1
+		0x00, 0x00, 0x03, 0xE8, // TimeoutMs: 1000
+		0x02,                // TopicData array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x01, // PartitionData array length (0 partitions + 1)
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	// The 30 bytes of the request are far above 1 byte per second
+	response, throttle, err := broker.ProcessRequest(NewSession("127.0.0.1"), produce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if throttle != time.Second {
+		t.Errorf("expected 1s of throttling, got %v", throttle)
+	}
+	throttleTime := response[len(response)-5 : len(response)-1]
+	if !bytes.Equal(throttleTime, []byte{0x00, 0x00, 0x03, 0xE8}) {
+		t.Errorf("ThrottleTime mismatch: got %v, want 1000ms", throttleTime)
+	}
+
+	// The quota of a producer does not apply to the other clients
+	other := bytes.Replace(produce, []byte("test"), []byte("tess"), 1)
+	if _, throttle, err := broker.ProcessRequest(NewSession("127.0.0.1"), other); err != nil || throttle != 0 {
+		t.Errorf("expected no throttling without a quota, got %v, %v", throttle, err)
+	}
+}
+
+func TestProcessRequestRecordsMetrics(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), nil, metadata.NewLoader(slog.New(slog.DiscardHandler)), nil, nil)
+
+	apiVersions := []byte{
+		0x00, 0x00, 0x00, 0x11, // MessageSize: 17
+		0x00, 0x12, // RequestApiKey: 18 (ApiVersions)
+		0x00, 0x04, // RequestApiVersion: 4 (flexible)
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00,      // Number of header tagged fields (varint, 0)
+		0x02, 'g', // clientSoftwareName: "g"
+		0x02, '1', // clientSoftwareVersion: "1"
+		0x00, // Request tagged fields
+	}
+	unsupportedApi := []byte{
+		0x00, 0x00, 0x00, 0x0C, // MessageSize: 12
+		0x00, 0x00, // RequestApiKey: 0 (Produce)
+		0x00, 0x0B, // RequestApiVersion: 11
+		0x00, 0x00, 0x00, 0x43, // CorrelationId: 67
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+	}
+
+	response, _, err := broker.ProcessRequest(NewSession("127.0.0.1"), apiVersions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := broker.ProcessRequest(NewSession("127.0.0.1"), unsupportedApi); err == nil {
+		t.Fatal("expected an error for an unsupported API")
+	}
+
+	tests := []struct {
+		name    string
+		counter *metrics.Counter
+		labels  []string
+		want    float64
+	}{
+		{"ApiVersions requests", broker.metrics.requests, []string{"ApiVersions", "4"}, 1},
+		{"ApiVersions errors", broker.metrics.errors, []string{"ApiVersions", "NONE"}, 1},
+		{"ApiVersions request bytes", broker.metrics.requestBytes, []string{"ApiVersions"}, float64(len(apiVersions))},
+		{"ApiVersions response bytes", broker.metrics.responseBytes, []string{"ApiVersions"}, float64(len(response))},
+		{"Produce requests", broker.metrics.requests, []string{"Produce", "11"}, 1},
+		{"Produce errors", broker.metrics.errors, []string{"Produce", "INVALID_REQUEST"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.counter.Value(tt.labels...); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessRequestLogsRequests(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	now := time.Now()
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug})), active, loader, nil, nil)
+	session := NewSession("127.0.0.1")
+	session.ConnectionId = "127.0.0.1:9092-127.0.0.1:50000-1"
+
+	apiVersions := []byte{
+		0x00, 0x00, 0x00, 0x11, // MessageSize: 17
+		0x00, 0x12, // RequestApiKey: 18 (ApiVersions)
+		0x00, 0x04, // RequestApiVersion: 4 (flexible)
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00,      // Number of header tagged fields (varint, 0)
+		0x02, 'g', // clientSoftwareName: "g"
+		0x02, '1', // clientSoftwareVersion: "1"
+		0x00, // Request tagged fields
+	}
+
+	if _, _, err := broker.ProcessRequest(session, apiVersions); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected the request frame, the request and the response frame, got %q", lines)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"Request frame", lines[0], []string{"level=DEBUG", `msg="Request frame"`, "frame=000000110012000400000042000474657374000267023100"}},
+		{"Request", lines[1], []string{
+			"level=INFO", `msg="Completed request"`, "connection=127.0.0.1:9092-127.0.0.1:50000-1", "principal=User:ANONYMOUS",
+			"client_id=test", "client_software_name=g", "client_software_version=1", "api_key=ApiVersions", "api_version=4",
+			"correlation_id=66", "latency=", "error_code=NONE",
+		}},
+		{"Response frame", lines[2], []string{"level=DEBUG", `msg="Response frame"`, "frame=0000014e00000042"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.want {
+				if !strings.Contains(tt.line, want) {
+					t.Errorf("expected %q in %q", want, tt.line)
+				}
+			}
+		})
+	}
+
+	// Every request is slow once the controller committed a threshold of 0
+	threshold := "0"
+	if err := active.AlterConfig(config.Resource{Type: config.BROKER, Name: ""}, "request.logger.slow.threshold.ms", &threshold); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	output.Reset()
+	if _, _, err := broker.ProcessRequest(session, apiVersions); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), `level=WARN msg="Completed request"`) {
+		t.Errorf("expected a slow request warning, got %q", output.String())
+	}
+}
+
+func TestParseRequestBodyRejectsHugeArrayLengths(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), nil, metadata.NewLoader(slog.New(slog.DiscardHandler)), nil, nil)
+
+	// A compact array length of 2^42+1 followed by a few bytes, which must not be allocated for
+	hugeArray := []byte{0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 0x00, 0x00, 0x00}
+
+	// The versions the broker advertises, the parsers reject the others before reading the body
+	versions := map[KafkaAPIKey][]int16{}
+	for _, api := range broker.handlers[ApiVersions].(*ApiVersionsHandler).supportedApis {
+		versions[KafkaAPIKey(api.ApiKey)] = []int16{api.MinVersion, api.MaxVersion}
+	}
+
+	tests := []struct {
+		name   string
+		apiKey KafkaAPIKey
+		// The fields before the array
+		prefix []byte
+	}{
+		{"Metadata topics", Metadata, []byte{}},
+		{"DescribeConfigs resources", DescribeConfigs, []byte{}},
+		{"DescribeConfigs configuration keys", DescribeConfigs, []byte{0x02, 0x02, 0x04, 'f', 'o', 'o'}},
+		{"CreateAcls creations", CreateAcls, []byte{}},
+		{"AlterClientQuotas entries", AlterClientQuotas, []byte{}},
+		{"ElectLeaders topic partitions", ElectLeaders, []byte{0x00}},
+		{"OffsetForLeaderEpoch topics", OffsetForLeaderEpoch, []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+		{"DescribeTopicPartitions topics", DescribeTopicPartitions, []byte{}},
+		{"DescribeLogDirs topics", DescribeLogDirs, []byte{}},
+		{"AlterReplicaLogDirs partitions", AlterReplicaLogDirs, []byte{0x02, 0x03, '/', 'b', 0x02, 0x04, 'f', 'o', 'o'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := RequestHeader{RequestApiKey: int16(tt.apiKey), RequestApiVersion: versions[tt.apiKey][1], CorrelationId: 66, ClientId: "test"}
+			// The body starts after a 19-byte header
+			buffer := append(append(make([]byte, 19), tt.prefix...), hugeArray...)
+
+			_, err := broker.handlers[tt.apiKey].ParseRequestBody(header, buffer, 19)
+			var parseErr *RequestParseError
+			if !errors.As(err, &parseErr) || parseErr.Code != INVALID_REQUEST {
+				t.Errorf("expected INVALID_REQUEST, got %v", err)
+			}
+		})
+	}
+
+	// No parser may allocate for, or panic on, a huge length wherever it appears in the body
+	for apiKey, handler := range broker.handlers {
+		tested, ok := versions[apiKey]
+		if !ok {
+			tested = []int16{0}
+		}
+		for _, version := range tested {
+			for offset := range 8 {
+				buffer := append(make([]byte, 19+offset), hugeArray...)
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("%s v%d panicked with a huge length at offset %d: %v", KafkaAPIKeyNames[apiKey], version, offset, r)
+						}
+					}()
+					handler.ParseRequestBody(RequestHeader{RequestApiKey: int16(apiKey), RequestApiVersion: version}, buffer, 19)
+				}()
+			}
+		}
+	}
+}
+
 func BenchmarkProcessRequest(b *testing.B) {
 	buffer := []byte{
 		0x00, 0x00, 0x00, 0x18, // MessageSize: 24
@@ -76,13 +400,265 @@ func BenchmarkProcessRequest(b *testing.B) {
 		0x06,                    // Value length (varint, 6)
 		'v', 'a', 'l', 'u', 'e', // Value: "value"
 	}
-	broker := NewKafkaBroker()
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), nil, metadata.NewLoader(slog.New(slog.DiscardHandler)), nil, nil)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := broker.ProcessRequest(buffer)
+		_, _, err := broker.ProcessRequest(NewSession("127.0.0.1"), buffer)
 		if err != nil {
 			b.Fatal(err)
 		}
 	}
 }
+
+// authenticateTestSession authenticates session as alice with SASL PLAIN, or re-authenticates it
+func authenticateTestSession(t *testing.T, broker *KafkaBroker, session *Session) {
+	t.Helper()
+
+	handshake := []byte{
+		0x00, 0x00, 0x00, 0x15, // MessageSize: 21
+		0x00, 0x11, // RequestApiKey: 17 (SaslHandshake)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x43, // CorrelationId: 67
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, 0x05, 'P', 'L', 'A', 'I', 'N', // Mechanism: "PLAIN"
+	}
+
+	if _, _, err := broker.ProcessRequest(session, handshake); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticate := []byte{
+		0x00, 0x00, 0x00, 0x25, // MessageSize: 37
+		0x00, 0x24, // RequestApiKey: 36 (SaslAuthenticate)
+		0x00, 0x02, // RequestApiVersion: 2
+		0x00, 0x00, 0x00, 0x44, // CorrelationId: 68
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00,                                                                                                  // Number of header tagged fields (varint, 0)
+		0x14, 0x00, 'a', 'l', 'i', 'c', 'e', 0x00, 'a', 'l', 'i', 'c', 'e', '-', 's', 'e', 'c', 'r', 'e', 't', // AuthBytes: "\x00alice\x00alice-secret"
+		0x00, // Request tagged fields
+	}
+
+	if _, _, err := broker.ProcessRequest(session, authenticate); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessRequestRequiresAuthentication(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{
+		"sasl.enabled.mechanisms": "PLAIN",
+		"sasl.jaas.config":        `org.apache.kafka.common.security.plain.PlainLoginModule required user_alice="alice-secret";`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), nil, metadata.NewLoader(slog.New(slog.DiscardHandler)), nil, nil)
+	session := NewSession("127.0.0.1")
+	session.Listener = config.Listener{Name: "SASL_PLAINTEXT", SecurityProtocol: config.SASL_PLAINTEXT}
+
+	describeConfigs := []byte{
+		0x00, 0x00, 0x00, 0x1A, // MessageSize: 26
+		0x00, 0x20, // RequestApiKey: 32 (DescribeConfigs)
+		0x00, 0x04, // RequestApiVersion: 4
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00,                // Number of header tagged fields (varint, 0)
+		0x02,                // Resources array length (1 resource + 1)
+		0x02,                // ResourceType: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceName: "foo"
+		0x00, // ConfigurationKeys: null
+		0x00, // Resource tagged fields
+		0x00, // IncludeSynonyms: false
+		0x00, // IncludeDocumentation: false
+		0x00, // Request tagged fields
+	}
+
+	if _, _, err := broker.ProcessRequest(session, describeConfigs); !errors.Is(err, ErrAuthenticationRequired) {
+		t.Fatalf("expected ErrAuthenticationRequired before authentication, got %v", err)
+	}
+
+	plaintextSession := NewSession("127.0.0.1")
+	plaintextSession.Listener = config.Listener{Name: "PLAINTEXT", SecurityProtocol: config.PLAINTEXT}
+	if _, _, err := broker.ProcessRequest(plaintextSession, describeConfigs); err != nil {
+		t.Fatalf("PLAINTEXT listeners must not require authentication, got %v", err)
+	}
+
+	authenticateTestSession(t, broker, session)
+
+	if session.Principal() != "User:alice" {
+		t.Fatalf("Principal mismatch: got %s, want User:alice", session.Principal())
+	}
+
+	if _, _, err := broker.ProcessRequest(session, describeConfigs); err != nil {
+		t.Errorf("unexpected error after authentication: %v", err)
+	}
+}
+
+func TestProcessRequestReauthenticatesWhileAFetchWaits(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{
+		"sasl.enabled.mechanisms": "PLAIN",
+		"sasl.jaas.config":        `org.apache.kafka.common.security.plain.PlainLoginModule required user_alice="alice-secret";`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, loader, _ := newTestConfigs(t, time.Now())
+	logs, err := storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+
+	// Broker 1 leads foo
+	channel := NewControllerChannel(1, "CONTROLLER", active.Node(), time.Second, 10*time.Millisecond)
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), active, loader, logs, channel)
+	defer broker.Shutdown()
+	session := NewSession("127.0.0.1")
+	session.Listener = config.Listener{Name: "SASL_PLAINTEXT", SecurityProtocol: config.SASL_PLAINTEXT}
+	authenticateTestSession(t, broker, session)
+
+	fetch := []byte{
+		0x00, 0x00, 0x00, 0x53, // MessageSize: 83
+		0x00, 0x01, // RequestApiKey: 1 (Fetch)
+		0x00, 0x0C, // RequestApiVersion: 12
+		0x00, 0x00, 0x00, 0x45, // CorrelationId: 69
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00,                   // Number of header tagged fields (varint, 0)
+		0xFF, 0xFF, 0xFF, 0xFF, // ReplicaId: -1
+		0x00, 0x00, 0x00, 0x64, // MaxWaitMs: 100
+		0x00, 0x10, 0x00, 0x00, // MinBytes: 1048576
+		0x00, 0x10, 0x00, 0x00, // MaxBytes: 1048576
+		0x00,                   // IsolationLevel: 0
+		0x00, 0x00, 0x00, 0x00, // SessionId: 0
+		0xFF, 0xFF, 0xFF, 0xFF, // SessionEpoch: -1
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Topic: "foo"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // Partition: 0
+		0xFF, 0xFF, 0xFF, 0xFF, // CurrentLeaderEpoch: -1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // FetchOffset: 0
+		0xFF, 0xFF, 0xFF, 0xFF, // LastFetchedEpoch: -1
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // LogStartOffset: -1
+		0x00, 0x10, 0x00, 0x00, // PartitionMaxBytes: 1048576
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x01, // ForgottenTopicsData: empty
+		0x01, // RackId: ""
+		0x00, // Request tagged fields
+	}
+
+	// The fetch reads the session when it leaves the purgatory, while the client re-authenticates
+	fetched := make(chan []byte, 1)
+	broker.ProcessRequestAsync(session, fetch, func(response []byte, throttle time.Duration, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		fetched <- response
+	})
+	authenticateTestSession(t, broker, session)
+
+	// The response has no error and no records after the wait
+	response := <-fetched
+	if errorCode := binary.BigEndian.Uint16(response[29:]); errorCode != uint16(NONE) {
+		t.Errorf("expected the fetch of foo to wait without error, got %d", errorCode)
+	}
+	if session.Principal() != "User:alice" {
+		t.Errorf("Principal mismatch: got %s, want User:alice", session.Principal())
+	}
+}
+
+func TestKafkaBrokerAppliesTopicConfigsToTheLogs(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	active, loader, _ := newTestConfigs(t, now)
+	logs, err := storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	if _, err := logs.GetOrCreateLog(foo); err != nil {
+		t.Fatal(err)
+	}
+
+	broker := NewKafkaBroker(serverConfig, metrics.NewRegistry(), slog.New(slog.DiscardHandler), active, loader, logs, nil)
+	defer broker.Shutdown()
+	if _, err := logs.Append(foo, newTestRecords(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The running log follows the committed topic config
+	maxMessageBytes := "60"
+	if err := active.AlterConfig(config.Resource{Type: config.TOPIC, Name: "foo"}, "max.message.bytes", &maxMessageBytes); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	if _, err := logs.Append(foo, newTestRecords(), 0); !errors.Is(err, storage.ErrRecordTooLarge) {
+		t.Errorf("expected ErrRecordTooLarge once max.message.bytes is 60, got %v", err)
+	}
+}
+
+func TestKafkaBrokerExportsBrokerGauges(t *testing.T) {
+	serverConfig, err := config.NewServerConfig(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	active, loader, _ := newTestConfigs(t, now)
+	// Broker 1 leads baz and dropped broker 2 from its ISR
+	if _, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 2, IncarnationId: "00000000-0000-0000-0000-000000000008"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.CreateTopic("baz", [][]int32{{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.AlterPartition(controller.IsrChange{Topic: "baz", Partition: 0, LeaderId: 1, Isr: []int32{1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	logs, err := storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	if _, err := logs.GetOrCreateLog(foo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logs.Append(foo, newTestRecords(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := metrics.NewRegistry()
+	broker := NewKafkaBroker(serverConfig, registry, slog.New(slog.DiscardHandler), active, loader, logs, nil)
+	defer broker.Shutdown()
+
+	output := &bytes.Buffer{}
+	if _, err := registry.WriteTo(output); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"kafka_server_under_replicated_partitions 1\n",
+		`kafka_log_size_bytes{topic="foo",partition="0"} 61` + "\n",
+		`kafka_server_purgatory_size{purgatory="metadata"} 0` + "\n",
+		`kafka_server_purgatory_delayed_operations{purgatory="metadata"} 0` + "\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected the metrics to contain %q, got:\n%s", expected, output.String())
+		}
+	}
+}
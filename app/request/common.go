@@ -14,5 +14,5 @@ type KafkaResponse interface {
 
 type RequestHandler interface {
 	ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error)
-	Handle(KafkaRequest) (KafkaResponse, error)
+	Handle(session *Session, req KafkaRequest) (KafkaResponse, error)
 }
@@ -0,0 +1,334 @@
+package request
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+// controllerMaxResponseSize bounds the responses the controller channel reads
+const controllerMaxResponseSize = 1 << 20
+
+// ControllerChannel sends the requests of a broker to the active controller, which is the leader of the metadata
+// quorum the broker follows. Like the quorum, it reaches the controller on its PLAINTEXT controller listener named
+// listenerName
+type ControllerChannel struct {
+	listenerName string
+	quorum       *raft.Node
+	client       *nodeClient
+}
+
+func NewControllerChannel(nodeId int32, listenerName string, quorum *raft.Node, timeout time.Duration, retryBackoff time.Duration) *ControllerChannel {
+	return &ControllerChannel{
+		listenerName: listenerName,
+		quorum:       quorum,
+		client:       newNodeClient(fmt.Sprintf("broker-%d-to-controller", nodeId), timeout, retryBackoff, controllerMaxResponseSize),
+	}
+}
+
+// Close closes the connection to the controller
+func (c *ControllerChannel) Close() {
+	c.client.close()
+}
+
+// RegisterBroker registers the broker with the active controller and returns its epoch
+func (c *ControllerChannel) RegisterBroker(registration controller.BrokerRegistration) (int64, error) {
+	req := &BrokerRegistrationRequest{
+		BrokerId:            registration.BrokerId,
+		IncarnationId:       registration.IncarnationId,
+		Listeners:           make([]BrokerRegistrationListener, 0, len(registration.Endpoints)),
+		Features:            []BrokerRegistrationFeature{},
+		Rack:                registration.Rack,
+		LogDirs:             registration.LogDirs,
+		PreviousBrokerEpoch: registration.PreviousBrokerEpoch,
+		TaggedFields:        map[string]string{},
+	}
+	for _, endpoint := range registration.Endpoints {
+		req.Listeners = append(req.Listeners, BrokerRegistrationListener{
+			Name:             endpoint.Name,
+			Host:             endpoint.Host,
+			Port:             endpoint.Port,
+			SecurityProtocol: endpoint.SecurityProtocol,
+			TaggedFields:     map[string]string{},
+		})
+	}
+
+	buffer, err := c.send(BrokerRegistration, 3, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	response, err := parseBrokerRegistrationResponse(buffer)
+	if err != nil {
+		return -1, err
+	}
+	if err := controllerError(response.ErrorCode); err != nil {
+		return -1, err
+	}
+
+	return response.BrokerEpoch, nil
+}
+
+// Heartbeat sends a heartbeat of the broker to the active controller
+func (c *ControllerChannel) Heartbeat(heartbeat controller.BrokerHeartbeat) (controller.HeartbeatResult, error) {
+	req := &BrokerHeartbeatRequest{
+		BrokerId:              heartbeat.BrokerId,
+		BrokerEpoch:           heartbeat.BrokerEpoch,
+		CurrentMetadataOffset: heartbeat.CurrentMetadataOffset,
+		WantFence:             heartbeat.WantFence,
+		WantShutDown:          heartbeat.WantShutDown,
+		TaggedFields:          map[string]string{},
+	}
+
+	buffer, err := c.send(BrokerHeartbeat, 0, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return controller.HeartbeatResult{}, err
+	}
+
+	response, err := parseBrokerHeartbeatResponse(buffer)
+	if err != nil {
+		return controller.HeartbeatResult{}, err
+	}
+	if err := controllerError(response.ErrorCode); err != nil {
+		return controller.HeartbeatResult{}, err
+	}
+
+	return controller.HeartbeatResult{IsCaughtUp: response.IsCaughtUp, IsFenced: response.IsFenced, ShouldShutDown: response.ShouldShutDown}, nil
+}
+
+// AlterPartition proposes an ISR change of a partition the broker leads to the active controller, and returns the
+// partition epoch after the change. The controller knows the topic by topicId
+func (c *ControllerChannel) AlterPartition(brokerId int32, topicId string, change controller.IsrChange) (int32, error) {
+	req := &AlterPartitionRequest{
+		BrokerId:    brokerId,
+		BrokerEpoch: -1,
+		Topics: []AlterPartitionTopic{{
+			TopicId: topicId,
+			Partitions: []AlterPartitionPartition{{
+				PartitionIndex: change.Partition,
+				LeaderEpoch:    change.LeaderEpoch,
+				NewIsr:         change.Isr,
+				PartitionEpoch: change.PartitionEpoch,
+				TaggedFields:   map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	buffer, err := c.send(AlterPartition, 2, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	response, err := parseAlterPartitionResponse(buffer)
+	if err != nil {
+		return 0, err
+	}
+	if err := controllerError(response.ErrorCode); err != nil {
+		return 0, err
+	}
+	if len(response.Topics) != 1 || len(response.Topics[0].Partitions) != 1 {
+		return 0, fmt.Errorf("the controller answered AlterPartition with %d topics", len(response.Topics))
+	}
+
+	partition := response.Topics[0].Partitions[0]
+	if err := controllerError(partition.ErrorCode); err != nil {
+		return 0, err
+	}
+	return partition.PartitionEpoch, nil
+}
+
+// AlterConfigs forwards changes to the configs of a resource to the active controller, a nil value deletes a config.
+// The changes were validated by the broker, they are sent as SET and DELETE operations. An error of the controller
+// keeps its code and message for the client
+func (c *ControllerChannel) AlterConfigs(resource config.Resource, changes map[string]*string, validateOnly bool) error {
+	requestResource := IncrementalAlterConfigsResource{
+		ResourceType: int8(resource.Type),
+		ResourceName: resource.Name,
+		Configs:      make([]IncrementalAlterableConfig, 0, len(changes)),
+		TaggedFields: map[string]string{},
+	}
+	for _, name := range slices.Sorted(maps.Keys(changes)) {
+		op := config.SET
+		if changes[name] == nil {
+			op = config.DELETE
+		}
+		requestResource.Configs = append(requestResource.Configs, IncrementalAlterableConfig{
+			Name:            name,
+			ConfigOperation: int8(op),
+			Value:           changes[name],
+			TaggedFields:    map[string]string{},
+		})
+	}
+	req := &IncrementalAlterConfigsRequest{
+		Resources:    []IncrementalAlterConfigsResource{requestResource},
+		ValidateOnly: validateOnly,
+		TaggedFields: map[string]string{},
+	}
+
+	buffer, err := c.send(IncrementalAlterConfigs, 1, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := parseIncrementalAlterConfigsResponse(buffer)
+	if err != nil {
+		return err
+	}
+	if len(response.Responses) != 1 {
+		return fmt.Errorf("the controller answered IncrementalAlterConfigs with %d resources", len(response.Responses))
+	}
+
+	result := response.Responses[0]
+	if result.ErrorCode == int16(NONE) {
+		return nil
+	}
+	message := KafkaErrorCodeNames[KafkaErrorCode(result.ErrorCode)]
+	if result.ErrorMessage != nil {
+		message = *result.ErrorMessage
+	}
+	return &RequestParseError{Code: KafkaErrorCode(result.ErrorCode), Message: message}
+}
+
+// AllocateProducerIds asks the active controller for the next block of producer ids of the broker, it is the
+// producer id source of the transaction coordinator
+func (c *ControllerChannel) AllocateProducerIds(brokerId int32, brokerEpoch int64) (controller.ProducerIdsBlock, error) {
+	req := &AllocateProducerIdsRequest{BrokerId: brokerId, BrokerEpoch: brokerEpoch, TaggedFields: map[string]string{}}
+
+	buffer, err := c.send(AllocateProducerIds, 0, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return controller.ProducerIdsBlock{}, err
+	}
+
+	response, err := parseAllocateProducerIdsResponse(buffer)
+	if err != nil {
+		return controller.ProducerIdsBlock{}, err
+	}
+	if err := controllerError(response.ErrorCode); err != nil {
+		return controller.ProducerIdsBlock{}, err
+	}
+
+	return controller.ProducerIdsBlock{FirstProducerId: response.ProducerIdStart, Size: response.ProducerIdLen}, nil
+}
+
+// CreateTopic asks the active controller to create a topic, such as an internal topic a coordinator needs. The
+// configs were validated by the broker
+func (c *ControllerChannel) CreateTopic(topic controller.NewTopic, timeout time.Duration) error {
+	requestTopic := CreatableTopic{
+		Name:              topic.Name,
+		NumPartitions:     topic.NumPartitions,
+		ReplicationFactor: topic.ReplicationFactor,
+		Assignments:       []CreatableReplicaAssignment{},
+		Configs:           make([]CreatableTopicConfig, 0, len(topic.Configs)),
+		TaggedFields:      map[string]string{},
+	}
+	for _, name := range slices.Sorted(maps.Keys(topic.Configs)) {
+		value := topic.Configs[name]
+		requestTopic.Configs = append(requestTopic.Configs, CreatableTopicConfig{Name: name, Value: &value, TaggedFields: map[string]string{}})
+	}
+	req := &CreateTopicsRequest{
+		Topics:       []CreatableTopic{requestTopic},
+		TimeoutMs:    int32(timeout.Milliseconds()),
+		TaggedFields: map[string]string{},
+	}
+
+	buffer, err := c.send(CreateTopics, 7, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := parseCreateTopicsResponse(buffer)
+	if err != nil {
+		return err
+	}
+	if len(response.Topics) != 1 {
+		return fmt.Errorf("the controller answered CreateTopics with %d topics", len(response.Topics))
+	}
+	return controllerError(response.Topics[0].ErrorCode)
+}
+
+// send writes the request to the active controller and reads its response. Without a known leader of the quorum the
+// request fails with ErrNotController, like one the former controller rejects
+func (c *ControllerChannel) send(apiKey KafkaAPIKey, apiVersion int16, serialize func(RequestHeader) ([]byte, error)) ([]byte, error) {
+	leader := c.quorum.LeaderAndEpoch().LeaderId
+	if leader == raft.NO_LEADER {
+		return nil, fmt.Errorf("%w: the quorum has no leader", controller.ErrNotController)
+	}
+
+	response, err := c.client.send(leader, func() (string, error) { return c.address(leader) }, apiKey, apiVersion, 0, serialize)
+	if err != nil {
+		return nil, fmt.Errorf("%s to controller %d: %w", KafkaAPIKeyNames[apiKey], leader, err)
+	}
+	return response, nil
+}
+
+// address is the endpoint of the controller on the controller listener
+func (c *ControllerChannel) address(controllerId int32) (string, error) {
+	for _, voter := range c.quorum.Voters() {
+		if voter.Id != controllerId {
+			continue
+		}
+		for _, endpoint := range voter.Endpoints {
+			if endpoint.Name == c.listenerName {
+				return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port))), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("controller %d has no %s endpoint", controllerId, c.listenerName)
+}
+
+// controllerError maps the error codes of the responses of the controller back to the errors of the controller, nil
+// for NONE
+func controllerError(errorCode int16) error {
+	switch KafkaErrorCode(errorCode) {
+	case NONE:
+		return nil
+	case NOT_CONTROLLER:
+		return controller.ErrNotController
+	case DUPLICATE_BROKER_REGISTRATION:
+		return controller.ErrDuplicateBrokerRegistration
+	case BROKER_ID_NOT_REGISTERED:
+		return metadata.ErrUnknownBroker
+	case STALE_BROKER_EPOCH:
+		return metadata.ErrStaleBrokerEpoch
+	case UNKNOWN_TOPIC_OR_PARTITION, UNKNOWN_TOPIC_ID:
+		return metadata.ErrUnknownTopic
+	case FENCED_LEADER_EPOCH:
+		return controller.ErrFencedLeaderEpoch
+	case INVALID_UPDATE_VERSION:
+		return controller.ErrInvalidUpdateVersion
+	case INELIGIBLE_REPLICA:
+		return controller.ErrIneligibleReplica
+	case TOPIC_ALREADY_EXISTS:
+		return metadata.ErrTopicExists
+	default:
+		return fmt.Errorf("the controller failed the request: %s", KafkaErrorCodeNames[KafkaErrorCode(errorCode)])
+	}
+}
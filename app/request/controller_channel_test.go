@@ -0,0 +1,295 @@
+package request
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestControllerChannelRegistersAndHeartbeats(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+	listener, endpoint := listenQuorum(t)
+
+	// A single voter reached on its controller listener, it is active once it polled
+	transport := raft.NewMemoryTransport(clock.Now)
+	active := controller.NewController(controller.Config{SessionTimeout: 9 * time.Second}, raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint}}},
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), slog.New(slog.DiscardHandler), clock.Now())
+	transport.Register(active.Node())
+	active.Node().Poll(clock.Now())
+
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		BrokerRegistration: &BrokerRegistrationHandler{controller: active, authorizer: authorizer, now: clock.Now},
+		BrokerHeartbeat:    &BrokerHeartbeatHandler{controller: active, authorizer: authorizer, now: clock.Now},
+	})
+
+	channel := NewControllerChannel(2, "CONTROLLER", active.Node(), time.Second, 10*time.Millisecond)
+	defer channel.Close()
+
+	rack := "r1"
+	registration := controller.BrokerRegistration{
+		BrokerId:            2,
+		IncarnationId:       "00000000-0000-0000-0000-000000000007",
+		Endpoints:           []metadata.BrokerEndpoint{{Name: "PLAINTEXT", Host: "localhost", Port: 9092, SecurityProtocol: 0}},
+		Rack:                &rack,
+		LogDirs:             []string{"00000000-0000-0000-0000-000000000009"},
+		PreviousBrokerEpoch: -1,
+	}
+	epoch, err := channel.RegisterBroker(registration)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	active.Node().Poll(clock.Now())
+
+	broker, ok := active.Image().Broker(2)
+	if !ok {
+		t.Fatal("expected the controller to register broker 2")
+	}
+	if broker.Epoch != epoch || len(broker.Endpoints) != 1 || broker.Endpoints[0].Port != 9092 || *broker.Rack != "r1" {
+		t.Errorf("registration mismatch: got %+v with epoch %d", broker, epoch)
+	}
+
+	// Another incarnation is rejected while the session of the first one is alive
+	other := registration
+	other.IncarnationId = "00000000-0000-0000-0000-000000000008"
+	if _, err := channel.RegisterBroker(other); !errors.Is(err, controller.ErrDuplicateBrokerRegistration) {
+		t.Errorf("expected ErrDuplicateBrokerRegistration, got %v", err)
+	}
+
+	result, err := channel.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: epoch, CurrentMetadataOffset: active.Image().Offset - 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	active.Node().Poll(clock.Now())
+	if broker, _ := active.Image().Broker(2); !result.IsCaughtUp || broker.Fenced {
+		t.Errorf("expected the caught up broker to be unfenced, got %+v", result)
+	}
+
+	if _, err := channel.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: epoch + 1}); !errors.Is(err, metadata.ErrStaleBrokerEpoch) {
+		t.Errorf("expected ErrStaleBrokerEpoch, got %v", err)
+	}
+}
+
+func TestControllerChannelWithoutLeader(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+
+	// Voter 2 of a quorum of two does not know a leader before an election
+	transport := raft.NewMemoryTransport(clock.Now)
+	node := raft.NewNode(raft.Config{
+		NodeId:          2,
+		DirectoryId:     raft.ZERO_DIRECTORY_ID,
+		Voters:          raft.StaticVoters(1, 2),
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(2), &recordingListener{}, clock.Now())
+
+	channel := NewControllerChannel(2, "CONTROLLER", node, time.Second, 10*time.Millisecond)
+	defer channel.Close()
+
+	if _, err := channel.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: 1}); !errors.Is(err, controller.ErrNotController) {
+		t.Errorf("expected ErrNotController, got %v", err)
+	}
+}
+
+func TestControllerChannelForwardsConfigs(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+	listener, endpoint := listenQuorum(t)
+
+	transport := raft.NewMemoryTransport(clock.Now)
+	active := controller.NewController(controller.Config{SessionTimeout: 9 * time.Second}, raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint}}},
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), slog.New(slog.DiscardHandler), clock.Now())
+	transport.Register(active.Node())
+	active.Node().Poll(clock.Now())
+
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		IncrementalAlterConfigs: &IncrementalAlterConfigsHandler{configs: config.NewStore(1, map[string]string{}), controller: active, authorizer: authorizer},
+	})
+
+	channel := NewControllerChannel(2, "CONTROLLER", active.Node(), time.Second, 10*time.Millisecond)
+	defer channel.Close()
+
+	// Broker 2 has no controller of its own, it forwards the changes it validated
+	handler := IncrementalAlterConfigsHandler{configs: config.NewStore(2, map[string]string{}), channel: channel, authorizer: authorizer}
+	request := IncrementalAlterConfigsRequest{
+		Header: RequestHeader{RequestApiKey: 44, RequestApiVersion: 1, CorrelationId: 7},
+		Resources: []IncrementalAlterConfigsResource{
+			{ResourceType: 4, ResourceName: "2", Configs: []IncrementalAlterableConfig{{Name: "log.retention.ms", ConfigOperation: 0, Value: stringPtr("1000")}}},
+			{ResourceType: 2, ResourceName: "foo", Configs: []IncrementalAlterableConfig{{Name: "retention.ms", ConfigOperation: 0, Value: stringPtr("1")}}},
+		},
+	}
+
+	got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(clock.Now())
+
+	// The errors of the controller reach the client with their code
+	gotResp := got.(*IncrementalAlterConfigsResponse)
+	if gotResp.Responses[0].ErrorCode != int16(NONE) || gotResp.Responses[1].ErrorCode != int16(UNKNOWN_TOPIC_OR_PARTITION) {
+		t.Errorf("expected the broker config to be altered and the unknown topic to fail, got %+v", gotResp.Responses)
+	}
+
+	if configs := active.Image().Configs(config.Resource{Type: config.BROKER, Name: "2"}); configs["log.retention.ms"] != "1000" {
+		t.Errorf("expected the controller to commit log.retention.ms of broker 2, got %v", configs)
+	}
+}
+
+func TestControllerChannelAllocatesProducerIdsAndCreatesTopics(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+	listener, endpoint := listenQuorum(t)
+
+	transport := raft.NewMemoryTransport(clock.Now)
+	active := controller.NewController(controller.Config{SessionTimeout: 9 * time.Second}, raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint}}},
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), slog.New(slog.DiscardHandler), clock.Now())
+	transport.Register(active.Node())
+	active.Node().Poll(clock.Now())
+
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		BrokerRegistration:  &BrokerRegistrationHandler{controller: active, authorizer: authorizer, now: clock.Now},
+		BrokerHeartbeat:     &BrokerHeartbeatHandler{controller: active, authorizer: authorizer, now: clock.Now},
+		AllocateProducerIds: &AllocateProducerIdsHandler{controller: active, authorizer: authorizer},
+		CreateTopics:        &CreateTopicsHandler{configs: config.NewStore(1, map[string]string{}), controller: active, authorizer: authorizer},
+	})
+
+	channel := NewControllerChannel(2, "CONTROLLER", active.Node(), time.Second, 10*time.Millisecond)
+	defer channel.Close()
+
+	epoch, err := channel.RegisterBroker(controller.BrokerRegistration{BrokerId: 2, IncarnationId: "00000000-0000-0000-0000-000000000007", PreviousBrokerEpoch: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(clock.Now())
+	if _, err := channel.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: epoch, CurrentMetadataOffset: active.Image().Offset - 1}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(clock.Now())
+
+	for _, want := range []int64{0, controller.PRODUCER_ID_BLOCK_SIZE} {
+		block, err := channel.AllocateProducerIds(2, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block.FirstProducerId != want || block.Size != controller.PRODUCER_ID_BLOCK_SIZE {
+			t.Errorf("expected the block from %d, got %+v", want, block)
+		}
+	}
+	if _, err := channel.AllocateProducerIds(2, epoch+1); !errors.Is(err, metadata.ErrStaleBrokerEpoch) {
+		t.Errorf("expected ErrStaleBrokerEpoch, got %v", err)
+	}
+
+	topic := controller.NewTopic{Name: "__transaction_state", NumPartitions: 3, ReplicationFactor: 1, Configs: map[string]string{"cleanup.policy": "compact"}}
+	if err := channel.CreateTopic(topic, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(clock.Now())
+	if created, ok := active.Image().Topic("__transaction_state"); !ok || len(created.Partitions) != 3 {
+		t.Errorf("expected the topic with 3 partitions, got %+v", created)
+	}
+	if configs := active.Image().Configs(config.Resource{Type: config.TOPIC, Name: "__transaction_state"}); configs["cleanup.policy"] != "compact" {
+		t.Errorf("expected the topic to be compacted, got %v", configs)
+	}
+	if err := channel.CreateTopic(topic, time.Second); !errors.Is(err, metadata.ErrTopicExists) {
+		t.Errorf("expected ErrTopicExists, got %v", err)
+	}
+}
+
+func TestControllerChannelCleanRestartKeepsTheEligibleLeaderReplica(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+	listener, endpoint := listenQuorum(t)
+
+	transport := raft.NewMemoryTransport(clock.Now)
+	active := controller.NewController(controller.Config{SessionTimeout: 9 * time.Second}, raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint}}},
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), slog.New(slog.DiscardHandler), clock.Now())
+	transport.Register(active.Node())
+	active.Node().Poll(clock.Now())
+
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		BrokerRegistration: &BrokerRegistrationHandler{controller: active, authorizer: authorizer, now: clock.Now},
+		BrokerHeartbeat:    &BrokerHeartbeatHandler{controller: active, authorizer: authorizer, now: clock.Now},
+	})
+
+	channel := NewControllerChannel(2, "CONTROLLER", active.Node(), time.Second, 10*time.Millisecond)
+	defer channel.Close()
+
+	epoch, err := channel.RegisterBroker(controller.BrokerRegistration{BrokerId: 2, IncarnationId: "00000000-0000-0000-0000-000000000007", PreviousBrokerEpoch: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(clock.Now())
+	if _, err := channel.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: epoch, CurrentMetadataOffset: active.Image().Offset - 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The only replica of foo-0 leaves an ISR smaller than min.insync.replicas for the ELR when it shuts down
+	if _, err := active.CreateTopic("foo", [][]int32{{2}}); err != nil {
+		t.Fatal(err)
+	}
+	minIsr := "2"
+	if err := active.AlterConfig(config.Resource{Type: config.TOPIC, Name: "foo"}, "min.insync.replicas", &minIsr); err != nil {
+		t.Fatal(err)
+	}
+	result, err := channel.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: epoch, CurrentMetadataOffset: math.MaxInt64, WantShutDown: true})
+	if err != nil || !result.ShouldShutDown {
+		t.Fatalf("expected the broker to shut down, got %+v, %v", result, err)
+	}
+	active.Node().Poll(clock.Now())
+
+	logDirs := []string{t.TempDir()}
+	if err := storage.MarkCleanShutdown(logDirs, epoch); err != nil {
+		t.Fatal(err)
+	}
+	clean, previousBrokerEpoch, err := storage.ConsumeCleanShutdown(logDirs)
+	if err != nil || !clean || previousBrokerEpoch != epoch {
+		t.Fatalf("expected a clean restart from epoch %d, got %v, %d, %v", epoch, clean, previousBrokerEpoch, err)
+	}
+
+	// The next incarnation stopped cleanly from the current registration, it has every committed record
+	if _, err := channel.RegisterBroker(controller.BrokerRegistration{BrokerId: 2, IncarnationId: "00000000-0000-0000-0000-000000000008", PreviousBrokerEpoch: previousBrokerEpoch}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(clock.Now())
+
+	topic, _ := active.Image().Topic("foo")
+	if partition := topic.Partitions[0]; !slices.Equal(partition.Elr, []int32{2}) {
+		t.Errorf("expected broker 2 to stay in the ELR of foo-0, got %+v", partition)
+	}
+}
@@ -0,0 +1,325 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type AclCreation struct {
+	ResourceType        int8
+	ResourceName        string
+	ResourcePatternType int8
+	Principal           string
+	Host                string
+	Operation           int8
+	PermissionType      int8
+	TaggedFields        map[string]string
+}
+
+func (c AclCreation) toBinding() acl.Binding {
+	return acl.Binding{
+		ResourceType:   acl.ResourceType(c.ResourceType),
+		ResourceName:   c.ResourceName,
+		PatternType:    acl.PatternType(c.ResourcePatternType),
+		Principal:      c.Principal,
+		Host:           c.Host,
+		Operation:      acl.Operation(c.Operation),
+		PermissionType: acl.PermissionType(c.PermissionType),
+	}
+}
+
+type CreateAclsRequest struct {
+	Header       RequestHeader
+	Creations    []AclCreation
+	TaggedFields map[string]string
+}
+
+func (r *CreateAclsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *CreateAclsRequest) GetApiKey() KafkaAPIKey {
+	return CreateAcls
+}
+
+func (r *CreateAclsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *CreateAclsRequest) Validate() error {
+	if r.Header.RequestApiVersion < 2 || r.Header.RequestApiVersion > 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AclCreationResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	TaggedFields map[string]string
+}
+
+type CreateAclsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Results       []AclCreationResult
+	TaggedFields  map[string]string
+}
+
+func (r *CreateAclsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *CreateAclsResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTime = throttleTimeMs }
+
+func (r *CreateAclsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.Results {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *CreateAclsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, result := range r.Results {
+		bufferSize += 16
+		if result.ErrorMessage != nil {
+			bufferSize += len(*result.ErrorMessage)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Results)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range r.Results {
+		index, err = serializer.SerializeInt16(buffer, index, result.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, result.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, result.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// CreateAclsHandler creates ACLs through the active controller, their responses wait for the broker to load them
+type CreateAclsHandler struct {
+	controller *controller.Controller
+	// nil to answer without waiting for the ACLs
+	commits    *metadataPurgatory
+	timeout    time.Duration
+	authorizer acl.Authorizer
+}
+
+func (h *CreateAclsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &CreateAclsRequest{}
+	req.Header = requestHeader
+
+	creationsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse creations length from CreateAcls request",
+		}
+	}
+
+	creations := make([]AclCreation, 0, max(creationsLength, 0))
+
+	for i := 0; i < creationsLength; i++ {
+		creation := AclCreation{}
+
+		creation.ResourceType, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource type from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource name from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.ResourcePatternType, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource pattern type from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.Principal, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse principal from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.Host, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse host from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.Operation, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse operation from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.PermissionType, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse permission type from CreateAcls request at index %d", i),
+			}
+		}
+
+		creation.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse creation tagged fields from CreateAcls request",
+			}
+		}
+
+		creations = append(creations, creation)
+	}
+
+	req.Creations = creations
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from CreateAcls request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *CreateAclsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *CreateAclsHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*CreateAclsRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("CreateAclsHandler received %T instead of *CreateAclsRequest", req))
+		return
+	}
+
+	results := make([]AclCreationResult, len(apiReq.Creations))
+	for i := range results {
+		results[i].TaggedFields = make(map[string]string)
+	}
+	response := &CreateAclsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Results:       results,
+		TaggedFields:  make(map[string]string),
+	}
+
+	// The ACLs are metadata records, the active controller validates and appends them
+	_, err := aclManager(h.authorizer, session, acl.ALTER, apiReq.Validate())
+	if err == nil && h.controller == nil {
+		err = controller.ErrNotController
+	}
+	offset := int64(-1)
+	if err == nil {
+		bindings := make([]acl.Binding, 0, len(apiReq.Creations))
+		for _, creation := range apiReq.Creations {
+			bindings = append(bindings, creation.toBinding())
+		}
+
+		var bindingErrs []error
+		bindingErrs, offset, err = h.controller.CreateAcls(bindings)
+		for i, bindingErr := range bindingErrs {
+			if bindingErr != nil {
+				results[i].ErrorCode, results[i].ErrorMessage = configErrorCode(bindingErr)
+			}
+		}
+	}
+	if err != nil {
+		for i := range results {
+			results[i].ErrorCode, results[i].ErrorMessage = configErrorCode(err)
+		}
+	}
+
+	if offset < 0 || h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	// The response waits for the broker to load the ACLs, so that the client sees them right away
+	h.commits.await(h.timeout, loadedOffset(offset), func() {
+		respond(response, nil)
+	}, func() {
+		message := "the ACL was not loaded before the timeout"
+		for i := range results {
+			if results[i].ErrorCode == int16(NONE) {
+				results[i].ErrorCode, results[i].ErrorMessage = int16(REQUEST_TIMED_OUT), &message
+			}
+		}
+		respond(response, nil)
+	})
+}
@@ -0,0 +1,218 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestCreateAclsParseRequestBody(t *testing.T) {
+	handler := CreateAclsHandler{}
+
+	tests := []struct {
+		name      string
+		input     []byte
+		bodyIndex int
+		want      CreateAclsRequest
+		wantErr   bool
+	}{
+		{
+			name: "CreateAcls request with one creation",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x25, // MessageSize: 37
+				0x00, 0x1E, // RequestApiKey: 30 (CreateAcls)
+				0x00, 0x03, // RequestApiVersion: 3
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,                // Creations array length (1 creation + 1)
+				0x02,                // ResourceType: 2 (TOPIC)
+				0x04, 'f', 'o', 'o', // ResourceName: "foo"
+				0x03,                                         // ResourcePatternType: 3 (LITERAL)
+				0x09, 'U', 's', 'e', 'r', ':', 'b', 'o', 'b', // Principal: "User:bob"
+				0x02, '*', // Host: "*"
+				0x03, // Operation: 3 (READ)
+				0x03, // PermissionType: 3 (ALLOW)
+				0x00, // Creation tagged fields
+				0x00, // Request tagged fields
+			},
+			bodyIndex: 19,
+			want: CreateAclsRequest{
+				Creations: []AclCreation{
+					{
+						ResourceType:        2,
+						ResourceName:        "foo",
+						ResourcePatternType: 3,
+						Principal:           "User:bob",
+						Host:                "*",
+						Operation:           3,
+						PermissionType:      3,
+						TaggedFields:        map[string]string{},
+					},
+				},
+				TaggedFields: map[string]string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "CreateAcls request with a truncated creation",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x15, // MessageSize: 21
+				0x00, 0x1E, // RequestApiKey: 30 (CreateAcls)
+				0x00, 0x03, // RequestApiVersion: 3
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,                // Creations array length (1 creation + 1)
+				0x02,                // ResourceType: 2 (TOPIC)
+				0x04, 'f', 'o', 'o', // ResourceName: "foo"
+			},
+			bodyIndex: 19,
+			want:      CreateAclsRequest{},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := RequestHeader{RequestApiKey: 30, RequestApiVersion: 3, CorrelationId: 66, ClientId: "test"}
+
+			got, err := handler.ParseRequestBody(header, tt.input, tt.bodyIndex)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotReq, ok := got.(*CreateAclsRequest)
+			if !ok {
+				t.Fatalf("expected *CreateAclsRequest, got %T", got)
+			}
+
+			if !reflect.DeepEqual(gotReq.Creations, tt.want.Creations) {
+				t.Errorf("Creations mismatch:\ngot  %+v\nwant %+v", gotReq.Creations, tt.want.Creations)
+			}
+
+			if !reflect.DeepEqual(gotReq.TaggedFields, tt.want.TaggedFields) {
+				t.Errorf("TaggedFields mismatch: got %v, want %v", gotReq.TaggedFields, tt.want.TaggedFields)
+			}
+		})
+	}
+}
+
+func TestCreateAclsHandleRequest(t *testing.T) {
+	header := RequestHeader{RequestApiKey: 30, RequestApiVersion: 3, CorrelationId: 7}
+	readFoo := AclCreation{ResourceType: 2, ResourceName: "foo", ResourcePatternType: 3, Principal: "User:bob", Host: "*", Operation: 3, PermissionType: 3}
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		authorizer    acl.Authorizer
+		controller    *controller.Controller
+		session       *Session
+		creations     []AclCreation
+		wantErrorCode int16
+		wantAcls      int
+	}{
+		{
+			name:          "Valid binding",
+			authorizer:    acl.NewAclAuthorizer(nil, true),
+			controller:    newTestController(now),
+			session:       NewSession("127.0.0.1"),
+			creations:     []AclCreation{readFoo},
+			wantErrorCode: 0,
+			wantAcls:      1,
+		},
+		{
+			name:          "Existing binding",
+			authorizer:    acl.NewAclAuthorizer(nil, true),
+			controller:    newTestController(now),
+			session:       NewSession("127.0.0.1"),
+			creations:     []AclCreation{readFoo, readFoo},
+			wantErrorCode: 0,
+			wantAcls:      1,
+		},
+		{
+			name:          "Prefixed wildcard is invalid",
+			authorizer:    acl.NewAclAuthorizer(nil, true),
+			controller:    newTestController(now),
+			session:       NewSession("127.0.0.1"),
+			creations:     []AclCreation{{ResourceType: 2, ResourceName: "foo", ResourcePatternType: 1, Principal: "User:bob", Host: "*", Operation: 3, PermissionType: 3}},
+			wantErrorCode: int16(INVALID_REQUEST),
+			wantAcls:      0,
+		},
+		{
+			name:          "Not allowed to alter the cluster",
+			authorizer:    acl.NewAclAuthorizer(nil, false),
+			controller:    newTestController(now),
+			session:       NewSession("127.0.0.1"),
+			creations:     []AclCreation{readFoo},
+			wantErrorCode: int16(CLUSTER_AUTHORIZATION_FAILED),
+			wantAcls:      0,
+		},
+		{
+			name:          "Super user",
+			authorizer:    acl.NewAclAuthorizer([]string{"User:admin"}, false),
+			controller:    newTestController(now),
+			session:       &Session{principal: "User:admin", ClientHost: "127.0.0.1"},
+			creations:     []AclCreation{readFoo},
+			wantErrorCode: 0,
+			wantAcls:      1,
+		},
+		{
+			name:          "Not a controller",
+			authorizer:    acl.NewAclAuthorizer(nil, true),
+			session:       NewSession("127.0.0.1"),
+			creations:     []AclCreation{readFoo},
+			wantErrorCode: int16(NOT_CONTROLLER),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CreateAclsHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := CreateAclsRequest{Header: header, Creations: tt.creations}
+
+			got, err := handler.Handle(tt.session, &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*CreateAclsResponse)
+			if !ok {
+				t.Fatalf("expected *CreateAclsResponse, got %T", got)
+			}
+
+			if len(gotResp.Results) != len(tt.creations) {
+				t.Fatalf("Results length mismatch: got %d, want %d", len(gotResp.Results), len(tt.creations))
+			}
+
+			for i, result := range gotResp.Results {
+				if result.ErrorCode != tt.wantErrorCode {
+					t.Errorf("Results[%d] ErrorCode mismatch: got %d, want %d", i, result.ErrorCode, tt.wantErrorCode)
+				}
+			}
+
+			// The ACLs are the records the controller appended
+			if tt.controller != nil {
+				tt.controller.Node().Poll(now)
+				if acls := tt.controller.Image().Acls(); len(acls) != tt.wantAcls {
+					t.Errorf("ACLs length mismatch: got %d, want %d", len(acls), tt.wantAcls)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,830 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// createTopicsConfigErrorCodeTag is the tag of topic_config_error_code, why the configs of a created topic are not
+// in the response
+const createTopicsConfigErrorCodeTag = "0"
+
+type CreatableReplicaAssignment struct {
+	PartitionIndex int32
+	BrokerIds      []int32
+	TaggedFields   map[string]string
+}
+
+type CreatableTopicConfig struct {
+	Name         string
+	Value        *string
+	TaggedFields map[string]string
+}
+
+type CreatableTopic struct {
+	Name string
+	// -1 when the assignments are given or for the default of the cluster
+	NumPartitions     int32
+	ReplicationFactor int16
+	Assignments       []CreatableReplicaAssignment
+	Configs           []CreatableTopicConfig
+	TaggedFields      map[string]string
+}
+
+type CreateTopicsRequest struct {
+	Header       RequestHeader
+	Topics       []CreatableTopic
+	TimeoutMs    int32
+	ValidateOnly bool
+	TaggedFields map[string]string
+}
+
+func (r *CreateTopicsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *CreateTopicsRequest) GetApiKey() KafkaAPIKey {
+	return CreateTopics
+}
+
+func (r *CreateTopicsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *CreateTopicsRequest) Validate() error {
+	if r.Header.RequestApiVersion < 5 || r.Header.RequestApiVersion > 7 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the controller channel of a broker sends to the active controller
+func (r *CreateTopicsRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	for _, topic := range r.Topics {
+		bufferSize += 32 + len(topic.Name)
+		for _, assignment := range topic.Assignments {
+			bufferSize += 16 + 4*len(assignment.BrokerIds)
+		}
+		for _, topicConfig := range topic.Configs {
+			bufferSize += 16 + len(topicConfig.Name)
+			if topicConfig.Value != nil {
+				bufferSize += len(*topicConfig.Value)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, topic.NumPartitions)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, topic.ReplicationFactor)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Assignments)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, assignment := range topic.Assignments {
+			index, err = serializer.SerializeInt32(buffer, index, assignment.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(assignment.BrokerIds)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, brokerId := range assignment.BrokerIds {
+				index, err = serializer.SerializeInt32(buffer, index, brokerId)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, assignment.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Configs)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topicConfig := range topic.Configs {
+			index, err = serializer.SerializeCompactString(buffer, index, topicConfig.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, topicConfig.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, topicConfig.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.TimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeBoolean(buffer, index, r.ValidateOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type CreatableTopicConfigs struct {
+	Name         string
+	Value        *string
+	ReadOnly     bool
+	ConfigSource int8
+	IsSensitive  bool
+	TaggedFields map[string]string
+}
+
+type CreatableTopicResult struct {
+	Name         string
+	TopicId      string
+	ErrorCode    int16
+	ErrorMessage *string
+	// -1 when the topic was not created
+	NumPartitions     int32
+	ReplicationFactor int16
+	// nil when the topic was not created or its configs cannot be described
+	Configs      []CreatableTopicConfigs
+	TaggedFields map[string]string
+}
+
+type CreateTopicsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Topics        []CreatableTopicResult
+	TaggedFields  map[string]string
+}
+
+func (r *CreateTopicsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *CreateTopicsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *CreateTopicsResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{}
+	for _, topic := range r.Topics {
+		counts[topic.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *CreateTopicsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, topic := range r.Topics {
+		bufferSize += 64 + len(topic.Name)
+		if topic.ErrorMessage != nil {
+			bufferSize += len(*topic.ErrorMessage)
+		}
+		for _, entry := range topic.Configs {
+			bufferSize += 16 + len(entry.Name)
+			if entry.Value != nil {
+				bufferSize += len(*entry.Value)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if apiVersion >= 7 {
+			index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, topic.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, topic.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, topic.NumPartitions)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, topic.ReplicationFactor)
+		if err != nil {
+			return nil, err
+		}
+
+		// A null array of configs has length 0
+		configsLength := 0
+		if topic.Configs != nil {
+			configsLength = len(topic.Configs) + 1
+		}
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(configsLength))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range topic.Configs {
+			index, err = serializer.SerializeCompactString(buffer, index, entry.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, entry.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeBoolean(buffer, index, entry.ReadOnly)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, entry.ConfigSource)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeBoolean(buffer, index, entry.IsSensitive)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, entry.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseCreateTopicsResponse reads the response of the active controller to the controller channel, at version 7
+func parseCreateTopicsResponse(buffer []byte) (*CreateTopicsResponse, error) {
+	response := &CreateTopicsResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CreateTopics response: %w", err)
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CreateTopics response: %w", err)
+	}
+
+	response.Topics = make([]CreatableTopicResult, 0, max(topicsLength, 0))
+	for i := 0; i < topicsLength; i++ {
+		topic := CreatableTopicResult{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err == nil {
+			topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+		}
+		if err == nil {
+			topic.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+		}
+		if err == nil {
+			topic.ErrorMessage, index, err = parser.ExtractCompactNullableString(buffer, index)
+		}
+		if err == nil {
+			topic.NumPartitions, index, err = parser.ExtractInt32(buffer, index)
+		}
+		if err == nil {
+			topic.ReplicationFactor, index, err = parser.ExtractInt16(buffer, index)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic of CreateTopics response: %w", err)
+		}
+
+		var configsLength int
+		configsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse configs of CreateTopics response: %w", err)
+		}
+		if configsLength >= 0 {
+			topic.Configs = make([]CreatableTopicConfigs, 0, configsLength)
+		}
+		for j := 0; j < configsLength; j++ {
+			entry := CreatableTopicConfigs{}
+
+			entry.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err == nil {
+				entry.Value, index, err = parser.ExtractCompactNullableString(buffer, index)
+			}
+			if err == nil {
+				entry.ReadOnly, index, err = parser.ExtractBoolean(buffer, index)
+			}
+			if err == nil {
+				entry.ConfigSource, index, err = parser.ExtractInt8(buffer, index)
+			}
+			if err == nil {
+				entry.IsSensitive, index, err = parser.ExtractBoolean(buffer, index)
+			}
+			if err == nil {
+				entry.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse config of CreateTopics response: %w", err)
+			}
+
+			topic.Configs = append(topic.Configs, entry)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic of CreateTopics response: %w", err)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CreateTopics response: %w", err)
+	}
+
+	return response, nil
+}
+
+// CreateTopicsHandler creates topics on the active controller. The topics are placed on the active brokers unless
+// the request assigns their replicas. The response waits up to the timeout of the request for the broker to load them
+type CreateTopicsHandler struct {
+	configs    *config.Store
+	controller *controller.Controller
+	// nil to answer without waiting for the topics
+	commits    *metadataPurgatory
+	authorizer acl.Authorizer
+}
+
+func (h *CreateTopicsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &CreateTopicsRequest{}
+	req.Header = requestHeader
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from CreateTopics request",
+		}
+	}
+
+	req.Topics = make([]CreatableTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := CreatableTopic{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from CreateTopics request at index %d", i),
+			}
+		}
+
+		topic.NumPartitions, index, err = parser.ExtractInt32(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse number of partitions from CreateTopics request",
+			}
+		}
+
+		topic.ReplicationFactor, index, err = parser.ExtractInt16(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse replication factor from CreateTopics request",
+			}
+		}
+
+		assignmentsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || assignmentsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse assignments length from CreateTopics request",
+			}
+		}
+		index = newIndex
+
+		topic.Assignments = make([]CreatableReplicaAssignment, 0, assignmentsLength)
+		for j := 0; j < assignmentsLength; j++ {
+			assignment := CreatableReplicaAssignment{}
+
+			assignment.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition index from CreateTopics request",
+				}
+			}
+
+			assignment.BrokerIds, index, err = parseNullableInt32Array(buffer, index)
+			if err != nil || assignment.BrokerIds == nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse broker ids from CreateTopics request",
+				}
+			}
+
+			assignment.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse assignment tagged fields from CreateTopics request",
+				}
+			}
+
+			topic.Assignments = append(topic.Assignments, assignment)
+		}
+
+		configsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || configsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse configs length from CreateTopics request",
+			}
+		}
+		index = newIndex
+
+		topic.Configs = make([]CreatableTopicConfig, 0, configsLength)
+		for j := 0; j < configsLength; j++ {
+			topicConfig := CreatableTopicConfig{}
+
+			topicConfig.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse config name from CreateTopics request",
+				}
+			}
+
+			topicConfig.Value, index, err = parser.ExtractCompactNullableString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse config value from CreateTopics request",
+				}
+			}
+
+			topicConfig.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse config tagged fields from CreateTopics request",
+				}
+			}
+
+			topic.Configs = append(topic.Configs, topicConfig)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from CreateTopics request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse timeout from CreateTopics request",
+		}
+	}
+
+	req.ValidateOnly, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse validate only from CreateTopics request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from CreateTopics request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *CreateTopicsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *CreateTopicsHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*CreateTopicsRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("CreateTopicsHandler received %T instead of *CreateTopicsRequest", req))
+		return
+	}
+
+	versionErr := apiReq.Validate()
+	// Kafka creates none of the topics that appear more than once in a request
+	counts := make(map[string]int)
+	for _, topic := range apiReq.Topics {
+		counts[topic.Name]++
+	}
+	canCreateAll := session.authorize(h.authorizer, acl.CREATE, acl.CLUSTER, acl.ClusterResourceName)
+
+	results := make([]CreatableTopicResult, 0, len(apiReq.Topics))
+	for _, topic := range apiReq.Topics {
+		result := CreatableTopicResult{
+			Name:              topic.Name,
+			TopicId:           zeroUuid,
+			NumPartitions:     -1,
+			ReplicationFactor: -1,
+			TaggedFields:      make(map[string]string),
+		}
+
+		err := versionErr
+		if err == nil && counts[topic.Name] > 1 {
+			err = &RequestParseError{Code: INVALID_REQUEST, Message: fmt.Sprintf("Topic %s appears more than once in the request", topic.Name)}
+		}
+		if err == nil && !canCreateAll && !session.authorize(h.authorizer, acl.CREATE, acl.TOPIC, topic.Name) {
+			err = &RequestParseError{Code: TOPIC_AUTHORIZATION_FAILED, Message: fmt.Sprintf("Not authorized to create topic %s", topic.Name)}
+		}
+
+		var newTopic controller.NewTopic
+		if err == nil {
+			newTopic, err = h.newTopic(topic)
+		}
+
+		var assignments [][]int32
+		if err == nil && h.controller == nil {
+			err = controller.ErrNotController
+		} else if err == nil {
+			result.TopicId, assignments, err = h.controller.CreateTopics(newTopic, apiReq.ValidateOnly)
+			if result.TopicId == "" {
+				result.TopicId = zeroUuid
+			}
+		}
+
+		if err != nil {
+			result.ErrorCode, result.ErrorMessage = createTopicErrorCode(err)
+			results = append(results, result)
+			continue
+		}
+
+		result.NumPartitions = int32(len(assignments))
+		result.ReplicationFactor = int16(len(assignments[0]))
+		if session.authorize(h.authorizer, acl.DESCRIBE_CONFIGS, acl.TOPIC, topic.Name) {
+			result.Configs = h.describeConfigs(topic.Name, newTopic.Configs)
+		} else {
+			result.TaggedFields[createTopicsConfigErrorCodeTag] = string(binary.BigEndian.AppendUint16(nil, uint16(TOPIC_AUTHORIZATION_FAILED)))
+		}
+
+		results = append(results, result)
+	}
+
+	response := &CreateTopicsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Topics:        results,
+		TaggedFields:  make(map[string]string),
+	}
+
+	if apiReq.ValidateOnly || h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	// The response waits for the broker to load the created topics, the ones it did not load in time time out
+	created := func(image *metadata.Image) bool {
+		for _, result := range results {
+			if _, ok := image.TopicById(result.TopicId); result.ErrorCode == int16(NONE) && !ok {
+				return false
+			}
+		}
+		return true
+	}
+	h.commits.await(time.Duration(apiReq.TimeoutMs)*time.Millisecond, created, func() {
+		respond(response, nil)
+	}, func() {
+		image := h.commits.loader.Image()
+		message := "the topic was not loaded before the timeout"
+		for i := range response.Topics {
+			if _, ok := image.TopicById(response.Topics[i].TopicId); response.Topics[i].ErrorCode == int16(NONE) && !ok {
+				response.Topics[i].ErrorCode, response.Topics[i].ErrorMessage = int16(REQUEST_TIMED_OUT), &message
+			}
+		}
+		respond(response, nil)
+	})
+}
+
+// newTopic checks the assignments and the configs of a topic to create. The assignments replace the number of
+// partitions and the replication factor, and must list the partitions from 0
+func (h *CreateTopicsHandler) newTopic(topic CreatableTopic) (controller.NewTopic, error) {
+	newTopic := controller.NewTopic{Name: topic.Name, NumPartitions: topic.NumPartitions, ReplicationFactor: topic.ReplicationFactor}
+
+	if len(topic.Assignments) > 0 {
+		if topic.NumPartitions != -1 || topic.ReplicationFactor != -1 {
+			return newTopic, &RequestParseError{Code: INVALID_REQUEST, Message: "Both the number of partitions or the replication factor and the replica assignments were set"}
+		}
+
+		newTopic.Assignments = make([][]int32, len(topic.Assignments))
+		for _, assignment := range topic.Assignments {
+			if assignment.PartitionIndex < 0 || int(assignment.PartitionIndex) >= len(topic.Assignments) || newTopic.Assignments[assignment.PartitionIndex] != nil {
+				return newTopic, fmt.Errorf("%w: the partitions of %s must be assigned once each, from 0", controller.ErrInvalidReplicas, topic.Name)
+			}
+			newTopic.Assignments[assignment.PartitionIndex] = assignment.BrokerIds
+		}
+	}
+
+	configs := make(map[string]string, len(topic.Configs))
+	for _, topicConfig := range topic.Configs {
+		if topicConfig.Value == nil {
+			return newTopic, fmt.Errorf("%w: null value for config %s of topic %s", config.ErrInvalidConfig, topicConfig.Name, topic.Name)
+		}
+		configs[topicConfig.Name] = *topicConfig.Value
+	}
+
+	// The topic has no dynamic config yet, every config of the request is a change
+	changes, err := h.configs.Alter(config.Resource{Type: config.TOPIC, Name: topic.Name}, configs)
+	if err != nil {
+		return newTopic, err
+	}
+	if len(changes) > 0 {
+		newTopic.Configs = make(map[string]string, len(changes))
+		for name, value := range changes {
+			newTopic.Configs[name] = *value
+		}
+	}
+
+	return newTopic, nil
+}
+
+// describeConfigs returns every config of a created topic, with the configs of the request in place of those the
+// brokers do not know before the topic is committed
+func (h *CreateTopicsHandler) describeConfigs(topic string, configs map[string]string) []CreatableTopicConfigs {
+	entries, err := h.configs.Describe(config.Resource{Type: config.TOPIC, Name: topic}, nil)
+	if err != nil {
+		return nil
+	}
+
+	described := make([]CreatableTopicConfigs, 0, len(entries))
+	for _, entry := range entries {
+		describedEntry := CreatableTopicConfigs{
+			Name:         entry.Definition.Name,
+			Value:        entry.Value,
+			ReadOnly:     entry.Definition.ReadOnly,
+			ConfigSource: int8(entry.Source),
+			IsSensitive:  entry.Definition.IsSensitive(),
+			TaggedFields: make(map[string]string),
+		}
+
+		if value, ok := configs[entry.Definition.Name]; ok {
+			describedEntry.ConfigSource = int8(config.TOPIC_CONFIG)
+			describedEntry.Value = nil
+			if !describedEntry.IsSensitive {
+				describedEntry.Value = &value
+			}
+		}
+
+		described = append(described, describedEntry)
+	}
+
+	return described
+}
+
+// createTopicErrorCode maps the reason a topic was not created to its error code and message
+func createTopicErrorCode(err error) (int16, *string) {
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, metadata.ErrTopicExists):
+		return int16(TOPIC_ALREADY_EXISTS), &message
+	case errors.Is(err, controller.ErrInvalidTopic):
+		return int16(INVALID_TOPIC_EXCEPTION), &message
+	case errors.Is(err, controller.ErrInvalidPartitions):
+		return int16(INVALID_PARTITIONS), &message
+	case errors.Is(err, controller.ErrInvalidReplicationFactor):
+		return int16(INVALID_REPLICATION_FACTOR), &message
+	case errors.Is(err, controller.ErrInvalidReplicas):
+		return int16(INVALID_REPLICA_ASSIGNMENT), &message
+	default:
+		return configErrorCode(err)
+	}
+}
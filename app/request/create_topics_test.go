@@ -0,0 +1,355 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestCreateTopicsParseRequestBody(t *testing.T) {
+	handler := CreateTopicsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x4A, // MessageSize: 74
+		0x00, 0x13, // RequestApiKey: 19 (CreateTopics)
+		0x00, 0x07, // RequestApiVersion: 7
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x03,                // Topics array length (2 topics + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x00, 0x00, 0x00, 0x03, // NumPartitions: 3
+		0x00, 0x02, // ReplicationFactor: 2
+		0x01,                                                             // Assignments array length (0 assignments + 1)
+		0x02,                                                             // Configs array length (1 config + 1)
+		0x0D, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's', // Name: "retention.ms"
+		0x02, '1', // Value: "1"
+		0x00,                // Config tagged fields
+		0x00,                // Topic tagged fields
+		0x04, 'b', 'a', 'r', // Name: "bar"
+		0xFF, 0xFF, 0xFF, 0xFF, // NumPartitions: -1
+		0xFF, 0xFF, // ReplicationFactor: -1
+		0x02,                   // Assignments array length (1 assignment + 1)
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x02,                   // BrokerIds array length (1 broker + 1)
+		0x00, 0x00, 0x00, 0x01, // BrokerId: 1
+		0x00,                   // Assignment tagged fields
+		0x01,                   // Configs array length (0 configs + 1)
+		0x00,                   // Topic tagged fields
+		0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+		0x01, // ValidateOnly: true
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &CreateTopicsRequest{
+		Header: header,
+		Topics: []CreatableTopic{
+			{
+				Name:              "foo",
+				NumPartitions:     3,
+				ReplicationFactor: 2,
+				Assignments:       []CreatableReplicaAssignment{},
+				Configs:           []CreatableTopicConfig{{Name: "retention.ms", Value: stringPtr("1"), TaggedFields: map[string]string{}}},
+				TaggedFields:      map[string]string{},
+			},
+			{
+				Name:              "bar",
+				NumPartitions:     -1,
+				ReplicationFactor: -1,
+				Assignments:       []CreatableReplicaAssignment{{PartitionIndex: 0, BrokerIds: []int32{1}, TaggedFields: map[string]string{}}},
+				Configs:           []CreatableTopicConfig{},
+				TaggedFields:      map[string]string{},
+			},
+		},
+		TimeoutMs:    30000,
+		ValidateOnly: true,
+		TaggedFields: map[string]string{},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	_, err = handler.ParseRequestBody(header, input[:40], 19)
+	if err == nil {
+		t.Errorf("expected error for truncated request but got nil")
+	}
+}
+
+func TestCreateTopicsHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	header := RequestHeader{RequestApiKey: 19, RequestApiVersion: 7, CorrelationId: 9}
+
+	tests := []struct {
+		name         string
+		version      int16
+		authorizer   acl.Authorizer
+		noController bool
+		validateOnly bool
+		topics       []CreatableTopic
+		want         []KafkaErrorCode
+		wantCreated  []string
+	}{
+		{
+			name:        "Create",
+			topics:      []CreatableTopic{{Name: "baz", NumPartitions: 2, ReplicationFactor: 1, Configs: []CreatableTopicConfig{{Name: "retention.ms", Value: stringPtr("1000")}}}},
+			want:        []KafkaErrorCode{NONE},
+			wantCreated: []string{"baz"},
+		},
+		{
+			name:        "Assigned replicas",
+			topics:      []CreatableTopic{{Name: "baz", NumPartitions: -1, ReplicationFactor: -1, Assignments: []CreatableReplicaAssignment{{PartitionIndex: 0, BrokerIds: []int32{1}}}}},
+			want:        []KafkaErrorCode{NONE},
+			wantCreated: []string{"baz"},
+		},
+		{
+			name:         "Validate only",
+			validateOnly: true,
+			topics:       []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 1}},
+			want:         []KafkaErrorCode{NONE},
+		},
+		{
+			name:   "Existing topic",
+			topics: []CreatableTopic{{Name: "foo", NumPartitions: 1, ReplicationFactor: 1}},
+			want:   []KafkaErrorCode{TOPIC_ALREADY_EXISTS},
+		},
+		{
+			name:        "Duplicate topic",
+			topics:      []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 1}, {Name: "qux", NumPartitions: 1, ReplicationFactor: 1}, {Name: "baz", NumPartitions: 1, ReplicationFactor: 1}},
+			want:        []KafkaErrorCode{INVALID_REQUEST, NONE, INVALID_REQUEST},
+			wantCreated: []string{"qux"},
+		},
+		{
+			name:   "Illegal name",
+			topics: []CreatableTopic{{Name: "baz/qux", NumPartitions: 1, ReplicationFactor: 1}},
+			want:   []KafkaErrorCode{INVALID_TOPIC_EXCEPTION},
+		},
+		{
+			name:   "Invalid config",
+			topics: []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 1, Configs: []CreatableTopicConfig{{Name: "retention.ms", Value: stringPtr("x")}}}},
+			want:   []KafkaErrorCode{INVALID_CONFIG},
+		},
+		{
+			name:   "Assignments and replication factor",
+			topics: []CreatableTopic{{Name: "baz", NumPartitions: -1, ReplicationFactor: 1, Assignments: []CreatableReplicaAssignment{{PartitionIndex: 0, BrokerIds: []int32{1}}}}},
+			want:   []KafkaErrorCode{INVALID_REQUEST},
+		},
+		{
+			name:   "Missing partition",
+			topics: []CreatableTopic{{Name: "baz", NumPartitions: -1, ReplicationFactor: -1, Assignments: []CreatableReplicaAssignment{{PartitionIndex: 1, BrokerIds: []int32{1}}}}},
+			want:   []KafkaErrorCode{INVALID_REPLICA_ASSIGNMENT},
+		},
+		{
+			name:   "Too few brokers",
+			topics: []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 2}},
+			want:   []KafkaErrorCode{INVALID_REPLICATION_FACTOR},
+		},
+		{
+			name:   "No partitions",
+			topics: []CreatableTopic{{Name: "baz", NumPartitions: 0, ReplicationFactor: 1}},
+			want:   []KafkaErrorCode{INVALID_PARTITIONS},
+		},
+		{
+			name:       "Not authorized",
+			authorizer: denyAllAuthorizer{},
+			topics:     []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 1}},
+			want:       []KafkaErrorCode{TOPIC_AUTHORIZATION_FAILED},
+		},
+		{
+			name:         "Not the controller",
+			noController: true,
+			topics:       []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 1}},
+			want:         []KafkaErrorCode{NOT_CONTROLLER},
+		},
+		{
+			name:    "Unsupported version",
+			version: 4,
+			topics:  []CreatableTopic{{Name: "baz", NumPartitions: 1, ReplicationFactor: 1}},
+			want:    []KafkaErrorCode{UNSUPPORTED_VERSION},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, loader, configs := newTestConfigs(t, now)
+			// Broker 1 of the test controller is fenced until it heartbeats
+			epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 1, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: 1, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+				t.Fatal(err)
+			}
+
+			handler := &CreateTopicsHandler{configs: configs, controller: active, authorizer: acl.NewAclAuthorizer(nil, true)}
+			if tt.authorizer != nil {
+				handler.authorizer = tt.authorizer
+			}
+			if tt.noController {
+				handler.controller = nil
+			}
+
+			request := &CreateTopicsRequest{Header: header, Topics: tt.topics, TimeoutMs: 30000, ValidateOnly: tt.validateOnly}
+			if tt.version != 0 {
+				request.Header.RequestApiVersion = tt.version
+			}
+
+			response, err := handler.Handle(NewSession("127.0.0.1"), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := response.(*CreateTopicsResponse)
+			if len(got.Topics) != len(tt.want) {
+				t.Fatalf("expected %d topics, got %+v", len(tt.want), got.Topics)
+			}
+			for i, topic := range got.Topics {
+				if topic.ErrorCode != int16(tt.want[i]) {
+					t.Errorf("topic %s: expected error %d, got %d (%v)", topic.Name, tt.want[i], topic.ErrorCode, topic.ErrorMessage)
+				}
+				if topic.ErrorCode != int16(NONE) && (topic.NumPartitions != -1 || topic.Configs != nil) {
+					t.Errorf("topic %s: a topic that was not created has no partitions and configs, got %+v", topic.Name, topic)
+				}
+			}
+
+			if _, err := response.Serialize(request.Header.RequestApiVersion); err != nil {
+				t.Fatalf("failed to serialize the response: %v", err)
+			}
+
+			active.Node().Poll(now)
+			created := []string{}
+			for _, name := range loader.Image().TopicNames() {
+				if name != "foo" && name != "bar" {
+					created = append(created, name)
+				}
+			}
+			if len(tt.wantCreated) == 0 {
+				tt.wantCreated = []string{}
+			}
+			if !reflect.DeepEqual(created, tt.wantCreated) {
+				t.Errorf("expected the new topics %v, got %v", tt.wantCreated, created)
+			}
+		})
+	}
+}
+
+func TestCreateTopicsHandleRequestDescribesConfigs(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 1, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: 1, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &CreateTopicsHandler{configs: configs, controller: active, authorizer: acl.NewAclAuthorizer(nil, true)}
+	request := &CreateTopicsRequest{
+		Header: RequestHeader{RequestApiKey: 19, RequestApiVersion: 7, CorrelationId: 9},
+		Topics: []CreatableTopic{{Name: "baz", NumPartitions: 3, ReplicationFactor: 1, Configs: []CreatableTopicConfig{{Name: "retention.ms", Value: stringPtr("1000")}}}},
+	}
+
+	response, err := handler.Handle(NewSession("127.0.0.1"), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := response.(*CreateTopicsResponse).Topics[0]
+	if got.NumPartitions != 3 || got.ReplicationFactor != 1 || got.TopicId == zeroUuid {
+		t.Fatalf("expected a topic id, 3 partitions and 1 replica, got %+v", got)
+	}
+
+	wantEntries := map[string]CreatableTopicConfigs{
+		"retention.ms":   {Name: "retention.ms", Value: stringPtr("1000"), ConfigSource: int8(config.TOPIC_CONFIG), TaggedFields: map[string]string{}},
+		"cleanup.policy": {Name: "cleanup.policy", Value: stringPtr("delete"), ConfigSource: int8(config.DEFAULT_CONFIG), TaggedFields: map[string]string{}},
+	}
+	for _, entry := range got.Configs {
+		if want, ok := wantEntries[entry.Name]; ok {
+			if !reflect.DeepEqual(entry, want) {
+				t.Errorf("expected config %+v, got %+v", want, entry)
+			}
+			delete(wantEntries, entry.Name)
+		}
+	}
+	if len(wantEntries) > 0 {
+		t.Errorf("the configs %v are missing from %+v", wantEntries, got.Configs)
+	}
+
+	active.Node().Poll(now)
+	topic, ok := loader.Image().Topic("baz")
+	if !ok || topic.Id != got.TopicId || len(topic.Partitions) != 3 {
+		t.Errorf("expected topic baz %s with 3 partitions, got %+v", got.TopicId, topic)
+	}
+	if value, _ := configs.Value(config.Resource{Type: config.TOPIC, Name: "baz"}, "retention.ms"); value != "1000" {
+		t.Errorf("expected retention.ms 1000 once the topic is committed, got %s", value)
+	}
+}
+
+func TestCreateTopicsWaitsForTheTopics(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+
+	commits := newMetadataPurgatory(loader)
+	defer commits.purgatory.Shutdown()
+	handler := &CreateTopicsHandler{configs: configs, controller: active, commits: commits, authorizer: acl.NewAclAuthorizer(nil, true)}
+
+	create := func(name string, timeoutMs int32) <-chan *CreateTopicsResponse {
+		responses := make(chan *CreateTopicsResponse, 1)
+		request := &CreateTopicsRequest{
+			Header:    RequestHeader{RequestApiKey: 19, RequestApiVersion: 7, CorrelationId: 9},
+			Topics:    []CreatableTopic{{Name: name, NumPartitions: -1, ReplicationFactor: -1, Assignments: []CreatableReplicaAssignment{{PartitionIndex: 0, BrokerIds: []int32{1}}}}},
+			TimeoutMs: timeoutMs,
+		}
+		handler.HandleDelayed(NewSession("127.0.0.1"), request, func(response KafkaResponse, err error) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			responses <- response.(*CreateTopicsResponse)
+		})
+		return responses
+	}
+
+	responses := create("baz", 10_000)
+	select {
+	case response := <-responses:
+		t.Fatalf("expected the response to wait for the topic, got %+v", response.Topics)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	active.Node().Poll(now)
+	select {
+	case response := <-responses:
+		if _, ok := loader.Image().Topic("baz"); !ok || response.Topics[0].ErrorCode != int16(NONE) {
+			t.Errorf("expected baz to be loaded before the response, got %+v", response.Topics)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the response once the topic was loaded")
+	}
+
+	select {
+	case response := <-create("qux", 10):
+		if response.Topics[0].ErrorCode != int16(REQUEST_TIMED_OUT) {
+			t.Errorf("expected REQUEST_TIMED_OUT, got %+v", response.Topics)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the creation to time out")
+	}
+}
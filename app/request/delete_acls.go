@@ -0,0 +1,344 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type DeleteAclsFilter struct {
+	Filter       AclFilter
+	TaggedFields map[string]string
+}
+
+type DeleteAclsRequest struct {
+	Header       RequestHeader
+	Filters      []DeleteAclsFilter
+	TaggedFields map[string]string
+}
+
+func (r *DeleteAclsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DeleteAclsRequest) GetApiKey() KafkaAPIKey {
+	return DeleteAcls
+}
+
+func (r *DeleteAclsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DeleteAclsRequest) Validate() error {
+	if r.Header.RequestApiVersion < 2 || r.Header.RequestApiVersion > 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type DeleteAclsMatchingAcl struct {
+	ErrorCode      int16
+	ErrorMessage   *string
+	ResourceType   int8
+	ResourceName   string
+	PatternType    int8
+	Principal      string
+	Host           string
+	Operation      int8
+	PermissionType int8
+	TaggedFields   map[string]string
+}
+
+type DeleteAclsFilterResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	MatchingAcls []DeleteAclsMatchingAcl
+	TaggedFields map[string]string
+}
+
+type DeleteAclsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	FilterResults []DeleteAclsFilterResult
+	TaggedFields  map[string]string
+}
+
+func (r *DeleteAclsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DeleteAclsResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTime = throttleTimeMs }
+
+func (r *DeleteAclsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.FilterResults {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *DeleteAclsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, filterResult := range r.FilterResults {
+		bufferSize += 16
+		if filterResult.ErrorMessage != nil {
+			bufferSize += len(*filterResult.ErrorMessage)
+		}
+		for _, matchingAcl := range filterResult.MatchingAcls {
+			bufferSize += 32 + len(matchingAcl.ResourceName) + len(matchingAcl.Principal) + len(matchingAcl.Host)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.FilterResults)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filterResult := range r.FilterResults {
+		index, err = serializer.SerializeInt16(buffer, index, filterResult.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, filterResult.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(filterResult.MatchingAcls)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, matchingAcl := range filterResult.MatchingAcls {
+			index, err = serializer.SerializeInt16(buffer, index, matchingAcl.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, matchingAcl.ErrorMessage)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, matchingAcl.ResourceType)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactString(buffer, index, matchingAcl.ResourceName)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, matchingAcl.PatternType)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactString(buffer, index, matchingAcl.Principal)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactString(buffer, index, matchingAcl.Host)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, matchingAcl.Operation)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, matchingAcl.PermissionType)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, matchingAcl.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, filterResult.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// DeleteAclsHandler deletes ACLs through the active controller, its responses wait for the broker to load the deletions
+type DeleteAclsHandler struct {
+	controller *controller.Controller
+	// nil to answer without waiting for the deletions
+	commits    *metadataPurgatory
+	timeout    time.Duration
+	authorizer acl.Authorizer
+}
+
+func (h *DeleteAclsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DeleteAclsRequest{}
+	req.Header = requestHeader
+
+	filtersLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse filters length from DeleteAcls request",
+		}
+	}
+
+	filters := make([]DeleteAclsFilter, 0, max(filtersLength, 0))
+
+	for i := 0; i < filtersLength; i++ {
+		filter := DeleteAclsFilter{}
+
+		filter.Filter, index, err = parseAclFilter(buffer, index, "DeleteAcls")
+		if err != nil {
+			return nil, err
+		}
+
+		filter.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse filter tagged fields from DeleteAcls request",
+			}
+		}
+
+		filters = append(filters, filter)
+	}
+
+	req.Filters = filters
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DeleteAcls request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DeleteAclsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *DeleteAclsHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*DeleteAclsRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("DeleteAclsHandler received %T instead of *DeleteAclsRequest", req))
+		return
+	}
+
+	filterResults := make([]DeleteAclsFilterResult, len(apiReq.Filters))
+	for i := range filterResults {
+		filterResults[i] = DeleteAclsFilterResult{MatchingAcls: []DeleteAclsMatchingAcl{}, TaggedFields: make(map[string]string)}
+	}
+	response := &DeleteAclsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		FilterResults: filterResults,
+		TaggedFields:  make(map[string]string),
+	}
+
+	// The ACLs are metadata records, the active controller deletes the ones matched by the filters
+	_, err := aclManager(h.authorizer, session, acl.ALTER, apiReq.Validate())
+	if err == nil && h.controller == nil {
+		err = controller.ErrNotController
+	}
+	offset := int64(-1)
+	if err == nil {
+		filters := make([]acl.BindingFilter, 0, len(apiReq.Filters))
+		for _, requestFilter := range apiReq.Filters {
+			filters = append(filters, requestFilter.Filter.toBindingFilter())
+		}
+
+		var deletions []controller.AclDeletion
+		deletions, offset, err = h.controller.DeleteAcls(filters)
+		for i, deletion := range deletions {
+			if deletion.Err != nil {
+				filterResults[i].ErrorCode, filterResults[i].ErrorMessage = configErrorCode(deletion.Err)
+				continue
+			}
+
+			for _, binding := range deletion.Deleted {
+				filterResults[i].MatchingAcls = append(filterResults[i].MatchingAcls, DeleteAclsMatchingAcl{
+					ResourceType:   int8(binding.ResourceType),
+					ResourceName:   binding.ResourceName,
+					PatternType:    int8(binding.PatternType),
+					Principal:      binding.Principal,
+					Host:           binding.Host,
+					Operation:      int8(binding.Operation),
+					PermissionType: int8(binding.PermissionType),
+					TaggedFields:   make(map[string]string),
+				})
+			}
+		}
+	}
+	if err != nil {
+		for i := range filterResults {
+			filterResults[i].ErrorCode, filterResults[i].ErrorMessage = configErrorCode(err)
+		}
+	}
+
+	if offset < 0 || h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	// The response waits for the broker to load the deletions, so that the client no longer sees the ACLs
+	h.commits.await(h.timeout, loadedOffset(offset), func() {
+		respond(response, nil)
+	}, func() {
+		message := "the deletion was not loaded before the timeout"
+		for i := range filterResults {
+			if filterResults[i].ErrorCode == int16(NONE) && len(filterResults[i].MatchingAcls) > 0 {
+				filterResults[i].ErrorCode, filterResults[i].ErrorMessage = int16(REQUEST_TIMED_OUT), &message
+			}
+		}
+		respond(response, nil)
+	})
+}
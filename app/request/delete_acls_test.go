@@ -0,0 +1,126 @@
+package request
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+)
+
+func TestDeleteAclsParseRequestBody(t *testing.T) {
+	handler := DeleteAclsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x1C, // MessageSize: 28
+		0x00, 0x1F, // RequestApiKey: 31 (DeleteAcls)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // Filters array length (1 filter + 1)
+		0x02,                // ResourceTypeFilter: 2 (TOPIC)
+		0x00,                // ResourceNameFilter: null
+		0x01,                // PatternTypeFilter: 1 (ANY)
+		0x04, 'U', 's', 'e', // PrincipalFilter: "Use"
+		0x00, // HostFilter: null
+		0x03, // Operation: 3 (READ)
+		0x01, // PermissionType: 1 (ANY)
+		0x00, // Filter tagged fields
+		0x00, // Request tagged fields
+	}
+
+	want := []DeleteAclsFilter{
+		{
+			Filter: AclFilter{
+				ResourceTypeFilter: 2,
+				ResourceNameFilter: nil,
+				PatternTypeFilter:  1,
+				PrincipalFilter:    stringPtr("Use"),
+				HostFilter:         nil,
+				Operation:          3,
+				PermissionType:     1,
+			},
+			TaggedFields: map[string]string{},
+		},
+	}
+
+	header := RequestHeader{RequestApiKey: 31, RequestApiVersion: 3, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*DeleteAclsRequest)
+	if !ok {
+		t.Fatalf("expected *DeleteAclsRequest, got %T", got)
+	}
+
+	if !reflect.DeepEqual(gotReq.Filters, want) {
+		t.Errorf("Filters mismatch:\ngot  %+v\nwant %+v", gotReq.Filters, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:25], 19); err == nil {
+		t.Errorf("expected error for a truncated filter but got nil")
+	}
+}
+
+func TestDeleteAclsHandleRequest(t *testing.T) {
+	now := time.Now()
+	active := newTestController(now)
+	if _, _, err := active.CreateAcls([]acl.Binding{
+		{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW},
+		{ResourceType: acl.TOPIC, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW},
+		{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:alice", Host: "*", Operation: acl.WRITE, PermissionType: acl.ALLOW},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := DeleteAclsHandler{controller: active, authorizer: acl.NewAclAuthorizer(nil, true)}
+	request := DeleteAclsRequest{
+		Header: RequestHeader{RequestApiKey: 31, RequestApiVersion: 3, CorrelationId: 7},
+		Filters: []DeleteAclsFilter{
+			{Filter: AclFilter{ResourceTypeFilter: 2, ResourceNameFilter: stringPtr("foo"), PatternTypeFilter: 3, Operation: 1, PermissionType: 1}},
+			{Filter: AclFilter{ResourceTypeFilter: 2, PatternTypeFilter: 1, Operation: 0, PermissionType: 1}},
+		},
+	}
+
+	got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotResp, ok := got.(*DeleteAclsResponse)
+	if !ok {
+		t.Fatalf("expected *DeleteAclsResponse, got %T", got)
+	}
+
+	if len(gotResp.FilterResults) != 2 {
+		t.Fatalf("FilterResults length mismatch: got %d, want 2", len(gotResp.FilterResults))
+	}
+
+	wantMatching := []DeleteAclsMatchingAcl{
+		{ResourceType: 2, ResourceName: "foo", PatternType: 3, Principal: "User:alice", Host: "*", Operation: 4, PermissionType: 3, TaggedFields: map[string]string{}},
+		{ResourceType: 2, ResourceName: "foo", PatternType: 3, Principal: "User:bob", Host: "*", Operation: 3, PermissionType: 3, TaggedFields: map[string]string{}},
+	}
+
+	if !reflect.DeepEqual(gotResp.FilterResults[0].MatchingAcls, wantMatching) {
+		t.Errorf("MatchingAcls mismatch:\ngot  %+v\nwant %+v", gotResp.FilterResults[0].MatchingAcls, wantMatching)
+	}
+
+	if gotResp.FilterResults[1].ErrorCode != int16(INVALID_REQUEST) {
+		t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.FilterResults[1].ErrorCode, INVALID_REQUEST)
+	}
+
+	active.Node().Poll(now)
+	remaining := slices.Collect(maps.Values(active.Image().Acls()))
+	if len(remaining) != 1 || remaining[0].ResourceName != "bar" {
+		t.Errorf("remaining ACLs mismatch: got %+v", remaining)
+	}
+}
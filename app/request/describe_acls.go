@@ -0,0 +1,371 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// AclFilter selects ACL bindings in DescribeAcls and DeleteAcls requests
+type AclFilter struct {
+	ResourceTypeFilter int8
+	ResourceNameFilter *string
+	PatternTypeFilter  int8
+	PrincipalFilter    *string
+	HostFilter         *string
+	Operation          int8
+	PermissionType     int8
+}
+
+func (f AclFilter) toBindingFilter() acl.BindingFilter {
+	return acl.BindingFilter{
+		ResourceType:   acl.ResourceType(f.ResourceTypeFilter),
+		ResourceName:   f.ResourceNameFilter,
+		PatternType:    acl.PatternType(f.PatternTypeFilter),
+		Principal:      f.PrincipalFilter,
+		Host:           f.HostFilter,
+		Operation:      acl.Operation(f.Operation),
+		PermissionType: acl.PermissionType(f.PermissionType),
+	}
+}
+
+func parseAclFilter(buffer []byte, index int, requestName string) (AclFilter, int, error) {
+	var err error
+	filter := AclFilter{}
+
+	filter.ResourceTypeFilter, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse resource type filter from %s request", requestName),
+		}
+	}
+
+	filter.ResourceNameFilter, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse resource name filter from %s request", requestName),
+		}
+	}
+
+	filter.PatternTypeFilter, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse pattern type filter from %s request", requestName),
+		}
+	}
+
+	filter.PrincipalFilter, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse principal filter from %s request", requestName),
+		}
+	}
+
+	filter.HostFilter, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse host filter from %s request", requestName),
+		}
+	}
+
+	filter.Operation, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse operation from %s request", requestName),
+		}
+	}
+
+	filter.PermissionType, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return AclFilter{}, index, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: fmt.Sprintf("Failed to parse permission type from %s request", requestName),
+		}
+	}
+
+	return filter, index, nil
+}
+
+type DescribeAclsRequest struct {
+	Header       RequestHeader
+	Filter       AclFilter
+	TaggedFields map[string]string
+}
+
+func (r *DescribeAclsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeAclsRequest) GetApiKey() KafkaAPIKey {
+	return DescribeAcls
+}
+
+func (r *DescribeAclsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeAclsRequest) Validate() error {
+	if r.Header.RequestApiVersion < 2 || r.Header.RequestApiVersion > 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type AclDescription struct {
+	Principal      string
+	Host           string
+	Operation      int8
+	PermissionType int8
+	TaggedFields   map[string]string
+}
+
+type DescribeAclsResource struct {
+	ResourceType int8
+	ResourceName string
+	PatternType  int8
+	Acls         []AclDescription
+	TaggedFields map[string]string
+}
+
+type DescribeAclsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	Resources     []DescribeAclsResource
+	TaggedFields  map[string]string
+}
+
+func (r *DescribeAclsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeAclsResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTime = throttleTimeMs }
+
+func (r *DescribeAclsResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *DescribeAclsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+	for _, resource := range r.Resources {
+		bufferSize += 32 + len(resource.ResourceName)
+		for _, description := range resource.Acls {
+			bufferSize += 32 + len(description.Principal) + len(description.Host)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Resources)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range r.Resources {
+		index, err = serializer.SerializeInt8(buffer, index, resource.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, resource.ResourceName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt8(buffer, index, resource.PatternType)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(resource.Acls)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, description := range resource.Acls {
+			index, err = serializer.SerializeCompactString(buffer, index, description.Principal)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactString(buffer, index, description.Host)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, description.Operation)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, description.PermissionType)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, description.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, resource.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type DescribeAclsHandler struct {
+	authorizer acl.Authorizer
+}
+
+func (h *DescribeAclsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeAclsRequest{}
+	req.Header = requestHeader
+
+	req.Filter, index, err = parseAclFilter(buffer, index, "DescribeAcls")
+	if err != nil {
+		return nil, err
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeAcls request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeAclsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeAclsRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeAclsHandler received %T instead of *DescribeAclsRequest", req)
+	}
+
+	response := &DescribeAclsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Resources:     []DescribeAclsResource{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	manager, err := aclManager(h.authorizer, session, acl.DESCRIBE, apiReq.Validate())
+	if err != nil {
+		response.ErrorCode, response.ErrorMessage = configErrorCode(err)
+		return response, nil
+	}
+
+	filter := apiReq.Filter.toBindingFilter()
+	if err := filter.Validate(); err != nil {
+		response.ErrorCode, response.ErrorMessage = configErrorCode(&RequestParseError{Code: INVALID_REQUEST, Message: err.Error()})
+		return response, nil
+	}
+
+	// Bindings are grouped by the resource pattern they apply to
+	resourceIndexes := make(map[acl.Binding]int)
+	for _, binding := range manager.Acls(filter) {
+		key := acl.Binding{ResourceType: binding.ResourceType, ResourceName: binding.ResourceName, PatternType: binding.PatternType}
+
+		position, ok := resourceIndexes[key]
+		if !ok {
+			position = len(response.Resources)
+			resourceIndexes[key] = position
+			response.Resources = append(response.Resources, DescribeAclsResource{
+				ResourceType: int8(binding.ResourceType),
+				ResourceName: binding.ResourceName,
+				PatternType:  int8(binding.PatternType),
+				Acls:         []AclDescription{},
+				TaggedFields: make(map[string]string),
+			})
+		}
+
+		response.Resources[position].Acls = append(response.Resources[position].Acls, AclDescription{
+			Principal:      binding.Principal,
+			Host:           binding.Host,
+			Operation:      int8(binding.Operation),
+			PermissionType: int8(binding.PermissionType),
+			TaggedFields:   make(map[string]string),
+		})
+	}
+
+	return response, nil
+}
+
+// aclManager returns the ACL store of the authorizer once the request version is valid,
+// the broker runs an authorizer that keeps ACLs and the client is allowed to perform the operation on the cluster
+func aclManager(authorizer acl.Authorizer, session *Session, op acl.Operation, versionErr error) (acl.Manager, error) {
+	if versionErr != nil {
+		return nil, versionErr
+	}
+
+	manager, ok := authorizer.(acl.Manager)
+	if !ok {
+		return nil, &RequestParseError{Code: SECURITY_DISABLED, Message: "No authorizer that keeps ACLs is configured on the broker"}
+	}
+
+	if !session.authorize(authorizer, op, acl.CLUSTER, acl.ClusterResourceName) {
+		return nil, &RequestParseError{Code: CLUSTER_AUTHORIZATION_FAILED, Message: "Not authorized to manage the ACLs of the cluster"}
+	}
+
+	return manager, nil
+}
@@ -0,0 +1,207 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+)
+
+type denyAllAuthorizer struct{}
+
+func (a denyAllAuthorizer) Authorize(principal string, host string, op acl.Operation, resource acl.Resource) bool {
+	return false
+}
+
+func TestDescribeAclsParseRequestBody(t *testing.T) {
+	handler := DescribeAclsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x1A, // MessageSize: 26
+		0x00, 0x1D, // RequestApiKey: 29 (DescribeAcls)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // ResourceTypeFilter: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceNameFilter: "foo"
+		0x02, // PatternTypeFilter: 2 (MATCH)
+		0x00, // PrincipalFilter: null
+		0x00, // HostFilter: null
+		0x01, // Operation: 1 (ANY)
+		0x01, // PermissionType: 1 (ANY)
+		0x00, // Request tagged fields
+	}
+
+	want := AclFilter{
+		ResourceTypeFilter: 2,
+		ResourceNameFilter: stringPtr("foo"),
+		PatternTypeFilter:  2,
+		PrincipalFilter:    nil,
+		HostFilter:         nil,
+		Operation:          1,
+		PermissionType:     1,
+	}
+
+	header := RequestHeader{RequestApiKey: 29, RequestApiVersion: 3, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*DescribeAclsRequest)
+	if !ok {
+		t.Fatalf("expected *DescribeAclsRequest, got %T", got)
+	}
+
+	if !reflect.DeepEqual(gotReq.Filter, want) {
+		t.Errorf("Filter mismatch:\ngot  %+v\nwant %+v", gotReq.Filter, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:24], 19); err == nil {
+		t.Errorf("expected error for a truncated filter but got nil")
+	}
+}
+
+func TestDescribeAclsHandleRequest(t *testing.T) {
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW},
+		{ResourceType: acl.TOPIC, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:alice", Host: "*", Operation: acl.WRITE, PermissionType: acl.ALLOW},
+		{ResourceType: acl.TOPIC, ResourceName: "f", PatternType: acl.PREFIXED, Principal: "User:bob", Host: "*", Operation: acl.DESCRIBE, PermissionType: acl.DENY},
+		{ResourceType: acl.GROUP, ResourceName: "foo", PatternType: acl.LITERAL, Principal: "User:bob", Host: "*", Operation: acl.READ, PermissionType: acl.ALLOW},
+	})
+
+	header := RequestHeader{RequestApiKey: 29, RequestApiVersion: 3, CorrelationId: 7}
+
+	tests := []struct {
+		name          string
+		authorizer    acl.Authorizer
+		filter        AclFilter
+		wantErrorCode int16
+		wantResources []DescribeAclsResource
+	}{
+		{
+			name:          "Bindings that apply to a topic",
+			authorizer:    authorizer,
+			filter:        AclFilter{ResourceTypeFilter: 2, ResourceNameFilter: stringPtr("foo"), PatternTypeFilter: 2, Operation: 1, PermissionType: 1},
+			wantErrorCode: 0,
+			wantResources: []DescribeAclsResource{
+				{
+					ResourceType: 2,
+					ResourceName: "f",
+					PatternType:  4,
+					Acls: []AclDescription{
+						{Principal: "User:bob", Host: "*", Operation: 8, PermissionType: 2, TaggedFields: map[string]string{}},
+					},
+					TaggedFields: map[string]string{},
+				},
+				{
+					ResourceType: 2,
+					ResourceName: "foo",
+					PatternType:  3,
+					Acls: []AclDescription{
+						{Principal: "User:alice", Host: "*", Operation: 4, PermissionType: 3, TaggedFields: map[string]string{}},
+						{Principal: "User:bob", Host: "*", Operation: 3, PermissionType: 3, TaggedFields: map[string]string{}},
+					},
+					TaggedFields: map[string]string{},
+				},
+			},
+		},
+		{
+			name:          "Invalid filter",
+			authorizer:    authorizer,
+			filter:        AclFilter{ResourceTypeFilter: 0, PatternTypeFilter: 1, Operation: 1, PermissionType: 1},
+			wantErrorCode: int16(INVALID_REQUEST),
+			wantResources: []DescribeAclsResource{},
+		},
+		{
+			name:          "Authorizer without ACLs",
+			authorizer:    denyAllAuthorizer{},
+			filter:        AclFilter{ResourceTypeFilter: 1, PatternTypeFilter: 1, Operation: 1, PermissionType: 1},
+			wantErrorCode: int16(SECURITY_DISABLED),
+			wantResources: []DescribeAclsResource{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DescribeAclsHandler{authorizer: tt.authorizer}
+			request := DescribeAclsRequest{Header: header, Filter: tt.filter}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*DescribeAclsResponse)
+			if !ok {
+				t.Fatalf("expected *DescribeAclsResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+
+			if !reflect.DeepEqual(gotResp.Resources, tt.wantResources) {
+				t.Errorf("Resources mismatch:\ngot  %+v\nwant %+v", gotResp.Resources, tt.wantResources)
+			}
+		})
+	}
+}
+
+func TestDescribeAclsResponseSerialize(t *testing.T) {
+	response := DescribeAclsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Resources: []DescribeAclsResource{
+			{
+				ResourceType: 2,
+				ResourceName: "foo",
+				PatternType:  3,
+				Acls: []AclDescription{
+					{Principal: "User:bob", Host: "*", Operation: 3, PermissionType: 3, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x24, // MessageSize: 36
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                // ErrorMessage: null
+		0x02,                // Resources array length (1 resource + 1)
+		0x02,                // ResourceType: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceName: "foo"
+		0x03,                                         // PatternType: 3 (LITERAL)
+		0x02,                                         // Acls array length (1 acl + 1)
+		0x09, 'U', 's', 'e', 'r', ':', 'b', 'o', 'b', // Principal: "User:bob"
+		0x02, '*', // Host: "*"
+		0x03, // Operation: 3 (READ)
+		0x03, // PermissionType: 3 (ALLOW)
+		0x00, // Acl tagged fields
+		0x00, // Resource tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
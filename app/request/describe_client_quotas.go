@@ -0,0 +1,453 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// Match types of DescribeClientQuotas filter components
+const (
+	// Entities whose component has exactly the given name
+	MATCH_TYPE_EXACT int8 = 0
+	// Entities whose component is the default
+	MATCH_TYPE_DEFAULT int8 = 1
+	// Entities whose component has any name other than the default
+	MATCH_TYPE_SPECIFIED int8 = 2
+)
+
+// EntityComponent is one component of a quota entity, a nil name is the default
+type EntityComponent struct {
+	EntityType   string
+	EntityName   *string
+	TaggedFields map[string]string
+}
+
+type ClientQuotaFilterComponent struct {
+	EntityType   string
+	MatchType    int8
+	Match        *string
+	TaggedFields map[string]string
+}
+
+type DescribeClientQuotasRequest struct {
+	Header     RequestHeader
+	Components []ClientQuotaFilterComponent
+	// Strict only matches entities that have no component other than the filtered ones
+	Strict       bool
+	TaggedFields map[string]string
+}
+
+func (r *DescribeClientQuotasRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeClientQuotasRequest) GetApiKey() KafkaAPIKey {
+	return DescribeClientQuotas
+}
+
+func (r *DescribeClientQuotasRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeClientQuotasRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	seen := make(map[string]bool)
+	for _, component := range r.Components {
+		if component.EntityType != quota.USER && component.EntityType != quota.CLIENT_ID {
+			return &RequestParseError{Code: INVALID_REQUEST, Message: "Unsupported quota entity type: " + component.EntityType}
+		}
+
+		if seen[component.EntityType] {
+			return &RequestParseError{Code: INVALID_REQUEST, Message: "Duplicate filter component entity type: " + component.EntityType}
+		}
+		seen[component.EntityType] = true
+
+		switch component.MatchType {
+		case MATCH_TYPE_EXACT:
+			if component.Match == nil {
+				return &RequestParseError{Code: INVALID_REQUEST, Message: "Exact match of " + component.EntityType + " requires a name"}
+			}
+		case MATCH_TYPE_DEFAULT, MATCH_TYPE_SPECIFIED:
+		default:
+			return &RequestParseError{Code: INVALID_REQUEST, Message: fmt.Sprintf("Unknown match type: %d", component.MatchType)}
+		}
+	}
+
+	return nil
+}
+
+type ClientQuotaValue struct {
+	Key          string
+	Value        float64
+	TaggedFields map[string]string
+}
+
+type ClientQuotaEntry struct {
+	Entity       []EntityComponent
+	Values       []ClientQuotaValue
+	TaggedFields map[string]string
+}
+
+type DescribeClientQuotasResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	// Entries is nil when the request failed
+	Entries      []ClientQuotaEntry
+	TaggedFields map[string]string
+}
+
+func (r *DescribeClientQuotasResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeClientQuotasResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeClientQuotasResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *DescribeClientQuotasResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+	for _, entry := range r.Entries {
+		bufferSize += 8 + entityComponentsSize(entry.Entity)
+		for _, value := range entry.Values {
+			bufferSize += 16 + len(value.Key)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	// A length of 0 represents a null array
+	entriesLength := uint64(0)
+	if r.Entries != nil {
+		entriesLength = uint64(len(r.Entries) + 1)
+	}
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, entriesLength)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range r.Entries {
+		index, err = serializeEntityComponents(buffer, index, entry.Entity)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(entry.Values)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range entry.Values {
+			index, err = serializer.SerializeCompactString(buffer, index, value.Key)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeFloat64(buffer, index, value.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, value.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, entry.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+func entityComponentsSize(components []EntityComponent) int {
+	size := 8
+	for _, component := range components {
+		size += 16 + len(component.EntityType)
+		if component.EntityName != nil {
+			size += len(*component.EntityName)
+		}
+	}
+	return size
+}
+
+func serializeEntityComponents(buffer []byte, index int, components []EntityComponent) (int, error) {
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(components)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, component := range components {
+		index, err = serializer.SerializeCompactString(buffer, index, component.EntityType)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, component.EntityName)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, component.TaggedFields)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	return index, nil
+}
+
+// entityComponents describes an entity the way the client quota APIs do, user first
+func entityComponents(entity quota.Entity) []EntityComponent {
+	components := []EntityComponent{}
+
+	for _, component := range []struct {
+		entityType string
+		name       quota.Name
+	}{{quota.USER, entity.User}, {quota.CLIENT_ID, entity.ClientId}} {
+		switch component.name.Kind {
+		case quota.DEFAULT:
+			components = append(components, EntityComponent{EntityType: component.entityType, TaggedFields: make(map[string]string)})
+		case quota.SPECIFIC:
+			name := component.name.Value
+			components = append(components, EntityComponent{EntityType: component.entityType, EntityName: &name, TaggedFields: make(map[string]string)})
+		}
+	}
+
+	return components
+}
+
+type DescribeClientQuotasHandler struct {
+	quotas     *quota.Store
+	authorizer acl.Authorizer
+}
+
+func (h *DescribeClientQuotasHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeClientQuotasRequest{}
+	req.Header = requestHeader
+
+	componentsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse components length from DescribeClientQuotas request",
+		}
+	}
+
+	if componentsLength >= 0 {
+		req.Components = make([]ClientQuotaFilterComponent, 0, componentsLength)
+
+		for i := 0; i < componentsLength; i++ {
+			component := ClientQuotaFilterComponent{}
+
+			component.EntityType, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse entity type from DescribeClientQuotas request at index %d", i),
+				}
+			}
+
+			component.MatchType, index, err = parser.ExtractInt8(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse match type from DescribeClientQuotas request at index %d", i),
+				}
+			}
+
+			component.Match, index, err = parser.ExtractCompactNullableString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse match from DescribeClientQuotas request at index %d", i),
+				}
+			}
+
+			component.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse component tagged fields from DescribeClientQuotas request",
+				}
+			}
+
+			req.Components = append(req.Components, component)
+		}
+	}
+
+	req.Strict, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse strict from DescribeClientQuotas request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeClientQuotas request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeClientQuotasHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeClientQuotasRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeClientQuotasHandler received %T instead of *DescribeClientQuotasRequest", req)
+	}
+
+	response := &DescribeClientQuotasResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Entries:       nil,
+		TaggedFields:  make(map[string]string),
+	}
+
+	if err := apiReq.Validate(); err != nil {
+		response.ErrorCode, response.ErrorMessage = configErrorCode(err)
+		return response, nil
+	}
+
+	if !session.authorize(h.authorizer, acl.DESCRIBE_CONFIGS, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode, response.ErrorMessage = configErrorCode(&RequestParseError{Code: CLUSTER_AUTHORIZATION_FAILED, Message: "Not authorized to describe the client quotas"})
+		return response, nil
+	}
+
+	response.Entries = []ClientQuotaEntry{}
+
+	entities := h.quotas.Entities()
+	// Sorted so that clients get a stable order
+	sorted := make([]quota.Entity, 0, len(entities))
+	for entity := range entities {
+		if matchesFilter(entity, apiReq.Components, apiReq.Strict) {
+			sorted = append(sorted, entity)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b quota.Entity) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	for _, entity := range sorted {
+		entry := ClientQuotaEntry{
+			Entity:       entityComponents(entity),
+			Values:       []ClientQuotaValue{},
+			TaggedFields: make(map[string]string),
+		}
+
+		values := entities[entity]
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			entry.Values = append(entry.Values, ClientQuotaValue{Key: key, Value: values[key], TaggedFields: make(map[string]string)})
+		}
+
+		response.Entries = append(response.Entries, entry)
+	}
+
+	return response, nil
+}
+
+func matchesFilter(entity quota.Entity, components []ClientQuotaFilterComponent, strict bool) bool {
+	names := map[string]quota.Name{quota.USER: entity.User, quota.CLIENT_ID: entity.ClientId}
+	filtered := make(map[string]bool)
+
+	for _, component := range components {
+		filtered[component.EntityType] = true
+		name := names[component.EntityType]
+
+		switch component.MatchType {
+		case MATCH_TYPE_EXACT:
+			if name.Kind != quota.SPECIFIC || name.Value != *component.Match {
+				return false
+			}
+		case MATCH_TYPE_DEFAULT:
+			if name.Kind != quota.DEFAULT {
+				return false
+			}
+		case MATCH_TYPE_SPECIFIED:
+			if name.Kind != quota.SPECIFIC {
+				return false
+			}
+		}
+	}
+
+	if strict {
+		for entityType, name := range names {
+			if name.Kind != quota.ABSENT && !filtered[entityType] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
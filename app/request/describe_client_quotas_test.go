@@ -0,0 +1,257 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+)
+
+func TestDescribeClientQuotasParseRequestBody(t *testing.T) {
+	handler := DescribeClientQuotasHandler{}
+
+	tests := []struct {
+		name           string
+		input          []byte
+		wantComponents []ClientQuotaFilterComponent
+		wantStrict     bool
+		wantErr        bool
+	}{
+		{
+			name: "Exact user",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x1F, // MessageSize: 31
+				0x00, 0x30, // RequestApiKey: 48 (DescribeClientQuotas)
+				0x00, 0x01, // RequestApiVersion: 1
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,                     // Components array length: 1
+				0x05, 'u', 's', 'e', 'r', // EntityType: "user"
+				0x00,                          // MatchType: 0 (exact)
+				0x06, 'a', 'l', 'i', 'c', 'e', // Match: "alice"
+				0x00, // Component tagged fields
+				0x01, // Strict: true
+				0x00, // Request tagged fields
+			},
+			wantComponents: []ClientQuotaFilterComponent{{EntityType: "user", MatchType: MATCH_TYPE_EXACT, Match: stringPtr("alice"), TaggedFields: map[string]string{}}},
+			wantStrict:     true,
+		},
+		{
+			name: "Truncated component",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x13, // MessageSize: 19
+				0x00, 0x30, // RequestApiKey: 48 (DescribeClientQuotas)
+				0x00, 0x01, // RequestApiVersion: 1
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,           // Components array length: 1
+				0x05, 'u', 's', // EntityType: truncated
+			},
+			wantErr: true,
+		},
+	}
+
+	header := RequestHeader{RequestApiKey: 48, RequestApiVersion: 1, CorrelationId: 66, ClientId: "test"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.ParseRequestBody(header, tt.input, 19)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotReq, ok := got.(*DescribeClientQuotasRequest)
+			if !ok {
+				t.Fatalf("expected *DescribeClientQuotasRequest, got %T", got)
+			}
+
+			if !reflect.DeepEqual(gotReq.Components, tt.wantComponents) {
+				t.Errorf("Components mismatch: got %+v, want %+v", gotReq.Components, tt.wantComponents)
+			}
+
+			if gotReq.Strict != tt.wantStrict {
+				t.Errorf("Strict mismatch: got %v, want %v", gotReq.Strict, tt.wantStrict)
+			}
+		})
+	}
+}
+
+func TestDescribeClientQuotasHandleRequest(t *testing.T) {
+	quotas := quota.NewStore()
+	for entity, ops := range map[quota.Entity][]quota.Op{
+		{User: quota.Specific("alice")}:                                  {{Key: quota.REQUEST_PERCENTAGE, Value: 50}},
+		{User: quota.Specific("alice"), ClientId: quota.Specific("app")}: {{Key: quota.PRODUCER_BYTE_RATE, Value: 1024}},
+		{User: quota.Default}:                                            {{Key: quota.CONSUMER_BYTE_RATE, Value: 2048}},
+		{ClientId: quota.Default}:                                        {{Key: quota.PRODUCER_BYTE_RATE, Value: 4096}},
+	} {
+		if err := quotas.Alter(entity, ops); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		authorizer    acl.Authorizer
+		components    []ClientQuotaFilterComponent
+		strict        bool
+		wantErrorCode int16
+		// Entities of the entries, as {user=..., client-id=...}
+		wantEntities []string
+	}{
+		{
+			name:         "Every entity",
+			authorizer:   acl.NewAclAuthorizer(nil, true),
+			wantEntities: []string{"{client-id=<default>}", "{user=<default>}", "{user=alice, client-id=app}", "{user=alice}"},
+		},
+		{
+			name:         "Exact user",
+			authorizer:   acl.NewAclAuthorizer(nil, true),
+			components:   []ClientQuotaFilterComponent{{EntityType: "user", MatchType: MATCH_TYPE_EXACT, Match: stringPtr("alice")}},
+			wantEntities: []string{"{user=alice, client-id=app}", "{user=alice}"},
+		},
+		{
+			name:         "Exact user, strict",
+			authorizer:   acl.NewAclAuthorizer(nil, true),
+			components:   []ClientQuotaFilterComponent{{EntityType: "user", MatchType: MATCH_TYPE_EXACT, Match: stringPtr("alice")}},
+			strict:       true,
+			wantEntities: []string{"{user=alice}"},
+		},
+		{
+			name:         "Default client id",
+			authorizer:   acl.NewAclAuthorizer(nil, true),
+			components:   []ClientQuotaFilterComponent{{EntityType: "client-id", MatchType: MATCH_TYPE_DEFAULT}},
+			wantEntities: []string{"{client-id=<default>}"},
+		},
+		{
+			name:         "Specified users",
+			authorizer:   acl.NewAclAuthorizer(nil, true),
+			components:   []ClientQuotaFilterComponent{{EntityType: "user", MatchType: MATCH_TYPE_SPECIFIED}},
+			wantEntities: []string{"{user=alice, client-id=app}", "{user=alice}"},
+		},
+		{
+			name:          "Unsupported entity type",
+			authorizer:    acl.NewAclAuthorizer(nil, true),
+			components:    []ClientQuotaFilterComponent{{EntityType: "ip", MatchType: MATCH_TYPE_DEFAULT}},
+			wantErrorCode: int16(INVALID_REQUEST),
+		},
+		{
+			name:          "Exact match without a name",
+			authorizer:    acl.NewAclAuthorizer(nil, true),
+			components:    []ClientQuotaFilterComponent{{EntityType: "user", MatchType: MATCH_TYPE_EXACT}},
+			wantErrorCode: int16(INVALID_REQUEST),
+		},
+		{
+			name:          "Not authorized",
+			authorizer:    denyAllAuthorizer{},
+			wantErrorCode: int16(CLUSTER_AUTHORIZATION_FAILED),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DescribeClientQuotasHandler{quotas: quotas, authorizer: tt.authorizer}
+			request := DescribeClientQuotasRequest{
+				Header:     RequestHeader{RequestApiKey: 48, RequestApiVersion: 1, CorrelationId: 7},
+				Components: tt.components,
+				Strict:     tt.strict,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*DescribeClientQuotasResponse)
+			if !ok {
+				t.Fatalf("expected *DescribeClientQuotasResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+
+			if tt.wantErrorCode != 0 {
+				if gotResp.Entries != nil {
+					t.Errorf("expected null entries on error, got %v", gotResp.Entries)
+				}
+				return
+			}
+
+			entities := []string{}
+			for _, entry := range gotResp.Entries {
+				entity, err := quotaEntity(entry.Entity)
+				if err != nil {
+					t.Fatal(err)
+				}
+				entities = append(entities, entity.String())
+			}
+
+			if !reflect.DeepEqual(entities, tt.wantEntities) {
+				t.Errorf("entities mismatch: got %v, want %v", entities, tt.wantEntities)
+			}
+		})
+	}
+}
+
+func TestDescribeClientQuotasResponseSerialize(t *testing.T) {
+	response := DescribeClientQuotasResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Entries: []ClientQuotaEntry{
+			{
+				Entity:       []EntityComponent{{EntityType: "user", EntityName: stringPtr("alice"), TaggedFields: map[string]string{}}},
+				Values:       []ClientQuotaValue{{Key: "request_percentage", Value: 50, TaggedFields: map[string]string{}}},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x39, // MessageSize: 57
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                     // ErrorMessage: null
+		0x02,                     // Entries array length: 1
+		0x02,                     // Entity array length: 1
+		0x05, 'u', 's', 'e', 'r', // EntityType: "user"
+		0x06, 'a', 'l', 'i', 'c', 'e', // EntityName: "alice"
+		0x00,                                                                                           // Entity tagged fields
+		0x02,                                                                                           // Values array length: 1
+		0x13, 'r', 'e', 'q', 'u', 'e', 's', 't', '_', 'p', 'e', 'r', 'c', 'e', 'n', 't', 'a', 'g', 'e', // Key: "request_percentage"
+		0x40, 0x49, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Value: 50.0
+		0x00, // Value tagged fields
+		0x00, // Entry tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -0,0 +1,502 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/quota"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type DescribeConfigsResource struct {
+	ResourceType int8
+	ResourceName string
+	// ConfigurationKeys is nil when all the configs of the resource are requested
+	ConfigurationKeys []string
+	TaggedFields      map[string]string
+}
+
+type DescribeConfigsRequest struct {
+	Header               RequestHeader
+	Resources            []DescribeConfigsResource
+	IncludeSynonyms      bool
+	IncludeDocumentation bool
+	TaggedFields         map[string]string
+}
+
+func (r *DescribeConfigsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeConfigsRequest) GetApiKey() KafkaAPIKey {
+	return DescribeConfigs
+}
+
+func (r *DescribeConfigsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeConfigsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 4 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type DescribeConfigsSynonym struct {
+	Name         string
+	Value        *string
+	Source       int8
+	TaggedFields map[string]string
+}
+
+type DescribeConfigsEntry struct {
+	Name          string
+	Value         *string
+	ReadOnly      bool
+	ConfigSource  int8
+	IsSensitive   bool
+	Synonyms      []DescribeConfigsSynonym
+	ConfigType    int8
+	Documentation *string
+	TaggedFields  map[string]string
+}
+
+type DescribeConfigsResult struct {
+	ErrorCode    int16
+	ErrorMessage *string
+	ResourceType int8
+	ResourceName string
+	Configs      []DescribeConfigsEntry
+	TaggedFields map[string]string
+}
+
+type DescribeConfigsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Results       []DescribeConfigsResult
+	TaggedFields  map[string]string
+}
+
+func (r *DescribeConfigsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeConfigsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeConfigsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.Results {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *DescribeConfigsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, r.bufferSize())
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Results)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range r.Results {
+		index, err = serializer.SerializeInt16(buffer, index, result.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, result.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt8(buffer, index, result.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, result.ResourceName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(result.Configs)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range result.Configs {
+			index, err = serializer.SerializeCompactString(buffer, index, entry.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, entry.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeBoolean(buffer, index, entry.ReadOnly)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, entry.ConfigSource)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeBoolean(buffer, index, entry.IsSensitive)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(entry.Synonyms)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, synonym := range entry.Synonyms {
+				index, err = serializer.SerializeCompactString(buffer, index, synonym.Name)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeCompactNullableString(buffer, index, synonym.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt8(buffer, index, synonym.Source)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeTaggedFields(buffer, index, synonym.TaggedFields)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, entry.ConfigType)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, entry.Documentation)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, entry.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, result.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// Config documentation strings make the response size vary a lot so the buffer is sized from its content.
+// Every field gets enough room for the largest varint the serializer may need to write
+func (r *DescribeConfigsResponse) bufferSize() int {
+	size := 64
+
+	for _, result := range r.Results {
+		size += 64 + len(result.ResourceName)
+		if result.ErrorMessage != nil {
+			size += len(*result.ErrorMessage)
+		}
+
+		for _, entry := range result.Configs {
+			size += 64 + len(entry.Name)
+			if entry.Value != nil {
+				size += len(*entry.Value)
+			}
+			if entry.Documentation != nil {
+				size += len(*entry.Documentation)
+			}
+
+			for _, synonym := range entry.Synonyms {
+				size += 32 + len(synonym.Name)
+				if synonym.Value != nil {
+					size += len(*synonym.Value)
+				}
+			}
+		}
+	}
+
+	return size
+}
+
+type DescribeConfigsHandler struct {
+	configs *config.Store
+	// The topics are the ones of the metadata image
+	loader     *metadata.Loader
+	authorizer acl.Authorizer
+}
+
+func (h *DescribeConfigsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeConfigsRequest{}
+	req.Header = requestHeader
+
+	resourcesLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse resources length from DescribeConfigs request",
+		}
+	}
+
+	resources := make([]DescribeConfigsResource, 0, max(resourcesLength, 0))
+
+	for i := 0; i < resourcesLength; i++ {
+		resource := DescribeConfigsResource{}
+
+		resource.ResourceType, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource type from DescribeConfigs request at index %d", i),
+			}
+		}
+
+		resource.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource name from DescribeConfigs request at index %d", i),
+			}
+		}
+
+		var keysLength int
+		keysLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse configuration keys length from DescribeConfigs request at index %d", i),
+			}
+		}
+
+		// A null array means that all the configs are requested
+		if keysLength >= 0 {
+			resource.ConfigurationKeys = make([]string, 0, keysLength)
+
+			for j := 0; j < keysLength; j++ {
+				var key string
+				key, index, err = parser.ExtractCompactString(buffer, index)
+				if err != nil {
+					return nil, &RequestParseError{
+						Code:    INVALID_REQUEST,
+						Message: fmt.Sprintf("Failed to parse configuration key from DescribeConfigs request at index %d", j),
+					}
+				}
+
+				resource.ConfigurationKeys = append(resource.ConfigurationKeys, key)
+			}
+		}
+
+		resource.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse resource tagged fields from DescribeConfigs request",
+			}
+		}
+
+		resources = append(resources, resource)
+	}
+
+	req.Resources = resources
+
+	req.IncludeSynonyms, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse include synonyms from DescribeConfigs request",
+		}
+	}
+
+	req.IncludeDocumentation, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse include documentation from DescribeConfigs request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeConfigs request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeConfigsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeConfigsRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeConfigsHandler received %T instead of *DescribeConfigsRequest", req)
+	}
+
+	versionErr := apiReq.Validate()
+	results := make([]DescribeConfigsResult, 0, len(apiReq.Resources))
+
+	for _, resource := range apiReq.Resources {
+		result := DescribeConfigsResult{
+			ResourceType: resource.ResourceType,
+			ResourceName: resource.ResourceName,
+			Configs:      []DescribeConfigsEntry{},
+			TaggedFields: make(map[string]string),
+		}
+
+		if versionErr != nil {
+			result.ErrorCode, result.ErrorMessage = configErrorCode(versionErr)
+			results = append(results, result)
+			continue
+		}
+
+		if err := authorizeConfigResource(h.authorizer, session, acl.DESCRIBE_CONFIGS, resource.ResourceType, resource.ResourceName); err != nil {
+			result.ErrorCode, result.ErrorMessage = configErrorCode(err)
+			results = append(results, result)
+			continue
+		}
+
+		if config.ResourceType(resource.ResourceType) == config.TOPIC {
+			if _, ok := h.loader.Image().Topic(resource.ResourceName); !ok {
+				result.ErrorCode, result.ErrorMessage = configErrorCode(fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, resource.ResourceName))
+				results = append(results, result)
+				continue
+			}
+		}
+
+		entries, err := h.configs.Describe(config.Resource{Type: config.ResourceType(resource.ResourceType), Name: resource.ResourceName}, resource.ConfigurationKeys)
+		if err != nil {
+			result.ErrorCode, result.ErrorMessage = configErrorCode(err)
+			results = append(results, result)
+			continue
+		}
+
+		for _, entry := range entries {
+			describedEntry := DescribeConfigsEntry{
+				Name:         entry.Definition.Name,
+				Value:        entry.Value,
+				ReadOnly:     entry.Definition.ReadOnly,
+				ConfigSource: int8(entry.Source),
+				IsSensitive:  entry.Definition.IsSensitive(),
+				Synonyms:     []DescribeConfigsSynonym{},
+				ConfigType:   int8(entry.Definition.Type),
+				TaggedFields: make(map[string]string),
+			}
+
+			if apiReq.IncludeSynonyms {
+				for _, synonym := range entry.Synonyms {
+					describedEntry.Synonyms = append(describedEntry.Synonyms, DescribeConfigsSynonym{
+						Name:         synonym.Name,
+						Value:        synonym.Value,
+						Source:       int8(synonym.Source),
+						TaggedFields: make(map[string]string),
+					})
+				}
+			}
+
+			if apiReq.IncludeDocumentation {
+				documentation := entry.Definition.Documentation
+				describedEntry.Documentation = &documentation
+			}
+
+			result.Configs = append(result.Configs, describedEntry)
+		}
+
+		results = append(results, result)
+	}
+
+	response := &DescribeConfigsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Results:       results,
+		TaggedFields:  make(map[string]string),
+	}
+
+	return response, nil
+}
+
+// authorizeConfigResource checks the permission to describe or alter the configs of a resource.
+// Topic configs are protected by the ACLs of the topic while broker configs are protected by the ACLs of the cluster
+func authorizeConfigResource(authorizer acl.Authorizer, session *Session, op acl.Operation, resourceType int8, resourceName string) error {
+	switch config.ResourceType(resourceType) {
+	case config.TOPIC:
+		if !session.authorize(authorizer, op, acl.TOPIC, resourceName) {
+			return &RequestParseError{Code: TOPIC_AUTHORIZATION_FAILED, Message: fmt.Sprintf("Not authorized to access the configs of topic %s", resourceName)}
+		}
+	case config.BROKER:
+		if !session.authorize(authorizer, op, acl.CLUSTER, acl.ClusterResourceName) {
+			return &RequestParseError{Code: CLUSTER_AUTHORIZATION_FAILED, Message: "Not authorized to access the configs of the cluster"}
+		}
+	}
+
+	return nil
+}
+
+// configErrorCode maps the errors returned while describing or altering configs to the Kafka error code and message of a resource result
+func configErrorCode(err error) (int16, *string) {
+	message := err.Error()
+
+	var reqError *RequestParseError
+	switch {
+	case errors.As(err, &reqError):
+		return int16(reqError.Code), &reqError.Message
+	case errors.Is(err, config.ErrInvalidConfig):
+		return int16(INVALID_CONFIG), &message
+	case errors.Is(err, config.ErrInvalidRequest), errors.Is(err, quota.ErrInvalidQuota):
+		return int16(INVALID_REQUEST), &message
+	default:
+		return controllerErrorCode(err)
+	}
+}
@@ -0,0 +1,343 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+)
+
+func TestDescribeConfigsParseRequestBody(t *testing.T) {
+	handler := DescribeConfigsHandler{}
+
+	tests := []struct {
+		name      string
+		input     []byte
+		bodyIndex int
+		want      DescribeConfigsRequest
+		wantErr   bool
+	}{
+		{
+			name: "DescribeConfigs request with all configs of a topic",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x1A, // MessageSize: 26
+				0x00, 0x20, // RequestApiKey: 32 (DescribeConfigs)
+				0x00, 0x04, // RequestApiVersion: 4
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,                // Resources array length (1 resource + 1)
+				0x02,                // ResourceType: 2 (TOPIC)
+				0x04, 'f', 'o', 'o', // ResourceName: "foo"
+				0x00, // ConfigurationKeys: null
+				0x00, // Resource tagged fields
+				0x01, // IncludeSynonyms: true
+				0x00, // IncludeDocumentation: false
+				0x00, // Request tagged fields
+			},
+			bodyIndex: 19,
+			want: DescribeConfigsRequest{
+				Resources: []DescribeConfigsResource{
+					{
+						ResourceType:      2,
+						ResourceName:      "foo",
+						ConfigurationKeys: nil,
+						TaggedFields:      map[string]string{},
+					},
+				},
+				IncludeSynonyms:      true,
+				IncludeDocumentation: false,
+				TaggedFields:         map[string]string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "DescribeConfigs request with configuration keys",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x26, // MessageSize: 38
+				0x00, 0x20, // RequestApiKey: 32 (DescribeConfigs)
+				0x00, 0x04, // RequestApiVersion: 4
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,      // Resources array length (1 resource + 1)
+				0x04,      // ResourceType: 4 (BROKER)
+				0x02, '1', // ResourceName: "1"
+				0x02,                                                                  // ConfigurationKeys array length (1 key + 1)
+				0x0E, 'm', 'e', 's', 's', 'a', 'g', 'e', '.', 'm', 'a', 'x', '.', 'b', // ConfigurationKey: "message.max.b"
+				0x00, // Resource tagged fields
+				0x00, // IncludeSynonyms: false
+				0x01, // IncludeDocumentation: true
+				0x00, // Request tagged fields
+			},
+			bodyIndex: 19,
+			want: DescribeConfigsRequest{
+				Resources: []DescribeConfigsResource{
+					{
+						ResourceType:      4,
+						ResourceName:      "1",
+						ConfigurationKeys: []string{"message.max.b"},
+						TaggedFields:      map[string]string{},
+					},
+				},
+				IncludeSynonyms:      false,
+				IncludeDocumentation: true,
+				TaggedFields:         map[string]string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Truncated request",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x13, // MessageSize: 19
+				0x00, 0x20, // RequestApiKey: 32 (DescribeConfigs)
+				0x00, 0x04, // RequestApiVersion: 4
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02, // Resources array length (1 resource + 1)
+				0x02, // ResourceType: 2 (TOPIC)
+			},
+			bodyIndex: 19,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, _, err := ParseRequestHeader(tt.input, 0)
+			if err != nil {
+				t.Fatalf("failed to parse header: %v", err)
+			}
+
+			got, err := handler.ParseRequestBody(header, tt.input, tt.bodyIndex)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotReq, ok := got.(*DescribeConfigsRequest)
+			if !ok {
+				t.Fatalf("expected *DescribeConfigsRequest, got %T", got)
+			}
+
+			tt.want.Header = header
+			if !reflect.DeepEqual(*gotReq, tt.want) {
+				t.Errorf("request mismatch:\ngot  %+v\nwant %+v", *gotReq, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeConfigsHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	retention := "1000"
+	if err := active.AlterConfig(config.Resource{Type: config.TOPIC, Name: "foo"}, "retention.ms", &retention); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	handler := DescribeConfigsHandler{configs: configs, loader: loader, authorizer: acl.NewAclAuthorizer(nil, true)}
+	header := RequestHeader{RequestApiKey: 32, RequestApiVersion: 4, CorrelationId: 7}
+
+	tests := []struct {
+		name          string
+		request       DescribeConfigsRequest
+		wantErrorCode int16
+		wantConfigs   []DescribeConfigsEntry
+	}{
+		{
+			name: "Topic override with synonyms",
+			request: DescribeConfigsRequest{
+				Header: header,
+				Resources: []DescribeConfigsResource{
+					{ResourceType: 2, ResourceName: "foo", ConfigurationKeys: []string{"retention.ms"}},
+				},
+				IncludeSynonyms: true,
+			},
+			wantErrorCode: 0,
+			wantConfigs: []DescribeConfigsEntry{
+				{
+					Name:         "retention.ms",
+					Value:        stringPtr("1000"),
+					ConfigSource: int8(config.TOPIC_CONFIG),
+					Synonyms: []DescribeConfigsSynonym{
+						{Name: "retention.ms", Value: stringPtr("1000"), Source: int8(config.TOPIC_CONFIG), TaggedFields: map[string]string{}},
+						{Name: "log.retention.ms", Value: stringPtr("604800000"), Source: int8(config.DEFAULT_CONFIG), TaggedFields: map[string]string{}},
+					},
+					ConfigType:   int8(config.LONG),
+					TaggedFields: map[string]string{},
+				},
+			},
+		},
+		{
+			name: "Default value without synonyms",
+			request: DescribeConfigsRequest{
+				Header: header,
+				Resources: []DescribeConfigsResource{
+					{ResourceType: 2, ResourceName: "bar", ConfigurationKeys: []string{"retention.ms"}},
+				},
+			},
+			wantErrorCode: 0,
+			wantConfigs: []DescribeConfigsEntry{
+				{
+					Name:         "retention.ms",
+					Value:        stringPtr("604800000"),
+					ConfigSource: int8(config.DEFAULT_CONFIG),
+					Synonyms:     []DescribeConfigsSynonym{},
+					ConfigType:   int8(config.LONG),
+					TaggedFields: map[string]string{},
+				},
+			},
+		},
+		{
+			name: "Unknown topic",
+			request: DescribeConfigsRequest{
+				Header: header,
+				Resources: []DescribeConfigsResource{
+					{ResourceType: 2, ResourceName: "baz", ConfigurationKeys: []string{"retention.ms"}},
+				},
+			},
+			wantErrorCode: int16(UNKNOWN_TOPIC_OR_PARTITION),
+			wantConfigs:   []DescribeConfigsEntry{},
+		},
+		{
+			name: "Unknown broker",
+			request: DescribeConfigsRequest{
+				Header: header,
+				Resources: []DescribeConfigsResource{
+					{ResourceType: 4, ResourceName: "2"},
+				},
+			},
+			wantErrorCode: int16(INVALID_REQUEST),
+			wantConfigs:   []DescribeConfigsEntry{},
+		},
+		{
+			name: "Unsupported version",
+			request: DescribeConfigsRequest{
+				Header: RequestHeader{RequestApiKey: 32, RequestApiVersion: 1, CorrelationId: 7},
+				Resources: []DescribeConfigsResource{
+					{ResourceType: 2, ResourceName: "foo"},
+				},
+			},
+			wantErrorCode: int16(UNSUPPORTED_VERSION),
+			wantConfigs:   []DescribeConfigsEntry{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.Handle(NewSession("127.0.0.1"), &tt.request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*DescribeConfigsResponse)
+			if !ok {
+				t.Fatalf("expected *DescribeConfigsResponse, got %T", got)
+			}
+
+			if gotResp.CorrelationId != 7 {
+				t.Errorf("CorrelationId mismatch: got %d, want 7", gotResp.CorrelationId)
+			}
+
+			if len(gotResp.Results) != 1 {
+				t.Fatalf("Results length mismatch: got %d, want 1", len(gotResp.Results))
+			}
+
+			result := gotResp.Results[0]
+
+			if result.ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", result.ErrorCode, tt.wantErrorCode)
+			}
+
+			if !reflect.DeepEqual(result.Configs, tt.wantConfigs) {
+				t.Errorf("Configs mismatch:\ngot  %+v\nwant %+v", result.Configs, tt.wantConfigs)
+			}
+		})
+	}
+}
+
+func TestDescribeConfigsResponseSerialize(t *testing.T) {
+	response := DescribeConfigsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Results: []DescribeConfigsResult{
+			{
+				ErrorCode:    0,
+				ErrorMessage: nil,
+				ResourceType: 2,
+				ResourceName: "foo",
+				Configs: []DescribeConfigsEntry{
+					{
+						Name:         "a",
+						Value:        stringPtr("b"),
+						ConfigSource: 1,
+						Synonyms:     []DescribeConfigsSynonym{},
+						ConfigType:   2,
+						TaggedFields: map[string]string{},
+					},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x20, // MessageSize: 32
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,       // Results array length (1 result + 1)
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                // ErrorMessage: null
+		0x02,                // ResourceType: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceName: "foo"
+		0x02,      // Configs array length (1 config + 1)
+		0x02, 'a', // Name: "a"
+		0x02, 'b', // Value: "b"
+		0x00, // ReadOnly: false
+		0x01, // ConfigSource: 1 (TOPIC_CONFIG)
+		0x00, // IsSensitive: false
+		0x01, // Synonyms array length (0 synonyms + 1)
+		0x02, // ConfigType: 2 (STRING)
+		0x00, // Documentation: null
+		0x00, // Config tagged fields
+		0x00, // Result tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
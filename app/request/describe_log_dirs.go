@@ -0,0 +1,354 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+type DescribableLogDirTopic struct {
+	Topic        string
+	Partitions   []int32
+	TaggedFields map[string]string
+}
+
+type DescribeLogDirsRequest struct {
+	Header RequestHeader
+	// nil to describe the logs of every partition
+	Topics       []DescribableLogDirTopic
+	TaggedFields map[string]string
+}
+
+func (r *DescribeLogDirsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeLogDirsRequest) GetApiKey() KafkaAPIKey {
+	return DescribeLogDirs
+}
+
+func (r *DescribeLogDirsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeLogDirsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 4 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type DescribeLogDirsPartition struct {
+	PartitionIndex int32
+	PartitionSize  int64
+	OffsetLag      int64
+	IsFutureKey    bool
+	TaggedFields   map[string]string
+}
+
+type DescribeLogDirsTopic struct {
+	Name         string
+	Partitions   []DescribeLogDirsPartition
+	TaggedFields map[string]string
+}
+
+type DescribeLogDirsResult struct {
+	ErrorCode    int16
+	LogDir       string
+	Topics       []DescribeLogDirsTopic
+	TotalBytes   int64
+	UsableBytes  int64
+	TaggedFields map[string]string
+}
+
+type DescribeLogDirsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	Results       []DescribeLogDirsResult
+	TaggedFields  map[string]string
+}
+
+func (r *DescribeLogDirsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeLogDirsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeLogDirsResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, result := range r.Results {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *DescribeLogDirsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, result := range r.Results {
+		bufferSize += 40 + len(result.LogDir)
+		for _, topic := range result.Topics {
+			bufferSize += 16 + len(topic.Name) + 32*len(topic.Partitions)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Results)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range r.Results {
+		index, err = serializer.SerializeInt16(buffer, index, result.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, result.LogDir)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(result.Topics)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topic := range result.Topics {
+			index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, partition := range topic.Partitions {
+				index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt64(buffer, index, partition.PartitionSize)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt64(buffer, index, partition.OffsetLag)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeBoolean(buffer, index, partition.IsFutureKey)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeInt64(buffer, index, result.TotalBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt64(buffer, index, result.UsableBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, result.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// DescribeLogDirsHandler describes the log dirs of the broker, with the size of their disk and of the logs they hold
+type DescribeLogDirsHandler struct {
+	logs       *storage.LogManager
+	authorizer acl.Authorizer
+}
+
+func (h *DescribeLogDirsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeLogDirsRequest{}
+	req.Header = requestHeader
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from DescribeLogDirs request",
+		}
+	}
+
+	// A null array means that every partition is described
+	if topicsLength >= 0 {
+		req.Topics = make([]DescribableLogDirTopic, 0, topicsLength)
+	}
+	for i := 0; i < topicsLength; i++ {
+		topic := DescribableLogDirTopic{}
+
+		topic.Topic, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from DescribeLogDirs request at index %d", i),
+			}
+		}
+
+		topic.Partitions, index, err = parseNullableInt32Array(buffer, index)
+		if err != nil || topic.Partitions == nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions from DescribeLogDirs request",
+			}
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from DescribeLogDirs request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeLogDirs request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeLogDirsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeLogDirsRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeLogDirsHandler received %T instead of *DescribeLogDirsRequest", req)
+	}
+
+	response := &DescribeLogDirsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Results:       []DescribeLogDirsResult{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.DESCRIBE, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	var requested map[storage.TopicPartition]bool
+	if apiReq.Topics != nil {
+		requested = make(map[storage.TopicPartition]bool)
+		for _, topic := range apiReq.Topics {
+			for _, partition := range topic.Partitions {
+				requested[storage.TopicPartition{Topic: topic.Topic, Partition: partition}] = true
+			}
+		}
+	}
+
+	for _, description := range h.logs.DescribeLogDirs() {
+		result := DescribeLogDirsResult{
+			ErrorCode:    int16(NONE),
+			LogDir:       description.Path,
+			Topics:       []DescribeLogDirsTopic{},
+			TotalBytes:   description.TotalBytes,
+			UsableBytes:  description.UsableBytes,
+			TaggedFields: map[string]string{},
+		}
+		if description.Err != nil {
+			result.ErrorCode = int16(KAFKA_STORAGE_ERROR)
+		}
+
+		// The replicas are sorted by partition, so they are grouped by topic
+		for _, replica := range description.Replicas {
+			if requested != nil && !requested[replica.TopicPartition] {
+				continue
+			}
+
+			last := len(result.Topics) - 1
+			if last < 0 || result.Topics[last].Name != replica.Topic {
+				result.Topics = append(result.Topics, DescribeLogDirsTopic{
+					Name:         replica.Topic,
+					Partitions:   []DescribeLogDirsPartition{},
+					TaggedFields: map[string]string{},
+				})
+				last++
+			}
+
+			result.Topics[last].Partitions = append(result.Topics[last].Partitions, DescribeLogDirsPartition{
+				PartitionIndex: replica.Partition,
+				PartitionSize:  replica.Size,
+				OffsetLag:      replica.OffsetLag,
+				IsFutureKey:    replica.IsFuture,
+				TaggedFields:   map[string]string{},
+			})
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
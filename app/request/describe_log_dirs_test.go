@@ -0,0 +1,271 @@
+package request
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// newTestLogManager returns a log manager of two log dirs, with foo-0 and bar-0 in the first dir and foo-1 in the
+// second one, where foo-0 is moving
+func newTestLogManager(t *testing.T) (*storage.LogManager, []string) {
+	t.Helper()
+
+	dirs := []string{filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")}
+	logs, err := storage.LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logs.Close() })
+
+	for _, partition := range []storage.TopicPartition{{Topic: "foo", Partition: 0}, {Topic: "foo", Partition: 1}, {Topic: "bar", Partition: 0}} {
+		if _, err := logs.GetOrCreateLog(partition); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A record batch header of 3 records without the records
+	batch := make([]byte, 61)
+	batch[11] = 49 // BatchLength
+	batch[16] = 2  // Magic
+	batch[26] = 2  // LastOffsetDelta
+	// No producer
+	copy(batch[43:57], bytes.Repeat([]byte{0xff}, 14))
+	if _, err := logs.Append(storage.TopicPartition{Topic: "foo", Partition: 0}, batch, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.AlterReplicaLogDir(storage.TopicPartition{Topic: "foo", Partition: 0}, dirs[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	return logs, dirs
+}
+
+func TestDescribeLogDirsParseRequestBody(t *testing.T) {
+	handler := DescribeLogDirsHandler{}
+	header := RequestHeader{RequestApiKey: 35, RequestApiVersion: 4, CorrelationId: 66, ClientId: "test"}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  *DescribeLogDirsRequest
+	}{
+		{
+			name: "Some partitions",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x1F, // MessageSize: 31
+				0x00, 0x23, // RequestApiKey: 35 (DescribeLogDirs)
+				0x00, 0x04, // RequestApiVersion: 4
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,                // Topics array length: 1
+				0x04, 'f', 'o', 'o', // Topic: "foo"
+				0x03,                   // Partitions array length: 2
+				0x00, 0x00, 0x00, 0x00, // Partition: 0
+				0x00, 0x00, 0x00, 0x01, // Partition: 1
+				0x00, // Topic tagged fields
+				0x00, // Request tagged fields
+			},
+			want: &DescribeLogDirsRequest{
+				Header: header,
+				Topics: []DescribableLogDirTopic{
+					{Topic: "foo", Partitions: []int32{0, 1}, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		{
+			name: "Every partition",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x11, // MessageSize: 17
+				0x00, 0x23, // RequestApiKey: 35 (DescribeLogDirs)
+				0x00, 0x04, // RequestApiVersion: 4
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x00, // Topics: null
+				0x00, // Request tagged fields
+			},
+			want: &DescribeLogDirsRequest{
+				Header:       header,
+				Topics:       nil,
+				TaggedFields: map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.ParseRequestBody(header, tt.input, 19)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("request mismatch: got %+v, want %+v", got, tt.want)
+			}
+
+			if _, err := handler.ParseRequestBody(header, tt.input[:len(tt.input)-1], 19); err == nil {
+				t.Errorf("expected error for a truncated request")
+			}
+		})
+	}
+}
+
+func TestDescribeLogDirsHandleRequest(t *testing.T) {
+	logs, dirs := newTestLogManager(t)
+
+	tests := []struct {
+		name          string
+		authorizer    acl.Authorizer
+		topics        []DescribableLogDirTopic
+		wantErrorCode KafkaErrorCode
+		wantTopics    [][]DescribeLogDirsTopic
+	}{
+		{"Not authorized", denyAllAuthorizer{}, nil, CLUSTER_AUTHORIZATION_FAILED, [][]DescribeLogDirsTopic{}},
+		{"Every partition", acl.NewAclAuthorizer(nil, true), nil, NONE, [][]DescribeLogDirsTopic{
+			{
+				{Name: "bar", Partitions: []DescribeLogDirsPartition{
+					{PartitionIndex: 0, PartitionSize: 0, OffsetLag: 0, IsFutureKey: false, TaggedFields: map[string]string{}},
+				}, TaggedFields: map[string]string{}},
+				{Name: "foo", Partitions: []DescribeLogDirsPartition{
+					{PartitionIndex: 0, PartitionSize: 61, OffsetLag: 0, IsFutureKey: false, TaggedFields: map[string]string{}},
+				}, TaggedFields: map[string]string{}},
+			},
+			{
+				{Name: "foo", Partitions: []DescribeLogDirsPartition{
+					{PartitionIndex: 0, PartitionSize: 0, OffsetLag: 3, IsFutureKey: true, TaggedFields: map[string]string{}},
+					{PartitionIndex: 1, PartitionSize: 0, OffsetLag: 0, IsFutureKey: false, TaggedFields: map[string]string{}},
+				}, TaggedFields: map[string]string{}},
+			},
+		}},
+		// The partitions without a log on the broker are left out
+		{"Some partitions", acl.NewAclAuthorizer(nil, true), []DescribableLogDirTopic{{Topic: "foo", Partitions: []int32{1, 2}}}, NONE, [][]DescribeLogDirsTopic{
+			{},
+			{
+				{Name: "foo", Partitions: []DescribeLogDirsPartition{
+					{PartitionIndex: 1, PartitionSize: 0, OffsetLag: 0, IsFutureKey: false, TaggedFields: map[string]string{}},
+				}, TaggedFields: map[string]string{}},
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DescribeLogDirsHandler{logs: logs, authorizer: tt.authorizer}
+			request := DescribeLogDirsRequest{
+				Header: RequestHeader{RequestApiKey: 35, RequestApiVersion: 4, CorrelationId: 7},
+				Topics: tt.topics,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*DescribeLogDirsResponse)
+			if !ok {
+				t.Fatalf("expected *DescribeLogDirsResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if len(gotResp.Results) != len(tt.wantTopics) {
+				t.Fatalf("expected %d log dirs, got %+v", len(tt.wantTopics), gotResp.Results)
+			}
+			for i, result := range gotResp.Results {
+				if result.LogDir != dirs[i] || result.ErrorCode != int16(NONE) || result.TotalBytes <= 0 {
+					t.Errorf("expected %s to be online with its disk usage, got %+v", dirs[i], result)
+				}
+				if !reflect.DeepEqual(result.Topics, append([]DescribeLogDirsTopic{}, tt.wantTopics[i]...)) {
+					t.Errorf("%s: got %+v, want %+v", dirs[i], result.Topics, tt.wantTopics[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDescribeLogDirsResponseSerialize(t *testing.T) {
+	response := &DescribeLogDirsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		Results: []DescribeLogDirsResult{
+			{
+				ErrorCode: 0,
+				LogDir:    "/a",
+				Topics: []DescribeLogDirsTopic{
+					{
+						Name: "foo",
+						Partitions: []DescribeLogDirsPartition{
+							{PartitionIndex: 0, PartitionSize: 61, OffsetLag: 3, IsFutureKey: true, TaggedFields: map[string]string{}},
+						},
+						TaggedFields: map[string]string{},
+					},
+				},
+				TotalBytes:   4096,
+				UsableBytes:  1024,
+				TaggedFields: map[string]string{},
+			},
+			{
+				ErrorCode:    56,
+				LogDir:       "/b",
+				Topics:       []DescribeLogDirsTopic{},
+				TotalBytes:   -1,
+				UsableBytes:  -1,
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := response.Serialize(4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x57, // MessageSize: 87
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x03,       // Results array length: 2
+		0x00, 0x00, // ErrorCode: 0
+		0x03, '/', 'a', // LogDir: "/a"
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3D, // PartitionSize: 61
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, // OffsetLag: 3
+		0x01,                                           // IsFutureKey: true
+		0x00,                                           // Partition tagged fields
+		0x00,                                           // Topic tagged fields
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, // TotalBytes: 4096
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00, // UsableBytes: 1024
+		0x00,       // Result tagged fields
+		0x00, 0x38, // ErrorCode: 56 (KAFKA_STORAGE_ERROR)
+		0x03, '/', 'b', // LogDir: "/b"
+		0x01,                                           // Topics array length: 0
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // TotalBytes: -1
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // UsableBytes: -1
+		0x00, // Result tagged fields
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
@@ -0,0 +1,364 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+type DescribeProducersTopic struct {
+	Name             string
+	PartitionIndexes []int32
+	TaggedFields     map[string]string
+}
+
+type DescribeProducersRequest struct {
+	Header       RequestHeader
+	Topics       []DescribeProducersTopic
+	TaggedFields map[string]string
+}
+
+func (r *DescribeProducersRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeProducersRequest) GetApiKey() KafkaAPIKey {
+	return DescribeProducers
+}
+
+func (r *DescribeProducersRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeProducersRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type ProducerStateResult struct {
+	ProducerId    int64
+	ProducerEpoch int32
+	LastSequence  int32
+	LastTimestamp int64
+	// -1 when no marker of the producer was written
+	CoordinatorEpoch int32
+	// -1 when the producer has no ongoing transaction
+	CurrentTxnStartOffset int64
+	TaggedFields          map[string]string
+}
+
+type DescribeProducersPartitionResult struct {
+	PartitionIndex  int32
+	ErrorCode       int16
+	ErrorMessage    *string
+	ActiveProducers []ProducerStateResult
+	TaggedFields    map[string]string
+}
+
+type DescribeProducersTopicResult struct {
+	Name         string
+	Partitions   []DescribeProducersPartitionResult
+	TaggedFields map[string]string
+}
+
+type DescribeProducersResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Topics        []DescribeProducersTopicResult
+	TaggedFields  map[string]string
+}
+
+func (r *DescribeProducersResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeProducersResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeProducersResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, topic := range r.Topics {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *DescribeProducersResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.Name)
+		for _, partition := range topic.Partitions {
+			bufferSize += 16 + 40*len(partition.ActiveProducers)
+			if partition.ErrorMessage != nil {
+				bufferSize += len(*partition.ErrorMessage)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, partition.ErrorMessage)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(partition.ActiveProducers)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, producer := range partition.ActiveProducers {
+				index, err = serializer.SerializeInt64(buffer, index, producer.ProducerId)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt32(buffer, index, producer.ProducerEpoch)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt32(buffer, index, producer.LastSequence)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt64(buffer, index, producer.LastTimestamp)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt32(buffer, index, producer.CoordinatorEpoch)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt64(buffer, index, producer.CurrentTxnStartOffset)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeTaggedFields(buffer, index, producer.TaggedFields)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// DescribeProducersHandler describes the idempotent and transactional producers of the partitions the broker leads,
+// from the producer state of their logs. It helps finding the producer of a transaction that holds the last stable
+// offset of a partition
+type DescribeProducersHandler struct {
+	// nil when the broker has no partition logs
+	replicas   *replica.Manager
+	authorizer acl.Authorizer
+}
+
+func (h *DescribeProducersHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeProducersRequest{}
+	req.Header = requestHeader
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from DescribeProducers request",
+		}
+	}
+
+	req.Topics = make([]DescribeProducersTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := DescribeProducersTopic{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from DescribeProducers request at index %d", i),
+			}
+		}
+
+		partitionsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partition indexes length from DescribeProducers request",
+			}
+		}
+		index = newIndex
+
+		topic.PartitionIndexes = make([]int32, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			var partitionIndex int32
+			partitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition index from DescribeProducers request",
+				}
+			}
+			topic.PartitionIndexes = append(topic.PartitionIndexes, partitionIndex)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from DescribeProducers request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeProducers request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeProducersHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeProducersRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeProducersHandler received %T instead of *DescribeProducersRequest", req)
+	}
+
+	response := &DescribeProducersResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		Topics:        make([]DescribeProducersTopicResult, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		errorCode := NONE
+		switch {
+		case !session.authorize(h.authorizer, acl.READ, acl.TOPIC, requestTopic.Name):
+			errorCode = TOPIC_AUTHORIZATION_FAILED
+		case h.replicas == nil:
+			errorCode = NOT_LEADER_OR_FOLLOWER
+		}
+
+		topic := DescribeProducersTopicResult{
+			Name:         requestTopic.Name,
+			Partitions:   make([]DescribeProducersPartitionResult, 0, len(requestTopic.PartitionIndexes)),
+			TaggedFields: make(map[string]string),
+		}
+		for _, partitionIndex := range requestTopic.PartitionIndexes {
+			partition := DescribeProducersPartitionResult{
+				PartitionIndex:  partitionIndex,
+				ErrorCode:       int16(errorCode),
+				ActiveProducers: []ProducerStateResult{},
+				TaggedFields:    make(map[string]string),
+			}
+
+			if errorCode == NONE {
+				producers, err := h.replicas.ActiveProducers(storage.TopicPartition{Topic: requestTopic.Name, Partition: partitionIndex})
+				if err != nil {
+					partition.ErrorCode = int16(replicaErrorCode(err))
+				}
+				for _, producer := range producers {
+					partition.ActiveProducers = append(partition.ActiveProducers, ProducerStateResult{
+						ProducerId:            producer.ProducerId,
+						ProducerEpoch:         int32(producer.ProducerEpoch),
+						LastSequence:          producer.LastSequence,
+						LastTimestamp:         producer.LastTimestamp,
+						CoordinatorEpoch:      producer.CoordinatorEpoch,
+						CurrentTxnStartOffset: producer.CurrentTxnFirstOffset,
+						TaggedFields:          make(map[string]string),
+					})
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,169 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestDescribeProducersParseRequestBody(t *testing.T) {
+	handler := DescribeProducersHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x1F, // MessageSize: 31
+		0x00, 0x3D, // RequestApiKey: 61 (DescribeProducers)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x03,                   // PartitionIndexes array length (2 partitions + 1)
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, 0x00, 0x01, // PartitionIndex: 1
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &DescribeProducersRequest{
+		Header:       header,
+		Topics:       []DescribeProducersTopic{{Name: "foo", PartitionIndexes: []int32{0, 1}, TaggedFields: map[string]string{}}},
+		TaggedFields: map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:28], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestDescribeProducersHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	_, loader, configs := newTestConfigs(t, now)
+	_, replicas := newTestLogReplicas(t, loader, configs)
+
+	// Producer 5 has an ongoing transaction in foo-0
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	appended := make(chan map[storage.TopicPartition]replica.AppendResult, 1)
+	replicas.AppendAsCoordinator(time.Second, map[storage.TopicPartition][]byte{foo: storage.NewTransactionalBatch(5, 2, 1_000, []storage.Record{{Value: []byte("a")}})}, func(results map[storage.TopicPartition]replica.AppendResult) {
+		appended <- results
+	})
+	if err := (<-appended)[foo].Err; err != nil {
+		t.Fatal(err)
+	}
+
+	// Anonymous can not read bar
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.TOPIC, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.READ, PermissionType: acl.DENY},
+	})
+
+	topics := []DescribeProducersTopic{{Name: "foo", PartitionIndexes: []int32{0, 1}}, {Name: "bar", PartitionIndexes: []int32{0}}}
+	tests := []struct {
+		name     string
+		replicas *replica.Manager
+		want     map[string][]KafkaErrorCode
+	}{
+		{"No replicas", nil, map[string][]KafkaErrorCode{"foo": {NOT_LEADER_OR_FOLLOWER, NOT_LEADER_OR_FOLLOWER}, "bar": {TOPIC_AUTHORIZATION_FAILED}}},
+		{"Producers described", replicas, map[string][]KafkaErrorCode{"foo": {NONE, UNKNOWN_TOPIC_OR_PARTITION}, "bar": {TOPIC_AUTHORIZATION_FAILED}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &DescribeProducersHandler{replicas: tt.replicas, authorizer: authorizer}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &DescribeProducersRequest{
+				Header: RequestHeader{RequestApiKey: 61, RequestApiVersion: 0, CorrelationId: 7},
+				Topics: topics,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*DescribeProducersResponse)
+			results := map[string][]KafkaErrorCode{}
+			for _, topic := range response.Topics {
+				for _, partition := range topic.Partitions {
+					results[topic.Name] = append(results[topic.Name], KafkaErrorCode(partition.ErrorCode))
+				}
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, results)
+			}
+
+			if tt.replicas == nil {
+				return
+			}
+			want := []ProducerStateResult{{ProducerId: 5, ProducerEpoch: 2, LastSequence: -1, LastTimestamp: 1_000, CoordinatorEpoch: -1, CurrentTxnStartOffset: 0, TaggedFields: map[string]string{}}}
+			if producers := response.Topics[0].Partitions[0].ActiveProducers; !reflect.DeepEqual(producers, want) {
+				t.Errorf("expected the producers %+v of foo-0, got %+v", want, producers)
+			}
+		})
+	}
+}
+
+func TestDescribeProducersResponseSerialize(t *testing.T) {
+	response := &DescribeProducersResponse{
+		CorrelationId: 7,
+		Topics: []DescribeProducersTopicResult{{
+			Name: "foo",
+			Partitions: []DescribeProducersPartitionResult{{
+				PartitionIndex:  0,
+				ActiveProducers: []ProducerStateResult{{ProducerId: 5, ProducerEpoch: 2, LastSequence: 3, LastTimestamp: 1000, CoordinatorEpoch: -1, CurrentTxnStartOffset: 10, TaggedFields: map[string]string{}}},
+				TaggedFields:    map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := response.Serialize(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x00, 0x00, 0x00, 0x3F, // MessageSize: 63
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x02,                   // Partitions array length (1 partition + 1)
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                                           // ErrorMessage: null
+		0x02,                                           // ActiveProducers array length (1 producer + 1)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x00, 0x00, 0x02, // ProducerEpoch: 2
+		0x00, 0x00, 0x00, 0x03, // LastSequence: 3
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8, // LastTimestamp: 1000
+		0xFF, 0xFF, 0xFF, 0xFF, // CoordinatorEpoch: -1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A, // CurrentTxnStartOffset: 10
+		0x00, // Producer tagged fields
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Response tagged fields
+	}
+	if !reflect.DeepEqual(serialized, want) {
+		t.Errorf("expected %x, got %x", want, serialized)
+	}
+}
@@ -0,0 +1,525 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// clusterMetadataTopic is the topic name of the metadata log, its only partition is replicated by the quorum
+const clusterMetadataTopic = "__cluster_metadata"
+
+type DescribeQuorumPartition struct {
+	PartitionIndex int32
+	TaggedFields   map[string]string
+}
+
+type DescribeQuorumTopic struct {
+	TopicName    string
+	Partitions   []DescribeQuorumPartition
+	TaggedFields map[string]string
+}
+
+type DescribeQuorumRequest struct {
+	Header       RequestHeader
+	Topics       []DescribeQuorumTopic
+	TaggedFields map[string]string
+}
+
+func (r *DescribeQuorumRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeQuorumRequest) GetApiKey() KafkaAPIKey {
+	return DescribeQuorum
+}
+
+func (r *DescribeQuorumRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeQuorumRequest) Validate() error {
+	if r.Header.RequestApiVersion != 2 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// QuorumReplicaState is the progress of a voter or an observer, timestamps are -1 when unknown
+type QuorumReplicaState struct {
+	ReplicaId             int32
+	ReplicaDirectoryId    string
+	LogEndOffset          int64
+	LastFetchTimestamp    int64
+	LastCaughtUpTimestamp int64
+	TaggedFields          map[string]string
+}
+
+type DescribeQuorumPartitionData struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	ErrorMessage   *string
+	LeaderId       int32
+	LeaderEpoch    int32
+	HighWatermark  int64
+	CurrentVoters  []QuorumReplicaState
+	Observers      []QuorumReplicaState
+	TaggedFields   map[string]string
+}
+
+type DescribeQuorumTopicData struct {
+	TopicName    string
+	Partitions   []DescribeQuorumPartitionData
+	TaggedFields map[string]string
+}
+
+type QuorumListener struct {
+	Name         string
+	Host         string
+	Port         uint16
+	TaggedFields map[string]string
+}
+
+type QuorumNode struct {
+	NodeId       int32
+	Listeners    []QuorumListener
+	TaggedFields map[string]string
+}
+
+type DescribeQuorumResponse struct {
+	CorrelationId int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	Topics        []DescribeQuorumTopicData
+	Nodes         []QuorumNode
+	TaggedFields  map[string]string
+}
+
+func (r *DescribeQuorumResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeQuorumResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, topic := range r.Topics {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *DescribeQuorumResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.TopicName)
+		for _, partition := range topic.Partitions {
+			bufferSize += 64 + 64*(len(partition.CurrentVoters)+len(partition.Observers))
+			if partition.ErrorMessage != nil {
+				bufferSize += len(*partition.ErrorMessage)
+			}
+		}
+	}
+	for _, node := range r.Nodes {
+		bufferSize += 16
+		for _, listener := range node.Listeners {
+			bufferSize += 16 + len(listener.Name) + len(listener.Host)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, partition.ErrorMessage)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.HighWatermark)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, replicas := range [][]QuorumReplicaState{partition.CurrentVoters, partition.Observers} {
+				index, err = serializeQuorumReplicas(buffer, index, replicas)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Nodes)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range r.Nodes {
+		index, err = serializer.SerializeInt32(buffer, index, node.NodeId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializeQuorumListeners(buffer, index, node.Listeners)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, node.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+func serializeQuorumReplicas(buffer []byte, index int, replicas []QuorumReplicaState) (int, error) {
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(replicas)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, replica := range replicas {
+		index, err = serializer.SerializeInt32(buffer, index, replica.ReplicaId)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeUUID(buffer, index, replica.ReplicaDirectoryId)
+		if err != nil {
+			return index, err
+		}
+
+		for _, value := range []int64{replica.LogEndOffset, replica.LastFetchTimestamp, replica.LastCaughtUpTimestamp} {
+			index, err = serializer.SerializeInt64(buffer, index, value)
+			if err != nil {
+				return index, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, replica.TaggedFields)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	return index, nil
+}
+
+// serializeQuorumListeners writes the endpoints of a voter, as DescribeQuorum and AddRaftVoter share them
+func serializeQuorumListeners(buffer []byte, index int, listeners []QuorumListener) (int, error) {
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(listeners)+1))
+	if err != nil {
+		return index, err
+	}
+
+	for _, listener := range listeners {
+		index, err = serializer.SerializeCompactString(buffer, index, listener.Name)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, listener.Host)
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, int16(listener.Port))
+		if err != nil {
+			return index, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, listener.TaggedFields)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	return index, nil
+}
+
+// DescribeQuorumHandler describes the metadata quorum this node is a voter of. Only its leader knows the progress of
+// the replicas, the other nodes answer NOT_LEADER_OR_FOLLOWER, as do brokers that are not controllers
+type DescribeQuorumHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *DescribeQuorumHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeQuorumRequest{}
+	req.Header = requestHeader
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from DescribeQuorum request",
+		}
+	}
+
+	req.Topics = make([]DescribeQuorumTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := DescribeQuorumTopic{}
+
+		topic.TopicName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from DescribeQuorum request at index %d", i),
+			}
+		}
+
+		partitionsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from DescribeQuorum request",
+			}
+		}
+		index = newIndex
+
+		topic.Partitions = make([]DescribeQuorumPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := DescribeQuorumPartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition index from DescribeQuorum request",
+				}
+			}
+
+			partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition tagged fields from DescribeQuorum request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from DescribeQuorum request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeQuorum request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeQuorumHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeQuorumRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeQuorumHandler received %T instead of *DescribeQuorumRequest", req)
+	}
+
+	response := &DescribeQuorumResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Topics:        make([]DescribeQuorumTopicData, 0, len(apiReq.Topics)),
+		Nodes:         []QuorumNode{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.DESCRIBE, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	// Brokers that are not controllers know nothing of the quorum
+	info, err := raft.QuorumInfo{}, raft.ErrNotLeader
+	leader := raft.LeaderAndEpoch{LeaderId: raft.NO_LEADER, Epoch: -1}
+	if h.quorum != nil {
+		info, err = h.quorum.DescribeQuorum(h.now())
+		leader = h.quorum.LeaderAndEpoch()
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := DescribeQuorumTopicData{
+			TopicName:    requestTopic.TopicName,
+			Partitions:   make([]DescribeQuorumPartitionData, 0, len(requestTopic.Partitions)),
+			TaggedFields: make(map[string]string),
+		}
+
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := DescribeQuorumPartitionData{
+				PartitionIndex: requestPartition.PartitionIndex,
+				ErrorCode:      int16(NONE),
+				LeaderId:       leader.LeaderId,
+				LeaderEpoch:    leader.Epoch,
+				HighWatermark:  -1,
+				CurrentVoters:  []QuorumReplicaState{},
+				Observers:      []QuorumReplicaState{},
+				TaggedFields:   make(map[string]string),
+			}
+
+			switch {
+			case requestTopic.TopicName != clusterMetadataTopic || requestPartition.PartitionIndex != 0:
+				partition.ErrorCode = int16(UNKNOWN_TOPIC_OR_PARTITION)
+				partition.LeaderId, partition.LeaderEpoch = raft.NO_LEADER, -1
+			case err != nil:
+				partition.ErrorCode, partition.ErrorMessage = quorumErrorCode(err)
+			default:
+				partition.HighWatermark = info.HighWatermark
+				partition.CurrentVoters = quorumReplicaStates(info.Voters)
+				partition.Observers = quorumReplicaStates(info.Observers)
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	if err == nil {
+		for _, nodeId := range slices.Sorted(maps.Keys(info.Endpoints)) {
+			node := QuorumNode{NodeId: nodeId, Listeners: []QuorumListener{}, TaggedFields: make(map[string]string)}
+			for _, endpoint := range info.Endpoints[nodeId] {
+				node.Listeners = append(node.Listeners, QuorumListener{
+					Name:         endpoint.Name,
+					Host:         endpoint.Host,
+					Port:         endpoint.Port,
+					TaggedFields: make(map[string]string),
+				})
+			}
+			response.Nodes = append(response.Nodes, node)
+		}
+	}
+
+	return response, nil
+}
+
+func quorumReplicaStates(replicas []raft.ReplicaState) []QuorumReplicaState {
+	timestamp := func(t time.Time) int64 {
+		if t.IsZero() {
+			return -1
+		}
+		return t.UnixMilli()
+	}
+
+	states := make([]QuorumReplicaState, 0, len(replicas))
+	for _, replica := range replicas {
+		directoryId := replica.DirectoryId
+		if directoryId == "" {
+			directoryId = zeroUuid
+		}
+
+		states = append(states, QuorumReplicaState{
+			ReplicaId:             replica.ReplicaId,
+			ReplicaDirectoryId:    directoryId,
+			LogEndOffset:          replica.LogEndOffset,
+			LastFetchTimestamp:    timestamp(replica.LastFetchTime),
+			LastCaughtUpTimestamp: timestamp(replica.LastCaughtUpTime),
+			TaggedFields:          make(map[string]string),
+		})
+	}
+	return states
+}
@@ -0,0 +1,260 @@
+package request
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+// newTestQuorum returns the leader of a quorum of one voter, node 1
+func newTestQuorum(now time.Time) *raft.Node {
+	transport := raft.NewMemoryTransport(func() time.Time { return now })
+	node := raft.NewNode(raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{{Name: "CONTROLLER", Host: "localhost", Port: 9093}}}},
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), metadata.NewLoader(slog.New(slog.DiscardHandler)), now)
+	transport.Register(node)
+	node.Poll(now)
+	return node
+}
+
+func TestDescribeQuorumParseRequestBody(t *testing.T) {
+	handler := DescribeQuorumHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2B, // MessageSize: 43
+		0x00, 0x37, // RequestApiKey: 55 (DescribeQuorum)
+		0x00, 0x02, // RequestApiVersion: 2
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                                                                                           // Topics array length: 1
+		0x13, '_', '_', 'c', 'l', 'u', 's', 't', 'e', 'r', '_', 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', // TopicName: "__cluster_metadata"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 55, RequestApiVersion: 2, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*DescribeQuorumRequest)
+	if !ok {
+		t.Fatalf("expected *DescribeQuorumRequest, got %T", got)
+	}
+
+	want := []DescribeQuorumTopic{
+		{
+			TopicName:    "__cluster_metadata",
+			Partitions:   []DescribeQuorumPartition{{PartitionIndex: 0, TaggedFields: map[string]string{}}},
+			TaggedFields: map[string]string{},
+		},
+	}
+	if !reflect.DeepEqual(gotReq.Topics, want) {
+		t.Errorf("Topics mismatch: got %+v, want %+v", gotReq.Topics, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestDescribeQuorumHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	leader := newTestQuorum(now)
+
+	tests := []struct {
+		name          string
+		quorum        *raft.Node
+		authorizer    acl.Authorizer
+		topic         string
+		wantErrorCode KafkaErrorCode
+		wantPartition KafkaErrorCode
+		wantVoters    []int32
+	}{
+		{"Leader", leader, acl.NewAclAuthorizer(nil, true), "__cluster_metadata", NONE, NONE, []int32{1}},
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), "__cluster_metadata", NONE, NOT_LEADER_OR_FOLLOWER, []int32{}},
+		{"Unknown partition", leader, acl.NewAclAuthorizer(nil, true), "foo", NONE, UNKNOWN_TOPIC_OR_PARTITION, []int32{}},
+		{"Not authorized", leader, denyAllAuthorizer{}, "__cluster_metadata", CLUSTER_AUTHORIZATION_FAILED, NONE, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DescribeQuorumHandler{quorum: tt.quorum, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			request := DescribeQuorumRequest{
+				Header: RequestHeader{RequestApiKey: 55, RequestApiVersion: 2, CorrelationId: 7},
+				Topics: []DescribeQuorumTopic{{TopicName: tt.topic, Partitions: []DescribeQuorumPartition{{PartitionIndex: 0}}}},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*DescribeQuorumResponse)
+			if !ok {
+				t.Fatalf("expected *DescribeQuorumResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if tt.wantVoters == nil {
+				if len(gotResp.Topics) != 0 {
+					t.Errorf("expected no topics, got %+v", gotResp.Topics)
+				}
+				return
+			}
+
+			partition := gotResp.Topics[0].Partitions[0]
+			if partition.ErrorCode != int16(tt.wantPartition) {
+				t.Errorf("partition error code mismatch: got %d, want %d", partition.ErrorCode, tt.wantPartition)
+			}
+
+			voters := []int32{}
+			for _, voter := range partition.CurrentVoters {
+				voters = append(voters, voter.ReplicaId)
+			}
+			if !reflect.DeepEqual(voters, tt.wantVoters) {
+				t.Errorf("voters mismatch: got %v, want %v", voters, tt.wantVoters)
+			}
+		})
+	}
+}
+
+func TestDescribeQuorumLeaderReportsItself(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	handler := DescribeQuorumHandler{quorum: newTestQuorum(now), authorizer: acl.NewAclAuthorizer(nil, true), now: func() time.Time { return now }}
+	request := DescribeQuorumRequest{
+		Header: RequestHeader{RequestApiKey: 55, RequestApiVersion: 2, CorrelationId: 7},
+		Topics: []DescribeQuorumTopic{{TopicName: "__cluster_metadata", Partitions: []DescribeQuorumPartition{{PartitionIndex: 0}}}},
+	}
+
+	got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotResp := got.(*DescribeQuorumResponse)
+
+	partition := gotResp.Topics[0].Partitions[0]
+	if partition.LeaderId != 1 || partition.LeaderEpoch != 1 || partition.HighWatermark != 1 {
+		t.Errorf("expected leader 1 in epoch 1 at high watermark 1, got %+v", partition)
+	}
+
+	wantVoter := QuorumReplicaState{
+		ReplicaId:             1,
+		ReplicaDirectoryId:    "00000000-0000-0000-0000-000000000001",
+		LogEndOffset:          1,
+		LastFetchTimestamp:    1_000_000,
+		LastCaughtUpTimestamp: 1_000_000,
+		TaggedFields:          map[string]string{},
+	}
+	if !reflect.DeepEqual(partition.CurrentVoters, []QuorumReplicaState{wantVoter}) {
+		t.Errorf("voters mismatch: got %+v", partition.CurrentVoters)
+	}
+
+	wantNodes := []QuorumNode{{
+		NodeId:       1,
+		Listeners:    []QuorumListener{{Name: "CONTROLLER", Host: "localhost", Port: 9093, TaggedFields: map[string]string{}}},
+		TaggedFields: map[string]string{},
+	}}
+	if !reflect.DeepEqual(gotResp.Nodes, wantNodes) {
+		t.Errorf("nodes mismatch: got %+v", gotResp.Nodes)
+	}
+}
+
+func TestDescribeQuorumResponseSerialize(t *testing.T) {
+	response := DescribeQuorumResponse{
+		CorrelationId: 7,
+		ErrorCode:     0,
+		Topics: []DescribeQuorumTopicData{
+			{
+				TopicName: "m",
+				Partitions: []DescribeQuorumPartitionData{
+					{
+						PartitionIndex: 0,
+						ErrorCode:      0,
+						LeaderId:       1,
+						LeaderEpoch:    2,
+						HighWatermark:  3,
+						CurrentVoters: []QuorumReplicaState{
+							{ReplicaId: 1, ReplicaDirectoryId: "00000000-0000-0000-0000-000000000001", LogEndOffset: 3, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1, TaggedFields: map[string]string{}},
+						},
+						Observers:    []QuorumReplicaState{},
+						TaggedFields: map[string]string{},
+					},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		Nodes: []QuorumNode{
+			{NodeId: 1, Listeners: []QuorumListener{{Name: "C", Host: "h", Port: 9093, TaggedFields: map[string]string{}}}, TaggedFields: map[string]string{}},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x63, // MessageSize: 99
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,       // Response header tagged fields
+		0x00, 0x00, // ErrorCode: 0
+		0x00,      // ErrorMessage: null
+		0x02,      // Topics array length: 1
+		0x02, 'm', // TopicName: "m"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                   // ErrorMessage: null
+		0x00, 0x00, 0x00, 0x01, // LeaderId: 1
+		0x00, 0x00, 0x00, 0x02, // LeaderEpoch: 2
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, // HighWatermark: 3
+		0x02,                   // CurrentVoters array length: 1
+		0x00, 0x00, 0x00, 0x01, // ReplicaId: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // ReplicaDirectoryId
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, // LogEndOffset: 3
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // LastFetchTimestamp: -1
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // LastCaughtUpTimestamp: -1
+		0x00,                   // Voter tagged fields
+		0x01,                   // Observers array length: 0
+		0x00,                   // Partition tagged fields
+		0x00,                   // Topic tagged fields
+		0x02,                   // Nodes array length: 1
+		0x00, 0x00, 0x00, 0x01, // NodeId: 1
+		0x02,      // Listeners array length: 1
+		0x02, 'C', // Name: "C"
+		0x02, 'h', // Host: "h"
+		0x23, 0x85, // Port: 9093
+		0x00, // Listener tagged fields
+		0x00, // Node tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -3,7 +3,11 @@ package request
 import (
 	"encoding/binary"
 	"fmt"
+	"maps"
+	"slices"
 
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
 	"github.com/codecrafters-io/kafka-starter-go/app/parser"
 	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
 )
@@ -52,11 +56,11 @@ type Partition struct {
 	Index                  int32
 	LeaderId               int32
 	LeaderEpoch            int32
-	ReplicaNodes           int32
-	IsrNodes               int32
-	EligibleLeaderReplicas int32
-	LastKnownELR           int32
-	OfflineReplicas        int32
+	ReplicaNodes           []int32
+	IsrNodes               []int32
+	EligibleLeaderReplicas []int32 // nullable
+	LastKnownELR           []int32 // nullable
+	OfflineReplicas        []int32
 	TaggedFields           map[string]string
 }
 
@@ -80,8 +84,32 @@ type DescribeTopicPartitionsResponse struct {
 
 func (r *DescribeTopicPartitionsResponse) GetCorrelationId() int32 { return r.CorrelationId }
 
+func (r *DescribeTopicPartitionsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeTopicPartitionsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, topic := range r.Topics {
+		counts[topic.ErrorCode]++
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
 func (r *DescribeTopicPartitionsResponse) Serialize(apiVersion int16) ([]byte, error) {
-	buffer := make([]byte, 256)
+	bufferSize := 256
+	for _, topic := range r.Topics {
+		bufferSize += 64 + len(topic.Name)
+		for _, partition := range topic.Partitions {
+			bufferSize += 64 + 4*(len(partition.ReplicaNodes)+len(partition.IsrNodes)+len(partition.EligibleLeaderReplicas)+
+				len(partition.LastKnownELR)+len(partition.OfflineReplicas))
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
 	index := 0
 	var err error
 
@@ -157,32 +185,32 @@ func (r *DescribeTopicPartitionsResponse) Serialize(apiVersion int16) ([]byte, e
 				return nil, err
 			}
 
-			index, err = serializer.SerializeInt32(buffer, index, item.ReplicaNodes)
+			index, err = serializeInt32Array(buffer, index, item.ReplicaNodes)
 			if err != nil {
 				return nil, err
 			}
 
-			index, err = serializer.SerializeInt32(buffer, index, item.IsrNodes)
+			index, err = serializeInt32Array(buffer, index, item.IsrNodes)
 			if err != nil {
 				return nil, err
 			}
 
-			index, err = serializer.SerializeInt32(buffer, index, item.EligibleLeaderReplicas)
+			index, err = serializeNullableInt32Array(buffer, index, item.EligibleLeaderReplicas)
 			if err != nil {
 				return nil, err
 			}
 
-			index, err = serializer.SerializeInt32(buffer, index, item.LastKnownELR)
+			index, err = serializeNullableInt32Array(buffer, index, item.LastKnownELR)
 			if err != nil {
 				return nil, err
 			}
 
-			index, err = serializer.SerializeInt32(buffer, index, item.OfflineReplicas)
+			index, err = serializeInt32Array(buffer, index, item.OfflineReplicas)
 			if err != nil {
 				return nil, err
 			}
 
-			index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+			index, err = serializer.SerializeTaggedFields(buffer, index, item.TaggedFields)
 			if err != nil {
 				return nil, err
 			}
@@ -237,25 +265,36 @@ func (r *DescribeTopicPartitionsResponse) Serialize(apiVersion int16) ([]byte, e
 	return buffer[:index], nil
 }
 
-type DescribeTopicPartitionsHandler struct{}
+// serializeNullableInt32Array serializes a compact array of int32, nil as a null array
+func serializeNullableInt32Array(buffer []byte, index int, values []int32) (int, error) {
+	if values == nil {
+		return serializer.SerializeUnsignedVarInt(buffer, index, 0)
+	}
+	return serializeInt32Array(buffer, index, values)
+}
+
+// DescribeTopicPartitionsHandler describes the partitions of topics out of the committed metadata of the broker
+type DescribeTopicPartitionsHandler struct {
+	loader     *metadata.Loader
+	authorizer acl.Authorizer
+}
 
 func (h *DescribeTopicPartitionsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
 	var err error
 	req := &DescribeTopicPartitionsRequest{}
 	req.Header = requestHeader
 
-	arrayLength, index, err := parser.ExtractUnsignedVarInt(buffer, index)
-	if err != nil {
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
 		return nil, &RequestParseError{
 			Code:    INVALID_REQUEST,
 			Message: "Failed to parse topics length from DescribeTopicPartitions request",
 		}
 	}
 
-	topicsLength := arrayLength - 1
 	topics := make([]Topic, 0, topicsLength)
 
-	for i := 0; i < int(topicsLength); i++ {
+	for i := 0; i < topicsLength; i++ {
 		topic := Topic{}
 
 		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
@@ -346,7 +385,7 @@ func (h *DescribeTopicPartitionsHandler) ParseRequestBody(requestHeader RequestH
 	return req, nil
 }
 
-func (h *DescribeTopicPartitionsHandler) Handle(req KafkaRequest) (KafkaResponse, error) {
+func (h *DescribeTopicPartitionsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
 	apiReq, ok := req.(*DescribeTopicPartitionsRequest)
 	if !ok {
 		return nil, fmt.Errorf("DescribeTopicPartitionsHandler received %T instead of *DescribeTopicPartitionsRequest", req)
@@ -364,17 +403,35 @@ func (h *DescribeTopicPartitionsHandler) Handle(req KafkaRequest) (KafkaResponse
 	// }
 
 	var topics []ResponseTopic
+	image := h.loader.Image()
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := ResponseTopic{
+			ErrorCode:                 int16(UNKNOWN_TOPIC_OR_PARTITION),
+			Name:                      requestTopic.Name,
+			Id:                        "00000000-0000-0000-0000-000000000000",
+			IsInternal:                isInternalTopic(requestTopic.Name),
+			Partitions:                []Partition{},
+			TopicAuthorizedOperations: 0,
+			TaggedFields:              requestTopic.TaggedFields,
+		}
+
+		// Clients that may not describe a topic must not learn whether it exists
+		if !session.authorize(h.authorizer, acl.DESCRIBE, acl.TOPIC, requestTopic.Name) {
+			topic.ErrorCode = int16(TOPIC_AUTHORIZATION_FAILED)
+			topics = append(topics, topic)
+			continue
+		}
+		topic.TopicAuthorizedOperations = session.authorizedOperations(h.authorizer, acl.TOPIC, requestTopic.Name)
+
+		if topicImage, ok := image.Topic(requestTopic.Name); ok {
+			topic.ErrorCode = int16(NONE)
+			topic.Id = topicImage.Id
+			topic.Partitions = describePartitions(image, topicImage)
+		}
 
-	topic := ResponseTopic{
-		ErrorCode:                 int16(UNKNOWN_TOPIC_OR_PARTITION),
-		Name:                      apiReq.Topics[0].Name,
-		Id:                        "00000000-0000-0000-0000-000000000000",
-		IsInternal:                false,
-		Partitions:                []Partition{},
-		TopicAuthorizedOperations: 0,
-		TaggedFields:              apiReq.Topics[0].TaggedFields,
+		topics = append(topics, topic)
 	}
-	topics = append(topics, topic)
 
 	response := &DescribeTopicPartitionsResponse{
 		CorrelationId: apiReq.Header.CorrelationId,
@@ -386,3 +443,35 @@ func (h *DescribeTopicPartitionsHandler) Handle(req KafkaRequest) (KafkaResponse
 
 	return response, nil
 }
+
+// describePartitions describes the partitions of a topic in the order of their index. The replicas of brokers that are
+// not registered or fenced are offline
+func describePartitions(image *metadata.Image, topic *metadata.TopicImage) []Partition {
+	partitions := make([]Partition, 0, len(topic.Partitions))
+
+	for _, partitionId := range slices.Sorted(maps.Keys(topic.Partitions)) {
+		partition := topic.Partitions[partitionId]
+
+		offline := []int32{}
+		for _, replica := range partition.Replicas {
+			if broker, ok := image.Broker(replica); !ok || broker.Fenced {
+				offline = append(offline, replica)
+			}
+		}
+
+		partitions = append(partitions, Partition{
+			ErrorCode:              int16(NONE),
+			Index:                  partitionId,
+			LeaderId:               partition.Leader,
+			LeaderEpoch:            partition.LeaderEpoch,
+			ReplicaNodes:           partition.Replicas,
+			IsrNodes:               partition.Isr,
+			EligibleLeaderReplicas: partition.Elr,
+			LastKnownELR:           partition.LastKnownElr,
+			OfflineReplicas:        offline,
+			TaggedFields:           map[string]string{},
+		})
+	}
+
+	return partitions
+}
@@ -1,8 +1,15 @@
 package request
 
 import (
+	"log/slog"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
 )
 
 func TestDescribeTopicPartitionsParseRequestBody(t *testing.T) {
@@ -423,7 +430,7 @@ func TestDescribeTopicPartitionsValidate(t *testing.T) {
 }
 
 func TestDescribeTopicPartitionsHandleRequest(t *testing.T) {
-	handler := DescribeTopicPartitionsHandler{}
+	handler := DescribeTopicPartitionsHandler{loader: metadata.NewLoader(slog.New(slog.DiscardHandler)), authorizer: acl.NewAclAuthorizer(nil, true)}
 
 	tests := []struct {
 		name    string
@@ -461,7 +468,7 @@ func TestDescribeTopicPartitionsHandleRequest(t *testing.T) {
 						Id:                        "00000000-0000-0000-0000-000000000000",
 						IsInternal:                false,
 						Partitions:                []Partition{},
-						TopicAuthorizedOperations: 0,
+						TopicAuthorizedOperations: 3576, // Every topic operation since no ACLs exist
 						TaggedFields:              map[string]string{},
 					},
 				},
@@ -473,7 +480,7 @@ func TestDescribeTopicPartitionsHandleRequest(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := handler.Handle(&tt.request)
+			got, err := handler.Handle(NewSession("127.0.0.1"), &tt.request)
 
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
@@ -555,3 +562,144 @@ func TestDescribeTopicPartitionsHandleRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestDescribeTopicPartitionsHandleRequestWithController(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	// The broker loads the records its controller commits
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+
+	epochs := map[int32]int64{}
+	for _, brokerId := range []int32{1, 2} {
+		epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: brokerId, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+		epochs[brokerId] = epoch
+	}
+	topicId, err := active.CreateTopic("foo", [][]int32{{1, 2}, {2, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	minIsr := "2"
+	if err := active.AlterConfig(config.Resource{Type: config.BROKER, Name: ""}, "min.insync.replicas", &minIsr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Broker 2 stops heartbeating and is fenced, it stays eligible to lead as the ISR shrinks below 2
+	now = now.Add(10 * time.Second)
+	if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: 1, BrokerEpoch: epochs[1], CurrentMetadataOffset: epochs[1]}, now); err != nil {
+		t.Fatal(err)
+	}
+	active.Tick(now)
+	active.Node().Poll(now)
+
+	handler := DescribeTopicPartitionsHandler{loader: loader, authorizer: acl.NewAclAuthorizer(nil, true)}
+	request := DescribeTopicPartitionsRequest{
+		Header: RequestHeader{RequestApiKey: 75, RequestApiVersion: 0, CorrelationId: 7},
+		Topics: []Topic{{Name: "foo"}, {Name: "bar"}},
+	}
+
+	got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gotResp, ok := got.(*DescribeTopicPartitionsResponse)
+	if !ok {
+		t.Fatalf("expected *DescribeTopicPartitionsResponse, got %T", got)
+	}
+
+	if len(gotResp.Topics) != 2 {
+		t.Fatalf("expected 2 topics, got %d", len(gotResp.Topics))
+	}
+	if topic := gotResp.Topics[1]; topic.ErrorCode != int16(UNKNOWN_TOPIC_OR_PARTITION) || len(topic.Partitions) != 0 {
+		t.Errorf("bar must be unknown, got %+v", topic)
+	}
+
+	foo := gotResp.Topics[0]
+	if foo.ErrorCode != int16(NONE) || foo.Id != topicId {
+		t.Errorf("foo must be known with id %s, got error code %d and id %s", topicId, foo.ErrorCode, foo.Id)
+	}
+	want := []Partition{
+		{Index: 0, LeaderId: 1, LeaderEpoch: 0, ReplicaNodes: []int32{1, 2}, IsrNodes: []int32{1}, EligibleLeaderReplicas: []int32{2}, OfflineReplicas: []int32{2}, TaggedFields: map[string]string{}},
+		{Index: 1, LeaderId: 1, LeaderEpoch: 1, ReplicaNodes: []int32{2, 1}, IsrNodes: []int32{1}, EligibleLeaderReplicas: []int32{2}, OfflineReplicas: []int32{2}, TaggedFields: map[string]string{}},
+	}
+	if !reflect.DeepEqual(foo.Partitions, want) {
+		t.Errorf("partitions mismatch: got %+v, want %+v", foo.Partitions, want)
+	}
+}
+
+func TestDescribeTopicPartitionsResponseSerialize(t *testing.T) {
+	response := &DescribeTopicPartitionsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Topics: []ResponseTopic{
+			{
+				ErrorCode:  0,
+				Name:       "foo",
+				Id:         "00000000-0000-0000-0000-000000000001",
+				IsInternal: false,
+				Partitions: []Partition{
+					{
+						ErrorCode:              0,
+						Index:                  0,
+						LeaderId:               1,
+						LeaderEpoch:            2,
+						ReplicaNodes:           []int32{1, 2},
+						IsrNodes:               []int32{1},
+						EligibleLeaderReplicas: []int32{2},
+						LastKnownELR:           nil,
+						OfflineReplicas:        []int32{},
+						TaggedFields:           map[string]string{},
+					},
+				},
+				TopicAuthorizedOperations: 0,
+				TaggedFields:              map[string]string{},
+			},
+		},
+		NextCursor:   nil,
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x4D, // MessageSize: 77
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,       // Topics array length: 1
+		0x00, 0x00, // ErrorCode: 0
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // Id
+		0x00,       // IsInternal: false
+		0x02,       // Partitions array length: 1
+		0x00, 0x00, // ErrorCode: 0
+		0x00, 0x00, 0x00, 0x00, // Index: 0
+		0x00, 0x00, 0x00, 0x01, // LeaderId: 1
+		0x00, 0x00, 0x00, 0x02, // LeaderEpoch: 2
+		0x03,                   // ReplicaNodes array length: 2
+		0x00, 0x00, 0x00, 0x01, // Replica: 1
+		0x00, 0x00, 0x00, 0x02, // Replica: 2
+		0x02,                   // IsrNodes array length: 1
+		0x00, 0x00, 0x00, 0x01, // Isr: 1
+		0x02,                   // EligibleLeaderReplicas array length: 1
+		0x00, 0x00, 0x00, 0x02, // Eligible leader replica: 2
+		0x00,                   // LastKnownELR: null
+		0x01,                   // OfflineReplicas array length: 0
+		0x00,                   // Partition tagged fields
+		0x00, 0x00, 0x00, 0x00, // TopicAuthorizedOperations: 0
+		0x00, // Topic tagged fields
+		0xFF, // NextCursor: null
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
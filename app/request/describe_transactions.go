@@ -0,0 +1,299 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type DescribeTransactionsRequest struct {
+	Header           RequestHeader
+	TransactionalIds []string
+	TaggedFields     map[string]string
+}
+
+func (r *DescribeTransactionsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeTransactionsRequest) GetApiKey() KafkaAPIKey {
+	return DescribeTransactions
+}
+
+func (r *DescribeTransactionsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeTransactionsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type TransactionTopicData struct {
+	Topic        string
+	Partitions   []int32
+	TaggedFields map[string]string
+}
+
+type TransactionStateDescription struct {
+	ErrorCode       int16
+	TransactionalId string
+	// The name Kafka gives to the state, empty on error
+	TransactionState       string
+	TransactionTimeoutMs   int32
+	TransactionStartTimeMs int64
+	ProducerId             int64
+	ProducerEpoch          int16
+	Topics                 []TransactionTopicData
+	TaggedFields           map[string]string
+}
+
+type DescribeTransactionsResponse struct {
+	CorrelationId     int32
+	ThrottleTime      int32
+	TransactionStates []TransactionStateDescription
+	TaggedFields      map[string]string
+}
+
+func (r *DescribeTransactionsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeTransactionsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeTransactionsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, state := range r.TransactionStates {
+		counts[state.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *DescribeTransactionsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, state := range r.TransactionStates {
+		bufferSize += 48 + len(state.TransactionalId) + len(state.TransactionState)
+		for _, topic := range state.Topics {
+			bufferSize += 16 + len(topic.Topic) + 4*len(topic.Partitions)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.TransactionStates)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, state := range r.TransactionStates {
+		index, err = serializer.SerializeInt16(buffer, index, state.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, state.TransactionalId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, state.TransactionState)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, state.TransactionTimeoutMs)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt64(buffer, index, state.TransactionStartTimeMs)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt64(buffer, index, state.ProducerId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, state.ProducerEpoch)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(state.Topics)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topic := range state.Topics {
+			index, err = serializer.SerializeCompactString(buffer, index, topic.Topic)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, partition := range topic.Partitions {
+				index, err = serializer.SerializeInt32(buffer, index, partition)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, state.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// DescribeTransactionsHandler describes the transactions of the transactional ids the coordinator of the broker owns,
+// the clients send it to the coordinator FindCoordinator returned for every transactional id
+type DescribeTransactionsHandler struct {
+	// nil when the broker has no transaction coordinator
+	coordinator *transaction.Coordinator
+	authorizer  acl.Authorizer
+}
+
+func (h *DescribeTransactionsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeTransactionsRequest{}
+	req.Header = requestHeader
+
+	idsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || idsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse transactional ids length from DescribeTransactions request",
+		}
+	}
+
+	req.TransactionalIds = make([]string, 0, idsLength)
+	for i := 0; i < idsLength; i++ {
+		var transactionalId string
+		transactionalId, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse transactional id from DescribeTransactions request at index %d", i),
+			}
+		}
+		req.TransactionalIds = append(req.TransactionalIds, transactionalId)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeTransactions request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeTransactionsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeTransactionsRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeTransactionsHandler received %T instead of *DescribeTransactionsRequest", req)
+	}
+
+	response := &DescribeTransactionsResponse{
+		CorrelationId:     apiReq.Header.CorrelationId,
+		TransactionStates: make([]TransactionStateDescription, 0, len(apiReq.TransactionalIds)),
+		TaggedFields:      make(map[string]string),
+	}
+
+	for _, transactionalId := range apiReq.TransactionalIds {
+		state := TransactionStateDescription{
+			ErrorCode:              int16(NONE),
+			TransactionalId:        transactionalId,
+			TransactionStartTimeMs: -1,
+			ProducerId:             -1,
+			ProducerEpoch:          -1,
+			Topics:                 []TransactionTopicData{},
+			TaggedFields:           make(map[string]string),
+		}
+
+		switch {
+		case !session.authorize(h.authorizer, acl.DESCRIBE, acl.TRANSACTIONAL_ID, transactionalId):
+			state.ErrorCode = int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED)
+		case h.coordinator == nil:
+			state.ErrorCode = int16(COORDINATOR_NOT_AVAILABLE)
+		default:
+			described, err := h.coordinator.DescribeTransaction(transactionalId)
+			if err != nil {
+				state.ErrorCode = transactionErrorCode(err)
+				break
+			}
+
+			state.TransactionState = described.State.String()
+			state.TransactionTimeoutMs = described.TimeoutMs
+			state.TransactionStartTimeMs = described.StartTimestamp
+			state.ProducerId, state.ProducerEpoch = described.ProducerId, described.ProducerEpoch
+			// Like Kafka, the topics the principal can not describe are left out. The partitions are sorted by topic
+			for _, partition := range described.Partitions {
+				if !session.authorize(h.authorizer, acl.DESCRIBE, acl.TOPIC, partition.Topic) {
+					continue
+				}
+				if last := len(state.Topics) - 1; last >= 0 && state.Topics[last].Topic == partition.Topic {
+					state.Topics[last].Partitions = append(state.Topics[last].Partitions, partition.Partition)
+					continue
+				}
+				state.Topics = append(state.Topics, TransactionTopicData{Topic: partition.Topic, Partitions: []int32{partition.Partition}, TaggedFields: make(map[string]string)})
+			}
+		}
+
+		response.TransactionStates = append(response.TransactionStates, state)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,176 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+func TestDescribeTransactionsParseRequestBody(t *testing.T) {
+	handler := DescribeTransactionsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x1C, // MessageSize: 28
+		0x00, 0x41, // RequestApiKey: 65 (DescribeTransactions)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x03,                // TransactionalIds array length (2 ids + 1)
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x04, 'o', 't', 'h', // TransactionalId: "oth"
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &DescribeTransactionsRequest{Header: header, TransactionalIds: []string{"txn", "oth"}, TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:26], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestDescribeTransactionsHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+
+	// The transaction of txn has foo-0 and bar-0
+	session := NewSession("127.0.0.1")
+	initialized, err := (&InitProducerIdHandler{coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &InitProducerIdRequest{
+		Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 1},
+		TransactionalId:      stringPtr("txn"),
+		TransactionTimeoutMs: 60000,
+		ProducerId:           -1,
+		ProducerEpoch:        -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	producerId, producerEpoch := initialized.(*InitProducerIdResponse).ProducerId, initialized.(*InitProducerIdResponse).ProducerEpoch
+	if _, err := (&AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &AddPartitionsToTxnRequest{
+		Header:          RequestHeader{RequestApiKey: 24, RequestApiVersion: 3, CorrelationId: 2},
+		TransactionalId: "txn",
+		ProducerId:      producerId,
+		ProducerEpoch:   producerEpoch,
+		Topics:          []AddPartitionsToTxnTopic{{Name: "bar", Partitions: []int32{0}}, {Name: "foo", Partitions: []int32{0}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anonymous can not describe secret nor the topic bar
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.TRANSACTIONAL_ID, ResourceName: "secret", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.DESCRIBE, PermissionType: acl.DENY},
+		{ResourceType: acl.TOPIC, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.DESCRIBE, PermissionType: acl.DENY},
+	})
+
+	tests := []struct {
+		name        string
+		coordinator *transaction.Coordinator
+		want        map[string]KafkaErrorCode
+	}{
+		{"No coordinator", nil, map[string]KafkaErrorCode{"txn": COORDINATOR_NOT_AVAILABLE, "unknown": COORDINATOR_NOT_AVAILABLE, "secret": TRANSACTIONAL_ID_AUTHORIZATION_FAILED}},
+		{"Transactions described", coordinator, map[string]KafkaErrorCode{"txn": NONE, "unknown": TRANSACTIONAL_ID_NOT_FOUND, "secret": TRANSACTIONAL_ID_AUTHORIZATION_FAILED}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &DescribeTransactionsHandler{coordinator: tt.coordinator, authorizer: authorizer}
+			got, err := handler.Handle(session, &DescribeTransactionsRequest{
+				Header:           RequestHeader{RequestApiKey: 65, RequestApiVersion: 0, CorrelationId: 7},
+				TransactionalIds: []string{"txn", "unknown", "secret"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*DescribeTransactionsResponse)
+			results := map[string]KafkaErrorCode{}
+			for _, state := range response.TransactionStates {
+				results[state.TransactionalId] = KafkaErrorCode(state.ErrorCode)
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, results)
+			}
+
+			if tt.coordinator == nil {
+				return
+			}
+			described := response.TransactionStates[0]
+			if described.TransactionState != "Ongoing" || described.ProducerId != producerId || described.ProducerEpoch != producerEpoch || described.TransactionTimeoutMs != 60000 || described.TransactionStartTimeMs <= 0 {
+				t.Errorf("expected the ongoing transaction of producer %d, got %+v", producerId, described)
+			}
+			want := []TransactionTopicData{{Topic: "foo", Partitions: []int32{0}, TaggedFields: map[string]string{}}}
+			if !reflect.DeepEqual(described.Topics, want) {
+				t.Errorf("expected only the topics anonymous can describe %+v, got %+v", want, described.Topics)
+			}
+		})
+	}
+}
+
+func TestDescribeTransactionsResponseSerialize(t *testing.T) {
+	response := &DescribeTransactionsResponse{
+		CorrelationId: 7,
+		TransactionStates: []TransactionStateDescription{{
+			TransactionalId:        "txn",
+			TransactionState:       "Ongoing",
+			TransactionTimeoutMs:   60000,
+			TransactionStartTimeMs: 1000,
+			ProducerId:             5,
+			ProducerEpoch:          1,
+			Topics:                 []TransactionTopicData{{Topic: "foo", Partitions: []int32{0}, TaggedFields: map[string]string{}}},
+			TaggedFields:           map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := response.Serialize(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x00, 0x00, 0x00, 0x3B, // MessageSize: 59
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,       // TransactionStates array length (1 state + 1)
+		0x00, 0x00, // ErrorCode: 0
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x08, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // TransactionState: "Ongoing"
+		0x00, 0x00, 0xEA, 0x60, // TransactionTimeoutMs: 60000
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8, // TransactionStartTimeMs: 1000
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x01, // ProducerEpoch: 1
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Topic: "foo"
+		0x02,                   // Partitions array length (1 partition + 1)
+		0x00, 0x00, 0x00, 0x00, // Partition: 0
+		0x00, // Topic tagged fields
+		0x00, // State tagged fields
+		0x00, // Response tagged fields
+	}
+	if !reflect.DeepEqual(serialized, want) {
+		t.Errorf("expected %x, got %x", want, serialized)
+	}
+}
@@ -0,0 +1,335 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// SCRAM mechanisms are identified by these codes in the user SCRAM credentials APIs
+var scramMechanismNames = map[int8]string{
+	1: sasl.SCRAM_SHA_256,
+	2: sasl.SCRAM_SHA_512,
+}
+
+var scramMechanismTypes = map[string]int8{
+	sasl.SCRAM_SHA_256: 1,
+	sasl.SCRAM_SHA_512: 2,
+}
+
+type UserName struct {
+	Name         string
+	TaggedFields map[string]string
+}
+
+type DescribeUserScramCredentialsRequest struct {
+	Header RequestHeader
+	// Users is nil to describe every user
+	Users        []UserName
+	TaggedFields map[string]string
+}
+
+func (r *DescribeUserScramCredentialsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *DescribeUserScramCredentialsRequest) GetApiKey() KafkaAPIKey {
+	return DescribeUserScramCredentials
+}
+
+func (r *DescribeUserScramCredentialsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *DescribeUserScramCredentialsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// CredentialInfo only describes how a credential was derived, the salt and keys are never returned
+type CredentialInfo struct {
+	Mechanism    int8
+	Iterations   int32
+	TaggedFields map[string]string
+}
+
+type DescribeUserScramCredentialsResult struct {
+	User            string
+	ErrorCode       int16
+	ErrorMessage    *string
+	CredentialInfos []CredentialInfo
+	TaggedFields    map[string]string
+}
+
+type DescribeUserScramCredentialsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	Results       []DescribeUserScramCredentialsResult
+	TaggedFields  map[string]string
+}
+
+func (r *DescribeUserScramCredentialsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *DescribeUserScramCredentialsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *DescribeUserScramCredentialsResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, result := range r.Results {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *DescribeUserScramCredentialsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+	for _, result := range r.Results {
+		bufferSize += 32 + len(result.User) + 8*len(result.CredentialInfos)
+		if result.ErrorMessage != nil {
+			bufferSize += len(*result.ErrorMessage)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Results)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range r.Results {
+		index, err = serializer.SerializeCompactString(buffer, index, result.User)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, result.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, result.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(result.CredentialInfos)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range result.CredentialInfos {
+			index, err = serializer.SerializeInt8(buffer, index, info.Mechanism)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, info.Iterations)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, info.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, result.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type DescribeUserScramCredentialsHandler struct {
+	credentials *sasl.CredentialStore
+	authorizer  acl.Authorizer
+}
+
+func (h *DescribeUserScramCredentialsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &DescribeUserScramCredentialsRequest{}
+	req.Header = requestHeader
+
+	usersLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse users length from DescribeUserScramCredentials request",
+		}
+	}
+
+	// A length of -1 represents a null array
+	if usersLength >= 0 {
+		req.Users = make([]UserName, 0, usersLength)
+
+		for i := 0; i < usersLength; i++ {
+			user := UserName{}
+
+			user.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse user name from DescribeUserScramCredentials request at index %d", i),
+				}
+			}
+
+			user.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse user tagged fields from DescribeUserScramCredentials request",
+				}
+			}
+
+			req.Users = append(req.Users, user)
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from DescribeUserScramCredentials request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *DescribeUserScramCredentialsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*DescribeUserScramCredentialsRequest)
+	if !ok {
+		return nil, fmt.Errorf("DescribeUserScramCredentialsHandler received %T instead of *DescribeUserScramCredentialsRequest", req)
+	}
+
+	response := &DescribeUserScramCredentialsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Results:       []DescribeUserScramCredentialsResult{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	if err := apiReq.Validate(); err != nil {
+		response.ErrorCode, response.ErrorMessage = configErrorCode(err)
+		return response, nil
+	}
+
+	if !session.authorize(h.authorizer, acl.DESCRIBE, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode, response.ErrorMessage = configErrorCode(&RequestParseError{Code: CLUSTER_AUTHORIZATION_FAILED, Message: "Not authorized to describe the user SCRAM credentials"})
+		return response, nil
+	}
+
+	describeAll := len(apiReq.Users) == 0
+	users := h.credentials.ScramUsers()
+	if !describeAll {
+		users = make([]string, 0, len(apiReq.Users))
+		for _, user := range apiReq.Users {
+			users = append(users, user.Name)
+		}
+	}
+
+	requested := make(map[string]int)
+	for _, user := range users {
+		requested[user]++
+	}
+
+	reported := make(map[string]bool)
+
+	for _, user := range users {
+		// Duplicated users get a single result
+		if reported[user] {
+			continue
+		}
+		reported[user] = true
+
+		result := DescribeUserScramCredentialsResult{
+			User:            user,
+			CredentialInfos: []CredentialInfo{},
+			TaggedFields:    make(map[string]string),
+		}
+
+		credentials := h.credentials.ScramCredentials(user)
+
+		switch {
+		case requested[user] > 1:
+			result.ErrorCode, result.ErrorMessage = configErrorCode(&RequestParseError{Code: DUPLICATE_RESOURCE, Message: "Cannot describe SCRAM credentials for the same user twice in a single request: " + user})
+		case len(credentials) == 0:
+			result.ErrorCode, result.ErrorMessage = configErrorCode(&RequestParseError{Code: RESOURCE_NOT_FOUND, Message: "Attempt to describe a user credential that does not exist: " + user})
+		default:
+			for _, mechanism := range sasl.Mechanisms {
+				credential, ok := credentials[mechanism]
+				if !ok {
+					continue
+				}
+
+				result.CredentialInfos = append(result.CredentialInfos, CredentialInfo{
+					Mechanism:    scramMechanismTypes[mechanism],
+					Iterations:   int32(credential.Iterations),
+					TaggedFields: make(map[string]string),
+				})
+			}
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,250 @@
+package request
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+func TestDescribeUserScramCredentialsParseRequestBody(t *testing.T) {
+	handler := DescribeUserScramCredentialsHandler{}
+
+	tests := []struct {
+		name      string
+		input     []byte
+		wantUsers []UserName
+		wantErr   bool
+	}{
+		{
+			name: "Requested users",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x16, // MessageSize: 22
+				0x00, 0x32, // RequestApiKey: 50 (DescribeUserScramCredentials)
+				0x00, 0x00, // RequestApiVersion: 0
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,                      // Users array length: 1
+				0x04, 'b', 'o', 'b', 0x00, // User: "bob", no tagged fields
+				0x00, // Request tagged fields
+			},
+			wantUsers: []UserName{{Name: "bob", TaggedFields: map[string]string{}}},
+		},
+		{
+			name: "Every user",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x11, // MessageSize: 17
+				0x00, 0x32, // RequestApiKey: 50 (DescribeUserScramCredentials)
+				0x00, 0x00, // RequestApiVersion: 0
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x00, // Users array: null
+				0x00, // Request tagged fields
+			},
+			wantUsers: nil,
+		},
+		{
+			name: "Truncated user",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x12, // MessageSize: 18
+				0x00, 0x32, // RequestApiKey: 50 (DescribeUserScramCredentials)
+				0x00, 0x00, // RequestApiVersion: 0
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x02,      // Users array length: 1
+				0x04, 'b', // User: truncated
+			},
+			wantErr: true,
+		},
+	}
+
+	header := RequestHeader{RequestApiKey: 50, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.ParseRequestBody(header, tt.input, 19)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotReq, ok := got.(*DescribeUserScramCredentialsRequest)
+			if !ok {
+				t.Fatalf("expected *DescribeUserScramCredentialsRequest, got %T", got)
+			}
+
+			if (gotReq.Users == nil) != (tt.wantUsers == nil) || len(gotReq.Users) != len(tt.wantUsers) {
+				t.Fatalf("Users mismatch: got %v, want %v", gotReq.Users, tt.wantUsers)
+			}
+
+			for i, user := range gotReq.Users {
+				if user.Name != tt.wantUsers[i].Name {
+					t.Errorf("Users[%d] mismatch: got %s, want %s", i, user.Name, tt.wantUsers[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDescribeUserScramCredentialsHandleRequest(t *testing.T) {
+	credentials := sasl.NewCredentialStore()
+	for _, mechanism := range []string{sasl.SCRAM_SHA_512, sasl.SCRAM_SHA_256} {
+		credential, err := sasl.NewScramCredential(mechanism, "alice-secret", []byte("salt"), 8192)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials.SetScramCredential("alice", mechanism, credential)
+	}
+
+	credential, err := sasl.NewScramCredential(sasl.SCRAM_SHA_256, "bob-secret", []byte("salt"), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials.SetScramCredential("bob", sasl.SCRAM_SHA_256, credential)
+
+	tests := []struct {
+		name          string
+		authorizer    acl.Authorizer
+		users         []UserName
+		wantErrorCode int16
+		wantResults   []DescribeUserScramCredentialsResult
+	}{
+		{
+			name:       "Every user",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			users:      nil,
+			wantResults: []DescribeUserScramCredentialsResult{
+				{User: "alice", CredentialInfos: []CredentialInfo{{Mechanism: 1, Iterations: 8192}, {Mechanism: 2, Iterations: 8192}}},
+				{User: "bob", CredentialInfos: []CredentialInfo{{Mechanism: 1, Iterations: 4096}}},
+			},
+		},
+		{
+			name:       "Requested users",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			users:      []UserName{{Name: "bob"}, {Name: "carol"}, {Name: "alice"}, {Name: "alice"}},
+			wantResults: []DescribeUserScramCredentialsResult{
+				{User: "bob", CredentialInfos: []CredentialInfo{{Mechanism: 1, Iterations: 4096}}},
+				{User: "carol", ErrorCode: int16(RESOURCE_NOT_FOUND), CredentialInfos: []CredentialInfo{}},
+				{User: "alice", ErrorCode: int16(DUPLICATE_RESOURCE), CredentialInfos: []CredentialInfo{}},
+			},
+		},
+		{
+			name:          "Not authorized",
+			authorizer:    denyAllAuthorizer{},
+			users:         nil,
+			wantErrorCode: int16(CLUSTER_AUTHORIZATION_FAILED),
+			wantResults:   []DescribeUserScramCredentialsResult{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DescribeUserScramCredentialsHandler{credentials: credentials, authorizer: tt.authorizer}
+			request := DescribeUserScramCredentialsRequest{
+				Header: RequestHeader{RequestApiKey: 50, RequestApiVersion: 0, CorrelationId: 7},
+				Users:  tt.users,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*DescribeUserScramCredentialsResponse)
+			if !ok {
+				t.Fatalf("expected *DescribeUserScramCredentialsResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+
+			if len(gotResp.Results) != len(tt.wantResults) {
+				t.Fatalf("Results length mismatch: got %d, want %d", len(gotResp.Results), len(tt.wantResults))
+			}
+
+			for i, result := range gotResp.Results {
+				want := tt.wantResults[i]
+				if result.User != want.User || result.ErrorCode != want.ErrorCode {
+					t.Errorf("Results[%d] mismatch: got %s/%d, want %s/%d", i, result.User, result.ErrorCode, want.User, want.ErrorCode)
+				}
+
+				if len(result.CredentialInfos) != len(want.CredentialInfos) {
+					t.Fatalf("Results[%d] CredentialInfos mismatch: got %v, want %v", i, result.CredentialInfos, want.CredentialInfos)
+				}
+
+				for j, info := range result.CredentialInfos {
+					if info.Mechanism != want.CredentialInfos[j].Mechanism || info.Iterations != want.CredentialInfos[j].Iterations {
+						t.Errorf("Results[%d] CredentialInfos[%d] mismatch: got %v, want %v", i, j, info, want.CredentialInfos[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDescribeUserScramCredentialsResponseSerialize(t *testing.T) {
+	response := DescribeUserScramCredentialsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Results: []DescribeUserScramCredentialsResult{
+			{
+				User:            "bob",
+				ErrorCode:       0,
+				ErrorMessage:    nil,
+				CredentialInfos: []CredentialInfo{{Mechanism: 1, Iterations: 4096, TaggedFields: map[string]string{}}},
+				TaggedFields:    map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x1D, // MessageSize: 29
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                // ErrorMessage: null
+		0x02,                // Results array length: 1
+		0x04, 'b', 'o', 'b', // User: "bob"
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                   // ErrorMessage: null
+		0x02,                   // CredentialInfos array length: 1
+		0x01,                   // Mechanism: 1 (SCRAM-SHA-256)
+		0x00, 0x00, 0x10, 0x00, // Iterations: 4096
+		0x00, // CredentialInfo tagged fields
+		0x00, // Result tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
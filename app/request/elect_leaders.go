@@ -0,0 +1,397 @@
+package request
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type ElectLeadersTopicPartitions struct {
+	Topic        string
+	Partitions   []int32
+	TaggedFields map[string]string
+}
+
+type ElectLeadersRequest struct {
+	Header       RequestHeader
+	ElectionType int8
+	// nil to elect the leaders of every partition
+	TopicPartitions []ElectLeadersTopicPartitions
+	TimeoutMs       int32
+	TaggedFields    map[string]string
+}
+
+func (r *ElectLeadersRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *ElectLeadersRequest) GetApiKey() KafkaAPIKey {
+	return ElectLeaders
+}
+
+func (r *ElectLeadersRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *ElectLeadersRequest) Validate() error {
+	if r.Header.RequestApiVersion != 2 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type ElectLeadersPartitionResult struct {
+	PartitionId  int32
+	ErrorCode    int16
+	ErrorMessage *string
+	TaggedFields map[string]string
+}
+
+type ElectLeadersReplicaElectionResult struct {
+	Topic           string
+	PartitionResult []ElectLeadersPartitionResult
+	TaggedFields    map[string]string
+}
+
+type ElectLeadersResponse struct {
+	CorrelationId          int32
+	ThrottleTime           int32
+	ErrorCode              int16
+	ReplicaElectionResults []ElectLeadersReplicaElectionResult
+	TaggedFields           map[string]string
+}
+
+func (r *ElectLeadersResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *ElectLeadersResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *ElectLeadersResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, result := range r.ReplicaElectionResults {
+		for _, partition := range result.PartitionResult {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *ElectLeadersResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, result := range r.ReplicaElectionResults {
+		bufferSize += 16 + len(result.Topic)
+		for _, partition := range result.PartitionResult {
+			bufferSize += 16
+			if partition.ErrorMessage != nil {
+				bufferSize += len(*partition.ErrorMessage)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.ReplicaElectionResults)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range r.ReplicaElectionResults {
+		index, err = serializer.SerializeCompactString(buffer, index, result.Topic)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(result.PartitionResult)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range result.PartitionResult {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, partition.ErrorMessage)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, result.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// ElectLeadersHandler elects the preferred leaders of partitions or, with an unclean election, leaders for the
+// partitions without one, on the active controller. The response waits up to the timeout of the request for the
+// broker to load the new leaders
+type ElectLeadersHandler struct {
+	controller *controller.Controller
+	// nil to answer without waiting for the new leaders
+	commits    *metadataPurgatory
+	authorizer acl.Authorizer
+}
+
+func (h *ElectLeadersHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &ElectLeadersRequest{}
+	req.Header = requestHeader
+
+	req.ElectionType, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse election type from ElectLeaders request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topic partitions length from ElectLeaders request",
+		}
+	}
+
+	// A null array means that every partition is elected
+	if topicsLength >= 0 {
+		req.TopicPartitions = make([]ElectLeadersTopicPartitions, 0, topicsLength)
+	}
+	for i := 0; i < topicsLength; i++ {
+		topic := ElectLeadersTopicPartitions{}
+
+		topic.Topic, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic from ElectLeaders request at index %d", i),
+			}
+		}
+
+		partitionsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from ElectLeaders request",
+			}
+		}
+		index = newIndex
+
+		topic.Partitions = make([]int32, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			var partition int32
+			partition, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition from ElectLeaders request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from ElectLeaders request",
+			}
+		}
+
+		req.TopicPartitions = append(req.TopicPartitions, topic)
+	}
+
+	req.TimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse timeout from ElectLeaders request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from ElectLeaders request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *ElectLeadersHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *ElectLeadersHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*ElectLeadersRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("ElectLeadersHandler received %T instead of *ElectLeadersRequest", req))
+		return
+	}
+
+	response := &ElectLeadersResponse{
+		CorrelationId:          apiReq.Header.CorrelationId,
+		ErrorCode:              int16(NONE),
+		ReplicaElectionResults: []ElectLeadersReplicaElectionResult{},
+		TaggedFields:           make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		respond(response, nil)
+		return
+	}
+	if h.controller == nil {
+		response.ErrorCode = int16(NOT_CONTROLLER)
+		respond(response, nil)
+		return
+	}
+
+	var partitions []controller.TopicPartition
+	if apiReq.TopicPartitions != nil {
+		partitions = []controller.TopicPartition{}
+		for _, topic := range apiReq.TopicPartitions {
+			for _, partition := range topic.Partitions {
+				partitions = append(partitions, controller.TopicPartition{Topic: topic.Topic, Partition: partition})
+			}
+		}
+	}
+
+	results, offset, err := h.controller.ElectLeaders(controller.ElectionType(apiReq.ElectionType), partitions)
+	if errors.Is(err, controller.ErrInvalidElectionType) {
+		response.ErrorCode = int16(INVALID_REQUEST)
+		respond(response, nil)
+		return
+	}
+	if err != nil {
+		response.ErrorCode, _ = controllerErrorCode(err)
+		respond(response, nil)
+		return
+	}
+
+	// The results are grouped by topic, in the order of the topic names and of the partitions
+	for _, partition := range slices.SortedFunc(maps.Keys(results), compareTopicPartitions) {
+		last := len(response.ReplicaElectionResults) - 1
+		if last < 0 || response.ReplicaElectionResults[last].Topic != partition.Topic {
+			response.ReplicaElectionResults = append(response.ReplicaElectionResults, ElectLeadersReplicaElectionResult{
+				Topic:           partition.Topic,
+				PartitionResult: []ElectLeadersPartitionResult{},
+				TaggedFields:    map[string]string{},
+			})
+			last++
+		}
+
+		result := ElectLeadersPartitionResult{PartitionId: partition.Partition, ErrorCode: int16(NONE), TaggedFields: map[string]string{}}
+		if err := results[partition]; err != nil {
+			result.ErrorCode, result.ErrorMessage = electionErrorCode(err)
+		}
+		response.ReplicaElectionResults[last].PartitionResult = append(response.ReplicaElectionResults[last].PartitionResult, result)
+	}
+
+	if offset < 0 || h.commits == nil {
+		respond(response, nil)
+		return
+	}
+
+	// Like Kafka, the partitions whose new leader was not loaded in time time out
+	h.commits.await(time.Duration(apiReq.TimeoutMs)*time.Millisecond, loadedOffset(offset), func() {
+		respond(response, nil)
+	}, func() {
+		message := "the new leader was not loaded before the timeout"
+		for i := range response.ReplicaElectionResults {
+			for j := range response.ReplicaElectionResults[i].PartitionResult {
+				result := &response.ReplicaElectionResults[i].PartitionResult[j]
+				if result.ErrorCode == int16(NONE) {
+					result.ErrorCode, result.ErrorMessage = int16(REQUEST_TIMED_OUT), &message
+				}
+			}
+		}
+		respond(response, nil)
+	})
+}
+
+func compareTopicPartitions(a, b controller.TopicPartition) int {
+	return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+}
+
+// electionErrorCode maps the result of the election of a partition to its error code and message
+func electionErrorCode(err error) (int16, *string) {
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, controller.ErrElectionNotNeeded):
+		return int16(ELECTION_NOT_NEEDED), &message
+	case errors.Is(err, controller.ErrPreferredLeaderNotAvailable):
+		return int16(PREFERRED_LEADER_NOT_AVAILABLE), &message
+	case errors.Is(err, controller.ErrEligibleLeadersNotAvailable):
+		return int16(ELIGIBLE_LEADERS_NOT_AVAILABLE), &message
+	case errors.Is(err, metadata.ErrUnknownTopic), errors.Is(err, metadata.ErrUnknownPartition):
+		return int16(UNKNOWN_TOPIC_OR_PARTITION), &message
+	default:
+		return controllerErrorCode(err)
+	}
+}
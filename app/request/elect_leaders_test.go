@@ -0,0 +1,320 @@
+package request
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+func TestElectLeadersParseRequestBody(t *testing.T) {
+	handler := ElectLeadersHandler{}
+	header := RequestHeader{RequestApiKey: 43, RequestApiVersion: 2, CorrelationId: 66, ClientId: "test"}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  *ElectLeadersRequest
+	}{
+		{
+			name: "Some partitions",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x24, // MessageSize: 36
+				0x00, 0x2B, // RequestApiKey: 43 (ElectLeaders)
+				0x00, 0x02, // RequestApiVersion: 2
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x00,                // ElectionType: 0 (PREFERRED)
+				0x02,                // TopicPartitions array length: 1
+				0x04, 'f', 'o', 'o', // Topic: "foo"
+				0x03,                   // Partitions array length: 2
+				0x00, 0x00, 0x00, 0x00, // Partition: 0
+				0x00, 0x00, 0x00, 0x01, // Partition: 1
+				0x00,                   // Topic tagged fields
+				0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+				0x00, // Request tagged fields
+			},
+			want: &ElectLeadersRequest{
+				Header:       header,
+				ElectionType: 0,
+				TopicPartitions: []ElectLeadersTopicPartitions{
+					{Topic: "foo", Partitions: []int32{0, 1}, TaggedFields: map[string]string{}},
+				},
+				TimeoutMs:    30000,
+				TaggedFields: map[string]string{},
+			},
+		},
+		{
+			name: "Every partition",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x16, // MessageSize: 22
+				0x00, 0x2B, // RequestApiKey: 43 (ElectLeaders)
+				0x00, 0x02, // RequestApiVersion: 2
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x01,                   // ElectionType: 1 (UNCLEAN)
+				0x00,                   // TopicPartitions: null
+				0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+				0x00, // Request tagged fields
+			},
+			want: &ElectLeadersRequest{
+				Header:          header,
+				ElectionType:    1,
+				TopicPartitions: nil,
+				TimeoutMs:       30000,
+				TaggedFields:    map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.ParseRequestBody(header, tt.input, 19)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("request mismatch: got %+v, want %+v", got, tt.want)
+			}
+
+			if _, err := handler.ParseRequestBody(header, tt.input[:len(tt.input)-3], 19); err == nil {
+				t.Errorf("expected error for a truncated request")
+			}
+		})
+	}
+}
+
+func TestElectLeadersHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active := newTestController(now)
+
+	for _, brokerId := range []int32{1, 2} {
+		epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: brokerId, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {2, 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	somePartitions := []ElectLeadersTopicPartitions{
+		{Topic: "foo", Partitions: []int32{1, 0}},
+		{Topic: "bar", Partitions: []int32{0}},
+	}
+
+	tests := []struct {
+		name            string
+		controller      *controller.Controller
+		authorizer      acl.Authorizer
+		electionType    int8
+		topicPartitions []ElectLeadersTopicPartitions
+		wantErrorCode   KafkaErrorCode
+		wantResults     map[string][]KafkaErrorCode
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), 0, somePartitions, NOT_CONTROLLER, map[string][]KafkaErrorCode{}},
+		{"Not authorized", active, denyAllAuthorizer{}, 0, somePartitions, CLUSTER_AUTHORIZATION_FAILED, map[string][]KafkaErrorCode{}},
+		{"Invalid election type", active, acl.NewAclAuthorizer(nil, true), 2, somePartitions, INVALID_REQUEST, map[string][]KafkaErrorCode{}},
+		{"Preferred", active, acl.NewAclAuthorizer(nil, true), 0, somePartitions, NONE, map[string][]KafkaErrorCode{
+			"bar": {UNKNOWN_TOPIC_OR_PARTITION},
+			"foo": {ELECTION_NOT_NEEDED, ELECTION_NOT_NEEDED},
+		}},
+		{"Unclean", active, acl.NewAclAuthorizer(nil, true), 1, somePartitions, NONE, map[string][]KafkaErrorCode{
+			"bar": {UNKNOWN_TOPIC_OR_PARTITION},
+			"foo": {ELECTION_NOT_NEEDED, ELECTION_NOT_NEEDED},
+		}},
+		// Partitions that did not need an election are left out
+		{"Every partition", active, acl.NewAclAuthorizer(nil, true), 0, nil, NONE, map[string][]KafkaErrorCode{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ElectLeadersHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := ElectLeadersRequest{
+				Header:          RequestHeader{RequestApiKey: 43, RequestApiVersion: 2, CorrelationId: 7},
+				ElectionType:    tt.electionType,
+				TopicPartitions: tt.topicPartitions,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*ElectLeadersResponse)
+			if !ok {
+				t.Fatalf("expected *ElectLeadersResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+
+			gotResults := map[string][]KafkaErrorCode{}
+			for _, result := range gotResp.ReplicaElectionResults {
+				for i, partition := range result.PartitionResult {
+					if partition.PartitionId != int32(i) {
+						t.Errorf("%s: expected partition %d at index %d, got %d", result.Topic, i, i, partition.PartitionId)
+					}
+					gotResults[result.Topic] = append(gotResults[result.Topic], KafkaErrorCode(partition.ErrorCode))
+				}
+			}
+			if !reflect.DeepEqual(gotResults, tt.wantResults) {
+				t.Errorf("results mismatch: got %v, want %v", gotResults, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestElectLeadersWaitsForTheNewLeaders(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+
+	epochs := map[int32]int64{}
+	for _, brokerId := range []int32{1, 2} {
+		epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: brokerId, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+		epochs[brokerId] = epoch
+	}
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	// Both partitions lose their leader once broker 1, the last member of their ISR, is fenced. Broker 2 is only
+	// elected by an unclean election
+	for _, brokerId := range []int32{2, 1} {
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epochs[brokerId], CurrentMetadataOffset: epochs[brokerId], WantFence: true}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: 2, BrokerEpoch: epochs[2], CurrentMetadataOffset: epochs[2]}, now); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	commits := newMetadataPurgatory(loader)
+	defer commits.purgatory.Shutdown()
+	handler := ElectLeadersHandler{controller: active, commits: commits, authorizer: acl.NewAclAuthorizer(nil, true)}
+
+	elect := func(partition int32, timeoutMs int32) <-chan *ElectLeadersResponse {
+		responses := make(chan *ElectLeadersResponse, 1)
+		request := &ElectLeadersRequest{
+			Header:          RequestHeader{RequestApiKey: 43, RequestApiVersion: 2, CorrelationId: 7},
+			ElectionType:    1,
+			TopicPartitions: []ElectLeadersTopicPartitions{{Topic: "foo", Partitions: []int32{partition}}},
+			TimeoutMs:       timeoutMs,
+		}
+		handler.HandleDelayed(NewSession("127.0.0.1"), request, func(response KafkaResponse, err error) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			responses <- response.(*ElectLeadersResponse)
+		})
+		return responses
+	}
+	resultCode := func(response *ElectLeadersResponse) KafkaErrorCode {
+		return KafkaErrorCode(response.ReplicaElectionResults[0].PartitionResult[0].ErrorCode)
+	}
+
+	// The response waits for the broker to load the new leader
+	responses := elect(0, 10_000)
+	select {
+	case response := <-responses:
+		t.Fatalf("expected the response to wait for the commit, got %v", resultCode(response))
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	active.Node().Poll(now)
+	select {
+	case response := <-responses:
+		if code := resultCode(response); code != NONE {
+			t.Errorf("expected NONE, got %v", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the response once the new leader was loaded")
+	}
+	topic, _ := loader.Image().Topic("foo")
+	if leader := topic.Partitions[0].Leader; leader != 2 {
+		t.Errorf("expected broker 2 to lead foo-0, got %d", leader)
+	}
+
+	// The election of a leader that is not loaded before the timeout times out
+	select {
+	case response := <-elect(1, 10):
+		if code := resultCode(response); code != REQUEST_TIMED_OUT {
+			t.Errorf("expected REQUEST_TIMED_OUT, got %v", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the election to time out")
+	}
+}
+
+func TestElectLeadersResponseSerialize(t *testing.T) {
+	message := "leader election not needed"
+	response := &ElectLeadersResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ReplicaElectionResults: []ElectLeadersReplicaElectionResult{
+			{
+				Topic: "foo",
+				PartitionResult: []ElectLeadersPartitionResult{
+					{PartitionId: 0, ErrorCode: 0, ErrorMessage: nil, TaggedFields: map[string]string{}},
+					{PartitionId: 1, ErrorCode: 84, ErrorMessage: &message, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := response.Serialize(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x3D, // MessageSize: 61
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x02,                // ReplicaElectionResults array length: 1
+		0x04, 'f', 'o', 'o', // Topic: "foo"
+		0x03,                   // PartitionResult array length: 2
+		0x00, 0x00, 0x00, 0x00, // PartitionId: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                   // ErrorMessage: null
+		0x00,                   // Partition tagged fields
+		0x00, 0x00, 0x00, 0x01, // PartitionId: 1
+		0x00, 0x54, // ErrorCode: 84 (ELECTION_NOT_NEEDED)
+		0x1B, 'l', 'e', 'a', 'd', 'e', 'r', ' ', 'e', 'l', 'e', 'c', 't', 'i', 'o', 'n', ' ', 'n', 'o', 't', ' ', 'n', 'e', 'e', 'd', 'e', 'd', // ErrorMessage
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
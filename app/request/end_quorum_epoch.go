@@ -0,0 +1,357 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type EndQuorumEpochCandidate struct {
+	CandidateId          int32
+	CandidateDirectoryId string
+	TaggedFields         map[string]string
+}
+
+type EndQuorumEpochPartition struct {
+	PartitionIndex int32
+	LeaderId       int32
+	LeaderEpoch    int32
+	// The voters that should start the next election first, the longest logs first
+	PreferredCandidates []EndQuorumEpochCandidate
+	TaggedFields        map[string]string
+}
+
+type EndQuorumEpochTopic struct {
+	TopicName    string
+	Partitions   []EndQuorumEpochPartition
+	TaggedFields map[string]string
+}
+
+type EndQuorumEpochRequest struct {
+	Header          RequestHeader
+	ClusterId       *string
+	Topics          []EndQuorumEpochTopic
+	LeaderEndpoints []QuorumListener
+	TaggedFields    map[string]string
+}
+
+func (r *EndQuorumEpochRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *EndQuorumEpochRequest) GetApiKey() KafkaAPIKey {
+	return EndQuorumEpoch
+}
+
+func (r *EndQuorumEpochRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *EndQuorumEpochRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the quorum transport sends to a voter
+func (r *EndQuorumEpochRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	if r.ClusterId != nil {
+		bufferSize += len(*r.ClusterId)
+	}
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.TopicName)
+		for _, partition := range topic.Partitions {
+			bufferSize += 24 + 24*len(partition.PreferredCandidates)
+		}
+	}
+	for _, listener := range r.LeaderEndpoints {
+		bufferSize += 16 + len(listener.Name) + len(listener.Host)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(partition.PreferredCandidates)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, candidate := range partition.PreferredCandidates {
+				index, err = serializer.SerializeInt32(buffer, index, candidate.CandidateId)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeUUID(buffer, index, candidate.CandidateDirectoryId)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeTaggedFields(buffer, index, candidate.TaggedFields)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializeQuorumListeners(buffer, index, r.LeaderEndpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// EndQuorumEpochHandler tells the metadata quorum of this controller that its leader resigned
+type EndQuorumEpochHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *EndQuorumEpochHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &EndQuorumEpochRequest{}
+	req.Header = requestHeader
+
+	req.ClusterId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse cluster id from EndQuorumEpoch request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from EndQuorumEpoch request",
+		}
+	}
+
+	req.Topics = make([]EndQuorumEpochTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := EndQuorumEpochTopic{}
+
+		topic.TopicName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from EndQuorumEpoch request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from EndQuorumEpoch request",
+			}
+		}
+
+		topic.Partitions = make([]EndQuorumEpochPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := EndQuorumEpochPartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.LeaderId, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition from EndQuorumEpoch request at index %d", j),
+				}
+			}
+
+			var candidatesLength int
+			candidatesLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+			if err != nil || candidatesLength < 0 {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse preferred candidates length from EndQuorumEpoch request",
+				}
+			}
+
+			partition.PreferredCandidates = make([]EndQuorumEpochCandidate, 0, candidatesLength)
+			for k := 0; k < candidatesLength; k++ {
+				candidate := EndQuorumEpochCandidate{}
+
+				candidate.CandidateId, index, err = parser.ExtractInt32(buffer, index)
+				if err == nil {
+					candidate.CandidateDirectoryId, index, err = parser.ExtractUUID(buffer, index)
+				}
+				if err == nil {
+					candidate.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+				}
+				if err != nil {
+					return nil, &RequestParseError{
+						Code:    INVALID_REQUEST,
+						Message: fmt.Sprintf("Failed to parse preferred candidate from EndQuorumEpoch request at index %d", k),
+					}
+				}
+
+				partition.PreferredCandidates = append(partition.PreferredCandidates, candidate)
+			}
+
+			partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition tagged fields from EndQuorumEpoch request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from EndQuorumEpoch request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.LeaderEndpoints, index, err = parseQuorumListeners(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse leader endpoints from EndQuorumEpoch request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from EndQuorumEpoch request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *EndQuorumEpochHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*EndQuorumEpochRequest)
+	if !ok {
+		return nil, fmt.Errorf("EndQuorumEpochHandler received %T instead of *EndQuorumEpochRequest", req)
+	}
+
+	response := &QuorumEpochResponse{
+		ApiKey:        EndQuorumEpoch,
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Topics:        make([]QuorumTopicResult, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := QuorumTopicResult{TopicName: requestTopic.TopicName, Partitions: []QuorumPartitionResult{}, TaggedFields: map[string]string{}}
+
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := quorumPartitionResult(h.quorum, requestTopic.TopicName, requestPartition.PartitionIndex)
+			if partition.ErrorCode == int16(NONE) {
+				successors := make([]int32, 0, len(requestPartition.PreferredCandidates))
+				for _, candidate := range requestPartition.PreferredCandidates {
+					successors = append(successors, candidate.CandidateId)
+				}
+
+				ended := h.quorum.HandleEndQuorumEpoch(raft.EndQuorumEpochRequest{
+					LeaderEpoch:         requestPartition.LeaderEpoch,
+					LeaderId:            requestPartition.LeaderId,
+					PreferredSuccessors: successors,
+				}, h.now())
+
+				partition.ErrorCode = ended.ErrorCode
+				partition.LeaderId, partition.LeaderEpoch = ended.LeaderId, ended.LeaderEpoch
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,78 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+)
+
+func TestEndQuorumEpochRequestRoundTrip(t *testing.T) {
+	request := &EndQuorumEpochRequest{
+		Header: RequestHeader{RequestApiKey: 54, RequestApiVersion: 1, CorrelationId: 66, ClientId: "test", TaggedFields: map[string]string{}},
+		Topics: []EndQuorumEpochTopic{{
+			TopicName: clusterMetadataTopic,
+			Partitions: []EndQuorumEpochPartition{{
+				PartitionIndex: 0,
+				LeaderId:       1,
+				LeaderEpoch:    3,
+				PreferredCandidates: []EndQuorumEpochCandidate{
+					{CandidateId: 2, CandidateDirectoryId: "00000000-0000-0000-0000-000000000002", TaggedFields: map[string]string{}},
+					{CandidateId: 3, CandidateDirectoryId: zeroUuid, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		LeaderEndpoints: []QuorumListener{{Name: "CONTROLLER", Host: "localhost", Port: 9093, TaggedFields: map[string]string{}}},
+		TaggedFields:    map[string]string{},
+	}
+
+	serialized, err := request.Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header, index, err := ParseRequestHeader(serialized, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request.Header.MessageSize = header.MessageSize
+
+	got, err := (&EndQuorumEpochHandler{}).ParseRequestBody(header, serialized, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, request) {
+		t.Errorf("parsed %+v, want %+v", got, request)
+	}
+}
+
+func TestEndQuorumEpochHandle(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	leader := newTestQuorum(now)
+	handler := &EndQuorumEpochHandler{quorum: leader, authorizer: acl.NewAclAuthorizer(nil, true), now: func() time.Time { return now }}
+
+	// The leader of epoch 1 ignores the resignation of a leader of an older epoch
+	request := &EndQuorumEpochRequest{
+		Header: RequestHeader{RequestApiKey: 54, RequestApiVersion: 1, CorrelationId: 7},
+		Topics: []EndQuorumEpochTopic{{
+			TopicName:  clusterMetadataTopic,
+			Partitions: []EndQuorumEpochPartition{{PartitionIndex: 0, LeaderId: 2, LeaderEpoch: 0}},
+		}},
+	}
+
+	response, err := handler.Handle(NewSession("127.0.0.1"), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := response.(*QuorumEpochResponse)
+	want := []QuorumPartitionResult{{PartitionIndex: 0, ErrorCode: int16(FENCED_LEADER_EPOCH), LeaderId: 1, LeaderEpoch: 1, TaggedFields: map[string]string{}}}
+	if got.ErrorCode != int16(NONE) || len(got.Topics) != 1 || !reflect.DeepEqual(got.Topics[0].Partitions, want) {
+		t.Errorf("expected partitions %+v, got %+v", want, got)
+	}
+	if got := leader.LeaderAndEpoch(); got.LeaderId != 1 || got.Epoch != 1 {
+		t.Errorf("expected node 1 to still lead epoch 1, got %+v", got)
+	}
+}
@@ -0,0 +1,179 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type EndTxnRequest struct {
+	Header          RequestHeader
+	TransactionalId string
+	ProducerId      int64
+	ProducerEpoch   int16
+	// false to abort the transaction
+	Committed    bool
+	TaggedFields map[string]string
+}
+
+func (r *EndTxnRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *EndTxnRequest) GetApiKey() KafkaAPIKey {
+	return EndTxn
+}
+
+func (r *EndTxnRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *EndTxnRequest) Validate() error {
+	if r.Header.RequestApiVersion != 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type EndTxnResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	TaggedFields  map[string]string
+}
+
+func (r *EndTxnResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *EndTxnResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *EndTxnResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *EndTxnResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, 32)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// EndTxnHandler commits or aborts the transaction of a producer on the coordinator of its transactional id. The
+// response is sent once the decision is written to the state log, before the markers reach the partitions
+type EndTxnHandler struct {
+	// nil without partition logs
+	coordinator *transaction.Coordinator
+	authorizer  acl.Authorizer
+}
+
+func (h *EndTxnHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &EndTxnRequest{}
+	req.Header = requestHeader
+
+	req.TransactionalId, index, err = parser.ExtractCompactString(buffer, index)
+	if err == nil {
+		req.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+	}
+	if err == nil {
+		req.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse producer from EndTxn request",
+		}
+	}
+
+	req.Committed, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse committed from EndTxn request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from EndTxn request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *EndTxnHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *EndTxnHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*EndTxnRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("EndTxnHandler received %T instead of *EndTxnRequest", req))
+		return
+	}
+
+	response := &EndTxnResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		TaggedFields:  make(map[string]string),
+	}
+
+	switch {
+	case !session.authorize(h.authorizer, acl.WRITE, acl.TRANSACTIONAL_ID, apiReq.TransactionalId):
+		response.ErrorCode = int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED)
+	case h.coordinator == nil:
+		response.ErrorCode = int16(COORDINATOR_NOT_AVAILABLE)
+	default:
+		h.coordinator.EndTransaction(apiReq.TransactionalId, apiReq.ProducerId, apiReq.ProducerEpoch, apiReq.Committed, func(err error) {
+			if err != nil {
+				response.ErrorCode = transactionErrorCode(err)
+			}
+			respond(response, nil)
+		})
+		return
+	}
+
+	respond(response, nil)
+}
@@ -0,0 +1,159 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestEndTxnParseRequestBody(t *testing.T) {
+	handler := EndTxnHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x1F, // MessageSize: 31
+		0x00, 0x1A, // RequestApiKey: 26 (EndTxn)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x01, // ProducerEpoch: 1
+		0x01, // Committed: true
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &EndTxnRequest{Header: header, TransactionalId: "txn", ProducerId: 5, ProducerEpoch: 1, Committed: true, TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:33], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestEndTxnCommitsTransaction(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+	if _, err := active.CreateTopic(CONSUMER_OFFSETS_TOPIC, [][]int32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	session := NewSession("127.0.0.1")
+	handle := func(handler RequestHandler, req KafkaRequest) KafkaResponse {
+		t.Helper()
+
+		response, err := handler.Handle(session, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return response
+	}
+
+	initialized := handle(&InitProducerIdHandler{coordinator: coordinator, authorizer: authorizer}, &InitProducerIdRequest{
+		Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 1},
+		TransactionalId:      stringPtr("txn"),
+		TransactionTimeoutMs: 60000,
+		ProducerId:           -1,
+		ProducerEpoch:        -1,
+	}).(*InitProducerIdResponse)
+	producerId, producerEpoch := initialized.ProducerId, initialized.ProducerEpoch
+
+	added := handle(&AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: authorizer}, &AddPartitionsToTxnRequest{
+		Header:          RequestHeader{RequestApiKey: 24, RequestApiVersion: 3, CorrelationId: 2},
+		TransactionalId: "txn",
+		ProducerId:      producerId,
+		ProducerEpoch:   producerEpoch,
+		Topics:          []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0}}},
+	}).(*AddPartitionsToTxnResponse)
+	if added.Results[0].Results[0].ErrorCode != int16(NONE) {
+		t.Fatalf("expected foo-0 to be added, got %+v", added.Results)
+	}
+
+	addedOffsets := handle(&AddOffsetsToTxnHandler{configs: configs, loader: loader, coordinator: coordinator, authorizer: authorizer}, &AddOffsetsToTxnRequest{
+		Header:          RequestHeader{RequestApiKey: 25, RequestApiVersion: 3, CorrelationId: 3},
+		TransactionalId: "txn",
+		ProducerId:      producerId,
+		ProducerEpoch:   producerEpoch,
+		GroupId:         "group",
+	}).(*AddOffsetsToTxnResponse)
+	if addedOffsets.ErrorCode != int16(NONE) {
+		t.Fatalf("expected the offsets of the group to be added, got %+v", addedOffsets)
+	}
+
+	committedOffsets := handle(&TxnOffsetCommitHandler{configs: configs, loader: loader, replicas: replicas, timeout: time.Second, authorizer: authorizer, now: time.Now}, &TxnOffsetCommitRequest{
+		Header:          RequestHeader{RequestApiKey: 28, RequestApiVersion: 3, CorrelationId: 4},
+		TransactionalId: "txn",
+		GroupId:         "group",
+		ProducerId:      producerId,
+		ProducerEpoch:   producerEpoch,
+		GenerationId:    -1,
+		Topics:          []TxnOffsetCommitTopic{{Name: "foo", Partitions: []TxnOffsetCommitPartition{{PartitionIndex: 0, CommittedOffset: 10, CommittedLeaderEpoch: -1}}}},
+	}).(*TxnOffsetCommitResponse)
+	if committedOffsets.Topics[0].Results[0].ErrorCode != int16(NONE) {
+		t.Fatalf("expected the offset of foo-0 to be committed, got %+v", committedOffsets.Topics)
+	}
+
+	tests := []struct {
+		name          string
+		authorizer    acl.Authorizer
+		producerEpoch int16
+		want          KafkaErrorCode
+	}{
+		{"Unauthorized transactional id", denyAllAuthorizer{}, producerEpoch, TRANSACTIONAL_ID_AUTHORIZATION_FAILED},
+		{"Other producer epoch", authorizer, producerEpoch + 1, PRODUCER_FENCED},
+		{"Transaction committed", authorizer, producerEpoch, NONE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ended := handle(&EndTxnHandler{coordinator: coordinator, authorizer: tt.authorizer}, &EndTxnRequest{
+				Header:          RequestHeader{RequestApiKey: 26, RequestApiVersion: 3, CorrelationId: 5},
+				TransactionalId: "txn",
+				ProducerId:      producerId,
+				ProducerEpoch:   tt.producerEpoch,
+				Committed:       true,
+			}).(*EndTxnResponse)
+			if ended.ErrorCode != int16(tt.want) {
+				t.Errorf("expected %s, got %s", KafkaErrorCodeNames[tt.want], KafkaErrorCodeNames[KafkaErrorCode(ended.ErrorCode)])
+			}
+		})
+	}
+
+	// The markers reach foo-0 and the partition of the offsets of the group, which the commit makes stable
+	offsets := storage.TopicPartition{Topic: CONSUMER_OFFSETS_TOPIC, Partition: 0}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fooEndOffset, _ := logs.LogEndOffset(storage.TopicPartition{Topic: "foo", Partition: 0})
+		offsetsEndOffset, _ := logs.LogEndOffset(offsets)
+		lastStableOffset, _ := logs.LastStableOffset(offsets, offsetsEndOffset)
+		if fooEndOffset == 1 && offsetsEndOffset == 2 && lastStableOffset == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the commit markers, got the end offsets %d and %d and a last stable offset of %d", fooEndOffset, offsetsEndOffset, lastStableOffset)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -140,6 +140,7 @@ const (
 )
 
 var KafkaErrorCodeNames = map[KafkaErrorCode]string{
+	UNKNOWN:                               "UNKNOWN_SERVER_ERROR",
 	NONE:                                  "NONE",
 	OFFSET_OUT_OF_RANGE:                   "OFFSET_OUT_OF_RANGE",
 	CORRUPT_MESSAGE:                       "CORRUPT_MESSAGE",
@@ -0,0 +1,1039 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// metadataTopicId is the topic id of the metadata log, the quorum fetches it by id like any other topic
+const metadataTopicId = "00000000-0000-0000-0000-000000000001"
+
+// The tags of the tagged fields of Fetch
+const (
+	fetchReplicaStateTag       = "1"
+	fetchReplicaDirectoryIdTag = "0"
+	fetchDivergingEpochTag     = "0"
+	fetchCurrentLeaderTag      = "1"
+	fetchSnapshotIdTag         = "2"
+)
+
+type FetchPartition struct {
+	Partition          int32
+	CurrentLeaderEpoch int32
+	FetchOffset        int64
+	LastFetchedEpoch   int32
+	LogStartOffset     int64
+	PartitionMaxBytes  int32
+	// Sent in a tagged field by the replicas of the metadata log
+	ReplicaDirectoryId string
+	TaggedFields       map[string]string
+}
+
+type FetchTopic struct {
+	// Versions before 13 name the topic, the later ones send its id
+	Topic        string
+	TopicId      string
+	Partitions   []FetchPartition
+	TaggedFields map[string]string
+}
+
+type FetchForgottenTopic struct {
+	Topic        string
+	TopicId      string
+	Partitions   []int32
+	TaggedFields map[string]string
+}
+
+type FetchRequest struct {
+	Header RequestHeader
+	// From version 15 the replica state is sent in a tagged field by followers, consumers leave the replica id at -1
+	ReplicaId       int32
+	ReplicaEpoch    int64
+	MaxWaitMs       int32
+	MinBytes        int32
+	MaxBytes        int32
+	IsolationLevel  int8
+	SessionId       int32
+	SessionEpoch    int32
+	Topics          []FetchTopic
+	ForgottenTopics []FetchForgottenTopic
+	RackId          string
+	TaggedFields    map[string]string
+}
+
+func (r *FetchRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *FetchRequest) GetApiKey() KafkaAPIKey {
+	return Fetch
+}
+
+func (r *FetchRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+// Validate accepts the flexible versions
+func (r *FetchRequest) Validate() error {
+	if r.Header.RequestApiVersion < 12 || r.Header.RequestApiVersion > 17 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the quorum transport sends to the leader
+func (r *FetchRequest) Serialize() ([]byte, error) {
+	bufferSize := 96 + len(r.Header.ClientId) + len(r.RackId)
+	for _, topic := range r.Topics {
+		bufferSize += 24 + 64*len(topic.Partitions)
+	}
+	for _, topic := range r.ForgottenTopics {
+		bufferSize += 24 + 4*len(topic.Partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range []int32{r.MaxWaitMs, r.MinBytes, r.MaxBytes} {
+		index, err = serializer.SerializeInt32(buffer, index, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeInt8(buffer, index, r.IsolationLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range []int32{r.SessionId, r.SessionEpoch} {
+		index, err = serializer.SerializeInt32(buffer, index, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.Partition)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.CurrentLeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.FetchOffset)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LastFetchedEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.LogStartOffset)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionMaxBytes)
+			if err != nil {
+				return nil, err
+			}
+
+			taggedFields := maps.Clone(partition.TaggedFields)
+			if partition.ReplicaDirectoryId != "" {
+				if taggedFields == nil {
+					taggedFields = map[string]string{}
+				}
+				directoryId := make([]byte, 16)
+				if _, err := serializer.SerializeUUID(directoryId, 0, partition.ReplicaDirectoryId); err != nil {
+					return nil, err
+				}
+				taggedFields[fetchReplicaDirectoryIdTag] = string(directoryId)
+			}
+			index, err = serializer.SerializeTaggedFields(buffer, index, taggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.ForgottenTopics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.ForgottenTopics {
+		index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeCompactString(buffer, index, r.RackId)
+	if err != nil {
+		return nil, err
+	}
+
+	taggedFields := maps.Clone(r.TaggedFields)
+	if taggedFields == nil {
+		taggedFields = map[string]string{}
+	}
+	// ReplicaState: the replica id, the broker epoch and its tagged fields
+	replicaState := binary.BigEndian.AppendUint32(nil, uint32(r.ReplicaId))
+	replicaState = binary.BigEndian.AppendUint64(replicaState, uint64(r.ReplicaEpoch))
+	taggedFields[fetchReplicaStateTag] = string(append(replicaState, 0))
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, taggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type FetchDivergingEpoch struct {
+	Epoch     int32
+	EndOffset int64
+}
+
+type FetchCurrentLeader struct {
+	LeaderId    int32
+	LeaderEpoch int32
+}
+
+type FetchSnapshotId struct {
+	EndOffset int64
+	Epoch     int32
+}
+
+type FetchAbortedTransaction struct {
+	ProducerId  int64
+	FirstOffset int64
+}
+
+type FetchPartitionData struct {
+	PartitionIndex   int32
+	ErrorCode        int16
+	HighWatermark    int64
+	LastStableOffset int64
+	LogStartOffset   int64
+	// The aborted transactions of the records of a READ_COMMITTED fetch, nil for the other fetches
+	AbortedTransactions []FetchAbortedTransaction
+	// The tagged fields the quorum answers with, nil when not set
+	DivergingEpoch       *FetchDivergingEpoch
+	CurrentLeader        *FetchCurrentLeader
+	SnapshotId           *FetchSnapshotId
+	PreferredReadReplica int32
+	Records              []byte
+	TaggedFields         map[string]string
+}
+
+type FetchTopicResponse struct {
+	// Versions before 13 name the topic, the later ones send its id
+	Topic        string
+	TopicId      string
+	Partitions   []FetchPartitionData
+	TaggedFields map[string]string
+}
+
+type FetchResponse struct {
+	CorrelationId  int32
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	SessionId      int32
+	Responses      []FetchTopicResponse
+	TaggedFields   map[string]string
+}
+
+func (r *FetchResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *FetchResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTimeMs = throttleTimeMs }
+
+func (r *FetchResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, topic := range r.Responses {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *FetchResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, topic := range r.Responses {
+		bufferSize += 24 + len(topic.Topic)
+		for _, partition := range topic.Partitions {
+			bufferSize += 96 + 20*len(partition.AbortedTransactions) + len(partition.Records)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTimeMs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.SessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Responses)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Responses {
+		if apiVersion >= 13 {
+			index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+		} else {
+			index, err = serializer.SerializeCompactString(buffer, index, topic.Topic)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, offset := range []int64{partition.HighWatermark, partition.LastStableOffset, partition.LogStartOffset} {
+				index, err = serializer.SerializeInt64(buffer, index, offset)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// AbortedTransactions, null for the fetches that are not READ_COMMITTED
+			abortedLength := uint64(0)
+			if partition.AbortedTransactions != nil {
+				abortedLength = uint64(len(partition.AbortedTransactions) + 1)
+			}
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, abortedLength)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, transaction := range partition.AbortedTransactions {
+				index, err = serializer.SerializeInt64(buffer, index, transaction.ProducerId)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt64(buffer, index, transaction.FirstOffset)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.PreferredReadReplica)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactBytes(buffer, index, partition.Records)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.taggedFields())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// taggedFields adds the diverging epoch, the current leader and the snapshot id to the tagged fields of the partition,
+// each a struct followed by its own empty tagged fields
+func (p *FetchPartitionData) taggedFields() map[string]string {
+	taggedFields := maps.Clone(p.TaggedFields)
+	if taggedFields == nil {
+		taggedFields = map[string]string{}
+	}
+
+	if p.DivergingEpoch != nil {
+		value := binary.BigEndian.AppendUint32(nil, uint32(p.DivergingEpoch.Epoch))
+		value = binary.BigEndian.AppendUint64(value, uint64(p.DivergingEpoch.EndOffset))
+		taggedFields[fetchDivergingEpochTag] = string(append(value, 0))
+	}
+	if p.CurrentLeader != nil {
+		value := binary.BigEndian.AppendUint32(nil, uint32(p.CurrentLeader.LeaderId))
+		value = binary.BigEndian.AppendUint32(value, uint32(p.CurrentLeader.LeaderEpoch))
+		taggedFields[fetchCurrentLeaderTag] = string(append(value, 0))
+	}
+	if p.SnapshotId != nil {
+		value := binary.BigEndian.AppendUint64(nil, uint64(p.SnapshotId.EndOffset))
+		value = binary.BigEndian.AppendUint32(value, uint32(p.SnapshotId.Epoch))
+		taggedFields[fetchSnapshotIdTag] = string(append(value, 0))
+	}
+
+	return taggedFields
+}
+
+// parseFetchResponse reads the response of the leader to a Fetch of the quorum transport
+func parseFetchResponse(buffer []byte) (*FetchResponse, error) {
+	response := &FetchResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTimeMs, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err == nil {
+		response.SessionId, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Fetch response: %w", err)
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, fmt.Errorf("failed to parse responses length from Fetch response")
+	}
+
+	response.Responses = make([]FetchTopicResponse, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := FetchTopicResponse{}
+
+		topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic id from Fetch response: %w", err)
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, fmt.Errorf("failed to parse partitions length from Fetch response")
+		}
+
+		topic.Partitions = make([]FetchPartitionData, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := FetchPartitionData{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+			}
+			if err == nil {
+				partition.HighWatermark, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.LastStableOffset, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.LogStartOffset, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse partition from Fetch response: %w", err)
+			}
+
+			var abortedLength int
+			abortedLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse aborted transactions from Fetch response: %w", err)
+			}
+			if abortedLength >= 0 {
+				partition.AbortedTransactions = make([]FetchAbortedTransaction, 0, abortedLength)
+			}
+			for k := 0; k < abortedLength && err == nil; k++ {
+				transaction := FetchAbortedTransaction{}
+				transaction.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+				if err == nil {
+					transaction.FirstOffset, index, err = parser.ExtractInt64(buffer, index)
+				}
+				if err == nil {
+					_, index, err = parser.ExtractTagFields(buffer, index)
+				}
+				partition.AbortedTransactions = append(partition.AbortedTransactions, transaction)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse aborted transactions from Fetch response: %w", err)
+			}
+
+			partition.PreferredReadReplica, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.Records, index, err = parser.ExtractCompactBytes(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err == nil {
+				err = partition.parseTaggedFields()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse partition from Fetch response: %w", err)
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic tagged fields from Fetch response: %w", err)
+		}
+
+		response.Responses = append(response.Responses, topic)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tagged fields from Fetch response: %w", err)
+	}
+
+	return response, nil
+}
+
+// parseTaggedFields moves the diverging epoch, the current leader and the snapshot id out of the tagged fields
+func (p *FetchPartitionData) parseTaggedFields() error {
+	if value, ok := p.TaggedFields[fetchDivergingEpochTag]; ok {
+		epoch, index, err := parser.ExtractInt32([]byte(value), 0)
+		if err != nil {
+			return err
+		}
+		endOffset, _, err := parser.ExtractInt64([]byte(value), index)
+		if err != nil {
+			return err
+		}
+		p.DivergingEpoch = &FetchDivergingEpoch{Epoch: epoch, EndOffset: endOffset}
+		delete(p.TaggedFields, fetchDivergingEpochTag)
+	}
+
+	if value, ok := p.TaggedFields[fetchCurrentLeaderTag]; ok {
+		leaderId, index, err := parser.ExtractInt32([]byte(value), 0)
+		if err != nil {
+			return err
+		}
+		leaderEpoch, _, err := parser.ExtractInt32([]byte(value), index)
+		if err != nil {
+			return err
+		}
+		p.CurrentLeader = &FetchCurrentLeader{LeaderId: leaderId, LeaderEpoch: leaderEpoch}
+		delete(p.TaggedFields, fetchCurrentLeaderTag)
+	}
+
+	if value, ok := p.TaggedFields[fetchSnapshotIdTag]; ok {
+		endOffset, index, err := parser.ExtractInt64([]byte(value), 0)
+		if err != nil {
+			return err
+		}
+		epoch, _, err := parser.ExtractInt32([]byte(value), index)
+		if err != nil {
+			return err
+		}
+		p.SnapshotId = &FetchSnapshotId{EndOffset: endOffset, Epoch: epoch}
+		delete(p.TaggedFields, fetchSnapshotIdTag)
+	}
+
+	return nil
+}
+
+// FetchHandler serves the fetches of the metadata log by the replicas of the quorum, and the fetches of the other
+// topics by consumers and followers from the replica manager. The response waits in its purgatory while there are
+// not enough records to return
+type FetchHandler struct {
+	quorum     *raft.Node
+	loader     *metadata.Loader
+	replicas   *replica.Manager
+	metrics    *topicMetrics
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *FetchHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &FetchRequest{ReplicaId: -1, ReplicaEpoch: -1}
+	req.Header = requestHeader
+
+	// The body of the versions that are not supported is laid out differently
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if requestHeader.RequestApiVersion < 15 {
+		req.ReplicaId, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err == nil {
+		req.MaxWaitMs, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err == nil {
+		req.MinBytes, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err == nil {
+		req.MaxBytes, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err == nil {
+		req.IsolationLevel, index, err = parser.ExtractInt8(buffer, index)
+	}
+	if err == nil {
+		req.SessionId, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err == nil {
+		req.SessionEpoch, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse Fetch request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from Fetch request",
+		}
+	}
+
+	req.Topics = make([]FetchTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := FetchTopic{}
+
+		if requestHeader.RequestApiVersion >= 13 {
+			topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+		} else {
+			topic.Topic, index, err = parser.ExtractCompactString(buffer, index)
+		}
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic from Fetch request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from Fetch request",
+			}
+		}
+
+		topic.Partitions = make([]FetchPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := FetchPartition{}
+
+			partition.Partition, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.CurrentLeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.FetchOffset, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.LastFetchedEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.LogStartOffset, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.PartitionMaxBytes, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err == nil {
+				partition.ReplicaDirectoryId = zeroUuid
+				if value, ok := partition.TaggedFields[fetchReplicaDirectoryIdTag]; ok {
+					partition.ReplicaDirectoryId, _, err = parser.ExtractUUID([]byte(value), 0)
+					delete(partition.TaggedFields, fetchReplicaDirectoryIdTag)
+				}
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition from Fetch request at index %d", j),
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from Fetch request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	forgottenLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || forgottenLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse forgotten topics length from Fetch request",
+		}
+	}
+
+	req.ForgottenTopics = make([]FetchForgottenTopic, 0, forgottenLength)
+	for i := 0; i < forgottenLength; i++ {
+		topic := FetchForgottenTopic{}
+
+		if requestHeader.RequestApiVersion >= 13 {
+			topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+		} else {
+			topic.Topic, index, err = parser.ExtractCompactString(buffer, index)
+		}
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse forgotten topic from Fetch request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse forgotten partitions length from Fetch request",
+			}
+		}
+
+		topic.Partitions = make([]int32, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			var partition int32
+			partition, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse forgotten partition from Fetch request at index %d", j),
+				}
+			}
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse forgotten topic tagged fields from Fetch request",
+			}
+		}
+
+		req.ForgottenTopics = append(req.ForgottenTopics, topic)
+	}
+
+	req.RackId, index, err = parser.ExtractCompactString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse rack id from Fetch request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from Fetch request",
+		}
+	}
+
+	if value, ok := req.TaggedFields[fetchReplicaStateTag]; ok {
+		var stateIndex int
+		req.ReplicaId, stateIndex, err = parser.ExtractInt32([]byte(value), 0)
+		if err == nil {
+			req.ReplicaEpoch, _, err = parser.ExtractInt64([]byte(value), stateIndex)
+		}
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse replica state from Fetch request",
+			}
+		}
+		delete(req.TaggedFields, fetchReplicaStateTag)
+	}
+
+	return req, nil
+}
+
+func (h *FetchHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *FetchHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*FetchRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("FetchHandler received %T instead of *FetchRequest", req))
+		return
+	}
+
+	response := &FetchResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Responses:     make([]FetchTopicResponse, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	// Replicas fetch every topic, consumers need to be allowed to read it
+	if apiReq.ReplicaId >= 0 && !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		respond(response, nil)
+		return
+	}
+
+	image := h.loader.Image()
+	// The partitions the replica manager fetches, and where their results go in the response
+	fetches := []replica.PartitionFetch{}
+	positions := map[storage.TopicPartition][2]int{}
+
+	for i, requestTopic := range apiReq.Topics {
+		topic := FetchTopicResponse{Topic: requestTopic.Topic, TopicId: requestTopic.TopicId, Partitions: []FetchPartitionData{}, TaggedFields: map[string]string{}}
+		topicImage, known := image.TopicById(requestTopic.TopicId)
+		unknownErrorCode := UNKNOWN_TOPIC_ID
+		if apiReq.Header.RequestApiVersion < 13 {
+			topicImage, known = image.Topic(requestTopic.Topic)
+			unknownErrorCode = UNKNOWN_TOPIC_OR_PARTITION
+		}
+
+		for j, requestPartition := range requestTopic.Partitions {
+			partition := FetchPartitionData{
+				PartitionIndex:       requestPartition.Partition,
+				ErrorCode:            int16(NONE),
+				HighWatermark:        -1,
+				LastStableOffset:     -1,
+				LogStartOffset:       -1,
+				PreferredReadReplica: -1,
+				Records:              []byte{},
+				TaggedFields:         map[string]string{},
+			}
+
+			switch {
+			case requestTopic.TopicId == metadataTopicId && apiReq.ReplicaId >= 0:
+				if err := h.fetchQuorum(apiReq.ReplicaId, requestPartition, &partition); err != nil {
+					respond(nil, err)
+					return
+				}
+			case !known:
+				partition.ErrorCode = int16(unknownErrorCode)
+			case apiReq.ReplicaId < 0 && !session.authorize(h.authorizer, acl.READ, acl.TOPIC, topicImage.Name):
+				partition.ErrorCode = int16(TOPIC_AUTHORIZATION_FAILED)
+			case h.replicas == nil:
+				partition.ErrorCode = int16(NOT_LEADER_OR_FOLLOWER)
+			default:
+				topicPartition := storage.TopicPartition{Topic: topicImage.Name, Partition: requestPartition.Partition}
+				fetches = append(fetches, replica.PartitionFetch{
+					TopicPartition:     topicPartition,
+					TopicId:            topicImage.Id,
+					CurrentLeaderEpoch: requestPartition.CurrentLeaderEpoch,
+					LastFetchedEpoch:   requestPartition.LastFetchedEpoch,
+					FetchOffset:        requestPartition.FetchOffset,
+					MaxBytes:           int(requestPartition.PartitionMaxBytes),
+				})
+				positions[topicPartition] = [2]int{i, j}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Responses = append(response.Responses, topic)
+	}
+
+	if len(fetches) == 0 {
+		respond(response, nil)
+		return
+	}
+
+	h.replicas.FetchMessages(replica.FetchRequest{
+		ReplicaId:      apiReq.ReplicaId,
+		MaxWait:        time.Duration(apiReq.MaxWaitMs) * time.Millisecond,
+		MinBytes:       int(apiReq.MinBytes),
+		MaxBytes:       int(apiReq.MaxBytes),
+		IsolationLevel: apiReq.IsolationLevel,
+		Partitions:     fetches,
+	}, func(results map[storage.TopicPartition]replica.FetchResult) {
+		image := h.loader.Image()
+		for topicPartition, result := range results {
+			position := positions[topicPartition]
+			partition := &response.Responses[position[0]].Partitions[position[1]]
+			partition.ErrorCode = int16(replicaErrorCode(result.Err))
+			partition.HighWatermark, partition.LastStableOffset = result.HighWatermark, result.LastStableOffset
+			partition.LogStartOffset, partition.Records = result.LogStartOffset, result.Records
+			if result.AbortedTransactions != nil {
+				partition.AbortedTransactions = make([]FetchAbortedTransaction, 0, len(result.AbortedTransactions))
+				for _, transaction := range result.AbortedTransactions {
+					partition.AbortedTransactions = append(partition.AbortedTransactions, FetchAbortedTransaction{ProducerId: transaction.ProducerId, FirstOffset: transaction.FirstOffset})
+				}
+			}
+			h.metrics.recordBytesOut(topicPartition.Topic, len(result.Records))
+			if result.DivergingEpoch != nil {
+				partition.DivergingEpoch = &FetchDivergingEpoch{Epoch: result.DivergingEpoch.Epoch, EndOffset: result.DivergingEpoch.EndOffset}
+			}
+
+			// Clients that fetched from a former leader learn the current one
+			if partition.ErrorCode == int16(NOT_LEADER_OR_FOLLOWER) || partition.ErrorCode == int16(FENCED_LEADER_EPOCH) {
+				if topic, ok := image.Topic(topicPartition.Topic); ok {
+					if partitionImage, ok := topic.Partitions[topicPartition.Partition]; ok {
+						partition.CurrentLeader = &FetchCurrentLeader{LeaderId: partitionImage.Leader, LeaderEpoch: partitionImage.LeaderEpoch}
+					}
+				}
+			}
+		}
+
+		respond(response, nil)
+	})
+}
+
+// fetchQuorum answers the fetch of the metadata log by a replica of the quorum
+func (h *FetchHandler) fetchQuorum(replicaId int32, requestPartition FetchPartition, partition *FetchPartitionData) error {
+	switch {
+	case requestPartition.Partition != 0:
+		partition.ErrorCode = int16(UNKNOWN_TOPIC_OR_PARTITION)
+		return nil
+	case h.quorum == nil:
+		partition.ErrorCode = int16(NOT_LEADER_OR_FOLLOWER)
+		return nil
+	}
+
+	fetched := h.quorum.HandleFetch(raft.FetchRequest{
+		ReplicaId:          replicaId,
+		ReplicaDirectoryId: requestPartition.ReplicaDirectoryId,
+		CurrentLeaderEpoch: requestPartition.CurrentLeaderEpoch,
+		FetchOffset:        requestPartition.FetchOffset,
+		LastFetchedEpoch:   requestPartition.LastFetchedEpoch,
+	}, h.now())
+
+	records, err := encodeQuorumEntries(fetched.Entries)
+	if err != nil {
+		return err
+	}
+
+	partition.ErrorCode = fetched.ErrorCode
+	partition.HighWatermark, partition.LastStableOffset = fetched.HighWatermark, fetched.HighWatermark
+	partition.LogStartOffset = h.quorum.LogStartOffset()
+	partition.CurrentLeader = &FetchCurrentLeader{LeaderId: fetched.LeaderId, LeaderEpoch: fetched.LeaderEpoch}
+	partition.Records = records
+	if fetched.DivergingEpoch != nil {
+		partition.DivergingEpoch = &FetchDivergingEpoch{Epoch: fetched.DivergingEpoch.Epoch, EndOffset: fetched.DivergingEpoch.EndOffset}
+	}
+	if fetched.SnapshotId != nil {
+		partition.SnapshotId = &FetchSnapshotId{EndOffset: fetched.SnapshotId.EndOffset, Epoch: fetched.SnapshotId.Epoch}
+	}
+	return nil
+}
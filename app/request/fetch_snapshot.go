@@ -0,0 +1,612 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// The tags of the tagged fields of FetchSnapshot
+const (
+	fetchSnapshotReplicaDirectoryIdTag = "0"
+	fetchSnapshotCurrentLeaderTag      = "0"
+)
+
+type FetchSnapshotPartition struct {
+	Partition          int32
+	CurrentLeaderEpoch int32
+	SnapshotId         FetchSnapshotId
+	// The position in the snapshot to fetch from
+	Position           int64
+	ReplicaDirectoryId string
+	TaggedFields       map[string]string
+}
+
+type FetchSnapshotTopic struct {
+	Name         string
+	Partitions   []FetchSnapshotPartition
+	TaggedFields map[string]string
+}
+
+type FetchSnapshotRequest struct {
+	Header       RequestHeader
+	ReplicaId    int32
+	MaxBytes     int32
+	Topics       []FetchSnapshotTopic
+	TaggedFields map[string]string
+}
+
+func (r *FetchSnapshotRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *FetchSnapshotRequest) GetApiKey() KafkaAPIKey {
+	return FetchSnapshot
+}
+
+func (r *FetchSnapshotRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *FetchSnapshotRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the quorum transport sends to the leader
+func (r *FetchSnapshotRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.Name) + 64*len(topic.Partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ReplicaId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.Partition)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.CurrentLeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeFetchSnapshotId(buffer, index, partition.SnapshotId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.Position)
+			if err != nil {
+				return nil, err
+			}
+
+			taggedFields := maps.Clone(partition.TaggedFields)
+			if partition.ReplicaDirectoryId != "" {
+				if taggedFields == nil {
+					taggedFields = map[string]string{}
+				}
+				directoryId := make([]byte, 16)
+				if _, err := serializer.SerializeUUID(directoryId, 0, partition.ReplicaDirectoryId); err != nil {
+					return nil, err
+				}
+				taggedFields[fetchSnapshotReplicaDirectoryIdTag] = string(directoryId)
+			}
+			index, err = serializer.SerializeTaggedFields(buffer, index, taggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// serializeFetchSnapshotId writes a snapshot id as the struct of FetchSnapshot, with its own tagged fields
+func serializeFetchSnapshotId(buffer []byte, index int, snapshotId FetchSnapshotId) (int, error) {
+	index, err := serializer.SerializeInt64(buffer, index, snapshotId.EndOffset)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, snapshotId.Epoch)
+	if err != nil {
+		return index, err
+	}
+
+	return serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+}
+
+func parseFetchSnapshotId(buffer []byte, index int) (FetchSnapshotId, int, error) {
+	snapshotId := FetchSnapshotId{}
+	var err error
+
+	snapshotId.EndOffset, index, err = parser.ExtractInt64(buffer, index)
+	if err != nil {
+		return snapshotId, index, err
+	}
+
+	snapshotId.Epoch, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return snapshotId, index, err
+	}
+
+	_, index, err = parser.ExtractTagFields(buffer, index)
+	return snapshotId, index, err
+}
+
+type FetchSnapshotPartitionData struct {
+	Index      int32
+	ErrorCode  int16
+	SnapshotId FetchSnapshotId
+	// Sent in a tagged field, nil when not set
+	CurrentLeader    *FetchCurrentLeader
+	Size             int64
+	Position         int64
+	UnalignedRecords []byte
+	TaggedFields     map[string]string
+}
+
+type FetchSnapshotTopicResponse struct {
+	Name         string
+	Partitions   []FetchSnapshotPartitionData
+	TaggedFields map[string]string
+}
+
+type FetchSnapshotResponse struct {
+	CorrelationId  int32
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	Topics         []FetchSnapshotTopicResponse
+	TaggedFields   map[string]string
+}
+
+func (r *FetchSnapshotResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *FetchSnapshotResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTimeMs = throttleTimeMs
+}
+
+func (r *FetchSnapshotResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, topic := range r.Topics {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *FetchSnapshotResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.Name)
+		for _, partition := range topic.Partitions {
+			bufferSize += 64 + len(partition.UnalignedRecords)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTimeMs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.Index)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeFetchSnapshotId(buffer, index, partition.SnapshotId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.Size)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.Position)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactBytes(buffer, index, partition.UnalignedRecords)
+			if err != nil {
+				return nil, err
+			}
+
+			taggedFields := maps.Clone(partition.TaggedFields)
+			if taggedFields == nil {
+				taggedFields = map[string]string{}
+			}
+			if partition.CurrentLeader != nil {
+				value := binary.BigEndian.AppendUint32(nil, uint32(partition.CurrentLeader.LeaderId))
+				value = binary.BigEndian.AppendUint32(value, uint32(partition.CurrentLeader.LeaderEpoch))
+				taggedFields[fetchSnapshotCurrentLeaderTag] = string(append(value, 0))
+			}
+			index, err = serializer.SerializeTaggedFields(buffer, index, taggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseFetchSnapshotResponse reads the response of the leader to a FetchSnapshot of the quorum transport
+func parseFetchSnapshotResponse(buffer []byte) (*FetchSnapshotResponse, error) {
+	response := &FetchSnapshotResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTimeMs, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FetchSnapshot response: %w", err)
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, fmt.Errorf("failed to parse topics length from FetchSnapshot response")
+	}
+
+	response.Topics = make([]FetchSnapshotTopicResponse, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := FetchSnapshotTopicResponse{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic name from FetchSnapshot response: %w", err)
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, fmt.Errorf("failed to parse partitions length from FetchSnapshot response")
+		}
+
+		topic.Partitions = make([]FetchSnapshotPartitionData, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := FetchSnapshotPartitionData{}
+
+			partition.Index, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+			}
+			if err == nil {
+				partition.SnapshotId, index, err = parseFetchSnapshotId(buffer, index)
+			}
+			if err == nil {
+				partition.Size, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.Position, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.UnalignedRecords, index, err = parser.ExtractCompactBytes(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if value, ok := partition.TaggedFields[fetchSnapshotCurrentLeaderTag]; ok && err == nil {
+				leader := FetchCurrentLeader{}
+				var leaderIndex int
+				leader.LeaderId, leaderIndex, err = parser.ExtractInt32([]byte(value), 0)
+				if err == nil {
+					leader.LeaderEpoch, _, err = parser.ExtractInt32([]byte(value), leaderIndex)
+				}
+				partition.CurrentLeader = &leader
+				delete(partition.TaggedFields, fetchSnapshotCurrentLeaderTag)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse partition from FetchSnapshot response: %w", err)
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic tagged fields from FetchSnapshot response: %w", err)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tagged fields from FetchSnapshot response: %w", err)
+	}
+
+	return response, nil
+}
+
+// FetchSnapshotHandler serves the snapshots of the metadata log to the replicas that fell behind its start. A
+// snapshot is sent whole, whatever the position and the maximum size of the request
+type FetchSnapshotHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+}
+
+func (h *FetchSnapshotHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &FetchSnapshotRequest{}
+	req.Header = requestHeader
+
+	req.ReplicaId, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		req.MaxBytes, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse FetchSnapshot request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from FetchSnapshot request",
+		}
+	}
+
+	req.Topics = make([]FetchSnapshotTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := FetchSnapshotTopic{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from FetchSnapshot request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from FetchSnapshot request",
+			}
+		}
+
+		topic.Partitions = make([]FetchSnapshotPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := FetchSnapshotPartition{}
+
+			partition.Partition, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.CurrentLeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.SnapshotId, index, err = parseFetchSnapshotId(buffer, index)
+			}
+			if err == nil {
+				partition.Position, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err == nil {
+				partition.ReplicaDirectoryId = zeroUuid
+				if value, ok := partition.TaggedFields[fetchSnapshotReplicaDirectoryIdTag]; ok {
+					partition.ReplicaDirectoryId, _, err = parser.ExtractUUID([]byte(value), 0)
+					delete(partition.TaggedFields, fetchSnapshotReplicaDirectoryIdTag)
+				}
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition from FetchSnapshot request at index %d", j),
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from FetchSnapshot request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from FetchSnapshot request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *FetchSnapshotHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*FetchSnapshotRequest)
+	if !ok {
+		return nil, fmt.Errorf("FetchSnapshotHandler received %T instead of *FetchSnapshotRequest", req)
+	}
+
+	response := &FetchSnapshotResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Topics:        make([]FetchSnapshotTopicResponse, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := FetchSnapshotTopicResponse{Name: requestTopic.Name, Partitions: []FetchSnapshotPartitionData{}, TaggedFields: map[string]string{}}
+
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := FetchSnapshotPartitionData{
+				Index:            requestPartition.Partition,
+				ErrorCode:        int16(NONE),
+				SnapshotId:       requestPartition.SnapshotId,
+				UnalignedRecords: []byte{},
+				TaggedFields:     map[string]string{},
+			}
+
+			switch {
+			case requestTopic.Name != clusterMetadataTopic || requestPartition.Partition != 0:
+				partition.ErrorCode = int16(UNKNOWN_TOPIC_OR_PARTITION)
+			case h.quorum == nil:
+				partition.ErrorCode = int16(NOT_LEADER_OR_FOLLOWER)
+			default:
+				fetched := h.quorum.HandleFetchSnapshot(raft.FetchSnapshotRequest{
+					ReplicaId:          apiReq.ReplicaId,
+					CurrentLeaderEpoch: requestPartition.CurrentLeaderEpoch,
+					SnapshotId:         raft.SnapshotId{EndOffset: requestPartition.SnapshotId.EndOffset, Epoch: requestPartition.SnapshotId.Epoch},
+				})
+
+				partition.ErrorCode = fetched.ErrorCode
+				partition.CurrentLeader = &FetchCurrentLeader{LeaderId: fetched.LeaderId, LeaderEpoch: fetched.LeaderEpoch}
+				if fetched.Snapshot != nil {
+					records, err := encodeQuorumSnapshot(*fetched.Snapshot)
+					if err != nil {
+						return nil, err
+					}
+					partition.UnalignedRecords = records
+					partition.Size = int64(len(records))
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
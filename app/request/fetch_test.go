@@ -0,0 +1,162 @@
+package request
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+func TestFetchRequestRoundTrip(t *testing.T) {
+	request := &FetchRequest{
+		Header:          RequestHeader{RequestApiKey: 1, RequestApiVersion: 17, CorrelationId: 66, ClientId: "test", TaggedFields: map[string]string{}},
+		ReplicaId:       2,
+		ReplicaEpoch:    -1,
+		MaxWaitMs:       500,
+		MaxBytes:        1024,
+		SessionEpoch:    -1,
+		Topics:          []FetchTopic{{TopicId: metadataTopicId, Partitions: []FetchPartition{{Partition: 0, CurrentLeaderEpoch: 3, FetchOffset: 10, LastFetchedEpoch: 2, LogStartOffset: -1, PartitionMaxBytes: 1024, ReplicaDirectoryId: "00000000-0000-0000-0000-000000000002", TaggedFields: map[string]string{}}}, TaggedFields: map[string]string{}}},
+		ForgottenTopics: []FetchForgottenTopic{{TopicId: zeroUuid, Partitions: []int32{1}, TaggedFields: map[string]string{}}},
+		TaggedFields:    map[string]string{},
+	}
+
+	serialized, err := request.Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header, index, err := ParseRequestHeader(serialized, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request.Header.MessageSize = header.MessageSize
+
+	got, err := (&FetchHandler{}).ParseRequestBody(header, serialized, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, request) {
+		t.Errorf("parsed %+v, want %+v", got, request)
+	}
+}
+
+func TestFetchParseRequestBodyVersion12(t *testing.T) {
+	input := []byte{
+		// Body, which starts after a 19-byte header
+		0xFF, 0xFF, 0xFF, 0xFF, // ReplicaId: -1, before version 15
+		0x00, 0x00, 0x01, 0xF4, // MaxWaitMs: 500
+		0x00, 0x00, 0x00, 0x01, // MinBytes: 1
+		0x00, 0x00, 0x04, 0x00, // MaxBytes: 1024
+		0x00,                   // IsolationLevel: 0
+		0x00, 0x00, 0x00, 0x00, // SessionId: 0
+		0xFF, 0xFF, 0xFF, 0xFF, // SessionEpoch: -1
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Topic: "foo", before version 13
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // Partition: 0
+		0xFF, 0xFF, 0xFF, 0xFF, // CurrentLeaderEpoch: -1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // FetchOffset: 5
+		0xFF, 0xFF, 0xFF, 0xFF, // LastFetchedEpoch: -1
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // LogStartOffset: -1
+		0x00, 0x00, 0x04, 0x00, // PartitionMaxBytes: 1024
+		0x00,                // Partition tagged fields
+		0x00,                // Topic tagged fields
+		0x02,                // ForgottenTopicsData array length: 1
+		0x04, 'b', 'a', 'r', // Topic: "bar"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x01, // Partition: 1
+		0x00, // Forgotten topic tagged fields
+		0x01, // RackId: ""
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 1, RequestApiVersion: 12, CorrelationId: 66, ClientId: "test"}
+	got, err := (&FetchHandler{}).ParseRequestBody(header, append(make([]byte, 19), input...), 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &FetchRequest{
+		Header:          header,
+		ReplicaId:       -1,
+		ReplicaEpoch:    -1,
+		MaxWaitMs:       500,
+		MinBytes:        1,
+		MaxBytes:        1024,
+		SessionEpoch:    -1,
+		Topics:          []FetchTopic{{Topic: "foo", Partitions: []FetchPartition{{Partition: 0, CurrentLeaderEpoch: -1, FetchOffset: 5, LastFetchedEpoch: -1, LogStartOffset: -1, PartitionMaxBytes: 1024, ReplicaDirectoryId: zeroUuid, TaggedFields: map[string]string{}}}, TaggedFields: map[string]string{}}},
+		ForgottenTopics: []FetchForgottenTopic{{Topic: "bar", Partitions: []int32{1}, TaggedFields: map[string]string{}}},
+		TaggedFields:    map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+
+	// Version 11 is not flexible
+	header.RequestApiVersion = 11
+	_, err = (&FetchHandler{}).ParseRequestBody(header, append(make([]byte, 19), input...), 19)
+	if parseErr, ok := err.(*RequestParseError); !ok || parseErr.Code != UNSUPPORTED_VERSION {
+		t.Errorf("expected UNSUPPORTED_VERSION, got %v", err)
+	}
+}
+
+func TestFetchHandle(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	leader := newTestQuorum(now)
+
+	tests := []struct {
+		name       string
+		quorum     *raft.Node
+		authorizer acl.Authorizer
+		replicaId  int32
+		topicId    string
+		wantError  KafkaErrorCode
+		want       KafkaErrorCode
+	}{
+		{"Not authorized", leader, denyAllAuthorizer{}, 2, metadataTopicId, CLUSTER_AUTHORIZATION_FAILED, NONE},
+		{"Consumer of the metadata log", leader, acl.NewAclAuthorizer(nil, true), -1, metadataTopicId, NONE, UNKNOWN_TOPIC_ID},
+		{"Unknown topic id", leader, acl.NewAclAuthorizer(nil, true), 2, zeroUuid, NONE, UNKNOWN_TOPIC_ID},
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), 2, metadataTopicId, NONE, NOT_LEADER_OR_FOLLOWER},
+		{"Observer", leader, acl.NewAclAuthorizer(nil, true), 2, metadataTopicId, NONE, NONE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &FetchHandler{quorum: tt.quorum, loader: metadata.NewLoader(slog.New(slog.DiscardHandler)), authorizer: tt.authorizer, now: func() time.Time { return now }}
+			request := &FetchRequest{
+				Header:    RequestHeader{RequestApiKey: 1, RequestApiVersion: 17, CorrelationId: 7},
+				ReplicaId: tt.replicaId,
+				Topics: []FetchTopic{{
+					TopicId:    tt.topicId,
+					Partitions: []FetchPartition{{Partition: 0, CurrentLeaderEpoch: 1, FetchOffset: 0, LastFetchedEpoch: 0, ReplicaDirectoryId: zeroUuid}},
+				}},
+			}
+
+			response, err := handler.Handle(NewSession("127.0.0.1"), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := response.(*FetchResponse)
+			if got.ErrorCode != int16(tt.wantError) {
+				t.Fatalf("expected error %d, got %d", tt.wantError, got.ErrorCode)
+			}
+			if tt.wantError != NONE {
+				return
+			}
+			if len(got.Responses) != 1 || len(got.Responses[0].Partitions) != 1 {
+				t.Fatalf("expected one partition, got %+v", got.Responses)
+			}
+			partition := got.Responses[0].Partitions[0]
+			if partition.ErrorCode != int16(tt.want) {
+				t.Errorf("expected partition error %d, got %d", tt.want, partition.ErrorCode)
+			}
+			if tt.want == NONE && (partition.CurrentLeader == nil || partition.CurrentLeader.LeaderId != 1) {
+				t.Errorf("expected node 1 as the current leader, got %+v", partition.CurrentLeader)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+// CONSUMER_OFFSETS_TOPIC is the internal topic of the consumer groups, the offsets of the transactions are committed to
+// the partition of their group
+const CONSUMER_OFFSETS_TOPIC = "__consumer_offsets"
+
+// isInternalTopic tells whether a topic is one of the internal topics of the coordinators, which the clients may not
+// produce to
+func isInternalTopic(name string) bool {
+	return name == CONSUMER_OFFSETS_TOPIC || name == transaction.TRANSACTION_STATE_TOPIC
+}
+
+const (
+	GROUP_COORDINATOR       int8 = 0
+	TRANSACTION_COORDINATOR int8 = 1
+)
+
+type FindCoordinatorRequest struct {
+	Header          RequestHeader
+	KeyType         int8
+	CoordinatorKeys []string
+	TaggedFields    map[string]string
+}
+
+func (r *FindCoordinatorRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *FindCoordinatorRequest) GetApiKey() KafkaAPIKey {
+	return FindCoordinator
+}
+
+func (r *FindCoordinatorRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *FindCoordinatorRequest) Validate() error {
+	if r.Header.RequestApiVersion != 4 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type FindCoordinatorCoordinator struct {
+	Key string
+	// -1, "" and -1 when the coordinator is not known
+	NodeId       int32
+	Host         string
+	Port         int32
+	ErrorCode    int16
+	ErrorMessage *string
+	TaggedFields map[string]string
+}
+
+type FindCoordinatorResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Coordinators  []FindCoordinatorCoordinator
+	TaggedFields  map[string]string
+}
+
+func (r *FindCoordinatorResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *FindCoordinatorResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *FindCoordinatorResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{}
+	for _, coordinator := range r.Coordinators {
+		counts[coordinator.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *FindCoordinatorResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, coordinator := range r.Coordinators {
+		bufferSize += 32 + len(coordinator.Key) + len(coordinator.Host)
+		if coordinator.ErrorMessage != nil {
+			bufferSize += len(*coordinator.ErrorMessage)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Coordinators)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, coordinator := range r.Coordinators {
+		index, err = serializer.SerializeCompactString(buffer, index, coordinator.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, coordinator.NodeId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, coordinator.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, coordinator.Port)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, coordinator.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, coordinator.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, coordinator.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// FindCoordinatorHandler returns the coordinators of consumer groups and transactional ids, the leaders of their
+// partition of the internal topic of their coordinator. The internal topic is created on the first request for it, the
+// coordinators are not available until its partitions have a leader
+type FindCoordinatorHandler struct {
+	configs    *config.Store
+	loader     *metadata.Loader
+	controller *controller.Controller
+	// nil on a broker without a quorum
+	channel    *ControllerChannel
+	timeout    time.Duration
+	authorizer acl.Authorizer
+}
+
+func (h *FindCoordinatorHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &FindCoordinatorRequest{}
+	req.Header = requestHeader
+
+	req.KeyType, index, err = parser.ExtractInt8(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse key type from FindCoordinator request",
+		}
+	}
+
+	keysLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || keysLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse coordinator keys length from FindCoordinator request",
+		}
+	}
+
+	req.CoordinatorKeys = make([]string, 0, keysLength)
+	for i := 0; i < keysLength; i++ {
+		var key string
+		key, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse coordinator key from FindCoordinator request at index %d", i),
+			}
+		}
+		req.CoordinatorKeys = append(req.CoordinatorKeys, key)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from FindCoordinator request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *FindCoordinatorHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*FindCoordinatorRequest)
+	if !ok {
+		return nil, fmt.Errorf("FindCoordinatorHandler received %T instead of *FindCoordinatorRequest", req)
+	}
+
+	response := &FindCoordinatorResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Coordinators:  make([]FindCoordinatorCoordinator, 0, len(apiReq.CoordinatorKeys)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	var resourceType acl.ResourceType
+	var internalTopic string
+	switch apiReq.KeyType {
+	case GROUP_COORDINATOR:
+		resourceType, internalTopic = acl.GROUP, CONSUMER_OFFSETS_TOPIC
+	case TRANSACTION_COORDINATOR:
+		resourceType, internalTopic = acl.TRANSACTIONAL_ID, transaction.TRANSACTION_STATE_TOPIC
+	}
+
+	image := h.loader.Image()
+	topic, exists := image.Topic(internalTopic)
+	created := false
+	for _, key := range apiReq.CoordinatorKeys {
+		coordinator := FindCoordinatorCoordinator{Key: key, NodeId: -1, Host: "", Port: -1, TaggedFields: make(map[string]string)}
+
+		var err error
+		switch {
+		case internalTopic == "":
+			err = &RequestParseError{Code: INVALID_REQUEST, Message: fmt.Sprintf("Unknown key type %d", apiReq.KeyType)}
+		case resourceType == acl.GROUP && !session.authorize(h.authorizer, acl.DESCRIBE, acl.GROUP, key):
+			err = &RequestParseError{Code: GROUP_AUTHORIZATION_FAILED, Message: fmt.Sprintf("Not authorized to describe group %s", key)}
+		case resourceType == acl.TRANSACTIONAL_ID && !session.authorize(h.authorizer, acl.DESCRIBE, acl.TRANSACTIONAL_ID, key):
+			err = &RequestParseError{Code: TRANSACTIONAL_ID_AUTHORIZATION_FAILED, Message: fmt.Sprintf("Not authorized to describe transactional id %s", key)}
+		case !exists:
+			// The topic is created once per request, the clients find the coordinator once it is loaded
+			if !created {
+				created = true
+				if createErr := h.createInternalTopic(internalTopic); createErr != nil && !errors.Is(createErr, metadata.ErrTopicExists) {
+					err = &RequestParseError{Code: COORDINATOR_NOT_AVAILABLE, Message: fmt.Sprintf("Failed to create %s: %v", internalTopic, createErr)}
+					break
+				}
+			}
+			err = &RequestParseError{Code: COORDINATOR_NOT_AVAILABLE, Message: fmt.Sprintf("%s is being created", internalTopic)}
+		default:
+			partition := topic.Partitions[transaction.PartitionFor(key, len(topic.Partitions))]
+			endpoint, ok := aliveEndpoint(image, partition.Leader, session.Listener.Name)
+			if !ok {
+				err = &RequestParseError{Code: COORDINATOR_NOT_AVAILABLE, Message: fmt.Sprintf("The partition of %s has no available leader", key)}
+				break
+			}
+			coordinator.NodeId, coordinator.Host, coordinator.Port = partition.Leader, endpoint.Host, int32(endpoint.Port)
+		}
+
+		if parseErr, ok := err.(*RequestParseError); ok {
+			coordinator.ErrorCode, coordinator.ErrorMessage = int16(parseErr.Code), &parseErr.Message
+		}
+		response.Coordinators = append(response.Coordinators, coordinator)
+	}
+
+	return response, nil
+}
+
+// createInternalTopic asks the active controller to create the internal topic of a coordinator, with the number of
+// partitions and the replication factor of its configs. The internal topics are compacted
+func (h *FindCoordinatorHandler) createInternalTopic(name string) error {
+	brokerResource := config.Resource{Type: config.BROKER, Name: ""}
+	topic := controller.NewTopic{Name: name, Configs: map[string]string{"cleanup.policy": "compact"}}
+	if name == transaction.TRANSACTION_STATE_TOPIC {
+		numPartitions, _ := h.configs.Int64(brokerResource, "transaction.state.log.num.partitions")
+		replicationFactor, _ := h.configs.Int64(brokerResource, "transaction.state.log.replication.factor")
+		minIsr, _ := h.configs.Int64(brokerResource, "transaction.state.log.min.isr")
+		topic.NumPartitions, topic.ReplicationFactor = int32(numPartitions), int16(replicationFactor)
+		topic.Configs["min.insync.replicas"] = strconv.FormatInt(minIsr, 10)
+	} else {
+		numPartitions, _ := h.configs.Int64(brokerResource, "offsets.topic.num.partitions")
+		replicationFactor, _ := h.configs.Int64(brokerResource, "offsets.topic.replication.factor")
+		topic.NumPartitions, topic.ReplicationFactor = int32(numPartitions), int16(replicationFactor)
+	}
+
+	switch {
+	case h.channel != nil:
+		return h.channel.CreateTopic(topic, h.timeout)
+	case h.controller != nil:
+		_, _, err := h.controller.CreateTopics(topic, false)
+		return err
+	default:
+		return controller.ErrNotController
+	}
+}
@@ -0,0 +1,126 @@
+package request
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+func TestFindCoordinatorParseRequestBody(t *testing.T) {
+	handler := FindCoordinatorHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x18, // MessageSize: 24
+		0x00, 0x0A, // RequestApiKey: 10 (FindCoordinator)
+		0x00, 0x04, // RequestApiVersion: 4
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x01,                // KeyType: 1 (transaction)
+		0x03,                // CoordinatorKeys array length (2 keys + 1)
+		0x04, 't', 'x', 'n', // Key: "txn"
+		0x02, 'a', // Key: "a"
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 10, RequestApiVersion: 4, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &FindCoordinatorRequest{Header: header, KeyType: 1, CoordinatorKeys: []string{"txn", "a"}, TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:26], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestFindCoordinatorHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+
+	epoch, err := active.RegisterBroker(controller.BrokerRegistration{
+		BrokerId:      1,
+		IncarnationId: "00000000-0000-0000-0000-000000000007",
+		Endpoints:     []metadata.BrokerEndpoint{{Name: "PLAINTEXT", Host: "localhost", Port: 9092}},
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: 1, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	configs := config.NewStore(1, map[string]string{
+		"transaction.state.log.num.partitions":     "3",
+		"transaction.state.log.replication.factor": "1",
+		"transaction.state.log.min.isr":            "1",
+	})
+	session := NewSession("127.0.0.1")
+	session.Listener = config.Listener{Name: "PLAINTEXT"}
+
+	find := func(authorizer acl.Authorizer, keyType int8, key string) FindCoordinatorCoordinator {
+		t.Helper()
+
+		handler := &FindCoordinatorHandler{configs: configs, loader: loader, controller: active, timeout: time.Second, authorizer: authorizer}
+		got, err := handler.Handle(session, &FindCoordinatorRequest{
+			Header:          RequestHeader{RequestApiKey: 10, RequestApiVersion: 4, CorrelationId: 7},
+			KeyType:         keyType,
+			CoordinatorKeys: []string{key},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		response := got.(*FindCoordinatorResponse)
+		if len(response.Coordinators) != 1 || response.Coordinators[0].Key != key {
+			t.Fatalf("expected the coordinator of %s, got %+v", key, response.Coordinators)
+		}
+		return response.Coordinators[0]
+	}
+
+	if got := find(denyAllAuthorizer{}, TRANSACTION_COORDINATOR, "txn"); got.ErrorCode != int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED) {
+		t.Errorf("expected TRANSACTIONAL_ID_AUTHORIZATION_FAILED, got %+v", got)
+	}
+	if got := find(denyAllAuthorizer{}, GROUP_COORDINATOR, "group"); got.ErrorCode != int16(GROUP_AUTHORIZATION_FAILED) {
+		t.Errorf("expected GROUP_AUTHORIZATION_FAILED, got %+v", got)
+	}
+	if got := find(acl.NewAclAuthorizer(nil, true), 5, "txn"); got.ErrorCode != int16(INVALID_REQUEST) {
+		t.Errorf("expected INVALID_REQUEST for an unknown key type, got %+v", got)
+	}
+
+	// The state log is created on the first request, the coordinator is known once it is loaded
+	if got := find(acl.NewAclAuthorizer(nil, true), TRANSACTION_COORDINATOR, "txn"); got.ErrorCode != int16(COORDINATOR_NOT_AVAILABLE) || got.NodeId != -1 {
+		t.Errorf("expected COORDINATOR_NOT_AVAILABLE while the state log is created, got %+v", got)
+	}
+	active.Node().Poll(now)
+
+	topic, ok := loader.Image().Topic("__transaction_state")
+	if !ok || len(topic.Partitions) != 3 {
+		t.Fatalf("expected the state log with 3 partitions, got %+v", topic)
+	}
+	if topicConfigs := loader.Image().Configs(config.Resource{Type: config.TOPIC, Name: "__transaction_state"}); topicConfigs["cleanup.policy"] != "compact" || topicConfigs["min.insync.replicas"] != "1" {
+		t.Errorf("expected a compacted state log with min.insync.replicas 1, got %v", topicConfigs)
+	}
+
+	want := FindCoordinatorCoordinator{Key: "txn", NodeId: 1, Host: "localhost", Port: 9092, TaggedFields: map[string]string{}}
+	if got := find(acl.NewAclAuthorizer(nil, true), TRANSACTION_COORDINATOR, "txn"); !reflect.DeepEqual(got, want) {
+		t.Errorf("coordinator mismatch: got %+v, want %+v", got, want)
+	}
+}
@@ -1,6 +1,11 @@
 package request
 
-import "github.com/codecrafters-io/kafka-starter-go/app/parser"
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
 
 type RequestHeader struct {
 	MessageSize       int32
@@ -67,3 +72,61 @@ func ParseRequestHeader(buffer []byte, index int) (RequestHeader, int, error) {
 
 	return requestHeader, index, nil
 }
+
+// serializeRequestHeader writes the header of a request this node sends, after a placeholder for the message size.
+// It returns the index of the request body
+func serializeRequestHeader(buffer []byte, requestHeader RequestHeader) (int, error) {
+	// Message size (placeholder)
+	index := 4
+	var err error
+
+	index, err = serializer.SerializeInt16(buffer, index, requestHeader.RequestApiKey)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, requestHeader.RequestApiVersion)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, requestHeader.CorrelationId)
+	if err != nil {
+		return index, err
+	}
+
+	index, err = serializer.SerializeString(buffer, index, requestHeader.ClientId)
+	if err != nil {
+		return index, err
+	}
+
+	if isFlexibleVersion(requestHeader.RequestApiKey, requestHeader.RequestApiVersion) {
+		index, err = serializer.SerializeTaggedFields(buffer, index, requestHeader.TaggedFields)
+		if err != nil {
+			return index, err
+		}
+	}
+
+	return index, nil
+}
+
+// parseResponseHeader reads the size and the header of a flexible response. It returns the correlation id and the
+// index of the response body
+func parseResponseHeader(buffer []byte) (int32, int, error) {
+	_, index, err := parser.ExtractInt32(buffer, 0)
+	if err != nil {
+		return 0, index, fmt.Errorf("failed to parse message size from response header: %w", err)
+	}
+
+	correlationId, index, err := parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return 0, index, fmt.Errorf("failed to parse correlation ID from response header: %w", err)
+	}
+
+	_, index, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return 0, index, fmt.Errorf("failed to parse tagged fields from response header: %w", err)
+	}
+
+	return correlationId, index, nil
+}
@@ -0,0 +1,393 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type IncrementalAlterableConfig struct {
+	Name            string
+	ConfigOperation int8
+	Value           *string
+	TaggedFields    map[string]string
+}
+
+type IncrementalAlterConfigsResource struct {
+	ResourceType int8
+	ResourceName string
+	Configs      []IncrementalAlterableConfig
+	TaggedFields map[string]string
+}
+
+type IncrementalAlterConfigsRequest struct {
+	Header       RequestHeader
+	Resources    []IncrementalAlterConfigsResource
+	ValidateOnly bool
+	TaggedFields map[string]string
+}
+
+func (r *IncrementalAlterConfigsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *IncrementalAlterConfigsRequest) GetApiKey() KafkaAPIKey {
+	return IncrementalAlterConfigs
+}
+
+func (r *IncrementalAlterConfigsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *IncrementalAlterConfigsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the controller channel of a broker forwards to the active controller
+func (r *IncrementalAlterConfigsRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	for _, resource := range r.Resources {
+		bufferSize += 16 + len(resource.ResourceName)
+		for _, alterableConfig := range resource.Configs {
+			bufferSize += 16 + len(alterableConfig.Name)
+			if alterableConfig.Value != nil {
+				bufferSize += len(*alterableConfig.Value)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Resources)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range r.Resources {
+		index, err = serializer.SerializeInt8(buffer, index, resource.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, resource.ResourceName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(resource.Configs)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, alterableConfig := range resource.Configs {
+			index, err = serializer.SerializeCompactString(buffer, index, alterableConfig.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt8(buffer, index, alterableConfig.ConfigOperation)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, alterableConfig.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, alterableConfig.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, resource.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeBoolean(buffer, index, r.ValidateOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type IncrementalAlterConfigsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Responses     []AlterConfigsResourceResponse
+	TaggedFields  map[string]string
+}
+
+func (r *IncrementalAlterConfigsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *IncrementalAlterConfigsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *IncrementalAlterConfigsResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, result := range r.Responses {
+		counts[result.ErrorCode]++
+	}
+	return counts
+}
+
+func (r *IncrementalAlterConfigsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	return serializeAlterConfigsResponse(r.CorrelationId, r.ThrottleTime, r.Responses, r.TaggedFields)
+}
+
+// parseIncrementalAlterConfigsResponse reads the response of the active controller to the controller channel
+func parseIncrementalAlterConfigsResponse(buffer []byte) (*IncrementalAlterConfigsResponse, error) {
+	response := &IncrementalAlterConfigsResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ThrottleTime, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse IncrementalAlterConfigs response: %w", err)
+	}
+
+	responsesLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || responsesLength < 0 {
+		return nil, fmt.Errorf("failed to parse responses length from IncrementalAlterConfigs response")
+	}
+
+	response.Responses = make([]AlterConfigsResourceResponse, 0, responsesLength)
+	for i := 0; i < responsesLength; i++ {
+		resource := AlterConfigsResourceResponse{}
+
+		resource.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+		if err == nil {
+			resource.ErrorMessage, index, err = parser.ExtractCompactNullableString(buffer, index)
+		}
+		if err == nil {
+			resource.ResourceType, index, err = parser.ExtractInt8(buffer, index)
+		}
+		if err == nil {
+			resource.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+		}
+		if err == nil {
+			resource.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse resource from IncrementalAlterConfigs response: %w", err)
+		}
+
+		response.Responses = append(response.Responses, resource)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tagged fields from IncrementalAlterConfigs response: %w", err)
+	}
+
+	return response, nil
+}
+
+type IncrementalAlterConfigsHandler struct {
+	configs    *config.Store
+	controller *controller.Controller
+	// nil when this node is not a broker of a metadata quorum
+	channel    *ControllerChannel
+	authorizer acl.Authorizer
+}
+
+func (h *IncrementalAlterConfigsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &IncrementalAlterConfigsRequest{}
+	req.Header = requestHeader
+
+	resourcesLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse resources length from IncrementalAlterConfigs request",
+		}
+	}
+
+	resources := make([]IncrementalAlterConfigsResource, 0, max(resourcesLength, 0))
+
+	for i := 0; i < resourcesLength; i++ {
+		resource := IncrementalAlterConfigsResource{}
+
+		resource.ResourceType, index, err = parser.ExtractInt8(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource type from IncrementalAlterConfigs request at index %d", i),
+			}
+		}
+
+		resource.ResourceName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse resource name from IncrementalAlterConfigs request at index %d", i),
+			}
+		}
+
+		var configsLength int
+		configsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse configs length from IncrementalAlterConfigs request at index %d", i),
+			}
+		}
+
+		resource.Configs = make([]IncrementalAlterableConfig, 0, max(configsLength, 0))
+
+		for j := 0; j < configsLength; j++ {
+			alterableConfig := IncrementalAlterableConfig{}
+
+			alterableConfig.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse config name from IncrementalAlterConfigs request at index %d", j),
+				}
+			}
+
+			alterableConfig.ConfigOperation, index, err = parser.ExtractInt8(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse config operation from IncrementalAlterConfigs request at index %d", j),
+				}
+			}
+
+			alterableConfig.Value, index, err = parser.ExtractCompactNullableString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse config value from IncrementalAlterConfigs request at index %d", j),
+				}
+			}
+
+			alterableConfig.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse config tagged fields from IncrementalAlterConfigs request",
+				}
+			}
+
+			resource.Configs = append(resource.Configs, alterableConfig)
+		}
+
+		resource.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse resource tagged fields from IncrementalAlterConfigs request",
+			}
+		}
+
+		resources = append(resources, resource)
+	}
+
+	req.Resources = resources
+
+	req.ValidateOnly, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse validate only from IncrementalAlterConfigs request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from IncrementalAlterConfigs request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *IncrementalAlterConfigsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*IncrementalAlterConfigsRequest)
+	if !ok {
+		return nil, fmt.Errorf("IncrementalAlterConfigsHandler received %T instead of *IncrementalAlterConfigsRequest", req)
+	}
+
+	versionErr := apiReq.Validate()
+	responses := make([]AlterConfigsResourceResponse, 0, len(apiReq.Resources))
+
+	for _, resource := range apiReq.Resources {
+		response := AlterConfigsResourceResponse{
+			ResourceType: resource.ResourceType,
+			ResourceName: resource.ResourceName,
+			TaggedFields: make(map[string]string),
+		}
+
+		err := versionErr
+		if err == nil {
+			err = authorizeAlterConfigs(h.authorizer, session, resource.ResourceType, resource.ResourceName)
+		}
+
+		if err == nil {
+			ops := make([]config.AlterOp, 0, len(resource.Configs))
+			for _, alterableConfig := range resource.Configs {
+				ops = append(ops, config.AlterOp{
+					Name:  alterableConfig.Name,
+					Op:    config.OpType(alterableConfig.ConfigOperation),
+					Value: alterableConfig.Value,
+				})
+			}
+
+			configResource := config.Resource{Type: config.ResourceType(resource.ResourceType), Name: resource.ResourceName}
+			var changes map[string]*string
+			changes, err = h.configs.IncrementalAlter(configResource, ops)
+			if err == nil {
+				err = alterConfigs(h.configs, h.controller, h.channel, configResource, changes, apiReq.ValidateOnly)
+			}
+		}
+
+		if err != nil {
+			response.ErrorCode, response.ErrorMessage = configErrorCode(err)
+		}
+
+		responses = append(responses, response)
+	}
+
+	response := &IncrementalAlterConfigsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ThrottleTime:  0,
+		Responses:     responses,
+		TaggedFields:  make(map[string]string),
+	}
+
+	return response, nil
+}
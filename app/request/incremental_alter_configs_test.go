@@ -0,0 +1,186 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+)
+
+func TestIncrementalAlterConfigsParseRequestBody(t *testing.T) {
+	handler := IncrementalAlterConfigsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x32, // MessageSize: 50
+		0x00, 0x2C, // RequestApiKey: 44 (IncrementalAlterConfigs)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // Resources array length (1 resource + 1)
+		0x02,                // ResourceType: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceName: "foo"
+		0x02,                                                                       // Configs array length (1 config + 1)
+		0x0F, 'c', 'l', 'e', 'a', 'n', 'u', 'p', '.', 'p', 'o', 'l', 'i', 'c', 'y', // Name: "cleanup.policy"
+		0x02,                                    // ConfigOperation: 2 (APPEND)
+		0x08, 'c', 'o', 'm', 'p', 'a', 'c', 't', // Value: "compact"
+		0x00, // Config tagged fields
+		0x00, // Resource tagged fields
+		0x00, // ValidateOnly: false
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &IncrementalAlterConfigsRequest{
+		Header: header,
+		Resources: []IncrementalAlterConfigsResource{
+			{
+				ResourceType: 2,
+				ResourceName: "foo",
+				Configs: []IncrementalAlterableConfig{
+					{Name: "cleanup.policy", ConfigOperation: 2, Value: stringPtr("compact"), TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		ValidateOnly: false,
+		TaggedFields: map[string]string{},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestIncrementalAlterConfigsHandleRequest(t *testing.T) {
+	header := RequestHeader{RequestApiKey: 44, RequestApiVersion: 1, CorrelationId: 11}
+
+	tests := []struct {
+		name          string
+		configs       []IncrementalAlterableConfig
+		wantErrorCode int16
+		wantPolicy    string
+	}{
+		{
+			name:          "Append",
+			configs:       []IncrementalAlterableConfig{{Name: "cleanup.policy", ConfigOperation: int8(config.APPEND), Value: stringPtr("compact")}},
+			wantErrorCode: 0,
+			wantPolicy:    "delete,compact",
+		},
+		{
+			name:          "Set",
+			configs:       []IncrementalAlterableConfig{{Name: "cleanup.policy", ConfigOperation: int8(config.SET), Value: stringPtr("compact")}},
+			wantErrorCode: 0,
+			wantPolicy:    "compact",
+		},
+		{
+			name:          "Unknown config",
+			configs:       []IncrementalAlterableConfig{{Name: "cleanup.foo", ConfigOperation: int8(config.SET), Value: stringPtr("compact")}},
+			wantErrorCode: int16(INVALID_CONFIG),
+			wantPolicy:    "delete",
+		},
+		{
+			name: "Duplicate config",
+			configs: []IncrementalAlterableConfig{
+				{Name: "cleanup.policy", ConfigOperation: int8(config.SET), Value: stringPtr("compact")},
+				{Name: "cleanup.policy", ConfigOperation: int8(config.DELETE)},
+			},
+			wantErrorCode: int16(INVALID_REQUEST),
+			wantPolicy:    "delete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.UnixMilli(1_000_000)
+			active, _, configs := newTestConfigs(t, now)
+			handler := IncrementalAlterConfigsHandler{configs: configs, controller: active, authorizer: acl.NewAclAuthorizer(nil, true)}
+
+			request := IncrementalAlterConfigsRequest{
+				Header: header,
+				Resources: []IncrementalAlterConfigsResource{
+					{ResourceType: 2, ResourceName: "foo", Configs: tt.configs},
+				},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			active.Node().Poll(now)
+
+			gotResp, ok := got.(*IncrementalAlterConfigsResponse)
+			if !ok {
+				t.Fatalf("expected *IncrementalAlterConfigsResponse, got %T", got)
+			}
+
+			if len(gotResp.Responses) != 1 {
+				t.Fatalf("Responses length mismatch: got %d, want 1", len(gotResp.Responses))
+			}
+
+			if gotResp.Responses[0].ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.Responses[0].ErrorCode, tt.wantErrorCode)
+			}
+
+			policy, err := configs.Value(config.Resource{Type: config.TOPIC, Name: "foo"}, "cleanup.policy")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if policy != tt.wantPolicy {
+				t.Errorf("cleanup.policy mismatch: got %s, want %s", policy, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestIncrementalAlterConfigsResponseSerialize(t *testing.T) {
+	message := "bad"
+	response := IncrementalAlterConfigsResponse{
+		CorrelationId: 11,
+		ThrottleTime:  0,
+		Responses: []AlterConfigsResourceResponse{
+			{ErrorCode: 40, ErrorMessage: &message, ResourceType: 2, ResourceName: "foo", TaggedFields: map[string]string{}},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x17, // MessageSize: 23
+		0x00, 0x00, 0x00, 0x0B, // CorrelationId: 11
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,       // Responses array length (1 response + 1)
+		0x00, 0x28, // ErrorCode: 40 (INVALID_CONFIG)
+		0x04, 'b', 'a', 'd', // ErrorMessage: "bad"
+		0x02,                // ResourceType: 2 (TOPIC)
+		0x04, 'f', 'o', 'o', // ResourceName: "foo"
+		0x00, // Response tagged fields
+		0x00, // Tagged fields
+	}
+
+	got, err := response.Serialize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -0,0 +1,238 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type InitProducerIdRequest struct {
+	Header RequestHeader
+	// nil for an idempotent producer
+	TransactionalId      *string
+	TransactionTimeoutMs int32
+	// -1 for a new producer, the current producer id and epoch of a producer that resets its epoch
+	ProducerId    int64
+	ProducerEpoch int16
+	TaggedFields  map[string]string
+}
+
+func (r *InitProducerIdRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *InitProducerIdRequest) GetApiKey() KafkaAPIKey {
+	return InitProducerId
+}
+
+func (r *InitProducerIdRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *InitProducerIdRequest) Validate() error {
+	if r.Header.RequestApiVersion != 4 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type InitProducerIdResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	// -1 on errors
+	ProducerId    int64
+	ProducerEpoch int16
+	TaggedFields  map[string]string
+}
+
+func (r *InitProducerIdResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *InitProducerIdResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *InitProducerIdResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *InitProducerIdResponse) Serialize(apiVersion int16) ([]byte, error) {
+	buffer := make([]byte, 40)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.ProducerId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ProducerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// InitProducerIdHandler hands out producer ids and epochs. The producers with a transactional id ask its coordinator,
+// the idempotent producers ask any broker
+type InitProducerIdHandler struct {
+	// nil without partition logs
+	coordinator *transaction.Coordinator
+	authorizer  acl.Authorizer
+}
+
+func (h *InitProducerIdHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &InitProducerIdRequest{}
+	req.Header = requestHeader
+
+	req.TransactionalId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse transactional id from InitProducerId request",
+		}
+	}
+
+	req.TransactionTimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse transaction timeout from InitProducerId request",
+		}
+	}
+
+	req.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+	if err == nil {
+		req.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse producer from InitProducerId request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from InitProducerId request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *InitProducerIdHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *InitProducerIdHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*InitProducerIdRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("InitProducerIdHandler received %T instead of *InitProducerIdRequest", req))
+		return
+	}
+
+	response := &InitProducerIdResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		ProducerId:    -1,
+		ProducerEpoch: -1,
+		TaggedFields:  make(map[string]string),
+	}
+
+	// The idempotent producers need IDEMPOTENT_WRITE on the cluster, the transactional ones WRITE on their id
+	transactionalId := apiReq.TransactionalId
+	if transactionalId != nil && *transactionalId == "" {
+		transactionalId = nil
+	}
+	switch {
+	case transactionalId != nil && !session.authorize(h.authorizer, acl.WRITE, acl.TRANSACTIONAL_ID, *transactionalId):
+		response.ErrorCode = int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED)
+	case transactionalId == nil && !session.authorize(h.authorizer, acl.IDEMPOTENT_WRITE, acl.CLUSTER, acl.ClusterResourceName):
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+	case h.coordinator == nil:
+		response.ErrorCode = int16(COORDINATOR_NOT_AVAILABLE)
+	}
+	if response.ErrorCode != int16(NONE) {
+		respond(response, nil)
+		return
+	}
+
+	h.coordinator.InitProducerId(transactionalId, apiReq.TransactionTimeoutMs, apiReq.ProducerId, apiReq.ProducerEpoch, func(producerId int64, producerEpoch int16, err error) {
+		if err != nil {
+			response.ErrorCode = transactionErrorCode(err)
+		} else {
+			response.ProducerId, response.ProducerEpoch = producerId, producerEpoch
+		}
+		respond(response, nil)
+	})
+}
+
+// transactionErrorCode maps the errors of the transaction coordinator, and of the partitions it appends to, to error
+// codes
+func transactionErrorCode(err error) int16 {
+	switch {
+	case errors.Is(err, transaction.ErrNotCoordinator):
+		return int16(NOT_COORDINATOR)
+	case errors.Is(err, transaction.ErrCoordinatorLoadInProgress):
+		return int16(COORDINATOR_LOAD_IN_PROGRESS)
+	case errors.Is(err, transaction.ErrCoordinatorNotAvailable):
+		return int16(COORDINATOR_NOT_AVAILABLE)
+	case errors.Is(err, transaction.ErrConcurrentTransactions):
+		return int16(CONCURRENT_TRANSACTIONS)
+	case errors.Is(err, transaction.ErrInvalidTxnState):
+		return int16(INVALID_TXN_STATE)
+	case errors.Is(err, transaction.ErrProducerFenced):
+		return int16(PRODUCER_FENCED)
+	case errors.Is(err, transaction.ErrInvalidProducerIdMapping):
+		return int16(INVALID_PRODUCER_ID_MAPPING)
+	case errors.Is(err, transaction.ErrInvalidTransactionTimeout):
+		return int16(INVALID_TRANSACTION_TIMEOUT)
+	case errors.Is(err, transaction.ErrTransactionalIdNotFound):
+		return int16(TRANSACTIONAL_ID_NOT_FOUND)
+	default:
+		return int16(replicaErrorCode(err))
+	}
+}
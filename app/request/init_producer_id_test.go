@@ -0,0 +1,153 @@
+package request
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+// testProducerIds allocates the blocks of producer ids like the active controller
+type testProducerIds struct {
+	mutex sync.Mutex
+	next  int64
+}
+
+func (s *testProducerIds) AllocateProducerIds(brokerId int32, brokerEpoch int64) (controller.ProducerIdsBlock, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	block := controller.ProducerIdsBlock{FirstProducerId: s.next, Size: 10}
+	s.next += 10
+	return block, nil
+}
+
+// newTestCoordinator returns the transaction coordinator of broker 1 of newTestConfigs, which leads the single
+// partition of the state log and writes the markers to its own replicas
+func newTestCoordinator(t *testing.T, active *controller.Controller, loader *metadata.Loader, logs *storage.LogManager, replicas *replica.Manager, now time.Time) *transaction.Coordinator {
+	t.Helper()
+
+	coordinator := transaction.NewCoordinator(transaction.Config{
+		NodeId:                1,
+		NumPartitions:         1,
+		MaxTimeout:            time.Minute,
+		AbortTimedOutInterval: time.Hour,
+		RequestTimeout:        time.Second,
+		RetryBackoff:          10 * time.Millisecond,
+	}, logs, replicas, NewTransactionTransport(1, "PLAINTEXT", loader, replicas, time.Second, 10*time.Millisecond), &testProducerIds{}, func() int64 { return 1 }, slog.New(slog.DiscardHandler))
+	t.Cleanup(coordinator.Shutdown)
+	loader.Subscribe(coordinator.ApplyImage)
+
+	if _, err := active.CreateTopic(transaction.TRANSACTION_STATE_TOPIC, [][]int32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+	return coordinator
+}
+
+func TestInitProducerIdParseRequestBody(t *testing.T) {
+	handler := InitProducerIdHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x22, // MessageSize: 34
+		0x00, 0x16, // RequestApiKey: 22 (InitProducerId)
+		0x00, 0x04, // RequestApiVersion: 4
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x00, 0x00, 0xEA, 0x60, // TransactionTimeoutMs: 60000
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // ProducerId: -1
+		0xFF, 0xFF, // ProducerEpoch: -1
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	transactionalId := "txn"
+	want := &InitProducerIdRequest{
+		Header:               header,
+		TransactionalId:      &transactionalId,
+		TransactionTimeoutMs: 60000,
+		ProducerId:           -1,
+		ProducerEpoch:        -1,
+		TaggedFields:         map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestInitProducerIdHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+
+	transactionalId := "txn"
+	empty := ""
+	tests := []struct {
+		name            string
+		coordinator     *transaction.Coordinator
+		authorizer      acl.Authorizer
+		transactionalId *string
+		timeoutMs       int32
+		want            KafkaErrorCode
+		producerId      int64
+	}{
+		{"Unauthorized transactional id", coordinator, denyAllAuthorizer{}, &transactionalId, 60000, TRANSACTIONAL_ID_AUTHORIZATION_FAILED, -1},
+		{"Unauthorized idempotent producer", coordinator, denyAllAuthorizer{}, nil, 60000, CLUSTER_AUTHORIZATION_FAILED, -1},
+		{"No coordinator", nil, acl.NewAclAuthorizer(nil, true), &transactionalId, 60000, COORDINATOR_NOT_AVAILABLE, -1},
+		{"Transaction timeout above transaction.max.timeout.ms", coordinator, acl.NewAclAuthorizer(nil, true), &transactionalId, 3_600_000, INVALID_TRANSACTION_TIMEOUT, -1},
+		{"Idempotent producer", coordinator, acl.NewAclAuthorizer(nil, true), nil, 60000, NONE, 0},
+		{"Empty transactional id", coordinator, acl.NewAclAuthorizer(nil, true), &empty, 60000, NONE, 1},
+		{"Transactional producer", coordinator, acl.NewAclAuthorizer(nil, true), &transactionalId, 60000, NONE, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &InitProducerIdHandler{coordinator: tt.coordinator, authorizer: tt.authorizer}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &InitProducerIdRequest{
+				Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 7},
+				TransactionalId:      tt.transactionalId,
+				TransactionTimeoutMs: tt.timeoutMs,
+				ProducerId:           -1,
+				ProducerEpoch:        -1,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*InitProducerIdResponse)
+			if response.ErrorCode != int16(tt.want) || response.ProducerId != tt.producerId {
+				t.Errorf("expected %s with producer id %d, got %+v", KafkaErrorCodeNames[tt.want], tt.producerId, response)
+			}
+			if tt.want == NONE && response.ProducerEpoch != 0 {
+				t.Errorf("expected the first epoch of the producer, got %d", response.ProducerEpoch)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type ListPartitionReassignmentsTopic struct {
+	Name             string
+	PartitionIndexes []int32
+	TaggedFields     map[string]string
+}
+
+type ListPartitionReassignmentsRequest struct {
+	Header    RequestHeader
+	TimeoutMs int32
+	// nil to list the reassignments of every partition
+	Topics       []ListPartitionReassignmentsTopic
+	TaggedFields map[string]string
+}
+
+func (r *ListPartitionReassignmentsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *ListPartitionReassignmentsRequest) GetApiKey() KafkaAPIKey {
+	return ListPartitionReassignments
+}
+
+func (r *ListPartitionReassignmentsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *ListPartitionReassignmentsRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type OngoingPartitionReassignment struct {
+	PartitionIndex   int32
+	Replicas         []int32
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+	TaggedFields     map[string]string
+}
+
+type OngoingTopicReassignment struct {
+	Name         string
+	Partitions   []OngoingPartitionReassignment
+	TaggedFields map[string]string
+}
+
+type ListPartitionReassignmentsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	Topics        []OngoingTopicReassignment
+	TaggedFields  map[string]string
+}
+
+func (r *ListPartitionReassignmentsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *ListPartitionReassignmentsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *ListPartitionReassignmentsResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *ListPartitionReassignmentsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.Name)
+		for _, partition := range topic.Partitions {
+			bufferSize += 16 + 4*(len(partition.Replicas)+len(partition.AddingReplicas)+len(partition.RemovingReplicas))
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, replicas := range [][]int32{partition.Replicas, partition.AddingReplicas, partition.RemovingReplicas} {
+				index, err = serializeInt32Array(buffer, index, replicas)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// ListPartitionReassignmentsHandler lists the reassignments in progress on the active controller
+type ListPartitionReassignmentsHandler struct {
+	controller *controller.Controller
+	authorizer acl.Authorizer
+}
+
+func (h *ListPartitionReassignmentsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &ListPartitionReassignmentsRequest{}
+	req.Header = requestHeader
+
+	req.TimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse timeout from ListPartitionReassignments request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from ListPartitionReassignments request",
+		}
+	}
+
+	// A null array means that every reassignment is listed
+	if topicsLength >= 0 {
+		req.Topics = make([]ListPartitionReassignmentsTopic, 0, topicsLength)
+	}
+	for i := 0; i < topicsLength; i++ {
+		topic := ListPartitionReassignmentsTopic{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from ListPartitionReassignments request at index %d", i),
+			}
+		}
+
+		topic.PartitionIndexes, index, err = parseNullableInt32Array(buffer, index)
+		if err != nil || topic.PartitionIndexes == nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partition indexes from ListPartitionReassignments request",
+			}
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from ListPartitionReassignments request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from ListPartitionReassignments request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *ListPartitionReassignmentsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*ListPartitionReassignmentsRequest)
+	if !ok {
+		return nil, fmt.Errorf("ListPartitionReassignmentsHandler received %T instead of *ListPartitionReassignmentsRequest", req)
+	}
+
+	response := &ListPartitionReassignmentsResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		ErrorMessage:  nil,
+		Topics:        []OngoingTopicReassignment{},
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.DESCRIBE, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+	if h.controller == nil {
+		response.ErrorCode = int16(NOT_CONTROLLER)
+		return response, nil
+	}
+
+	var partitions []controller.TopicPartition
+	if apiReq.Topics != nil {
+		partitions = []controller.TopicPartition{}
+		for _, topic := range apiReq.Topics {
+			for _, partition := range topic.PartitionIndexes {
+				partitions = append(partitions, controller.TopicPartition{Topic: topic.Name, Partition: partition})
+			}
+		}
+	}
+
+	reassignments, err := h.controller.ListPartitionReassignments(partitions)
+	if errors.Is(err, metadata.ErrUnknownTopic) {
+		message := err.Error()
+		response.ErrorCode, response.ErrorMessage = int16(UNKNOWN_TOPIC_OR_PARTITION), &message
+		return response, nil
+	}
+	if err != nil {
+		response.ErrorCode, response.ErrorMessage = controllerErrorCode(err)
+		return response, nil
+	}
+
+	// The reassignments are grouped by topic, in the order of the topic names and of the partitions
+	for _, partition := range slices.SortedFunc(maps.Keys(reassignments), compareTopicPartitions) {
+		last := len(response.Topics) - 1
+		if last < 0 || response.Topics[last].Name != partition.Topic {
+			response.Topics = append(response.Topics, OngoingTopicReassignment{
+				Name:         partition.Topic,
+				Partitions:   []OngoingPartitionReassignment{},
+				TaggedFields: map[string]string{},
+			})
+			last++
+		}
+
+		reassignment := reassignments[partition]
+		response.Topics[last].Partitions = append(response.Topics[last].Partitions, OngoingPartitionReassignment{
+			PartitionIndex:   partition.Partition,
+			Replicas:         reassignment.Replicas,
+			AddingReplicas:   reassignment.AddingReplicas,
+			RemovingReplicas: reassignment.RemovingReplicas,
+			TaggedFields:     map[string]string{},
+		})
+	}
+
+	return response, nil
+}
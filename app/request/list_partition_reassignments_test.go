@@ -0,0 +1,202 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestListPartitionReassignmentsParseRequestBody(t *testing.T) {
+	handler := ListPartitionReassignmentsHandler{}
+	header := RequestHeader{RequestApiKey: 46, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  *ListPartitionReassignmentsRequest
+	}{
+		{
+			name: "Some partitions",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x23, // MessageSize: 35
+				0x00, 0x2E, // RequestApiKey: 46 (ListPartitionReassignments)
+				0x00, 0x00, // RequestApiVersion: 0
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+				0x02,                // Topics array length: 1
+				0x04, 'f', 'o', 'o', // Name: "foo"
+				0x03,                   // PartitionIndexes array length: 2
+				0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+				0x00, 0x00, 0x00, 0x01, // PartitionIndex: 1
+				0x00, // Topic tagged fields
+				0x00, // Request tagged fields
+			},
+			want: &ListPartitionReassignmentsRequest{
+				Header:    header,
+				TimeoutMs: 30000,
+				Topics: []ListPartitionReassignmentsTopic{
+					{Name: "foo", PartitionIndexes: []int32{0, 1}, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		{
+			name: "Every partition",
+			input: []byte{
+				// Header
+				0x00, 0x00, 0x00, 0x15, // MessageSize: 21
+				0x00, 0x2E, // RequestApiKey: 46 (ListPartitionReassignments)
+				0x00, 0x00, // RequestApiVersion: 0
+				0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+				0x00, 0x04, // ClientId length: 4
+				't', 'e', 's', 't', // ClientId: "test"
+				0x00, // Number of header tagged fields (varint, 0)
+				// Body starts here
+				0x00, 0x00, 0x75, 0x30, // TimeoutMs: 30000
+				0x00, // Topics: null
+				0x00, // Request tagged fields
+			},
+			want: &ListPartitionReassignmentsRequest{
+				Header:       header,
+				TimeoutMs:    30000,
+				Topics:       nil,
+				TaggedFields: map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.ParseRequestBody(header, tt.input, 19)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("request mismatch: got %+v, want %+v", got, tt.want)
+			}
+
+			if _, err := handler.ParseRequestBody(header, tt.input[:len(tt.input)-3], 19); err == nil {
+				t.Errorf("expected error for a truncated request")
+			}
+		})
+	}
+}
+
+func TestListPartitionReassignmentsHandleRequest(t *testing.T) {
+	active := newReassigningController(t, time.UnixMilli(1_000_000), 2)
+	if _, err := active.AlterPartitionReassignments(map[controller.TopicPartition][]int32{{Topic: "foo", Partition: 1}: {2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	fooReassignment := OngoingTopicReassignment{
+		Name: "foo",
+		Partitions: []OngoingPartitionReassignment{
+			{PartitionIndex: 1, Replicas: []int32{2, 3, 1}, AddingReplicas: []int32{3}, RemovingReplicas: []int32{1}, TaggedFields: map[string]string{}},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	tests := []struct {
+		name          string
+		controller    *controller.Controller
+		authorizer    acl.Authorizer
+		topics        []ListPartitionReassignmentsTopic
+		wantErrorCode KafkaErrorCode
+		wantTopics    []OngoingTopicReassignment
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), nil, NOT_CONTROLLER, []OngoingTopicReassignment{}},
+		{"Not authorized", active, denyAllAuthorizer{}, nil, CLUSTER_AUTHORIZATION_FAILED, []OngoingTopicReassignment{}},
+		{"Unknown topic", active, acl.NewAclAuthorizer(nil, true), []ListPartitionReassignmentsTopic{{Name: "bar", PartitionIndexes: []int32{0}}}, UNKNOWN_TOPIC_OR_PARTITION, []OngoingTopicReassignment{}},
+		{"Every partition", active, acl.NewAclAuthorizer(nil, true), nil, NONE, []OngoingTopicReassignment{fooReassignment}},
+		// The partitions without a reassignment are left out
+		{"Some partitions", active, acl.NewAclAuthorizer(nil, true), []ListPartitionReassignmentsTopic{{Name: "foo", PartitionIndexes: []int32{0, 1, 5}}}, NONE, []OngoingTopicReassignment{fooReassignment}},
+		{"No reassignment", active, acl.NewAclAuthorizer(nil, true), []ListPartitionReassignmentsTopic{{Name: "foo", PartitionIndexes: []int32{0}}}, NONE, []OngoingTopicReassignment{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ListPartitionReassignmentsHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := ListPartitionReassignmentsRequest{
+				Header: RequestHeader{RequestApiKey: 46, RequestApiVersion: 0, CorrelationId: 7},
+				Topics: tt.topics,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*ListPartitionReassignmentsResponse)
+			if !ok {
+				t.Fatalf("expected *ListPartitionReassignmentsResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if !reflect.DeepEqual(gotResp.Topics, tt.wantTopics) {
+				t.Errorf("topics mismatch: got %+v, want %+v", gotResp.Topics, tt.wantTopics)
+			}
+		})
+	}
+}
+
+func TestListPartitionReassignmentsResponseSerialize(t *testing.T) {
+	response := &ListPartitionReassignmentsResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		ErrorCode:     0,
+		ErrorMessage:  nil,
+		Topics: []OngoingTopicReassignment{
+			{
+				Name: "foo",
+				Partitions: []OngoingPartitionReassignment{
+					{PartitionIndex: 0, Replicas: []int32{2, 3, 1}, AddingReplicas: []int32{3}, RemovingReplicas: []int32{1}, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	got, err := response.Serialize(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x30, // MessageSize: 48
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                // ErrorMessage: null
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x04,                   // Replicas array length: 3
+		0x00, 0x00, 0x00, 0x02, // Replica: 2
+		0x00, 0x00, 0x00, 0x03, // Replica: 3
+		0x00, 0x00, 0x00, 0x01, // Replica: 1
+		0x02,                   // AddingReplicas array length: 1
+		0x00, 0x00, 0x00, 0x03, // Replica: 3
+		0x02,                   // RemovingReplicas array length: 1
+		0x00, 0x00, 0x00, 0x01, // Replica: 1
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Tagged fields
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
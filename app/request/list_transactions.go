@@ -0,0 +1,290 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type ListTransactionsRequest struct {
+	Header RequestHeader
+	// The state names and the producer ids of the listed transactions, empty for any
+	StateFilters      []string
+	ProducerIdFilters []int64
+	// Only the transactions running for longer than DurationFilter milliseconds are listed, -1 for any. Since v1
+	DurationFilter int64
+	TaggedFields   map[string]string
+}
+
+func (r *ListTransactionsRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *ListTransactionsRequest) GetApiKey() KafkaAPIKey {
+	return ListTransactions
+}
+
+func (r *ListTransactionsRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *ListTransactionsRequest) Validate() error {
+	if r.Header.RequestApiVersion < 0 || r.Header.RequestApiVersion > 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type TransactionListing struct {
+	TransactionalId  string
+	ProducerId       int64
+	TransactionState string
+	TaggedFields     map[string]string
+}
+
+type ListTransactionsResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	// The state filters of the request that name no state
+	UnknownStateFilters []string
+	TransactionStates   []TransactionListing
+	TaggedFields        map[string]string
+}
+
+func (r *ListTransactionsResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *ListTransactionsResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *ListTransactionsResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *ListTransactionsResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, state := range r.UnknownStateFilters {
+		bufferSize += 8 + len(state)
+	}
+	for _, listing := range r.TransactionStates {
+		bufferSize += 24 + len(listing.TransactionalId) + len(listing.TransactionState)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.UnknownStateFilters)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, state := range r.UnknownStateFilters {
+		index, err = serializer.SerializeCompactString(buffer, index, state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.TransactionStates)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, listing := range r.TransactionStates {
+		index, err = serializer.SerializeCompactString(buffer, index, listing.TransactionalId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt64(buffer, index, listing.ProducerId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, listing.TransactionState)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, listing.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// ListTransactionsHandler lists the transactions of the transactional ids the coordinator of the broker owns, the
+// clients send it to every broker to list the transactions of the cluster
+type ListTransactionsHandler struct {
+	// nil when the broker has no transaction coordinator
+	coordinator *transaction.Coordinator
+	authorizer  acl.Authorizer
+}
+
+func (h *ListTransactionsHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &ListTransactionsRequest{DurationFilter: -1}
+	req.Header = requestHeader
+
+	statesLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || statesLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse state filters length from ListTransactions request",
+		}
+	}
+
+	req.StateFilters = make([]string, 0, statesLength)
+	for i := 0; i < statesLength; i++ {
+		var state string
+		state, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse state filter from ListTransactions request at index %d", i),
+			}
+		}
+		req.StateFilters = append(req.StateFilters, state)
+	}
+
+	producerIdsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || producerIdsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse producer id filters length from ListTransactions request",
+		}
+	}
+
+	req.ProducerIdFilters = make([]int64, 0, producerIdsLength)
+	for i := 0; i < producerIdsLength; i++ {
+		var producerId int64
+		producerId, index, err = parser.ExtractInt64(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse producer id filter from ListTransactions request at index %d", i),
+			}
+		}
+		req.ProducerIdFilters = append(req.ProducerIdFilters, producerId)
+	}
+
+	if requestHeader.RequestApiVersion >= 1 {
+		req.DurationFilter, index, err = parser.ExtractInt64(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse duration filter from ListTransactions request",
+			}
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from ListTransactions request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *ListTransactionsHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*ListTransactionsRequest)
+	if !ok {
+		return nil, fmt.Errorf("ListTransactionsHandler received %T instead of *ListTransactionsRequest", req)
+	}
+
+	response := &ListTransactionsResponse{
+		CorrelationId:       apiReq.Header.CorrelationId,
+		ErrorCode:           int16(NONE),
+		UnknownStateFilters: []string{},
+		TransactionStates:   []TransactionListing{},
+		TaggedFields:        make(map[string]string),
+	}
+
+	if h.coordinator == nil {
+		response.ErrorCode = int16(COORDINATOR_NOT_AVAILABLE)
+		return response, nil
+	}
+
+	filter := transaction.TransactionFilter{
+		States:         make([]transaction.TransactionState, 0, len(apiReq.StateFilters)),
+		ProducerIds:    apiReq.ProducerIdFilters,
+		DurationFilter: time.Duration(apiReq.DurationFilter) * time.Millisecond,
+	}
+	for _, name := range apiReq.StateFilters {
+		state, ok := transaction.ParseTransactionState(name)
+		if !ok {
+			response.UnknownStateFilters = append(response.UnknownStateFilters, name)
+			continue
+		}
+		filter.States = append(filter.States, state)
+	}
+	// Like Kafka, filtering on unknown states only lists no transaction
+	if len(apiReq.StateFilters) > 0 && len(filter.States) == 0 {
+		return response, nil
+	}
+
+	transactions, err := h.coordinator.ListTransactions(filter)
+	if err != nil {
+		response.ErrorCode = transactionErrorCode(err)
+		return response, nil
+	}
+	for _, listed := range transactions {
+		// The transactional ids the principal can not describe are left out
+		if !session.authorize(h.authorizer, acl.DESCRIBE, acl.TRANSACTIONAL_ID, listed.TransactionalId) {
+			continue
+		}
+		response.TransactionStates = append(response.TransactionStates, TransactionListing{
+			TransactionalId:  listed.TransactionalId,
+			ProducerId:       listed.ProducerId,
+			TransactionState: listed.State.String(),
+			TaggedFields:     make(map[string]string),
+		})
+	}
+
+	return response, nil
+}
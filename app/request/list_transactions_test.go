@@ -0,0 +1,185 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+func TestListTransactionsParseRequestBody(t *testing.T) {
+	handler := ListTransactionsHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2A, // MessageSize: 42
+		0x00, 0x42, // RequestApiKey: 66 (ListTransactions)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                                    // StateFilters array length (1 state + 1)
+		0x08, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // StateFilter: "Ongoing"
+		0x02,                                           // ProducerIdFilters array length (1 producer id + 1)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerIdFilter: 5
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xEA, 0x60, // DurationFilter: 60000
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &ListTransactionsRequest{Header: header, StateFilters: []string{"Ongoing"}, ProducerIdFilters: []int64{5}, DurationFilter: 60000, TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	// Version 0 has no duration filter
+	v0 := append(append([]byte{}, input[:37]...), 0x00)
+	v0[3], v0[7] = 0x22, 0x00 // MessageSize: 34, RequestApiVersion: 0
+	header, _, err = ParseRequestHeader(v0, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+	got, err = handler.ParseRequestBody(header, v0, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if durationFilter := got.(*ListTransactionsRequest).DurationFilter; durationFilter != -1 {
+		t.Errorf("expected no duration filter in version 0, got %d", durationFilter)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestListTransactionsHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+
+	// txn has an ongoing transaction, empty and secret have none
+	session := NewSession("127.0.0.1")
+	producerIds := map[string]int64{}
+	for _, transactionalId := range []string{"txn", "empty", "secret"} {
+		initialized, err := (&InitProducerIdHandler{coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &InitProducerIdRequest{
+			Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 1},
+			TransactionalId:      stringPtr(transactionalId),
+			TransactionTimeoutMs: 60000,
+			ProducerId:           -1,
+			ProducerEpoch:        -1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		producerIds[transactionalId] = initialized.(*InitProducerIdResponse).ProducerId
+	}
+	if _, err := (&AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &AddPartitionsToTxnRequest{
+		Header:          RequestHeader{RequestApiKey: 24, RequestApiVersion: 3, CorrelationId: 2},
+		TransactionalId: "txn",
+		ProducerId:      producerIds["txn"],
+		Topics:          []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anonymous can not describe secret
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.TRANSACTIONAL_ID, ResourceName: "secret", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.DESCRIBE, PermissionType: acl.DENY},
+	})
+
+	tests := []struct {
+		name        string
+		coordinator *transaction.Coordinator
+		states      []string
+		producerIds []int64
+		duration    int64
+		want        KafkaErrorCode
+		wantIds     []string
+		wantUnknown []string
+	}{
+		{"No coordinator", nil, nil, nil, -1, COORDINATOR_NOT_AVAILABLE, []string{}, []string{}},
+		{"Any transaction", coordinator, nil, nil, -1, NONE, []string{"empty", "txn"}, []string{}},
+		{"State", coordinator, []string{"Ongoing", "Dead"}, nil, -1, NONE, []string{"txn"}, []string{"Dead"}},
+		{"Unknown states only", coordinator, []string{"Dead"}, nil, -1, NONE, []string{}, []string{"Dead"}},
+		{"Producer id", coordinator, nil, []int64{producerIds["empty"], producerIds["secret"]}, -1, NONE, []string{"empty"}, []string{}},
+		{"Not running long enough", coordinator, []string{"Ongoing"}, nil, time.Hour.Milliseconds(), NONE, []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &ListTransactionsHandler{coordinator: tt.coordinator, authorizer: authorizer}
+			got, err := handler.Handle(session, &ListTransactionsRequest{
+				Header:            RequestHeader{RequestApiKey: 66, RequestApiVersion: 1, CorrelationId: 7},
+				StateFilters:      tt.states,
+				ProducerIdFilters: tt.producerIds,
+				DurationFilter:    tt.duration,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*ListTransactionsResponse)
+			if response.ErrorCode != int16(tt.want) {
+				t.Errorf("expected %s, got %s", KafkaErrorCodeNames[tt.want], KafkaErrorCodeNames[KafkaErrorCode(response.ErrorCode)])
+			}
+			ids := []string{}
+			for _, listing := range response.TransactionStates {
+				ids = append(ids, listing.TransactionalId)
+				if listing.ProducerId != producerIds[listing.TransactionalId] {
+					t.Errorf("expected producer %d for %s, got %d", producerIds[listing.TransactionalId], listing.TransactionalId, listing.ProducerId)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) || !reflect.DeepEqual(response.UnknownStateFilters, tt.wantUnknown) {
+				t.Errorf("expected the transactions %v and the unknown states %v, got %v and %v", tt.wantIds, tt.wantUnknown, ids, response.UnknownStateFilters)
+			}
+		})
+	}
+}
+
+func TestListTransactionsResponseSerialize(t *testing.T) {
+	response := &ListTransactionsResponse{
+		CorrelationId:       7,
+		UnknownStateFilters: []string{"Dead"},
+		TransactionStates:   []TransactionListing{{TransactionalId: "txn", ProducerId: 5, TransactionState: "Ongoing", TaggedFields: map[string]string{}}},
+		TaggedFields:        map[string]string{},
+	}
+
+	serialized, err := response.Serialize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x00, 0x00, 0x00, 0x28, // MessageSize: 40
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x00, 0x00, // ErrorCode: 0
+		0x02,                     // UnknownStateFilters array length (1 state + 1)
+		0x05, 'D', 'e', 'a', 'd', // UnknownStateFilter: "Dead"
+		0x02,                // TransactionStates array length (1 state + 1)
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x08, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // TransactionState: "Ongoing"
+		0x00, // State tagged fields
+		0x00, // Response tagged fields
+	}
+	if !reflect.DeepEqual(serialized, want) {
+		t.Errorf("expected %x, got %x", want, serialized)
+	}
+}
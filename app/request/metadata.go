@@ -0,0 +1,600 @@
+package request
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+// Topic and cluster authorized operations are reported as this value when the client did not ask for them
+const authorizedOperationsOmitted int32 = math.MinInt32
+
+const zeroUuid = "00000000-0000-0000-0000-000000000000"
+
+type MetadataRequestTopic struct {
+	TopicId string
+	// Name is nil when the topic is requested by id
+	Name         *string
+	TaggedFields map[string]string
+}
+
+type MetadataRequest struct {
+	Header RequestHeader
+	// Topics is nil to request every topic
+	Topics                 []MetadataRequestTopic
+	AllowAutoTopicCreation bool
+	// Sent up to version 10
+	IncludeClusterAuthorizedOperations bool
+	IncludeTopicAuthorizedOperations   bool
+	TaggedFields                       map[string]string
+}
+
+func (r *MetadataRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *MetadataRequest) GetApiKey() KafkaAPIKey {
+	return Metadata
+}
+
+func (r *MetadataRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+// Validate accepts the flexible versions
+func (r *MetadataRequest) Validate() error {
+	if r.Header.RequestApiVersion < 9 || r.Header.RequestApiVersion > 12 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type MetadataResponseBroker struct {
+	NodeId       int32
+	Host         string
+	Port         int32
+	Rack         *string
+	TaggedFields map[string]string
+}
+
+type MetadataResponsePartition struct {
+	ErrorCode       int16
+	PartitionIndex  int32
+	LeaderId        int32
+	LeaderEpoch     int32
+	ReplicaNodes    []int32
+	IsrNodes        []int32
+	OfflineReplicas []int32
+	TaggedFields    map[string]string
+}
+
+type MetadataResponseTopic struct {
+	ErrorCode                 int16
+	Name                      *string
+	TopicId                   string
+	IsInternal                bool
+	Partitions                []MetadataResponsePartition
+	TopicAuthorizedOperations int32
+	TaggedFields              map[string]string
+}
+
+type MetadataResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Brokers       []MetadataResponseBroker
+	ClusterId     *string
+	ControllerId  int32
+	Topics        []MetadataResponseTopic
+	// Sent up to version 10
+	ClusterAuthorizedOperations int32
+	TaggedFields                map[string]string
+}
+
+func (r *MetadataResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *MetadataResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTime = throttleTimeMs }
+
+func serializeInt32Array(buffer []byte, index int, values []int32) (int, error) {
+	index, err := serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(values)+1))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, value := range values {
+		index, err = serializer.SerializeInt32(buffer, index, value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return index, nil
+}
+
+func (r *MetadataResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, topic := range r.Topics {
+		counts[topic.ErrorCode]++
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *MetadataResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	if r.ClusterId != nil {
+		bufferSize += len(*r.ClusterId)
+	}
+	for _, broker := range r.Brokers {
+		bufferSize += 32 + len(broker.Host)
+		if broker.Rack != nil {
+			bufferSize += len(*broker.Rack)
+		}
+	}
+	for _, topic := range r.Topics {
+		bufferSize += 48
+		if topic.Name != nil {
+			bufferSize += len(*topic.Name)
+		}
+		for _, partition := range topic.Partitions {
+			bufferSize += 32 + 4*(len(partition.ReplicaNodes)+len(partition.IsrNodes)+len(partition.OfflineReplicas))
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Brokers)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, broker := range r.Brokers {
+		index, err = serializer.SerializeInt32(buffer, index, broker.NodeId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactString(buffer, index, broker.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, broker.Port)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, broker.Rack)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, broker.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ControllerId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeInt16(buffer, index, topic.ErrorCode)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeCompactNullableString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if apiVersion >= 10 {
+			index, err = serializer.SerializeUUID(buffer, index, topic.TopicId)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeBoolean(buffer, index, topic.IsInternal)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeInt32Array(buffer, index, partition.ReplicaNodes)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeInt32Array(buffer, index, partition.IsrNodes)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeInt32Array(buffer, index, partition.OfflineReplicas)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, topic.TopicAuthorizedOperations)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if apiVersion <= 10 {
+		index, err = serializer.SerializeInt32(buffer, index, r.ClusterAuthorizedOperations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// MetadataHandler describes the cluster from the committed metadata image: the brokers, the active controller, and
+// the topics with the leaders and replicas of their partitions
+type MetadataHandler struct {
+	// The id of this broker, which clients can always reach
+	brokerId   int32
+	loader     *metadata.Loader
+	authorizer acl.Authorizer
+}
+
+func (h *MetadataHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &MetadataRequest{}
+	req.Header = requestHeader
+
+	// The body of the versions that are not supported is laid out differently
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from Metadata request",
+		}
+	}
+
+	// A length of -1 represents a null array
+	if topicsLength >= 0 {
+		req.Topics = make([]MetadataRequestTopic, 0, topicsLength)
+
+		for i := 0; i < topicsLength; i++ {
+			topic := MetadataRequestTopic{TopicId: zeroUuid}
+
+			if requestHeader.RequestApiVersion >= 10 {
+				topic.TopicId, index, err = parser.ExtractUUID(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse topic id from Metadata request at index %d", i),
+				}
+			}
+
+			topic.Name, index, err = parser.ExtractCompactNullableString(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse topic name from Metadata request at index %d", i),
+				}
+			}
+
+			// Like Kafka, topics are only requested by id from version 12
+			if topic.Name == nil && requestHeader.RequestApiVersion < 12 {
+				return nil, &RequestParseError{
+					Code:    UNSUPPORTED_VERSION,
+					Message: fmt.Sprintf("Metadata requests by topic id need version 12, got version %d", requestHeader.RequestApiVersion),
+				}
+			}
+
+			topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse topic tagged fields from Metadata request",
+				}
+			}
+
+			req.Topics = append(req.Topics, topic)
+		}
+	}
+
+	req.AllowAutoTopicCreation, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse allow auto topic creation from Metadata request",
+		}
+	}
+
+	if requestHeader.RequestApiVersion <= 10 {
+		req.IncludeClusterAuthorizedOperations, index, err = parser.ExtractBoolean(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse include cluster authorized operations from Metadata request",
+			}
+		}
+	}
+
+	req.IncludeTopicAuthorizedOperations, index, err = parser.ExtractBoolean(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse include topic authorized operations from Metadata request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from Metadata request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *MetadataHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*MetadataRequest)
+	if !ok {
+		return nil, fmt.Errorf("MetadataHandler received %T instead of *MetadataRequest", req)
+	}
+
+	image := h.loader.Image()
+	response := &MetadataResponse{
+		CorrelationId:               apiReq.Header.CorrelationId,
+		ThrottleTime:                0,
+		Brokers:                     []MetadataResponseBroker{},
+		ClusterId:                   nil,
+		ControllerId:                -1,
+		Topics:                      []MetadataResponseTopic{},
+		ClusterAuthorizedOperations: authorizedOperationsOmitted,
+		TaggedFields:                make(map[string]string),
+	}
+	if apiReq.IncludeClusterAuthorizedOperations {
+		response.ClusterAuthorizedOperations = session.authorizedOperations(h.authorizer, acl.CLUSTER, acl.ClusterResourceName)
+	}
+
+	// Clients get the endpoints of the listener they connected through, the brokers without it are left out like
+	// Kafka does. Fenced brokers are not alive
+	for _, brokerId := range image.BrokerIds() {
+		broker, _ := image.Broker(brokerId)
+		if endpoint, ok := aliveEndpoint(image, brokerId, session.Listener.Name); ok {
+			response.Brokers = append(response.Brokers, MetadataResponseBroker{
+				NodeId:       brokerId,
+				Host:         endpoint.Host,
+				Port:         int32(endpoint.Port),
+				Rack:         broker.Rack,
+				TaggedFields: make(map[string]string),
+			})
+		}
+	}
+
+	// The client reached this broker through the listener, it is listed even before it registered or when there is no
+	// metadata quorum, so that clients can always bootstrap from it
+	if !slices.ContainsFunc(response.Brokers, func(broker MetadataResponseBroker) bool { return broker.NodeId == h.brokerId }) {
+		local := MetadataResponseBroker{
+			NodeId:       h.brokerId,
+			Host:         session.Listener.AdvertisedHost,
+			Port:         session.Listener.AdvertisedPort,
+			TaggedFields: make(map[string]string),
+		}
+		if broker, ok := image.Broker(h.brokerId); ok {
+			local.Rack = broker.Rack
+		}
+		response.Brokers = append(response.Brokers, local)
+		slices.SortFunc(response.Brokers, func(a, b MetadataResponseBroker) int { return cmp.Compare(a.NodeId, b.NodeId) })
+	}
+
+	// Clients send their controller requests to a broker, the active controller when it is a listed broker and this
+	// broker otherwise
+	response.ControllerId = h.brokerId
+	if leaderId := h.loader.Leader().LeaderId; slices.ContainsFunc(response.Brokers, func(broker MetadataResponseBroker) bool { return broker.NodeId == leaderId }) {
+		response.ControllerId = leaderId
+	}
+
+	// A null topic list asks for every topic, those the client may not describe are left out
+	if apiReq.Topics == nil {
+		for _, name := range image.TopicNames() {
+			if session.authorize(h.authorizer, acl.DESCRIBE, acl.TOPIC, name) {
+				topicImage, _ := image.Topic(name)
+				response.Topics = append(response.Topics, h.describeTopic(session, apiReq, image, topicImage))
+			}
+		}
+		return response, nil
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		var topicImage *metadata.TopicImage
+		var exists bool
+		if requestTopic.Name == nil {
+			topicImage, exists = image.TopicById(requestTopic.TopicId)
+		} else {
+			topicImage, exists = image.Topic(*requestTopic.Name)
+		}
+
+		// Clients that may not describe a topic must not learn whether it exists
+		switch {
+		case requestTopic.Name == nil && (!exists || !session.authorize(h.authorizer, acl.DESCRIBE, acl.TOPIC, topicImage.Name)):
+			response.Topics = append(response.Topics, metadataTopicError(UNKNOWN_TOPIC_ID, nil, requestTopic.TopicId))
+		case requestTopic.Name != nil && !session.authorize(h.authorizer, acl.DESCRIBE, acl.TOPIC, *requestTopic.Name):
+			response.Topics = append(response.Topics, metadataTopicError(TOPIC_AUTHORIZATION_FAILED, requestTopic.Name, zeroUuid))
+		case !exists:
+			response.Topics = append(response.Topics, metadataTopicError(UNKNOWN_TOPIC_OR_PARTITION, requestTopic.Name, zeroUuid))
+		default:
+			response.Topics = append(response.Topics, h.describeTopic(session, apiReq, image, topicImage))
+		}
+	}
+
+	return response, nil
+}
+
+func metadataTopicError(code KafkaErrorCode, name *string, topicId string) MetadataResponseTopic {
+	return MetadataResponseTopic{
+		ErrorCode:                 int16(code),
+		Name:                      name,
+		TopicId:                   topicId,
+		IsInternal:                name != nil && isInternalTopic(*name),
+		Partitions:                []MetadataResponsePartition{},
+		TopicAuthorizedOperations: authorizedOperationsOmitted,
+		TaggedFields:              make(map[string]string),
+	}
+}
+
+// describeTopic describes a topic the client may describe, with its partitions in the order of their index
+func (h *MetadataHandler) describeTopic(session *Session, apiReq *MetadataRequest, image *metadata.Image, topicImage *metadata.TopicImage) MetadataResponseTopic {
+	name := topicImage.Name
+	topic := MetadataResponseTopic{
+		ErrorCode:                 int16(NONE),
+		Name:                      &name,
+		TopicId:                   topicImage.Id,
+		IsInternal:                isInternalTopic(name),
+		Partitions:                make([]MetadataResponsePartition, 0, len(topicImage.Partitions)),
+		TopicAuthorizedOperations: authorizedOperationsOmitted,
+		TaggedFields:              make(map[string]string),
+	}
+	if apiReq.IncludeTopicAuthorizedOperations {
+		topic.TopicAuthorizedOperations = session.authorizedOperations(h.authorizer, acl.TOPIC, name)
+	}
+
+	for _, partitionId := range slices.Sorted(maps.Keys(topicImage.Partitions)) {
+		partitionImage := topicImage.Partitions[partitionId]
+		partition := MetadataResponsePartition{
+			ErrorCode:       int16(NONE),
+			PartitionIndex:  partitionId,
+			LeaderId:        partitionImage.Leader,
+			LeaderEpoch:     partitionImage.LeaderEpoch,
+			ReplicaNodes:    partitionImage.Replicas,
+			IsrNodes:        partitionImage.Isr,
+			OfflineReplicas: []int32{},
+			TaggedFields:    make(map[string]string),
+		}
+		for _, replica := range partitionImage.Replicas {
+			if broker, ok := image.Broker(replica); !ok || broker.Fenced {
+				partition.OfflineReplicas = append(partition.OfflineReplicas, replica)
+			}
+		}
+
+		// Like Kafka, a leader the client cannot reach is reported as no leader
+		if _, ok := aliveEndpoint(image, partitionImage.Leader, session.Listener.Name); !ok {
+			partition.ErrorCode = int16(LEADER_NOT_AVAILABLE)
+			if broker, ok := image.Broker(partitionImage.Leader); ok && !broker.Fenced {
+				partition.ErrorCode = int16(LISTENER_NOT_FOUND)
+			}
+			partition.LeaderId = -1
+		}
+
+		topic.Partitions = append(topic.Partitions, partition)
+	}
+
+	return topic
+}
+
+// aliveEndpoint returns the endpoint of an unfenced broker on the listener named listenerName
+func aliveEndpoint(image *metadata.Image, brokerId int32, listenerName string) (metadata.BrokerEndpoint, bool) {
+	broker, ok := image.Broker(brokerId)
+	if !ok || broker.Fenced {
+		return metadata.BrokerEndpoint{}, false
+	}
+	for _, endpoint := range broker.Endpoints {
+		if endpoint.Name == listenerName {
+			return endpoint, true
+		}
+	}
+	return metadata.BrokerEndpoint{}, false
+}
@@ -0,0 +1,45 @@
+package request
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/purgatory"
+)
+
+// metadataImageKey is the key the operations waiting for the metadata of the broker watch, every new image is an
+// event on it
+const metadataImageKey = "metadata"
+
+// metadataPurgatory parks the responses of the requests that changed the metadata until the broker loaded the
+// records they appended, like the controller of Kafka answers once its records are committed. Clients that read the
+// metadata right after the response then see the change
+type metadataPurgatory struct {
+	loader    *metadata.Loader
+	purgatory *purgatory.Purgatory
+}
+
+func newMetadataPurgatory(loader *metadata.Loader) *metadataPurgatory {
+	p := &metadataPurgatory{loader: loader, purgatory: purgatory.New("metadata", purgatory.DEFAULT_PURGE_INTERVAL)}
+	loader.Subscribe(func(image *metadata.Image) {
+		p.purgatory.CheckAndComplete(metadataImageKey)
+	})
+	return p
+}
+
+// loadedOffset tells if an image holds the record at offset
+func loadedOffset(offset int64) func(image *metadata.Image) bool {
+	return func(image *metadata.Image) bool { return image.Offset > offset }
+}
+
+// await calls onLoaded once loaded tells the image of the broker holds the change, or onTimeout when timeout elapses
+// first. A request without a timeout is not parked: onLoaded is called right away, loaded or not
+func (p *metadataPurgatory) await(timeout time.Duration, loaded func(image *metadata.Image) bool, onLoaded func(), onTimeout func()) {
+	if timeout <= 0 {
+		onLoaded()
+		return
+	}
+
+	operation := purgatory.NewDelayedOperation(timeout, func() bool { return loaded(p.loader.Image()) }, onLoaded, onTimeout)
+	p.purgatory.TryCompleteElseWatch(operation, []string{metadataImageKey})
+}
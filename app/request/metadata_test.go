@@ -0,0 +1,445 @@
+package request
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+)
+
+func TestMetadataParseRequestBody(t *testing.T) {
+	handler := MetadataHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x28, // MessageSize: 40
+		0x00, 0x03, // RequestApiKey: 3 (Metadata)
+		0x00, 0x0C, // RequestApiVersion: 12
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02, // Topics array length: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // TopicId: zero
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x00, // Topic tagged fields
+		0x00, // AllowAutoTopicCreation: false
+		0x01, // IncludeTopicAuthorizedOperations: true
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 3, RequestApiVersion: 12, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*MetadataRequest)
+	if !ok {
+		t.Fatalf("expected *MetadataRequest, got %T", got)
+	}
+
+	if len(gotReq.Topics) != 1 || gotReq.Topics[0].Name == nil || *gotReq.Topics[0].Name != "foo" || gotReq.Topics[0].TopicId != zeroUuid {
+		t.Errorf("Topics mismatch: got %+v", gotReq.Topics)
+	}
+
+	if gotReq.AllowAutoTopicCreation || !gotReq.IncludeTopicAuthorizedOperations {
+		t.Errorf("flags mismatch: got AllowAutoTopicCreation %v, IncludeTopicAuthorizedOperations %v", gotReq.AllowAutoTopicCreation, gotReq.IncludeTopicAuthorizedOperations)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated topic id but got nil")
+	}
+}
+
+func TestMetadataParseRequestBodyVersion9(t *testing.T) {
+	handler := MetadataHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x19, // MessageSize: 25
+		0x00, 0x03, // RequestApiKey: 3 (Metadata)
+		0x00, 0x09, // RequestApiVersion: 9
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x00, // Topic tagged fields
+		0x00, // AllowAutoTopicCreation: false
+		0x01, // IncludeClusterAuthorizedOperations: true
+		0x00, // IncludeTopicAuthorizedOperations: false
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 3, RequestApiVersion: 9, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq := got.(*MetadataRequest)
+	if len(gotReq.Topics) != 1 || gotReq.Topics[0].Name == nil || *gotReq.Topics[0].Name != "foo" || gotReq.Topics[0].TopicId != zeroUuid {
+		t.Errorf("Topics mismatch: got %+v", gotReq.Topics)
+	}
+	if !gotReq.IncludeClusterAuthorizedOperations || gotReq.IncludeTopicAuthorizedOperations {
+		t.Errorf("flags mismatch: got IncludeClusterAuthorizedOperations %v, IncludeTopicAuthorizedOperations %v", gotReq.IncludeClusterAuthorizedOperations, gotReq.IncludeTopicAuthorizedOperations)
+	}
+
+	// Topics are requested by id from version 12, and version 8 is not flexible
+	byId := []byte{
+		0x02, // Topics array length: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, // TopicId
+		0x00, // Name: null
+		0x00, // Topic tagged fields
+	}
+	for _, tt := range []struct {
+		version int16
+		body    []byte
+	}{{10, byId}, {8, input[19:]}} {
+		header.RequestApiVersion = tt.version
+		_, err := handler.ParseRequestBody(header, append(make([]byte, 19), tt.body...), 19)
+		if parseErr, ok := err.(*RequestParseError); !ok || parseErr.Code != UNSUPPORTED_VERSION {
+			t.Errorf("version %d: expected UNSUPPORTED_VERSION, got %v", tt.version, err)
+		}
+	}
+}
+
+// newMetadataController returns an active controller with brokers 1 and 2 on the PLAINTEXT listener, broker 1 also on
+// SASL_PLAINTEXT, and broker 3 fenced. Topic foo has partitions led by 1 and 2, topic bar a partition led by 3
+func newMetadataController(t *testing.T, now time.Time, loader *metadata.Loader) *controller.Controller {
+	t.Helper()
+
+	active := newTestController(now, loader)
+	rack := "rack-a"
+	registrations := []controller.BrokerRegistration{
+		{BrokerId: 1, Rack: &rack, Endpoints: []metadata.BrokerEndpoint{
+			{Name: "PLAINTEXT", Host: "localhost", Port: 9092, SecurityProtocol: config.PLAINTEXT.Id()},
+			{Name: "SASL_PLAINTEXT", Host: "broker.example", Port: 19093, SecurityProtocol: config.SASL_PLAINTEXT.Id()},
+		}},
+		{BrokerId: 2, Endpoints: []metadata.BrokerEndpoint{{Name: "PLAINTEXT", Host: "broker-2", Port: 9092, SecurityProtocol: config.PLAINTEXT.Id()}}},
+		{BrokerId: 3, Endpoints: []metadata.BrokerEndpoint{{Name: "PLAINTEXT", Host: "broker-3", Port: 9092, SecurityProtocol: config.PLAINTEXT.Id()}}},
+	}
+	for _, registration := range registrations {
+		registration.IncarnationId = "00000000-0000-0000-0000-000000000007"
+		epoch, err := active.RegisterBroker(registration, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if registration.BrokerId == 3 {
+			continue
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: registration.BrokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := active.CreateTopic("foo", [][]int32{{1, 2}, {2, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.CreateTopic("bar", [][]int32{{3, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+	return active
+}
+
+func TestMetadataHandleRequest(t *testing.T) {
+	now := time.Now()
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	newMetadataController(t, now, loader)
+	foo, _ := loader.Image().Topic("foo")
+	bar, _ := loader.Image().Topic("bar")
+
+	plaintext := config.Listener{Name: "PLAINTEXT", SecurityProtocol: config.PLAINTEXT}
+	sasl := config.Listener{Name: "SASL_PLAINTEXT", SecurityProtocol: config.SASL_PLAINTEXT}
+	rack := "rack-a"
+
+	fooTopic := func(leader2 int32, errorCode KafkaErrorCode) MetadataResponseTopic {
+		return MetadataResponseTopic{
+			ErrorCode: int16(NONE),
+			Name:      stringPtr("foo"),
+			TopicId:   foo.Id,
+			Partitions: []MetadataResponsePartition{
+				{ErrorCode: int16(NONE), PartitionIndex: 0, LeaderId: 1, ReplicaNodes: []int32{1, 2}, IsrNodes: []int32{1, 2}, OfflineReplicas: []int32{}, TaggedFields: map[string]string{}},
+				{ErrorCode: int16(errorCode), PartitionIndex: 1, LeaderId: leader2, ReplicaNodes: []int32{2, 1}, IsrNodes: []int32{2, 1}, OfflineReplicas: []int32{}, TaggedFields: map[string]string{}},
+			},
+			TopicAuthorizedOperations: authorizedOperationsOmitted,
+			TaggedFields:              map[string]string{},
+		}
+	}
+	barTopic := MetadataResponseTopic{
+		ErrorCode: int16(NONE),
+		Name:      stringPtr("bar"),
+		TopicId:   bar.Id,
+		Partitions: []MetadataResponsePartition{
+			{ErrorCode: int16(LEADER_NOT_AVAILABLE), PartitionIndex: 0, LeaderId: -1, ReplicaNodes: []int32{3, 1}, IsrNodes: []int32{3, 1}, OfflineReplicas: []int32{3}, TaggedFields: map[string]string{}},
+		},
+		TopicAuthorizedOperations: authorizedOperationsOmitted,
+		TaggedFields:              map[string]string{},
+	}
+
+	tests := []struct {
+		name        string
+		authorizer  acl.Authorizer
+		listener    config.Listener
+		topics      []MetadataRequestTopic
+		wantBrokers []MetadataResponseBroker
+		wantTopics  []MetadataResponseTopic
+	}{
+		{
+			name:       "Every topic on the plaintext listener",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			listener:   plaintext,
+			topics:     nil,
+			wantBrokers: []MetadataResponseBroker{
+				{NodeId: 1, Host: "localhost", Port: 9092, Rack: &rack, TaggedFields: map[string]string{}},
+				{NodeId: 2, Host: "broker-2", Port: 9092, TaggedFields: map[string]string{}},
+			},
+			wantTopics: []MetadataResponseTopic{barTopic, fooTopic(2, NONE)},
+		},
+		{
+			name:       "Topics by name and id on the SASL listener",
+			authorizer: acl.NewAclAuthorizer(nil, true),
+			listener:   sasl,
+			topics: []MetadataRequestTopic{
+				{TopicId: zeroUuid, Name: stringPtr("foo")},
+				{TopicId: zeroUuid, Name: stringPtr("baz")},
+				{TopicId: bar.Id},
+				{TopicId: "550e8400-e29b-41d4-a716-446655440000"},
+				{TopicId: zeroUuid, Name: stringPtr(CONSUMER_OFFSETS_TOPIC)},
+			},
+			wantBrokers: []MetadataResponseBroker{{NodeId: 1, Host: "broker.example", Port: 19093, Rack: &rack, TaggedFields: map[string]string{}}},
+			wantTopics: []MetadataResponseTopic{
+				fooTopic(-1, LISTENER_NOT_FOUND),
+				metadataTopicError(UNKNOWN_TOPIC_OR_PARTITION, stringPtr("baz"), zeroUuid),
+				barTopic,
+				metadataTopicError(UNKNOWN_TOPIC_ID, nil, "550e8400-e29b-41d4-a716-446655440000"),
+				// The internal topics are flagged even before they are created
+				{
+					ErrorCode:                 int16(UNKNOWN_TOPIC_OR_PARTITION),
+					Name:                      stringPtr(CONSUMER_OFFSETS_TOPIC),
+					TopicId:                   zeroUuid,
+					IsInternal:                true,
+					Partitions:                []MetadataResponsePartition{},
+					TopicAuthorizedOperations: authorizedOperationsOmitted,
+					TaggedFields:              map[string]string{},
+				},
+			},
+		},
+		{
+			name:       "Not authorized",
+			authorizer: denyAllAuthorizer{},
+			listener:   config.Listener{Name: "SSL", SecurityProtocol: config.SSL, AdvertisedHost: "localhost", AdvertisedPort: 9093},
+			topics:     []MetadataRequestTopic{{TopicId: zeroUuid, Name: stringPtr("foo")}, {TopicId: foo.Id}},
+			// The broker that was reached is listed with the endpoint the client used
+			wantBrokers: []MetadataResponseBroker{{NodeId: 1, Host: "localhost", Port: 9093, Rack: &rack, TaggedFields: map[string]string{}}},
+			wantTopics: []MetadataResponseTopic{
+				metadataTopicError(TOPIC_AUTHORIZATION_FAILED, stringPtr("foo"), zeroUuid),
+				metadataTopicError(UNKNOWN_TOPIC_ID, nil, foo.Id),
+			},
+		},
+		{
+			name:       "Every topic the client may describe",
+			authorizer: denyAllAuthorizer{},
+			listener:   plaintext,
+			topics:     nil,
+			wantBrokers: []MetadataResponseBroker{
+				{NodeId: 1, Host: "localhost", Port: 9092, Rack: &rack, TaggedFields: map[string]string{}},
+				{NodeId: 2, Host: "broker-2", Port: 9092, TaggedFields: map[string]string{}},
+			},
+			wantTopics: []MetadataResponseTopic{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := MetadataHandler{brokerId: 1, loader: loader, authorizer: tt.authorizer}
+			session := NewSession("127.0.0.1")
+			session.Listener = tt.listener
+
+			request := MetadataRequest{
+				Header: RequestHeader{RequestApiKey: 3, RequestApiVersion: 12, CorrelationId: 7},
+				Topics: tt.topics,
+			}
+
+			got, err := handler.Handle(session, &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*MetadataResponse)
+			if !ok {
+				t.Fatalf("expected *MetadataResponse, got %T", got)
+			}
+
+			if !reflect.DeepEqual(gotResp.Brokers, tt.wantBrokers) {
+				t.Errorf("Brokers mismatch: got %+v, want %+v", gotResp.Brokers, tt.wantBrokers)
+			}
+
+			// The only controller of the quorum is the active one
+			if gotResp.ControllerId != 1 {
+				t.Errorf("ControllerId mismatch: got %d, want 1", gotResp.ControllerId)
+			}
+
+			if !reflect.DeepEqual(gotResp.Topics, tt.wantTopics) {
+				t.Errorf("Topics mismatch:\ngot  %+v\nwant %+v", gotResp.Topics, tt.wantTopics)
+			}
+		})
+	}
+}
+
+func TestMetadataHandleRequestWithoutQuorum(t *testing.T) {
+	// Without controller.quorum.voters the loader never gets any metadata
+	handler := MetadataHandler{brokerId: 3, loader: metadata.NewLoader(slog.New(slog.DiscardHandler)), authorizer: acl.NewAclAuthorizer(nil, true)}
+	session := NewSession("127.0.0.1")
+	session.Listener = config.Listener{Name: "PLAINTEXT", SecurityProtocol: config.PLAINTEXT, AdvertisedHost: "localhost", AdvertisedPort: 9092}
+
+	request := MetadataRequest{Header: RequestHeader{RequestApiKey: 3, RequestApiVersion: 12, CorrelationId: 7}}
+	got, err := handler.Handle(session, &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotResp := got.(*MetadataResponse)
+	wantBrokers := []MetadataResponseBroker{{NodeId: 3, Host: "localhost", Port: 9092, TaggedFields: map[string]string{}}}
+	if !reflect.DeepEqual(gotResp.Brokers, wantBrokers) {
+		t.Errorf("Brokers mismatch: got %+v, want %+v", gotResp.Brokers, wantBrokers)
+	}
+	if gotResp.ControllerId != 3 {
+		t.Errorf("ControllerId mismatch: got %d, want 3", gotResp.ControllerId)
+	}
+	if gotResp.ClusterAuthorizedOperations != authorizedOperationsOmitted {
+		t.Errorf("expected the cluster authorized operations to be omitted, got %d", gotResp.ClusterAuthorizedOperations)
+	}
+
+	// Clients up to version 10 may ask for the operations they are allowed on the cluster
+	request = MetadataRequest{Header: RequestHeader{RequestApiKey: 3, RequestApiVersion: 10, CorrelationId: 8}, IncludeClusterAuthorizedOperations: true}
+	got, err = handler.Handle(session, &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operations := got.(*MetadataResponse).ClusterAuthorizedOperations; operations == authorizedOperationsOmitted || operations == 0 {
+		t.Errorf("expected the cluster authorized operations, got %d", operations)
+	}
+}
+
+func TestMetadataResponseSerialize(t *testing.T) {
+	response := MetadataResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Brokers: []MetadataResponseBroker{
+			{NodeId: 1, Host: "localhost", Port: 9092, Rack: nil, TaggedFields: map[string]string{}},
+		},
+		ClusterId:    nil,
+		ControllerId: 1,
+		Topics: []MetadataResponseTopic{
+			{
+				ErrorCode:                 int16(UNKNOWN_TOPIC_OR_PARTITION),
+				Name:                      stringPtr("foo"),
+				TopicId:                   zeroUuid,
+				IsInternal:                false,
+				Partitions:                []MetadataResponsePartition{},
+				TopicAuthorizedOperations: authorizedOperationsOmitted,
+				TaggedFields:              map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x42, // MessageSize: 66
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,                   // Brokers array length: 1
+		0x00, 0x00, 0x00, 0x01, // NodeId: 1
+		0x0A, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', // Host: "localhost"
+		0x00, 0x00, 0x23, 0x84, // Port: 9092
+		0x00,                   // Rack: null
+		0x00,                   // Broker tagged fields
+		0x00,                   // ClusterId: null
+		0x00, 0x00, 0x00, 0x01, // ControllerId: 1
+		0x02,       // Topics array length: 1
+		0x00, 0x03, // ErrorCode: 3 (UNKNOWN_TOPIC_OR_PARTITION)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // TopicId: zero
+		0x00,                   // IsInternal: false
+		0x01,                   // Partitions array length: 0
+		0x80, 0x00, 0x00, 0x00, // TopicAuthorizedOperations: omitted
+		0x00, // Topic tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
+
+func TestMetadataResponseSerializeVersion9(t *testing.T) {
+	response := MetadataResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Brokers:       []MetadataResponseBroker{},
+		ClusterId:     nil,
+		ControllerId:  1,
+		Topics: []MetadataResponseTopic{
+			{
+				ErrorCode:                 int16(NONE),
+				Name:                      stringPtr("foo"),
+				TopicId:                   zeroUuid,
+				Partitions:                []MetadataResponsePartition{},
+				TopicAuthorizedOperations: authorizedOperationsOmitted,
+				TaggedFields:              map[string]string{},
+			},
+		},
+		ClusterAuthorizedOperations: 0x0F,
+		TaggedFields:                map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x22, // MessageSize: 34
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x01,                   // Brokers array length: 0
+		0x00,                   // ClusterId: null
+		0x00, 0x00, 0x00, 0x01, // ControllerId: 1
+		0x02,       // Topics array length: 1
+		0x00, 0x00, // ErrorCode: 0
+		0x04, 'f', 'o', 'o', // Name: "foo", no TopicId before version 10
+		0x00,                   // IsInternal: false
+		0x01,                   // Partitions array length: 0
+		0x80, 0x00, 0x00, 0x00, // TopicAuthorizedOperations: omitted
+		0x00,                   // Topic tagged fields
+		0x00, 0x00, 0x00, 0x0F, // ClusterAuthorizedOperations, up to version 10
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -0,0 +1,144 @@
+package request
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/metrics"
+	"github.com/codecrafters-io/kafka-starter-go/app/purgatory"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// erroredResponse is implemented by responses that report error codes, so that they are counted per code like
+// Kafka's ErrorsPerSec. Successful results count as NONE
+type erroredResponse interface {
+	errorCounts() map[int16]int
+}
+
+// requestMetrics are the per API metrics of Kafka's kafka.network RequestMetrics
+type requestMetrics struct {
+	requests      *metrics.Counter
+	errors        *metrics.Counter
+	totalTime     *metrics.Histogram
+	requestBytes  *metrics.Counter
+	responseBytes *metrics.Counter
+}
+
+func newRequestMetrics(registry *metrics.Registry) *requestMetrics {
+	return &requestMetrics{
+		requests:      registry.NewCounter("kafka_network_requests_total", "Requests received, by API and version.", "request", "version"),
+		errors:        registry.NewCounter("kafka_network_errors_total", "Error codes returned, by API and error. Results without an error count as NONE.", "request", "error"),
+		totalTime:     registry.NewHistogram("kafka_network_request_total_time_seconds", "Time taken to process requests, by API and version.", metrics.DEFAULT_BUCKETS, "request", "version"),
+		requestBytes:  registry.NewCounter("kafka_network_request_bytes_total", "Size of the requests received, by API.", "request"),
+		responseBytes: registry.NewCounter("kafka_network_response_bytes_total", "Size of the responses sent, by API.", "request"),
+	}
+}
+
+func (m *requestMetrics) record(header RequestHeader, requestSize int, responseSize int, errorCounts map[int16]int, elapsed time.Duration) {
+	name := apiKeyName(KafkaAPIKey(header.RequestApiKey))
+	version := strconv.Itoa(int(header.RequestApiVersion))
+
+	m.requests.Inc(name, version)
+	m.totalTime.Observe(elapsed.Seconds(), name, version)
+	m.requestBytes.Add(float64(requestSize), name)
+	m.responseBytes.Add(float64(responseSize), name)
+
+	for code, count := range errorCounts {
+		m.errors.Add(float64(count), name, errorCodeName(KafkaErrorCode(code)))
+	}
+}
+
+// topicMetrics are the per topic metrics of Kafka's kafka.server BrokerTopicMetrics. nil records nothing
+type topicMetrics struct {
+	bytesIn  *metrics.Counter
+	bytesOut *metrics.Counter
+}
+
+func newTopicMetrics(registry *metrics.Registry) *topicMetrics {
+	return &topicMetrics{
+		bytesIn:  registry.NewCounter("kafka_server_bytes_in_total", "Bytes appended to the partitions the broker leads, by topic.", "topic"),
+		bytesOut: registry.NewCounter("kafka_server_bytes_out_total", "Record bytes fetched from the broker by consumers and followers, by topic.", "topic"),
+	}
+}
+
+func (m *topicMetrics) recordBytesIn(topic string, size int) {
+	if m != nil {
+		m.bytesIn.Add(float64(size), topic)
+	}
+}
+
+func (m *topicMetrics) recordBytesOut(topic string, size int) {
+	if m != nil && size > 0 {
+		m.bytesOut.Add(float64(size), topic)
+	}
+}
+
+// registerBrokerGauges adds the gauges read from the state of the broker: the partitions it leads with replicas out
+// of their ISR, the size of its partition logs, and the operations parked in its purgatories
+func registerBrokerGauges(registry *metrics.Registry, nodeId int32, loader *metadata.Loader, logs *storage.LogManager, purgatories []*purgatory.Purgatory) {
+	registry.NewGaugeFunc("kafka_server_under_replicated_partitions", "Partitions the broker leads whose ISR is smaller than their replicas.", func() float64 {
+		return float64(underReplicatedPartitions(loader.Image(), nodeId))
+	})
+
+	if logs != nil {
+		registry.NewGaugeVecFunc("kafka_log_size_bytes", "Size of the partition logs, by topic and partition.", func() []metrics.Sample {
+			var samples []metrics.Sample
+			for partition, size := range logs.LogSizes() {
+				samples = append(samples, metrics.Sample{LabelValues: []string{partition.Topic, strconv.Itoa(int(partition.Partition))}, Value: float64(size)})
+			}
+			return samples
+		}, "topic", "partition")
+	}
+
+	purgatorySamples := func(count func(*purgatory.Purgatory) int) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			samples := make([]metrics.Sample, 0, len(purgatories))
+			for _, p := range purgatories {
+				samples = append(samples, metrics.Sample{LabelValues: []string{p.Name}, Value: float64(count(p))})
+			}
+			return samples
+		}
+	}
+	registry.NewGaugeVecFunc("kafka_server_purgatory_size", "Watch list entries of the purgatories, an operation watching several keys counts once per key.", purgatorySamples((*purgatory.Purgatory).Watched), "purgatory")
+	registry.NewGaugeVecFunc("kafka_server_purgatory_delayed_operations", "Operations waiting to complete or expire in the purgatories.", purgatorySamples((*purgatory.Purgatory).Delayed), "purgatory")
+}
+
+// underReplicatedPartitions counts the partitions led by nodeId with a replica out of the ISR
+func underReplicatedPartitions(image *metadata.Image, nodeId int32) int {
+	count := 0
+	for _, name := range image.TopicNames() {
+		topic, _ := image.Topic(name)
+		for _, partition := range topic.Partitions {
+			if partition.Leader == nodeId && len(partition.Isr) < len(partition.Replicas) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// failedErrorCounts counts the error of a request that failed without a response
+func failedErrorCounts(err error) map[int16]int {
+	var parseErr *RequestParseError
+	if errors.As(err, &parseErr) {
+		return map[int16]int{int16(parseErr.Code): 1}
+	}
+
+	return map[int16]int{int16(UNKNOWN): 1}
+}
+
+func apiKeyName(apiKey KafkaAPIKey) string {
+	if name, ok := KafkaAPIKeyNames[apiKey]; ok {
+		return name
+	}
+	return strconv.Itoa(int(apiKey))
+}
+
+func errorCodeName(code KafkaErrorCode) string {
+	if name, ok := KafkaErrorCodeNames[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
@@ -0,0 +1,124 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/network"
+)
+
+// nodeClient sends the requests of this node to other nodes of the cluster, over one PLAINTEXT connection per node.
+// Requests are sent one at a time and wait for their response. A node that could not be reached is not dialed again
+// before the retry backoff
+type nodeClient struct {
+	clientId        string
+	timeout         time.Duration
+	retryBackoff    time.Duration
+	maxResponseSize int32
+	now             func() time.Time
+
+	mutex         sync.Mutex
+	connections   map[int32]net.Conn
+	retryAfter    map[int32]time.Time
+	correlationId int32
+}
+
+func newNodeClient(clientId string, timeout time.Duration, retryBackoff time.Duration, maxResponseSize int32) *nodeClient {
+	return &nodeClient{
+		clientId:        clientId,
+		timeout:         timeout,
+		retryBackoff:    retryBackoff,
+		maxResponseSize: maxResponseSize,
+		now:             time.Now,
+		connections:     make(map[int32]net.Conn),
+		retryAfter:      make(map[int32]time.Time),
+	}
+}
+
+// close closes the connections to the nodes
+func (c *nodeClient) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for destination, connection := range c.connections {
+		connection.Close()
+		delete(c.connections, destination)
+	}
+}
+
+// send writes the request built by serialize to destination and reads its response, address is only called to dial
+// a new connection. timeout bounds the round trip on top of the timeout of the client, for requests the destination
+// may hold such as a Fetch with a max wait. A failed connection is closed and the destination backed off
+func (c *nodeClient) send(destination int32, address func() (string, error), apiKey KafkaAPIKey, apiVersion int16, timeout time.Duration, serialize func(RequestHeader) ([]byte, error)) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	if retryAfter, ok := c.retryAfter[destination]; ok && now.Before(retryAfter) {
+		return nil, fmt.Errorf("%d is backed off", destination)
+	}
+
+	response, err := c.roundTrip(destination, address, apiKey, apiVersion, serialize, now.Add(c.timeout+timeout))
+	if err != nil {
+		if connection, ok := c.connections[destination]; ok {
+			connection.Close()
+			delete(c.connections, destination)
+		}
+		c.retryAfter[destination] = now.Add(c.retryBackoff)
+		return nil, err
+	}
+
+	delete(c.retryAfter, destination)
+	return response, nil
+}
+
+func (c *nodeClient) roundTrip(destination int32, address func() (string, error), apiKey KafkaAPIKey, apiVersion int16, serialize func(RequestHeader) ([]byte, error), deadline time.Time) ([]byte, error) {
+	c.correlationId++
+	request, err := serialize(RequestHeader{
+		RequestApiKey:     int16(apiKey),
+		RequestApiVersion: apiVersion,
+		CorrelationId:     c.correlationId,
+		ClientId:          c.clientId,
+		TaggedFields:      map[string]string{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	connection, ok := c.connections[destination]
+	if !ok {
+		destinationAddress, err := address()
+		if err != nil {
+			return nil, err
+		}
+		connection, err = net.DialTimeout("tcp", destinationAddress, c.timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.connections[destination] = connection
+	}
+
+	if err := connection.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := connection.Write(request); err != nil {
+		return nil, err
+	}
+
+	response, err := network.ReadFrame(connection, c.maxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+
+	correlationId, _, err := parseResponseHeader(response)
+	if err != nil {
+		return nil, err
+	}
+	if correlationId != c.correlationId {
+		return nil, fmt.Errorf("correlation id %d instead of %d", correlationId, c.correlationId)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,348 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+type OffsetForLeaderPartition struct {
+	Partition          int32
+	CurrentLeaderEpoch int32
+	LeaderEpoch        int32
+	TaggedFields       map[string]string
+}
+
+type OffsetForLeaderTopic struct {
+	Topic        string
+	Partitions   []OffsetForLeaderPartition
+	TaggedFields map[string]string
+}
+
+type OffsetForLeaderEpochRequest struct {
+	Header       RequestHeader
+	ReplicaId    int32
+	Topics       []OffsetForLeaderTopic
+	TaggedFields map[string]string
+}
+
+func (r *OffsetForLeaderEpochRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *OffsetForLeaderEpochRequest) GetApiKey() KafkaAPIKey {
+	return OffsetForLeaderEpoch
+}
+
+func (r *OffsetForLeaderEpochRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *OffsetForLeaderEpochRequest) Validate() error {
+	if r.Header.RequestApiVersion != 4 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type EpochEndOffset struct {
+	ErrorCode    int16
+	Partition    int32
+	LeaderEpoch  int32
+	EndOffset    int64
+	TaggedFields map[string]string
+}
+
+type OffsetForLeaderTopicResult struct {
+	Topic        string
+	Partitions   []EpochEndOffset
+	TaggedFields map[string]string
+}
+
+type OffsetForLeaderEpochResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Topics        []OffsetForLeaderTopicResult
+	TaggedFields  map[string]string
+}
+
+func (r *OffsetForLeaderEpochResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *OffsetForLeaderEpochResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *OffsetForLeaderEpochResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, topic := range r.Topics {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *OffsetForLeaderEpochResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.Topic) + 32*len(topic.Partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Topic)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.Partition)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.EndOffset)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// OffsetForLeaderEpochHandler answers from the leader epoch cache of the partitions the broker leads
+type OffsetForLeaderEpochHandler struct {
+	// nil when the broker has no partition logs
+	replicas   *replica.Manager
+	authorizer acl.Authorizer
+}
+
+func (h *OffsetForLeaderEpochHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &OffsetForLeaderEpochRequest{}
+	req.Header = requestHeader
+
+	req.ReplicaId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse replica id from OffsetForLeaderEpoch request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from OffsetForLeaderEpoch request",
+		}
+	}
+
+	req.Topics = make([]OffsetForLeaderTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := OffsetForLeaderTopic{}
+
+		topic.Topic, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from OffsetForLeaderEpoch request at index %d", i),
+			}
+		}
+
+		partitionsLength, newIndex, err := parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from OffsetForLeaderEpoch request",
+			}
+		}
+		index = newIndex
+
+		topic.Partitions = make([]OffsetForLeaderPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := OffsetForLeaderPartition{}
+
+			partition.Partition, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition from OffsetForLeaderEpoch request",
+				}
+			}
+
+			partition.CurrentLeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse current leader epoch from OffsetForLeaderEpoch request",
+				}
+			}
+
+			partition.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse leader epoch from OffsetForLeaderEpoch request",
+				}
+			}
+
+			partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition tagged fields from OffsetForLeaderEpoch request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from OffsetForLeaderEpoch request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from OffsetForLeaderEpoch request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *OffsetForLeaderEpochHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*OffsetForLeaderEpochRequest)
+	if !ok {
+		return nil, fmt.Errorf("OffsetForLeaderEpochHandler received %T instead of *OffsetForLeaderEpochRequest", req)
+	}
+
+	response := &OffsetForLeaderEpochResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		Topics:        make([]OffsetForLeaderTopicResult, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	// Followers truncating their log must be brokers of the cluster, consumers only need to describe the topic
+	isFollower := apiReq.ReplicaId >= 0
+	clusterAuthorized := !isFollower || session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName)
+
+	for _, requestTopic := range apiReq.Topics {
+		errorCode := NONE
+		switch {
+		case !clusterAuthorized:
+			errorCode = CLUSTER_AUTHORIZATION_FAILED
+		case !isFollower && !session.authorize(h.authorizer, acl.DESCRIBE, acl.TOPIC, requestTopic.Topic):
+			errorCode = TOPIC_AUTHORIZATION_FAILED
+		case h.replicas == nil:
+			errorCode = NOT_LEADER_OR_FOLLOWER
+		}
+
+		topic := OffsetForLeaderTopicResult{
+			Topic:        requestTopic.Topic,
+			Partitions:   make([]EpochEndOffset, 0, len(requestTopic.Partitions)),
+			TaggedFields: make(map[string]string),
+		}
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := EpochEndOffset{
+				ErrorCode:    int16(errorCode),
+				Partition:    requestPartition.Partition,
+				LeaderEpoch:  storage.UNDEFINED_EPOCH,
+				EndOffset:    storage.UNDEFINED_EPOCH_OFFSET,
+				TaggedFields: make(map[string]string),
+			}
+
+			if errorCode == NONE {
+				topicPartition := storage.TopicPartition{Topic: requestTopic.Topic, Partition: requestPartition.Partition}
+				endOffset, err := h.replicas.LastOffsetForLeaderEpoch(topicPartition, requestPartition.CurrentLeaderEpoch, requestPartition.LeaderEpoch)
+				if err != nil {
+					partition.ErrorCode = int16(replicaErrorCode(err))
+				} else {
+					partition.LeaderEpoch, partition.EndOffset = endOffset.Epoch, endOffset.EndOffset
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
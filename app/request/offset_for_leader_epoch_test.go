@@ -0,0 +1,183 @@
+package request
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestOffsetForLeaderEpochParseRequestBody(t *testing.T) {
+	handler := OffsetForLeaderEpochHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x28, // MessageSize: 40
+		0x00, 0x17, // RequestApiKey: 23 (OffsetForLeaderEpoch)
+		0x00, 0x04, // RequestApiVersion: 4
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00, 0x00, 0x00, 0x02, // ReplicaId: 2
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Topic: "foo"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x01, // Partition: 1
+		0x00, 0x00, 0x00, 0x05, // CurrentLeaderEpoch: 5
+		0x00, 0x00, 0x00, 0x03, // LeaderEpoch: 3
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 23, RequestApiVersion: 4, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*OffsetForLeaderEpochRequest)
+	if !ok {
+		t.Fatalf("expected *OffsetForLeaderEpochRequest, got %T", got)
+	}
+
+	if gotReq.ReplicaId != 2 {
+		t.Errorf("ReplicaId mismatch: got %d, want 2", gotReq.ReplicaId)
+	}
+
+	want := []OffsetForLeaderTopic{
+		{
+			Topic:        "foo",
+			Partitions:   []OffsetForLeaderPartition{{Partition: 1, CurrentLeaderEpoch: 5, LeaderEpoch: 3, TaggedFields: map[string]string{}}},
+			TaggedFields: map[string]string{},
+		},
+	}
+	if !reflect.DeepEqual(gotReq.Topics, want) {
+		t.Errorf("Topics mismatch: got %+v, want %+v", gotReq.Topics, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestOffsetForLeaderEpochHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	_, loader, configs := newTestConfigs(t, now)
+	replicas := newTestReplicas(t, loader, configs)
+
+	// Broker 1 leads foo-0 in epoch 0
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	produced := make(chan map[storage.TopicPartition]replica.AppendResult, 1)
+	replicas.AppendRecords(0, 1, map[storage.TopicPartition][]byte{foo: newTestRecords()}, func(results map[storage.TopicPartition]replica.AppendResult) {
+		produced <- results
+	})
+	if results := <-produced; results[foo].Err != nil {
+		t.Fatal(results[foo].Err)
+	}
+
+	undefined := EpochEndOffset{ErrorCode: int16(UNKNOWN_TOPIC_OR_PARTITION), LeaderEpoch: -1, EndOffset: -1}
+	tests := []struct {
+		name               string
+		replicas           *replica.Manager
+		authorizer         acl.Authorizer
+		replicaId          int32
+		currentLeaderEpoch int32
+		want               []EpochEndOffset
+	}{
+		{"Consumer", replicas, acl.NewAclAuthorizer(nil, true), -1, -1, []EpochEndOffset{{LeaderEpoch: 0, EndOffset: 3}, undefined}},
+		{"Follower", replicas, acl.NewAclAuthorizer(nil, true), 2, 0, []EpochEndOffset{{LeaderEpoch: 0, EndOffset: 3}, undefined}},
+		{"Unknown leader epoch", replicas, acl.NewAclAuthorizer(nil, true), 2, 1, []EpochEndOffset{{ErrorCode: int16(UNKNOWN_LEADER_EPOCH), LeaderEpoch: -1, EndOffset: -1}, undefined}},
+		{"No replicas", nil, acl.NewAclAuthorizer(nil, true), 2, 0, []EpochEndOffset{
+			{ErrorCode: int16(NOT_LEADER_OR_FOLLOWER), LeaderEpoch: -1, EndOffset: -1}, {ErrorCode: int16(NOT_LEADER_OR_FOLLOWER), LeaderEpoch: -1, EndOffset: -1},
+		}},
+		{"Consumer not authorized", replicas, denyAllAuthorizer{}, -1, -1, []EpochEndOffset{
+			{ErrorCode: int16(TOPIC_AUTHORIZATION_FAILED), LeaderEpoch: -1, EndOffset: -1}, {ErrorCode: int16(TOPIC_AUTHORIZATION_FAILED), LeaderEpoch: -1, EndOffset: -1},
+		}},
+		{"Follower not authorized", replicas, denyAllAuthorizer{}, 2, 0, []EpochEndOffset{
+			{ErrorCode: int16(CLUSTER_AUTHORIZATION_FAILED), LeaderEpoch: -1, EndOffset: -1}, {ErrorCode: int16(CLUSTER_AUTHORIZATION_FAILED), LeaderEpoch: -1, EndOffset: -1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := OffsetForLeaderEpochHandler{replicas: tt.replicas, authorizer: tt.authorizer}
+			request := OffsetForLeaderEpochRequest{
+				Header:    RequestHeader{RequestApiKey: 23, RequestApiVersion: 4, CorrelationId: 7},
+				ReplicaId: tt.replicaId,
+				Topics: []OffsetForLeaderTopic{{Topic: "foo", Partitions: []OffsetForLeaderPartition{
+					{Partition: 0, CurrentLeaderEpoch: tt.currentLeaderEpoch, LeaderEpoch: 0},
+					{Partition: 1, CurrentLeaderEpoch: tt.currentLeaderEpoch, LeaderEpoch: 0},
+				}}},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*OffsetForLeaderEpochResponse)
+			if !ok {
+				t.Fatalf("expected *OffsetForLeaderEpochResponse, got %T", got)
+			}
+
+			if len(gotResp.Topics) != 1 || len(gotResp.Topics[0].Partitions) != 2 {
+				t.Fatalf("expected one topic with two partitions, got %+v", gotResp.Topics)
+			}
+			for i, partition := range gotResp.Topics[0].Partitions {
+				want := tt.want[i]
+				if partition.ErrorCode != want.ErrorCode || partition.LeaderEpoch != want.LeaderEpoch || partition.EndOffset != want.EndOffset {
+					t.Errorf("partition %d: got error %d, epoch %d and end offset %d, want %d, %d and %d", partition.Partition, partition.ErrorCode, partition.LeaderEpoch, partition.EndOffset, want.ErrorCode, want.LeaderEpoch, want.EndOffset)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetForLeaderEpochResponseSerialize(t *testing.T) {
+	response := OffsetForLeaderEpochResponse{
+		CorrelationId: 7,
+		ThrottleTime:  0,
+		Topics: []OffsetForLeaderTopicResult{
+			{
+				Topic:        "foo",
+				Partitions:   []EpochEndOffset{{ErrorCode: 0, Partition: 1, LeaderEpoch: 3, EndOffset: 10, TaggedFields: map[string]string{}}},
+				TaggedFields: map[string]string{},
+			},
+		},
+		TaggedFields: map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x24, // MessageSize: 36
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                   // Response header tagged fields
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime: 0
+		0x02,                // Topics array length: 1
+		0x04, 'f', 'o', 'o', // Topic: "foo"
+		0x02,       // Partitions array length: 1
+		0x00, 0x00, // ErrorCode: 0
+		0x00, 0x00, 0x00, 0x01, // Partition: 1
+		0x00, 0x00, 0x00, 0x03, // LeaderEpoch: 3
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A, // EndOffset: 10
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -0,0 +1,505 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+type ProducePartitionData struct {
+	Index int32
+	// The record batches, nil when the producer sent none
+	Records      []byte
+	TaggedFields map[string]string
+}
+
+type ProduceTopicData struct {
+	Name          string
+	PartitionData []ProducePartitionData
+	TaggedFields  map[string]string
+}
+
+type ProduceRequest struct {
+	Header          RequestHeader
+	TransactionalId *string
+	Acks            int16
+	TimeoutMs       int32
+	TopicData       []ProduceTopicData
+	TaggedFields    map[string]string
+}
+
+func (r *ProduceRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *ProduceRequest) GetApiKey() KafkaAPIKey {
+	return Produce
+}
+
+func (r *ProduceRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+// Validate accepts the flexible versions, 10 and 11 only add tagged fields the broker does not send
+func (r *ProduceRequest) Validate() error {
+	if r.Header.RequestApiVersion < 9 || r.Header.RequestApiVersion > 11 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type ProducePartitionResponse struct {
+	Index           int32
+	ErrorCode       int16
+	BaseOffset      int64
+	LogAppendTimeMs int64
+	LogStartOffset  int64
+	ErrorMessage    *string
+	TaggedFields    map[string]string
+}
+
+type ProduceTopicResponse struct {
+	Name               string
+	PartitionResponses []ProducePartitionResponse
+	TaggedFields       map[string]string
+}
+
+type ProduceResponse struct {
+	CorrelationId  int32
+	Responses      []ProduceTopicResponse
+	ThrottleTimeMs int32
+	TaggedFields   map[string]string
+}
+
+func (r *ProduceResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *ProduceResponse) setThrottleTime(throttleTimeMs int32) { r.ThrottleTimeMs = throttleTimeMs }
+
+func (r *ProduceResponse) errorCounts() map[int16]int {
+	counts := make(map[int16]int)
+	for _, topic := range r.Responses {
+		for _, partition := range topic.PartitionResponses {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *ProduceResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, topic := range r.Responses {
+		bufferSize += 8 + len(topic.Name)
+		for _, partition := range topic.PartitionResponses {
+			bufferSize += 48
+			if partition.ErrorMessage != nil {
+				bufferSize += len(*partition.ErrorMessage)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Responses)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Responses {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.PartitionResponses)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.PartitionResponses {
+			index, err = serializer.SerializeInt32(buffer, index, partition.Index)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, value := range []int64{partition.BaseOffset, partition.LogAppendTimeMs, partition.LogStartOffset} {
+				index, err = serializer.SerializeInt64(buffer, index, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// RecordErrors: the batches are accepted or rejected as a whole
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeCompactNullableString(buffer, index, partition.ErrorMessage)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTimeMs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// ProduceHandler appends the records of producers to the partitions the broker leads. The response of acks=-1
+// waits for the ISR in the purgatory of the replica manager, and acks=0 gets no response
+type ProduceHandler struct {
+	replicas *replica.Manager
+	// Verifies the partitions of transactions with their coordinator, nil without partition logs
+	verifier   *TransactionTransport
+	metrics    *topicMetrics
+	authorizer acl.Authorizer
+}
+
+func (h *ProduceHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &ProduceRequest{}
+	req.Header = requestHeader
+
+	// The body of the versions that are not supported is laid out differently
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	req.TransactionalId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err == nil {
+		req.Acks, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err == nil {
+		req.TimeoutMs, index, err = parser.ExtractInt32(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse Produce request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topic data length from Produce request",
+		}
+	}
+
+	req.TopicData = make([]ProduceTopicData, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := ProduceTopicData{}
+
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from Produce request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partition data length from Produce request",
+			}
+		}
+
+		topic.PartitionData = make([]ProducePartitionData, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := ProducePartitionData{}
+
+			partition.Index, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.Records, index, err = parseNullableRecords(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition data from Produce request at index %d", j),
+				}
+			}
+
+			topic.PartitionData = append(topic.PartitionData, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from Produce request",
+			}
+		}
+
+		req.TopicData = append(req.TopicData, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from Produce request",
+		}
+	}
+
+	return req, nil
+}
+
+// parseNullableRecords parses compact nullable records, null records are nil
+func parseNullableRecords(buffer []byte, index int) ([]byte, int, error) {
+	if index < len(buffer) && buffer[index] == 0 {
+		return nil, index + 1, nil
+	}
+	return parser.ExtractCompactBytes(buffer, index)
+}
+
+func (h *ProduceHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *ProduceHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*ProduceRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("ProduceHandler received %T instead of *ProduceRequest", req))
+		return
+	}
+
+	response := &ProduceResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		Responses:     make([]ProduceTopicResponse, 0, len(apiReq.TopicData)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	// The records of the partitions the client may write to, and where their results go in the response
+	records := map[storage.TopicPartition][]byte{}
+	positions := map[storage.TopicPartition][2]int{}
+	validAcks := apiReq.Acks == replica.ACKS_ALL || apiReq.Acks == 0 || apiReq.Acks == 1
+	transactionalAuthorized := apiReq.TransactionalId == nil || session.authorize(h.authorizer, acl.WRITE, acl.TRANSACTIONAL_ID, *apiReq.TransactionalId)
+
+	for i, requestTopic := range apiReq.TopicData {
+		topic := ProduceTopicResponse{Name: requestTopic.Name, PartitionResponses: []ProducePartitionResponse{}, TaggedFields: map[string]string{}}
+		authorized := session.authorize(h.authorizer, acl.WRITE, acl.TOPIC, requestTopic.Name)
+
+		for j, requestPartition := range requestTopic.PartitionData {
+			partition := ProducePartitionResponse{
+				Index:           requestPartition.Index,
+				ErrorCode:       int16(NONE),
+				BaseOffset:      -1,
+				LogAppendTimeMs: -1,
+				LogStartOffset:  -1,
+				TaggedFields:    map[string]string{},
+			}
+
+			topicPartition := storage.TopicPartition{Topic: requestTopic.Name, Partition: requestPartition.Index}
+			switch {
+			case !validAcks:
+				partition.ErrorCode = int16(INVALID_REQUIRED_ACKS)
+			case !transactionalAuthorized:
+				partition.ErrorCode = int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED)
+			case !authorized:
+				partition.ErrorCode = int16(TOPIC_AUTHORIZATION_FAILED)
+			// The internal topics are only written by their coordinators
+			case isInternalTopic(requestTopic.Name):
+				partition.ErrorCode = int16(INVALID_TOPIC_EXCEPTION)
+			case h.replicas == nil:
+				partition.ErrorCode = int16(NOT_LEADER_OR_FOLLOWER)
+			case len(requestPartition.Records) == 0:
+				partition.ErrorCode = int16(CORRUPT_MESSAGE)
+			default:
+				records[topicPartition] = requestPartition.Records
+				positions[topicPartition] = [2]int{i, j}
+			}
+
+			topic.PartitionResponses = append(topic.PartitionResponses, partition)
+		}
+
+		response.Responses = append(response.Responses, topic)
+	}
+
+	complete := func(results map[storage.TopicPartition]replica.AppendResult) {
+		for topicPartition, result := range results {
+			position := positions[topicPartition]
+			partition := &response.Responses[position[0]].PartitionResponses[position[1]]
+			partition.ErrorCode = int16(replicaErrorCode(result.Err))
+			partition.BaseOffset, partition.LogStartOffset = result.BaseOffset, result.LogStartOffset
+			partition.LogAppendTimeMs = result.LogAppendTime
+			if result.Err != nil {
+				message := result.Err.Error()
+				partition.ErrorMessage = &message
+				continue
+			}
+			h.metrics.recordBytesIn(topicPartition.Topic, len(records[topicPartition]))
+		}
+
+		// Producers that do not wait for acknowledgements read no response
+		if apiReq.Acks == 0 {
+			respond(nil, nil)
+			return
+		}
+		respond(response, nil)
+	}
+
+	appendRecords := func() {
+		if len(records) == 0 {
+			complete(nil)
+			return
+		}
+		h.replicas.AppendRecords(time.Duration(apiReq.TimeoutMs)*time.Millisecond, apiReq.Acks, records, complete)
+	}
+
+	// Like Kafka, the first transactional batch of a producer in a partition is only appended once the coordinator
+	// verified that the partition is part of the ongoing transaction, so that the late batch of a transaction that
+	// already ended does not leave one open
+	unverified := []storage.TopicPartition{}
+	var producerId int64
+	var producerEpoch int16
+	if apiReq.TransactionalId != nil && h.verifier != nil {
+		for topicPartition, batches := range records {
+			id, epoch, ok := storage.TransactionalProducer(batches)
+			if ok && !h.replicas.InTransaction(topicPartition, id, epoch) {
+				unverified = append(unverified, topicPartition)
+				producerId, producerEpoch = id, epoch
+			}
+		}
+	}
+	if len(unverified) == 0 {
+		appendRecords()
+		return
+	}
+
+	go func() {
+		errs, err := h.verifier.VerifyTransaction(*apiReq.TransactionalId, producerId, producerEpoch, unverified)
+		for _, topicPartition := range unverified {
+			verifyErr := err
+			if verifyErr == nil {
+				verifyErr = errs[topicPartition]
+			}
+			if verifyErr == nil {
+				continue
+			}
+			position := positions[topicPartition]
+			partition := &response.Responses[position[0]].PartitionResponses[position[1]]
+			partition.ErrorCode = int16(verificationErrorCode(verifyErr))
+			message := verifyErr.Error()
+			partition.ErrorMessage = &message
+			delete(records, topicPartition)
+		}
+		appendRecords()
+	}()
+}
+
+// verificationErrorCode maps the error of the verification of a partition of a transaction to the error of the
+// produce. Like Kafka, the errors of the coordinator the producer can not act on become the retriable
+// NOT_ENOUGH_REPLICAS
+func verificationErrorCode(err error) KafkaErrorCode {
+	switch {
+	case errors.Is(err, transaction.ErrInvalidTxnState):
+		return INVALID_TXN_STATE
+	case errors.Is(err, transaction.ErrProducerFenced):
+		return INVALID_PRODUCER_EPOCH
+	case errors.Is(err, transaction.ErrInvalidProducerIdMapping):
+		return INVALID_PRODUCER_ID_MAPPING
+	default:
+		return NOT_ENOUGH_REPLICAS
+	}
+}
+
+// replicaErrorCode maps the errors of the replica manager and of the partition logs to their error code
+func replicaErrorCode(err error) KafkaErrorCode {
+	switch {
+	case err == nil:
+		return NONE
+	case errors.Is(err, replica.ErrNotLeaderOrFollower):
+		return NOT_LEADER_OR_FOLLOWER
+	case errors.Is(err, replica.ErrUnknownTopicOrPartition):
+		return UNKNOWN_TOPIC_OR_PARTITION
+	case errors.Is(err, replica.ErrFencedLeaderEpoch):
+		return FENCED_LEADER_EPOCH
+	case errors.Is(err, replica.ErrUnknownLeaderEpoch):
+		return UNKNOWN_LEADER_EPOCH
+	case errors.Is(err, replica.ErrRequestTimedOut):
+		return REQUEST_TIMED_OUT
+	case errors.Is(err, replica.ErrNotEnoughReplicas):
+		return NOT_ENOUGH_REPLICAS
+	case errors.Is(err, replica.ErrNotEnoughReplicasAfterAppend):
+		return NOT_ENOUGH_REPLICAS_AFTER_APPEND
+	case errors.Is(err, replica.ErrOffsetOutOfRange), errors.Is(err, storage.ErrOffsetOutOfRange):
+		return OFFSET_OUT_OF_RANGE
+	case errors.Is(err, storage.ErrInvalidRecordBatch):
+		return CORRUPT_MESSAGE
+	case errors.Is(err, storage.ErrRecordTooLarge):
+		return MESSAGE_TOO_LARGE
+	case errors.Is(err, storage.ErrKafkaStorage):
+		return KAFKA_STORAGE_ERROR
+	case errors.Is(err, storage.ErrUnknownLog):
+		return NOT_LEADER_OR_FOLLOWER
+	case errors.Is(err, storage.ErrInvalidProducerEpoch):
+		return INVALID_PRODUCER_EPOCH
+	case errors.Is(err, storage.ErrOutOfOrderSequence):
+		return OUT_OF_ORDER_SEQUENCE_NUMBER
+	case errors.Is(err, storage.ErrDuplicateSequence):
+		return DUPLICATE_SEQUENCE_NUMBER
+	case errors.Is(err, storage.ErrTransactionCoordinatorFenced):
+		return TRANSACTION_COORDINATOR_FENCED
+	default:
+		return UNKNOWN
+	}
+}
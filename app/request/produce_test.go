@@ -0,0 +1,316 @@
+package request
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/metrics"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestProduceParseRequestBody(t *testing.T) {
+	handler := ProduceHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2C, // MessageSize: 44
+		0x00, 0x00, // RequestApiKey: 0 (Produce)
+		0x00, 0x09, // RequestApiVersion: 9
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00,       // TransactionalId: null
+		0xFF, 0xFF, // Acks: -1
+		0x00, 0x00, 0x03, 0xE8, // TimeoutMs: 1000
+		0x02,                // TopicData array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x03,                   // PartitionData array length (2 partitions + 1)
+		0x00, 0x00, 0x00, 0x00, // Index: 0
+		0x03, 0x01, 0x02, // Records: 2 bytes
+		0x00,                   // Partition tagged fields
+		0x00, 0x00, 0x00, 0x01, // Index: 1
+		0x00, // Records: null
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &ProduceRequest{
+		Header:    header,
+		Acks:      -1,
+		TimeoutMs: 1000,
+		TopicData: []ProduceTopicData{{
+			Name: "foo",
+			PartitionData: []ProducePartitionData{
+				{Index: 0, Records: []byte{0x01, 0x02}, TaggedFields: map[string]string{}},
+				{Index: 1, TaggedFields: map[string]string{}},
+			},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+
+	// Versions 10 and 11 only add tagged fields, version 8 is not flexible
+	for _, tt := range []struct {
+		version int16
+		want    error
+	}{{11, nil}, {8, &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}}} {
+		header.RequestApiVersion = tt.version
+		if _, err := handler.ParseRequestBody(header, input, 19); !reflect.DeepEqual(err, tt.want) {
+			t.Errorf("version %d: expected %v, got %v", tt.version, tt.want, err)
+		}
+	}
+}
+
+// newTestReplicas returns the replicas of broker 1, the leader and only replica of every partition of the loaded
+// metadata, see newTestConfigs
+func newTestReplicas(t *testing.T, loader *metadata.Loader, configs *config.Store) *replica.Manager {
+	t.Helper()
+
+	_, replicas := newTestLogReplicas(t, loader, configs)
+	return replicas
+}
+
+// newTestLogReplicas is newTestReplicas that also returns the partition logs of the replicas
+func newTestLogReplicas(t *testing.T, loader *metadata.Loader, configs *config.Store) (*storage.LogManager, *replica.Manager) {
+	t.Helper()
+
+	logs, err := storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logs.Close() })
+
+	replicas := replica.NewManager(replica.Config{
+		NodeId:        1,
+		MaxLag:        30 * time.Second,
+		FetchMaxWait:  50 * time.Millisecond,
+		FetchMaxBytes: 1 << 20,
+		FetchBackoff:  10 * time.Millisecond,
+		QuotaSamples:  11,
+		QuotaWindow:   time.Second,
+	}, logs, configs, NewReplicaTransport(1, "PLAINTEXT", loader, nil, time.Second, 10*time.Millisecond), slog.New(slog.DiscardHandler))
+	t.Cleanup(replicas.Shutdown)
+	loader.Subscribe(replicas.ApplyImage)
+	return logs, replicas
+}
+
+// newTestRecords returns a record batch header of 3 records without the records
+func newTestRecords() []byte {
+	batch := make([]byte, 61)
+	batch[11] = 49 // BatchLength
+	batch[16] = 2  // Magic
+	batch[26] = 2  // LastOffsetDelta
+	// No producer
+	copy(batch[43:57], bytes.Repeat([]byte{0xff}, 14))
+	return batch
+}
+
+func TestProduceHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	_, loader, configs := newTestConfigs(t, now)
+	replicas := newTestReplicas(t, loader, configs)
+	batch := newTestRecords()
+
+	// Anonymous can not write with the transactional id secret
+	secretDenied := acl.NewAclAuthorizer(nil, true)
+	secretDenied.Load([]acl.Binding{
+		{ResourceType: acl.TRANSACTIONAL_ID, ResourceName: "secret", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.WRITE, PermissionType: acl.DENY},
+	})
+
+	tests := []struct {
+		name            string
+		replicas        *replica.Manager
+		authorizer      acl.Authorizer
+		transactionalId *string
+		acks            int16
+		topic           string
+		records         []byte
+		want            KafkaErrorCode
+		baseOffset      int64
+	}{
+		{"Invalid acks", replicas, acl.NewAclAuthorizer(nil, true), nil, 2, "foo", batch, INVALID_REQUIRED_ACKS, -1},
+		{"Not authorized", replicas, denyAllAuthorizer{}, nil, -1, "foo", batch, TOPIC_AUTHORIZATION_FAILED, -1},
+		{"Transactional id not authorized", replicas, secretDenied, stringPtr("secret"), -1, "foo", batch, TRANSACTIONAL_ID_AUTHORIZATION_FAILED, -1},
+		{"Internal topic", replicas, acl.NewAclAuthorizer(nil, true), nil, -1, CONSUMER_OFFSETS_TOPIC, batch, INVALID_TOPIC_EXCEPTION, -1},
+		{"No replicas", nil, acl.NewAclAuthorizer(nil, true), nil, -1, "foo", batch, NOT_LEADER_OR_FOLLOWER, -1},
+		{"No records", replicas, acl.NewAclAuthorizer(nil, true), nil, -1, "foo", nil, CORRUPT_MESSAGE, -1},
+		{"Unknown topic", replicas, acl.NewAclAuthorizer(nil, true), nil, -1, "baz", batch, UNKNOWN_TOPIC_OR_PARTITION, -1},
+		{"Acks all", replicas, acl.NewAclAuthorizer(nil, true), nil, -1, "foo", batch, NONE, 0},
+		{"Acks one", replicas, acl.NewAclAuthorizer(nil, true), nil, 1, "foo", batch, NONE, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &ProduceHandler{replicas: tt.replicas, authorizer: tt.authorizer}
+			request := &ProduceRequest{
+				Header:          RequestHeader{RequestApiKey: 0, RequestApiVersion: 9, CorrelationId: 7},
+				TransactionalId: tt.transactionalId,
+				Acks:            tt.acks,
+				TimeoutMs:       1000,
+				TopicData:       []ProduceTopicData{{Name: tt.topic, PartitionData: []ProducePartitionData{{Index: 0, Records: tt.records}}}},
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			response := got.(*ProduceResponse)
+			if len(response.Responses) != 1 || len(response.Responses[0].PartitionResponses) != 1 {
+				t.Fatalf("expected one partition, got %+v", response.Responses)
+			}
+			partition := response.Responses[0].PartitionResponses[0]
+			if partition.ErrorCode != int16(tt.want) || partition.BaseOffset != tt.baseOffset {
+				t.Errorf("expected error %d at base offset %d, got %+v", tt.want, tt.baseOffset, partition)
+			}
+		})
+	}
+
+	// Producers that do not wait for acknowledgements get no response
+	topicMetrics := newTopicMetrics(metrics.NewRegistry())
+	handler := &ProduceHandler{replicas: replicas, metrics: topicMetrics, authorizer: acl.NewAclAuthorizer(nil, true)}
+	got, err := handler.Handle(NewSession("127.0.0.1"), &ProduceRequest{
+		Header:    RequestHeader{RequestApiKey: 0, RequestApiVersion: 9, CorrelationId: 8},
+		TopicData: []ProduceTopicData{{Name: "foo", PartitionData: []ProducePartitionData{{Index: 0, Records: batch}}}},
+	})
+	if err != nil || got != nil {
+		t.Fatalf("expected no response to acks=0, got %+v and %v", got, err)
+	}
+	if bytesIn := topicMetrics.bytesIn.Value("foo"); bytesIn != float64(len(batch)) {
+		t.Errorf("expected %d bytes in for foo, got %v", len(batch), bytesIn)
+	}
+
+	// Consumers read the records the producers appended
+	foo, _ := loader.Image().Topic("foo")
+	fetchHandler := &FetchHandler{loader: loader, replicas: replicas, metrics: topicMetrics, authorizer: acl.NewAclAuthorizer(nil, true), now: time.Now}
+	fetched, err := fetchHandler.Handle(NewSession("127.0.0.1"), &FetchRequest{
+		Header:    RequestHeader{RequestApiKey: 1, RequestApiVersion: 17, CorrelationId: 9},
+		ReplicaId: -1,
+		MaxBytes:  1 << 20,
+		Topics:    []FetchTopic{{TopicId: foo.Id, Partitions: []FetchPartition{{Partition: 0, CurrentLeaderEpoch: -1, FetchOffset: 0, PartitionMaxBytes: 1 << 20}}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	partition := fetched.(*FetchResponse).Responses[0].Partitions[0]
+	if partition.ErrorCode != int16(NONE) || partition.HighWatermark != 9 || len(partition.Records) != 3*len(batch) {
+		t.Errorf("expected the 3 batches below the high watermark 9, got %+v", partition)
+	}
+	if bytesOut := topicMetrics.bytesOut.Value("foo"); bytesOut != float64(3*len(batch)) {
+		t.Errorf("expected %d bytes out for foo, got %v", 3*len(batch), bytesOut)
+	}
+
+	// Consumers before version 13 name the topics, and get their names back
+	fetched, err = fetchHandler.Handle(NewSession("127.0.0.1"), &FetchRequest{
+		Header:    RequestHeader{RequestApiKey: 1, RequestApiVersion: 12, CorrelationId: 10},
+		ReplicaId: -1,
+		MaxBytes:  1 << 20,
+		Topics: []FetchTopic{
+			{Topic: "foo", Partitions: []FetchPartition{{Partition: 0, CurrentLeaderEpoch: -1, FetchOffset: 3, PartitionMaxBytes: 1 << 20}}},
+			{Topic: "baz", Partitions: []FetchPartition{{Partition: 0, CurrentLeaderEpoch: -1, FetchOffset: 0, PartitionMaxBytes: 1 << 20}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	responses := fetched.(*FetchResponse).Responses
+	if partition := responses[0].Partitions[0]; responses[0].Topic != "foo" || partition.ErrorCode != int16(NONE) || len(partition.Records) != 2*len(batch) {
+		t.Errorf("expected the 2 batches of foo from offset 3, got %s %+v", responses[0].Topic, partition)
+	}
+	if partition := responses[1].Partitions[0]; responses[1].Topic != "baz" || partition.ErrorCode != int16(UNKNOWN_TOPIC_OR_PARTITION) {
+		t.Errorf("expected baz to be unknown, got %s %+v", responses[1].Topic, partition)
+	}
+
+	serialized, err := fetched.Serialize(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The response starts with its header, the throttle time, the error code, the session id and the topics
+	if !bytes.HasPrefix(serialized[9+10:], []byte{0x03, 0x04, 'f', 'o', 'o'}) {
+		t.Errorf("expected the topics of the response to start with foo, got %v", serialized[9+10:])
+	}
+}
+
+func TestProduceVerifiesTransactions(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+	verifier := NewTransactionTransport(1, "PLAINTEXT", loader, replicas, time.Second, 10*time.Millisecond)
+	verifier.coordinator = coordinator
+
+	session := NewSession("127.0.0.1")
+	initialized, err := (&InitProducerIdHandler{coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &InitProducerIdRequest{
+		Header:               RequestHeader{RequestApiKey: 22, RequestApiVersion: 4, CorrelationId: 1},
+		TransactionalId:      stringPtr("txn"),
+		TransactionTimeoutMs: 60000,
+		ProducerId:           -1,
+		ProducerEpoch:        -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	producerId, producerEpoch := initialized.(*InitProducerIdResponse).ProducerId, initialized.(*InitProducerIdResponse).ProducerEpoch
+
+	handler := &ProduceHandler{replicas: replicas, verifier: verifier, authorizer: acl.NewAclAuthorizer(nil, true)}
+	produce := func() ProducePartitionResponse {
+		t.Helper()
+
+		got, err := handler.Handle(session, &ProduceRequest{
+			Header:          RequestHeader{RequestApiKey: 0, RequestApiVersion: 9, CorrelationId: 7},
+			TransactionalId: stringPtr("txn"),
+			Acks:            -1,
+			TimeoutMs:       1000,
+			TopicData:       []ProduceTopicData{{Name: "foo", PartitionData: []ProducePartitionData{{Index: 0, Records: storage.NewTransactionalBatch(producerId, producerEpoch, 1_000, []storage.Record{{Value: []byte("a")}})}}}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return got.(*ProduceResponse).Responses[0].PartitionResponses[0]
+	}
+
+	// foo-0 is not part of the transaction yet
+	if partition := produce(); partition.ErrorCode != int16(INVALID_TXN_STATE) {
+		t.Errorf("expected INVALID_TXN_STATE before the partition is added, got %+v", partition)
+	}
+
+	if _, err := (&AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)}).Handle(session, &AddPartitionsToTxnRequest{
+		Header:          RequestHeader{RequestApiKey: 24, RequestApiVersion: 3, CorrelationId: 2},
+		TransactionalId: "txn",
+		ProducerId:      producerId,
+		ProducerEpoch:   producerEpoch,
+		Topics:          []AddPartitionsToTxnTopic{{Name: "foo", Partitions: []int32{0}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if partition := produce(); partition.ErrorCode != int16(NONE) || partition.BaseOffset != 0 {
+		t.Errorf("expected the verified batch at offset 0, got %+v", partition)
+	}
+	if !replicas.InTransaction(storage.TopicPartition{Topic: "foo", Partition: 0}, producerId, producerEpoch) {
+		t.Errorf("expected the transaction to be ongoing in foo-0")
+	}
+}
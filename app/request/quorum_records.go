@@ -0,0 +1,344 @@
+package request
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+var errInvalidRecordBatch = errors.New("invalid record batch")
+
+// The layout of a v2 record batch, the entries of the metadata log are sent as one batch each
+const (
+	recordBatchHeaderSize = 61
+	recordBatchMagic      = 2
+	// The batch length counts the bytes after it, the CRC covers the bytes from the attributes on
+	recordBatchLengthOffset     = 8
+	recordBatchEpochOffset      = 12
+	recordBatchMagicOffset      = 16
+	recordBatchCrcOffset        = 17
+	recordBatchAttributesOffset = 21
+	controlBatchAttribute       = 0x20
+)
+
+// The types of the control records of the metadata log, the key of the record after its version
+const (
+	leaderChangeControlType int16 = 2
+	kraftVotersControlType  int16 = 6
+)
+
+var recordBatchCrcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeQuorumEntries writes the entries of the metadata log as record batches of one record, the batch carries the
+// offset and the epoch of the entry. Control entries are control batches, those changing the voter set hold a
+// VotersRecord
+func encodeQuorumEntries(entries []raft.Entry) ([]byte, error) {
+	records := []byte{}
+	for _, entry := range entries {
+		var key, value []byte
+		if entry.Control {
+			controlType := leaderChangeControlType
+			if entry.Voters != nil {
+				controlType = kraftVotersControlType
+			}
+			key = binary.BigEndian.AppendUint32(nil, uint32(controlType))
+		}
+		value = entry.Data
+		if entry.Voters != nil {
+			encoded, err := encodeVotersRecord(entry.Voters)
+			if err != nil {
+				return nil, err
+			}
+			value = encoded
+		}
+
+		records = appendRecordBatch(records, entry, key, value)
+	}
+
+	return records, nil
+}
+
+func appendRecordBatch(buffer []byte, entry raft.Entry, key []byte, value []byte) []byte {
+	record := []byte{0}                     // Attributes
+	record = binary.AppendVarint(record, 0) // TimestampDelta
+	record = binary.AppendVarint(record, 0) // OffsetDelta
+	for _, field := range [][]byte{key, value} {
+		if field == nil {
+			record = binary.AppendVarint(record, -1)
+		} else {
+			record = binary.AppendVarint(record, int64(len(field)))
+			record = append(record, field...)
+		}
+	}
+	record = binary.AppendVarint(record, 0) // Headers
+
+	attributes := uint16(0)
+	if entry.Control {
+		attributes |= controlBatchAttribute
+	}
+
+	start := len(buffer)
+	buffer = binary.BigEndian.AppendUint64(buffer, uint64(entry.Offset))
+	buffer = binary.BigEndian.AppendUint32(buffer, 0) // BatchLength, filled in below
+	buffer = binary.BigEndian.AppendUint32(buffer, uint32(entry.Epoch))
+	buffer = append(buffer, recordBatchMagic)
+	buffer = binary.BigEndian.AppendUint32(buffer, 0) // CRC, filled in below
+	buffer = binary.BigEndian.AppendUint16(buffer, attributes)
+	buffer = binary.BigEndian.AppendUint32(buffer, 0)          // LastOffsetDelta
+	buffer = binary.BigEndian.AppendUint64(buffer, 0)          // BaseTimestamp
+	buffer = binary.BigEndian.AppendUint64(buffer, 0)          // MaxTimestamp
+	buffer = binary.BigEndian.AppendUint64(buffer, ^uint64(0)) // ProducerId: -1
+	buffer = binary.BigEndian.AppendUint16(buffer, ^uint16(0)) // ProducerEpoch: -1
+	buffer = binary.BigEndian.AppendUint32(buffer, ^uint32(0)) // BaseSequence: -1
+	buffer = binary.BigEndian.AppendUint32(buffer, 1)          // Records count
+	buffer = binary.AppendVarint(buffer, int64(len(record)))
+	buffer = append(buffer, record...)
+
+	batch := buffer[start:]
+	binary.BigEndian.PutUint32(batch[recordBatchLengthOffset:], uint32(len(batch)-recordBatchLengthOffset-4))
+	binary.BigEndian.PutUint32(batch[recordBatchCrcOffset:], crc32.Checksum(batch[recordBatchAttributesOffset:], recordBatchCrcTable))
+	return buffer
+}
+
+// decodeQuorumEntries reads the record batches written by encodeQuorumEntries
+func decodeQuorumEntries(records []byte) ([]raft.Entry, error) {
+	entries := []raft.Entry{}
+	for index := 0; index < len(records); {
+		if len(records)-index < recordBatchHeaderSize {
+			return nil, fmt.Errorf("%w: %d bytes left for a batch header", errInvalidRecordBatch, len(records)-index)
+		}
+
+		length := int(binary.BigEndian.Uint32(records[index+recordBatchLengthOffset:]))
+		end := index + recordBatchLengthOffset + 4 + length
+		if length < recordBatchHeaderSize-recordBatchLengthOffset-4 || end > len(records) {
+			return nil, fmt.Errorf("%w: batch length %d", errInvalidRecordBatch, length)
+		}
+		batch := records[index:end]
+		index = end
+
+		if batch[recordBatchMagicOffset] != recordBatchMagic ||
+			crc32.Checksum(batch[recordBatchAttributesOffset:], recordBatchCrcTable) != binary.BigEndian.Uint32(batch[recordBatchCrcOffset:]) {
+			return nil, fmt.Errorf("%w: corrupt batch at offset %d", errInvalidRecordBatch, int64(binary.BigEndian.Uint64(batch)))
+		}
+
+		entry := raft.Entry{
+			Offset:  int64(binary.BigEndian.Uint64(batch)),
+			Epoch:   int32(binary.BigEndian.Uint32(batch[recordBatchEpochOffset:])),
+			Control: binary.BigEndian.Uint16(batch[recordBatchAttributesOffset:])&controlBatchAttribute != 0,
+		}
+		key, value, err := parseSingleRecord(batch[recordBatchHeaderSize:])
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Control && len(key) == 4 && int16(binary.BigEndian.Uint16(key[2:])) == kraftVotersControlType {
+			entry.Voters, err = decodeVotersRecord(value)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			entry.Data = value
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// parseSingleRecord returns the key and the value of the only record of a batch
+func parseSingleRecord(record []byte) ([]byte, []byte, error) {
+	length, read := binary.Varint(record)
+	if read <= 0 || length < 0 || int64(len(record)-read) < length {
+		return nil, nil, fmt.Errorf("%w: invalid record length", errInvalidRecordBatch)
+	}
+	record = record[read : read+int(length)]
+
+	// Attributes, then the timestamp and offset deltas
+	index := 1
+	for range 2 {
+		if _, read := binary.Varint(record[min(index, len(record)):]); read <= 0 {
+			return nil, nil, fmt.Errorf("%w: invalid record", errInvalidRecordBatch)
+		} else {
+			index += read
+		}
+	}
+
+	fields := [][]byte{}
+	for range 2 {
+		if index > len(record) {
+			return nil, nil, fmt.Errorf("%w: invalid record", errInvalidRecordBatch)
+		}
+		size, read := binary.Varint(record[index:])
+		if read <= 0 || size < -1 || int64(len(record)-index-read) < size {
+			return nil, nil, fmt.Errorf("%w: invalid record field", errInvalidRecordBatch)
+		}
+		index += read
+
+		var field []byte
+		if size >= 0 {
+			field = append([]byte{}, record[index:index+int(size)]...)
+			index += int(size)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields[0], fields[1], nil
+}
+
+// encodeVotersRecord writes a voter set as the VotersRecord of KIP-853
+func encodeVotersRecord(voters []raft.Voter) ([]byte, error) {
+	bufferSize := 16
+	for _, voter := range voters {
+		bufferSize += 32
+		for _, endpoint := range voter.Endpoints {
+			bufferSize += 16 + len(endpoint.Name) + len(endpoint.Host)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializer.SerializeInt16(buffer, 0, 0) // Version
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(voters)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, voter := range voters {
+		index, err = serializer.SerializeInt32(buffer, index, voter.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUUID(buffer, index, voter.DirectoryId)
+		if err != nil {
+			return nil, err
+		}
+
+		listeners := make([]QuorumListener, 0, len(voter.Endpoints))
+		for _, endpoint := range voter.Endpoints {
+			listeners = append(listeners, QuorumListener{Name: endpoint.Name, Host: endpoint.Host, Port: endpoint.Port, TaggedFields: map[string]string{}})
+		}
+		index, err = serializeQuorumListeners(buffer, index, listeners)
+		if err != nil {
+			return nil, err
+		}
+
+		// KRaftVersionFeature: the voter supports kraft.version 0 to 1
+		index, err = serializer.SerializeInt16(buffer, index, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer[:index], nil
+}
+
+func decodeVotersRecord(buffer []byte) ([]raft.Voter, error) {
+	_, index, err := parser.ExtractInt16(buffer, 0) // Version
+	if err != nil {
+		return nil, fmt.Errorf("%w: voters record version: %w", errInvalidRecordBatch, err)
+	}
+
+	votersLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || votersLength < 0 {
+		return nil, fmt.Errorf("%w: voters record length", errInvalidRecordBatch)
+	}
+
+	voters := make([]raft.Voter, 0, votersLength)
+	for i := 0; i < votersLength; i++ {
+		voter := raft.Voter{}
+
+		voter.Id, index, err = parser.ExtractInt32(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("%w: voter id: %w", errInvalidRecordBatch, err)
+		}
+
+		voter.DirectoryId, index, err = parser.ExtractUUID(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("%w: voter directory id: %w", errInvalidRecordBatch, err)
+		}
+
+		var listeners []QuorumListener
+		listeners, index, err = parseQuorumListeners(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("%w: voter endpoints: %w", errInvalidRecordBatch, err)
+		}
+		voter.Endpoints = make([]raft.Endpoint, 0, len(listeners))
+		for _, listener := range listeners {
+			voter.Endpoints = append(voter.Endpoints, raft.Endpoint{Name: listener.Name, Host: listener.Host, Port: listener.Port})
+		}
+
+		// KRaftVersionFeature
+		for range 2 {
+			_, index, err = parser.ExtractInt16(buffer, index)
+			if err != nil {
+				return nil, fmt.Errorf("%w: voter kraft.version: %w", errInvalidRecordBatch, err)
+			}
+		}
+		for range 2 {
+			_, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, fmt.Errorf("%w: voter tagged fields: %w", errInvalidRecordBatch, err)
+			}
+		}
+
+		voters = append(voters, voter)
+	}
+
+	return voters, nil
+}
+
+// encodeQuorumSnapshot writes a snapshot as the record batches of its data, then of its voter set when it has one
+func encodeQuorumSnapshot(snapshot raft.Snapshot) ([]byte, error) {
+	entries := []raft.Entry{{Offset: snapshot.Id.EndOffset, Epoch: snapshot.Id.Epoch, Data: snapshot.Data}}
+	if snapshot.Voters != nil {
+		entries = append(entries, raft.Entry{Offset: snapshot.Id.EndOffset, Epoch: snapshot.Id.Epoch, Control: true, Voters: snapshot.Voters})
+	}
+	return encodeQuorumEntries(entries)
+}
+
+func decodeQuorumSnapshot(snapshotId raft.SnapshotId, records []byte) (*raft.Snapshot, error) {
+	entries, err := decodeQuorumEntries(records)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &raft.Snapshot{Id: snapshotId}
+	for _, entry := range entries {
+		if entry.Control {
+			snapshot.Voters = entry.Voters
+		} else {
+			snapshot.Data = entry.Data
+		}
+	}
+	return snapshot, nil
+}
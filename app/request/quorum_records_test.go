@@ -0,0 +1,41 @@
+package request
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+func TestQuorumEntriesRoundTrip(t *testing.T) {
+	entries := []raft.Entry{
+		{Offset: 0, Epoch: 1, Control: true},
+		{Offset: 1, Epoch: 1, Data: []byte("record")},
+		{Offset: 2, Epoch: 2, Control: true, Voters: []raft.Voter{
+			{Id: 1, DirectoryId: "00000000-0000-0000-0000-000000000001", Endpoints: []raft.Endpoint{{Name: "CONTROLLER", Host: "localhost", Port: 9093}}},
+			{Id: 2, DirectoryId: "00000000-0000-0000-0000-000000000002", Endpoints: []raft.Endpoint{}},
+		}},
+	}
+
+	records, err := encodeQuorumEntries(entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := decodeQuorumEntries(records)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("decoded %+v, want %+v", got, entries)
+	}
+
+	// A corrupted batch fails its CRC
+	records[len(records)-1] ^= 0xFF
+	if _, err := decodeQuorumEntries(records); !errors.Is(err, errInvalidRecordBatch) {
+		t.Errorf("expected errInvalidRecordBatch, got %v", err)
+	}
+	if _, err := decodeQuorumEntries(records[:recordBatchHeaderSize-1]); !errors.Is(err, errInvalidRecordBatch) {
+		t.Errorf("expected errInvalidRecordBatch for a truncated batch, got %v", err)
+	}
+}
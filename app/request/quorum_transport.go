@@ -0,0 +1,364 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+// quorumMaxResponseSize bounds the responses the quorum transport reads, a snapshot is sent in one response
+const quorumMaxResponseSize = 1 << 30
+
+// QuorumTransport sends the requests of a node of the metadata quorum to the other voters, over one connection per
+// voter to its listener named listenerName. The quorum only uses PLAINTEXT controller listeners.
+// A voter that could not be reached is not dialed again before the retry backoff, so that it does not hold back the
+// requests to the other voters
+type QuorumTransport struct {
+	nodeId       int32
+	listenerName string
+	// voters is the latest voter set of the node, it tells where the voters are
+	voters func() []raft.Voter
+	client *nodeClient
+}
+
+func NewQuorumTransport(nodeId int32, listenerName string, voters func() []raft.Voter, timeout time.Duration, retryBackoff time.Duration) *QuorumTransport {
+	return &QuorumTransport{
+		nodeId:       nodeId,
+		listenerName: listenerName,
+		voters:       voters,
+		client:       newNodeClient(fmt.Sprintf("raft-client-%d", nodeId), timeout, retryBackoff, quorumMaxResponseSize),
+	}
+}
+
+// Close closes the connections to the voters
+func (t *QuorumTransport) Close() {
+	t.client.close()
+}
+
+func (t *QuorumTransport) Vote(destination int32, request raft.VoteRequest) (raft.VoteResponse, error) {
+	voter, err := t.voter(destination)
+	if err != nil {
+		return raft.VoteResponse{}, err
+	}
+
+	req := &VoteRequest{
+		VoterId: destination,
+		Topics: []VoteTopic{{
+			TopicName: clusterMetadataTopic,
+			Partitions: []VotePartition{{
+				PartitionIndex:     0,
+				ReplicaEpoch:       request.CandidateEpoch,
+				ReplicaId:          request.CandidateId,
+				ReplicaDirectoryId: request.CandidateDirectoryId,
+				VoterDirectoryId:   voter.DirectoryId,
+				LastOffsetEpoch:    request.LastOffsetEpoch,
+				LastOffset:         request.LastOffset,
+				TaggedFields:       map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	buffer, err := t.send(voter, Vote, 1, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return raft.VoteResponse{}, err
+	}
+
+	response, err := parseQuorumEpochResponse(Vote, buffer)
+	if err != nil {
+		return raft.VoteResponse{}, err
+	}
+	partition, err := response.metadataPartitionResult()
+	if err != nil {
+		return raft.VoteResponse{}, err
+	}
+
+	return raft.VoteResponse{
+		ErrorCode:   partition.ErrorCode,
+		LeaderEpoch: partition.LeaderEpoch,
+		LeaderId:    partition.LeaderId,
+		VoteGranted: partition.VoteGranted,
+	}, nil
+}
+
+func (t *QuorumTransport) BeginQuorumEpoch(destination int32, request raft.BeginQuorumEpochRequest) (raft.BeginQuorumEpochResponse, error) {
+	voter, err := t.voter(destination)
+	if err != nil {
+		return raft.BeginQuorumEpochResponse{}, err
+	}
+
+	req := &BeginQuorumEpochRequest{
+		VoterId: destination,
+		Topics: []BeginQuorumEpochTopic{{
+			TopicName: clusterMetadataTopic,
+			Partitions: []BeginQuorumEpochPartition{{
+				PartitionIndex:   0,
+				VoterDirectoryId: voter.DirectoryId,
+				LeaderId:         request.LeaderId,
+				LeaderEpoch:      request.LeaderEpoch,
+				TaggedFields:     map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		LeaderEndpoints: t.leaderEndpoints(),
+		TaggedFields:    map[string]string{},
+	}
+
+	buffer, err := t.send(voter, BeginQuorumEpoch, 1, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return raft.BeginQuorumEpochResponse{}, err
+	}
+
+	response, err := parseQuorumEpochResponse(BeginQuorumEpoch, buffer)
+	if err != nil {
+		return raft.BeginQuorumEpochResponse{}, err
+	}
+	partition, err := response.metadataPartitionResult()
+	if err != nil {
+		return raft.BeginQuorumEpochResponse{}, err
+	}
+
+	return raft.BeginQuorumEpochResponse{ErrorCode: partition.ErrorCode, LeaderEpoch: partition.LeaderEpoch, LeaderId: partition.LeaderId}, nil
+}
+
+func (t *QuorumTransport) EndQuorumEpoch(destination int32, request raft.EndQuorumEpochRequest) (raft.EndQuorumEpochResponse, error) {
+	voter, err := t.voter(destination)
+	if err != nil {
+		return raft.EndQuorumEpochResponse{}, err
+	}
+
+	candidates := make([]EndQuorumEpochCandidate, 0, len(request.PreferredSuccessors))
+	for _, successor := range request.PreferredSuccessors {
+		directoryId := zeroUuid
+		if candidate, err := t.voter(successor); err == nil {
+			directoryId = candidate.DirectoryId
+		}
+		candidates = append(candidates, EndQuorumEpochCandidate{CandidateId: successor, CandidateDirectoryId: directoryId, TaggedFields: map[string]string{}})
+	}
+
+	req := &EndQuorumEpochRequest{
+		Topics: []EndQuorumEpochTopic{{
+			TopicName: clusterMetadataTopic,
+			Partitions: []EndQuorumEpochPartition{{
+				PartitionIndex:      0,
+				LeaderId:            request.LeaderId,
+				LeaderEpoch:         request.LeaderEpoch,
+				PreferredCandidates: candidates,
+				TaggedFields:        map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		LeaderEndpoints: t.leaderEndpoints(),
+		TaggedFields:    map[string]string{},
+	}
+
+	buffer, err := t.send(voter, EndQuorumEpoch, 1, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return raft.EndQuorumEpochResponse{}, err
+	}
+
+	response, err := parseQuorumEpochResponse(EndQuorumEpoch, buffer)
+	if err != nil {
+		return raft.EndQuorumEpochResponse{}, err
+	}
+	partition, err := response.metadataPartitionResult()
+	if err != nil {
+		return raft.EndQuorumEpochResponse{}, err
+	}
+
+	return raft.EndQuorumEpochResponse{ErrorCode: partition.ErrorCode, LeaderEpoch: partition.LeaderEpoch, LeaderId: partition.LeaderId}, nil
+}
+
+func (t *QuorumTransport) Fetch(destination int32, request raft.FetchRequest) (raft.FetchResponse, error) {
+	voter, err := t.voter(destination)
+	if err != nil {
+		return raft.FetchResponse{}, err
+	}
+
+	req := &FetchRequest{
+		ReplicaId:      request.ReplicaId,
+		ReplicaEpoch:   -1,
+		MaxWaitMs:      0,
+		MinBytes:       0,
+		MaxBytes:       quorumMaxResponseSize,
+		IsolationLevel: 0,
+		SessionId:      0,
+		SessionEpoch:   -1,
+		Topics: []FetchTopic{{
+			TopicId: metadataTopicId,
+			Partitions: []FetchPartition{{
+				Partition:          0,
+				CurrentLeaderEpoch: request.CurrentLeaderEpoch,
+				FetchOffset:        request.FetchOffset,
+				LastFetchedEpoch:   request.LastFetchedEpoch,
+				LogStartOffset:     -1,
+				PartitionMaxBytes:  quorumMaxResponseSize,
+				ReplicaDirectoryId: request.ReplicaDirectoryId,
+				TaggedFields:       map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		ForgottenTopics: []FetchForgottenTopic{},
+		TaggedFields:    map[string]string{},
+	}
+
+	buffer, err := t.send(voter, Fetch, 17, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return raft.FetchResponse{}, err
+	}
+
+	response, err := parseFetchResponse(buffer)
+	if err != nil {
+		return raft.FetchResponse{}, err
+	}
+	if response.ErrorCode != int16(NONE) {
+		return raft.FetchResponse{}, fmt.Errorf("Fetch failed: %s", KafkaErrorCodeNames[KafkaErrorCode(response.ErrorCode)])
+	}
+	if len(response.Responses) != 1 || len(response.Responses[0].Partitions) != 1 {
+		return raft.FetchResponse{}, fmt.Errorf("Fetch response without the metadata partition")
+	}
+	partition := response.Responses[0].Partitions[0]
+
+	entries, err := decodeQuorumEntries(partition.Records)
+	if err != nil {
+		return raft.FetchResponse{}, err
+	}
+
+	fetched := raft.FetchResponse{
+		ErrorCode:     partition.ErrorCode,
+		LeaderEpoch:   -1,
+		LeaderId:      raft.NO_LEADER,
+		HighWatermark: partition.HighWatermark,
+		Entries:       entries,
+	}
+	if partition.CurrentLeader != nil {
+		fetched.LeaderId, fetched.LeaderEpoch = partition.CurrentLeader.LeaderId, partition.CurrentLeader.LeaderEpoch
+	}
+	if partition.DivergingEpoch != nil {
+		fetched.DivergingEpoch = &raft.DivergingEpoch{Epoch: partition.DivergingEpoch.Epoch, EndOffset: partition.DivergingEpoch.EndOffset}
+	}
+	if partition.SnapshotId != nil {
+		fetched.SnapshotId = &raft.SnapshotId{EndOffset: partition.SnapshotId.EndOffset, Epoch: partition.SnapshotId.Epoch}
+	}
+
+	return fetched, nil
+}
+
+func (t *QuorumTransport) FetchSnapshot(destination int32, request raft.FetchSnapshotRequest) (raft.FetchSnapshotResponse, error) {
+	voter, err := t.voter(destination)
+	if err != nil {
+		return raft.FetchSnapshotResponse{}, err
+	}
+
+	req := &FetchSnapshotRequest{
+		ReplicaId: request.ReplicaId,
+		MaxBytes:  quorumMaxResponseSize,
+		Topics: []FetchSnapshotTopic{{
+			Name: clusterMetadataTopic,
+			Partitions: []FetchSnapshotPartition{{
+				Partition:          0,
+				CurrentLeaderEpoch: request.CurrentLeaderEpoch,
+				SnapshotId:         FetchSnapshotId{EndOffset: request.SnapshotId.EndOffset, Epoch: request.SnapshotId.Epoch},
+				Position:           0,
+				TaggedFields:       map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	buffer, err := t.send(voter, FetchSnapshot, 1, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return raft.FetchSnapshotResponse{}, err
+	}
+
+	response, err := parseFetchSnapshotResponse(buffer)
+	if err != nil {
+		return raft.FetchSnapshotResponse{}, err
+	}
+	if response.ErrorCode != int16(NONE) {
+		return raft.FetchSnapshotResponse{}, fmt.Errorf("FetchSnapshot failed: %s", KafkaErrorCodeNames[KafkaErrorCode(response.ErrorCode)])
+	}
+	if len(response.Topics) != 1 || len(response.Topics[0].Partitions) != 1 {
+		return raft.FetchSnapshotResponse{}, fmt.Errorf("FetchSnapshot response without the metadata partition")
+	}
+	partition := response.Topics[0].Partitions[0]
+
+	fetched := raft.FetchSnapshotResponse{ErrorCode: partition.ErrorCode, LeaderEpoch: -1, LeaderId: raft.NO_LEADER}
+	if partition.CurrentLeader != nil {
+		fetched.LeaderId, fetched.LeaderEpoch = partition.CurrentLeader.LeaderId, partition.CurrentLeader.LeaderEpoch
+	}
+	if partition.ErrorCode == int16(NONE) {
+		snapshotId := raft.SnapshotId{EndOffset: partition.SnapshotId.EndOffset, Epoch: partition.SnapshotId.Epoch}
+		fetched.Snapshot, err = decodeQuorumSnapshot(snapshotId, partition.UnalignedRecords)
+		if err != nil {
+			return raft.FetchSnapshotResponse{}, err
+		}
+	}
+
+	return fetched, nil
+}
+
+// voter finds the destination in the voter set of the node
+func (t *QuorumTransport) voter(destination int32) (raft.Voter, error) {
+	for _, voter := range t.voters() {
+		if voter.Id == destination {
+			return voter, nil
+		}
+	}
+	return raft.Voter{}, fmt.Errorf("%w: %d is not a voter", raft.ErrUnreachable, destination)
+}
+
+// leaderEndpoints are the endpoints of this node in the voter set, sent with the requests of a leader
+func (t *QuorumTransport) leaderEndpoints() []QuorumListener {
+	listeners := []QuorumListener{}
+	if voter, err := t.voter(t.nodeId); err == nil {
+		for _, endpoint := range voter.Endpoints {
+			listeners = append(listeners, QuorumListener{Name: endpoint.Name, Host: endpoint.Host, Port: endpoint.Port, TaggedFields: map[string]string{}})
+		}
+	}
+	return listeners
+}
+
+// address is the endpoint of the voter on the listener of the quorum, or its first endpoint when it has none by
+// that name
+func (t *QuorumTransport) address(voter raft.Voter) (string, error) {
+	for _, endpoint := range voter.Endpoints {
+		if endpoint.Name == t.listenerName {
+			return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port))), nil
+		}
+	}
+	if len(voter.Endpoints) > 0 {
+		return net.JoinHostPort(voter.Endpoints[0].Host, strconv.Itoa(int(voter.Endpoints[0].Port))), nil
+	}
+	return "", fmt.Errorf("%w: voter %d has no endpoint", raft.ErrUnreachable, voter.Id)
+}
+
+// send writes the request built by serialize to the voter and reads its response. The requests to the voters are
+// sent one at a time, the way the node polls them
+func (t *QuorumTransport) send(voter raft.Voter, apiKey KafkaAPIKey, apiVersion int16, serialize func(RequestHeader) ([]byte, error)) ([]byte, error) {
+	response, err := t.client.send(voter.Id, func() (string, error) { return t.address(voter) }, apiKey, apiVersion, 0, serialize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s to %d: %w", raft.ErrUnreachable, KafkaAPIKeyNames[apiKey], voter.Id, err)
+	}
+	return response, nil
+}
@@ -0,0 +1,248 @@
+package request
+
+import (
+	"log/slog"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/network"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+// testClock is the time of the nodes of a test, read by the handlers while the test polls the nodes
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(duration time.Duration) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(duration)
+	return c.now
+}
+
+// recordingListener keeps the records committed by a node and the data of the snapshots it loaded
+type recordingListener struct {
+	mutex     sync.Mutex
+	committed []string
+}
+
+func (l *recordingListener) HandleCommit(entries []raft.Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, entry := range entries {
+		if !entry.Control {
+			l.committed = append(l.committed, string(entry.Data))
+		}
+	}
+}
+
+func (l *recordingListener) HandleLoadSnapshot(snapshot raft.Snapshot) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.committed = []string{string(snapshot.Data)}
+}
+
+func (l *recordingListener) HandleLeaderChange(leader raft.LeaderAndEpoch) {}
+
+func (l *recordingListener) Committed() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string{}, l.committed...)
+}
+
+// listenQuorum binds a controller listener on a free port, its address is the endpoint of a voter
+func listenQuorum(t *testing.T) (net.Listener, raft.Endpoint) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return listener, raft.Endpoint{Name: "CONTROLLER", Host: host, Port: uint16(portNumber)}
+}
+
+// serveQuorum answers the quorum requests received by listener with the handlers of node
+func serveQuorum(listener net.Listener, node *raft.Node, clock *testClock) {
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		Vote:             &VoteHandler{quorum: node, authorizer: authorizer, now: clock.Now},
+		BeginQuorumEpoch: &BeginQuorumEpochHandler{quorum: node, authorizer: authorizer, now: clock.Now},
+		EndQuorumEpoch:   &EndQuorumEpochHandler{quorum: node, authorizer: authorizer, now: clock.Now},
+		Fetch:            &FetchHandler{quorum: node, loader: metadata.NewLoader(slog.New(slog.DiscardHandler)), authorizer: authorizer, now: clock.Now},
+		FetchSnapshot:    &FetchSnapshotHandler{quorum: node, authorizer: authorizer},
+	})
+}
+
+// serveRequests answers the requests received by listener with handlers, one connection at a time per goroutine
+func serveRequests(listener net.Listener, handlers map[KafkaAPIKey]RequestHandler) {
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer connection.Close()
+				for {
+					frame, err := network.ReadFrame(connection, quorumMaxResponseSize)
+					if err != nil {
+						return
+					}
+					header, index, err := ParseRequestHeader(frame, 0)
+					if err != nil {
+						return
+					}
+					handler := handlers[KafkaAPIKey(header.RequestApiKey)]
+					request, err := handler.ParseRequestBody(header, frame, index)
+					if err != nil {
+						return
+					}
+					response, err := handler.Handle(NewSession("127.0.0.1"), request)
+					if err != nil {
+						return
+					}
+					serialized, err := response.Serialize(header.RequestApiVersion)
+					if err != nil {
+						return
+					}
+					if _, err := connection.Write(serialized); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+}
+
+// newTransportNode starts a node whose quorum requests go over the network
+func newTransportNode(t *testing.T, nodeId int32, voters []raft.Voter, listener net.Listener, clock *testClock) (*raft.Node, *recordingListener) {
+	t.Helper()
+
+	var node *raft.Node
+	transport := NewQuorumTransport(nodeId, "CONTROLLER", func() []raft.Voter { return node.Voters() }, time.Second, 10*time.Millisecond)
+	t.Cleanup(transport.Close)
+
+	recorder := &recordingListener{}
+	node = raft.NewNode(raft.Config{
+		NodeId:          nodeId,
+		DirectoryId:     raft.ZERO_DIRECTORY_ID,
+		Voters:          voters,
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+		Seed:            uint64(nodeId),
+	}, transport, recorder, clock.Now())
+	serveQuorum(listener, node, clock)
+	return node, recorder
+}
+
+func TestQuorumTransportElectsAndReplicates(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+	listener1, endpoint1 := listenQuorum(t)
+	listener2, endpoint2 := listenQuorum(t)
+	voters := []raft.Voter{
+		{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint1}},
+		{Id: 2, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint2}},
+	}
+	node1, _ := newTransportNode(t, 1, voters, listener1, clock)
+	node2, recorder2 := newTransportNode(t, 2, voters, listener2, clock)
+
+	// Node 1 times out first, its Vote and BeginQuorumEpoch requests make node 2 its follower
+	now := clock.Advance(3 * time.Second)
+	node1.Poll(now)
+	node1.Poll(now)
+	if got := node1.LeaderAndEpoch(); got.LeaderId != 1 {
+		t.Fatalf("expected node 1 to lead, got %+v", got)
+	}
+	if got := node2.LeaderAndEpoch(); got != node1.LeaderAndEpoch() {
+		t.Fatalf("expected node 2 to follow %+v, got %+v", node1.LeaderAndEpoch(), got)
+	}
+
+	// Node 2 fetches the records, and learns of their commit at its next fetch
+	if _, err := node1.Append(node1.LeaderAndEpoch().Epoch, [][]byte{[]byte("a"), []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		now = clock.Advance(10 * time.Millisecond)
+		node2.Poll(now)
+		node1.Poll(now)
+	}
+	if got := recorder2.Committed(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected node 2 to commit a and b, got %v", got)
+	}
+}
+
+func TestQuorumTransportFetchesSnapshot(t *testing.T) {
+	clock := &testClock{now: time.UnixMilli(1_000_000)}
+	listener1, endpoint1 := listenQuorum(t)
+	listener2, _ := listenQuorum(t)
+	voters := []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint1}}}
+
+	// Node 1 leads alone and replaces its first records with a snapshot
+	leader, _ := newTransportNode(t, 1, voters, listener1, clock)
+	now := clock.Now()
+	leader.Poll(now)
+	if _, err := leader.Append(leader.LeaderAndEpoch().Epoch, [][]byte{[]byte("a"), []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	leader.Poll(now)
+	if err := leader.CreateSnapshot(leader.HighWatermark(), []byte("a,b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leader.Append(leader.LeaderAndEpoch().Epoch, [][]byte{[]byte("c")}); err != nil {
+		t.Fatal(err)
+	}
+	leader.Poll(now)
+
+	// Observer 2 finds the leader, fetches the snapshot and then the record after it
+	observer, recorder := newTransportNode(t, 2, voters, listener2, clock)
+	for range 3 {
+		now = clock.Advance(10 * time.Millisecond)
+		observer.Poll(now)
+	}
+	if got := recorder.Committed(); !reflect.DeepEqual(got, []string{"a,b", "c"}) {
+		t.Errorf("expected the observer to load the snapshot and commit c, got %v", got)
+	}
+}
+
+func TestQuorumTransportBacksOffUnreachableVoters(t *testing.T) {
+	listener, endpoint := listenQuorum(t)
+	listener.Close()
+
+	voters := []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{endpoint}}}
+	transport := NewQuorumTransport(2, "CONTROLLER", func() []raft.Voter { return voters }, time.Second, time.Minute)
+	defer transport.Close()
+
+	request := raft.VoteRequest{CandidateEpoch: 1, CandidateId: 2, CandidateDirectoryId: raft.ZERO_DIRECTORY_ID}
+	if _, err := transport.Vote(1, request); err == nil {
+		t.Fatal("expected the closed listener to fail the request")
+	}
+
+	// The voter is not dialed again before the backoff
+	start := time.Now()
+	if _, err := transport.Vote(1, request); err == nil || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("expected the backed off voter to fail at once, got %v after %s", err, time.Since(start))
+	}
+	if _, err := transport.Vote(3, request); err == nil {
+		t.Error("expected a node out of the voter set to be unreachable")
+	}
+}
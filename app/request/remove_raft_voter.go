@@ -0,0 +1,111 @@
+package request
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+type RemoveRaftVoterRequest struct {
+	Header           RequestHeader
+	ClusterId        *string
+	VoterId          int32
+	VoterDirectoryId string
+	TaggedFields     map[string]string
+}
+
+func (r *RemoveRaftVoterRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *RemoveRaftVoterRequest) GetApiKey() KafkaAPIKey {
+	return RemoveRaftVoter
+}
+
+func (r *RemoveRaftVoterRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *RemoveRaftVoterRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// RemoveRaftVoterHandler makes a voter of the metadata quorum an observer, on the leader of the quorum
+type RemoveRaftVoterHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+}
+
+func (h *RemoveRaftVoterHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &RemoveRaftVoterRequest{}
+	req.Header = requestHeader
+
+	req.ClusterId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse cluster id from RemoveRaftVoter request",
+		}
+	}
+
+	req.VoterId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse voter id from RemoveRaftVoter request",
+		}
+	}
+
+	req.VoterDirectoryId, index, err = parser.ExtractUUID(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse voter directory id from RemoveRaftVoter request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from RemoveRaftVoter request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *RemoveRaftVoterHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*RemoveRaftVoterRequest)
+	if !ok {
+		return nil, fmt.Errorf("RemoveRaftVoterHandler received %T instead of *RemoveRaftVoterRequest", req)
+	}
+
+	response := &RaftVoterResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	err := raft.ErrNotLeader
+	if h.quorum != nil {
+		err = h.quorum.RemoveVoter(apiReq.VoterId, apiReq.VoterDirectoryId)
+	}
+	if err != nil {
+		response.ErrorCode, response.ErrorMessage = quorumErrorCode(err)
+	}
+
+	return response, nil
+}
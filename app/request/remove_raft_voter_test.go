@@ -0,0 +1,101 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+func TestRemoveRaftVoterParseRequestBody(t *testing.T) {
+	handler := RemoveRaftVoterHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x28, // MessageSize: 40
+		0x00, 0x51, // RequestApiKey: 81 (RemoveRaftVoter)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x04, 'a', 'b', 'c', // ClusterId: "abc"
+		0x00, 0x00, 0x00, 0x02, // VoterId: 2
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // VoterDirectoryId
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 81, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*RemoveRaftVoterRequest)
+	if !ok {
+		t.Fatalf("expected *RemoveRaftVoterRequest, got %T", got)
+	}
+
+	clusterId := "abc"
+	want := &RemoveRaftVoterRequest{
+		Header:           header,
+		ClusterId:        &clusterId,
+		VoterId:          2,
+		VoterDirectoryId: "00000000-0000-0000-0000-000000000002",
+		TaggedFields:     map[string]string{},
+	}
+	if !reflect.DeepEqual(gotReq, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", gotReq, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:30], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestRemoveRaftVoterHandleRequest(t *testing.T) {
+	leader := newTestQuorum(time.UnixMilli(1_000_000))
+
+	tests := []struct {
+		name          string
+		quorum        *raft.Node
+		authorizer    acl.Authorizer
+		voterId       int32
+		wantErrorCode KafkaErrorCode
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), 1, NOT_LEADER_OR_FOLLOWER},
+		{"Not authorized", leader, denyAllAuthorizer{}, 1, CLUSTER_AUTHORIZATION_FAILED},
+		{"Unknown voter", leader, acl.NewAclAuthorizer(nil, true), 2, VOTER_NOT_FOUND},
+		{"Last voter", leader, acl.NewAclAuthorizer(nil, true), 1, INVALID_REQUEST},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RemoveRaftVoterHandler{quorum: tt.quorum, authorizer: tt.authorizer}
+			request := RemoveRaftVoterRequest{
+				Header:           RequestHeader{RequestApiKey: 81, RequestApiVersion: 0, CorrelationId: 7},
+				VoterId:          tt.voterId,
+				VoterDirectoryId: "00000000-0000-0000-0000-000000000001",
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*RaftVoterResponse)
+			if !ok {
+				t.Fatalf("expected *RaftVoterResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+		})
+	}
+}
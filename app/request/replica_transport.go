@@ -0,0 +1,197 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// replicaMaxResponseSize bounds the responses of the leaders to the fetches of the followers
+const replicaMaxResponseSize = 1 << 30
+
+// ReplicaTransport sends the requests of the replica manager of a broker: the fetches of its followers go to the
+// leaders on their listener named listenerName, which the broker registrations advertise, and the ISR changes go to
+// the active controller over the controller channel. Each leader has its own connection, so that a fetch the leader
+// holds does not delay the fetches from the other leaders
+type ReplicaTransport struct {
+	nodeId       int32
+	listenerName string
+	loader       *metadata.Loader
+	channel      *ControllerChannel
+	timeout      time.Duration
+	retryBackoff time.Duration
+
+	mutex   sync.Mutex
+	clients map[int32]*nodeClient
+}
+
+func NewReplicaTransport(nodeId int32, listenerName string, loader *metadata.Loader, channel *ControllerChannel, timeout time.Duration, retryBackoff time.Duration) *ReplicaTransport {
+	return &ReplicaTransport{
+		nodeId:       nodeId,
+		listenerName: listenerName,
+		loader:       loader,
+		channel:      channel,
+		timeout:      timeout,
+		retryBackoff: retryBackoff,
+		clients:      make(map[int32]*nodeClient),
+	}
+}
+
+// Close closes the connections to the leaders
+func (t *ReplicaTransport) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, client := range t.clients {
+		client.close()
+	}
+}
+
+// Fetch sends the fetch of the followers of the broker to the leader of their partitions
+func (t *ReplicaTransport) Fetch(leaderId int32, request replica.FetchRequest) (map[storage.TopicPartition]replica.FetchResult, error) {
+	req := &FetchRequest{
+		ReplicaId:       request.ReplicaId,
+		ReplicaEpoch:    -1,
+		MaxWaitMs:       int32(request.MaxWait.Milliseconds()),
+		MinBytes:        int32(request.MinBytes),
+		MaxBytes:        int32(request.MaxBytes),
+		IsolationLevel:  0,
+		SessionId:       0,
+		SessionEpoch:    -1,
+		Topics:          []FetchTopic{},
+		ForgottenTopics: []FetchForgottenTopic{},
+		TaggedFields:    map[string]string{},
+	}
+
+	// The partitions are grouped by topic, and found back in the response by topic id and index
+	topics := map[string]int{}
+	partitions := map[string]map[int32]storage.TopicPartition{}
+	for _, partition := range request.Partitions {
+		position, ok := topics[partition.TopicId]
+		if !ok {
+			position = len(req.Topics)
+			topics[partition.TopicId] = position
+			partitions[partition.TopicId] = map[int32]storage.TopicPartition{}
+			req.Topics = append(req.Topics, FetchTopic{TopicId: partition.TopicId, Partitions: []FetchPartition{}, TaggedFields: map[string]string{}})
+		}
+
+		req.Topics[position].Partitions = append(req.Topics[position].Partitions, FetchPartition{
+			Partition:          partition.Partition,
+			CurrentLeaderEpoch: partition.CurrentLeaderEpoch,
+			FetchOffset:        partition.FetchOffset,
+			LastFetchedEpoch:   partition.LastFetchedEpoch,
+			LogStartOffset:     -1,
+			PartitionMaxBytes:  int32(partition.MaxBytes),
+			TaggedFields:       map[string]string{},
+		})
+		partitions[partition.TopicId][partition.Partition] = partition.TopicPartition
+	}
+
+	// The leader holds the fetch up to its max wait
+	buffer, err := t.client(leaderId).send(leaderId, func() (string, error) { return t.address(leaderId) }, Fetch, 17, request.MaxWait, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Fetch to broker %d: %w", leaderId, err)
+	}
+
+	response, err := parseFetchResponse(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if response.ErrorCode != int16(NONE) {
+		return nil, fmt.Errorf("Fetch to broker %d failed: %s", leaderId, KafkaErrorCodeNames[KafkaErrorCode(response.ErrorCode)])
+	}
+
+	results := make(map[storage.TopicPartition]replica.FetchResult, len(request.Partitions))
+	for _, topic := range response.Responses {
+		for _, partition := range topic.Partitions {
+			topicPartition, ok := partitions[topic.TopicId][partition.PartitionIndex]
+			if !ok {
+				continue
+			}
+
+			result := replica.FetchResult{
+				Err:            replicaError(partition.ErrorCode),
+				HighWatermark:  partition.HighWatermark,
+				LogStartOffset: partition.LogStartOffset,
+				Records:        partition.Records,
+			}
+			if partition.DivergingEpoch != nil {
+				result.DivergingEpoch = &replica.EpochEndOffset{Epoch: partition.DivergingEpoch.Epoch, EndOffset: partition.DivergingEpoch.EndOffset}
+			}
+			results[topicPartition] = result
+		}
+	}
+
+	return results, nil
+}
+
+// AlterPartition sends an ISR change of a partition the broker leads to the active controller
+func (t *ReplicaTransport) AlterPartition(change controller.IsrChange) (int32, error) {
+	topic, ok := t.loader.Image().Topic(change.Topic)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", metadata.ErrUnknownTopic, change.Topic)
+	}
+
+	return t.channel.AlterPartition(t.nodeId, topic.Id, change)
+}
+
+// client returns the connection to a leader
+func (t *ReplicaTransport) client(leaderId int32) *nodeClient {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	client, ok := t.clients[leaderId]
+	if !ok {
+		client = newNodeClient(fmt.Sprintf("broker-%d-fetcher-%d", t.nodeId, leaderId), t.timeout, t.retryBackoff, replicaMaxResponseSize)
+		t.clients[leaderId] = client
+	}
+	return client
+}
+
+// address is the endpoint the registration of a broker advertises for the inter-broker listener
+func (t *ReplicaTransport) address(brokerId int32) (string, error) {
+	broker, ok := t.loader.Image().Broker(brokerId)
+	if !ok {
+		return "", fmt.Errorf("%w: %d", metadata.ErrUnknownBroker, brokerId)
+	}
+
+	for _, endpoint := range broker.Endpoints {
+		if endpoint.Name == t.listenerName {
+			return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port))), nil
+		}
+	}
+	return "", fmt.Errorf("broker %d has no %s endpoint", brokerId, t.listenerName)
+}
+
+// replicaError maps the error codes of the responses of the leaders back to the errors of the replica manager, nil for
+// NONE
+func replicaError(errorCode int16) error {
+	switch KafkaErrorCode(errorCode) {
+	case NONE:
+		return nil
+	case NOT_LEADER_OR_FOLLOWER:
+		return replica.ErrNotLeaderOrFollower
+	case UNKNOWN_TOPIC_OR_PARTITION, UNKNOWN_TOPIC_ID:
+		return replica.ErrUnknownTopicOrPartition
+	case FENCED_LEADER_EPOCH:
+		return replica.ErrFencedLeaderEpoch
+	case UNKNOWN_LEADER_EPOCH:
+		return replica.ErrUnknownLeaderEpoch
+	case OFFSET_OUT_OF_RANGE:
+		return replica.ErrOffsetOutOfRange
+	case TRANSACTION_COORDINATOR_FENCED:
+		return storage.ErrTransactionCoordinatorFenced
+	default:
+		return fmt.Errorf("the leader failed the fetch: %s", KafkaErrorCodeNames[KafkaErrorCode(errorCode)])
+	}
+}
@@ -0,0 +1,149 @@
+package request
+
+import (
+	"log/slog"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// listenBroker binds a broker listener on a free port, its address is the endpoint the broker registers
+func listenBroker(t *testing.T) (net.Listener, metadata.BrokerEndpoint) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return listener, metadata.BrokerEndpoint{Name: "PLAINTEXT", Host: host, Port: uint16(portNumber), SecurityProtocol: 0}
+}
+
+func TestReplicaTransportCompletesAReassignment(t *testing.T) {
+	now := time.Now()
+	controllerListener, controllerEndpoint := listenQuorum(t)
+
+	// A single voter reached on its controller listener, polled until the test ends like the broker would
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	transport := raft.NewMemoryTransport(time.Now)
+	active := controller.NewController(controller.Config{SessionTimeout: 9 * time.Second}, raft.Config{
+		NodeId:          1,
+		DirectoryId:     "00000000-0000-0000-0000-000000000001",
+		Voters:          []raft.Voter{{Id: 1, DirectoryId: raft.ZERO_DIRECTORY_ID, Endpoints: []raft.Endpoint{controllerEndpoint}}},
+		ElectionTimeout: time.Second,
+		FetchTimeout:    2 * time.Second,
+		FetchMaxEntries: 10,
+	}, transport.Endpoint(1), slog.New(slog.DiscardHandler), now, loader)
+	transport.Register(active.Node())
+	active.Node().Poll(now)
+
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	serveRequests(controllerListener, map[KafkaAPIKey]RequestHandler{
+		AlterPartition: &AlterPartitionHandler{controller: active, authorizer: authorizer},
+	})
+
+	// Both brokers serve the fetches of their followers on their PLAINTEXT listener
+	brokers := map[int32]*replica.Manager{}
+	logs := map[int32]*storage.LogManager{}
+	for _, brokerId := range []int32{1, 2} {
+		listener, endpoint := listenBroker(t)
+		epoch, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: brokerId, IncarnationId: "00000000-0000-0000-0000-000000000007", Endpoints: []metadata.BrokerEndpoint{endpoint}}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := active.Heartbeat(controller.BrokerHeartbeat{BrokerId: brokerId, BrokerEpoch: epoch, CurrentMetadataOffset: epoch}, now); err != nil {
+			t.Fatal(err)
+		}
+
+		logs[brokerId], err = storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, brokerId, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { logs[brokerId].Close() })
+
+		channel := NewControllerChannel(brokerId, "CONTROLLER", active.Node(), time.Second, 10*time.Millisecond)
+		t.Cleanup(channel.Close)
+		replicaTransport := NewReplicaTransport(brokerId, "PLAINTEXT", loader, channel, time.Second, 10*time.Millisecond)
+		t.Cleanup(replicaTransport.Close)
+
+		configs := config.NewStore(brokerId, map[string]string{})
+		brokers[brokerId] = replica.NewManager(replica.Config{
+			NodeId:        brokerId,
+			MaxLag:        30 * time.Second,
+			FetchMaxWait:  50 * time.Millisecond,
+			FetchMaxBytes: 1 << 20,
+			FetchBackoff:  10 * time.Millisecond,
+			QuotaSamples:  11,
+			QuotaWindow:   time.Second,
+		}, logs[brokerId], configs, replicaTransport, slog.New(slog.DiscardHandler))
+		t.Cleanup(brokers[brokerId].Shutdown)
+		loader.Subscribe(brokers[brokerId].ApplyImage)
+
+		serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+			Fetch: &FetchHandler{loader: loader, replicas: brokers[brokerId], authorizer: authorizer, now: time.Now},
+		})
+	}
+
+	if _, err := active.CreateTopic("foo", [][]int32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	appended := make(chan map[storage.TopicPartition]replica.AppendResult, 1)
+	brokers[1].AppendRecords(0, 1, map[storage.TopicPartition][]byte{foo: newTestRecords()}, func(results map[storage.TopicPartition]replica.AppendResult) { appended <- results })
+	if results := <-appended; results[foo].Err != nil {
+		t.Fatalf("unexpected error: %v", results[foo].Err)
+	}
+
+	stop := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				active.Node().Poll(time.Now())
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-polled
+	}()
+
+	// Broker 2 catches up from broker 1, which reports it in the ISR with AlterPartition, and the reassignment completes
+	if _, err := active.AlterPartitionReassignments(map[controller.TopicPartition][]int32{{Topic: "foo", Partition: 0}: {2}}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		topic, _ := loader.Image().Topic("foo")
+		partition := topic.Partitions[0]
+		if reflect.DeepEqual(partition.Replicas, []int32{2}) && partition.Leader == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the partition to move to broker 2, got %+v", partition)
+		}
+	}
+
+	if logEndOffset, err := logs[2].LogEndOffset(foo); err != nil || logEndOffset != 3 {
+		t.Errorf("expected broker 2 to have the 3 records, got a log end offset of %d, %v", logEndOffset, err)
+	}
+}
@@ -0,0 +1,94 @@
+package request
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// requestLogger writes one line per request, like Kafka's kafka.request.logger. Requests that failed or took longer
+// than request.logger.slow.threshold.ms are logged at WARN, the others at INFO, and at DEBUG the request and response
+// frames are hex dumped as well
+type requestLogger struct {
+	logger        *slog.Logger
+	slowThreshold atomic.Int64
+}
+
+func newRequestLogger(logger *slog.Logger, slowThreshold time.Duration) *requestLogger {
+	requestLog := &requestLogger{logger: logger}
+	requestLog.setSlowThreshold(slowThreshold)
+	return requestLog
+}
+
+func (l *requestLogger) setSlowThreshold(slowThreshold time.Duration) {
+	l.slowThreshold.Store(int64(slowThreshold))
+}
+
+func (l *requestLogger) log(session *Session, header RequestHeader, errorCounts map[int16]int, err error, elapsed time.Duration) {
+	level := slog.LevelInfo
+	if err != nil || elapsed > time.Duration(l.slowThreshold.Load()) {
+		level = slog.LevelWarn
+	}
+
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := append(sessionAttrs(session),
+		slog.String("client_id", header.ClientId),
+		slog.String("client_software_name", session.ClientSoftwareName),
+		slog.String("client_software_version", session.ClientSoftwareVersion),
+		slog.String("api_key", apiKeyName(KafkaAPIKey(header.RequestApiKey))),
+		slog.Int("api_version", int(header.RequestApiVersion)),
+		slog.Int("correlation_id", int(header.CorrelationId)),
+		slog.Duration("latency", elapsed),
+		slog.String("error_code", errorCodeNames(errorCounts)),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	l.logger.LogAttrs(ctx, level, "Completed request", attrs...)
+}
+
+// logFrame hex dumps a request or response frame at DEBUG
+func (l *requestLogger) logFrame(session *Session, message string, frame []byte) {
+	ctx := context.Background()
+	if frame == nil || !l.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := append(sessionAttrs(session), slog.String("frame", hex.EncodeToString(frame)))
+	l.logger.LogAttrs(ctx, slog.LevelDebug, message, attrs...)
+}
+
+// sessionAttrs are the attributes every line about a connection starts with, so that they can be correlated
+func sessionAttrs(session *Session) []slog.Attr {
+	return []slog.Attr{
+		slog.String("connection", session.ConnectionId),
+		slog.String("listener", session.Listener.Name),
+		slog.String("principal", session.Principal()),
+	}
+}
+
+// errorCodeNames lists the errors of a response, NONE when every result succeeded
+func errorCodeNames(errorCounts map[int16]int) string {
+	names := []string{}
+	for code := range errorCounts {
+		if KafkaErrorCode(code) != NONE {
+			names = append(names, errorCodeName(KafkaErrorCode(code)))
+		}
+	}
+
+	if len(names) == 0 {
+		return errorCodeName(NONE)
+	}
+
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,199 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type SaslAuthenticateRequest struct {
+	Header       RequestHeader
+	AuthBytes    []byte
+	TaggedFields map[string]string
+}
+
+func (r *SaslAuthenticateRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *SaslAuthenticateRequest) GetApiKey() KafkaAPIKey {
+	return SaslAuthenticate
+}
+
+func (r *SaslAuthenticateRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *SaslAuthenticateRequest) Validate() error {
+	if r.Header.RequestApiVersion != 2 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type SaslAuthenticateResponse struct {
+	CorrelationId     int32
+	ErrorCode         int16
+	ErrorMessage      *string
+	AuthBytes         []byte
+	SessionLifetimeMs int64
+	TaggedFields      map[string]string
+}
+
+func (r *SaslAuthenticateResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *SaslAuthenticateResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *SaslAuthenticateResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 64 + len(r.AuthBytes)
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactBytes(buffer, index, r.AuthBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, r.SessionLifetimeMs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type SaslAuthenticateHandler struct {
+	// Maximum lifetime of an authenticated session (connections.max.reauth.ms), 0 when sessions never expire
+	maxReauthMs int64
+	now         func() time.Time
+}
+
+func (h *SaslAuthenticateHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &SaslAuthenticateRequest{}
+	req.Header = requestHeader
+
+	req.AuthBytes, index, err = parser.ExtractCompactBytes(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse auth bytes from SaslAuthenticate request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from SaslAuthenticate request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *SaslAuthenticateHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*SaslAuthenticateRequest)
+	if !ok {
+		return nil, fmt.Errorf("SaslAuthenticateHandler received %T instead of *SaslAuthenticateRequest", req)
+	}
+
+	response := &SaslAuthenticateResponse{
+		CorrelationId:     apiReq.Header.CorrelationId,
+		ErrorCode:         0,
+		ErrorMessage:      nil,
+		AuthBytes:         []byte{},
+		SessionLifetimeMs: 0,
+		TaggedFields:      make(map[string]string),
+	}
+
+	if err := apiReq.Validate(); err != nil {
+		response.ErrorCode = int16(UNSUPPORTED_VERSION)
+		return response, nil
+	}
+
+	if session.authenticator == nil {
+		message := "SaslAuthenticate request received before a SaslHandshake"
+		response.ErrorCode, response.ErrorMessage = int16(ILLEGAL_SASL_STATE), &message
+		return response, nil
+	}
+
+	challenge, err := session.authenticator.Evaluate(apiReq.AuthBytes)
+	if err != nil {
+		session.authenticator = nil
+		// The client may not guess credentials again on the same connection
+		session.closing = true
+		message := fmt.Sprintf("Authentication failed during authentication due to invalid credentials with SASL mechanism %s: %s", session.saslMechanism, err.Error())
+		response.ErrorCode, response.ErrorMessage = int16(SASL_AUTHENTICATION_FAILED), &message
+		return response, nil
+	}
+
+	response.AuthBytes = challenge
+	if !session.authenticator.Complete() {
+		return response, nil
+	}
+
+	principal := session.authenticator.Principal()
+	session.authenticator = nil
+
+	var expiresAt time.Time
+	if h.maxReauthMs > 0 {
+		expiresAt = h.now().Add(time.Duration(h.maxReauthMs) * time.Millisecond)
+	}
+
+	if current, ok := session.authenticate(principal, expiresAt); !ok {
+		session.closing = true
+		message := fmt.Sprintf("Cannot change principals during re-authentication from %s to %s", current, principal)
+		response.ErrorCode, response.ErrorMessage = int16(SASL_AUTHENTICATION_FAILED), &message
+		return response, nil
+	}
+
+	if h.maxReauthMs > 0 {
+		response.SessionLifetimeMs = h.maxReauthMs
+	}
+
+	return response, nil
+}
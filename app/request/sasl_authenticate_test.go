@@ -0,0 +1,189 @@
+package request
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+func TestSaslAuthenticateParseRequestBody(t *testing.T) {
+	handler := SaslAuthenticateHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x17, // MessageSize: 23
+		0x00, 0x24, // RequestApiKey: 36 (SaslAuthenticate)
+		0x00, 0x02, // RequestApiVersion: 2
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x07, 0x00, 'b', 'o', 'b', 0x00, 'x', // AuthBytes: "\x00bob\x00x"
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 36, RequestApiVersion: 2, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*SaslAuthenticateRequest)
+	if !ok {
+		t.Fatalf("expected *SaslAuthenticateRequest, got %T", got)
+	}
+
+	if !bytes.Equal(gotReq.AuthBytes, []byte("\x00bob\x00x")) {
+		t.Errorf("AuthBytes mismatch: got %q", gotReq.AuthBytes)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:23], 19); err == nil {
+		t.Errorf("expected error for truncated auth bytes but got nil")
+	}
+}
+
+func TestSaslAuthenticateHandleRequest(t *testing.T) {
+	credentials := sasl.NewCredentialStore()
+	credentials.SetPassword("alice", "alice-secret")
+	credentials.SetPassword("bob", "bob-secret")
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	handshakeHandler := SaslHandshakeHandler{mechanisms: []string{sasl.PLAIN}, credentials: credentials}
+	handler := SaslAuthenticateHandler{maxReauthMs: 60000, now: func() time.Time { return now }}
+
+	authenticate := func(session *Session, token string) *SaslAuthenticateResponse {
+		t.Helper()
+
+		handshake := SaslHandshakeRequest{Header: RequestHeader{RequestApiKey: 17, RequestApiVersion: 1}, Mechanism: sasl.PLAIN}
+		if _, err := handshakeHandler.Handle(session, &handshake); err != nil {
+			t.Fatal(err)
+		}
+
+		request := SaslAuthenticateRequest{Header: RequestHeader{RequestApiKey: 36, RequestApiVersion: 2, CorrelationId: 7}, AuthBytes: []byte(token)}
+		got, err := handler.Handle(session, &request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return got.(*SaslAuthenticateResponse)
+	}
+
+	t.Run("Valid credentials", func(t *testing.T) {
+		session := NewSession("127.0.0.1")
+
+		response := authenticate(session, "\x00alice\x00alice-secret")
+		if response.ErrorCode != 0 {
+			t.Fatalf("ErrorCode mismatch: got %d, want 0", response.ErrorCode)
+		}
+
+		if response.SessionLifetimeMs != 60000 {
+			t.Errorf("SessionLifetimeMs mismatch: got %d, want 60000", response.SessionLifetimeMs)
+		}
+
+		if session.Principal() != "User:alice" || !session.isAuthenticated(now) {
+			t.Errorf("expected an authenticated session for User:alice, got %s", session.Principal())
+		}
+
+		if session.isAuthenticated(now.Add(time.Minute)) {
+			t.Errorf("session must expire after connections.max.reauth.ms")
+		}
+
+		if session.Closing() {
+			t.Errorf("the connection must stay open after a successful authentication")
+		}
+	})
+
+	t.Run("Invalid credentials", func(t *testing.T) {
+		session := NewSession("127.0.0.1")
+
+		response := authenticate(session, "\x00alice\x00bob-secret")
+		if response.ErrorCode != int16(SASL_AUTHENTICATION_FAILED) || response.ErrorMessage == nil {
+			t.Errorf("ErrorCode mismatch: got %d, want %d", response.ErrorCode, SASL_AUTHENTICATION_FAILED)
+		}
+
+		if session.isAuthenticated(now) || session.Principal() != AnonymousPrincipal {
+			t.Errorf("session must stay unauthenticated, got %s", session.Principal())
+		}
+
+		if !session.Closing() {
+			t.Errorf("the connection must be closed after a failed authentication")
+		}
+	})
+
+	t.Run("Re-authentication", func(t *testing.T) {
+		session := NewSession("127.0.0.1")
+		authenticate(session, "\x00alice\x00alice-secret")
+
+		now = now.Add(30 * time.Second)
+		response := authenticate(session, "\x00alice\x00alice-secret")
+		if response.ErrorCode != 0 {
+			t.Fatalf("ErrorCode mismatch: got %d, want 0", response.ErrorCode)
+		}
+
+		if !session.isAuthenticated(now.Add(45 * time.Second)) {
+			t.Errorf("re-authentication must extend the session")
+		}
+
+		response = authenticate(session, "\x00bob\x00bob-secret")
+		if response.ErrorCode != int16(SASL_AUTHENTICATION_FAILED) {
+			t.Errorf("ErrorCode mismatch: got %d, want %d", response.ErrorCode, SASL_AUTHENTICATION_FAILED)
+		}
+
+		if session.Principal() != "User:alice" {
+			t.Errorf("principal must not change during re-authentication, got %s", session.Principal())
+		}
+
+		if !session.Closing() {
+			t.Errorf("the connection must be closed after a failed re-authentication")
+		}
+	})
+
+	t.Run("Without handshake", func(t *testing.T) {
+		session := NewSession("127.0.0.1")
+
+		request := SaslAuthenticateRequest{Header: RequestHeader{RequestApiKey: 36, RequestApiVersion: 2, CorrelationId: 7}, AuthBytes: []byte("\x00alice\x00alice-secret")}
+		got, err := handler.Handle(session, &request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.(*SaslAuthenticateResponse).ErrorCode != int16(ILLEGAL_SASL_STATE) {
+			t.Errorf("ErrorCode mismatch: got %d, want %d", got.(*SaslAuthenticateResponse).ErrorCode, ILLEGAL_SASL_STATE)
+		}
+	})
+}
+
+func TestSaslAuthenticateResponseSerialize(t *testing.T) {
+	response := SaslAuthenticateResponse{
+		CorrelationId:     7,
+		ErrorCode:         0,
+		ErrorMessage:      nil,
+		AuthBytes:         []byte("v=1"),
+		SessionLifetimeMs: 60000,
+		TaggedFields:      map[string]string{},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x15, // MessageSize: 21
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,       // Response header tagged fields
+		0x00, 0x00, // ErrorCode: 0
+		0x00,                // ErrorMessage: null
+		0x04, 'v', '=', '1', // AuthBytes: "v=1"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xEA, 0x60, // SessionLifetimeMs: 60000
+		0x00, // Response tagged fields
+	}
+
+	got, err := response.Serialize(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
@@ -0,0 +1,152 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type SaslHandshakeRequest struct {
+	Header    RequestHeader
+	Mechanism string
+}
+
+func (r *SaslHandshakeRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *SaslHandshakeRequest) GetApiKey() KafkaAPIKey {
+	return SaslHandshake
+}
+
+func (r *SaslHandshakeRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+// Version 0 sends the SASL tokens as raw bytes instead of SaslAuthenticate requests, which is not supported
+func (r *SaslHandshakeRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// SaslHandshake is never flexible so, like ApiVersions, it uses response header v0 (without tagged fields)
+type SaslHandshakeResponse struct {
+	CorrelationId int32
+	ErrorCode     int16
+	Mechanisms    []string
+}
+
+func (r *SaslHandshakeResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *SaslHandshakeResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *SaslHandshakeResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, mechanism := range r.Mechanisms {
+		bufferSize += 2 + len(mechanism)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, int32(len(r.Mechanisms)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mechanism := range r.Mechanisms {
+		index, err = serializer.SerializeString(buffer, index, mechanism)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type SaslHandshakeHandler struct {
+	mechanisms  []string
+	credentials *sasl.CredentialStore
+}
+
+func (h *SaslHandshakeHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &SaslHandshakeRequest{}
+	req.Header = requestHeader
+
+	req.Mechanism, _, err = parser.ExtractNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse mechanism from SaslHandshake request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *SaslHandshakeHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*SaslHandshakeRequest)
+	if !ok {
+		return nil, fmt.Errorf("SaslHandshakeHandler received %T instead of *SaslHandshakeRequest", req)
+	}
+
+	response := &SaslHandshakeResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     0,
+		Mechanisms:    h.mechanisms,
+	}
+
+	if err := apiReq.Validate(); err != nil {
+		response.ErrorCode = int16(UNSUPPORTED_VERSION)
+		return response, nil
+	}
+
+	if !slices.Contains(h.mechanisms, apiReq.Mechanism) {
+		response.ErrorCode = int16(UNSUPPORTED_SASL_MECHANISM)
+		return response, nil
+	}
+
+	// A handshake may only start a new exchange, and re-authentication must keep the mechanism of the session
+	if session.authenticator != nil || (session.hasAuthenticated() && session.saslMechanism != apiReq.Mechanism) {
+		response.ErrorCode = int16(ILLEGAL_SASL_STATE)
+		return response, nil
+	}
+
+	authenticator, err := sasl.NewAuthenticator(apiReq.Mechanism, h.credentials)
+	if err != nil {
+		response.ErrorCode = int16(UNSUPPORTED_SASL_MECHANISM)
+		return response, nil
+	}
+
+	session.saslMechanism = apiReq.Mechanism
+	session.authenticator = authenticator
+
+	return response, nil
+}
@@ -0,0 +1,146 @@
+package request
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+func TestSaslHandshakeParseRequestBody(t *testing.T) {
+	handler := SaslHandshakeHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x15, // MessageSize: 21
+		0x00, 0x11, // RequestApiKey: 17 (SaslHandshake)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		// Body starts here (no header tagged fields, SaslHandshake is never flexible)
+		0x00, 0x05, 'P', 'L', 'A', 'I', 'N', // Mechanism: "PLAIN"
+	}
+
+	header := RequestHeader{RequestApiKey: 17, RequestApiVersion: 1, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 18)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gotReq, ok := got.(*SaslHandshakeRequest)
+	if !ok {
+		t.Fatalf("expected *SaslHandshakeRequest, got %T", got)
+	}
+
+	if gotReq.Mechanism != "PLAIN" {
+		t.Errorf("Mechanism mismatch: got %s, want PLAIN", gotReq.Mechanism)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:21], 18); err == nil {
+		t.Errorf("expected error for a truncated mechanism but got nil")
+	}
+}
+
+func TestSaslHandshakeHandleRequest(t *testing.T) {
+	handler := SaslHandshakeHandler{mechanisms: []string{sasl.PLAIN, sasl.SCRAM_SHA_256}, credentials: sasl.NewCredentialStore()}
+
+	tests := []struct {
+		name          string
+		session       *Session
+		version       int16
+		mechanism     string
+		wantErrorCode int16
+		wantStarted   bool
+	}{
+		{
+			name:          "Enabled mechanism",
+			session:       NewSession("127.0.0.1"),
+			version:       1,
+			mechanism:     sasl.PLAIN,
+			wantErrorCode: 0,
+			wantStarted:   true,
+		},
+		{
+			name:          "Disabled mechanism",
+			session:       NewSession("127.0.0.1"),
+			version:       1,
+			mechanism:     sasl.SCRAM_SHA_512,
+			wantErrorCode: int16(UNSUPPORTED_SASL_MECHANISM),
+			wantStarted:   false,
+		},
+		{
+			name:          "Raw SASL tokens of version 0",
+			session:       NewSession("127.0.0.1"),
+			version:       0,
+			mechanism:     sasl.PLAIN,
+			wantErrorCode: int16(UNSUPPORTED_VERSION),
+			wantStarted:   false,
+		},
+		{
+			name:          "Re-authentication with another mechanism",
+			session:       &Session{principal: "User:alice", saslMechanism: sasl.PLAIN, authenticated: true},
+			version:       1,
+			mechanism:     sasl.SCRAM_SHA_256,
+			wantErrorCode: int16(ILLEGAL_SASL_STATE),
+			wantStarted:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := SaslHandshakeRequest{
+				Header:    RequestHeader{RequestApiKey: 17, RequestApiVersion: tt.version, CorrelationId: 7},
+				Mechanism: tt.mechanism,
+			}
+
+			got, err := handler.Handle(tt.session, &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*SaslHandshakeResponse)
+			if !ok {
+				t.Fatalf("expected *SaslHandshakeResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != tt.wantErrorCode {
+				t.Errorf("ErrorCode mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+
+			if len(gotResp.Mechanisms) != 2 {
+				t.Errorf("Mechanisms mismatch: got %v", gotResp.Mechanisms)
+			}
+
+			if (tt.session.authenticator != nil) != tt.wantStarted {
+				t.Errorf("exchange started mismatch: got %v, want %v", tt.session.authenticator != nil, tt.wantStarted)
+			}
+		})
+	}
+}
+
+func TestSaslHandshakeResponseSerialize(t *testing.T) {
+	response := SaslHandshakeResponse{
+		CorrelationId: 7,
+		ErrorCode:     0,
+		Mechanisms:    []string{"PLAIN"},
+	}
+
+	expected := []byte{
+		0x00, 0x00, 0x00, 0x11, // MessageSize: 17
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00, 0x00, // ErrorCode: 0
+		0x00, 0x00, 0x00, 0x01, // Mechanisms array length: 1
+		0x00, 0x05, 'P', 'L', 'A', 'I', 'N', // Mechanism: "PLAIN"
+	}
+
+	got, err := response.Serialize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, expected) {
+		t.Errorf("response mismatch:\ngot  %v\nwant %v", got, expected)
+	}
+}
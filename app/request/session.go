@@ -0,0 +1,128 @@
+package request
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/sasl"
+)
+
+// AnonymousPrincipal is the principal of clients that have not authenticated
+const AnonymousPrincipal = "User:ANONYMOUS"
+
+// ErrAuthenticationRequired is returned for requests sent on SASL listeners before authentication completed or after the session expired.
+// Like Kafka, the broker closes the connection instead of answering them
+var ErrAuthenticationRequired = errors.New("authentication required")
+
+// Session holds what the broker knows about the client on the other end of a connection.
+// It is created when the connection is accepted and handed to every handler along with the request
+type Session struct {
+	ClientHost string
+	// Listener is the listener the client connected through
+	Listener config.Listener
+	// ConnectionId identifies the connection in the logs, as local address-remote address-index like Kafka
+	ConnectionId string
+	// What the client reported in its ApiVersions request, empty until then
+	ClientSoftwareName    string
+	ClientSoftwareVersion string
+
+	// SASL state, only used on SASL_PLAINTEXT and SASL_SSL listeners
+	saslMechanism string
+	authenticator sasl.Authenticator
+
+	// mutex guards the principal and the authentication, a client re-authenticates while its other requests wait in
+	// the purgatories
+	mutex         sync.Mutex
+	principal     string
+	authenticated bool
+	// The client must re-authenticate before this time, zero when the session never expires (KIP-368)
+	expiresAt time.Time
+	// Set once authentication failed, the connection is closed after the failure response like in Kafka
+	closing bool
+}
+
+// ChangesSession tells if the request in buffer updates the session, such requests must not run concurrently with others of the same connection
+func ChangesSession(buffer []byte) bool {
+	header, _, err := ParseRequestHeader(buffer, 0)
+	if err != nil {
+		return false
+	}
+
+	apiKey := KafkaAPIKey(header.RequestApiKey)
+	return apiKey == ApiVersions || apiKey == SaslHandshake || apiKey == SaslAuthenticate
+}
+
+func NewSession(clientHost string) *Session {
+	return &Session{
+		ClientHost: clientHost,
+		principal:  AnonymousPrincipal,
+	}
+}
+
+// Principal is the principal the client is identified as, AnonymousPrincipal until it authenticates
+func (s *Session) Principal() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.principal
+}
+
+// SetPrincipal identifies the client before its first request, like SSL clients by their certificate
+func (s *Session) SetPrincipal(principal string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.principal = principal
+}
+
+// Closing tells if the connection must be closed once the response of the current request is written. Only requests
+// that change the session set it, they run alone on their connection
+func (s *Session) Closing() bool {
+	return s.closing
+}
+
+// isAuthenticated tells if requests other than the SASL ones may be processed
+func (s *Session) isAuthenticated(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.authenticated && (s.expiresAt.IsZero() || now.Before(s.expiresAt))
+}
+
+// hasAuthenticated tells if the client authenticated once, even if the session expired since
+func (s *Session) hasAuthenticated() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.authenticated
+}
+
+// authenticate records the principal the client authenticated as and when it must re-authenticate, zero for never.
+// A re-authentication may not change the principal, the current one is returned with false then
+func (s *Session) authenticate(principal string, expiresAt time.Time) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.authenticated && principal != s.principal {
+		return s.principal, false
+	}
+
+	s.principal = principal
+	s.authenticated = true
+	s.expiresAt = expiresAt
+	return principal, true
+}
+
+// quotaUser is the user name client quotas are set on, the principal without its type
+func (s *Session) quotaUser() string {
+	_, name, _ := strings.Cut(s.Principal(), ":")
+	return name
+}
+
+func (s *Session) authorize(authorizer acl.Authorizer, op acl.Operation, resourceType acl.ResourceType, resourceName string) bool {
+	return authorizer.Authorize(s.Principal(), s.ClientHost, op, acl.Resource{Type: resourceType, Name: resourceName})
+}
+
+func (s *Session) authorizedOperations(authorizer acl.Authorizer, resourceType acl.ResourceType, resourceName string) int32 {
+	return acl.AuthorizedOperations(authorizer, s.Principal(), s.ClientHost, acl.Resource{Type: resourceType, Name: resourceName})
+}
@@ -0,0 +1,273 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+// transactionMaxResponseSize bounds the responses of the leaders to the markers of the coordinator
+const transactionMaxResponseSize = 1 << 20
+
+// TransactionTransport sends the markers of the transaction coordinator of a broker to the leaders of the partitions
+// of the transactions, and the verifications of the leaders to the coordinators of the transactions, on their
+// listener named listenerName. The markers of the partitions the broker leads itself are appended to its replicas
+// directly, and the transactions its own coordinator owns are verified by it
+type TransactionTransport struct {
+	nodeId       int32
+	listenerName string
+	loader       *metadata.Loader
+	replicas     *replica.Manager
+	// The transaction coordinator of the broker, set once it is started
+	coordinator  *transaction.Coordinator
+	timeout      time.Duration
+	retryBackoff time.Duration
+	now          func() time.Time
+
+	mutex   sync.Mutex
+	clients map[int32]*nodeClient
+}
+
+func NewTransactionTransport(nodeId int32, listenerName string, loader *metadata.Loader, replicas *replica.Manager, timeout time.Duration, retryBackoff time.Duration) *TransactionTransport {
+	return &TransactionTransport{
+		nodeId:       nodeId,
+		listenerName: listenerName,
+		loader:       loader,
+		replicas:     replicas,
+		timeout:      timeout,
+		retryBackoff: retryBackoff,
+		now:          time.Now,
+		clients:      make(map[int32]*nodeClient),
+	}
+}
+
+// Close closes the connections to the leaders
+func (t *TransactionTransport) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, client := range t.clients {
+		client.close()
+	}
+}
+
+// WriteTxnMarkers writes a marker to the partitions of a leader, and returns the error of every partition
+func (t *TransactionTransport) WriteTxnMarkers(leaderId int32, marker transaction.Marker) (map[storage.TopicPartition]error, error) {
+	if leaderId == t.nodeId {
+		return t.appendMarker(marker), nil
+	}
+
+	req := &WriteTxnMarkersRequest{
+		Markers: []WritableTxnMarker{{
+			ProducerId:        marker.ProducerId,
+			ProducerEpoch:     marker.ProducerEpoch,
+			TransactionResult: marker.Commit,
+			Topics:            []WritableTxnMarkerTopic{},
+			CoordinatorEpoch:  marker.CoordinatorEpoch,
+			TaggedFields:      map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+	topics := map[string]int{}
+	for _, partition := range marker.Partitions {
+		position, ok := topics[partition.Topic]
+		if !ok {
+			position = len(req.Markers[0].Topics)
+			topics[partition.Topic] = position
+			req.Markers[0].Topics = append(req.Markers[0].Topics, WritableTxnMarkerTopic{Name: partition.Topic, PartitionIndexes: []int32{}, TaggedFields: map[string]string{}})
+		}
+		req.Markers[0].Topics[position].PartitionIndexes = append(req.Markers[0].Topics[position].PartitionIndexes, partition.Partition)
+	}
+
+	buffer, err := t.client(leaderId).send(leaderId, func() (string, error) { return t.address(leaderId) }, WriteTxnMarkers, 1, t.timeout, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("WriteTxnMarkers to broker %d: %w", leaderId, err)
+	}
+
+	response, err := parseWriteTxnMarkersResponse(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The partitions missing from the response are retried
+	errs := make(map[storage.TopicPartition]error, len(marker.Partitions))
+	for _, partition := range marker.Partitions {
+		errs[partition] = fmt.Errorf("broker %d did not answer for %s-%d", leaderId, partition.Topic, partition.Partition)
+	}
+	for _, result := range response.Markers {
+		for _, topic := range result.Topics {
+			for _, partition := range topic.Partitions {
+				topicPartition := storage.TopicPartition{Topic: topic.Name, Partition: partition.PartitionIndex}
+				if _, ok := errs[topicPartition]; ok {
+					errs[topicPartition] = replicaError(partition.ErrorCode)
+				}
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// VerifyTransaction asks the coordinator of a transactional id whether partitions the broker leads are part of the
+// ongoing transaction of its producer, with a version 4 AddPartitionsToTxn that only verifies them. It returns the
+// error of every partition, or the error of the request when the coordinator could not be asked
+func (t *TransactionTransport) VerifyTransaction(transactionalId string, producerId int64, producerEpoch int16, partitions []storage.TopicPartition) (map[storage.TopicPartition]error, error) {
+	topic, ok := t.loader.Image().Topic(transaction.TRANSACTION_STATE_TOPIC)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not exist", transaction.ErrCoordinatorNotAvailable, transaction.TRANSACTION_STATE_TOPIC)
+	}
+	partition, ok := topic.Partitions[transaction.PartitionFor(transactionalId, len(topic.Partitions))]
+	if !ok || partition.Leader == metadata.NO_LEADER {
+		return nil, fmt.Errorf("%w: the partition of %s has no leader", transaction.ErrCoordinatorNotAvailable, transactionalId)
+	}
+	coordinatorId := partition.Leader
+	if coordinatorId == t.nodeId && t.coordinator != nil {
+		errs, err := t.coordinator.VerifyPartitions(transactionalId, producerId, producerEpoch, partitions)
+		if err != nil {
+			// Like in the response of a remote coordinator, the error of the producer is the one of every partition
+			errs = make(map[storage.TopicPartition]error, len(partitions))
+			for _, partition := range partitions {
+				errs[partition] = err
+			}
+		}
+		return errs, nil
+	}
+
+	verified := AddPartitionsToTxnTransaction{
+		TransactionalId: transactionalId,
+		ProducerId:      producerId,
+		ProducerEpoch:   producerEpoch,
+		VerifyOnly:      true,
+		Topics:          []AddPartitionsToTxnTopic{},
+		TaggedFields:    map[string]string{},
+	}
+	topics := map[string]int{}
+	for _, partition := range partitions {
+		position, ok := topics[partition.Topic]
+		if !ok {
+			position = len(verified.Topics)
+			topics[partition.Topic] = position
+			verified.Topics = append(verified.Topics, AddPartitionsToTxnTopic{Name: partition.Topic, Partitions: []int32{}, TaggedFields: map[string]string{}})
+		}
+		verified.Topics[position].Partitions = append(verified.Topics[position].Partitions, partition.Partition)
+	}
+	req := &AddPartitionsToTxnRequest{Transactions: []AddPartitionsToTxnTransaction{verified}, TaggedFields: map[string]string{}}
+
+	buffer, err := t.client(coordinatorId).send(coordinatorId, func() (string, error) { return t.address(coordinatorId) }, AddPartitionsToTxn, 4, t.timeout, func(header RequestHeader) ([]byte, error) {
+		req.Header = header
+		return req.Serialize()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: AddPartitionsToTxn to broker %d: %w", transaction.ErrCoordinatorNotAvailable, coordinatorId, err)
+	}
+
+	response, err := parseAddPartitionsToTxnResponse(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if response.ErrorCode != int16(NONE) {
+		return nil, transactionError(response.ErrorCode)
+	}
+
+	// The partitions missing from the response are not verified
+	errs := make(map[storage.TopicPartition]error, len(partitions))
+	for _, partition := range partitions {
+		errs[partition] = fmt.Errorf("%w: broker %d did not answer for %s-%d", transaction.ErrCoordinatorNotAvailable, coordinatorId, partition.Topic, partition.Partition)
+	}
+	for _, result := range response.ResultsByTransaction {
+		for _, topic := range result.TopicResults {
+			for _, partition := range topic.Results {
+				topicPartition := storage.TopicPartition{Topic: topic.Name, Partition: partition.PartitionIndex}
+				if _, ok := errs[topicPartition]; ok {
+					errs[topicPartition] = transactionError(partition.ErrorCode)
+				}
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// transactionError maps the error codes of the responses of the coordinators back to their errors, nil for NONE
+func transactionError(errorCode int16) error {
+	switch KafkaErrorCode(errorCode) {
+	case NONE:
+		return nil
+	case NOT_COORDINATOR:
+		return transaction.ErrNotCoordinator
+	case COORDINATOR_LOAD_IN_PROGRESS:
+		return transaction.ErrCoordinatorLoadInProgress
+	case CONCURRENT_TRANSACTIONS:
+		return transaction.ErrConcurrentTransactions
+	case INVALID_TXN_STATE:
+		return transaction.ErrInvalidTxnState
+	case PRODUCER_FENCED:
+		return transaction.ErrProducerFenced
+	case INVALID_PRODUCER_ID_MAPPING:
+		return transaction.ErrInvalidProducerIdMapping
+	default:
+		return fmt.Errorf("%w: the coordinator failed the verification: %s", transaction.ErrCoordinatorNotAvailable, KafkaErrorCodeNames[KafkaErrorCode(errorCode)])
+	}
+}
+
+// appendMarker appends a marker to the partitions the broker leads, and waits until it is replicated
+func (t *TransactionTransport) appendMarker(marker transaction.Marker) map[storage.TopicPartition]error {
+	controlType := storage.ABORT_MARKER
+	if marker.Commit {
+		controlType = storage.COMMIT_MARKER
+	}
+	batch := storage.NewControlBatch(marker.ProducerId, marker.ProducerEpoch, marker.CoordinatorEpoch, controlType, t.now().UnixMilli())
+	records := make(map[storage.TopicPartition][]byte, len(marker.Partitions))
+	for _, partition := range marker.Partitions {
+		records[partition] = batch
+	}
+
+	appended := make(chan map[storage.TopicPartition]replica.AppendResult, 1)
+	t.replicas.AppendAsCoordinator(t.timeout, records, func(results map[storage.TopicPartition]replica.AppendResult) {
+		appended <- results
+	})
+
+	errs := make(map[storage.TopicPartition]error, len(marker.Partitions))
+	for partition, result := range <-appended {
+		errs[partition] = result.Err
+	}
+	return errs
+}
+
+// client returns the connection to a leader
+func (t *TransactionTransport) client(leaderId int32) *nodeClient {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	client, ok := t.clients[leaderId]
+	if !ok {
+		client = newNodeClient(fmt.Sprintf("broker-%d-txn-marker-sender-%d", t.nodeId, leaderId), t.timeout, t.retryBackoff, transactionMaxResponseSize)
+		t.clients[leaderId] = client
+	}
+	return client
+}
+
+// address is the endpoint the registration of a broker advertises for the inter-broker listener
+func (t *TransactionTransport) address(brokerId int32) (string, error) {
+	broker, ok := t.loader.Image().Broker(brokerId)
+	if !ok {
+		return "", fmt.Errorf("%w: %d", metadata.ErrUnknownBroker, brokerId)
+	}
+
+	for _, endpoint := range broker.Endpoints {
+		if endpoint.Name == t.listenerName {
+			return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port))), nil
+		}
+	}
+	return "", fmt.Errorf("broker %d has no %s endpoint", brokerId, t.listenerName)
+}
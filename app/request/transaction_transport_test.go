@@ -0,0 +1,144 @@
+package request
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+	"github.com/codecrafters-io/kafka-starter-go/app/transaction"
+)
+
+func TestTransactionTransportWritesMarkers(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+
+	// Broker 1 leads foo-0 and serves the markers of the other coordinators on its PLAINTEXT listener
+	listener, endpoint := listenBroker(t)
+	if _, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 1, IncarnationId: "00000000-0000-0000-0000-000000000007", Endpoints: []metadata.BrokerEndpoint{endpoint}}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := active.CreateTopic("foo", [][]int32{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	active.Node().Poll(now)
+
+	logs, replicas := newTestLogReplicas(t, loader, config.NewStore(1, map[string]string{}))
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		WriteTxnMarkers: &WriteTxnMarkersHandler{replicas: replicas, timeout: time.Second, authorizer: acl.NewAclAuthorizer(nil, true), now: time.Now},
+	})
+
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	unknown := storage.TopicPartition{Topic: "foo", Partition: 1}
+	marker := transaction.Marker{ProducerId: 5, ProducerEpoch: 0, Commit: true, CoordinatorEpoch: 3, Partitions: []storage.TopicPartition{foo, unknown}}
+
+	tests := []struct {
+		name     string
+		nodeId   int32
+		replicas *replica.Manager
+	}{
+		{"Remote leader", 2, nil},
+		{"Local leader", 1, replicas},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewTransactionTransport(tt.nodeId, "PLAINTEXT", loader, tt.replicas, time.Second, 10*time.Millisecond)
+			defer transport.Close()
+
+			errs, err := transport.WriteTxnMarkers(1, marker)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if errs[foo] != nil || !errors.Is(errs[unknown], replica.ErrUnknownTopicOrPartition) {
+				t.Errorf("expected foo-0 to have the marker and foo-1 to be unknown, got %v", errs)
+			}
+			if endOffset, err := logs.LogEndOffset(foo); err != nil || endOffset != int64(i+1) {
+				t.Errorf("expected %d markers in foo-0, got an end offset of %d: %v", i+1, endOffset, err)
+			}
+		})
+	}
+
+	// A broker without the listener is not reached
+	transport := NewTransactionTransport(2, "SSL", loader, nil, time.Second, 10*time.Millisecond)
+	defer transport.Close()
+	if _, err := transport.WriteTxnMarkers(1, marker); err == nil {
+		t.Errorf("expected an error for a leader without the listener")
+	}
+}
+
+func TestTransactionTransportVerifiesTransactions(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	loader := metadata.NewLoader(slog.New(slog.DiscardHandler))
+	active := newTestController(now, loader)
+
+	// Broker 1 is the coordinator of every transactional id and serves the verifications of the other leaders
+	listener, endpoint := listenBroker(t)
+	if _, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 1, IncarnationId: "00000000-0000-0000-0000-000000000007", Endpoints: []metadata.BrokerEndpoint{endpoint}}, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"foo", "bar"} {
+		if _, err := active.CreateTopic(topic, [][]int32{{1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active.Node().Poll(now)
+
+	logs, replicas := newTestLogReplicas(t, loader, config.NewStore(1, map[string]string{}))
+	coordinator := newTestCoordinator(t, active, loader, logs, replicas, now)
+	serveRequests(listener, map[KafkaAPIKey]RequestHandler{
+		AddPartitionsToTxn: &AddPartitionsToTxnHandler{loader: loader, coordinator: coordinator, authorizer: acl.NewAclAuthorizer(nil, true)},
+	})
+
+	transactionalId := "txn"
+	initialized := make(chan int64, 1)
+	coordinator.InitProducerId(&transactionalId, 60000, -1, -1, func(producerId int64, producerEpoch int16, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		initialized <- producerId
+	})
+	producerId := <-initialized
+	foo := storage.TopicPartition{Topic: "foo", Partition: 0}
+	bar := storage.TopicPartition{Topic: "bar", Partition: 0}
+	added := make(chan error, 1)
+	coordinator.AddPartitions(transactionalId, producerId, 0, []storage.TopicPartition{foo}, func(err error) { added <- err })
+	if err := <-added; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		nodeId      int32
+		coordinator *transaction.Coordinator
+	}{
+		{"Remote coordinator", 2, nil},
+		{"Local coordinator", 1, coordinator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewTransactionTransport(tt.nodeId, "PLAINTEXT", loader, replicas, time.Second, 10*time.Millisecond)
+			transport.coordinator = tt.coordinator
+			defer transport.Close()
+
+			errs, err := transport.VerifyTransaction(transactionalId, producerId, 0, []storage.TopicPartition{foo, bar})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if errs[foo] != nil || !errors.Is(errs[bar], transaction.ErrInvalidTxnState) {
+				t.Errorf("expected only foo-0 to be part of the transaction, got %v", errs)
+			}
+			if errs, err := transport.VerifyTransaction(transactionalId, producerId, 1, []storage.TopicPartition{foo}); err != nil || !errors.Is(errs[foo], transaction.ErrProducerFenced) {
+				t.Errorf("expected ErrProducerFenced, got %v, %v", errs, err)
+			}
+		})
+	}
+}
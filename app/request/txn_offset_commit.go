@@ -0,0 +1,328 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+type TxnOffsetCommitPartition struct {
+	PartitionIndex       int32
+	CommittedOffset      int64
+	CommittedLeaderEpoch int32
+	CommittedMetadata    *string
+	TaggedFields         map[string]string
+}
+
+type TxnOffsetCommitTopic struct {
+	Name         string
+	Partitions   []TxnOffsetCommitPartition
+	TaggedFields map[string]string
+}
+
+type TxnOffsetCommitRequest struct {
+	Header          RequestHeader
+	TransactionalId string
+	GroupId         string
+	ProducerId      int64
+	ProducerEpoch   int16
+	GenerationId    int32
+	MemberId        string
+	GroupInstanceId *string
+	Topics          []TxnOffsetCommitTopic
+	TaggedFields    map[string]string
+}
+
+func (r *TxnOffsetCommitRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *TxnOffsetCommitRequest) GetApiKey() KafkaAPIKey {
+	return TxnOffsetCommit
+}
+
+func (r *TxnOffsetCommitRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *TxnOffsetCommitRequest) Validate() error {
+	if r.Header.RequestApiVersion != 3 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type TxnOffsetCommitResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	Topics        []AddPartitionsToTxnTopicResult
+	TaggedFields  map[string]string
+}
+
+func (r *TxnOffsetCommitResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *TxnOffsetCommitResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *TxnOffsetCommitResponse) errorCounts() map[int16]int {
+	return (&AddPartitionsToTxnResponse{Results: r.Topics}).errorCounts()
+}
+
+// Serialize writes the response like the one of AddPartitionsToTxn, the topics and the errors of their partitions
+// after the throttle time
+func (r *TxnOffsetCommitResponse) Serialize(apiVersion int16) ([]byte, error) {
+	return (&AddPartitionsToTxnResponse{CorrelationId: r.CorrelationId, ThrottleTime: r.ThrottleTime, Results: r.Topics, TaggedFields: r.TaggedFields}).Serialize(apiVersion)
+}
+
+// TxnOffsetCommitHandler commits the offsets of a consumer group within the transaction of a producer: they are
+// written to the partition of __consumer_offsets of the group, which the producer added with AddOffsetsToTxn, as a
+// transactional batch of the producer. The marker of the transaction makes them visible or discards them
+type TxnOffsetCommitHandler struct {
+	configs *config.Store
+	loader  *metadata.Loader
+	// nil without partition logs
+	replicas   *replica.Manager
+	timeout    time.Duration
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *TxnOffsetCommitHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &TxnOffsetCommitRequest{}
+	req.Header = requestHeader
+
+	req.TransactionalId, index, err = parser.ExtractCompactString(buffer, index)
+	if err == nil {
+		req.GroupId, index, err = parser.ExtractCompactString(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse ids from TxnOffsetCommit request",
+		}
+	}
+
+	req.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+	if err == nil {
+		req.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse producer from TxnOffsetCommit request",
+		}
+	}
+
+	req.GenerationId, index, err = parser.ExtractInt32(buffer, index)
+	if err == nil {
+		req.MemberId, index, err = parser.ExtractCompactString(buffer, index)
+	}
+	if err == nil {
+		req.GroupInstanceId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	}
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse member from TxnOffsetCommit request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics from TxnOffsetCommit request",
+		}
+	}
+
+	req.Topics = make([]TxnOffsetCommitTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := TxnOffsetCommitTopic{}
+
+		var partitionsLength int
+		topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+		if err == nil {
+			partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		}
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic from TxnOffsetCommit request",
+			}
+		}
+
+		topic.Partitions = make([]TxnOffsetCommitPartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := TxnOffsetCommitPartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.CommittedOffset, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err == nil {
+				partition.CommittedLeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.CommittedMetadata, index, err = parser.ExtractCompactNullableString(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition from TxnOffsetCommit request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic from TxnOffsetCommit request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from TxnOffsetCommit request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *TxnOffsetCommitHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *TxnOffsetCommitHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*TxnOffsetCommitRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("TxnOffsetCommitHandler received %T instead of *TxnOffsetCommitRequest", req))
+		return
+	}
+
+	response := &TxnOffsetCommitResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		Topics:        make([]AddPartitionsToTxnTopicResult, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	errorCode := int16(NONE)
+	switch {
+	case !session.authorize(h.authorizer, acl.WRITE, acl.TRANSACTIONAL_ID, apiReq.TransactionalId):
+		errorCode = int16(TRANSACTIONAL_ID_AUTHORIZATION_FAILED)
+	case !session.authorize(h.authorizer, acl.READ, acl.GROUP, apiReq.GroupId):
+		errorCode = int16(GROUP_AUTHORIZATION_FAILED)
+	case h.replicas == nil:
+		errorCode = int16(NOT_COORDINATOR)
+	}
+
+	// The offsets of the authorized topics are committed together, the others fail on their own
+	image := h.loader.Image()
+	timestamp := h.now().UnixMilli()
+	records := []storage.Record{}
+	for _, topic := range apiReq.Topics {
+		result := AddPartitionsToTxnTopicResult{Name: topic.Name, Results: make([]AddPartitionsToTxnPartitionResult, 0, len(topic.Partitions)), TaggedFields: make(map[string]string)}
+
+		topicErrorCode := errorCode
+		topicImage, exists := image.Topic(topic.Name)
+		if topicErrorCode == int16(NONE) && !session.authorize(h.authorizer, acl.READ, acl.TOPIC, topic.Name) {
+			topicErrorCode = int16(TOPIC_AUTHORIZATION_FAILED)
+		}
+		for _, partition := range topic.Partitions {
+			partitionErrorCode := topicErrorCode
+			if partitionErrorCode == int16(NONE) && (!exists || topicImage.Partitions[partition.PartitionIndex] == nil) {
+				partitionErrorCode = int16(UNKNOWN_TOPIC_OR_PARTITION)
+			}
+			if partitionErrorCode == int16(NONE) {
+				records = append(records, storage.Record{
+					Key:   offsetCommitKey(apiReq.GroupId, topic.Name, partition.PartitionIndex),
+					Value: offsetCommitValue(partition, timestamp),
+				})
+			}
+			result.Results = append(result.Results, AddPartitionsToTxnPartitionResult{PartitionIndex: partition.PartitionIndex, ErrorCode: partitionErrorCode, TaggedFields: make(map[string]string)})
+		}
+
+		response.Topics = append(response.Topics, result)
+	}
+
+	if len(records) == 0 {
+		respond(response, nil)
+		return
+	}
+
+	offsetsPartition := offsetsPartition(image, h.configs, apiReq.GroupId)
+	batch := storage.NewTransactionalBatch(apiReq.ProducerId, apiReq.ProducerEpoch, timestamp, records)
+	h.replicas.AppendAsCoordinator(h.timeout, map[storage.TopicPartition][]byte{offsetsPartition: batch}, func(results map[storage.TopicPartition]replica.AppendResult) {
+		if err := results[offsetsPartition].Err; err != nil {
+			errorCode := offsetCommitErrorCode(err)
+			for i := range response.Topics {
+				for j := range response.Topics[i].Results {
+					if response.Topics[i].Results[j].ErrorCode == int16(NONE) {
+						response.Topics[i].Results[j].ErrorCode = errorCode
+					}
+				}
+			}
+		}
+		respond(response, nil)
+	})
+}
+
+// offsetCommitErrorCode maps the errors of the append to the partition of __consumer_offsets of a group: the broker
+// that does not lead it is not the coordinator of the group
+func offsetCommitErrorCode(err error) int16 {
+	switch errorCode := replicaErrorCode(err); errorCode {
+	case NOT_LEADER_OR_FOLLOWER, UNKNOWN_TOPIC_OR_PARTITION, KAFKA_STORAGE_ERROR:
+		return int16(NOT_COORDINATOR)
+	case NOT_ENOUGH_REPLICAS, NOT_ENOUGH_REPLICAS_AFTER_APPEND, REQUEST_TIMED_OUT:
+		return int16(COORDINATOR_NOT_AVAILABLE)
+	default:
+		return int16(errorCode)
+	}
+}
+
+// offsetCommitKey is the key of the record of the committed offset of a partition for a group in __consumer_offsets,
+// version 1 of the key of Kafka
+func offsetCommitKey(groupId string, topic string, partition int32) []byte {
+	key := binary.BigEndian.AppendUint16(nil, 1) // Version
+	key = binary.BigEndian.AppendUint16(key, uint16(len(groupId)))
+	key = append(key, groupId...)
+	key = binary.BigEndian.AppendUint16(key, uint16(len(topic)))
+	key = append(key, topic...)
+	return binary.BigEndian.AppendUint32(key, uint32(partition))
+}
+
+// offsetCommitValue is the value of the record of a committed offset, version 3 of the value of Kafka
+func offsetCommitValue(partition TxnOffsetCommitPartition, timestamp int64) []byte {
+	metadata := ""
+	if partition.CommittedMetadata != nil {
+		metadata = *partition.CommittedMetadata
+	}
+
+	value := binary.BigEndian.AppendUint16(nil, 3) // Version
+	value = binary.BigEndian.AppendUint64(value, uint64(partition.CommittedOffset))
+	value = binary.BigEndian.AppendUint32(value, uint32(partition.CommittedLeaderEpoch))
+	value = binary.BigEndian.AppendUint16(value, uint16(len(metadata)))
+	value = append(value, metadata...)
+	return binary.BigEndian.AppendUint64(value, uint64(timestamp))
+}
@@ -0,0 +1,173 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestTxnOffsetCommitParseRequestBody(t *testing.T) {
+	handler := TxnOffsetCommitHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x43, // MessageSize: 67
+		0x00, 0x1C, // RequestApiKey: 28 (TxnOffsetCommit)
+		0x00, 0x03, // RequestApiVersion: 3
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x04, 't', 'x', 'n', // TransactionalId: "txn"
+		0x06, 'g', 'r', 'o', 'u', 'p', // GroupId: "group"
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x00, // ProducerEpoch: 0
+		0xFF, 0xFF, 0xFF, 0xFF, // GenerationId: -1
+		0x01,                // MemberId: ""
+		0x00,                // GroupInstanceId: null
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x02,                   // Partitions array length (1 partition + 1)
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A, // CommittedOffset: 10
+		0xFF, 0xFF, 0xFF, 0xFF, // CommittedLeaderEpoch: -1
+		0x00, // CommittedMetadata: null
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &TxnOffsetCommitRequest{
+		Header:          header,
+		TransactionalId: "txn",
+		GroupId:         "group",
+		ProducerId:      5,
+		ProducerEpoch:   0,
+		GenerationId:    -1,
+		MemberId:        "",
+		Topics: []TxnOffsetCommitTopic{{
+			Name:         "foo",
+			Partitions:   []TxnOffsetCommitPartition{{PartitionIndex: 0, CommittedOffset: 10, CommittedLeaderEpoch: -1, TaggedFields: map[string]string{}}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:60], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestTxnOffsetCommitHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+
+	// Anonymous can not read bar
+	authorizer := acl.NewAclAuthorizer(nil, true)
+	authorizer.Load([]acl.Binding{
+		{ResourceType: acl.TOPIC, ResourceName: "bar", PatternType: acl.LITERAL, Principal: "User:ANONYMOUS", Host: "*", Operation: acl.READ, PermissionType: acl.DENY},
+	})
+
+	topics := []TxnOffsetCommitTopic{
+		{Name: "foo", Partitions: []TxnOffsetCommitPartition{{PartitionIndex: 0, CommittedOffset: 10, CommittedLeaderEpoch: 2, CommittedMetadata: stringPtr("m")}, {PartitionIndex: 1, CommittedOffset: 10, CommittedLeaderEpoch: -1}}},
+		{Name: "bar", Partitions: []TxnOffsetCommitPartition{{PartitionIndex: 0, CommittedOffset: 10, CommittedLeaderEpoch: -1}}},
+	}
+	tests := []struct {
+		name        string
+		replicas    *replica.Manager
+		authorizer  acl.Authorizer
+		offsetTopic bool
+		want        map[string][]KafkaErrorCode
+	}{
+		{"Unauthorized transactional id", replicas, denyAllAuthorizer{}, false, map[string][]KafkaErrorCode{"foo": {TRANSACTIONAL_ID_AUTHORIZATION_FAILED, TRANSACTIONAL_ID_AUTHORIZATION_FAILED}, "bar": {TRANSACTIONAL_ID_AUTHORIZATION_FAILED}}},
+		{"No replicas", nil, authorizer, false, map[string][]KafkaErrorCode{"foo": {NOT_COORDINATOR, NOT_COORDINATOR}, "bar": {NOT_COORDINATOR}}},
+		{"No offsets topic", replicas, authorizer, false, map[string][]KafkaErrorCode{"foo": {NOT_COORDINATOR, UNKNOWN_TOPIC_OR_PARTITION}, "bar": {TOPIC_AUTHORIZATION_FAILED}}},
+		{"Offsets written", replicas, authorizer, true, map[string][]KafkaErrorCode{"foo": {NONE, UNKNOWN_TOPIC_OR_PARTITION}, "bar": {TOPIC_AUTHORIZATION_FAILED}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.offsetTopic {
+				if _, err := active.CreateTopic(CONSUMER_OFFSETS_TOPIC, [][]int32{{1}}); err != nil {
+					t.Fatal(err)
+				}
+				active.Node().Poll(now)
+			}
+
+			handler := &TxnOffsetCommitHandler{configs: configs, loader: loader, replicas: tt.replicas, timeout: time.Second, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &TxnOffsetCommitRequest{
+				Header:          RequestHeader{RequestApiKey: 28, RequestApiVersion: 3, CorrelationId: 7},
+				TransactionalId: "txn",
+				GroupId:         "group",
+				ProducerId:      5,
+				ProducerEpoch:   0,
+				GenerationId:    -1,
+				Topics:          topics,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			results := map[string][]KafkaErrorCode{}
+			for _, topic := range got.(*TxnOffsetCommitResponse).Topics {
+				for _, partition := range topic.Results {
+					results[topic.Name] = append(results[topic.Name], KafkaErrorCode(partition.ErrorCode))
+				}
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, results)
+			}
+		})
+	}
+
+	// The offset of foo-0 is a record of the transaction of the producer in the offsets of the group
+	data, err := logs.Read(storage.TopicPartition{Topic: CONSUMER_OFFSETS_TOPIC, Partition: 0}, 0, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := storage.ReadRecords(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.Record{{
+		Offset: 0,
+		Key: []byte{
+			0x00, 0x01, // Version: 1
+			0x00, 0x05, 'g', 'r', 'o', 'u', 'p', // Group: "group"
+			0x00, 0x03, 'f', 'o', 'o', // Topic: "foo"
+			0x00, 0x00, 0x00, 0x00, // Partition: 0
+		},
+		Value: []byte{
+			0x00, 0x03, // Version: 3
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A, // Offset: 10
+			0x00, 0x00, 0x00, 0x02, // LeaderEpoch: 2
+			0x00, 0x01, 'm', // Metadata: "m"
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x0F, 0x42, 0x40, // CommitTimestamp: 1000000
+		},
+	}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("expected the offset records %v, got %v", want, records)
+	}
+	if lastStableOffset, err := logs.LastStableOffset(storage.TopicPartition{Topic: CONSUMER_OFFSETS_TOPIC, Partition: 0}, 1); err != nil || lastStableOffset != 0 {
+		t.Errorf("expected the offsets to wait for the end of the transaction, got a last stable offset of %d: %v", lastStableOffset, err)
+	}
+}
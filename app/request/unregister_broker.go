@@ -0,0 +1,163 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type UnregisterBrokerRequest struct {
+	Header       RequestHeader
+	BrokerId     int32
+	TaggedFields map[string]string
+}
+
+func (r *UnregisterBrokerRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *UnregisterBrokerRequest) GetApiKey() KafkaAPIKey {
+	return UnregisterBroker
+}
+
+func (r *UnregisterBrokerRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *UnregisterBrokerRequest) Validate() error {
+	if r.Header.RequestApiVersion != 0 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+type UnregisterBrokerResponse struct {
+	CorrelationId int32
+	ThrottleTime  int32
+	ErrorCode     int16
+	ErrorMessage  *string
+	TaggedFields  map[string]string
+}
+
+func (r *UnregisterBrokerResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *UnregisterBrokerResponse) setThrottleTime(throttleTimeMs int32) {
+	r.ThrottleTime = throttleTimeMs
+}
+
+func (r *UnregisterBrokerResponse) errorCounts() map[int16]int {
+	return map[int16]int{r.ErrorCode: 1}
+}
+
+func (r *UnregisterBrokerResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	if r.ErrorMessage != nil {
+		bufferSize += len(*r.ErrorMessage)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.ThrottleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ErrorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// UnregisterBrokerHandler removes a decommissioned broker from the cluster metadata, on the active controller
+type UnregisterBrokerHandler struct {
+	controller *controller.Controller
+	authorizer acl.Authorizer
+}
+
+func (h *UnregisterBrokerHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &UnregisterBrokerRequest{}
+	req.Header = requestHeader
+
+	req.BrokerId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse broker id from UnregisterBroker request",
+		}
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from UnregisterBroker request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *UnregisterBrokerHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*UnregisterBrokerRequest)
+	if !ok {
+		return nil, fmt.Errorf("UnregisterBrokerHandler received %T instead of *UnregisterBrokerRequest", req)
+	}
+
+	response := &UnregisterBrokerResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.ALTER, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	err := controller.ErrNotController
+	if h.controller != nil {
+		err = h.controller.UnregisterBroker(apiReq.BrokerId)
+	}
+	if err != nil {
+		response.ErrorCode, response.ErrorMessage = controllerErrorCode(err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,92 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+func TestUnregisterBrokerParseRequestBody(t *testing.T) {
+	handler := UnregisterBrokerHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x14, // MessageSize: 20
+		0x00, 0x40, // RequestApiKey: 64 (UnregisterBroker)
+		0x00, 0x00, // RequestApiVersion: 0
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00, 0x00, 0x00, 0x02, // BrokerId: 2
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 64, RequestApiVersion: 0, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &UnregisterBrokerRequest{Header: header, BrokerId: 2, TaggedFields: map[string]string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:21], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestUnregisterBrokerHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	active := newTestController(now)
+
+	if _, err := active.RegisterBroker(controller.BrokerRegistration{BrokerId: 2, IncarnationId: "00000000-0000-0000-0000-000000000007"}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		controller    *controller.Controller
+		authorizer    acl.Authorizer
+		wantErrorCode KafkaErrorCode
+	}{
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), NOT_CONTROLLER},
+		{"Not authorized", active, denyAllAuthorizer{}, CLUSTER_AUTHORIZATION_FAILED},
+		{"Unregistered", active, acl.NewAclAuthorizer(nil, true), NONE},
+		{"Not registered", active, acl.NewAclAuthorizer(nil, true), BROKER_ID_NOT_REGISTERED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := UnregisterBrokerHandler{controller: tt.controller, authorizer: tt.authorizer}
+			request := UnregisterBrokerRequest{
+				Header:   RequestHeader{RequestApiKey: 64, RequestApiVersion: 0, CorrelationId: 7},
+				BrokerId: 2,
+			}
+
+			got, err := handler.Handle(NewSession("127.0.0.1"), &request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotResp, ok := got.(*UnregisterBrokerResponse)
+			if !ok {
+				t.Fatalf("expected *UnregisterBrokerResponse, got %T", got)
+			}
+
+			if gotResp.ErrorCode != int16(tt.wantErrorCode) {
+				t.Errorf("error code mismatch: got %d, want %d", gotResp.ErrorCode, tt.wantErrorCode)
+			}
+			if (gotResp.ErrorMessage != nil) != (tt.wantErrorCode == NOT_CONTROLLER || tt.wantErrorCode == BROKER_ID_NOT_REGISTERED) {
+				t.Errorf("unexpected error message %v", gotResp.ErrorMessage)
+			}
+		})
+	}
+}
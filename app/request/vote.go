@@ -0,0 +1,560 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+)
+
+type VotePartition struct {
+	PartitionIndex     int32
+	ReplicaEpoch       int32
+	ReplicaId          int32
+	ReplicaDirectoryId string
+	// The directory id the candidate expects of the voter, the zero uuid when it does not know it
+	VoterDirectoryId string
+	LastOffsetEpoch  int32
+	LastOffset       int64
+	TaggedFields     map[string]string
+}
+
+type VoteTopic struct {
+	TopicName    string
+	Partitions   []VotePartition
+	TaggedFields map[string]string
+}
+
+type VoteRequest struct {
+	Header       RequestHeader
+	ClusterId    *string
+	VoterId      int32
+	Topics       []VoteTopic
+	TaggedFields map[string]string
+}
+
+func (r *VoteRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *VoteRequest) GetApiKey() KafkaAPIKey {
+	return Vote
+}
+
+func (r *VoteRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *VoteRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the quorum transport sends to a voter
+func (r *VoteRequest) Serialize() ([]byte, error) {
+	bufferSize := 64 + len(r.Header.ClientId)
+	if r.ClusterId != nil {
+		bufferSize += len(*r.ClusterId)
+	}
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.TopicName) + 64*len(topic.Partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeCompactNullableString(buffer, index, r.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, r.VoterId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			for _, value := range []int32{partition.PartitionIndex, partition.ReplicaEpoch, partition.ReplicaId} {
+				index, err = serializer.SerializeInt32(buffer, index, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			for _, directoryId := range []string{partition.ReplicaDirectoryId, partition.VoterDirectoryId} {
+				index, err = serializer.SerializeUUID(buffer, index, directoryId)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LastOffsetEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt64(buffer, index, partition.LastOffset)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// QuorumPartitionResult is the answer of a voter to Vote, BeginQuorumEpoch and EndQuorumEpoch, which have the same
+// partition fields but for VoteGranted
+type QuorumPartitionResult struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	LeaderId       int32
+	LeaderEpoch    int32
+	VoteGranted    bool
+	TaggedFields   map[string]string
+}
+
+type QuorumTopicResult struct {
+	TopicName    string
+	Partitions   []QuorumPartitionResult
+	TaggedFields map[string]string
+}
+
+// QuorumEpochResponse is the response of Vote, BeginQuorumEpoch and EndQuorumEpoch
+type QuorumEpochResponse struct {
+	ApiKey        KafkaAPIKey
+	CorrelationId int32
+	ErrorCode     int16
+	Topics        []QuorumTopicResult
+	TaggedFields  map[string]string
+}
+
+func (r *QuorumEpochResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *QuorumEpochResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{r.ErrorCode: 1}
+	for _, topic := range r.Topics {
+		for _, partition := range topic.Partitions {
+			counts[partition.ErrorCode]++
+		}
+	}
+	return counts
+}
+
+func (r *QuorumEpochResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, topic := range r.Topics {
+		bufferSize += 16 + len(topic.TopicName) + 24*len(topic.Partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, r.ErrorCode)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Topics)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range r.Topics {
+		index, err = serializer.SerializeCompactString(buffer, index, topic.TopicName)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topic.Partitions {
+			index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderId)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeInt32(buffer, index, partition.LeaderEpoch)
+			if err != nil {
+				return nil, err
+			}
+
+			// Only Vote grants anything
+			if r.ApiKey == Vote {
+				index, err = serializer.SerializeBoolean(buffer, index, partition.VoteGranted)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseQuorumEpochResponse reads the response of a voter to Vote, BeginQuorumEpoch or EndQuorumEpoch
+func parseQuorumEpochResponse(apiKey KafkaAPIKey, buffer []byte) (*QuorumEpochResponse, error) {
+	response := &QuorumEpochResponse{ApiKey: apiKey}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	response.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse error code from %s response: %w", KafkaAPIKeyNames[apiKey], err)
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, fmt.Errorf("failed to parse topics length from %s response", KafkaAPIKeyNames[apiKey])
+	}
+
+	response.Topics = make([]QuorumTopicResult, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := QuorumTopicResult{}
+
+		topic.TopicName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic name from %s response: %w", KafkaAPIKeyNames[apiKey], err)
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, fmt.Errorf("failed to parse partitions length from %s response", KafkaAPIKeyNames[apiKey])
+		}
+
+		topic.Partitions = make([]QuorumPartitionResult, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := QuorumPartitionResult{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderId, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.LeaderEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil && apiKey == Vote {
+				partition.VoteGranted, index, err = parser.ExtractBoolean(buffer, index)
+			}
+			if err == nil {
+				partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse partition from %s response: %w", KafkaAPIKeyNames[apiKey], err)
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse topic tagged fields from %s response: %w", KafkaAPIKeyNames[apiKey], err)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tagged fields from %s response: %w", KafkaAPIKeyNames[apiKey], err)
+	}
+
+	return response, nil
+}
+
+// metadataPartitionResult is the only partition of a response to a quorum request, or an error when the response
+// has none
+func (r *QuorumEpochResponse) metadataPartitionResult() (QuorumPartitionResult, error) {
+	if r.ErrorCode != int16(NONE) {
+		return QuorumPartitionResult{}, fmt.Errorf("%s failed: %s", KafkaAPIKeyNames[r.ApiKey], KafkaErrorCodeNames[KafkaErrorCode(r.ErrorCode)])
+	}
+	if len(r.Topics) != 1 || len(r.Topics[0].Partitions) != 1 {
+		return QuorumPartitionResult{}, fmt.Errorf("%s response without the metadata partition", KafkaAPIKeyNames[r.ApiKey])
+	}
+	return r.Topics[0].Partitions[0], nil
+}
+
+// VoteHandler asks the metadata quorum of this controller for its vote
+type VoteHandler struct {
+	quorum     *raft.Node
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *VoteHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	var err error
+	req := &VoteRequest{}
+	req.Header = requestHeader
+
+	req.ClusterId, index, err = parser.ExtractCompactNullableString(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse cluster id from Vote request",
+		}
+	}
+
+	req.VoterId, index, err = parser.ExtractInt32(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse voter id from Vote request",
+		}
+	}
+
+	topicsLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || topicsLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse topics length from Vote request",
+		}
+	}
+
+	req.Topics = make([]VoteTopic, 0, topicsLength)
+	for i := 0; i < topicsLength; i++ {
+		topic := VoteTopic{}
+
+		topic.TopicName, index, err = parser.ExtractCompactString(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topic name from Vote request at index %d", i),
+			}
+		}
+
+		var partitionsLength int
+		partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		if err != nil || partitionsLength < 0 {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse partitions length from Vote request",
+			}
+		}
+
+		topic.Partitions = make([]VotePartition, 0, partitionsLength)
+		for j := 0; j < partitionsLength; j++ {
+			partition := VotePartition{}
+
+			partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+			if err == nil {
+				partition.ReplicaEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.ReplicaId, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.ReplicaDirectoryId, index, err = parser.ExtractUUID(buffer, index)
+			}
+			if err == nil {
+				partition.VoterDirectoryId, index, err = parser.ExtractUUID(buffer, index)
+			}
+			if err == nil {
+				partition.LastOffsetEpoch, index, err = parser.ExtractInt32(buffer, index)
+			}
+			if err == nil {
+				partition.LastOffset, index, err = parser.ExtractInt64(buffer, index)
+			}
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: fmt.Sprintf("Failed to parse partition from Vote request at index %d", j),
+				}
+			}
+
+			partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, &RequestParseError{
+					Code:    INVALID_REQUEST,
+					Message: "Failed to parse partition tagged fields from Vote request",
+				}
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse topic tagged fields from Vote request",
+			}
+		}
+
+		req.Topics = append(req.Topics, topic)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from Vote request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *VoteHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	apiReq, ok := req.(*VoteRequest)
+	if !ok {
+		return nil, fmt.Errorf("VoteHandler received %T instead of *VoteRequest", req)
+	}
+
+	response := &QuorumEpochResponse{
+		ApiKey:        Vote,
+		CorrelationId: apiReq.Header.CorrelationId,
+		ErrorCode:     int16(NONE),
+		Topics:        make([]QuorumTopicResult, 0, len(apiReq.Topics)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	if !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName) {
+		response.ErrorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+		return response, nil
+	}
+
+	for _, requestTopic := range apiReq.Topics {
+		topic := QuorumTopicResult{TopicName: requestTopic.TopicName, Partitions: []QuorumPartitionResult{}, TaggedFields: map[string]string{}}
+
+		for _, requestPartition := range requestTopic.Partitions {
+			partition := quorumPartitionResult(h.quorum, requestTopic.TopicName, requestPartition.PartitionIndex)
+			if partition.ErrorCode == int16(NONE) {
+				voted := h.quorum.HandleVote(raft.VoteRequest{
+					CandidateEpoch:       requestPartition.ReplicaEpoch,
+					CandidateId:          requestPartition.ReplicaId,
+					CandidateDirectoryId: requestPartition.ReplicaDirectoryId,
+					LastOffsetEpoch:      requestPartition.LastOffsetEpoch,
+					LastOffset:           requestPartition.LastOffset,
+				}, h.now())
+
+				partition.ErrorCode = voted.ErrorCode
+				partition.LeaderId, partition.LeaderEpoch = voted.LeaderId, voted.LeaderEpoch
+				partition.VoteGranted = voted.VoteGranted
+			}
+
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+
+		response.Topics = append(response.Topics, topic)
+	}
+
+	return response, nil
+}
+
+// quorumPartitionResult starts the result of a quorum request for a partition. Only the partition of the metadata
+// log is replicated by the quorum, and only controllers take part in it
+func quorumPartitionResult(quorum *raft.Node, topicName string, partitionIndex int32) QuorumPartitionResult {
+	result := QuorumPartitionResult{
+		PartitionIndex: partitionIndex,
+		ErrorCode:      int16(NONE),
+		LeaderId:       raft.NO_LEADER,
+		LeaderEpoch:    -1,
+		TaggedFields:   map[string]string{},
+	}
+
+	switch {
+	case topicName != clusterMetadataTopic || partitionIndex != 0:
+		result.ErrorCode = int16(UNKNOWN_TOPIC_OR_PARTITION)
+	case quorum == nil:
+		result.ErrorCode = int16(NOT_LEADER_OR_FOLLOWER)
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/raft"
+)
+
+func TestVoteParseRequestBody(t *testing.T) {
+	handler := VoteHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x64, // MessageSize: 100
+		0x00, 0x34, // RequestApiKey: 52 (Vote)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x00,                   // ClusterId: null
+		0x00, 0x00, 0x00, 0x02, // VoterId: 2
+		0x02,                                                                                           // Topics array length: 1
+		0x13, '_', '_', 'c', 'l', 'u', 's', 't', 'e', 'r', '_', 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', // TopicName: "__cluster_metadata"
+		0x02,                   // Partitions array length: 1
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x00, 0x00, 0x05, // ReplicaEpoch: 5
+		0x00, 0x00, 0x00, 0x01, // ReplicaId: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // ReplicaDirectoryId
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // VoterDirectoryId
+		0x00, 0x00, 0x00, 0x04, // LastOffsetEpoch: 4
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A, // LastOffset: 10
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header := RequestHeader{RequestApiKey: 52, RequestApiVersion: 1, CorrelationId: 66, ClientId: "test"}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &VoteRequest{
+		Header:  header,
+		VoterId: 2,
+		Topics: []VoteTopic{{
+			TopicName: clusterMetadataTopic,
+			Partitions: []VotePartition{{
+				PartitionIndex:     0,
+				ReplicaEpoch:       5,
+				ReplicaId:          1,
+				ReplicaDirectoryId: "00000000-0000-0000-0000-000000000001",
+				VoterDirectoryId:   zeroUuid,
+				LastOffsetEpoch:    4,
+				LastOffset:         10,
+				TaggedFields:       map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRequestBody() = %+v, want %+v", got, want)
+	}
+
+	// The request the quorum transport sends is the one parsed above
+	serialized, err := want.Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(serialized, input) {
+		t.Errorf("Serialize() = %v, want %v", serialized, input)
+	}
+}
+
+func TestVoteHandle(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	leader := newTestQuorum(now)
+
+	tests := []struct {
+		name       string
+		quorum     *raft.Node
+		authorizer acl.Authorizer
+		topicName  string
+		epoch      int32
+		wantError  KafkaErrorCode
+		want       []QuorumPartitionResult
+	}{
+		{"Not authorized", leader, denyAllAuthorizer{}, clusterMetadataTopic, 2, CLUSTER_AUTHORIZATION_FAILED, nil},
+		{"Not a controller", nil, acl.NewAclAuthorizer(nil, true), clusterMetadataTopic, 2, NONE, []QuorumPartitionResult{
+			{PartitionIndex: 0, ErrorCode: int16(NOT_LEADER_OR_FOLLOWER), LeaderId: raft.NO_LEADER, LeaderEpoch: -1, TaggedFields: map[string]string{}},
+		}},
+		{"Unknown topic", leader, acl.NewAclAuthorizer(nil, true), "foo", 2, NONE, []QuorumPartitionResult{
+			{PartitionIndex: 0, ErrorCode: int16(UNKNOWN_TOPIC_OR_PARTITION), LeaderId: raft.NO_LEADER, LeaderEpoch: -1, TaggedFields: map[string]string{}},
+		}},
+		{"Stale epoch", leader, acl.NewAclAuthorizer(nil, true), clusterMetadataTopic, 0, NONE, []QuorumPartitionResult{
+			{PartitionIndex: 0, ErrorCode: int16(FENCED_LEADER_EPOCH), LeaderId: 1, LeaderEpoch: 1, TaggedFields: map[string]string{}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &VoteHandler{quorum: tt.quorum, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			request := &VoteRequest{
+				Header:  RequestHeader{RequestApiKey: 52, RequestApiVersion: 1, CorrelationId: 7},
+				VoterId: 1,
+				Topics: []VoteTopic{{
+					TopicName:  tt.topicName,
+					Partitions: []VotePartition{{PartitionIndex: 0, ReplicaEpoch: tt.epoch, ReplicaId: 2, ReplicaDirectoryId: zeroUuid, VoterDirectoryId: zeroUuid}},
+				}},
+			}
+
+			response, err := handler.Handle(NewSession("127.0.0.1"), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := response.(*QuorumEpochResponse)
+			if got.ErrorCode != int16(tt.wantError) {
+				t.Fatalf("expected error %d, got %d", tt.wantError, got.ErrorCode)
+			}
+			if tt.want == nil {
+				if len(got.Topics) != 0 {
+					t.Errorf("expected no topics, got %+v", got.Topics)
+				}
+				return
+			}
+			if len(got.Topics) != 1 || !reflect.DeepEqual(got.Topics[0].Partitions, tt.want) {
+				t.Errorf("expected partitions %+v, got %+v", tt.want, got.Topics)
+			}
+		})
+	}
+}
+
+func TestQuorumEpochResponseRoundTrip(t *testing.T) {
+	for _, apiKey := range []KafkaAPIKey{Vote, BeginQuorumEpoch, EndQuorumEpoch} {
+		response := &QuorumEpochResponse{
+			ApiKey:        apiKey,
+			CorrelationId: 7,
+			ErrorCode:     int16(NONE),
+			Topics: []QuorumTopicResult{{
+				TopicName: clusterMetadataTopic,
+				Partitions: []QuorumPartitionResult{
+					{PartitionIndex: 0, ErrorCode: int16(NONE), LeaderId: 1, LeaderEpoch: 3, VoteGranted: apiKey == Vote, TaggedFields: map[string]string{}},
+				},
+				TaggedFields: map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}
+
+		serialized, err := response.Serialize(1)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", KafkaAPIKeyNames[apiKey], err)
+		}
+		got, err := parseQuorumEpochResponse(apiKey, serialized)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", KafkaAPIKeyNames[apiKey], err)
+		}
+		if !reflect.DeepEqual(got, response) {
+			t.Errorf("%s: parsed %+v, want %+v", KafkaAPIKeyNames[apiKey], got, response)
+		}
+	}
+}
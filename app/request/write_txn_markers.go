@@ -0,0 +1,526 @@
+package request
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+type WritableTxnMarkerTopic struct {
+	Name             string
+	PartitionIndexes []int32
+	TaggedFields     map[string]string
+}
+
+type WritableTxnMarker struct {
+	ProducerId    int64
+	ProducerEpoch int16
+	// true to commit the transaction, false to abort it
+	TransactionResult bool
+	Topics            []WritableTxnMarkerTopic
+	CoordinatorEpoch  int32
+	TaggedFields      map[string]string
+}
+
+type WriteTxnMarkersRequest struct {
+	Header       RequestHeader
+	Markers      []WritableTxnMarker
+	TaggedFields map[string]string
+}
+
+func (r *WriteTxnMarkersRequest) GetHeader() RequestHeader {
+	return r.Header
+}
+
+func (r *WriteTxnMarkersRequest) GetApiKey() KafkaAPIKey {
+	return WriteTxnMarkers
+}
+
+func (r *WriteTxnMarkersRequest) GetApiVersion() int16 {
+	return r.Header.RequestApiVersion
+}
+
+func (r *WriteTxnMarkersRequest) Validate() error {
+	if r.Header.RequestApiVersion != 1 {
+		return &RequestParseError{Code: UNSUPPORTED_VERSION, Message: "Invalid version"}
+	}
+
+	return nil
+}
+
+// Serialize writes the request the transaction coordinator of a broker sends to the leaders of the partitions of a
+// transaction
+func (r *WriteTxnMarkersRequest) Serialize() ([]byte, error) {
+	bufferSize := 32 + len(r.Header.ClientId)
+	for _, marker := range r.Markers {
+		bufferSize += 32
+		for _, topic := range marker.Topics {
+			bufferSize += 16 + len(topic.Name) + 4*len(topic.PartitionIndexes)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializeRequestHeader(buffer, r.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Markers)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, marker := range r.Markers {
+		index, err = serializer.SerializeInt64(buffer, index, marker.ProducerId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt16(buffer, index, marker.ProducerEpoch)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeBoolean(buffer, index, marker.TransactionResult)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(marker.Topics)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topic := range marker.Topics {
+			index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializeInt32Array(buffer, index, topic.PartitionIndexes)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, marker.CoordinatorEpoch)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, marker.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+type WritableTxnMarkerPartitionResult struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	TaggedFields   map[string]string
+}
+
+type WritableTxnMarkerTopicResult struct {
+	Name         string
+	Partitions   []WritableTxnMarkerPartitionResult
+	TaggedFields map[string]string
+}
+
+type WritableTxnMarkerResult struct {
+	ProducerId   int64
+	Topics       []WritableTxnMarkerTopicResult
+	TaggedFields map[string]string
+}
+
+// WriteTxnMarkersResponse has no throttle time, the requests come from the brokers
+type WriteTxnMarkersResponse struct {
+	CorrelationId int32
+	Markers       []WritableTxnMarkerResult
+	TaggedFields  map[string]string
+}
+
+func (r *WriteTxnMarkersResponse) GetCorrelationId() int32 { return r.CorrelationId }
+
+func (r *WriteTxnMarkersResponse) errorCounts() map[int16]int {
+	counts := map[int16]int{}
+	for _, marker := range r.Markers {
+		for _, topic := range marker.Topics {
+			for _, partition := range topic.Partitions {
+				counts[partition.ErrorCode]++
+			}
+		}
+	}
+	return counts
+}
+
+func (r *WriteTxnMarkersResponse) Serialize(apiVersion int16) ([]byte, error) {
+	bufferSize := 32
+	for _, marker := range r.Markers {
+		bufferSize += 16
+		for _, topic := range marker.Topics {
+			bufferSize += 16 + len(topic.Name) + 8*len(topic.Partitions)
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	index := 0
+	var err error
+
+	// Message size (placeholder)
+	index += 4
+
+	index, err = serializer.SerializeInt32(buffer, index, r.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Response header tagged fields
+	index, err = serializer.SerializeTaggedFields(buffer, index, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(r.Markers)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, marker := range r.Markers {
+		index, err = serializer.SerializeInt64(buffer, index, marker.ProducerId)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(marker.Topics)+1))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topic := range marker.Topics {
+			index, err = serializer.SerializeCompactString(buffer, index, topic.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err = serializer.SerializeUnsignedVarInt(buffer, index, uint64(len(topic.Partitions)+1))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, partition := range topic.Partitions {
+				index, err = serializer.SerializeInt32(buffer, index, partition.PartitionIndex)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeInt16(buffer, index, partition.ErrorCode)
+				if err != nil {
+					return nil, err
+				}
+
+				index, err = serializer.SerializeTaggedFields(buffer, index, partition.TaggedFields)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			index, err = serializer.SerializeTaggedFields(buffer, index, topic.TaggedFields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		index, err = serializer.SerializeTaggedFields(buffer, index, marker.TaggedFields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index, err = serializer.SerializeTaggedFields(buffer, index, r.TaggedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in message size
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(index-4))
+
+	return buffer[:index], nil
+}
+
+// parseWriteTxnMarkersResponse reads the response of a leader to the transaction coordinator of a broker
+func parseWriteTxnMarkersResponse(buffer []byte) (*WriteTxnMarkersResponse, error) {
+	response := &WriteTxnMarkersResponse{}
+
+	correlationId, index, err := parseResponseHeader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	response.CorrelationId = correlationId
+
+	markersLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse WriteTxnMarkers response: %w", err)
+	}
+
+	response.Markers = make([]WritableTxnMarkerResult, 0, max(markersLength, 0))
+	for i := 0; i < markersLength; i++ {
+		marker := WritableTxnMarkerResult{}
+
+		var topicsLength int
+		marker.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+		if err == nil {
+			topicsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse marker of WriteTxnMarkers response: %w", err)
+		}
+
+		marker.Topics = make([]WritableTxnMarkerTopicResult, 0, max(topicsLength, 0))
+		for j := 0; j < topicsLength; j++ {
+			topic := WritableTxnMarkerTopicResult{}
+
+			var partitionsLength int
+			topic.Name, index, err = parser.ExtractCompactString(buffer, index)
+			if err == nil {
+				partitionsLength, index, err = parser.ExtractCompactArrayLength(buffer, index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse topic of WriteTxnMarkers response: %w", err)
+			}
+
+			topic.Partitions = make([]WritableTxnMarkerPartitionResult, 0, max(partitionsLength, 0))
+			for k := 0; k < partitionsLength; k++ {
+				partition := WritableTxnMarkerPartitionResult{}
+
+				partition.PartitionIndex, index, err = parser.ExtractInt32(buffer, index)
+				if err == nil {
+					partition.ErrorCode, index, err = parser.ExtractInt16(buffer, index)
+				}
+				if err == nil {
+					partition.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse partition of WriteTxnMarkers response: %w", err)
+				}
+
+				topic.Partitions = append(topic.Partitions, partition)
+			}
+
+			topic.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse topic of WriteTxnMarkers response: %w", err)
+			}
+
+			marker.Topics = append(marker.Topics, topic)
+		}
+
+		marker.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse marker of WriteTxnMarkers response: %w", err)
+		}
+
+		response.Markers = append(response.Markers, marker)
+	}
+
+	response.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse WriteTxnMarkers response: %w", err)
+	}
+
+	return response, nil
+}
+
+// WriteTxnMarkersHandler writes the markers the transaction coordinators send to the partitions the broker leads. A
+// marker is a control batch that commits or aborts the transaction of its producer in a partition, and is answered
+// once it is replicated to the ISR
+type WriteTxnMarkersHandler struct {
+	// nil without partition logs
+	replicas   *replica.Manager
+	timeout    time.Duration
+	authorizer acl.Authorizer
+	now        func() time.Time
+}
+
+func (h *WriteTxnMarkersHandler) ParseRequestBody(requestHeader RequestHeader, buffer []byte, index int) (KafkaRequest, error) {
+	req := &WriteTxnMarkersRequest{}
+	req.Header = requestHeader
+
+	markersLength, index, err := parser.ExtractCompactArrayLength(buffer, index)
+	if err != nil || markersLength < 0 {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse markers from WriteTxnMarkers request",
+		}
+	}
+
+	req.Markers = make([]WritableTxnMarker, 0, markersLength)
+	for i := 0; i < markersLength; i++ {
+		marker := WritableTxnMarker{}
+
+		marker.ProducerId, index, err = parser.ExtractInt64(buffer, index)
+		if err == nil {
+			marker.ProducerEpoch, index, err = parser.ExtractInt16(buffer, index)
+		}
+		if err == nil {
+			marker.TransactionResult, index, err = parser.ExtractBoolean(buffer, index)
+		}
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse producer from WriteTxnMarkers request",
+			}
+		}
+
+		var topics []AddPartitionsToTxnTopic
+		topics, index, err = parseTxnTopics(buffer, index)
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: fmt.Sprintf("Failed to parse topics from WriteTxnMarkers request: %v", err),
+			}
+		}
+		marker.Topics = make([]WritableTxnMarkerTopic, 0, len(topics))
+		for _, topic := range topics {
+			marker.Topics = append(marker.Topics, WritableTxnMarkerTopic{Name: topic.Name, PartitionIndexes: topic.Partitions, TaggedFields: topic.TaggedFields})
+		}
+
+		marker.CoordinatorEpoch, index, err = parser.ExtractInt32(buffer, index)
+		if err == nil {
+			marker.TaggedFields, index, err = parser.ExtractTagFields(buffer, index)
+		}
+		if err != nil {
+			return nil, &RequestParseError{
+				Code:    INVALID_REQUEST,
+				Message: "Failed to parse coordinator epoch from WriteTxnMarkers request",
+			}
+		}
+
+		req.Markers = append(req.Markers, marker)
+	}
+
+	req.TaggedFields, _, err = parser.ExtractTagFields(buffer, index)
+	if err != nil {
+		return nil, &RequestParseError{
+			Code:    INVALID_REQUEST,
+			Message: "Failed to parse tagged fields from WriteTxnMarkers request",
+		}
+	}
+
+	return req, nil
+}
+
+func (h *WriteTxnMarkersHandler) Handle(session *Session, req KafkaRequest) (KafkaResponse, error) {
+	return waitForResponse(h, session, req)
+}
+
+func (h *WriteTxnMarkersHandler) HandleDelayed(session *Session, req KafkaRequest, respond func(KafkaResponse, error)) {
+	apiReq, ok := req.(*WriteTxnMarkersRequest)
+	if !ok {
+		respond(nil, fmt.Errorf("WriteTxnMarkersHandler received %T instead of *WriteTxnMarkersRequest", req))
+		return
+	}
+
+	response := &WriteTxnMarkersResponse{
+		CorrelationId: apiReq.Header.CorrelationId,
+		Markers:       make([]WritableTxnMarkerResult, 0, len(apiReq.Markers)),
+		TaggedFields:  make(map[string]string),
+	}
+
+	errorCode := int16(NONE)
+	switch {
+	case !session.authorize(h.authorizer, acl.CLUSTER_ACTION, acl.CLUSTER, acl.ClusterResourceName):
+		errorCode = int16(CLUSTER_AUTHORIZATION_FAILED)
+	case h.replicas == nil:
+		errorCode = int16(NOT_LEADER_OR_FOLLOWER)
+	}
+
+	// The markers of different producers may end transactions in the same partition, each marker is appended on its
+	// own and the response waits for all of them
+	var mutex sync.Mutex
+	var remaining int
+	done := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		remaining--
+		if remaining == 0 {
+			respond(response, nil)
+		}
+	}
+
+	appends := []func(){}
+	for _, marker := range apiReq.Markers {
+		result := WritableTxnMarkerResult{ProducerId: marker.ProducerId, Topics: make([]WritableTxnMarkerTopicResult, 0, len(marker.Topics)), TaggedFields: make(map[string]string)}
+		for _, topic := range marker.Topics {
+			topicResult := WritableTxnMarkerTopicResult{Name: topic.Name, Partitions: make([]WritableTxnMarkerPartitionResult, 0, len(topic.PartitionIndexes)), TaggedFields: make(map[string]string)}
+			for _, partition := range topic.PartitionIndexes {
+				topicResult.Partitions = append(topicResult.Partitions, WritableTxnMarkerPartitionResult{PartitionIndex: partition, ErrorCode: errorCode, TaggedFields: make(map[string]string)})
+			}
+			result.Topics = append(result.Topics, topicResult)
+		}
+		response.Markers = append(response.Markers, result)
+
+		if errorCode != int16(NONE) {
+			continue
+		}
+
+		position := len(response.Markers) - 1
+		controlType := storage.ABORT_MARKER
+		if marker.TransactionResult {
+			controlType = storage.COMMIT_MARKER
+		}
+		batch := storage.NewControlBatch(marker.ProducerId, marker.ProducerEpoch, marker.CoordinatorEpoch, controlType, h.now().UnixMilli())
+		records := map[storage.TopicPartition][]byte{}
+		for _, topic := range marker.Topics {
+			for _, partition := range topic.PartitionIndexes {
+				records[storage.TopicPartition{Topic: topic.Name, Partition: partition}] = batch
+			}
+		}
+
+		appends = append(appends, func() {
+			h.replicas.AppendAsCoordinator(h.timeout, records, func(results map[storage.TopicPartition]replica.AppendResult) {
+				mutex.Lock()
+				for i := range response.Markers[position].Topics {
+					topic := &response.Markers[position].Topics[i]
+					for j := range topic.Partitions {
+						if result := results[storage.TopicPartition{Topic: topic.Name, Partition: topic.Partitions[j].PartitionIndex}]; result.Err != nil {
+							topic.Partitions[j].ErrorCode = int16(replicaErrorCode(result.Err))
+						}
+					}
+				}
+				mutex.Unlock()
+				done()
+			})
+		})
+	}
+
+	// The response is sent by the last append to respond, or below when nothing was appended
+	remaining = len(appends) + 1
+	for _, append := range appends {
+		append()
+	}
+	done()
+}
@@ -0,0 +1,189 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/acl"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestWriteTxnMarkersParseRequestBody(t *testing.T) {
+	handler := WriteTxnMarkersHandler{}
+
+	input := []byte{
+		// Header
+		0x00, 0x00, 0x00, 0x2C, // MessageSize: 44
+		0x00, 0x1B, // RequestApiKey: 27 (WriteTxnMarkers)
+		0x00, 0x01, // RequestApiVersion: 1
+		0x00, 0x00, 0x00, 0x42, // CorrelationId: 66
+		0x00, 0x04, // ClientId length: 4
+		't', 'e', 's', 't', // ClientId: "test"
+		0x00, // Number of header tagged fields (varint, 0)
+		// Body starts here
+		0x02,                                           // Markers array length (1 marker + 1)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x00, 0x00, // ProducerEpoch: 0
+		0x01,                // TransactionResult: commit
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x02,                   // PartitionIndexes array length (1 partition + 1)
+		0x00, 0x00, 0x00, 0x00, // Partition: 0
+		0x00,                   // Topic tagged fields
+		0x00, 0x00, 0x00, 0x03, // CoordinatorEpoch: 3
+		0x00, // Marker tagged fields
+		0x00, // Request tagged fields
+	}
+
+	header, _, err := ParseRequestHeader(input, 0)
+	if err != nil {
+		t.Fatalf("failed to parse header: %v", err)
+	}
+
+	got, err := handler.ParseRequestBody(header, input, 19)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &WriteTxnMarkersRequest{
+		Header: header,
+		Markers: []WritableTxnMarker{{
+			ProducerId:        5,
+			ProducerEpoch:     0,
+			TransactionResult: true,
+			Topics:            []WritableTxnMarkerTopic{{Name: "foo", PartitionIndexes: []int32{0}, TaggedFields: map[string]string{}}},
+			CoordinatorEpoch:  3,
+			TaggedFields:      map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request mismatch: got %+v, want %+v", got, want)
+	}
+
+	// The request the coordinators send is the one the leaders parse
+	req := &WriteTxnMarkersRequest{Header: RequestHeader{RequestApiKey: 27, RequestApiVersion: 1, CorrelationId: 66, ClientId: "test"}, Markers: want.Markers, TaggedFields: map[string]string{}}
+	serialized, err := req.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(serialized, input) {
+		t.Errorf("expected the serialized request %x, got %x", input, serialized)
+	}
+
+	if _, err := handler.ParseRequestBody(header, input[:40], 19); err == nil {
+		t.Errorf("expected error for a truncated request")
+	}
+}
+
+func TestWriteTxnMarkersHandleRequest(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	_, loader, configs := newTestConfigs(t, now)
+	logs, replicas := newTestLogReplicas(t, loader, configs)
+
+	markers := []WritableTxnMarker{
+		{ProducerId: 5, ProducerEpoch: 0, TransactionResult: true, CoordinatorEpoch: 3, Topics: []WritableTxnMarkerTopic{{Name: "foo", PartitionIndexes: []int32{0, 1}}}},
+		{ProducerId: 6, ProducerEpoch: 0, TransactionResult: false, CoordinatorEpoch: 3, Topics: []WritableTxnMarkerTopic{{Name: "foo", PartitionIndexes: []int32{0}}, {Name: "bar", PartitionIndexes: []int32{0}}}},
+	}
+	tests := []struct {
+		name       string
+		replicas   *replica.Manager
+		authorizer acl.Authorizer
+		want       map[int64]map[string][]KafkaErrorCode
+	}{
+		{"Unauthorized", replicas, denyAllAuthorizer{}, map[int64]map[string][]KafkaErrorCode{
+			5: {"foo": {CLUSTER_AUTHORIZATION_FAILED, CLUSTER_AUTHORIZATION_FAILED}},
+			6: {"foo": {CLUSTER_AUTHORIZATION_FAILED}, "bar": {CLUSTER_AUTHORIZATION_FAILED}},
+		}},
+		{"No replicas", nil, acl.NewAclAuthorizer(nil, true), map[int64]map[string][]KafkaErrorCode{
+			5: {"foo": {NOT_LEADER_OR_FOLLOWER, NOT_LEADER_OR_FOLLOWER}},
+			6: {"foo": {NOT_LEADER_OR_FOLLOWER}, "bar": {NOT_LEADER_OR_FOLLOWER}},
+		}},
+		{"Markers written", replicas, acl.NewAclAuthorizer(nil, true), map[int64]map[string][]KafkaErrorCode{
+			5: {"foo": {NONE, UNKNOWN_TOPIC_OR_PARTITION}},
+			6: {"foo": {NONE}, "bar": {NONE}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &WriteTxnMarkersHandler{replicas: tt.replicas, timeout: time.Second, authorizer: tt.authorizer, now: func() time.Time { return now }}
+			got, err := handler.Handle(NewSession("127.0.0.1"), &WriteTxnMarkersRequest{
+				Header:  RequestHeader{RequestApiKey: 27, RequestApiVersion: 1, CorrelationId: 7},
+				Markers: markers,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			results := map[int64]map[string][]KafkaErrorCode{}
+			for _, marker := range got.(*WriteTxnMarkersResponse).Markers {
+				results[marker.ProducerId] = map[string][]KafkaErrorCode{}
+				for _, topic := range marker.Topics {
+					for _, partition := range topic.Partitions {
+						results[marker.ProducerId][topic.Name] = append(results[marker.ProducerId][topic.Name], KafkaErrorCode(partition.ErrorCode))
+					}
+				}
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("expected the errors %v, got %v", tt.want, results)
+			}
+		})
+	}
+
+	// Both markers were appended to foo-0
+	if endOffset, err := logs.LogEndOffset(storage.TopicPartition{Topic: "foo", Partition: 0}); err != nil || endOffset != 2 {
+		t.Errorf("expected the 2 markers in foo-0, got an end offset of %d: %v", endOffset, err)
+	}
+}
+
+func TestWriteTxnMarkersResponseSerialize(t *testing.T) {
+	response := &WriteTxnMarkersResponse{
+		CorrelationId: 7,
+		Markers: []WritableTxnMarkerResult{{
+			ProducerId: 5,
+			Topics: []WritableTxnMarkerTopicResult{{
+				Name:         "foo",
+				Partitions:   []WritableTxnMarkerPartitionResult{{PartitionIndex: 0, ErrorCode: int16(NOT_LEADER_OR_FOLLOWER), TaggedFields: map[string]string{}}},
+				TaggedFields: map[string]string{},
+			}},
+			TaggedFields: map[string]string{},
+		}},
+		TaggedFields: map[string]string{},
+	}
+
+	serialized, err := response.Serialize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x00, 0x00, 0x00, 0x1E, // MessageSize: 30
+		0x00, 0x00, 0x00, 0x07, // CorrelationId: 7
+		0x00,                                           // Response header tagged fields
+		0x02,                                           // Markers array length (1 marker + 1)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // ProducerId: 5
+		0x02,                // Topics array length (1 topic + 1)
+		0x04, 'f', 'o', 'o', // Name: "foo"
+		0x02,                   // Partitions array length (1 partition + 1)
+		0x00, 0x00, 0x00, 0x00, // PartitionIndex: 0
+		0x00, 0x06, // ErrorCode: 6 (NOT_LEADER_OR_FOLLOWER)
+		0x00, // Partition tagged fields
+		0x00, // Topic tagged fields
+		0x00, // Marker tagged fields
+		0x00, // Response tagged fields
+	}
+	if !reflect.DeepEqual(serialized, want) {
+		t.Errorf("expected %x, got %x", want, serialized)
+	}
+
+	parsed, err := parseWriteTxnMarkersResponse(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, response) {
+		t.Errorf("expected the parsed response %+v, got %+v", response, parsed)
+	}
+}
@@ -0,0 +1,48 @@
+package sasl
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	PLAIN         = "PLAIN"
+	SCRAM_SHA_256 = "SCRAM-SHA-256"
+	SCRAM_SHA_512 = "SCRAM-SHA-512"
+)
+
+// Mechanisms lists every mechanism the broker knows how to authenticate
+var Mechanisms = []string{PLAIN, SCRAM_SHA_256, SCRAM_SHA_512}
+
+var (
+	// ErrAuthenticationFailed is returned when the client credentials do not match the stored ones
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrInvalidToken is returned when a client message does not follow the mechanism format
+	ErrInvalidToken         = errors.New("invalid SASL token")
+	ErrUnsupportedMechanism = errors.New("unsupported SASL mechanism")
+)
+
+// Authenticator runs the server side of a single SASL exchange.
+// Evaluate is called with every token sent by the client and returns the token to send back
+type Authenticator interface {
+	Evaluate(response []byte) ([]byte, error)
+	// Complete tells if the exchange has finished successfully
+	Complete() bool
+	// Principal is the authenticated principal, only meaningful once the exchange is complete
+	Principal() string
+}
+
+func NewAuthenticator(mechanism string, credentials *CredentialStore) (Authenticator, error) {
+	switch mechanism {
+	case PLAIN:
+		return &plainAuthenticator{credentials: credentials}, nil
+	case SCRAM_SHA_256, SCRAM_SHA_512:
+		return newScramAuthenticator(mechanism, credentials), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMechanism, mechanism)
+	}
+}
+
+func userPrincipal(username string) string {
+	return "User:" + username
+}
@@ -0,0 +1,169 @@
+package sasl
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"maps"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+const (
+	// Kafka rejects SCRAM credentials outside of these iteration bounds
+	MinScramIterations = 4096
+	MaxScramIterations = 16384
+)
+
+// ScramCredential is what the broker stores for a SCRAM user (RFC 5802), the password itself is never kept
+type ScramCredential struct {
+	Salt       []byte
+	StoredKey  []byte
+	ServerKey  []byte
+	Iterations int
+}
+
+// NewScramCredential derives the stored credential of a password for a SCRAM mechanism
+func NewScramCredential(mechanism string, password string, salt []byte, iterations int) (ScramCredential, error) {
+	newHash, err := scramHash(mechanism)
+	if err != nil {
+		return ScramCredential{}, err
+	}
+
+	saltedPassword, err := pbkdf2.Key(newHash, password, salt, iterations, newHash().Size())
+	if err != nil {
+		return ScramCredential{}, err
+	}
+
+	return NewScramCredentialFromSaltedPassword(mechanism, saltedPassword, salt, iterations)
+}
+
+// NewScramCredentialFromSaltedPassword derives the stored credential when the client only sends the salted password,
+// as AlterUserScramCredentials does
+func NewScramCredentialFromSaltedPassword(mechanism string, saltedPassword []byte, salt []byte, iterations int) (ScramCredential, error) {
+	newHash, err := scramHash(mechanism)
+	if err != nil {
+		return ScramCredential{}, err
+	}
+
+	clientKey := computeHmac(newHash, saltedPassword, []byte("Client Key"))
+	storedKey := newHash()
+	storedKey.Write(clientKey)
+
+	return ScramCredential{
+		Salt:       salt,
+		StoredKey:  storedKey.Sum(nil),
+		ServerKey:  computeHmac(newHash, saltedPassword, []byte("Server Key")),
+		Iterations: iterations,
+	}, nil
+}
+
+func scramHash(mechanism string) (func() hash.Hash, error) {
+	switch mechanism {
+	case SCRAM_SHA_256:
+		return sha256.New, nil
+	case SCRAM_SHA_512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMechanism, mechanism)
+	}
+}
+
+func computeHmac(newHash func() hash.Hash, key []byte, message []byte) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// CredentialStore keeps the PLAIN passwords and SCRAM credentials of the users allowed to connect
+type CredentialStore struct {
+	mutex     sync.RWMutex
+	passwords map[string]string
+	// Username -> mechanism -> credential
+	scram map[string]map[string]ScramCredential
+}
+
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{
+		passwords: make(map[string]string),
+		scram:     make(map[string]map[string]ScramCredential),
+	}
+}
+
+// SetPassword registers a user that can authenticate with PLAIN
+func (s *CredentialStore) SetPassword(username string, password string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.passwords[username] = password
+}
+
+func (s *CredentialStore) Password(username string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	password, ok := s.passwords[username]
+	return password, ok
+}
+
+func (s *CredentialStore) SetScramCredential(username string, mechanism string, credential ScramCredential) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.scram[username] == nil {
+		s.scram[username] = make(map[string]ScramCredential)
+	}
+	s.scram[username][mechanism] = credential
+}
+
+func (s *CredentialStore) ScramCredential(username string, mechanism string) (ScramCredential, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	credential, ok := s.scram[username][mechanism]
+	return credential, ok
+}
+
+// ScramCredentials returns the credentials of a user keyed by mechanism
+func (s *CredentialStore) ScramCredentials(username string) map[string]ScramCredential {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return maps.Clone(s.scram[username])
+}
+
+// ScramUsers returns the users that have at least one SCRAM credential, sorted by name
+func (s *CredentialStore) ScramUsers() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(s.scram))
+}
+
+// LoadScramCredentials replaces the SCRAM credentials with the ones of the metadata, keyed by user and mechanism
+func (s *CredentialStore) LoadScramCredentials(credentials map[string]map[string]ScramCredential) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scram = make(map[string]map[string]ScramCredential, len(credentials))
+	for username, mechanisms := range credentials {
+		s.scram[username] = maps.Clone(mechanisms)
+	}
+}
+
+var jaasUserOption = regexp.MustCompile(`user_([^\s=]+)\s*=\s*"([^"]*)"`)
+
+// ParseJaasUsers extracts the user_<name>="<password>" options that the PlainLoginModule uses to declare PLAIN users
+func ParseJaasUsers(jaasConfig string) map[string]string {
+	users := make(map[string]string)
+
+	for _, match := range jaasUserOption.FindAllStringSubmatch(jaasConfig, -1) {
+		users[match[1]] = match[2]
+	}
+
+	return users
+}
@@ -0,0 +1,52 @@
+package sasl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCredentialStoreScramCredentials(t *testing.T) {
+	store := NewCredentialStore()
+
+	credential, err := NewScramCredential(SCRAM_SHA_256, "secret", []byte("salt"), MinScramIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.SetScramCredential("bob", SCRAM_SHA_256, credential)
+	store.SetScramCredential("alice", SCRAM_SHA_256, credential)
+	store.SetScramCredential("alice", SCRAM_SHA_512, credential)
+
+	if got := store.ScramUsers(); !reflect.DeepEqual(got, []string{"alice", "bob"}) {
+		t.Errorf("ScramUsers mismatch: got %v", got)
+	}
+
+	if got := store.ScramCredentials("alice"); len(got) != 2 {
+		t.Errorf("ScramCredentials mismatch: got %v", got)
+	}
+
+	store.LoadScramCredentials(map[string]map[string]ScramCredential{"alice": {SCRAM_SHA_512: credential}})
+
+	if got := store.ScramUsers(); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("ScramUsers mismatch after load: got %v", got)
+	}
+
+	if _, ok := store.ScramCredential("alice", SCRAM_SHA_256); ok {
+		t.Errorf("expected the loaded credentials to replace the previous ones")
+	}
+}
+
+func TestNewScramCredentialFromSaltedPassword(t *testing.T) {
+	fromPassword, err := NewScramCredential(SCRAM_SHA_512, "secret", []byte("salt"), MinScramIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewScramCredentialFromSaltedPassword(PLAIN, []byte("x"), []byte("salt"), MinScramIterations); err == nil {
+		t.Errorf("expected error for a non SCRAM mechanism")
+	}
+
+	if len(fromPassword.StoredKey) != 64 || len(fromPassword.ServerKey) != 64 {
+		t.Errorf("SCRAM-SHA-512 keys must be 64 bytes, got %d and %d", len(fromPassword.StoredKey), len(fromPassword.ServerKey))
+	}
+}
@@ -0,0 +1,53 @@
+package sasl
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+)
+
+// plainAuthenticator implements RFC 4616: the client sends [authzid] NUL authcid NUL passwd in a single message
+type plainAuthenticator struct {
+	credentials *CredentialStore
+	username    string
+	complete    bool
+}
+
+func (a *plainAuthenticator) Evaluate(response []byte) ([]byte, error) {
+	if a.complete {
+		return nil, fmt.Errorf("%w: PLAIN exchange is already complete", ErrInvalidToken)
+	}
+
+	parts := bytes.Split(response, []byte{0})
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 NUL separated fields in PLAIN message", ErrInvalidToken)
+	}
+
+	authzid, username, password := string(parts[0]), string(parts[1]), parts[2]
+	if username == "" {
+		return nil, fmt.Errorf("%w: username must not be empty", ErrInvalidToken)
+	}
+
+	// Acting on behalf of another user is not supported
+	if authzid != "" && authzid != username {
+		return nil, fmt.Errorf("%w: authorization id must be empty or match the username", ErrAuthenticationFailed)
+	}
+
+	expected, ok := a.credentials.Password(username)
+	if !ok || subtle.ConstantTimeCompare([]byte(expected), password) != 1 {
+		return nil, fmt.Errorf("%w: invalid username or password", ErrAuthenticationFailed)
+	}
+
+	a.username = username
+	a.complete = true
+
+	return []byte{}, nil
+}
+
+func (a *plainAuthenticator) Complete() bool {
+	return a.complete
+}
+
+func (a *plainAuthenticator) Principal() string {
+	return userPrincipal(a.username)
+}
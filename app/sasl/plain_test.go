@@ -0,0 +1,82 @@
+package sasl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlainAuthenticator(t *testing.T) {
+	credentials := NewCredentialStore()
+	credentials.SetPassword("alice", "alice-secret")
+
+	tests := []struct {
+		name          string
+		message       []byte
+		wantErr       error
+		wantPrincipal string
+	}{
+		{
+			name:          "Valid credentials",
+			message:       []byte("\x00alice\x00alice-secret"),
+			wantErr:       nil,
+			wantPrincipal: "User:alice",
+		},
+		{
+			name:          "Matching authorization id",
+			message:       []byte("alice\x00alice\x00alice-secret"),
+			wantErr:       nil,
+			wantPrincipal: "User:alice",
+		},
+		{
+			name:    "Other authorization id",
+			message: []byte("bob\x00alice\x00alice-secret"),
+			wantErr: ErrAuthenticationFailed,
+		},
+		{
+			name:    "Wrong password",
+			message: []byte("\x00alice\x00bob-secret"),
+			wantErr: ErrAuthenticationFailed,
+		},
+		{
+			name:    "Unknown user",
+			message: []byte("\x00bob\x00alice-secret"),
+			wantErr: ErrAuthenticationFailed,
+		},
+		{
+			name:    "Malformed message",
+			message: []byte("alice-secret"),
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator, err := NewAuthenticator(PLAIN, credentials)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = authenticator.Evaluate(tt.message)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error mismatch: got %v, want %v", err, tt.wantErr)
+			}
+
+			if authenticator.Complete() != (tt.wantErr == nil) {
+				t.Errorf("Complete mismatch: got %v", authenticator.Complete())
+			}
+
+			if tt.wantErr == nil && authenticator.Principal() != tt.wantPrincipal {
+				t.Errorf("Principal mismatch: got %s, want %s", authenticator.Principal(), tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+func TestParseJaasUsers(t *testing.T) {
+	jaas := `org.apache.kafka.common.security.plain.PlainLoginModule required username="admin" password="admin-secret" user_admin="admin-secret" user_alice = "alice-secret";`
+
+	got := ParseJaasUsers(jaas)
+	if len(got) != 2 || got["admin"] != "admin-secret" || got["alice"] != "alice-secret" {
+		t.Errorf("ParseJaasUsers mismatch: got %v", got)
+	}
+}
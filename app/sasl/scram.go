@@ -0,0 +1,221 @@
+package sasl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+type scramState int8
+
+const (
+	RECEIVE_CLIENT_FIRST scramState = iota
+	RECEIVE_CLIENT_FINAL
+	COMPLETE
+	FAILED
+)
+
+// scramAuthenticator implements the server side of RFC 5802 without channel binding:
+// client-first -> server-first -> client-final -> server-final
+type scramAuthenticator struct {
+	mechanism   string
+	newHash     func() hash.Hash
+	credentials *CredentialStore
+	// nonce generates the server part of the nonce
+	nonce func() string
+	state scramState
+
+	username        string
+	credential      ScramCredential
+	gs2Header       string
+	clientFirstBare string
+	serverFirst     string
+	combinedNonce   string
+}
+
+func newScramAuthenticator(mechanism string, credentials *CredentialStore) *scramAuthenticator {
+	newHash, _ := scramHash(mechanism)
+
+	return &scramAuthenticator{
+		mechanism:   mechanism,
+		newHash:     newHash,
+		credentials: credentials,
+		nonce:       randomNonce,
+		state:       RECEIVE_CLIENT_FIRST,
+	}
+}
+
+func randomNonce() string {
+	buffer := make([]byte, 24)
+	_, _ = rand.Read(buffer)
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
+
+func (a *scramAuthenticator) Evaluate(response []byte) ([]byte, error) {
+	var challenge []byte
+	var err error
+
+	switch a.state {
+	case RECEIVE_CLIENT_FIRST:
+		challenge, err = a.handleClientFirst(string(response))
+		if err == nil {
+			a.state = RECEIVE_CLIENT_FINAL
+		}
+	case RECEIVE_CLIENT_FINAL:
+		challenge, err = a.handleClientFinal(string(response))
+		if err == nil {
+			a.state = COMPLETE
+		}
+	default:
+		err = fmt.Errorf("%w: unexpected message in %s exchange", ErrInvalidToken, a.mechanism)
+	}
+
+	if err != nil {
+		a.state = FAILED
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (a *scramAuthenticator) Complete() bool {
+	return a.state == COMPLETE
+}
+
+func (a *scramAuthenticator) Principal() string {
+	return userPrincipal(a.username)
+}
+
+func (a *scramAuthenticator) handleClientFirst(message string) ([]byte, error) {
+	// gs2-header: cbind-flag "," [authzid] ","
+	parts := strings.SplitN(message, ",", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: invalid client-first message", ErrInvalidToken)
+	}
+
+	cbindFlag, authzid, bare := parts[0], parts[1], parts[2]
+	if cbindFlag != "n" && cbindFlag != "y" {
+		return nil, fmt.Errorf("%w: channel binding is not supported", ErrInvalidToken)
+	}
+
+	attributes := strings.Split(bare, ",")
+	if len(attributes) < 2 || !strings.HasPrefix(attributes[0], "n=") || !strings.HasPrefix(attributes[1], "r=") {
+		return nil, fmt.Errorf("%w: client-first message must start with the username and nonce", ErrInvalidToken)
+	}
+
+	username, err := decodeSaslName(strings.TrimPrefix(attributes[0], "n="))
+	if err != nil {
+		return nil, err
+	}
+
+	clientNonce := strings.TrimPrefix(attributes[1], "r=")
+	if username == "" || clientNonce == "" {
+		return nil, fmt.Errorf("%w: username and nonce must not be empty", ErrInvalidToken)
+	}
+
+	if authzid != "" {
+		if !strings.HasPrefix(authzid, "a=") {
+			return nil, fmt.Errorf("%w: invalid authorization id", ErrInvalidToken)
+		}
+
+		authorizationId, err := decodeSaslName(strings.TrimPrefix(authzid, "a="))
+		if err != nil {
+			return nil, err
+		}
+		if authorizationId != username {
+			return nil, fmt.Errorf("%w: authorization id must match the username", ErrAuthenticationFailed)
+		}
+	}
+
+	credential, ok := a.credentials.ScramCredential(username, a.mechanism)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid user credentials", ErrAuthenticationFailed)
+	}
+
+	a.username = username
+	a.credential = credential
+	a.gs2Header = cbindFlag + "," + authzid + ","
+	a.clientFirstBare = bare
+	a.combinedNonce = clientNonce + a.nonce()
+	a.serverFirst = fmt.Sprintf("r=%s,s=%s,i=%s", a.combinedNonce, base64.StdEncoding.EncodeToString(credential.Salt), strconv.Itoa(credential.Iterations))
+
+	return []byte(a.serverFirst), nil
+}
+
+func (a *scramAuthenticator) handleClientFinal(message string) ([]byte, error) {
+	proofIndex := strings.LastIndex(message, ",p=")
+	if proofIndex < 0 {
+		return nil, fmt.Errorf("%w: client-final message has no proof", ErrInvalidToken)
+	}
+
+	withoutProof := message[:proofIndex]
+	proof, err := base64.StdEncoding.DecodeString(message[proofIndex+len(",p="):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid proof encoding", ErrInvalidToken)
+	}
+
+	attributes := strings.Split(withoutProof, ",")
+	if len(attributes) < 2 || !strings.HasPrefix(attributes[0], "c=") || !strings.HasPrefix(attributes[1], "r=") {
+		return nil, fmt.Errorf("%w: client-final message must start with the channel binding and nonce", ErrInvalidToken)
+	}
+
+	channelBinding, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(attributes[0], "c="))
+	if err != nil || string(channelBinding) != a.gs2Header {
+		return nil, fmt.Errorf("%w: channel binding does not match the client-first message", ErrInvalidToken)
+	}
+
+	if strings.TrimPrefix(attributes[1], "r=") != a.combinedNonce {
+		return nil, fmt.Errorf("%w: nonce does not match the server-first message", ErrInvalidToken)
+	}
+
+	authMessage := []byte(a.clientFirstBare + "," + a.serverFirst + "," + withoutProof)
+
+	// ClientKey = ClientProof XOR HMAC(StoredKey, AuthMessage) and must hash to StoredKey
+	clientSignature := computeHmac(a.newHash, a.credential.StoredKey, authMessage)
+	if len(proof) != len(clientSignature) {
+		return nil, fmt.Errorf("%w: invalid user credentials", ErrAuthenticationFailed)
+	}
+
+	clientKey := make([]byte, len(proof))
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+
+	storedKey := a.newHash()
+	storedKey.Write(clientKey)
+	if !hmac.Equal(storedKey.Sum(nil), a.credential.StoredKey) {
+		return nil, fmt.Errorf("%w: invalid user credentials", ErrAuthenticationFailed)
+	}
+
+	serverSignature := computeHmac(a.newHash, a.credential.ServerKey, authMessage)
+
+	return []byte("v=" + base64.StdEncoding.EncodeToString(serverSignature)), nil
+}
+
+// decodeSaslName reverses the escaping of "," and "=" in SCRAM usernames
+func decodeSaslName(name string) (string, error) {
+	var builder strings.Builder
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != '=' {
+			builder.WriteByte(name[i])
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(name[i:], "=2C"):
+			builder.WriteByte(',')
+		case strings.HasPrefix(name[i:], "=3D"):
+			builder.WriteByte('=')
+		default:
+			return "", fmt.Errorf("%w: invalid escape in username %q", ErrInvalidToken, name)
+		}
+		i += 2
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,133 @@
+package sasl
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// The exchange from RFC 7677 section 3
+func TestScramAuthenticatorRfcExchange(t *testing.T) {
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	credential, err := NewScramCredential(SCRAM_SHA_256, "pencil", salt, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := NewCredentialStore()
+	credentials.SetScramCredential("user", SCRAM_SHA_256, credential)
+
+	authenticator := newScramAuthenticator(SCRAM_SHA_256, credentials)
+	authenticator.nonce = func() string { return "%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0" }
+
+	serverFirst, err := authenticator.Evaluate([]byte("n,,n=user,r=rOprNGfwEbeRWgbNEkqO"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantServerFirst := "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	if string(serverFirst) != wantServerFirst {
+		t.Errorf("server-first mismatch:\ngot  %s\nwant %s", serverFirst, wantServerFirst)
+	}
+
+	if authenticator.Complete() {
+		t.Errorf("exchange must not be complete after the client-first message")
+	}
+
+	serverFinal, err := authenticator.Evaluate([]byte("c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantServerFinal := "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+	if string(serverFinal) != wantServerFinal {
+		t.Errorf("server-final mismatch:\ngot  %s\nwant %s", serverFinal, wantServerFinal)
+	}
+
+	if !authenticator.Complete() || authenticator.Principal() != "User:user" {
+		t.Errorf("expected a complete exchange for User:user, got complete=%v principal=%s", authenticator.Complete(), authenticator.Principal())
+	}
+}
+
+func TestScramAuthenticatorFailures(t *testing.T) {
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	credential, err := NewScramCredential(SCRAM_SHA_256, "pencil", salt, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := NewCredentialStore()
+	credentials.SetScramCredential("user", SCRAM_SHA_256, credential)
+
+	tests := []struct {
+		name        string
+		mechanism   string
+		clientFirst string
+		clientFinal string
+		wantErr     error
+	}{
+		{
+			name:        "Unknown user",
+			mechanism:   SCRAM_SHA_256,
+			clientFirst: "n,,n=bob,r=rOprNGfwEbeRWgbNEkqO",
+			wantErr:     ErrAuthenticationFailed,
+		},
+		{
+			name:        "No credential for the mechanism",
+			mechanism:   SCRAM_SHA_512,
+			clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+			wantErr:     ErrAuthenticationFailed,
+		},
+		{
+			name:        "Channel binding",
+			mechanism:   SCRAM_SHA_256,
+			clientFirst: "p=tls-unique,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+			wantErr:     ErrInvalidToken,
+		},
+		{
+			name:        "Wrong password",
+			mechanism:   SCRAM_SHA_256,
+			clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=AAzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			wantErr:     ErrAuthenticationFailed,
+		},
+		{
+			name:        "Nonce mismatch",
+			mechanism:   SCRAM_SHA_256,
+			clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			wantErr:     ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newScramAuthenticator(tt.mechanism, credentials)
+			authenticator.nonce = func() string { return "%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0" }
+
+			_, err := authenticator.Evaluate([]byte(tt.clientFirst))
+			if err == nil && tt.clientFinal != "" {
+				_, err = authenticator.Evaluate([]byte(tt.clientFinal))
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error mismatch: got %v, want %v", err, tt.wantErr)
+			}
+
+			if authenticator.Complete() {
+				t.Errorf("exchange must not be complete after a failure")
+			}
+		})
+	}
+}
+
+func TestDecodeSaslName(t *testing.T) {
+	got, err := decodeSaslName("a=2Cb=3Dc")
+	if err != nil || got != "a,b=c" {
+		t.Errorf("decodeSaslName mismatch: got %q (%v), want %q", got, err, "a,b=c")
+	}
+
+	if _, err := decodeSaslName("a=b"); err == nil {
+		t.Errorf("expected error for an invalid escape")
+	}
+}
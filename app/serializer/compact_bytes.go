@@ -0,0 +1,28 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+func SerializeCompactBytes(buffer []byte, index int, value []byte) (int, error) {
+	if index < 0 {
+		return index, fmt.Errorf("failed to serialize compact bytes - negative index")
+	}
+
+	varintValue := uint64(len(value) + 1)
+	varintBytes := binary.PutUvarint(make([]byte, binary.MaxVarintLen64), varintValue)
+
+	if index+varintBytes+len(value) > len(buffer) {
+		return index, fmt.Errorf("failed to serialize compact bytes - buffer too small")
+	}
+
+	// Value length
+	index += binary.PutUvarint(buffer[index:], varintValue)
+
+	// Value (bytes)
+	copy(buffer[index:], value)
+	index += len(value)
+
+	return index, nil
+}
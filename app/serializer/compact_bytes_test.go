@@ -0,0 +1,65 @@
+package serializer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSerializeCompactBytes(t *testing.T) {
+	tests := []struct {
+		name       string
+		buffer     []byte
+		index      int
+		value      []byte
+		wantIdx    int
+		wantErr    bool
+		wantBuffer []byte
+	}{
+		{
+			name:       "Empty bytes",
+			buffer:     make([]byte, 2),
+			index:      0,
+			value:      []byte{},
+			wantIdx:    1,
+			wantErr:    false,
+			wantBuffer: []byte{0x01, 0x00},
+		},
+		{
+			name:       "Bytes from starting index",
+			buffer:     make([]byte, 5),
+			index:      1,
+			value:      []byte{0x00, 'a', 0x00},
+			wantIdx:    5,
+			wantErr:    false,
+			wantBuffer: []byte{0x00, 0x04, 0x00, 'a', 0x00},
+		},
+		{
+			name:       "Buffer too small",
+			buffer:     make([]byte, 3),
+			index:      0,
+			value:      []byte{0x01, 0x02, 0x03},
+			wantIdx:    0,
+			wantErr:    true,
+			wantBuffer: make([]byte, 3),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIdx, err := SerializeCompactBytes(tt.buffer, tt.index, tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeCompactBytes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("SerializeCompactBytes() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+
+			if !bytes.Equal(tt.buffer, tt.wantBuffer) {
+				t.Errorf("SerializeCompactBytes() buffer = %v, want %v", tt.buffer, tt.wantBuffer)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+func SerializeFloat64(buffer []byte, index int, value float64) (int, error) {
+	if index < 0 {
+		return index, fmt.Errorf("failed to serialize float64 - negative index")
+	}
+
+	if index+8 > len(buffer) {
+		return index, fmt.Errorf("failed to serialize float64 - buffer too small")
+	}
+
+	binary.BigEndian.PutUint64(buffer[index:index+8], math.Float64bits(value))
+	return index + 8, nil
+}
@@ -0,0 +1,74 @@
+package serializer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSerializeFloat64(t *testing.T) {
+	tests := []struct {
+		name       string
+		buffer     []byte
+		index      int
+		value      float64
+		wantIdx    int
+		wantErr    bool
+		wantBuffer []byte
+	}{
+		{
+			name:       "Valid float64",
+			buffer:     make([]byte, 8),
+			index:      0,
+			value:      1.5, // 0x3FF8000000000000
+			wantIdx:    8,
+			wantErr:    false,
+			wantBuffer: []byte{0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:       "Valid float64 from starting index",
+			buffer:     make([]byte, 9),
+			index:      1,
+			value:      -2, // 0xC000000000000000
+			wantIdx:    9,
+			wantErr:    false,
+			wantBuffer: []byte{0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:       "Buffer too small",
+			buffer:     make([]byte, 7),
+			index:      0,
+			value:      1,
+			wantIdx:    0,
+			wantErr:    true,
+			wantBuffer: make([]byte, 7),
+		},
+		{
+			name:       "Negative index",
+			buffer:     make([]byte, 8),
+			index:      -1,
+			value:      1,
+			wantIdx:    -1,
+			wantErr:    true,
+			wantBuffer: make([]byte, 8),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIdx, err := SerializeFloat64(tt.buffer, tt.index, tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeFloat64() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("SerializeFloat64() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+
+			if !bytes.Equal(tt.buffer, tt.wantBuffer) {
+				t.Errorf("SerializeFloat64() buffer = %v, want %v", tt.buffer, tt.wantBuffer)
+			}
+		})
+	}
+}
@@ -56,3 +56,16 @@ func SerializeUnsignedVarInt(buffer []byte, index int, value uint64) (int, error
 	index += binary.PutUvarint(buffer[index:], value)
 	return index, nil
 }
+
+func SerializeInt64(buffer []byte, index int, value int64) (int, error) {
+	if index < 0 {
+		return index, fmt.Errorf("failed to serialize int64 - negative index")
+	}
+
+	if index+8 > len(buffer) {
+		return index, fmt.Errorf("failed to serialize int64 - buffer too small")
+	}
+
+	binary.BigEndian.PutUint64(buffer[index:index+8], uint64(value))
+	return index + 8, nil
+}
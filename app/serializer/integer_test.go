@@ -585,3 +585,71 @@ func TestSerializeUnsignedVarInt_MaxValue(t *testing.T) {
 		t.Errorf("max uint64 varint length = %v, want 10", gotIdx)
 	}
 }
+
+func TestSerializeInt64(t *testing.T) {
+	tests := []struct {
+		name       string
+		buffer     []byte
+		index      int
+		value      int64
+		wantIdx    int
+		wantErr    bool
+		wantBuffer []byte
+	}{
+		{
+			name:       "Valid int64 positive value",
+			buffer:     make([]byte, 8),
+			index:      0,
+			value:      3600000,
+			wantIdx:    8,
+			wantErr:    false,
+			wantBuffer: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x36, 0xEE, 0x80},
+		},
+		{
+			name:       "Negative int64 value",
+			buffer:     make([]byte, 9),
+			index:      1,
+			value:      -1,
+			wantIdx:    9,
+			wantErr:    false,
+			wantBuffer: []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		},
+		{
+			name:       "Buffer too small",
+			buffer:     make([]byte, 7),
+			index:      0,
+			value:      1,
+			wantIdx:    0,
+			wantErr:    true,
+			wantBuffer: make([]byte, 7),
+		},
+		{
+			name:       "Negative index",
+			buffer:     make([]byte, 8),
+			index:      -1,
+			value:      1,
+			wantIdx:    -1,
+			wantErr:    true,
+			wantBuffer: make([]byte, 8),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIdx, err := SerializeInt64(tt.buffer, tt.index, tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeInt64() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("SerializeInt64() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+
+			if !bytes.Equal(tt.buffer, tt.wantBuffer) {
+				t.Errorf("SerializeInt64() buffer = %v, want %v", tt.buffer, tt.wantBuffer)
+			}
+		})
+	}
+}
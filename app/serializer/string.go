@@ -0,0 +1,31 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SerializeString writes a non-compact string, prefixed by its length as an int16
+func SerializeString(buffer []byte, index int, value string) (int, error) {
+	if index < 0 {
+		return index, fmt.Errorf("failed to serialize string - negative index")
+	}
+
+	if len(value) > 32767 {
+		return index, fmt.Errorf("failed to serialize string - value too long")
+	}
+
+	if index+2+len(value) > len(buffer) {
+		return index, fmt.Errorf("failed to serialize string - buffer too small")
+	}
+
+	// Value length
+	binary.BigEndian.PutUint16(buffer[index:index+2], uint16(len(value)))
+	index += 2
+
+	// Value (bytes)
+	copy(buffer[index:], value)
+	index += len(value)
+
+	return index, nil
+}
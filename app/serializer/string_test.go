@@ -0,0 +1,75 @@
+package serializer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSerializeString(t *testing.T) {
+	tests := []struct {
+		name       string
+		buffer     []byte
+		index      int
+		value      string
+		wantIdx    int
+		wantErr    bool
+		wantBuffer []byte
+	}{
+		{
+			name:       "Empty string",
+			buffer:     make([]byte, 3),
+			index:      0,
+			value:      "",
+			wantIdx:    2,
+			wantErr:    false,
+			wantBuffer: []byte{0x00, 0x00, 0x00},
+		},
+		{
+			name:       "Short string from starting index",
+			buffer:     make([]byte, 8),
+			index:      1,
+			value:      "PLAIN",
+			wantIdx:    8,
+			wantErr:    false,
+			wantBuffer: []byte{0x00, 0x00, 0x05, 'P', 'L', 'A', 'I', 'N'},
+		},
+		{
+			name:       "Buffer too small",
+			buffer:     make([]byte, 4),
+			index:      0,
+			value:      "PLAIN",
+			wantIdx:    0,
+			wantErr:    true,
+			wantBuffer: make([]byte, 4),
+		},
+		{
+			name:       "String too long",
+			buffer:     make([]byte, 40000),
+			index:      0,
+			value:      strings.Repeat("a", 32768),
+			wantIdx:    0,
+			wantErr:    true,
+			wantBuffer: make([]byte, 40000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIdx, err := SerializeString(tt.buffer, tt.index, tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SerializeString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if gotIdx != tt.wantIdx {
+				t.Errorf("SerializeString() gotIdx = %v, want %v", gotIdx, tt.wantIdx)
+			}
+
+			if !bytes.Equal(tt.buffer, tt.wantBuffer) {
+				t.Errorf("SerializeString() buffer = %v, want %v", tt.buffer, tt.wantBuffer)
+			}
+		})
+	}
+}
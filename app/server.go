@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/metrics"
+	"github.com/codecrafters-io/kafka-starter-go/app/network"
+	"github.com/codecrafters-io/kafka-starter-go/app/request"
+)
+
+// How long in-flight requests may take to complete once a shutdown has started
+const shutdownTimeout = 30 * time.Second
+
+var ErrShutdownTimeout = errors.New("connections still open after the shutdown timeout")
+
+// server runs the accept loops of the listeners and keeps track of open connections so that it can drain them on shutdown
+type server struct {
+	broker    *request.KafkaBroker
+	listeners []*brokerListener
+	logger    *slog.Logger
+	// Processes the requests of every connection
+	pool                  *network.WorkerPool
+	socketRequestMaxBytes int32
+	connectionQuotas      *network.ConnectionQuotas
+	connectionsMaxIdle    time.Duration
+
+	mutex        sync.Mutex
+	connections  map[net.Conn]struct{}
+	shuttingDown bool
+	// Numbers the connections in their ids
+	connectionIndex atomic.Int64
+	// Accept loops and connection handlers
+	running sync.WaitGroup
+}
+
+func newServer(broker *request.KafkaBroker, listeners []*brokerListener, networkConfig config.NetworkConfig, registry *metrics.Registry, logger *slog.Logger) *server {
+	s := &server{
+		broker:                broker,
+		listeners:             listeners,
+		logger:                logger,
+		pool:                  network.NewWorkerPool(networkConfig.NumIoThreads, networkConfig.QueuedMaxRequests),
+		socketRequestMaxBytes: networkConfig.SocketRequestMaxBytes,
+		connectionQuotas:      network.NewConnectionQuotas(networkConfig.MaxConnections, networkConfig.MaxConnectionsPerIp, networkConfig.MaxConnectionsPerIpOverrides),
+		connectionsMaxIdle:    networkConfig.ConnectionsMaxIdle,
+		connections:           make(map[net.Conn]struct{}),
+	}
+
+	registry.NewGaugeFunc("kafka_server_connections", "Open client connections across all listeners.", func() float64 {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return float64(len(s.connections))
+	})
+	registry.NewGaugeFunc("kafka_network_request_queue_size", "Requests waiting for an io thread.", func() float64 {
+		return float64(s.pool.Queued())
+	})
+
+	return s
+}
+
+// serve starts an accept loop for every listener and returns immediately
+func (s *server) serve() {
+	for _, listener := range s.listeners {
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			s.listenForConnections(listener)
+		}()
+	}
+}
+
+// track registers a new connection, it returns false once the server is shutting down
+func (s *server) track(connection net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.shuttingDown {
+		return false
+	}
+
+	s.connections[connection] = struct{}{}
+	s.running.Add(1)
+	return true
+}
+
+func (s *server) untrack(connection net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.connections, connection)
+	s.running.Done()
+}
+
+func (s *server) isShuttingDown() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.shuttingDown
+}
+
+// shutdown stops accepting connections and waits for the requests being processed to complete.
+// Connections waiting for their next request are closed right away, the others once their response is written.
+// After the timeout the remaining connections are closed and ErrShutdownTimeout is returned
+func (s *server) shutdown(timeout time.Duration) error {
+	s.mutex.Lock()
+	s.shuttingDown = true
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	// Listeners waiting for a free connection slot stop too
+	s.connectionQuotas.Close()
+	// Reads return immediately, so handlers stop after writing the response of their current request
+	for connection := range s.connections {
+		connection.SetReadDeadline(time.Now())
+	}
+	s.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		s.pool.Close()
+		return nil
+	case <-time.After(timeout):
+	}
+
+	s.mutex.Lock()
+	for connection := range s.connections {
+		connection.Close()
+	}
+	s.mutex.Unlock()
+
+	<-drained
+	s.pool.Close()
+	return ErrShutdownTimeout
+}
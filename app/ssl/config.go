@@ -0,0 +1,68 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Values of ssl.client.auth
+const (
+	CLIENT_AUTH_NONE      = "none"
+	CLIENT_AUTH_REQUESTED = "requested"
+	CLIENT_AUTH_REQUIRED  = "required"
+)
+
+var (
+	ErrMissingKeystore   = errors.New("ssl.keystore.location is required for SSL listeners")
+	ErrInvalidTruststore = errors.New("no certificate found in the truststore")
+	ErrInvalidClientAuth = errors.New("invalid ssl.client.auth")
+)
+
+// NewServerConfig builds the TLS config of an SSL listener.
+// The keystore is a PEM file holding the private key and the certificate chain of the broker,
+// the truststore a PEM file with the CA certificates client certificates are verified against
+func NewServerConfig(keystoreLocation string, truststoreLocation string, clientAuth string) (*tls.Config, error) {
+	if keystoreLocation == "" {
+		return nil, ErrMissingKeystore
+	}
+
+	// Both the certificate chain and the key are read from the same file
+	certificate, err := tls.LoadX509KeyPair(keystoreLocation, keystoreLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the keystore %s: %w", keystoreLocation, err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch clientAuth {
+	case CLIENT_AUTH_NONE, "":
+		config.ClientAuth = tls.NoClientCert
+	case CLIENT_AUTH_REQUESTED:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case CLIENT_AUTH_REQUIRED:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidClientAuth, clientAuth)
+	}
+
+	if truststoreLocation != "" {
+		pem, err := os.ReadFile(truststoreLocation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the truststore %s: %w", truststoreLocation, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTruststore, truststoreLocation)
+		}
+		config.ClientCAs = pool
+	}
+
+	return config, nil
+}
@@ -0,0 +1,235 @@
+package ssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+}
+
+// newTestCertificate creates a certificate signed by the parent, or a self-signed CA when parent is nil
+func newTestCertificate(t *testing.T, subject pkix.Name, parent *testCertificate) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (c *testCertificate) keyPair(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPair, err := tls.X509KeyPair(c.pem, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyPair
+}
+
+// writeKeystore writes the key and the certificate in a single PEM file, as ssl.keystore.location expects
+func (c *testCertificate) writeKeystore(t *testing.T, path string) {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), c.pem...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects a client to a listener using the server config and returns the principal of the client
+func handshake(t *testing.T, serverConfig *tls.Config, clientConfig *tls.Config, mapper *PrincipalMapper) (string, error) {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		connection, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+
+		// Wait for the server to close the connection
+		connection.Read(make([]byte, 1))
+	}()
+
+	connection, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	tlsConnection := connection.(*tls.Conn)
+	if err := tlsConnection.Handshake(); err != nil {
+		return "", err
+	}
+
+	return mapper.Principal(tlsConnection.ConnectionState())
+}
+
+func TestNewServerConfig(t *testing.T) {
+	directory := t.TempDir()
+
+	ca := newTestCertificate(t, pkix.Name{CommonName: "test-ca"}, nil)
+	server := newTestCertificate(t, pkix.Name{CommonName: "localhost"}, ca)
+	client := newTestCertificate(t, pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"eng"}, Organization: []string{"example"}}, ca)
+
+	keystore := filepath.Join(directory, "keystore.pem")
+	server.writeKeystore(t, keystore)
+
+	truststore := filepath.Join(directory, "truststore.pem")
+	if err := os.WriteFile(truststore, ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+
+	mapper, err := NewPrincipalMapper("RULE:^CN=(.*?),OU=eng.*$/$1/,DEFAULT")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		clientAuth        string
+		clientCertificate bool
+		wantPrincipal     string
+		wantErr           bool
+	}{
+		{
+			name:              "Client certificates ignored",
+			clientAuth:        CLIENT_AUTH_NONE,
+			clientCertificate: true,
+			wantPrincipal:     AnonymousPrincipal,
+		},
+		{
+			name:              "Requested client certificate",
+			clientAuth:        CLIENT_AUTH_REQUESTED,
+			clientCertificate: true,
+			wantPrincipal:     "User:alice",
+		},
+		{
+			name:              "Requested without a client certificate",
+			clientAuth:        CLIENT_AUTH_REQUESTED,
+			clientCertificate: false,
+			wantPrincipal:     AnonymousPrincipal,
+		},
+		{
+			name:              "Required without a client certificate",
+			clientAuth:        CLIENT_AUTH_REQUIRED,
+			clientCertificate: false,
+			wantErr:           true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConfig, err := NewServerConfig(keystore, truststore, tt.clientAuth)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			clientConfig := &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+			if tt.clientCertificate {
+				clientConfig.Certificates = []tls.Certificate{client.keyPair(t)}
+			}
+
+			got, err := handshake(t, serverConfig, clientConfig, mapper)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected handshake error but got principal %s", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.wantPrincipal {
+				t.Errorf("Principal mismatch: got %s, want %s", got, tt.wantPrincipal)
+			}
+		})
+	}
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		if _, err := NewServerConfig("", truststore, CLIENT_AUTH_NONE); !errors.Is(err, ErrMissingKeystore) {
+			t.Errorf("expected ErrMissingKeystore, got %v", err)
+		}
+
+		if _, err := NewServerConfig(keystore, truststore, "always"); !errors.Is(err, ErrInvalidClientAuth) {
+			t.Errorf("expected ErrInvalidClientAuth, got %v", err)
+		}
+
+		if _, err := NewServerConfig(keystore, keystore+".missing", CLIENT_AUTH_REQUIRED); err == nil {
+			t.Errorf("expected an error for a missing truststore")
+		}
+	})
+}
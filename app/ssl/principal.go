@@ -0,0 +1,111 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AnonymousPrincipal is used for SSL clients that did not present a certificate
+const AnonymousPrincipal = "User:ANONYMOUS"
+
+var ErrInvalidMappingRules = errors.New("invalid ssl.principal.mapping.rules")
+
+// mappingRule is either DEFAULT, which keeps the distinguished name, or RULE:pattern/replacement/[LU]
+type mappingRule struct {
+	isDefault   bool
+	pattern     *regexp.Regexp
+	replacement string
+	// "L" or "U" to change the case of the result
+	caseChange string
+}
+
+// One rule and the comma that separates it from the next one. Slashes in patterns and replacements are escaped as \/
+var mappingRulePattern = regexp.MustCompile(`^\s*(?:(DEFAULT)|RULE:((?:\\/|[^/])*)/((?:\\/|[^/])*)/([LU]?))\s*(?:,|$)`)
+
+// PrincipalMapper turns the subject of client certificates into principals, following ssl.principal.mapping.rules.
+// The first rule matching the whole distinguished name gives the user name
+type PrincipalMapper struct {
+	rules []mappingRule
+}
+
+// NewPrincipalMapper parses mapping rules, an empty string is the same as DEFAULT
+func NewPrincipalMapper(rules string) (*PrincipalMapper, error) {
+	mapper := &PrincipalMapper{}
+
+	remaining := strings.TrimSpace(rules)
+	if remaining == "" {
+		remaining = "DEFAULT"
+	}
+
+	for remaining != "" {
+		match := mappingRulePattern.FindStringSubmatch(remaining)
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMappingRules, remaining)
+		}
+		remaining = remaining[len(match[0]):]
+
+		if match[1] != "" {
+			mapper.rules = append(mapper.rules, mappingRule{isDefault: true})
+			continue
+		}
+
+		pattern, err := regexp.Compile("^(?:" + unescapeSlashes(match[2]) + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidMappingRules, err)
+		}
+
+		mapper.rules = append(mapper.rules, mappingRule{
+			pattern:     pattern,
+			replacement: unescapeSlashes(match[3]),
+			caseChange:  match[4],
+		})
+	}
+
+	return mapper, nil
+}
+
+func unescapeSlashes(s string) string {
+	return strings.ReplaceAll(s, `\/`, "/")
+}
+
+// Name applies the rules to a distinguished name, such as CN=alice,OU=eng,O=example
+func (m *PrincipalMapper) Name(distinguishedName string) (string, error) {
+	for _, rule := range m.rules {
+		if rule.isDefault {
+			return distinguishedName, nil
+		}
+
+		if !rule.pattern.MatchString(distinguishedName) {
+			continue
+		}
+
+		name := rule.pattern.ReplaceAllString(distinguishedName, rule.replacement)
+		switch rule.caseChange {
+		case "L":
+			name = strings.ToLower(name)
+		case "U":
+			name = strings.ToUpper(name)
+		}
+
+		return name, nil
+	}
+
+	return "", fmt.Errorf("no ssl.principal.mapping.rules rule matches %s", distinguishedName)
+}
+
+// Principal is the principal of a client from its TLS connection state, the handshake must have completed
+func (m *PrincipalMapper) Principal(state tls.ConnectionState) (string, error) {
+	if len(state.PeerCertificates) == 0 {
+		return AnonymousPrincipal, nil
+	}
+
+	name, err := m.Name(state.PeerCertificates[0].Subject.String())
+	if err != nil {
+		return "", err
+	}
+
+	return "User:" + name, nil
+}
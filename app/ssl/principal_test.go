@@ -0,0 +1,80 @@
+package ssl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrincipalMapperName(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    string
+		dn       string
+		wantName string
+		wantErr  bool
+	}{
+		{
+			name:     "Default rules",
+			rules:    "",
+			dn:       "CN=alice,OU=eng,O=example",
+			wantName: "CN=alice,OU=eng,O=example",
+		},
+		{
+			name:     "Common name of a unit",
+			rules:    "RULE:^CN=(.*?),OU=eng.*$/$1/,DEFAULT",
+			dn:       "CN=alice,OU=eng,O=example",
+			wantName: "alice",
+		},
+		{
+			name:     "Falls back to DEFAULT",
+			rules:    "RULE:^CN=(.*?),OU=eng.*$/$1/,DEFAULT",
+			dn:       "CN=bob,OU=ops,O=example",
+			wantName: "CN=bob,OU=ops,O=example",
+		},
+		{
+			name:     "Case change and escaped slash",
+			rules:    `RULE:^CN=(.*?),OU=(.*?),.*$/$2\/$1/U, DEFAULT`,
+			dn:       "CN=alice,OU=eng,O=example",
+			wantName: "ENG/ALICE",
+		},
+		{
+			name:    "No matching rule",
+			rules:   "RULE:^CN=(.*?),OU=eng.*$/$1/L",
+			dn:      "CN=bob,OU=ops,O=example",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewPrincipalMapper(tt.rules)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := mapper.Name(tt.dn)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got %s", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.wantName {
+				t.Errorf("Name mismatch: got %s, want %s", got, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestNewPrincipalMapperInvalidRules(t *testing.T) {
+	for _, rules := range []string{"RULE:^CN=(.*)$/$1", "RULE:^CN=(.*$/$1/", "NONE"} {
+		if _, err := NewPrincipalMapper(rules); !errors.Is(err, ErrInvalidMappingRules) {
+			t.Errorf("expected ErrInvalidMappingRules for %q, got %v", rules, err)
+		}
+	}
+}
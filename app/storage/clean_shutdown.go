@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// CleanShutdownFile is written in every log dir once the broker has stopped cleanly, the same name Kafka uses
+const CleanShutdownFile = ".kafka_cleanshutdown"
+
+// cleanShutdownMarker is the content of the clean shutdown marker, the same JSON Kafka writes. The broker epoch is the
+// one of the registration the broker stopped from, -1 when it was not registered
+type cleanShutdownMarker struct {
+	Version     int   `json:"version"`
+	BrokerEpoch int64 `json:"broker_epoch"`
+}
+
+// MarkCleanShutdown writes the clean shutdown marker with brokerEpoch in every log dir, creating the dirs when needed.
+// It must only be called once the logs have been flushed and closed
+func MarkCleanShutdown(logDirs []string, brokerEpoch int64) error {
+	data, err := json.Marshal(cleanShutdownMarker{Version: 0, BrokerEpoch: brokerEpoch})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range logDirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create log dir %s: %w", dir, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, CleanShutdownFile), data, 0o644); err != nil {
+			return fmt.Errorf("failed to write the clean shutdown marker in %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
+// ConsumeCleanShutdown tells if the broker stopped cleanly last time, meaning every log dir holds the marker, and
+// returns the broker epoch the markers agree on, -1 otherwise. The broker sends it when it registers again, so that
+// the controller keeps it in the ELRs. It must be called before the logs are loaded, which trust the logs of a clean
+// shutdown. The markers are removed so that a crash of this run is not mistaken for a clean shutdown. A log dir that
+// is not a dir has no marker, it is left for the log manager to take offline. A log dir without any log, such as on
+// the first start, has nothing to recover and counts as clean
+func ConsumeCleanShutdown(logDirs []string) (bool, int64, error) {
+	clean := len(logDirs) > 0
+	brokerEpoch, marked := int64(-1), false
+
+	for _, dir := range logDirs {
+		path := filepath.Join(dir, CleanShutdownFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			// A marker without epoch, such as one of an older version, tells nothing of the registration
+			marker := cleanShutdownMarker{BrokerEpoch: -1}
+			if json.Unmarshal(data, &marker) != nil {
+				marker.BrokerEpoch = -1
+			}
+			if !marked {
+				brokerEpoch, marked = marker.BrokerEpoch, true
+			} else if marker.BrokerEpoch != brokerEpoch {
+				brokerEpoch = -1
+			}
+			err = os.Remove(path)
+		}
+		if errors.Is(err, syscall.ENOTDIR) {
+			clean = false
+			continue
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			hasLogs, logsErr := containsLogs(dir)
+			if logsErr != nil {
+				return false, -1, logsErr
+			}
+			clean = clean && !hasLogs
+			continue
+		}
+
+		if err != nil {
+			return false, -1, fmt.Errorf("failed to consume the clean shutdown marker of %s: %w", dir, err)
+		}
+	}
+
+	if !clean {
+		return false, -1, nil
+	}
+	return true, brokerEpoch, nil
+}
+
+// containsLogs tells if a log dir holds a partition log or the metadata log, a missing dir holds none
+func containsLogs(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, _, ok := parsePartitionDirName(entry.Name()); ok || entry.Name() == MetadataLogDirName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
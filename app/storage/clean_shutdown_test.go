@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanShutdown(t *testing.T) {
+	root := t.TempDir()
+	logDirs := []string{filepath.Join(root, "a"), filepath.Join(root, "b")}
+
+	// Once a log dir holds a log, a missing marker means the broker crashed
+	for _, dir := range logDirs {
+		if err := os.MkdirAll(filepath.Join(dir, "foo-0"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clean, _, err := ConsumeCleanShutdown(logDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean {
+		t.Errorf("logs without a marker must not be a clean restart")
+	}
+
+	if err := MarkCleanShutdown(logDirs, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	clean, brokerEpoch, err := ConsumeCleanShutdown(logDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !clean || brokerEpoch != 7 {
+		t.Errorf("expected a clean restart from broker epoch 7 after MarkCleanShutdown, got %v and %d", clean, brokerEpoch)
+	}
+
+	// The markers are consumed, a crash now must be detected on the next start
+	clean, _, err = ConsumeCleanShutdown(logDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean {
+		t.Errorf("markers must be removed once consumed")
+	}
+
+	// A single missing marker means some log dir was not shut down cleanly
+	if err := MarkCleanShutdown(logDirs, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(logDirs[1], CleanShutdownFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	clean, _, err = ConsumeCleanShutdown(logDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean {
+		t.Errorf("expected an unclean restart when a marker is missing")
+	}
+
+	// A single dir without a marker holding the metadata log is not clean either
+	metadataDir := filepath.Join(root, "d")
+	if err := os.MkdirAll(filepath.Join(metadataDir, MetadataLogDirName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if clean, _, err := ConsumeCleanShutdown([]string{metadataDir}); err != nil || clean {
+		t.Errorf("expected an unclean restart with the metadata log, got %v, %v", clean, err)
+	}
+
+	// A log dir that is a file has no marker, the log manager takes it offline
+	failed := filepath.Join(root, "c")
+	if err := os.WriteFile(failed, []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if clean, _, err := ConsumeCleanShutdown([]string{failed}); err != nil || clean {
+		t.Errorf("expected an unclean restart without error, got %v, %v", clean, err)
+	}
+}
+
+func TestCleanShutdownOfFreshLogDirs(t *testing.T) {
+	root := t.TempDir()
+
+	// The first start has missing or empty log dirs, there is nothing to recover
+	empty := filepath.Join(root, "empty")
+	if err := os.MkdirAll(empty, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(empty, MetaPropertiesFile), []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clean, _, err := ConsumeCleanShutdown([]string{filepath.Join(root, "missing"), empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !clean {
+		t.Errorf("expected a fresh log dir to count as a clean shutdown")
+	}
+}
+
+func TestCleanShutdownBrokerEpoch(t *testing.T) {
+	root := t.TempDir()
+	logDirs := []string{filepath.Join(root, "a"), filepath.Join(root, "b")}
+
+	tests := []struct {
+		name    string
+		markers []string
+		want    int64
+	}{
+		{"Same epoch", []string{`{"version":0,"broker_epoch":7}`, `{"version":0,"broker_epoch":7}`}, 7},
+		{"Different epochs", []string{`{"version":0,"broker_epoch":7}`, `{"version":0,"broker_epoch":8}`}, -1},
+		{"Marker without epoch", []string{``, `{"version":0,"broker_epoch":7}`}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, dir := range logDirs {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, CleanShutdownFile), []byte(tt.markers[i]), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clean, brokerEpoch, err := ConsumeCleanShutdown(logDirs)
+			if err != nil || !clean || brokerEpoch != tt.want {
+				t.Errorf("expected a clean restart from broker epoch %d, got %v, %d, %v", tt.want, clean, brokerEpoch, err)
+			}
+		})
+	}
+}
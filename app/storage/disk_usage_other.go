@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package storage
+
+import "errors"
+
+// diskUsage is only implemented for linux and darwin, the disk usage of the log dirs is unknown elsewhere
+func diskUsage(path string) (int64, int64, error) {
+	return 0, 0, errors.New("disk usage is not supported on this platform")
+}
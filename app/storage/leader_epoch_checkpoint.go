@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LeaderEpochCheckpointFile is kept in every partition dir, the same name and format Kafka uses
+const LeaderEpochCheckpointFile = "leader-epoch-checkpoint"
+
+const leaderEpochCheckpointVersion = 0
+
+// Returned by EndOffsetFor when the epoch is unknown, like Kafka's UNDEFINED_EPOCH and UNDEFINED_EPOCH_OFFSET
+const (
+	UNDEFINED_EPOCH        int32 = -1
+	UNDEFINED_EPOCH_OFFSET int64 = -1
+)
+
+var ErrInvalidCheckpoint = errors.New("invalid leader epoch checkpoint")
+
+// EpochEntry is the first offset written by the leader of an epoch
+type EpochEntry struct {
+	Epoch       int32
+	StartOffset int64
+}
+
+// LeaderEpochCache maps each leader epoch of a partition to its start offset. Followers use it to find where their log
+// diverged from the leader's after a leader change. Every change is written to the checkpoint file before returning
+type LeaderEpochCache struct {
+	path string
+
+	mutex   sync.Mutex
+	entries []EpochEntry
+}
+
+// LoadLeaderEpochCache reads the checkpoint of a partition dir, a missing checkpoint is an empty cache
+func LoadLeaderEpochCache(partitionDir string) (*LeaderEpochCache, error) {
+	cache := &LeaderEpochCache{path: filepath.Join(partitionDir, LeaderEpochCheckpointFile)}
+
+	file, err := os.Open(cache.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", cache.path, err)
+	}
+	defer file.Close()
+
+	cache.entries, err = parseLeaderEpochCheckpoint(bufio.NewScanner(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cache.path, err)
+	}
+
+	return cache, nil
+}
+
+// parseLeaderEpochCheckpoint reads the version line, the entry count line, then one "epoch start_offset" line per entry
+func parseLeaderEpochCheckpoint(scanner *bufio.Scanner) ([]EpochEntry, error) {
+	readInt := func(what string) (int64, error) {
+		if !scanner.Scan() {
+			return 0, fmt.Errorf("%w: missing %s", ErrInvalidCheckpoint, what)
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid %s: %w", ErrInvalidCheckpoint, what, err)
+		}
+		return value, nil
+	}
+
+	version, err := readInt("version")
+	if err != nil {
+		return nil, err
+	}
+	if version != leaderEpochCheckpointVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidCheckpoint, version)
+	}
+
+	count, err := readInt("entry count")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]EpochEntry, 0, count)
+	for range count {
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("%w: expected %d entries, got %d", ErrInvalidCheckpoint, count, len(entries))
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: invalid entry %q", ErrInvalidCheckpoint, scanner.Text())
+		}
+
+		epoch, epochErr := strconv.ParseInt(fields[0], 10, 32)
+		startOffset, offsetErr := strconv.ParseInt(fields[1], 10, 64)
+		if epochErr != nil || offsetErr != nil {
+			return nil, fmt.Errorf("%w: invalid entry %q", ErrInvalidCheckpoint, scanner.Text())
+		}
+
+		entries = append(entries, EpochEntry{Epoch: int32(epoch), StartOffset: startOffset})
+	}
+
+	return entries, scanner.Err()
+}
+
+func (c *LeaderEpochCache) Entries() []EpochEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.entries)
+}
+
+// LatestEpoch returns UNDEFINED_EPOCH while the cache is empty
+func (c *LeaderEpochCache) LatestEpoch() int32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) == 0 {
+		return UNDEFINED_EPOCH
+	}
+	return c.entries[len(c.entries)-1].Epoch
+}
+
+// Assign records that epoch starts at startOffset, when its leader appends its first records. Entries that conflict
+// with it, of a later epoch or starting at or after the offset, are removed as they can only come from a diverged log
+func (c *LeaderEpochCache) Assign(epoch int32, startOffset int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) > 0 {
+		latest := c.entries[len(c.entries)-1]
+		if latest.Epoch == epoch && latest.StartOffset <= startOffset {
+			return nil
+		}
+	}
+
+	entries := make([]EpochEntry, 0, len(c.entries)+1)
+	for _, entry := range c.entries {
+		if entry.Epoch < epoch && entry.StartOffset < startOffset {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, EpochEntry{Epoch: epoch, StartOffset: startOffset})
+
+	return c.update(entries)
+}
+
+// EndOffsetFor answers OffsetForLeaderEpoch: the largest epoch up to requestedEpoch and the offset where it ended,
+// which is the start offset of the next epoch or logEndOffset for the latest one
+func (c *LeaderEpochCache) EndOffsetFor(requestedEpoch int32, logEndOffset int64) (int32, int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if requestedEpoch == UNDEFINED_EPOCH || len(c.entries) == 0 {
+		return UNDEFINED_EPOCH, UNDEFINED_EPOCH_OFFSET
+	}
+
+	if latest := c.entries[len(c.entries)-1]; requestedEpoch == latest.Epoch {
+		return latest.Epoch, logEndOffset
+	}
+
+	// The first epoch after the requested one ends it
+	higher, _ := slices.BinarySearchFunc(c.entries, requestedEpoch+1, func(entry EpochEntry, epoch int32) int {
+		return cmp.Compare(entry.Epoch, epoch)
+	})
+	if higher == len(c.entries) {
+		return UNDEFINED_EPOCH, UNDEFINED_EPOCH_OFFSET
+	}
+
+	// An epoch older than every known one ends where the oldest known one starts
+	if higher == 0 {
+		return requestedEpoch, c.entries[0].StartOffset
+	}
+
+	return c.entries[higher-1].Epoch, c.entries[higher].StartOffset
+}
+
+// TruncateFromEnd removes the epochs starting at or after endOffset, after the log was truncated to it
+func (c *LeaderEpochCache) TruncateFromEnd(endOffset int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	end := len(c.entries)
+	for end > 0 && c.entries[end-1].StartOffset >= endOffset {
+		end--
+	}
+	if end == len(c.entries) {
+		return nil
+	}
+
+	return c.update(slices.Clone(c.entries[:end]))
+}
+
+// TruncateFromStart removes the epochs that ended before startOffset, after the start of the log was deleted.
+// The epoch containing startOffset now starts there
+func (c *LeaderEpochCache) TruncateFromStart(startOffset int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	first := 0
+	for first+1 < len(c.entries) && c.entries[first+1].StartOffset <= startOffset {
+		first++
+	}
+	if len(c.entries) == 0 || (first == 0 && c.entries[0].StartOffset >= startOffset) {
+		return nil
+	}
+
+	entries := slices.Clone(c.entries[first:])
+	entries[0].StartOffset = max(entries[0].StartOffset, startOffset)
+
+	return c.update(entries)
+}
+
+// Clear removes every epoch, after the log was emptied to restart at another offset
+func (c *LeaderEpochCache) Clear() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) == 0 {
+		return nil
+	}
+	return c.update([]EpochEntry{})
+}
+
+// rename points the cache to the checkpoint of a partition dir that was renamed
+func (c *LeaderEpochCache) rename(partitionDir string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.path = filepath.Join(partitionDir, LeaderEpochCheckpointFile)
+}
+
+// update writes the entries to a temporary file renamed over the checkpoint, so that a crash never leaves it half written
+func (c *LeaderEpochCache) update(entries []EpochEntry) error {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "%d\n%d\n", leaderEpochCheckpointVersion, len(entries))
+	for _, entry := range entries {
+		fmt.Fprintf(builder, "%d %d\n", entry.Epoch, entry.StartOffset)
+	}
+
+	temporary := c.path + ".tmp"
+	if err := os.WriteFile(temporary, []byte(builder.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", temporary, err)
+	}
+	if err := os.Rename(temporary, c.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", c.path, err)
+	}
+
+	c.entries = entries
+	return nil
+}
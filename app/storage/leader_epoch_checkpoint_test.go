@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLeaderEpochCacheEndOffsetFor(t *testing.T) {
+	cache, err := LoadLeaderEpochCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range []EpochEntry{{Epoch: 2, StartOffset: 10}, {Epoch: 3, StartOffset: 25}, {Epoch: 5, StartOffset: 40}} {
+		if err := cache.Assign(entry.Epoch, entry.StartOffset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		requestedEpoch int32
+		wantEpoch      int32
+		wantEndOffset  int64
+	}{
+		{"Undefined epoch", UNDEFINED_EPOCH, UNDEFINED_EPOCH, UNDEFINED_EPOCH_OFFSET},
+		{"Older than every epoch", 1, 1, 10},
+		{"Known epoch", 2, 2, 25},
+		{"Epoch without leader appends", 4, 3, 40},
+		{"Latest epoch", 5, 5, 52},
+		{"Newer than every epoch", 6, UNDEFINED_EPOCH, UNDEFINED_EPOCH_OFFSET},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			epoch, endOffset := cache.EndOffsetFor(tt.requestedEpoch, 52)
+			if epoch != tt.wantEpoch || endOffset != tt.wantEndOffset {
+				t.Errorf("got (%d, %d), want (%d, %d)", epoch, endOffset, tt.wantEpoch, tt.wantEndOffset)
+			}
+		})
+	}
+}
+
+func TestLeaderEpochCacheAssignRemovesConflictingEntries(t *testing.T) {
+	cache, err := LoadLeaderEpochCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Assign(1, 0)
+	cache.Assign(2, 10)
+	cache.Assign(2, 15)
+	cache.Assign(3, 20)
+	// A new leader of epoch 4 whose log ends at 12 overwrites what epochs 2 and 3 appended after it
+	cache.Assign(4, 12)
+
+	want := []EpochEntry{{Epoch: 1, StartOffset: 0}, {Epoch: 2, StartOffset: 10}, {Epoch: 4, StartOffset: 12}}
+	if got := cache.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries mismatch:\ngot  %v\nwant %v", got, want)
+	}
+	if cache.LatestEpoch() != 4 {
+		t.Errorf("expected latest epoch 4, got %d", cache.LatestEpoch())
+	}
+}
+
+func TestLeaderEpochCacheTruncation(t *testing.T) {
+	tests := []struct {
+		name     string
+		truncate func(cache *LeaderEpochCache) error
+		want     []EpochEntry
+	}{
+		{
+			name:     "From end",
+			truncate: func(cache *LeaderEpochCache) error { return cache.TruncateFromEnd(20) },
+			want:     []EpochEntry{{Epoch: 1, StartOffset: 0}, {Epoch: 2, StartOffset: 10}},
+		},
+		{
+			name:     "From end past the log",
+			truncate: func(cache *LeaderEpochCache) error { return cache.TruncateFromEnd(100) },
+			want:     []EpochEntry{{Epoch: 1, StartOffset: 0}, {Epoch: 2, StartOffset: 10}, {Epoch: 3, StartOffset: 20}},
+		},
+		{
+			name:     "From start inside an epoch",
+			truncate: func(cache *LeaderEpochCache) error { return cache.TruncateFromStart(15) },
+			want:     []EpochEntry{{Epoch: 2, StartOffset: 15}, {Epoch: 3, StartOffset: 20}},
+		},
+		{
+			name:     "From start at an epoch boundary",
+			truncate: func(cache *LeaderEpochCache) error { return cache.TruncateFromStart(20) },
+			want:     []EpochEntry{{Epoch: 3, StartOffset: 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := LoadLeaderEpochCache(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cache.Assign(1, 0)
+			cache.Assign(2, 10)
+			cache.Assign(3, 20)
+
+			if err := tt.truncate(cache); err != nil {
+				t.Fatal(err)
+			}
+			if got := cache.Entries(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries mismatch:\ngot  %v\nwant %v", got, tt.want)
+			}
+
+			// The checkpoint holds the same entries
+			reloaded, err := LoadLeaderEpochCache(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reloaded.Entries(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reloaded entries mismatch:\ngot  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaderEpochCheckpointFormat(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := LoadLeaderEpochCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Assign(0, 0)
+	cache.Assign(4, 118)
+
+	content, err := os.ReadFile(filepath.Join(dir, LeaderEpochCheckpointFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "0\n2\n0 0\n4 118\n" {
+		t.Errorf("unexpected checkpoint %q", content)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, LeaderEpochCheckpointFile), []byte("0\n2\n0 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLeaderEpochCache(dir); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("expected ErrInvalidCheckpoint for a truncated checkpoint, got %v", err)
+	}
+}
@@ -0,0 +1,804 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The segments of a log are named after the offset of their first record, like the segments of a Kafka log
+const logSegmentSuffix = ".log"
+
+func segmentFileName(baseOffset int64) string {
+	return fmt.Sprintf("%020d%s", baseOffset, logSegmentSuffix)
+}
+
+// The record batch header fields the log reads, the rest of the batch is stored as is
+const (
+	batchLengthOffset     = 8
+	leaderEpochOffset     = 12
+	magicOffset           = 16
+	crcOffset             = 17
+	attributesOffset      = 21
+	lastOffsetDeltaOffset = 23
+	baseTimestampOffset   = 27
+	maxTimestampOffset    = 35
+	producerIdOffset      = 43
+	producerEpochOffset   = 51
+	baseSequenceOffset    = 53
+	recordsCountOffset    = 57
+	batchHeaderSize       = 61
+)
+
+// The bits of the batch attributes the log reads: the timestamp type, set when the timestamps are the log append
+// time, and the flags of the batches of a transaction and of the control batches
+const (
+	logAppendTimeAttribute = 0x08
+	transactionalAttribute = 0x10
+	controlAttribute       = 0x20
+)
+
+// The checksum of a record batch is a CRC-32C of everything from its attributes
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrKafkaStorage is returned when the disk of a log failed, or when its log dir went offline after such a failure
+	ErrKafkaStorage = errors.New("log dir is offline")
+	// ErrInvalidRecordBatch rejects appends that are not a sequence of complete record batches
+	ErrInvalidRecordBatch = errors.New("invalid record batch")
+	// ErrRecordTooLarge rejects the appends of a leader with a batch larger than max.message.bytes
+	ErrRecordTooLarge = errors.New("record batch too large")
+	// ErrOffsetOutOfRange is returned for reads before the log start offset or beyond the log end offset
+	ErrOffsetOutOfRange = errors.New("offset out of range")
+)
+
+// batchPosition locates a record batch in its segment
+type batchPosition struct {
+	baseOffset int64
+	lastOffset int64
+	position   int64
+	size       int64
+	// The partition leader epoch of the leader that appended the batch
+	leaderEpoch int32
+	// The largest timestamp of the records of the batch
+	maxTimestamp int64
+	// The producer of the batch, -1 without one, and the sequence of its first record
+	producerId    int64
+	producerEpoch int16
+	baseSequence  int32
+	transactional bool
+	control       bool
+	// The marker of a control batch ending a transaction and the epoch of the coordinator that wrote it, noMarker for
+	// the other batches
+	marker           int16
+	coordinatorEpoch int32
+}
+
+// lastSequence is the sequence of the last record of the batch
+func (b batchPosition) lastSequence() int32 {
+	return int32((int64(b.baseSequence) + b.lastOffset - b.baseOffset) % (math.MaxInt32 + 1))
+}
+
+// logSegment is a file of the log, holding the batches from baseOffset
+type logSegment struct {
+	baseOffset int64
+	file       *os.File
+	// Every batch of the segment in offset order, read back when the log is opened
+	batches []batchPosition
+	size    int64
+	// When the segment was created or loaded, segment.ms rolls it from there
+	created time.Time
+	// The largest timestamp of the batches, -1 when they have none
+	maxTimestamp int64
+}
+
+func (s *logSegment) add(batch batchPosition) {
+	s.batches = append(s.batches, batch)
+	s.maxTimestamp = max(s.maxTimestamp, batch.maxTimestamp)
+}
+
+// Log is the record batches of a partition replica in a partition dir of a log dir, split in segments that are rolled
+// and deleted following the topic configs
+type Log struct {
+	Topic     string
+	Partition int32
+
+	mutex  sync.Mutex
+	dir    string
+	config LogConfig
+	now    func() time.Time
+	// The epochs of the leaders that appended the batches, checkpointed in the partition dir
+	epochs *LeaderEpochCache
+	// The producers of the batches and their transactions
+	producers *producerStates
+	// The segments in offset order, the last one is the active segment the appends go to
+	segments       []*logSegment
+	logStartOffset int64
+	logEndOffset   int64
+	size           int64
+}
+
+// openLog opens the log of a partition dir, creating it when needed. A batch left incomplete by a crash is truncated.
+// After a crash verifyBatches also checks the checksum of every batch, while after a clean shutdown the log is trusted
+// and only the batch headers are read
+func openLog(dir string, topic string, partition int32, verifyBatches bool) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: failed to create partition dir %s: %w", ErrKafkaStorage, dir, err)
+	}
+
+	epochs, err := LoadLeaderEpochCache(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKafkaStorage, err)
+	}
+
+	log := &Log{Topic: topic, Partition: partition, dir: dir, config: DefaultLogConfig, now: time.Now, epochs: epochs, producers: newProducerStates()}
+	if err := log.recover(verifyBatches); err != nil {
+		for _, segment := range log.segments {
+			segment.file.Close()
+		}
+		return nil, fmt.Errorf("%w: failed to recover %s: %w", ErrKafkaStorage, dir, err)
+	}
+
+	return log, nil
+}
+
+// recover opens the segments of the partition dir in offset order. The log starts at the first one, and ends after
+// the last complete batch, or before the first corrupt one when verifyBatches is set: the rest of its segment is
+// truncated and the segments after it are deleted. The leader epoch cache and the producer states follow the batches
+// that are left
+func (l *Log) recover(verifyBatches bool) error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+
+	baseOffsets := []int64{}
+	for _, entry := range entries {
+		// A compaction that did not complete left its segment as it was
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), cleanedSegmentSuffix) {
+			if err := os.Remove(filepath.Join(l.dir, entry.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), logSegmentSuffix) {
+			continue
+		}
+		baseOffset, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), logSegmentSuffix), 10, 64)
+		if err == nil && baseOffset >= 0 {
+			baseOffsets = append(baseOffsets, baseOffset)
+		}
+	}
+	slices.Sort(baseOffsets)
+	if len(baseOffsets) == 0 {
+		baseOffsets = []int64{0}
+	}
+
+	l.logStartOffset = baseOffsets[0]
+	l.logEndOffset = baseOffsets[0]
+	complete := true
+	for _, baseOffset := range baseOffsets {
+		// The segments after a truncated one, or after a gap, hold records the log no longer follows
+		if !complete || baseOffset != l.logEndOffset {
+			if err := os.Remove(filepath.Join(l.dir, segmentFileName(baseOffset))); err != nil {
+				return err
+			}
+			complete = false
+			continue
+		}
+
+		segment, truncated, err := l.recoverSegment(baseOffset, verifyBatches)
+		if err != nil {
+			return err
+		}
+		l.segments = append(l.segments, segment)
+		l.size += segment.size
+		if len(segment.batches) > 0 {
+			l.logEndOffset = segment.batches[len(segment.batches)-1].lastOffset + 1
+		}
+		complete = !truncated
+	}
+
+	if err := l.epochs.TruncateFromEnd(l.logEndOffset); err != nil {
+		return err
+	}
+	return l.epochs.TruncateFromStart(l.logStartOffset)
+}
+
+// recoverSegment reads the batch headers of a segment, and truncates it after the last batch that follows the log. It
+// returns true when something was truncated
+func (l *Log) recoverSegment(baseOffset int64, verifyBatches bool) (*logSegment, bool, error) {
+	path := filepath.Join(l.dir, segmentFileName(baseOffset))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+	segment := &logSegment{baseOffset: baseOffset, file: file, created: l.now(), maxTimestamp: -1}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, false, err
+	}
+
+	header := make([]byte, batchHeaderSize)
+	position := int64(0)
+	nextOffset := baseOffset
+	for position+batchHeaderSize <= info.Size() {
+		if _, err := file.ReadAt(header, position); err != nil {
+			file.Close()
+			return nil, false, err
+		}
+
+		// Compaction leaves gaps between the batches
+		batch, ok := parseBatchHeader(header, position)
+		if !ok || batch.baseOffset < nextOffset || position+batch.size > info.Size() {
+			break
+		}
+		if verifyBatches || batch.control {
+			valid, err := readBatch(file, &batch, verifyBatches)
+			if err != nil {
+				file.Close()
+				return nil, false, err
+			}
+			if !valid {
+				break
+			}
+		}
+
+		segment.add(batch)
+		l.producers.update(batch)
+		nextOffset = batch.lastOffset + 1
+		position += batch.size
+		if err := l.assignEpoch(batch); err != nil {
+			file.Close()
+			return nil, false, err
+		}
+	}
+
+	if position < info.Size() {
+		if err := file.Truncate(position); err != nil {
+			file.Close()
+			return nil, false, err
+		}
+	}
+	segment.size = position
+
+	return segment, position < info.Size(), nil
+}
+
+// assignEpoch records the epoch of a batch in the leader epoch cache when it starts a new epoch. Batches written
+// before epochs were tracked have none
+func (l *Log) assignEpoch(batch batchPosition) error {
+	if batch.leaderEpoch < 0 || batch.leaderEpoch <= l.epochs.LatestEpoch() {
+		return nil
+	}
+	return l.epochs.Assign(batch.leaderEpoch, batch.baseOffset)
+}
+
+// readBatch reads a batch whose header was parsed, to check its checksum when verify is set and to read the marker of
+// a control batch. It returns false for a corrupt batch
+func readBatch(file *os.File, batch *batchPosition, verify bool) (bool, error) {
+	data := make([]byte, batch.size)
+	if _, err := file.ReadAt(data, batch.position); err != nil {
+		return false, err
+	}
+
+	if verify && crc32.Checksum(data[attributesOffset:], crcTable) != binary.BigEndian.Uint32(data[crcOffset:]) {
+		return false, nil
+	}
+	return batch.readMarker(data), nil
+}
+
+// parseBatchHeader reads the offsets, the size, the max timestamp and the producer of the batch starting with header.
+// The marker of a control batch is in its record, see readMarker
+func parseBatchHeader(header []byte, position int64) (batchPosition, bool) {
+	batchLength := int32(binary.BigEndian.Uint32(header[batchLengthOffset:]))
+	lastOffsetDelta := int32(binary.BigEndian.Uint32(header[lastOffsetDeltaOffset:]))
+	if batchLength < batchHeaderSize-batchLengthOffset-4 || lastOffsetDelta < 0 {
+		return batchPosition{}, false
+	}
+
+	baseOffset := int64(binary.BigEndian.Uint64(header))
+	attributes := binary.BigEndian.Uint16(header[attributesOffset:])
+	return batchPosition{
+		baseOffset:       baseOffset,
+		lastOffset:       baseOffset + int64(lastOffsetDelta),
+		position:         position,
+		size:             batchLengthOffset + 4 + int64(batchLength),
+		leaderEpoch:      int32(binary.BigEndian.Uint32(header[leaderEpochOffset:])),
+		maxTimestamp:     int64(binary.BigEndian.Uint64(header[maxTimestampOffset:])),
+		producerId:       int64(binary.BigEndian.Uint64(header[producerIdOffset:])),
+		producerEpoch:    int16(binary.BigEndian.Uint16(header[producerEpochOffset:])),
+		baseSequence:     int32(binary.BigEndian.Uint32(header[baseSequenceOffset:])),
+		transactional:    attributes&transactionalAttribute != 0,
+		control:          attributes&controlAttribute != 0,
+		marker:           noMarker,
+		coordinatorEpoch: -1,
+	}, true
+}
+
+// readMarker reads the marker of a control batch from data, the whole batch. Other batches have none
+func (b *batchPosition) readMarker(data []byte) bool {
+	if !b.control {
+		return true
+	}
+	marker, coordinatorEpoch, ok := parseMarker(data)
+	b.marker, b.coordinatorEpoch = marker, coordinatorEpoch
+	return ok
+}
+
+func (l *Log) String() string {
+	return fmt.Sprintf("%s-%d", l.Topic, l.Partition)
+}
+
+// Dir is the partition dir of the log
+func (l *Log) Dir() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.dir
+}
+
+// SetConfig applies the topic configs to the log, from the next append or deletion of old segments
+func (l *Log) SetConfig(config LogConfig) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.config = config
+}
+
+// LogStartOffset is the offset of the first record the log still has
+func (l *Log) LogStartOffset() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.logStartOffset
+}
+
+func (l *Log) LogEndOffset() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.logEndOffset
+}
+
+// Size is the number of bytes of the record batches
+func (l *Log) Size() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.size
+}
+
+// AppendInfo is where an append placed its records. LastOffset is FirstOffset-1 when nothing was appended.
+// LogAppendTime is the timestamp the leader gave the batches, -1 when they keep their create time
+type AppendInfo struct {
+	FirstOffset   int64
+	LastOffset    int64
+	LogAppendTime int64
+}
+
+// appendOrigin is where the batches appended to a log come from, like the AppendOrigin of Kafka
+type appendOrigin int
+
+const (
+	// The leader appends the batches of a producer
+	appendFromClient appendOrigin = iota
+	// The leader appends the records of a coordinator, such as its transaction markers
+	appendFromCoordinator
+	// A follower copies the batches of its leader
+	appendFromReplication
+)
+
+// Append writes record batches as the leader of leaderEpoch, which assigns their offsets from the log end offset and
+// stamps them with its epoch, and with the append time for the LogAppendTime timestamp type. The batches of an
+// idempotent or transactional producer must follow its previous ones, and must not be control batches
+func (l *Log) Append(batches []byte, leaderEpoch int32) (AppendInfo, error) {
+	return l.append(batches, appendFromClient, leaderEpoch)
+}
+
+// AppendAsCoordinator writes the batches of a coordinator as the leader of leaderEpoch, see Append: the control
+// batches of transaction markers, or the records a coordinator writes for a producer without sequences. A batch with
+// an older epoch than its producer is rejected
+func (l *Log) AppendAsCoordinator(batches []byte, leaderEpoch int32) (AppendInfo, error) {
+	return l.append(batches, appendFromCoordinator, leaderEpoch)
+}
+
+// AppendAsFollower writes record batches copied from another replica, their offsets must follow the log end offset,
+// with the gaps of a compacted log. They keep the epoch and the timestamps the leader gave them
+func (l *Log) AppendAsFollower(batches []byte) (AppendInfo, error) {
+	return l.append(batches, appendFromReplication, 0)
+}
+
+func (l *Log) append(batches []byte, origin appendOrigin, leaderEpoch int32) (AppendInfo, error) {
+	asLeader := origin != appendFromReplication
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	info := AppendInfo{FirstOffset: l.logEndOffset, LastOffset: l.logEndOffset - 1, LogAppendTime: -1}
+	data := batches
+	if asLeader {
+		data = make([]byte, len(batches))
+		copy(data, batches)
+	}
+
+	// The batches are validated before anything is written, so that an invalid append leaves the log as it was
+	appended := []batchPosition{}
+	nextOffset := l.logEndOffset
+	for position := 0; position < len(data); {
+		if len(data)-position < batchHeaderSize {
+			return AppendInfo{}, fmt.Errorf("%w: %d bytes left after the last batch", ErrInvalidRecordBatch, len(data)-position)
+		}
+		if asLeader {
+			binary.BigEndian.PutUint64(data[position:], uint64(nextOffset))
+			binary.BigEndian.PutUint32(data[position+leaderEpochOffset:], uint32(leaderEpoch))
+			if l.config.LogAppendTime {
+				attributes := binary.BigEndian.Uint16(data[position+attributesOffset:])
+				binary.BigEndian.PutUint16(data[position+attributesOffset:], attributes|logAppendTimeAttribute)
+				binary.BigEndian.PutUint64(data[position+maxTimestampOffset:], uint64(now.UnixMilli()))
+			}
+		}
+
+		batch, ok := parseBatchHeader(data[position:], int64(position))
+		if !ok || position+int(batch.size) > len(data) {
+			return AppendInfo{}, fmt.Errorf("%w: malformed batch at byte %d", ErrInvalidRecordBatch, position)
+		}
+		// The batches a follower copies from a compacted log may have gaps between them
+		if batch.baseOffset < nextOffset {
+			return AppendInfo{}, fmt.Errorf("%w: expected base offset %d or more, got %d", ErrInvalidRecordBatch, nextOffset, batch.baseOffset)
+		}
+		if asLeader && batch.size > l.config.MaxMessageBytes {
+			return AppendInfo{}, fmt.Errorf("%w: the batch at byte %d has %d bytes, more than %d", ErrRecordTooLarge, position, batch.size, l.config.MaxMessageBytes)
+		}
+		// The checksum covers the attributes and the timestamps the leader changed
+		if asLeader && l.config.LogAppendTime {
+			end := position + int(batch.size)
+			binary.BigEndian.PutUint32(data[position+crcOffset:], crc32.Checksum(data[position+attributesOffset:end], crcTable))
+		}
+		// Only the coordinators write control batches
+		if asLeader && batch.control && origin != appendFromCoordinator {
+			return AppendInfo{}, fmt.Errorf("%w: unexpected control flag on the batch at byte %d", ErrInvalidRecordBatch, position)
+		}
+		if !batch.readMarker(data[position : position+int(batch.size)]) {
+			return AppendInfo{}, fmt.Errorf("%w: malformed control record at byte %d", ErrInvalidRecordBatch, position)
+		}
+
+		appended = append(appended, batch)
+		nextOffset = batch.lastOffset + 1
+		position += int(batch.size)
+	}
+	if len(appended) == 0 {
+		return info, nil
+	}
+	if asLeader {
+		if err := l.producers.checkAppend(appended, origin == appendFromClient); err != nil {
+			return AppendInfo{}, err
+		}
+	}
+
+	segment, err := l.maybeRoll(int64(len(data)), now)
+	if err != nil {
+		return AppendInfo{}, fmt.Errorf("%w: failed to roll %s: %w", ErrKafkaStorage, l, err)
+	}
+	if _, err := segment.file.WriteAt(data, segment.size); err != nil {
+		return AppendInfo{}, fmt.Errorf("%w: failed to append to %s: %w", ErrKafkaStorage, l, err)
+	}
+
+	for _, batch := range appended {
+		batch.position += segment.size
+		segment.add(batch)
+		l.producers.update(batch)
+	}
+	segment.size += int64(len(data))
+	l.size += int64(len(data))
+	l.logEndOffset = nextOffset
+	info.LastOffset = nextOffset - 1
+	if asLeader && l.config.LogAppendTime {
+		info.LogAppendTime = now.UnixMilli()
+	}
+
+	for _, batch := range appended {
+		if err := l.assignEpoch(batch); err != nil {
+			return AppendInfo{}, fmt.Errorf("%w: %w", ErrKafkaStorage, err)
+		}
+	}
+
+	return info, nil
+}
+
+// maybeRoll returns the segment an append of size bytes goes to: the active segment, unless the append would grow it
+// past segment.bytes or it is older than segment.ms, when a new segment starts at the log end offset. An empty
+// segment is never rolled
+func (l *Log) maybeRoll(size int64, now time.Time) (*logSegment, error) {
+	active := l.segments[len(l.segments)-1]
+	if len(active.batches) == 0 ||
+		(active.size+size <= l.config.SegmentBytes && now.Sub(active.created) < time.Duration(l.config.SegmentMs)*time.Millisecond) {
+		return active, nil
+	}
+
+	segment, err := l.newSegment(l.logEndOffset, now)
+	if err != nil {
+		return nil, err
+	}
+	l.segments = append(l.segments, segment)
+	return segment, nil
+}
+
+func (l *Log) newSegment(baseOffset int64, now time.Time) (*logSegment, error) {
+	path := filepath.Join(l.dir, segmentFileName(baseOffset))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &logSegment{baseOffset: baseOffset, file: file, created: now, maxTimestamp: -1}, nil
+}
+
+func (l *Log) deleteSegment(segment *logSegment) error {
+	segment.file.Close()
+	return os.Remove(filepath.Join(l.dir, segmentFileName(segment.baseOffset)))
+}
+
+// segmentIndex is the index of the segment holding offset, the first segment for the offsets before the log start
+func (l *Log) segmentIndex(offset int64) int {
+	return max(sort.Search(len(l.segments), func(i int) bool { return l.segments[i].baseOffset > offset })-1, 0)
+}
+
+// TruncateTo removes the batches from the one holding offset, such as the records of a follower that diverged from
+// its leader. The log end offset becomes the base offset of the first removed batch, and the producer states are
+// rebuilt from the batches that are left
+func (l *Log) TruncateTo(offset int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	index := l.segmentIndex(offset)
+	segment := l.segments[index]
+	first := sort.Search(len(segment.batches), func(i int) bool { return segment.batches[i].lastOffset >= offset })
+	if first == len(segment.batches) {
+		return nil
+	}
+
+	for _, removed := range l.segments[index+1:] {
+		if err := l.deleteSegment(removed); err != nil {
+			return fmt.Errorf("%w: failed to truncate %s: %w", ErrKafkaStorage, l, err)
+		}
+		l.size -= removed.size
+	}
+	l.segments = l.segments[:index+1]
+
+	position := segment.batches[first].position
+	if err := segment.file.Truncate(position); err != nil {
+		return fmt.Errorf("%w: failed to truncate %s: %w", ErrKafkaStorage, l, err)
+	}
+
+	l.logEndOffset = segment.batches[first].baseOffset
+	l.size -= segment.size - position
+	segment.batches = segment.batches[:first]
+	segment.size = position
+	segment.maxTimestamp = -1
+	for _, batch := range segment.batches {
+		segment.maxTimestamp = max(segment.maxTimestamp, batch.maxTimestamp)
+	}
+	l.producers.rebuild(l.segments)
+
+	if err := l.epochs.TruncateFromEnd(l.logEndOffset); err != nil {
+		return fmt.Errorf("%w: %w", ErrKafkaStorage, err)
+	}
+	return nil
+}
+
+// TruncateFullyAndStartAt deletes every record and restarts the log at offset, like a follower that fell behind the
+// log start offset of its leader
+func (l *Log) TruncateFullyAndStartAt(offset int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	segment, err := l.newSegment(offset, l.now())
+	if err != nil {
+		return fmt.Errorf("%w: failed to truncate %s: %w", ErrKafkaStorage, l, err)
+	}
+
+	removed := l.segments
+	l.segments = []*logSegment{segment}
+	l.logStartOffset = offset
+	l.logEndOffset = offset
+	l.size = 0
+	l.producers = newProducerStates()
+
+	var errs []error
+	for _, old := range removed {
+		// The new segment replaced the file of an old segment with the same base offset
+		if old.baseOffset == offset {
+			old.file.Close()
+			continue
+		}
+		if err := l.deleteSegment(old); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, l.epochs.Clear())
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: failed to truncate %s: %w", ErrKafkaStorage, l, err)
+	}
+	return nil
+}
+
+// DeleteOldSegments applies the retention of the delete cleanup policy: the oldest segments are deleted while their
+// records are older than retention.ms, or while the log stays at least retention.bytes without them. Like Kafka, only
+// the segments below highWatermark and below the first unstable transaction are deleted, and an expired active segment
+// is rolled first. It returns the number of segments deleted
+func (l *Log) DeleteOldSegments(highWatermark int64) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.config.Delete {
+		return 0, nil
+	}
+
+	now := l.now()
+	deleted := 0
+	size := l.size
+	// The records of the transactions that are not complete yet are kept
+	highWatermark = l.producers.firstUnstableOffset(highWatermark)
+	for deleted < len(l.segments) {
+		segment := l.segments[deleted]
+		endOffset := l.logEndOffset
+		if deleted+1 < len(l.segments) {
+			endOffset = l.segments[deleted+1].baseOffset
+		}
+		if len(segment.batches) == 0 || endOffset > highWatermark || !l.isExpired(segment, size, now) {
+			break
+		}
+		size -= segment.size
+		deleted++
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	if deleted == len(l.segments) {
+		segment, err := l.newSegment(l.logEndOffset, now)
+		if err != nil {
+			return 0, fmt.Errorf("%w: failed to roll %s: %w", ErrKafkaStorage, l, err)
+		}
+		l.segments = append(l.segments, segment)
+	}
+
+	for i := range deleted {
+		segment := l.segments[0]
+		if err := l.deleteSegment(segment); err != nil {
+			return i, fmt.Errorf("%w: failed to delete a segment of %s: %w", ErrKafkaStorage, l, err)
+		}
+		l.size -= segment.size
+		l.segments = l.segments[1:]
+		l.logStartOffset = l.segments[0].baseOffset
+	}
+	l.producers.truncateStart(l.logStartOffset)
+
+	if err := l.epochs.TruncateFromStart(l.logStartOffset); err != nil {
+		return deleted, fmt.Errorf("%w: %w", ErrKafkaStorage, err)
+	}
+	return deleted, nil
+}
+
+// isExpired tells if a segment is past retention.ms, or past retention.bytes in a log of size bytes. A segment whose
+// batches have no timestamp ages from when it was created or loaded
+func (l *Log) isExpired(segment *logSegment, size int64, now time.Time) bool {
+	timestamp := segment.maxTimestamp
+	if timestamp < 0 {
+		timestamp = segment.created.UnixMilli()
+	}
+	return (l.config.RetentionMs >= 0 && now.UnixMilli()-timestamp > l.config.RetentionMs) ||
+		(l.config.RetentionBytes >= 0 && size-segment.size >= l.config.RetentionBytes)
+}
+
+// LatestEpoch is the epoch of the leader that appended the last batch, UNDEFINED_EPOCH without epochs
+func (l *Log) LatestEpoch() int32 {
+	return l.epochs.LatestEpoch()
+}
+
+// EndOffsetForEpoch returns the largest epoch up to requestedEpoch and the offset where it ended, see
+// LeaderEpochCache.EndOffsetFor
+func (l *Log) EndOffsetForEpoch(requestedEpoch int32) (int32, int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.epochs.EndOffsetFor(requestedEpoch, l.logEndOffset)
+}
+
+// LeaderEpochs is the leader epoch cache of the log
+func (l *Log) LeaderEpochs() *LeaderEpochCache {
+	return l.epochs
+}
+
+// Read returns the batches from the one holding offset, at most maxBytes of them but at least one. Reading at the log
+// end offset returns no batch
+func (l *Log) Read(offset int64, maxBytes int) ([]byte, error) {
+	return l.ReadUpTo(offset, maxBytes, -1)
+}
+
+// ReadUpTo is Read without the batches past maxOffset, such as the ones above the high watermark that consumers must
+// not see yet. A negative maxOffset reads up to the log end offset. A read stops at the end of a segment
+func (l *Log) ReadUpTo(offset int64, maxBytes int, maxOffset int64) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if offset < l.logStartOffset || offset > l.logEndOffset {
+		return nil, fmt.Errorf("%w: %d is not in [%d, %d] for %s", ErrOffsetOutOfRange, offset, l.logStartOffset, l.logEndOffset, l)
+	}
+	if maxOffset < 0 {
+		maxOffset = l.logEndOffset
+	}
+
+	for _, segment := range l.segments[l.segmentIndex(offset):] {
+		batches := segment.batches
+		first := sort.Search(len(batches), func(i int) bool { return batches[i].lastOffset >= offset })
+		if first == len(batches) {
+			continue
+		}
+		if batches[first].lastOffset >= maxOffset {
+			return []byte{}, nil
+		}
+
+		last := first + 1
+		size := batches[first].size
+		for last < len(batches) && batches[last].lastOffset < maxOffset && size+batches[last].size <= int64(maxBytes) {
+			size += batches[last].size
+			last++
+		}
+
+		data := make([]byte, size)
+		if _, err := segment.file.ReadAt(data, batches[first].position); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: failed to read %s: %w", ErrKafkaStorage, l, err)
+		}
+		return data, nil
+	}
+
+	return []byte{}, nil
+}
+
+// rename moves the partition dir of the log, the segments stay open
+func (l *Log) rename(dir string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := os.Rename(l.dir, dir); err != nil {
+		return fmt.Errorf("%w: failed to rename %s to %s: %w", ErrKafkaStorage, l.dir, dir, err)
+	}
+	l.dir = dir
+	l.epochs.rename(dir)
+
+	return nil
+}
+
+// Close flushes the segments to the disk before closing them, a log closed without error is complete after a restart
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var errs []error
+	for _, segment := range l.segments {
+		if err := segment.file.Sync(); err != nil {
+			segment.file.Close()
+			errs = append(errs, fmt.Errorf("%w: failed to flush %s: %w", ErrKafkaStorage, l, err))
+			continue
+		}
+		if err := segment.file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%w: failed to close %s: %w", ErrKafkaStorage, l, err))
+		}
+	}
+	return errors.Join(errs...)
+}
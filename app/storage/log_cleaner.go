@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A segment is compacted into a file with this suffix, which then replaces the segment. One left by a crash is deleted
+// when the log is opened
+const cleanedSegmentSuffix = ".cleaned"
+
+// Compact applies the compact cleanup policy, like the log cleaner of Kafka: a batch of a closed segment is removed
+// once every one of its records has a later record with the same key below highWatermark and below the first unstable
+// transaction. The records that are left keep their offsets, the log has gaps where the removed batches were.
+//
+// Only the batches without producer are removed and only they replace older records, so that the producer states and
+// the transactions do not change. The batches with a null key or compressed records are kept, and so is the last batch
+// of every segment so that the segments still follow each other. Unlike Kafka the tombstones are never removed. It
+// returns the number of batches removed
+func (l *Log) Compact(highWatermark int64) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.config.Compact || len(l.segments) < 2 {
+		return 0, nil
+	}
+	highWatermark = l.producers.firstUnstableOffset(highWatermark)
+
+	// The records of the batches that can replace older ones, by segment and batch, and the offset of the latest record
+	// of every key
+	records := make([][][]Record, len(l.segments))
+	latest := map[string]int64{}
+	for i, segment := range l.segments {
+		records[i] = make([][]Record, len(segment.batches))
+		for j, batch := range segment.batches {
+			if batch.lastOffset >= highWatermark {
+				break
+			}
+			batchRecords, err := readCompactableRecords(segment, batch)
+			if err != nil {
+				return 0, fmt.Errorf("%w: failed to compact %s: %w", ErrKafkaStorage, l, err)
+			}
+			records[i][j] = batchRecords
+			for _, record := range batchRecords {
+				if record.Key != nil {
+					latest[string(record.Key)] = max(latest[string(record.Key)], record.Offset)
+				}
+			}
+		}
+	}
+
+	removed := 0
+	for i, segment := range l.segments[:len(l.segments)-1] {
+		if l.segments[i+1].baseOffset > highWatermark {
+			break
+		}
+
+		kept := make([]batchPosition, 0, len(segment.batches))
+		for j, batch := range segment.batches {
+			if j == len(segment.batches)-1 || !isReplaced(records[i][j], latest) {
+				kept = append(kept, batch)
+			}
+		}
+		if len(kept) == len(segment.batches) {
+			continue
+		}
+
+		count := len(segment.batches) - len(kept)
+		if err := l.rewriteSegment(segment, kept); err != nil {
+			return removed, fmt.Errorf("%w: failed to compact %s: %w", ErrKafkaStorage, l, err)
+		}
+		removed += count
+	}
+
+	return removed, nil
+}
+
+// readCompactableRecords reads the records of a batch that compaction can remove, nil for the other batches
+func readCompactableRecords(segment *logSegment, batch batchPosition) ([]Record, error) {
+	if batch.producerId >= 0 || batch.control {
+		return nil, nil
+	}
+
+	data := make([]byte, batch.size)
+	if _, err := segment.file.ReadAt(data, batch.position); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(data[attributesOffset:])&compressionAttributes != 0 {
+		return nil, nil
+	}
+
+	records, err := parseRecords(data)
+	if err != nil {
+		return nil, nil
+	}
+	return records, nil
+}
+
+// isReplaced tells if a later record replaced every record of a batch, a batch with a null key never is
+func isReplaced(records []Record, latest map[string]int64) bool {
+	if len(records) == 0 {
+		return false
+	}
+	for _, record := range records {
+		if record.Key == nil || latest[string(record.Key)] <= record.Offset {
+			return false
+		}
+	}
+	return true
+}
+
+// rewriteSegment replaces the file of a segment with one holding only the kept batches
+func (l *Log) rewriteSegment(segment *logSegment, kept []batchPosition) error {
+	path := filepath.Join(l.dir, segmentFileName(segment.baseOffset))
+	file, err := os.OpenFile(path+cleanedSegmentSuffix, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	batches := make([]batchPosition, 0, len(kept))
+	position := int64(0)
+	for _, batch := range kept {
+		data := make([]byte, batch.size)
+		if _, err := segment.file.ReadAt(data, batch.position); err != nil {
+			file.Close()
+			os.Remove(path + cleanedSegmentSuffix)
+			return err
+		}
+		if _, err := file.WriteAt(data, position); err != nil {
+			file.Close()
+			os.Remove(path + cleanedSegmentSuffix)
+			return err
+		}
+		batch.position = position
+		batches = append(batches, batch)
+		position += batch.size
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(path + cleanedSegmentSuffix)
+		return err
+	}
+	if err := os.Rename(path+cleanedSegmentSuffix, path); err != nil {
+		file.Close()
+		os.Remove(path + cleanedSegmentSuffix)
+		return err
+	}
+
+	segment.file.Close()
+	l.size -= segment.size - position
+	segment.file = file
+	segment.batches = batches
+	segment.size = position
+	segment.maxTimestamp = -1
+	for _, batch := range batches {
+		segment.maxTimestamp = max(segment.maxTimestamp, batch.maxTimestamp)
+	}
+	return nil
+}
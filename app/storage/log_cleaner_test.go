@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogCompact(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	now := time.UnixMilli(0)
+	config := DefaultLogConfig
+	config.SegmentBytes = 150
+	config.Delete = false
+	log := openTestLog(t, dir, config, &now)
+
+	// Two batches of a record go to every segment
+	for _, record := range []Record{{Key: []byte("a"), Value: []byte("1")}, {Key: []byte("b"), Value: []byte("1")},
+		{Key: []byte("a"), Value: []byte("2")}, {Key: []byte("c"), Value: []byte("1")}, {Key: []byte("a"), Value: []byte("3")}} {
+		if _, err := log.Append(NewRecordBatch(0, []Record{record}), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := segmentBaseOffsets(log), []int64{0, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected segments at %v, got %v", want, got)
+	}
+	batchSize := log.Size() / 5
+
+	tests := []struct {
+		name          string
+		compact       bool
+		highWatermark int64
+		wantRemoved   int
+		wantOffsets   []int64
+	}{
+		{"Delete policy", false, 5, 0, []int64{0, 1, 2, 3, 4}},
+		// The record of a at 2 is above the high watermark, so the segment at 2 stays as it is
+		{"Up to the high watermark", true, 3, 1, []int64{1, 2, 3, 4}},
+		// The last batch of a segment is kept, even when a later record replaced it
+		{"Closed segments", true, 5, 1, []int64{1, 3, 4}},
+		{"Nothing left to compact", true, 5, 0, []int64{1, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig := config
+			testConfig.Compact = tt.compact
+			log.SetConfig(testConfig)
+
+			removed, err := log.Compact(tt.highWatermark)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readTestOffsets(t, log); removed != tt.wantRemoved || !reflect.DeepEqual(got, tt.wantOffsets) {
+				t.Errorf("expected %d batches removed and records at %v, got %d and %v", tt.wantRemoved, tt.wantOffsets, removed, got)
+			}
+			if log.Size() != int64(len(tt.wantOffsets))*batchSize {
+				t.Errorf("expected %d bytes, got %d", int64(len(tt.wantOffsets))*batchSize, log.Size())
+			}
+		})
+	}
+	log.Close()
+
+	// The gaps stay after a restart, and a compaction a crash interrupted is dropped
+	if err := os.WriteFile(filepath.Join(dir, segmentFileName(2)+cleanedSegmentSuffix), []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	log, err := openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if got, want := readTestOffsets(t, log), []int64{1, 3, 4}; !reflect.DeepEqual(got, want) || log.LogEndOffset() != 5 {
+		t.Errorf("expected records at %v and a log end offset of 5 after a restart, got %v and %d", want, got, log.LogEndOffset())
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentFileName(2)+cleanedSegmentSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expected the interrupted compaction to be deleted, got %v", err)
+	}
+}
+
+// readTestOffsets reads the offsets of the records of a log, segment by segment
+func readTestOffsets(t *testing.T, log *Log) []int64 {
+	t.Helper()
+
+	offsets := []int64{}
+	for offset := log.LogStartOffset(); offset < log.LogEndOffset(); {
+		batches, err := log.Read(offset, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := ReadRecords(batches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			offsets = append(offsets, record.Offset)
+		}
+		offset = records[len(records)-1].Offset + 1
+	}
+	return offsets
+}
@@ -0,0 +1,29 @@
+package storage
+
+// LogConfig is the topic configs a log applies, they follow the dynamic configs of the topic without a restart
+type LogConfig struct {
+	// The active segment is rolled once it would grow past SegmentBytes, or once it is SegmentMs old
+	SegmentBytes int64
+	SegmentMs    int64
+	// Delete is the delete cleanup policy: the segments older than RetentionMs, or past the RetentionBytes of the log,
+	// are deleted. -1 is no limit
+	Delete         bool
+	RetentionMs    int64
+	RetentionBytes int64
+	// Compact is the compact cleanup policy: the records that a later record with the same key replaced are removed
+	Compact bool
+	// The largest record batch a leader appends
+	MaxMessageBytes int64
+	// LogAppendTime stamps the batches with the time the leader appended them instead of the time they were created
+	LogAppendTime bool
+}
+
+// DefaultLogConfig is the config of a log before the topic configs are known, with the defaults of the topic configs
+var DefaultLogConfig = LogConfig{
+	SegmentBytes:    1073741824,
+	SegmentMs:       604800000,
+	Delete:          true,
+	RetentionMs:     604800000,
+	RetentionBytes:  -1,
+	MaxMessageBytes: 1048588,
+}
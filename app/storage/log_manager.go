@@ -0,0 +1,902 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The suffixes of the partition dirs that do not hold the current log of their partition, the same ones Kafka uses
+const (
+	futureDirSuffix = "-future"
+	deleteDirSuffix = "-delete"
+)
+
+// MetadataLogDirName is the dir of the metadata log in the first log dir, it is not a partition of the broker
+const MetadataLogDirName = "__cluster_metadata-0"
+
+// Reported for the disk usage of a log dir that could not be read, like Kafka's UNKNOWN_VOLUME_BYTES
+const UNKNOWN_VOLUME_BYTES int64 = -1
+
+var (
+	// ErrLogDirNotFound is returned for a dir that is not one of the log dirs of the broker
+	ErrLogDirNotFound = errors.New("log dir not found")
+	// ErrUnknownLog is returned for a partition without a replica on the broker
+	ErrUnknownLog = errors.New("no log for the partition")
+	// ErrDuplicateLog fails the startup when a partition has a log in several log dirs
+	ErrDuplicateLog = errors.New("partition has a log in several log dirs")
+)
+
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+func (p TopicPartition) String() string {
+	return fmt.Sprintf("%s-%d", p.Topic, p.Partition)
+}
+
+func compareTopicPartitions(a, b TopicPartition) int {
+	return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+}
+
+type logDir struct {
+	path        string
+	directoryId string
+	// The failure that took the dir offline, nil while it is online
+	err error
+}
+
+// LogManager places the logs of the partition replicas across the log dirs. When the disk of a log dir fails, the dir
+// goes offline with the logs it holds, and the broker keeps serving the partitions of the other dirs
+type LogManager struct {
+	nodeId int32
+
+	// Appends and reads hold the read lock, the changes to the logs of a partition the write lock
+	mutex sync.RWMutex
+	dirs  []*logDir
+	logs  map[TopicPartition]*Log
+	// The copies of logs that AlterReplicaLogDirs moves to another dir, they replace the logs once caught up
+	futureLogs map[TopicPartition]*Log
+	// The partitions whose log was in a dir that went offline
+	offlineLogs map[TopicPartition]bool
+	// The dirs asked by AlterReplicaLogDirs for the partitions without a replica yet
+	preferredDirs map[TopicPartition]string
+	watchers      []func(dir string, partitions []TopicPartition)
+	// The configs of the logs of a topic, DefaultLogConfig until UpdateConfigs
+	logConfig func(topic string) LogConfig
+}
+
+// LoadLogManager opens the logs of every log dir. A dir that cannot be read starts offline, the startup only fails when
+// no dir is left. Unless the broker shut down cleanly, every batch is checked and the logs are truncated at the first
+// corrupt one
+func LoadLogManager(logDirs []string, nodeId int32, cleanShutdown bool) (*LogManager, error) {
+	m := &LogManager{
+		nodeId:        nodeId,
+		logs:          make(map[TopicPartition]*Log),
+		futureLogs:    make(map[TopicPartition]*Log),
+		offlineLogs:   make(map[TopicPartition]bool),
+		preferredDirs: make(map[TopicPartition]string),
+		logConfig:     func(string) LogConfig { return DefaultLogConfig },
+	}
+
+	online := 0
+	for _, path := range logDirs {
+		dir := &logDir{path: filepath.Clean(path)}
+		m.dirs = append(m.dirs, dir)
+
+		if err := m.loadDir(dir, cleanShutdown); err != nil {
+			// A dir of another node or a partition in two dirs is a misconfiguration, not a failed disk
+			if errors.Is(err, ErrDuplicateLog) || errors.Is(err, ErrInvalidMetaProperties) {
+				m.Close()
+				return nil, err
+			}
+			dir.err = err
+			continue
+		}
+		online++
+	}
+
+	if online == 0 {
+		m.Close()
+		return nil, fmt.Errorf("%w: every log dir failed: %w", ErrKafkaStorage, m.dirs[0].err)
+	}
+
+	// A future log without a current log was caught up, the broker stopped while it replaced the current log. Unless
+	// the current log is in a dir that failed to load
+	for partition, future := range m.futureLogs {
+		if _, exists := m.logs[partition]; exists {
+			continue
+		}
+		if online < len(m.dirs) {
+			future.Close()
+			delete(m.futureLogs, partition)
+			m.offlineLogs[partition] = true
+			continue
+		}
+		if err := future.rename(filepath.Join(filepath.Dir(future.Dir()), partition.String())); err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.logs[partition] = future
+		delete(m.futureLogs, partition)
+	}
+
+	return m, nil
+}
+
+func (m *LogManager) loadDir(dir *logDir, cleanShutdown bool) error {
+	meta, err := EnsureMetaProperties(dir.path, m.nodeId)
+	if err != nil {
+		return err
+	}
+	dir.directoryId = meta.DirectoryId
+
+	entries, err := os.ReadDir(dir.path)
+	if err != nil {
+		return fmt.Errorf("%w: failed to list %s: %w", ErrKafkaStorage, dir.path, err)
+	}
+
+	logs := map[TopicPartition]*Log{}
+	futureLogs := map[TopicPartition]*Log{}
+	for _, entry := range entries {
+		path := filepath.Join(dir.path, entry.Name())
+		if !entry.IsDir() || entry.Name() == MetadataLogDirName {
+			continue
+		}
+
+		// The deletion of a replaced log was interrupted
+		if strings.HasSuffix(entry.Name(), deleteDirSuffix) {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("%w: failed to delete %s: %w", ErrKafkaStorage, path, err)
+			}
+			continue
+		}
+
+		partition, future, ok := parsePartitionDirName(entry.Name())
+		if !ok {
+			continue
+		}
+		log, err := m.openLog(path, partition, !cleanShutdown)
+		if err != nil {
+			closeLogs(logs, futureLogs)
+			return err
+		}
+		if future {
+			futureLogs[partition] = log
+		} else {
+			logs[partition] = log
+		}
+	}
+
+	for partition, log := range logs {
+		if other, exists := m.logs[partition]; exists {
+			closeLogs(logs, futureLogs)
+			return fmt.Errorf("%w: %s is in %s and %s", ErrDuplicateLog, partition, other.Dir(), log.Dir())
+		}
+	}
+	maps.Copy(m.logs, logs)
+	maps.Copy(m.futureLogs, futureLogs)
+
+	return nil
+}
+
+// openLog opens the log of a partition with the configs of its topic
+func (m *LogManager) openLog(dir string, partition TopicPartition, verifyBatches bool) (*Log, error) {
+	log, err := openLog(dir, partition.Topic, partition.Partition, verifyBatches)
+	if err != nil {
+		return nil, err
+	}
+	log.SetConfig(m.logConfig(partition.Topic))
+	return log, nil
+}
+
+// UpdateConfigs applies the configs of their topic to every log, and to the logs opened from now on. The broker calls
+// it whenever the topic configs change
+func (m *LogManager) UpdateConfigs(logConfig func(topic string) LogConfig) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.logConfig = logConfig
+	for _, logs := range []map[TopicPartition]*Log{m.logs, m.futureLogs} {
+		for partition, log := range logs {
+			log.SetConfig(logConfig(partition.Topic))
+		}
+	}
+}
+
+// parsePartitionDirName reads the partition of a partition dir named topic-partition, or topic-partition.id-future
+// for a future log
+func parsePartitionDirName(name string) (TopicPartition, bool, bool) {
+	base, future := name, false
+	if strings.HasSuffix(name, futureDirSuffix) {
+		dot := strings.LastIndex(name, ".")
+		if dot < 0 {
+			return TopicPartition{}, false, false
+		}
+		base, future = name[:dot], true
+	}
+
+	dash := strings.LastIndex(base, "-")
+	if dash <= 0 {
+		return TopicPartition{}, false, false
+	}
+	index, err := strconv.ParseInt(base[dash+1:], 10, 32)
+	if err != nil || index < 0 {
+		return TopicPartition{}, false, false
+	}
+
+	return TopicPartition{Topic: base[:dash], Partition: int32(index)}, future, true
+}
+
+// futureDirName names the partition dir of a future log, unique so that an abandoned copy never clashes with a new one
+func futureDirName(partition TopicPartition) string {
+	return partition.String() + "." + strings.ReplaceAll(newDirectoryId(), "-", "") + futureDirSuffix
+}
+
+// closeLogs closes every log, it returns the errors of the logs that failed to close
+func closeLogs(logs ...map[TopicPartition]*Log) error {
+	var errs []error
+	for _, byPartition := range logs {
+		for _, log := range byPartition {
+			if err := log.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WatchOfflineDirs registers a listener called with the dir and the partitions it held when a log dir goes offline
+func (m *LogManager) WatchOfflineDirs(listener func(dir string, partitions []TopicPartition)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.watchers = append(m.watchers, listener)
+}
+
+// DirectoryIds returns the directory ids of the online log dirs
+func (m *LogManager) DirectoryIds() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	ids := []string{}
+	for _, dir := range m.dirs {
+		if dir.err == nil {
+			ids = append(ids, dir.directoryId)
+		}
+	}
+	return ids
+}
+
+// OnlineDirs returns the paths of the online log dirs, in the order of log.dirs
+func (m *LogManager) OnlineDirs() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	paths := []string{}
+	for _, dir := range m.dirs {
+		if dir.err == nil {
+			paths = append(paths, dir.path)
+		}
+	}
+	return paths
+}
+
+// dirOf returns the log dir holding a partition dir
+func (m *LogManager) dirOf(log *Log) *logDir {
+	parent := filepath.Dir(log.Dir())
+	for _, dir := range m.dirs {
+		if dir.path == parent {
+			return dir
+		}
+	}
+	return nil
+}
+
+// GetOrCreateLog returns the dir of the log of a partition, creating the log when the partition has none. A new log
+// goes to the dir AlterReplicaLogDirs asked for, otherwise to the online dir with the fewest logs
+func (m *LogManager) GetOrCreateLog(partition TopicPartition) (string, error) {
+	failedDir, dirPath, err := m.getOrCreateLog(partition)
+	if failedDir != nil {
+		m.takeOffline(failedDir, err)
+	}
+	return dirPath, err
+}
+
+func (m *LogManager) getOrCreateLog(partition TopicPartition) (*logDir, string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if log, exists := m.logs[partition]; exists {
+		return nil, log.Dir(), nil
+	}
+	if m.offlineLogs[partition] {
+		return nil, "", fmt.Errorf("%w: the log of %s is in an offline dir", ErrKafkaStorage, partition)
+	}
+
+	counts := map[string]int{}
+	for _, log := range m.logs {
+		counts[filepath.Dir(log.Dir())]++
+	}
+	for _, log := range m.futureLogs {
+		counts[filepath.Dir(log.Dir())]++
+	}
+
+	var target *logDir
+	for _, dir := range m.dirs {
+		if dir.err != nil {
+			continue
+		}
+		if dir.path == m.preferredDirs[partition] {
+			target = dir
+			break
+		}
+		if target == nil || counts[dir.path] < counts[target.path] {
+			target = dir
+		}
+	}
+	if target == nil {
+		return nil, "", fmt.Errorf("%w: every log dir is offline", ErrKafkaStorage)
+	}
+
+	log, err := m.openLog(filepath.Join(target.path, partition.String()), partition, false)
+	if err != nil {
+		return target, "", err
+	}
+	m.logs[partition] = log
+	delete(m.preferredDirs, partition)
+
+	return nil, log.Dir(), nil
+}
+
+// log returns the current log of a partition, the caller holds the read lock
+func (m *LogManager) log(partition TopicPartition) (*Log, error) {
+	if log, exists := m.logs[partition]; exists {
+		return log, nil
+	}
+	if m.offlineLogs[partition] {
+		return nil, fmt.Errorf("%w: the log of %s is in an offline dir", ErrKafkaStorage, partition)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownLog, partition)
+}
+
+// Append writes record batches to the log of a partition as its leader in leaderEpoch
+func (m *LogManager) Append(partition TopicPartition, batches []byte, leaderEpoch int32) (AppendInfo, error) {
+	return m.append(partition, batches, func(log *Log, batches []byte) (AppendInfo, error) {
+		return log.Append(batches, leaderEpoch)
+	})
+}
+
+// AppendAsCoordinator writes the transaction markers of a transaction coordinator to the log of a partition as its
+// leader in leaderEpoch
+func (m *LogManager) AppendAsCoordinator(partition TopicPartition, batches []byte, leaderEpoch int32) (AppendInfo, error) {
+	return m.append(partition, batches, func(log *Log, batches []byte) (AppendInfo, error) {
+		return log.AppendAsCoordinator(batches, leaderEpoch)
+	})
+}
+
+// AppendAsFollower writes record batches fetched from the leader of a partition to its log
+func (m *LogManager) AppendAsFollower(partition TopicPartition, batches []byte) (AppendInfo, error) {
+	return m.append(partition, batches, (*Log).AppendAsFollower)
+}
+
+func (m *LogManager) append(partition TopicPartition, batches []byte, appendTo func(*Log, []byte) (AppendInfo, error)) (AppendInfo, error) {
+	m.mutex.RLock()
+	log, err := m.log(partition)
+	if err != nil {
+		m.mutex.RUnlock()
+		return AppendInfo{}, err
+	}
+	info, err := appendTo(log, batches)
+	m.mutex.RUnlock()
+
+	if errors.Is(err, ErrKafkaStorage) {
+		m.takeLogOffline(log, err)
+	}
+	return info, err
+}
+
+// Read returns the record batches of a partition from offset, at most maxBytes of them but at least one
+func (m *LogManager) Read(partition TopicPartition, offset int64, maxBytes int) ([]byte, error) {
+	return m.ReadUpTo(partition, offset, maxBytes, -1)
+}
+
+// ReadUpTo is Read without the batches past maxOffset, see Log.ReadUpTo
+func (m *LogManager) ReadUpTo(partition TopicPartition, offset int64, maxBytes int, maxOffset int64) ([]byte, error) {
+	m.mutex.RLock()
+	log, err := m.log(partition)
+	if err != nil {
+		m.mutex.RUnlock()
+		return nil, err
+	}
+	data, err := log.ReadUpTo(offset, maxBytes, maxOffset)
+	m.mutex.RUnlock()
+
+	if errors.Is(err, ErrKafkaStorage) {
+		m.takeLogOffline(log, err)
+	}
+	return data, err
+}
+
+// TruncateTo removes the records of a partition from offset, in its future log too, see Log.TruncateTo
+func (m *LogManager) TruncateTo(partition TopicPartition, offset int64) error {
+	m.mutex.RLock()
+	log, err := m.log(partition)
+	if err != nil {
+		m.mutex.RUnlock()
+		return err
+	}
+	failed := log
+	err = log.TruncateTo(offset)
+	if future, ok := m.futureLogs[partition]; ok && err == nil {
+		failed = future
+		err = future.TruncateTo(offset)
+	}
+	m.mutex.RUnlock()
+
+	if errors.Is(err, ErrKafkaStorage) {
+		m.takeLogOffline(failed, err)
+	}
+	return err
+}
+
+// TruncateFullyAndStartAt deletes the records of a partition and restarts its log at offset, its future log too, see
+// Log.TruncateFullyAndStartAt
+func (m *LogManager) TruncateFullyAndStartAt(partition TopicPartition, offset int64) error {
+	m.mutex.RLock()
+	log, err := m.log(partition)
+	if err != nil {
+		m.mutex.RUnlock()
+		return err
+	}
+	failed := log
+	err = log.TruncateFullyAndStartAt(offset)
+	if future, ok := m.futureLogs[partition]; ok && err == nil {
+		failed = future
+		err = future.TruncateFullyAndStartAt(offset)
+	}
+	m.mutex.RUnlock()
+
+	if errors.Is(err, ErrKafkaStorage) {
+		m.takeLogOffline(failed, err)
+	}
+	return err
+}
+
+// DeleteOldSegments applies the retention of its topic to the log of a partition, up to highWatermark, see
+// Log.DeleteOldSegments
+func (m *LogManager) DeleteOldSegments(partition TopicPartition, highWatermark int64) (int, error) {
+	m.mutex.RLock()
+	log, err := m.log(partition)
+	if err != nil {
+		m.mutex.RUnlock()
+		return 0, err
+	}
+	deleted, err := log.DeleteOldSegments(highWatermark)
+	m.mutex.RUnlock()
+
+	if errors.Is(err, ErrKafkaStorage) {
+		m.takeLogOffline(log, err)
+	}
+	return deleted, err
+}
+
+// Compact applies the compact cleanup policy of its topic to the log of a partition, up to highWatermark, see
+// Log.Compact
+func (m *LogManager) Compact(partition TopicPartition, highWatermark int64) (int, error) {
+	m.mutex.RLock()
+	log, err := m.log(partition)
+	if err != nil {
+		m.mutex.RUnlock()
+		return 0, err
+	}
+	removed, err := log.Compact(highWatermark)
+	m.mutex.RUnlock()
+
+	if errors.Is(err, ErrKafkaStorage) {
+		m.takeLogOffline(log, err)
+	}
+	return removed, err
+}
+
+// EndOffsetForEpoch answers OffsetForLeaderEpoch for a partition, see Log.EndOffsetForEpoch
+func (m *LogManager) EndOffsetForEpoch(partition TopicPartition, requestedEpoch int32) (int32, int64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	epoch, endOffset := log.EndOffsetForEpoch(requestedEpoch)
+	return epoch, endOffset, nil
+}
+
+// LeaderEpochs returns the leader epoch cache of the log of a partition
+func (m *LogManager) LeaderEpochs(partition TopicPartition) (*LeaderEpochCache, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return nil, err
+	}
+	return log.LeaderEpochs(), nil
+}
+
+// LogStartOffset returns the offset of the first record a partition still has
+func (m *LogManager) LogStartOffset(partition TopicPartition) (int64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return 0, err
+	}
+	return log.LogStartOffset(), nil
+}
+
+// LogEndOffset returns the offset of the next record appended to a partition
+func (m *LogManager) LogEndOffset(partition TopicPartition) (int64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return 0, err
+	}
+	return log.LogEndOffset(), nil
+}
+
+// LastStableOffset returns the last stable offset of a partition whose high watermark is highWatermark, see
+// Log.LastStableOffset
+func (m *LogManager) LastStableOffset(partition TopicPartition, highWatermark int64) (int64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return 0, err
+	}
+	return log.LastStableOffset(highWatermark), nil
+}
+
+// AbortedTransactions returns the aborted transactions of a partition between two offsets, see Log.AbortedTransactions
+func (m *LogManager) AbortedTransactions(partition TopicPartition, startOffset int64, endOffset int64) ([]AbortedTransaction, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return nil, err
+	}
+	return log.AbortedTransactions(startOffset, endOffset), nil
+}
+
+// InTransaction tells whether a producer has a transaction ongoing in a partition, see Log.InTransaction
+func (m *LogManager) InTransaction(partition TopicPartition, producerId int64, producerEpoch int16) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return false, err
+	}
+	return log.InTransaction(producerId, producerEpoch), nil
+}
+
+// ExpireProducers forgets the idle producers of a partition, see Log.ExpireProducers
+func (m *LogManager) ExpireProducers(partition TopicPartition, expiration time.Duration) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return 0, err
+	}
+	return log.ExpireProducers(expiration), nil
+}
+
+// ActiveProducers returns the producers of a partition, see Log.ActiveProducers
+func (m *LogManager) ActiveProducers(partition TopicPartition) ([]ProducerState, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	log, err := m.log(partition)
+	if err != nil {
+		return nil, err
+	}
+	return log.ActiveProducers(), nil
+}
+
+// takeLogOffline takes the log dir of a log that failed offline, unless the log was already replaced
+func (m *LogManager) takeLogOffline(log *Log, err error) {
+	m.mutex.RLock()
+	dir := m.dirOf(log)
+	m.mutex.RUnlock()
+
+	if dir != nil {
+		m.takeOffline(dir, err)
+	}
+}
+
+// takeOffline closes the logs of a failed log dir and tells the watchers which partitions went offline with it
+func (m *LogManager) takeOffline(dir *logDir, err error) {
+	m.mutex.Lock()
+	if dir.err != nil {
+		m.mutex.Unlock()
+		return
+	}
+	dir.err = err
+
+	partitions := []TopicPartition{}
+	for partition, log := range m.logs {
+		if filepath.Dir(log.Dir()) == dir.path {
+			log.Close()
+			delete(m.logs, partition)
+			m.offlineLogs[partition] = true
+			partitions = append(partitions, partition)
+		}
+	}
+	// A copy in progress is abandoned, the current log of its partition stays where it is
+	for partition, log := range m.futureLogs {
+		if filepath.Dir(log.Dir()) == dir.path {
+			log.Close()
+			delete(m.futureLogs, partition)
+		}
+	}
+	slices.SortFunc(partitions, compareTopicPartitions)
+	watchers := slices.Clone(m.watchers)
+	m.mutex.Unlock()
+
+	for _, watcher := range watchers {
+		watcher(dir.path, partitions)
+	}
+}
+
+// AlterReplicaLogDir moves the log of a partition to another log dir. The log is copied to a future log in that dir,
+// which replaces it once caught up. For a partition without a replica yet, the dir is where its log will be created
+func (m *LogManager) AlterReplicaLogDir(partition TopicPartition, path string) error {
+	failedDir, err := m.alterReplicaLogDir(partition, path)
+	if failedDir != nil {
+		m.takeOffline(failedDir, err)
+	}
+	return err
+}
+
+func (m *LogManager) alterReplicaLogDir(partition TopicPartition, path string) (*logDir, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	index := slices.IndexFunc(m.dirs, func(dir *logDir) bool { return dir.path == filepath.Clean(path) })
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLogDirNotFound, path)
+	}
+	target := m.dirs[index]
+	if target.err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrKafkaStorage, target.path, target.err)
+	}
+
+	log, err := m.log(partition)
+	if errors.Is(err, ErrUnknownLog) {
+		m.preferredDirs[partition] = target.path
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A move to another dir replaces the one in progress, a move back to the current dir cancels it
+	if future, exists := m.futureLogs[partition]; exists {
+		if filepath.Dir(future.Dir()) == target.path {
+			return nil, nil
+		}
+		m.removeFutureLog(partition, future)
+	}
+	if filepath.Dir(log.Dir()) == target.path {
+		return nil, nil
+	}
+
+	future, err := m.openLog(filepath.Join(target.path, futureDirName(partition)), partition, false)
+	if err != nil {
+		return target, err
+	}
+	m.futureLogs[partition] = future
+
+	return nil, nil
+}
+
+// removeFutureLog deletes an abandoned copy, a dir that cannot be deleted is cleaned up at the next startup
+func (m *LogManager) removeFutureLog(partition TopicPartition, future *Log) {
+	future.Close()
+	delete(m.futureLogs, partition)
+
+	dir := future.Dir()
+	deleted := strings.TrimSuffix(dir, futureDirSuffix) + deleteDirSuffix
+	if os.Rename(dir, deleted) == nil {
+		os.RemoveAll(deleted)
+	}
+}
+
+// CopyFutureLogs copies up to maxBytes of records from every log being moved to its future log, and replaces the logs
+// whose future log caught up. It returns the partitions that moved
+func (m *LogManager) CopyFutureLogs(maxBytes int) []TopicPartition {
+	m.mutex.RLock()
+	partitions := slices.SortedFunc(maps.Keys(m.futureLogs), compareTopicPartitions)
+	m.mutex.RUnlock()
+
+	moved := []TopicPartition{}
+	for _, partition := range partitions {
+		m.mutex.RLock()
+		log, future := m.logs[partition], m.futureLogs[partition]
+		if log == nil || future == nil {
+			m.mutex.RUnlock()
+			continue
+		}
+
+		// The copy starts where the current log starts, the records the retention deleted are skipped
+		failed := future
+		var err error
+		if future.LogEndOffset() < log.LogStartOffset() {
+			err = future.TruncateFullyAndStartAt(log.LogStartOffset())
+		}
+		var data []byte
+		if err == nil {
+			failed = log
+			data, err = log.Read(future.LogEndOffset(), maxBytes)
+		}
+		if err == nil {
+			failed = future
+			_, err = future.AppendAsFollower(data)
+		}
+		m.mutex.RUnlock()
+
+		if err != nil {
+			if errors.Is(err, ErrKafkaStorage) {
+				m.takeLogOffline(failed, err)
+			}
+			continue
+		}
+
+		failedDir, err := m.maybeReplaceWithFutureLog(partition, log, future)
+		if failedDir != nil {
+			m.takeOffline(failedDir, err)
+		}
+		if err == nil && m.isCurrentLog(partition, future) {
+			moved = append(moved, partition)
+		}
+	}
+
+	return moved
+}
+
+func (m *LogManager) isCurrentLog(partition TopicPartition, log *Log) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.logs[partition] == log
+}
+
+// maybeReplaceWithFutureLog makes a caught up future log the log of its partition. The write lock stops the appends,
+// so that no record is appended to the current log after the future log caught up
+func (m *LogManager) maybeReplaceWithFutureLog(partition TopicPartition, log *Log, future *Log) (*logDir, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.logs[partition] != log || m.futureLogs[partition] != future || future.LogEndOffset() < log.LogEndOffset() {
+		return nil, nil
+	}
+
+	// The current log is renamed first: after a crash, a future log without a current log is known to be caught up
+	current := log.Dir()
+	deleted := filepath.Join(filepath.Dir(current), strings.TrimSuffix(filepath.Base(future.Dir()), futureDirSuffix)+deleteDirSuffix)
+	if err := log.rename(deleted); err != nil {
+		return m.dirOf(log), err
+	}
+	if err := future.rename(filepath.Join(filepath.Dir(future.Dir()), partition.String())); err != nil {
+		return m.dirOf(future), err
+	}
+
+	m.logs[partition] = future
+	delete(m.futureLogs, partition)
+	log.Close()
+	os.RemoveAll(deleted)
+
+	return nil, nil
+}
+
+// ReplicaDescription is the log of a partition in a log dir
+type ReplicaDescription struct {
+	TopicPartition
+	Size int64
+	// How many records the future log is behind the current log, 0 for a current log
+	OffsetLag int64
+	IsFuture  bool
+}
+
+// LogDirDescription is a log dir with the size of its disk and its logs
+type LogDirDescription struct {
+	Path string
+	// The failure that took the dir offline, nil while it is online
+	Err         error
+	TotalBytes  int64
+	UsableBytes int64
+	Replicas    []ReplicaDescription
+}
+
+// DescribeLogDirs returns every log dir with its logs, in the order of log.dirs and of the partitions
+func (m *LogManager) DescribeLogDirs() []LogDirDescription {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	descriptions := make([]LogDirDescription, 0, len(m.dirs))
+	for _, dir := range m.dirs {
+		description := LogDirDescription{
+			Path:        dir.path,
+			Err:         dir.err,
+			TotalBytes:  UNKNOWN_VOLUME_BYTES,
+			UsableBytes: UNKNOWN_VOLUME_BYTES,
+			Replicas:    []ReplicaDescription{},
+		}
+		if dir.err != nil {
+			descriptions = append(descriptions, description)
+			continue
+		}
+
+		if total, usable, err := diskUsage(dir.path); err == nil {
+			description.TotalBytes, description.UsableBytes = total, usable
+		}
+
+		for partition, log := range m.logs {
+			if filepath.Dir(log.Dir()) == dir.path {
+				description.Replicas = append(description.Replicas, ReplicaDescription{TopicPartition: partition, Size: log.Size()})
+			}
+		}
+		for partition, future := range m.futureLogs {
+			if filepath.Dir(future.Dir()) == dir.path {
+				description.Replicas = append(description.Replicas, ReplicaDescription{
+					TopicPartition: partition,
+					Size:           future.Size(),
+					OffsetLag:      max(m.logs[partition].LogEndOffset()-future.LogEndOffset(), 0),
+					IsFuture:       true,
+				})
+			}
+		}
+		slices.SortFunc(description.Replicas, func(a, b ReplicaDescription) int {
+			return compareTopicPartitions(a.TopicPartition, b.TopicPartition)
+		})
+
+		descriptions = append(descriptions, description)
+	}
+
+	return descriptions
+}
+
+// LogSizes returns the size of the log of every partition, without the future logs of AlterReplicaLogDirs
+func (m *LogManager) LogSizes() map[TopicPartition]int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	sizes := make(map[TopicPartition]int64, len(m.logs))
+	for partition, log := range m.logs {
+		sizes[partition] = log.Size()
+	}
+	return sizes
+}
+
+// Close flushes and closes every log, it must only be called once the broker stopped using them. An error means that
+// some logs may be incomplete after a restart
+func (m *LogManager) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return closeLogs(m.logs, m.futureLogs)
+}
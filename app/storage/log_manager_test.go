@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLogManagerPlacement(t *testing.T) {
+	dirs := []string{filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")}
+	manager, err := LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	// A new log goes to the dir with the fewest logs, the first one of log.dirs on a tie
+	for _, tt := range []struct {
+		partition TopicPartition
+		wantDir   string
+	}{
+		{TopicPartition{"foo", 0}, dirs[0]},
+		{TopicPartition{"foo", 1}, dirs[1]},
+		{TopicPartition{"bar", 0}, dirs[0]},
+		{TopicPartition{"foo", 0}, dirs[0]},
+	} {
+		dir, err := manager.GetOrCreateLog(tt.partition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(tt.wantDir, tt.partition.String()); dir != want {
+			t.Errorf("%s: expected %s, got %s", tt.partition, want, dir)
+		}
+	}
+
+	// AlterReplicaLogDirs picks the dir of a partition before its log exists
+	baz := TopicPartition{"baz", 0}
+	if err := manager.AlterReplicaLogDir(baz, dirs[0]); !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("expected ErrUnknownLog, got %v", err)
+	}
+	if dir, err := manager.GetOrCreateLog(baz); err != nil || dir != filepath.Join(dirs[0], "baz-0") {
+		t.Errorf("expected baz-0 in %s, got %s, %v", dirs[0], dir, err)
+	}
+
+	if err := manager.AlterReplicaLogDir(baz, filepath.Join(t.TempDir(), "c")); !errors.Is(err, ErrLogDirNotFound) {
+		t.Errorf("expected ErrLogDirNotFound, got %v", err)
+	}
+	if ids := manager.DirectoryIds(); len(ids) != 2 || ids[0] == ids[1] {
+		t.Errorf("expected a directory id per log dir, got %v", ids)
+	}
+
+	// The logs are found in their dirs after a restart, the metadata log is not one of them
+	manager.Append(TopicPartition{"foo", 1}, newTestBatch(0, 4, "a"), 0)
+	manager.Close()
+	if err := os.Mkdir(filepath.Join(dirs[0], MetadataLogDirName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manager, err = LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, err := manager.LogEndOffset(TopicPartition{"foo", 1}); err != nil || offset != 4 {
+		t.Errorf("expected foo-1 to end at 4, got %d, %v", offset, err)
+	}
+	if _, err := manager.LogEndOffset(TopicPartition{"__cluster_metadata", 0}); !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("expected the metadata log to be left out, got %v", err)
+	}
+	if _, err := LoadLogManager(dirs, 2, false); !errors.Is(err, ErrInvalidMetaProperties) {
+		t.Errorf("expected ErrInvalidMetaProperties for the dirs of another node, got %v", err)
+	}
+}
+
+func TestLogManagerOfflineDir(t *testing.T) {
+	dirs := []string{filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")}
+	manager, err := LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	foo, bar := TopicPartition{"foo", 0}, TopicPartition{"bar", 0}
+	manager.GetOrCreateLog(foo)
+	manager.GetOrCreateLog(bar)
+
+	offline := map[string][]TopicPartition{}
+	manager.WatchOfflineDirs(func(dir string, partitions []TopicPartition) {
+		offline[dir] = partitions
+	})
+
+	// The disk of the first dir fails under foo-0
+	manager.logs[foo].segments[0].file.Close()
+	if _, err := manager.Append(foo, newTestBatch(0, 1, "a"), 0); !errors.Is(err, ErrKafkaStorage) {
+		t.Fatalf("expected ErrKafkaStorage, got %v", err)
+	}
+	if want := map[string][]TopicPartition{dirs[0]: {foo}}; !reflect.DeepEqual(offline, want) {
+		t.Errorf("got %v, want %v", offline, want)
+	}
+
+	// The partitions of the failed dir stay offline, the others are still served
+	if _, err := manager.Read(foo, 0, 100); !errors.Is(err, ErrKafkaStorage) {
+		t.Errorf("expected ErrKafkaStorage, got %v", err)
+	}
+	if _, err := manager.GetOrCreateLog(foo); !errors.Is(err, ErrKafkaStorage) {
+		t.Errorf("expected ErrKafkaStorage, got %v", err)
+	}
+	if _, err := manager.Append(bar, newTestBatch(0, 1, "a"), 0); err != nil {
+		t.Errorf("expected bar-0 to be served, got %v", err)
+	}
+	if err := manager.AlterReplicaLogDir(bar, dirs[0]); !errors.Is(err, ErrKafkaStorage) {
+		t.Errorf("expected ErrKafkaStorage for a move to the offline dir, got %v", err)
+	}
+	if dir, err := manager.GetOrCreateLog(TopicPartition{"baz", 0}); err != nil || filepath.Dir(dir) != dirs[1] {
+		t.Errorf("expected new logs in the online dir, got %s, %v", dir, err)
+	}
+	if got := manager.OnlineDirs(); !reflect.DeepEqual(got, dirs[1:]) {
+		t.Errorf("expected %v online, got %v", dirs[1:], got)
+	}
+
+	descriptions := manager.DescribeLogDirs()
+	if descriptions[0].Err == nil || descriptions[0].TotalBytes != UNKNOWN_VOLUME_BYTES || len(descriptions[0].Replicas) != 0 {
+		t.Errorf("expected the first dir to be described as offline, got %+v", descriptions[0])
+	}
+}
+
+func TestLoadLogManagerWithFailedDir(t *testing.T) {
+	// A file where a log dir should be cannot be used as a dir
+	failed := filepath.Join(t.TempDir(), "a")
+	if err := os.WriteFile(failed, []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	online := filepath.Join(t.TempDir(), "b")
+
+	manager, err := LoadLogManager([]string{failed, online}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	if got := manager.OnlineDirs(); !reflect.DeepEqual(got, []string{online}) {
+		t.Errorf("expected %v online, got %v", []string{online}, got)
+	}
+
+	if _, err := LoadLogManager([]string{failed}, 1, false); err == nil {
+		t.Errorf("expected an error without an online dir")
+	}
+}
+
+func TestAlterReplicaLogDir(t *testing.T) {
+	dirs := []string{filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")}
+	manager, err := LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	foo := TopicPartition{"foo", 0}
+	manager.GetOrCreateLog(foo)
+	for range 3 {
+		manager.Append(foo, newTestBatch(0, 2, "abc"), 0)
+	}
+
+	if err := manager.AlterReplicaLogDir(foo, dirs[1]); err != nil {
+		t.Fatal(err)
+	}
+	want := []ReplicaDescription{{TopicPartition: foo, Size: 0, OffsetLag: 6, IsFuture: true}}
+	if got := manager.DescribeLogDirs()[1].Replicas; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// The future log copies a batch at a time, and replaces the log once caught up
+	if moved := manager.CopyFutureLogs(64); len(moved) != 0 {
+		t.Errorf("expected the future log to be behind, got %v moved", moved)
+	}
+	want = []ReplicaDescription{{TopicPartition: foo, Size: 64, OffsetLag: 4, IsFuture: true}}
+	if got := manager.DescribeLogDirs()[1].Replicas; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	manager.Append(foo, newTestBatch(0, 1, "abc"), 0)
+
+	if moved := manager.CopyFutureLogs(1024); !reflect.DeepEqual(moved, []TopicPartition{foo}) {
+		t.Errorf("expected foo-0 to move, got %v", moved)
+	}
+	descriptions := manager.DescribeLogDirs()
+	if len(descriptions[0].Replicas) != 0 {
+		t.Errorf("expected no log left in %s, got %+v", dirs[0], descriptions[0].Replicas)
+	}
+	want = []ReplicaDescription{{TopicPartition: foo, Size: 256, OffsetLag: 0, IsFuture: false}}
+	if !reflect.DeepEqual(descriptions[1].Replicas, want) {
+		t.Errorf("got %+v, want %+v", descriptions[1].Replicas, want)
+	}
+	if descriptions[1].TotalBytes <= 0 || descriptions[1].UsableBytes < 0 {
+		t.Errorf("expected the disk usage of %s, got %+v", dirs[1], descriptions[1])
+	}
+	if entries, _ := os.ReadDir(dirs[0]); len(entries) != 1 {
+		t.Errorf("expected only %s left in %s, got %v", MetaPropertiesFile, dirs[0], entries)
+	}
+
+	// A move back to the current dir cancels the one in progress
+	if err := manager.AlterReplicaLogDir(foo, dirs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.AlterReplicaLogDir(foo, dirs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if moved := manager.CopyFutureLogs(1024); len(moved) != 0 {
+		t.Errorf("expected no move, got %v", moved)
+	}
+	if offset, err := manager.LogEndOffset(foo); err != nil || offset != 7 {
+		t.Errorf("expected foo-0 to end at 7, got %d, %v", offset, err)
+	}
+}
+
+func TestLoadLogManagerCompletesInterruptedMove(t *testing.T) {
+	dirs := []string{filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")}
+	manager, err := LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo := TopicPartition{"foo", 0}
+	manager.GetOrCreateLog(foo)
+	manager.Append(foo, newTestBatch(0, 2, "abc"), 0)
+	manager.AlterReplicaLogDir(foo, dirs[1])
+	manager.futureLogs[foo].AppendAsFollower(newTestBatch(0, 2, "abc"))
+	manager.Close()
+
+	// The broker stopped right after the current log was renamed for deletion
+	if err := os.Rename(filepath.Join(dirs[0], "foo-0"), filepath.Join(dirs[0], "foo-0.1"+deleteDirSuffix)); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err = LoadLogManager(dirs, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	descriptions := manager.DescribeLogDirs()
+	want := []ReplicaDescription{{TopicPartition: foo, Size: 64}}
+	if len(descriptions[0].Replicas) != 0 || !reflect.DeepEqual(descriptions[1].Replicas, want) {
+		t.Errorf("expected foo-0 in %s only, got %+v", dirs[1], descriptions)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[1], "foo-0")); err != nil {
+		t.Errorf("expected the future log to be renamed, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dirs[0]); len(entries) != 1 {
+		t.Errorf("expected the deleted log to be removed, got %v", entries)
+	}
+}
+
+func TestLogManagerCloseReportsUnflushedLogs(t *testing.T) {
+	manager, err := LoadLogManager([]string{filepath.Join(t.TempDir(), "a")}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo, bar := TopicPartition{"foo", 0}, TopicPartition{"bar", 0}
+	manager.GetOrCreateLog(foo)
+	manager.GetOrCreateLog(bar)
+
+	// The segment of foo-0 can no longer be flushed
+	manager.logs[foo].segments[0].file.Close()
+	if err := manager.Close(); !errors.Is(err, ErrKafkaStorage) {
+		t.Errorf("expected ErrKafkaStorage, got %v", err)
+	}
+	if err := manager.logs[bar].segments[0].file.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected the other logs to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,449 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestBatch returns a record batch of records records, with a payload instead of the records themselves
+func newTestBatch(baseOffset int64, records int32, payload string) []byte {
+	batch := make([]byte, batchHeaderSize+len(payload))
+	binary.BigEndian.PutUint64(batch, uint64(baseOffset))
+	binary.BigEndian.PutUint32(batch[batchLengthOffset:], uint32(len(batch)-batchLengthOffset-4))
+	batch[16] = 2 // Magic
+	binary.BigEndian.PutUint32(batch[lastOffsetDeltaOffset:], uint32(records-1))
+	copy(batch[producerIdOffset:recordsCountOffset], bytes.Repeat([]byte{0xff}, recordsCountOffset-producerIdOffset)) // No producer
+	binary.BigEndian.PutUint32(batch[recordsCountOffset:], uint32(records))
+	copy(batch[batchHeaderSize:], payload)
+	binary.BigEndian.PutUint32(batch[crcOffset:], crc32.Checksum(batch[attributesOffset:], crcTable))
+	return batch
+}
+
+func TestLogAppendAndRead(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	// The leader assigns the offsets, whatever the base offset of the batches
+	for i, want := range []AppendInfo{{0, 2, -1}, {3, 3, -1}, {4, 5, -1}} {
+		info, err := log.Append(newTestBatch(42, []int32{3, 1, 2}[i], "abc"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info != want {
+			t.Errorf("batch %d: expected offsets %v, got %v", i, want, info)
+		}
+	}
+	if log.LogEndOffset() != 6 || log.Size() != 3*64 {
+		t.Errorf("expected a log end offset of 6 and 192 bytes, got %d and %d", log.LogEndOffset(), log.Size())
+	}
+
+	tests := []struct {
+		name      string
+		offset    int64
+		maxBytes  int
+		maxOffset int64
+		want      []byte
+	}{
+		{"First batch", 0, 64, -1, newTestBatch(0, 3, "abc")},
+		{"Offset inside a batch", 2, 64, -1, newTestBatch(0, 3, "abc")},
+		{"Several batches", 3, 128, -1, append(newTestBatch(3, 1, "abc"), newTestBatch(4, 2, "abc")...)},
+		{"At least one batch", 4, 1, -1, newTestBatch(4, 2, "abc")},
+		{"Log end offset", 6, 64, -1, []byte{}},
+		{"Up to the max offset", 0, 192, 4, append(newTestBatch(0, 3, "abc"), newTestBatch(3, 1, "abc")...)},
+		{"Batch past the max offset", 4, 64, 4, []byte{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := log.ReadUpTo(tt.offset, tt.maxBytes, tt.maxOffset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := log.Read(7, 64); !errors.Is(err, ErrOffsetOutOfRange) {
+		t.Errorf("expected ErrOffsetOutOfRange, got %v", err)
+	}
+}
+
+func TestLogAppendAsFollower(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if _, err := log.AppendAsFollower(append(newTestBatch(0, 2, "a"), newTestBatch(2, 1, "b")...)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		batches []byte
+	}{
+		{"Overlap", newTestBatch(2, 1, "c")},
+		{"Incomplete batch", newTestBatch(3, 1, "c")[:50]},
+		{"Trailing bytes", append(newTestBatch(3, 1, "c"), 0x00)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := log.AppendAsFollower(tt.batches); !errors.Is(err, ErrInvalidRecordBatch) {
+				t.Errorf("expected ErrInvalidRecordBatch, got %v", err)
+			}
+			if log.LogEndOffset() != 3 {
+				t.Errorf("a rejected append must leave the log as it was, got a log end offset of %d", log.LogEndOffset())
+			}
+		})
+	}
+
+	// The batches of a compacted log have gaps
+	if info, err := log.AppendAsFollower(newTestBatch(4, 1, "c")); err != nil || info.FirstOffset != 3 || log.LogEndOffset() != 5 {
+		t.Errorf("expected the batch after the gap to be appended, got %v, %v and a log end offset of %d", info, err, log.LogEndOffset())
+	}
+}
+
+func TestLogRecovery(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	log, err := openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Append(newTestBatch(0, 2, "a"), 0)
+	log.Append(newTestBatch(0, 3, "b"), 0)
+	log.Close()
+
+	// A crash left half of a batch at the end of the segment
+	file, err := os.OpenFile(filepath.Join(dir, segmentFileName(0)), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(newTestBatch(5, 1, "c")[:40])
+	file.Close()
+
+	log, err = openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if log.LogEndOffset() != 5 || log.Size() != 124 {
+		t.Errorf("expected a log end offset of 5 and 124 bytes, got %d and %d", log.LogEndOffset(), log.Size())
+	}
+	if info, err := log.Append(newTestBatch(0, 1, "c"), 0); err != nil || info.FirstOffset != 5 {
+		t.Errorf("expected the next batch at offset 5, got %d, %v", info.FirstOffset, err)
+	}
+}
+
+func TestLogRecoveryChecksBatchesAfterACrash(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	log, err := openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Append(newTestBatch(0, 2, "a"), 0)
+	log.Append(newTestBatch(0, 3, "b"), 0)
+	log.Close()
+
+	// The records of the second batch were not all written to the disk
+	file, err := os.OpenFile(filepath.Join(dir, segmentFileName(0)), os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteAt([]byte{'x'}, 62+batchHeaderSize)
+	file.Close()
+
+	// The log of a clean shutdown is trusted
+	log, err = openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.LogEndOffset() != 5 {
+		t.Errorf("expected a log end offset of 5 after a clean shutdown, got %d", log.LogEndOffset())
+	}
+	log.Close()
+
+	log, err = openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if log.LogEndOffset() != 2 || log.Size() != 62 {
+		t.Errorf("expected the corrupt batch to be truncated, got a log end offset of %d and %d bytes", log.LogEndOffset(), log.Size())
+	}
+}
+
+func TestLogLeaderEpochs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	log, err := openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The leaders stamp their epoch on the batches they append, the followers keep it
+	log.Append(newTestBatch(0, 2, "a"), 1)
+	log.Append(newTestBatch(0, 1, "b"), 1)
+	follower := newTestBatch(3, 2, "c")
+	binary.BigEndian.PutUint32(follower[leaderEpochOffset:], 3)
+	if _, err := log.AppendAsFollower(follower); err != nil {
+		t.Fatal(err)
+	}
+	log.Append(newTestBatch(0, 1, "d"), 4)
+
+	if got, want := log.LeaderEpochs().Entries(), []EpochEntry{{1, 0}, {3, 3}, {4, 5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the epochs %v, got %v", want, got)
+	}
+	if epoch, endOffset := log.EndOffsetForEpoch(2); epoch != 1 || endOffset != 3 {
+		t.Errorf("expected epoch 2 to end with epoch 1 at 3, got epoch %d at %d", epoch, endOffset)
+	}
+
+	// Truncating inside a batch removes the whole batch, and the epochs that started in it
+	if err := log.TruncateTo(4); err != nil {
+		t.Fatal(err)
+	}
+	if log.LogEndOffset() != 3 || log.Size() != 124 || log.LatestEpoch() != 1 {
+		t.Errorf("expected a log end offset of 3, 124 bytes and epoch 1, got %d, %d and %d", log.LogEndOffset(), log.Size(), log.LatestEpoch())
+	}
+	if info, err := log.Append(newTestBatch(0, 1, "e"), 5); err != nil || info.FirstOffset != 3 {
+		t.Errorf("expected the next batch at offset 3, got %d, %v", info.FirstOffset, err)
+	}
+	log.Close()
+
+	// The epochs are checkpointed in the partition dir
+	log, err = openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if got, want := log.LeaderEpochs().Entries(), []EpochEntry{{1, 0}, {5, 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the epochs %v after a restart, got %v", want, got)
+	}
+}
+
+// openTestLog opens a log with a config and a clock set by the test
+func openTestLog(t *testing.T, dir string, config LogConfig, now *time.Time) *Log {
+	t.Helper()
+
+	log, err := openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.now = func() time.Time { return *now }
+	log.SetConfig(config)
+	return log
+}
+
+func segmentBaseOffsets(log *Log) []int64 {
+	baseOffsets := []int64{}
+	for _, segment := range log.segments {
+		baseOffsets = append(baseOffsets, segment.baseOffset)
+	}
+	return baseOffsets
+}
+
+func TestLogRollsSegments(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	now := time.UnixMilli(0)
+	config := DefaultLogConfig
+	config.SegmentBytes = 128
+	config.SegmentMs = 1000
+	log := openTestLog(t, dir, config, &now)
+
+	// The third batch would grow the segment past segment.bytes, the fourth comes after segment.ms
+	log.Append(newTestBatch(0, 3, "abc"), 0)
+	log.Append(newTestBatch(0, 1, "abc"), 0)
+	log.Append(newTestBatch(0, 2, "abc"), 0)
+	now = now.Add(time.Second)
+	log.Append(newTestBatch(0, 1, "abc"), 0)
+
+	if got, want := segmentBaseOffsets(log), []int64{0, 4, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected segments at %v, got %v", want, got)
+	}
+	if log.Size() != 4*64 {
+		t.Errorf("expected 256 bytes, got %d", log.Size())
+	}
+
+	// A read stops at the end of a segment
+	if got, err := log.Read(0, 1024); err != nil || !bytes.Equal(got, append(newTestBatch(0, 3, "abc"), newTestBatch(3, 1, "abc")...)) {
+		t.Errorf("expected the batches of the first segment, got %v, %v", got, err)
+	}
+	if got, err := log.Read(4, 1024); err != nil || !bytes.Equal(got, newTestBatch(4, 2, "abc")) {
+		t.Errorf("expected the batch of the second segment, got %v, %v", got, err)
+	}
+
+	// Truncating in the second segment deletes the segments after it
+	if err := log.TruncateTo(5); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := segmentBaseOffsets(log), []int64{0, 4}; !reflect.DeepEqual(got, want) || log.LogEndOffset() != 4 || log.Size() != 128 {
+		t.Errorf("expected segments at %v, a log end offset of 4 and 128 bytes, got %v, %d and %d", want, got, log.LogEndOffset(), log.Size())
+	}
+	log.Append(newTestBatch(0, 2, "abc"), 0)
+	log.Close()
+
+	log, err := openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if got, want := segmentBaseOffsets(log), []int64{0, 4}; !reflect.DeepEqual(got, want) || log.LogEndOffset() != 6 || log.Size() != 192 {
+		t.Errorf("expected segments at %v, a log end offset of 6 and 192 bytes after a restart, got %v, %d and %d", want, got, log.LogEndOffset(), log.Size())
+	}
+}
+
+func TestLogDeleteOldSegments(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	now := time.UnixMilli(1000)
+	config := DefaultLogConfig
+	config.SegmentBytes = 64
+	config.RetentionMs = 2000
+	log := openTestLog(t, dir, config, &now)
+
+	// Every batch has its own segment, with the timestamp 0
+	log.Append(newTestBatch(0, 3, "abc"), 0)
+	log.Append(newTestBatch(0, 1, "abc"), 0)
+	log.Append(newTestBatch(0, 2, "abc"), 0)
+
+	tests := []struct {
+		name               string
+		now                int64
+		config             func(*LogConfig)
+		highWatermark      int64
+		wantDeleted        int
+		wantSegments       []int64
+		wantLogStartOffset int64
+	}{
+		{"Not expired", 1000, func(*LogConfig) {}, 6, 0, []int64{0, 3, 4}, 0},
+		{"Compact policy", 3000, func(c *LogConfig) { c.Delete = false }, 6, 0, []int64{0, 3, 4}, 0},
+		{"Up to the high watermark", 3000, func(*LogConfig) {}, 3, 1, []int64{3, 4}, 3},
+		{"Retention bytes", 3000, func(c *LogConfig) { c.RetentionMs, c.RetentionBytes = -1, 64 }, 6, 1, []int64{4}, 4},
+		{"Expired active segment", 3000, func(*LogConfig) {}, 6, 1, []int64{6}, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.UnixMilli(tt.now)
+			testConfig := config
+			tt.config(&testConfig)
+			log.SetConfig(testConfig)
+
+			deleted, err := log.DeleteOldSegments(tt.highWatermark)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != tt.wantDeleted || !reflect.DeepEqual(segmentBaseOffsets(log), tt.wantSegments) || log.LogStartOffset() != tt.wantLogStartOffset {
+				t.Errorf("expected %d deleted, segments at %v and a log start offset of %d, got %d, %v and %d",
+					tt.wantDeleted, tt.wantSegments, tt.wantLogStartOffset, deleted, segmentBaseOffsets(log), log.LogStartOffset())
+			}
+		})
+	}
+
+	if _, err := log.Read(4, 64); !errors.Is(err, ErrOffsetOutOfRange) {
+		t.Errorf("expected ErrOffsetOutOfRange before the log start offset, got %v", err)
+	}
+	if log.LogEndOffset() != 6 || log.Size() != 0 {
+		t.Errorf("expected a log end offset of 6 and no bytes, got %d and %d", log.LogEndOffset(), log.Size())
+	}
+	log.Close()
+
+	log, err := openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if log.LogStartOffset() != 6 || log.LogEndOffset() != 6 {
+		t.Errorf("expected the log to start and end at 6 after a restart, got %d and %d", log.LogStartOffset(), log.LogEndOffset())
+	}
+}
+
+func TestLogAppendAppliesTheTopicConfigs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	now := time.UnixMilli(5000)
+	config := DefaultLogConfig
+	config.MaxMessageBytes = 63
+	log := openTestLog(t, dir, config, &now)
+
+	// max.message.bytes only limits the leader, the followers copy what it appended
+	if _, err := log.Append(newTestBatch(0, 1, "abc"), 0); !errors.Is(err, ErrRecordTooLarge) {
+		t.Errorf("expected ErrRecordTooLarge, got %v", err)
+	}
+	if _, err := log.AppendAsFollower(newTestBatch(0, 1, "abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	config.MaxMessageBytes = 64
+	config.LogAppendTime = true
+	log.SetConfig(config)
+	info, err := log.Append(newTestBatch(0, 1, "abc"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LogAppendTime != 5000 {
+		t.Errorf("expected the log append time 5000, got %d", info.LogAppendTime)
+	}
+
+	batch, _ := log.Read(1, 64)
+	if binary.BigEndian.Uint16(batch[attributesOffset:])&logAppendTimeAttribute == 0 || binary.BigEndian.Uint64(batch[maxTimestampOffset:]) != 5000 {
+		t.Errorf("expected the batch to have the log append time, got %v", batch)
+	}
+	log.Close()
+
+	// The checksum covers the new timestamp
+	log, err = openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if log.LogEndOffset() != 2 {
+		t.Errorf("expected a log end offset of 2 after the checks, got %d", log.LogEndOffset())
+	}
+}
+
+func TestLogTruncateFullyAndStartAt(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	log, err := openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Append(newTestBatch(0, 2, "a"), 1)
+
+	if err := log.TruncateFullyAndStartAt(10); err != nil {
+		t.Fatal(err)
+	}
+	if log.LogStartOffset() != 10 || log.LogEndOffset() != 10 || log.Size() != 0 || len(log.LeaderEpochs().Entries()) != 0 {
+		t.Errorf("expected an empty log at 10 without epochs, got [%d, %d], %d bytes and %v", log.LogStartOffset(), log.LogEndOffset(), log.Size(), log.LeaderEpochs().Entries())
+	}
+	if info, err := log.Append(newTestBatch(0, 1, "b"), 2); err != nil || info.FirstOffset != 10 {
+		t.Errorf("expected the next batch at offset 10, got %d, %v", info.FirstOffset, err)
+	}
+	log.Close()
+
+	log, err = openLog(dir, "foo", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if got, want := segmentBaseOffsets(log), []int64{10}; !reflect.DeepEqual(got, want) || log.LogEndOffset() != 11 {
+		t.Errorf("expected a single segment at 10 ending at 11 after a restart, got %v and %d", got, log.LogEndOffset())
+	}
+}
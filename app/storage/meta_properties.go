@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+)
+
+// MetaPropertiesFile identifies the node owning a log dir and the dir itself, the same name Kafka uses
+const MetaPropertiesFile = "meta.properties"
+
+var ErrInvalidMetaProperties = errors.New("invalid meta.properties")
+
+// MetaProperties is the identity of a log dir. The directory id changes when the dir is lost and recreated, which
+// tells the metadata quorum that the entries the node acknowledged with it are gone
+type MetaProperties struct {
+	NodeId      int32
+	DirectoryId string
+}
+
+// EnsureMetaProperties reads the identity of a log dir, creating the dir and a new directory id on its first use.
+// A dir written by another node is rejected
+func EnsureMetaProperties(logDir string, nodeId int32) (MetaProperties, error) {
+	path := filepath.Join(logDir, MetaPropertiesFile)
+
+	properties, err := config.LoadProperties(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return writeMetaProperties(logDir, MetaProperties{NodeId: nodeId, DirectoryId: newDirectoryId()})
+	}
+	if err != nil {
+		return MetaProperties{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	storedNodeId, err := strconv.ParseInt(properties["node.id"], 10, 32)
+	if err != nil {
+		return MetaProperties{}, fmt.Errorf("%w: %s: node.id: %w", ErrInvalidMetaProperties, path, err)
+	}
+	if int32(storedNodeId) != nodeId {
+		return MetaProperties{}, fmt.Errorf("%w: %s belongs to node %d, not %d", ErrInvalidMetaProperties, path, storedNodeId, nodeId)
+	}
+	if properties["directory.id"] == "" {
+		return MetaProperties{}, fmt.Errorf("%w: %s has no directory.id", ErrInvalidMetaProperties, path)
+	}
+
+	return MetaProperties{NodeId: nodeId, DirectoryId: properties["directory.id"]}, nil
+}
+
+func writeMetaProperties(logDir string, meta MetaProperties) (MetaProperties, error) {
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return MetaProperties{}, fmt.Errorf("failed to create log dir %s: %w", logDir, err)
+	}
+
+	content := fmt.Sprintf("version=1\nnode.id=%d\ndirectory.id=%s\n", meta.NodeId, meta.DirectoryId)
+	if err := os.WriteFile(filepath.Join(logDir, MetaPropertiesFile), []byte(content), 0o644); err != nil {
+		return MetaProperties{}, fmt.Errorf("failed to write %s in %s: %w", MetaPropertiesFile, logDir, err)
+	}
+
+	return meta, nil
+}
+
+// newDirectoryId returns a random version 4 uuid
+func newDirectoryId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	value := hex.EncodeToString(id)
+	return value[0:8] + "-" + value[8:12] + "-" + value[12:16] + "-" + value[16:20] + "-" + value[20:32]
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureMetaProperties(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+
+	created, err := EnsureMetaProperties(logDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.DirectoryId) != 36 {
+		t.Fatalf("expected a uuid, got %q", created.DirectoryId)
+	}
+
+	// The directory id stays the same across restarts
+	loaded, err := EnsureMetaProperties(logDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != created {
+		t.Errorf("expected %+v, got %+v", created, loaded)
+	}
+
+	if _, err := EnsureMetaProperties(logDir, 2); !errors.Is(err, ErrInvalidMetaProperties) {
+		t.Errorf("expected ErrInvalidMetaProperties for another node, got %v", err)
+	}
+
+	other, err := EnsureMetaProperties(filepath.Join(t.TempDir(), "logs"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.DirectoryId == created.DirectoryId {
+		t.Errorf("every log dir must get its own directory id")
+	}
+}
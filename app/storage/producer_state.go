@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrInvalidProducerEpoch rejects the batches of a producer fenced by a newer epoch of its producer id
+	ErrInvalidProducerEpoch = errors.New("invalid producer epoch")
+	// ErrOutOfOrderSequence rejects a batch of an idempotent producer that does not follow its previous one
+	ErrOutOfOrderSequence = errors.New("out of order sequence number")
+	// ErrDuplicateSequence rejects the retry of one of the last batches of an idempotent producer, which the log already
+	// has
+	ErrDuplicateSequence = errors.New("duplicate sequence number")
+	// ErrTransactionCoordinatorFenced rejects the marker of a coordinator older than the one of the last marker of the
+	// producer
+	ErrTransactionCoordinatorFenced = errors.New("transaction coordinator fenced")
+)
+
+// ProducerState is what a log knows of an idempotent or transactional producer from its batches
+type ProducerState struct {
+	ProducerId    int64
+	ProducerEpoch int16
+	// The sequence of the last record of the producer, -1 before its first batch in the epoch
+	LastSequence int32
+	// The max timestamp of the last batch of the producer
+	LastTimestamp int64
+	// The epoch of the transaction coordinator that wrote the last marker of the producer, -1 without marker
+	CoordinatorEpoch int32
+	// The offset of the first record of the ongoing transaction of the producer, -1 outside of a transaction
+	CurrentTxnFirstOffset int64
+	// The sequences of the last batches of the producer in its epoch, oldest first, a retry of one is a duplicate
+	batches []sequenceRange
+}
+
+// producerBatchesKept is the number of the last batches of a producer whose retries are detected, like Kafka that
+// keeps as many as a producer may have in flight
+const producerBatchesKept = 5
+
+// sequenceRange is the sequences of the first and last records of a batch
+type sequenceRange struct {
+	first int32
+	last  int32
+}
+
+// AbortedTransaction is an aborted transaction of a log, from the first record of its producer to its ABORT marker.
+// READ_COMMITTED consumers skip the records of the producer in that range
+type AbortedTransaction struct {
+	ProducerId  int64
+	FirstOffset int64
+	LastOffset  int64
+}
+
+// producerStates follows the producers of a log and their transactions, from the batches appended to it. Like the
+// ProducerStateManager of Kafka, the state is rebuilt from the batches when the log is opened or truncated
+type producerStates struct {
+	producers map[int64]*ProducerState
+	// The aborted transactions of the log in the order of their markers
+	aborted []AbortedTransaction
+	// The transactions whose marker may not be replicated yet, they stay unstable until the high watermark passes it
+	completed []AbortedTransaction
+}
+
+func newProducerStates() *producerStates {
+	return &producerStates{producers: make(map[int64]*ProducerState)}
+}
+
+// nextSequence is the sequence following sequence, sequences wrap around after the largest int32
+func nextSequence(sequence int32) int32 {
+	if sequence == math.MaxInt32 {
+		return 0
+	}
+	return sequence + 1
+}
+
+// newProducerState is the state of a producer before its first batch
+func newProducerState(producerId int64, producerEpoch int16) *ProducerState {
+	return &ProducerState{ProducerId: producerId, ProducerEpoch: producerEpoch, LastSequence: -1, CoordinatorEpoch: -1, CurrentTxnFirstOffset: -1}
+}
+
+// check validates a batch of the producer appended by a leader: the epoch of the producer fences the older ones, and
+// the sequences of an epoch follow each other from 0 when checkSequence is set
+func (s *ProducerState) check(batch batchPosition, checkSequence bool) error {
+	if batch.producerEpoch < s.ProducerEpoch {
+		return fmt.Errorf("%w: producer %d has epoch %d, the batch has %d", ErrInvalidProducerEpoch, batch.producerId, s.ProducerEpoch, batch.producerEpoch)
+	}
+	if batch.marker != noMarker && batch.coordinatorEpoch < s.CoordinatorEpoch {
+		return fmt.Errorf("%w: producer %d has a marker of coordinator epoch %d, the batch has %d", ErrTransactionCoordinatorFenced, batch.producerId, s.CoordinatorEpoch, batch.coordinatorEpoch)
+	}
+	if batch.control || !checkSequence {
+		return nil
+	}
+
+	// A new epoch starts its sequences over
+	if batch.producerEpoch > s.ProducerEpoch {
+		if batch.baseSequence != 0 {
+			return fmt.Errorf("%w: producer %d starts epoch %d at sequence %d", ErrOutOfOrderSequence, batch.producerId, batch.producerEpoch, batch.baseSequence)
+		}
+		return nil
+	}
+	if slices.Contains(s.batches, sequenceRange{first: batch.baseSequence, last: batch.lastSequence()}) {
+		return fmt.Errorf("%w: producer %d already appended the sequences %d to %d", ErrDuplicateSequence, batch.producerId, batch.baseSequence, batch.lastSequence())
+	}
+	if batch.baseSequence != nextSequence(s.LastSequence) {
+		return fmt.Errorf("%w: producer %d expected sequence %d, got %d", ErrOutOfOrderSequence, batch.producerId, nextSequence(s.LastSequence), batch.baseSequence)
+	}
+	return nil
+}
+
+// apply moves the state of the producer past one of its batches. A transactional batch starts the transaction of the
+// producer, a marker ends it: the transaction is returned with true
+func (s *ProducerState) apply(batch batchPosition) (AbortedTransaction, bool) {
+	if batch.producerEpoch > s.ProducerEpoch {
+		s.ProducerEpoch = batch.producerEpoch
+		s.LastSequence = -1
+		s.batches = nil
+	}
+	s.LastTimestamp = batch.maxTimestamp
+
+	if batch.control {
+		if batch.marker == noMarker {
+			return AbortedTransaction{}, false
+		}
+		firstOffset := s.CurrentTxnFirstOffset
+		s.CurrentTxnFirstOffset = -1
+		s.CoordinatorEpoch = batch.coordinatorEpoch
+		if firstOffset < 0 {
+			return AbortedTransaction{}, false
+		}
+		return AbortedTransaction{ProducerId: s.ProducerId, FirstOffset: firstOffset, LastOffset: batch.lastOffset}, true
+	}
+
+	// The batches a coordinator writes for the producer have no sequence
+	if batch.baseSequence >= 0 {
+		s.LastSequence = batch.lastSequence()
+		// The kept batches are copied rather than appended to, the copies of the state share them
+		kept := s.batches[max(0, len(s.batches)-producerBatchesKept+1):]
+		s.batches = append(slices.Clip(kept), sequenceRange{first: batch.baseSequence, last: s.LastSequence})
+	}
+	if batch.transactional && s.CurrentTxnFirstOffset < 0 {
+		s.CurrentTxnFirstOffset = batch.baseOffset
+	}
+	return AbortedTransaction{}, false
+}
+
+// update applies a batch appended to the log to the state of its producer, and tracks the transaction its marker ends
+func (s *producerStates) update(batch batchPosition) {
+	if batch.producerId < 0 {
+		return
+	}
+
+	state, ok := s.producers[batch.producerId]
+	if !ok {
+		state = newProducerState(batch.producerId, batch.producerEpoch)
+		s.producers[batch.producerId] = state
+	}
+	transaction, completed := state.apply(batch)
+	if !completed {
+		return
+	}
+	s.completed = append(s.completed, transaction)
+	if batch.marker == ABORT_MARKER {
+		s.aborted = append(s.aborted, transaction)
+	}
+}
+
+// checkAppend validates the batches a leader appends, in order, against the states of their producers. The sequences
+// are only checked when checkSequence is set, for the batches of the producers themselves
+func (s *producerStates) checkAppend(batches []batchPosition, checkSequence bool) error {
+	pending := make(map[int64]*ProducerState)
+	for _, batch := range batches {
+		if batch.producerId < 0 {
+			continue
+		}
+
+		state, ok := pending[batch.producerId]
+		if !ok {
+			current, exists := s.producers[batch.producerId]
+			if !exists {
+				// The log does not know a producer before its first batch, such as one whose batches were deleted
+				state = newProducerState(batch.producerId, batch.producerEpoch)
+				state.apply(batch)
+				pending[batch.producerId] = state
+				continue
+			}
+			copied := *current
+			state = &copied
+			pending[batch.producerId] = state
+		}
+		if err := state.check(batch, checkSequence); err != nil {
+			return err
+		}
+		state.apply(batch)
+	}
+	return nil
+}
+
+// rebuild replaces the states with the ones of the batches of segments
+func (s *producerStates) rebuild(segments []*logSegment) {
+	*s = *newProducerStates()
+	for _, segment := range segments {
+		for _, batch := range segment.batches {
+			s.update(batch)
+		}
+	}
+}
+
+// firstUnstableOffset is the offset of the first record of a transaction that is ongoing, or whose marker is not below
+// highWatermark yet, and highWatermark when there is none. The transactions whose marker is below are forgotten
+func (s *producerStates) firstUnstableOffset(highWatermark int64) int64 {
+	first := highWatermark
+	for _, state := range s.producers {
+		if state.CurrentTxnFirstOffset >= 0 {
+			first = min(first, state.CurrentTxnFirstOffset)
+		}
+	}
+
+	s.completed = slices.DeleteFunc(s.completed, func(transaction AbortedTransaction) bool {
+		return transaction.LastOffset < highWatermark
+	})
+	for _, transaction := range s.completed {
+		first = min(first, transaction.FirstOffset)
+	}
+	return first
+}
+
+// truncateStart forgets the aborted transactions whose marker is before logStartOffset
+func (s *producerStates) truncateStart(logStartOffset int64) {
+	s.aborted = slices.DeleteFunc(s.aborted, func(transaction AbortedTransaction) bool {
+		return transaction.LastOffset < logStartOffset
+	})
+}
+
+// LastStableOffset is the offset of the first record of the log that is part of a transaction not known to be
+// complete, capped by highWatermark. READ_COMMITTED consumers read up to it
+func (l *Log) LastStableOffset(highWatermark int64) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return min(l.producers.firstUnstableOffset(highWatermark), highWatermark)
+}
+
+// AbortedTransactions returns the aborted transactions with records from startOffset and before endOffset, in the
+// order of their markers
+func (l *Log) AbortedTransactions(startOffset int64, endOffset int64) []AbortedTransaction {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	transactions := []AbortedTransaction{}
+	for _, transaction := range l.producers.aborted {
+		if transaction.LastOffset >= startOffset && transaction.FirstOffset < endOffset {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions
+}
+
+// InTransaction tells whether the producer has a transaction ongoing in the log with producerEpoch
+func (l *Log) InTransaction(producerId int64, producerEpoch int16) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	state, ok := l.producers.producers[producerId]
+	return ok && state.ProducerEpoch == producerEpoch && state.CurrentTxnFirstOffset >= 0
+}
+
+// ActiveProducers returns the state of the producers of the log, by producer id
+func (l *Log) ActiveProducers() []ProducerState {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	producers := make([]ProducerState, 0, len(l.producers.producers))
+	for _, producerId := range slices.Sorted(maps.Keys(l.producers.producers)) {
+		producers = append(producers, *l.producers.producers[producerId])
+	}
+	return producers
+}
+
+// ExpireProducers forgets the producers whose last batch is at least expiration old, like producer.id.expiration.ms.
+// The producers with a transaction ongoing are kept. It returns the number of producers forgotten
+func (l *Log) ExpireProducers(expiration time.Duration) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now().UnixMilli()
+	expired := 0
+	for producerId, state := range l.producers.producers {
+		if state.CurrentTxnFirstOffset < 0 && now-state.LastTimestamp >= expiration.Milliseconds() {
+			delete(l.producers.producers, producerId)
+			expired++
+		}
+	}
+	return expired
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestProducerBatch returns a record batch of records records of a producer, in a transaction when transactional
+// is set
+func newTestProducerBatch(producerId int64, producerEpoch int16, baseSequence int32, records int32, transactional bool) []byte {
+	batch := newTestBatch(0, records, "abc")
+	binary.BigEndian.PutUint64(batch[producerIdOffset:], uint64(producerId))
+	binary.BigEndian.PutUint16(batch[producerEpochOffset:], uint16(producerEpoch))
+	binary.BigEndian.PutUint32(batch[baseSequenceOffset:], uint32(baseSequence))
+	if transactional {
+		binary.BigEndian.PutUint16(batch[attributesOffset:], transactionalAttribute)
+	}
+	binary.BigEndian.PutUint32(batch[crcOffset:], crc32.Checksum(batch[attributesOffset:], crcTable))
+	return batch
+}
+
+func TestLogChecksProducerSequences(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	// The first batch of a producer the log does not know sets its sequence
+	if _, err := log.Append(newTestProducerBatch(7, 0, 5, 2, false), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		batch []byte
+		want  error
+	}{
+		{"Next sequence", newTestProducerBatch(7, 0, 7, 3, false), nil},
+		{"Retry of the last batch", newTestProducerBatch(7, 0, 7, 3, false), ErrDuplicateSequence},
+		{"Retry of an older batch", newTestProducerBatch(7, 0, 5, 2, false), ErrDuplicateSequence},
+		{"Gap in the sequences", newTestProducerBatch(7, 0, 11, 1, false), ErrOutOfOrderSequence},
+		{"New epoch after sequence 0", newTestProducerBatch(7, 1, 3, 1, false), ErrOutOfOrderSequence},
+		{"New epoch", newTestProducerBatch(7, 1, 0, 1, false), nil},
+		{"Fenced epoch", newTestProducerBatch(7, 0, 10, 1, false), ErrInvalidProducerEpoch},
+		{"Control batch from a client", NewControlBatch(7, 1, 0, COMMIT_MARKER, 0), ErrInvalidRecordBatch},
+		{"Other producer", newTestProducerBatch(8, 0, 0, 1, false), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := log.Append(tt.batch, 0); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// The batches of one append are checked against each other, and a rejected append changes nothing
+	batches := append(newTestProducerBatch(8, 0, 1, 1, false), newTestProducerBatch(8, 0, 3, 1, false)...)
+	if _, err := log.Append(batches, 0); !errors.Is(err, ErrOutOfOrderSequence) {
+		t.Errorf("expected the second batch to be out of order, got %v", err)
+	}
+
+	want := []ProducerState{
+		{ProducerId: 7, ProducerEpoch: 1, LastSequence: 0, CoordinatorEpoch: -1, CurrentTxnFirstOffset: -1, batches: []sequenceRange{{0, 0}}},
+		{ProducerId: 8, ProducerEpoch: 0, LastSequence: 0, CoordinatorEpoch: -1, CurrentTxnFirstOffset: -1, batches: []sequenceRange{{0, 0}}},
+	}
+	if got := log.ActiveProducers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the producers %v, got %v", want, got)
+	}
+
+	// A coordinator writes records for a producer without sequence, they leave its sequences as they were
+	if _, err := log.AppendAsCoordinator(newTestProducerBatch(8, 0, -1, 1, false), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(newTestProducerBatch(8, 0, 1, 1, false), 0); err != nil {
+		t.Errorf("expected the next sequence of the producer to be accepted, got %v", err)
+	}
+}
+
+func TestLogKeepsTheLastBatchesOfAProducer(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	for sequence := int32(0); sequence < producerBatchesKept+1; sequence++ {
+		if _, err := log.Append(newTestProducerBatch(7, 0, sequence, 1, false), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the retries of the last batches are known to be duplicates
+	if _, err := log.Append(newTestProducerBatch(7, 0, 1, 1, false), 0); !errors.Is(err, ErrDuplicateSequence) {
+		t.Errorf("expected the retry of a kept batch to be a duplicate, got %v", err)
+	}
+	if _, err := log.Append(newTestProducerBatch(7, 0, 0, 1, false), 0); !errors.Is(err, ErrOutOfOrderSequence) {
+		t.Errorf("expected the retry of a forgotten batch to be out of order, got %v", err)
+	}
+}
+
+func TestLogTransactions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo-0")
+	log, err := openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Producer 1 aborts the transaction at 0-1, producer 2 commits the one at 2-4, and producer 1 starts another at 7
+	appends := []struct {
+		batch       []byte
+		coordinator bool
+	}{
+		{newTestProducerBatch(1, 0, 0, 2, true), false},
+		{newTestProducerBatch(2, 0, 0, 3, true), false},
+		{NewControlBatch(1, 0, 4, ABORT_MARKER, 0), true},
+		{NewControlBatch(2, 0, 4, COMMIT_MARKER, 0), true},
+		{newTestProducerBatch(1, 0, 2, 1, true), false},
+	}
+	for i, append := range appends {
+		var err error
+		if append.coordinator {
+			_, err = log.AppendAsCoordinator(append.batch, 0)
+		} else {
+			_, err = log.Append(append.batch, 0)
+		}
+		if err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+
+	// The transactions stay unstable until the high watermark passes their marker
+	for _, tt := range []struct{ highWatermark, want int64 }{{1, 0}, {5, 0}, {6, 2}, {7, 7}, {8, 7}} {
+		if got := log.LastStableOffset(tt.highWatermark); got != tt.want {
+			t.Errorf("expected a last stable offset of %d with the high watermark at %d, got %d", tt.want, tt.highWatermark, got)
+		}
+	}
+
+	aborted := []AbortedTransaction{{ProducerId: 1, FirstOffset: 0, LastOffset: 5}}
+	if got := log.AbortedTransactions(2, 8); !reflect.DeepEqual(got, aborted) {
+		t.Errorf("expected the aborted transactions %v, got %v", aborted, got)
+	}
+	if got := log.AbortedTransactions(6, 8); len(got) != 0 {
+		t.Errorf("expected no aborted transaction after its marker, got %v", got)
+	}
+
+	// A marker with the epoch of a fenced producer is rejected
+	if _, err := log.AppendAsCoordinator(NewControlBatch(1, 0, 5, COMMIT_MARKER, 0), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(newTestProducerBatch(1, 2, 0, 1, true), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.AppendAsCoordinator(NewControlBatch(1, 1, 5, ABORT_MARKER, 0), 0); !errors.Is(err, ErrInvalidProducerEpoch) {
+		t.Errorf("expected ErrInvalidProducerEpoch, got %v", err)
+	}
+	log.Close()
+
+	// The producer states are rebuilt from the batches
+	log, err = openLog(dir, "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if got := log.AbortedTransactions(0, 10); !reflect.DeepEqual(got, aborted) {
+		t.Errorf("expected the aborted transactions %v after a restart, got %v", aborted, got)
+	}
+	if got := log.LastStableOffset(10); got != 9 {
+		t.Errorf("expected the ongoing transaction at 9 after a restart, got %d", got)
+	}
+
+	// Truncating the marker of a transaction makes it ongoing again
+	if err := log.TruncateTo(5); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.LastStableOffset(5); got != 0 {
+		t.Errorf("expected the truncated transaction to be ongoing again, got a last stable offset of %d", got)
+	}
+	if got := log.AbortedTransactions(0, 5); len(got) != 0 {
+		t.Errorf("expected no aborted transaction after the truncation, got %v", got)
+	}
+}
+
+func TestLogFencesOlderTransactionCoordinators(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	if _, err := log.Append(newTestProducerBatch(1, 0, 0, 1, true), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.AppendAsCoordinator(NewControlBatch(1, 0, 2, COMMIT_MARKER, 0), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A coordinator older than the one of the last marker may not write another
+	if _, err := log.AppendAsCoordinator(NewControlBatch(1, 0, 1, ABORT_MARKER, 0), 0); !errors.Is(err, ErrTransactionCoordinatorFenced) {
+		t.Errorf("expected ErrTransactionCoordinatorFenced, got %v", err)
+	}
+	if _, err := log.AppendAsCoordinator(NewControlBatch(1, 0, 2, COMMIT_MARKER, 0), 0); err != nil {
+		t.Errorf("expected the retry of the coordinator to be accepted, got %v", err)
+	}
+}
+
+func TestLogExpiresIdleProducers(t *testing.T) {
+	now := time.UnixMilli(999)
+	log := openTestLog(t, filepath.Join(t.TempDir(), "foo-0"), DefaultLogConfig, &now)
+	defer log.Close()
+
+	// The batches of both producers have the timestamp 0, the second producer has a transaction ongoing
+	if _, err := log.Append(newTestProducerBatch(1, 0, 0, 2, false), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(newTestProducerBatch(2, 0, 0, 1, true), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if expired := log.ExpireProducers(time.Second); expired != 0 || len(log.ActiveProducers()) != 2 {
+		t.Errorf("expected no producer to expire yet, got %d expired and %v", expired, log.ActiveProducers())
+	}
+
+	now = time.UnixMilli(1000)
+	if expired := log.ExpireProducers(time.Second); expired != 1 {
+		t.Errorf("expected 1 producer to expire, got %d", expired)
+	}
+	if producers := log.ActiveProducers(); len(producers) != 1 || producers[0].ProducerId != 2 {
+		t.Errorf("expected only the producer with a transaction ongoing, got %v", producers)
+	}
+
+	// An expired producer starts over like a new one
+	if _, err := log.Append(newTestProducerBatch(1, 0, 5, 1, false), 0); err != nil {
+		t.Errorf("expected the batch of the expired producer to be accepted, got %v", err)
+	}
+}
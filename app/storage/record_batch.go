@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// The types of the control records that end a transaction in a data partition, the key of the record after its
+// version
+const (
+	ABORT_MARKER  int16 = 0
+	COMMIT_MARKER int16 = 1
+)
+
+// noMarker is the marker of the batches that do not end a transaction
+const noMarker int16 = -1
+
+// compressionAttributes are the bits of the batch attributes naming the compression codec of its records
+const compressionAttributes = 0x07
+
+// Record is a record of a batch, a nil key or value is null. The offset is only known once the batch was appended
+type Record struct {
+	Offset int64
+	Key    []byte
+	Value  []byte
+}
+
+// NewRecordBatch returns an uncompressed batch of records without producer, written by the broker itself such as the
+// records of the internal topics. The leader assigns its offsets and leader epoch
+func NewRecordBatch(timestamp int64, records []Record) []byte {
+	return newRecordBatch(0, -1, -1, timestamp, records)
+}
+
+// NewTransactionalBatch returns an uncompressed batch of records a coordinator writes on behalf of the transaction of
+// producerId, such as the offsets a transactional producer commits for a consumer group. It has no sequence, and the
+// marker of the transaction makes its records visible or discards them
+func NewTransactionalBatch(producerId int64, producerEpoch int16, timestamp int64, records []Record) []byte {
+	return newRecordBatch(transactionalAttribute, producerId, producerEpoch, timestamp, records)
+}
+
+// NewControlBatch returns the control batch of a transaction marker, written by the leader of a data partition when
+// the transaction coordinator of producerId commits or aborts. The leader assigns its offset and leader epoch
+func NewControlBatch(producerId int64, producerEpoch int16, coordinatorEpoch int32, marker int16, timestamp int64) []byte {
+	key := binary.BigEndian.AppendUint16(nil, 0) // Version
+	key = binary.BigEndian.AppendUint16(key, uint16(marker))
+	value := binary.BigEndian.AppendUint16(nil, 0) // Version
+	value = binary.BigEndian.AppendUint32(value, uint32(coordinatorEpoch))
+
+	return newRecordBatch(transactionalAttribute|controlAttribute, producerId, producerEpoch, timestamp, []Record{{Key: key, Value: value}})
+}
+
+func newRecordBatch(attributes uint16, producerId int64, producerEpoch int16, timestamp int64, records []Record) []byte {
+	batch := make([]byte, batchHeaderSize)
+	batch[magicOffset] = 2
+	binary.BigEndian.PutUint16(batch[attributesOffset:], attributes)
+	binary.BigEndian.PutUint32(batch[lastOffsetDeltaOffset:], uint32(len(records)-1))
+	binary.BigEndian.PutUint64(batch[baseTimestampOffset:], uint64(timestamp))
+	binary.BigEndian.PutUint64(batch[maxTimestampOffset:], uint64(timestamp))
+	binary.BigEndian.PutUint64(batch[producerIdOffset:], uint64(producerId))
+	binary.BigEndian.PutUint16(batch[producerEpochOffset:], uint16(producerEpoch))
+	binary.BigEndian.PutUint32(batch[baseSequenceOffset:], ^uint32(0)) // -1
+	binary.BigEndian.PutUint32(batch[recordsCountOffset:], uint32(len(records)))
+
+	for offsetDelta, record := range records {
+		body := []byte{0}                   // Attributes
+		body = binary.AppendVarint(body, 0) // TimestampDelta
+		body = binary.AppendVarint(body, int64(offsetDelta))
+		for _, field := range [][]byte{record.Key, record.Value} {
+			if field == nil {
+				body = binary.AppendVarint(body, -1)
+			} else {
+				body = binary.AppendVarint(body, int64(len(field)))
+				body = append(body, field...)
+			}
+		}
+		body = binary.AppendVarint(body, 0) // Headers
+
+		batch = binary.AppendVarint(batch, int64(len(body)))
+		batch = append(batch, body...)
+	}
+
+	binary.BigEndian.PutUint32(batch[batchLengthOffset:], uint32(len(batch)-batchLengthOffset-4))
+	binary.BigEndian.PutUint32(batch[crcOffset:], crc32.Checksum(batch[attributesOffset:], crcTable))
+	return batch
+}
+
+// TransactionalProducer returns the producer of the first batch of batches when it is transactional, the batches of a
+// produce request all have the same producer. ok is false for the batches of no transaction
+func TransactionalProducer(batches []byte) (producerId int64, producerEpoch int16, ok bool) {
+	if len(batches) < batchHeaderSize {
+		return -1, -1, false
+	}
+	attributes := binary.BigEndian.Uint16(batches[attributesOffset:])
+	producerId = int64(binary.BigEndian.Uint64(batches[producerIdOffset:]))
+	if attributes&transactionalAttribute == 0 || attributes&controlAttribute != 0 || producerId < 0 {
+		return -1, -1, false
+	}
+	return producerId, int16(binary.BigEndian.Uint16(batches[producerEpochOffset:])), true
+}
+
+// ReadRecords returns the records of the uncompressed batches read from a log, with their offsets. The control
+// batches are skipped
+func ReadRecords(batches []byte) ([]Record, error) {
+	records := []Record{}
+	for index := 0; index < len(batches); {
+		if len(batches)-index < batchHeaderSize {
+			return nil, fmt.Errorf("%w: %d bytes left for a batch header", ErrInvalidRecordBatch, len(batches)-index)
+		}
+		length := int(binary.BigEndian.Uint32(batches[index+batchLengthOffset:]))
+		end := index + batchLengthOffset + 4 + length
+		if length < batchHeaderSize-batchLengthOffset-4 || end > len(batches) {
+			return nil, fmt.Errorf("%w: batch length %d", ErrInvalidRecordBatch, length)
+		}
+		batch := batches[index:end]
+		index = end
+
+		attributes := binary.BigEndian.Uint16(batch[attributesOffset:])
+		if attributes&controlAttribute != 0 {
+			continue
+		}
+		if attributes&compressionAttributes != 0 {
+			return nil, fmt.Errorf("%w: compressed batch", ErrInvalidRecordBatch)
+		}
+
+		batchRecords, err := parseRecords(batch)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, batchRecords...)
+	}
+	return records, nil
+}
+
+// parseRecords reads the records of an uncompressed batch
+func parseRecords(batch []byte) ([]Record, error) {
+	baseOffset := int64(binary.BigEndian.Uint64(batch))
+	count := int(binary.BigEndian.Uint32(batch[recordsCountOffset:]))
+
+	records := make([]Record, 0, count)
+	data := batch[batchHeaderSize:]
+	for range count {
+		length, read := binary.Varint(data)
+		if read <= 0 || length < 0 || int64(len(data)-read) < length {
+			return nil, fmt.Errorf("%w: invalid record length", ErrInvalidRecordBatch)
+		}
+		body := data[read : read+int(length)]
+		data = data[read+int(length):]
+
+		// Attributes, then the timestamp and offset deltas
+		index := 1
+		var offsetDelta int64
+		for i := range 2 {
+			if index > len(body) {
+				return nil, fmt.Errorf("%w: invalid record", ErrInvalidRecordBatch)
+			}
+			value, read := binary.Varint(body[index:])
+			if read <= 0 {
+				return nil, fmt.Errorf("%w: invalid record", ErrInvalidRecordBatch)
+			}
+			index += read
+			if i == 1 {
+				offsetDelta = value
+			}
+		}
+
+		fields := [][]byte{}
+		for range 2 {
+			if index > len(body) {
+				return nil, fmt.Errorf("%w: invalid record", ErrInvalidRecordBatch)
+			}
+			size, read := binary.Varint(body[index:])
+			if read <= 0 || size < -1 || int64(len(body)-index-read) < size {
+				return nil, fmt.Errorf("%w: invalid record field", ErrInvalidRecordBatch)
+			}
+			index += read
+
+			var field []byte
+			if size >= 0 {
+				field = append([]byte{}, body[index:index+int(size)]...)
+				index += int(size)
+			}
+			fields = append(fields, field)
+		}
+
+		records = append(records, Record{Offset: baseOffset + offsetDelta, Key: fields[0], Value: fields[1]})
+	}
+	return records, nil
+}
+
+// parseMarker reads the marker and the coordinator epoch of the record of a control batch. A control record that does
+// not end a transaction is noMarker
+func parseMarker(batch []byte) (int16, int32, bool) {
+	records, err := parseRecords(batch)
+	if err != nil || len(records) == 0 {
+		return 0, 0, false
+	}
+
+	key, value := records[0].Key, records[0].Value
+	if len(key) < 4 {
+		return 0, 0, false
+	}
+	marker := int16(binary.BigEndian.Uint16(key[2:]))
+	if marker != ABORT_MARKER && marker != COMMIT_MARKER {
+		return noMarker, -1, true
+	}
+	if len(value) < 6 {
+		return 0, 0, false
+	}
+	return marker, int32(binary.BigEndian.Uint32(value[2:])), true
+}
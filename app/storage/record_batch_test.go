@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadRecords(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	first := []Record{{Key: []byte("a"), Value: []byte("1")}, {Key: []byte("b"), Value: nil}}
+	second := []Record{{Key: nil, Value: []byte("2")}}
+	if _, err := log.Append(NewRecordBatch(1000, first), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.AppendAsCoordinator(NewControlBatch(3, 0, 1, COMMIT_MARKER, 1000), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.Append(NewRecordBatch(1000, second), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := log.Read(0, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadRecords(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The records keep the offsets the log assigned, the marker at offset 2 is skipped
+	want := []Record{
+		{Offset: 0, Key: []byte("a"), Value: []byte("1")},
+		{Offset: 1, Key: []byte("b"), Value: nil},
+		{Offset: 3, Key: nil, Value: []byte("2")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the records %v, got %v", want, got)
+	}
+
+	if _, err := ReadRecords(data[:len(data)-1]); err == nil {
+		t.Errorf("expected an error for a truncated batch")
+	}
+}
+
+func TestNewTransactionalBatch(t *testing.T) {
+	log, err := openLog(filepath.Join(t.TempDir(), "foo-0"), "foo", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	// The batch of a coordinator has no sequence, it starts the transaction of the producer until its marker
+	if _, err := log.AppendAsCoordinator(NewTransactionalBatch(3, 0, 1000, []Record{{Key: []byte("a"), Value: []byte("1")}}), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.LastStableOffset(1); got != 0 {
+		t.Errorf("expected the transaction to be ongoing, got a last stable offset of %d", got)
+	}
+
+	if _, err := log.AppendAsCoordinator(NewControlBatch(3, 0, 1, ABORT_MARKER, 1000), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.LastStableOffset(2); got != 2 {
+		t.Errorf("expected the transaction to be aborted, got a last stable offset of %d", got)
+	}
+	aborted := []AbortedTransaction{{ProducerId: 3, FirstOffset: 0, LastOffset: 1}}
+	if got := log.AbortedTransactions(0, 2); !reflect.DeepEqual(got, aborted) {
+		t.Errorf("expected the aborted transactions %v, got %v", aborted, got)
+	}
+}
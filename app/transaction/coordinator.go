@@ -0,0 +1,790 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+var (
+	// ErrNotCoordinator is returned for the transactional ids whose partition of the state log the broker does not
+	// lead, the clients look for the coordinator again
+	ErrNotCoordinator = errors.New("not the coordinator of the transactional id")
+	// ErrCoordinatorLoadInProgress is returned while the broker loads the partition of the transactional id
+	ErrCoordinatorLoadInProgress = errors.New("coordinator load in progress")
+	// ErrCoordinatorNotAvailable is returned when a change of the state could not be written to the state log
+	ErrCoordinatorNotAvailable = errors.New("coordinator not available")
+	// ErrConcurrentTransactions is returned while the previous transaction of the producer completes, or while another
+	// change of its state is written
+	ErrConcurrentTransactions = errors.New("concurrent transactions")
+	ErrInvalidTxnState        = errors.New("invalid transaction state")
+	// ErrProducerFenced is returned to a producer whose epoch is older than the one of its transactional id
+	ErrProducerFenced = errors.New("producer fenced")
+	// ErrInvalidProducerIdMapping is returned when the transactional id does not have the producer id of the request
+	ErrInvalidProducerIdMapping  = errors.New("invalid producer id mapping")
+	ErrInvalidTransactionTimeout = errors.New("invalid transaction timeout")
+	// ErrTransactionalIdNotFound is returned when describing a transactional id the coordinator has no state for
+	ErrTransactionalIdNotFound = errors.New("transactional id not found")
+)
+
+// Marker is a transaction marker, written by the leaders of the partitions of the transaction
+type Marker struct {
+	ProducerId       int64
+	ProducerEpoch    int16
+	Commit           bool
+	CoordinatorEpoch int32
+	Partitions       []storage.TopicPartition
+}
+
+// MarkerTransport writes the markers of the transactions to the leaders of their partitions, with the
+// WriteTxnMarkers API. It returns the error of every partition, or the error of the request
+type MarkerTransport interface {
+	WriteTxnMarkers(leaderId int32, marker Marker) (map[storage.TopicPartition]error, error)
+}
+
+// Config holds the settings of the transaction coordinator of a broker
+type Config struct {
+	NodeId int32
+	// The number of partitions of the state log until it is created, transaction.state.log.num.partitions
+	NumPartitions int
+	// The producers can not ask for a transaction timeout above MaxTimeout, transaction.max.timeout.ms
+	MaxTimeout time.Duration
+	// The transactions past their timeout are aborted every AbortTimedOutInterval,
+	// transaction.abort.timed.out.transaction.cleanup.interval.ms
+	AbortTimedOutInterval time.Duration
+	// The changes of the state wait up to RequestTimeout for the replicas of the state log, and the markers that
+	// could not be written are retried after RetryBackoff
+	RequestTimeout time.Duration
+	RetryBackoff   time.Duration
+}
+
+// Coordinator is the transaction coordinator of a broker, like the one of Kafka: it owns the transactional ids of
+// the partitions of the state log the broker leads, keeps their state in memory and writes every change to the state
+// log before answering. Once a transaction ends, the markers are written to its partitions and the transaction
+// completes. A transaction whose producer did not end it before its timeout is aborted, and the producer fenced
+type Coordinator struct {
+	config      Config
+	logs        *storage.LogManager
+	replicas    *replica.Manager
+	transport   MarkerTransport
+	producerIds *producerIdManager
+	logger      *slog.Logger
+	now         func() time.Time
+
+	mutex sync.Mutex
+	image *metadata.Image
+	// The coordinator epoch of the loaded partitions of the state log, the leader epoch of the partition
+	partitions map[int32]int32
+	loading    map[int32]bool
+	// The transactions of the loaded partitions by transactional id
+	transactions map[string]*TransactionMetadata
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// NewCoordinator starts the transaction coordinator of a broker, which owns no transactional id until ApplyImage
+// makes it the leader of partitions of the state log. brokerEpoch returns the epoch of the broker registration, which
+// the controller checks before allocating producer ids. Shutdown stops it
+func NewCoordinator(coordinatorConfig Config, logs *storage.LogManager, replicas *replica.Manager, transport MarkerTransport, producerIds ProducerIdSource, brokerEpoch func() int64, logger *slog.Logger) *Coordinator {
+	c := &Coordinator{
+		config:       coordinatorConfig,
+		logs:         logs,
+		replicas:     replicas,
+		transport:    transport,
+		producerIds:  &producerIdManager{brokerId: coordinatorConfig.NodeId, brokerEpoch: brokerEpoch, source: producerIds},
+		logger:       logger,
+		now:          time.Now,
+		image:        metadata.NewImage(),
+		partitions:   make(map[int32]int32),
+		loading:      make(map[int32]bool),
+		transactions: make(map[string]*TransactionMetadata),
+		stop:         make(chan struct{}),
+	}
+
+	c.stopped.Add(1)
+	go func() {
+		defer c.stopped.Done()
+		c.runTimeouts()
+	}()
+
+	return c
+}
+
+// Shutdown stops the timeouts and the markers still being written, the transactions complete on the next coordinator
+func (c *Coordinator) Shutdown() {
+	close(c.stop)
+	c.stopped.Wait()
+}
+
+// ApplyImage loads the transactions of the partitions of the state log the image makes the broker lead, and forgets
+// the ones of the partitions it no longer leads. It is subscribed to the metadata loader after the replica manager,
+// which opened the logs. The transactions that were completing when the previous coordinator stopped complete here
+func (c *Coordinator) ApplyImage(image *metadata.Image) {
+	led := make(map[int32]int32)
+	if topic, ok := image.Topic(TRANSACTION_STATE_TOPIC); ok {
+		for partitionId, partition := range topic.Partitions {
+			if partition.Leader == c.config.NodeId {
+				led[partitionId] = partition.LeaderEpoch
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	c.image = image
+	for partition, coordinatorEpoch := range c.partitions {
+		if epoch, ok := led[partition]; !ok || epoch != coordinatorEpoch {
+			c.unload(partition)
+		}
+	}
+	toLoad := []int32{}
+	for _, partition := range slices.Sorted(maps.Keys(led)) {
+		if _, ok := c.partitions[partition]; !ok {
+			c.loading[partition] = true
+			toLoad = append(toLoad, partition)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, partition := range toLoad {
+		transactions, err := c.load(partition)
+
+		c.mutex.Lock()
+		delete(c.loading, partition)
+		if err != nil {
+			c.mutex.Unlock()
+			c.logger.Error("Failed to load the transactions", "partition", partition, "error", err)
+			continue
+		}
+		coordinatorEpoch := led[partition]
+		c.partitions[partition] = coordinatorEpoch
+		completing := []string{}
+		for transactionalId, transaction := range transactions {
+			c.transactions[transactionalId] = transaction
+			if transaction.State == PREPARE_COMMIT || transaction.State == PREPARE_ABORT {
+				completing = append(completing, transactionalId)
+			}
+		}
+		c.mutex.Unlock()
+
+		c.logger.Info("Loaded the transactions", "partition", partition, "coordinatorEpoch", coordinatorEpoch, "transactions", len(transactions))
+		for _, transactionalId := range completing {
+			c.completeInBackground(transactionalId, coordinatorEpoch)
+		}
+	}
+}
+
+// unload forgets the transactions of a partition of the state log, the caller holds the mutex
+func (c *Coordinator) unload(partition int32) {
+	delete(c.partitions, partition)
+	for transactionalId := range c.transactions {
+		if c.partitionFor(transactionalId) == partition {
+			delete(c.transactions, transactionalId)
+		}
+	}
+}
+
+// load reads the transactions of a partition of the state log, the last record of a transactional id is its state
+func (c *Coordinator) load(partition int32) (map[string]*TransactionMetadata, error) {
+	topicPartition := storage.TopicPartition{Topic: TRANSACTION_STATE_TOPIC, Partition: partition}
+	offset, err := c.logs.LogStartOffset(topicPartition)
+	if err != nil {
+		return nil, err
+	}
+	endOffset, err := c.logs.LogEndOffset(topicPartition)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make(map[string]*TransactionMetadata)
+	for offset < endOffset {
+		batches, err := c.logs.Read(topicPartition, offset, 1<<20)
+		if err != nil {
+			return nil, err
+		}
+		records, err := storage.ReadRecords(batches)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		for _, record := range records {
+			transactionalId, transaction, err := decodeStateRecord(record.Key, record.Value)
+			if err != nil {
+				return nil, err
+			}
+			if transaction == nil {
+				delete(transactions, transactionalId)
+			} else {
+				transactions[transactionalId] = transaction
+			}
+		}
+		offset = records[len(records)-1].Offset + 1
+	}
+	return transactions, nil
+}
+
+// partitionFor returns the partition of the state log of a transactional id, the caller holds the mutex. Like Kafka,
+// the partitions are the ones of the topic once it exists
+func (c *Coordinator) partitionFor(transactionalId string) int32 {
+	numPartitions := c.config.NumPartitions
+	if topic, ok := c.image.Topic(TRANSACTION_STATE_TOPIC); ok {
+		numPartitions = len(topic.Partitions)
+	}
+	return PartitionFor(transactionalId, numPartitions)
+}
+
+// transaction returns the transaction of a transactional id, nil when it has none, and the epoch of the coordinator
+// owning it. The caller holds the mutex
+func (c *Coordinator) transaction(transactionalId string) (*TransactionMetadata, int32, error) {
+	partition := c.partitionFor(transactionalId)
+	if c.loading[partition] {
+		return nil, -1, fmt.Errorf("%w: partition %d of %s", ErrCoordinatorLoadInProgress, partition, TRANSACTION_STATE_TOPIC)
+	}
+	coordinatorEpoch, ok := c.partitions[partition]
+	if !ok {
+		return nil, -1, fmt.Errorf("%w: %s is in partition %d of %s", ErrNotCoordinator, transactionalId, partition, TRANSACTION_STATE_TOPIC)
+	}
+	return c.transactions[transactionalId], coordinatorEpoch, nil
+}
+
+// InitProducerId returns the producer id and epoch of a producer. An idempotent producer, without transactional id,
+// gets a new producer id, or the next epoch of its producer id when it has one. A transactional producer gets the
+// producer id of its transactional id with the next epoch, which fences its previous instances. The transaction they
+// left ongoing is aborted first: the producer retries on ErrConcurrentTransactions until it completed
+func (c *Coordinator) InitProducerId(transactionalId *string, timeoutMs int32, producerId int64, producerEpoch int16, respond func(int64, int16, error)) {
+	if transactionalId == nil || *transactionalId == "" {
+		if producerId >= 0 && producerEpoch >= 0 && producerEpoch < math.MaxInt16-1 {
+			respond(producerId, producerEpoch+1, nil)
+			return
+		}
+		producerId, err := c.producerIds.nextProducerId()
+		if err != nil {
+			respond(-1, -1, fmt.Errorf("%w: failed to allocate a producer id: %w", ErrCoordinatorNotAvailable, err))
+			return
+		}
+		respond(producerId, 0, nil)
+		return
+	}
+
+	if timeoutMs <= 0 || time.Duration(timeoutMs)*time.Millisecond > c.config.MaxTimeout {
+		respond(-1, -1, fmt.Errorf("%w: %d ms is not in (0, %d] ms", ErrInvalidTransactionTimeout, timeoutMs, c.config.MaxTimeout.Milliseconds()))
+		return
+	}
+
+	// A new transactional id, or one whose epochs are exhausted, gets a new producer id. It is allocated without the
+	// mutex, the controller may take a while
+	c.mutex.Lock()
+	transaction, _, err := c.transaction(*transactionalId)
+	c.mutex.Unlock()
+	if err != nil {
+		respond(-1, -1, err)
+		return
+	}
+	newProducerId := int64(-1)
+	if transaction == nil || transaction.ProducerEpoch >= math.MaxInt16-1 {
+		if newProducerId, err = c.producerIds.nextProducerId(); err != nil {
+			respond(-1, -1, fmt.Errorf("%w: failed to allocate a producer id: %w", ErrCoordinatorNotAvailable, err))
+			return
+		}
+	}
+
+	c.mutex.Lock()
+	transaction, coordinatorEpoch, err := c.transaction(*transactionalId)
+	if err != nil {
+		c.mutex.Unlock()
+		respond(-1, -1, err)
+		return
+	}
+	if transaction == nil {
+		if newProducerId < 0 {
+			c.mutex.Unlock()
+			respond(-1, -1, fmt.Errorf("%w: %s was removed meanwhile", ErrConcurrentTransactions, *transactionalId))
+			return
+		}
+		transaction = &TransactionMetadata{TransactionalId: *transactionalId, ProducerId: newProducerId, ProducerEpoch: -1, TimeoutMs: timeoutMs, State: EMPTY, LastUpdateTimestamp: -1, StartTimestamp: -1}
+		c.transactions[*transactionalId] = transaction
+	}
+
+	// A producer recovering from an error gives its current producer id and epoch, the ones of another instance
+	// are fenced
+	if producerId >= 0 && (producerId != transaction.ProducerId || producerEpoch != transaction.ProducerEpoch) {
+		c.mutex.Unlock()
+		respond(-1, -1, fmt.Errorf("%w: %s has producer id %d and epoch %d", ErrProducerFenced, *transactionalId, transaction.ProducerId, transaction.ProducerEpoch))
+		return
+	}
+	if transaction.pending || transaction.State == PREPARE_COMMIT || transaction.State == PREPARE_ABORT {
+		c.mutex.Unlock()
+		respond(-1, -1, fmt.Errorf("%w: the transaction of %s is %s", ErrConcurrentTransactions, *transactionalId, transaction.State))
+		return
+	}
+
+	if transaction.State == ONGOING {
+		target := c.fencedAbort(transaction)
+		c.mutex.Unlock()
+		c.abort(target, coordinatorEpoch)
+		respond(-1, -1, fmt.Errorf("%w: the ongoing transaction of %s is aborted", ErrConcurrentTransactions, *transactionalId))
+		return
+	}
+
+	target := *transaction
+	if transaction.ProducerEpoch >= math.MaxInt16-1 {
+		if newProducerId < 0 {
+			c.mutex.Unlock()
+			respond(-1, -1, fmt.Errorf("%w: the epochs of %s were exhausted meanwhile", ErrConcurrentTransactions, *transactionalId))
+			return
+		}
+		target.ProducerId, target.ProducerEpoch = newProducerId, 0
+	} else {
+		target.ProducerEpoch++
+	}
+	target.TimeoutMs = timeoutMs
+	target.State = EMPTY
+	target.Partitions = nil
+	target.LastUpdateTimestamp = c.now().UnixMilli()
+	target.StartTimestamp = -1
+	c.mutex.Unlock()
+
+	c.transition(transaction, &target, coordinatorEpoch, func(err error) {
+		if err != nil {
+			respond(-1, -1, err)
+			return
+		}
+		respond(target.ProducerId, target.ProducerEpoch, nil)
+	})
+}
+
+// AddPartitions adds partitions to the transaction of a producer, which starts it when it has none in progress. The
+// producer appends to the partitions once their addition is written
+func (c *Coordinator) AddPartitions(transactionalId string, producerId int64, producerEpoch int16, partitions []storage.TopicPartition, respond func(error)) {
+	c.mutex.Lock()
+	transaction, coordinatorEpoch, err := c.producerTransaction(transactionalId, producerId, producerEpoch)
+	if err != nil {
+		c.mutex.Unlock()
+		respond(err)
+		return
+	}
+	if transaction.pending || transaction.State == PREPARE_COMMIT || transaction.State == PREPARE_ABORT {
+		c.mutex.Unlock()
+		respond(fmt.Errorf("%w: the transaction of %s is %s", ErrConcurrentTransactions, transactionalId, transaction.State))
+		return
+	}
+
+	added := slices.Clone(transaction.Partitions)
+	if transaction.State != ONGOING {
+		added = nil
+	}
+	for _, partition := range partitions {
+		if !slices.Contains(added, partition) {
+			added = append(added, partition)
+		}
+	}
+	if transaction.State == ONGOING && len(added) == len(transaction.Partitions) {
+		c.mutex.Unlock()
+		respond(nil)
+		return
+	}
+	sortPartitions(added)
+
+	now := c.now().UnixMilli()
+	target := *transaction
+	if transaction.State != ONGOING {
+		target.StartTimestamp = now
+	}
+	target.State = ONGOING
+	target.Partitions = added
+	target.LastUpdateTimestamp = now
+	c.mutex.Unlock()
+
+	c.transition(transaction, &target, coordinatorEpoch, respond)
+}
+
+// VerifyPartitions tells the leaders of partitions whether they are part of the ongoing transaction of a producer,
+// before they append its first transactional batch. A partition that is not fails with ErrInvalidTxnState, a
+// producer the coordinator does not know fails the whole verification
+func (c *Coordinator) VerifyPartitions(transactionalId string, producerId int64, producerEpoch int16, partitions []storage.TopicPartition) (map[storage.TopicPartition]error, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	transaction, _, err := c.producerTransaction(transactionalId, producerId, producerEpoch)
+	if err != nil {
+		return nil, err
+	}
+	errs := make(map[storage.TopicPartition]error, len(partitions))
+	for _, partition := range partitions {
+		errs[partition] = nil
+		if transaction.State != ONGOING || !slices.Contains(transaction.Partitions, partition) {
+			errs[partition] = fmt.Errorf("%w: %s-%d is not part of the transaction of %s", ErrInvalidTxnState, partition.Topic, partition.Partition, transactionalId)
+		}
+	}
+	return errs, nil
+}
+
+// EndTransaction commits or aborts the transaction of a producer. It responds once the decision is written, the
+// markers are written to the partitions of the transaction afterwards. The retry of a request whose transaction
+// already completed succeeds
+func (c *Coordinator) EndTransaction(transactionalId string, producerId int64, producerEpoch int16, commit bool, respond func(error)) {
+	c.mutex.Lock()
+	transaction, coordinatorEpoch, err := c.producerTransaction(transactionalId, producerId, producerEpoch)
+	if err != nil {
+		c.mutex.Unlock()
+		respond(err)
+		return
+	}
+	if transaction.pending {
+		c.mutex.Unlock()
+		respond(fmt.Errorf("%w: the transaction of %s is changing", ErrConcurrentTransactions, transactionalId))
+		return
+	}
+
+	prepare, complete := PREPARE_ABORT, COMPLETE_ABORT
+	if commit {
+		prepare, complete = PREPARE_COMMIT, COMPLETE_COMMIT
+	}
+	switch transaction.State {
+	case ONGOING:
+	case complete:
+		c.mutex.Unlock()
+		respond(nil)
+		return
+	case prepare:
+		c.mutex.Unlock()
+		respond(fmt.Errorf("%w: the transaction of %s is %s", ErrConcurrentTransactions, transactionalId, transaction.State))
+		return
+	default:
+		c.mutex.Unlock()
+		respond(fmt.Errorf("%w: cannot end the transaction of %s, which is %s", ErrInvalidTxnState, transactionalId, transaction.State))
+		return
+	}
+
+	target := *transaction
+	target.State = prepare
+	target.LastUpdateTimestamp = c.now().UnixMilli()
+	c.mutex.Unlock()
+
+	c.transition(transaction, &target, coordinatorEpoch, func(err error) {
+		respond(err)
+		if err == nil {
+			c.completeInBackground(transactionalId, coordinatorEpoch)
+		}
+	})
+}
+
+// DescribeTransaction returns a copy of the state of the transaction of a transactional id
+func (c *Coordinator) DescribeTransaction(transactionalId string) (TransactionMetadata, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	transaction, _, err := c.transaction(transactionalId)
+	if err != nil {
+		return TransactionMetadata{}, err
+	}
+	if transaction == nil {
+		return TransactionMetadata{}, fmt.Errorf("%w: %s", ErrTransactionalIdNotFound, transactionalId)
+	}
+
+	described := *transaction
+	described.Partitions = slices.Clone(transaction.Partitions)
+	return described, nil
+}
+
+// TransactionFilter selects the transactions ListTransactions returns
+type TransactionFilter struct {
+	// The states and the producer ids of the listed transactions, empty for any
+	States      []TransactionState
+	ProducerIds []int64
+	// Only the transactions that started longer than DurationFilter ago are listed, a negative duration lists them
+	// regardless. Like Kafka, the transactions without start timestamp are always listed
+	DurationFilter time.Duration
+}
+
+// ListTransactions returns a copy of the transactions of the loaded partitions of the state log that filter selects,
+// by transactional id. It fails with ErrCoordinatorLoadInProgress while a partition is being loaded
+func (c *Coordinator) ListTransactions(filter TransactionFilter) ([]TransactionMetadata, error) {
+	now := c.now().UnixMilli()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.loading) > 0 {
+		return nil, fmt.Errorf("%w: partitions of %s", ErrCoordinatorLoadInProgress, TRANSACTION_STATE_TOPIC)
+	}
+
+	transactions := []TransactionMetadata{}
+	for _, transactionalId := range slices.Sorted(maps.Keys(c.transactions)) {
+		transaction := c.transactions[transactionalId]
+		switch {
+		case len(filter.States) > 0 && !slices.Contains(filter.States, transaction.State):
+			continue
+		case len(filter.ProducerIds) > 0 && !slices.Contains(filter.ProducerIds, transaction.ProducerId):
+			continue
+		case filter.DurationFilter >= 0 && now-transaction.StartTimestamp <= filter.DurationFilter.Milliseconds():
+			continue
+		}
+
+		listed := *transaction
+		listed.Partitions = slices.Clone(transaction.Partitions)
+		transactions = append(transactions, listed)
+	}
+	return transactions, nil
+}
+
+// producerTransaction returns the transaction of a transactional id for a request of its producer, which must have
+// its producer id and epoch. The caller holds the mutex
+func (c *Coordinator) producerTransaction(transactionalId string, producerId int64, producerEpoch int16) (*TransactionMetadata, int32, error) {
+	transaction, coordinatorEpoch, err := c.transaction(transactionalId)
+	if err != nil {
+		return nil, -1, err
+	}
+	if transaction == nil || transaction.ProducerId != producerId {
+		return nil, -1, fmt.Errorf("%w: %s does not have producer id %d", ErrInvalidProducerIdMapping, transactionalId, producerId)
+	}
+	if transaction.ProducerEpoch != producerEpoch {
+		return nil, -1, fmt.Errorf("%w: %s has epoch %d, not %d", ErrProducerFenced, transactionalId, transaction.ProducerEpoch, producerEpoch)
+	}
+	return transaction, coordinatorEpoch, nil
+}
+
+// fencedAbort returns the PREPARE_ABORT state of an ongoing transaction with the next epoch of its producer, which
+// fences the producer that did not end it. The caller holds the mutex
+func (c *Coordinator) fencedAbort(transaction *TransactionMetadata) *TransactionMetadata {
+	target := *transaction
+	if target.ProducerEpoch < math.MaxInt16 {
+		target.ProducerEpoch++
+	}
+	target.State = PREPARE_ABORT
+	target.LastUpdateTimestamp = c.now().UnixMilli()
+	return &target
+}
+
+// abort writes the PREPARE_ABORT state of a transaction, then completes it in the background
+func (c *Coordinator) abort(target *TransactionMetadata, coordinatorEpoch int32) {
+	c.mutex.Lock()
+	transaction, ok := c.transactions[target.TransactionalId]
+	c.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	c.transition(transaction, target, coordinatorEpoch, func(err error) {
+		if err != nil {
+			c.logger.Warn("Failed to abort a transaction", "transactionalId", target.TransactionalId, "error", err)
+			return
+		}
+		c.completeInBackground(target.TransactionalId, coordinatorEpoch)
+	})
+}
+
+// transition writes the target state of a transaction to the state log, and applies it once the replicas of the state
+// log have it. The transaction takes no other change meanwhile. The state is left as it was when the write fails, or
+// when the coordinator lost the partition in the meantime
+func (c *Coordinator) transition(transaction *TransactionMetadata, target *TransactionMetadata, coordinatorEpoch int32, respond func(error)) {
+	key, err := encodeStateKey(target.TransactionalId)
+	if err != nil {
+		respond(err)
+		return
+	}
+	value, err := encodeStateValue(target)
+	if err != nil {
+		respond(err)
+		return
+	}
+
+	c.mutex.Lock()
+	partition := c.partitionFor(target.TransactionalId)
+	if c.transactions[target.TransactionalId] != transaction || c.partitions[partition] != coordinatorEpoch {
+		c.mutex.Unlock()
+		respond(fmt.Errorf("%w: lost the transaction of %s", ErrNotCoordinator, target.TransactionalId))
+		return
+	}
+	if transaction.pending {
+		c.mutex.Unlock()
+		respond(fmt.Errorf("%w: the transaction of %s is changing", ErrConcurrentTransactions, target.TransactionalId))
+		return
+	}
+	transaction.pending = true
+	c.mutex.Unlock()
+
+	topicPartition := storage.TopicPartition{Topic: TRANSACTION_STATE_TOPIC, Partition: partition}
+	batch := storage.NewRecordBatch(c.now().UnixMilli(), []storage.Record{{Key: key, Value: value}})
+	c.replicas.AppendRecords(c.config.RequestTimeout, replica.ACKS_ALL, map[storage.TopicPartition][]byte{topicPartition: batch}, func(results map[storage.TopicPartition]replica.AppendResult) {
+		err := stateLogError(results[topicPartition].Err)
+
+		c.mutex.Lock()
+		transaction.pending = false
+		if err == nil && (c.transactions[target.TransactionalId] != transaction || c.partitions[partition] != coordinatorEpoch) {
+			err = fmt.Errorf("%w: lost the transaction of %s", ErrNotCoordinator, target.TransactionalId)
+		}
+		if err == nil {
+			*transaction = *target
+		}
+		c.mutex.Unlock()
+
+		respond(err)
+	})
+}
+
+// stateLogError maps the error of a write to the state log to the error of the coordinator
+func stateLogError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, replica.ErrNotLeaderOrFollower), errors.Is(err, replica.ErrUnknownTopicOrPartition), errors.Is(err, storage.ErrKafkaStorage):
+		return fmt.Errorf("%w: %w", ErrNotCoordinator, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrCoordinatorNotAvailable, err)
+	}
+}
+
+// completeInBackground writes the markers of a transaction in PREPARE_COMMIT or PREPARE_ABORT, then completes it
+func (c *Coordinator) completeInBackground(transactionalId string, coordinatorEpoch int32) {
+	c.stopped.Add(1)
+	go func() {
+		defer c.stopped.Done()
+		c.complete(transactionalId, coordinatorEpoch)
+	}()
+}
+
+// complete writes the markers of a transaction to the leaders of its partitions until each one has it, and then its
+// COMPLETE_COMMIT or COMPLETE_ABORT state. The partitions of a deleted topic need no marker. It gives up once the
+// coordinator lost the transaction or a leader fenced it, the next coordinator completes it
+func (c *Coordinator) complete(transactionalId string, coordinatorEpoch int32) {
+	var remaining []storage.TopicPartition
+	for {
+		c.mutex.Lock()
+		transaction, epoch, err := c.transaction(transactionalId)
+		if err != nil || epoch != coordinatorEpoch || transaction == nil || (transaction.State != PREPARE_COMMIT && transaction.State != PREPARE_ABORT) {
+			c.mutex.Unlock()
+			return
+		}
+		if remaining == nil {
+			remaining = slices.Clone(transaction.Partitions)
+		}
+		marker := Marker{ProducerId: transaction.ProducerId, ProducerEpoch: transaction.ProducerEpoch, Commit: transaction.State == PREPARE_COMMIT, CoordinatorEpoch: coordinatorEpoch}
+
+		byLeader := make(map[int32][]storage.TopicPartition)
+		remaining = slices.DeleteFunc(remaining, func(partition storage.TopicPartition) bool {
+			topic, ok := c.image.Topic(partition.Topic)
+			if !ok {
+				return true
+			}
+			if image, ok := topic.Partitions[partition.Partition]; ok && image.Leader != metadata.NO_LEADER {
+				byLeader[image.Leader] = append(byLeader[image.Leader], partition)
+			}
+			return false
+		})
+		c.mutex.Unlock()
+
+		for _, leaderId := range slices.Sorted(maps.Keys(byLeader)) {
+			marker.Partitions = byLeader[leaderId]
+			errs, err := c.transport.WriteTxnMarkers(leaderId, marker)
+			if err != nil {
+				c.logger.Warn("Failed to write transaction markers", "transactionalId", transactionalId, "leader", leaderId, "error", err)
+				continue
+			}
+			// A leader that has a marker of a newer coordinator tells the transaction moved to it
+			for _, partition := range marker.Partitions {
+				if errors.Is(errs[partition], storage.ErrTransactionCoordinatorFenced) {
+					c.logger.Warn("Transaction coordinator fenced", "transactionalId", transactionalId, "coordinatorEpoch", coordinatorEpoch, "error", errs[partition])
+					return
+				}
+			}
+			remaining = slices.DeleteFunc(remaining, func(partition storage.TopicPartition) bool {
+				return slices.Contains(marker.Partitions, partition) && errs[partition] == nil
+			})
+		}
+
+		if len(remaining) == 0 && c.writeComplete(transactionalId, coordinatorEpoch) {
+			return
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(c.config.RetryBackoff):
+		}
+	}
+}
+
+// writeComplete writes the COMPLETE_COMMIT or COMPLETE_ABORT state of a transaction whose markers were written, and
+// tells if it is done
+func (c *Coordinator) writeComplete(transactionalId string, coordinatorEpoch int32) bool {
+	c.mutex.Lock()
+	transaction, epoch, err := c.transaction(transactionalId)
+	if err != nil || epoch != coordinatorEpoch || transaction == nil || (transaction.State != PREPARE_COMMIT && transaction.State != PREPARE_ABORT) {
+		c.mutex.Unlock()
+		return true
+	}
+	target := *transaction
+	target.State = COMPLETE_ABORT
+	if transaction.State == PREPARE_COMMIT {
+		target.State = COMPLETE_COMMIT
+	}
+	target.Partitions = nil
+	target.LastUpdateTimestamp = c.now().UnixMilli()
+	c.mutex.Unlock()
+
+	written := make(chan error, 1)
+	c.transition(transaction, &target, coordinatorEpoch, func(err error) {
+		written <- err
+	})
+	if err := <-written; err != nil {
+		c.logger.Warn("Failed to complete a transaction", "transactionalId", transactionalId, "error", err)
+		return errors.Is(err, ErrNotCoordinator)
+	}
+	return true
+}
+
+// runTimeouts aborts the transactions past their timeout every AbortTimedOutInterval, until Shutdown
+func (c *Coordinator) runTimeouts() {
+	ticker := time.NewTicker(c.config.AbortTimedOutInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.abortTimedOutTransactions()
+		}
+	}
+}
+
+// abortTimedOutTransactions aborts the ongoing transactions that started longer than their timeout ago. Their
+// producer is fenced, it must not add to the aborted transaction
+func (c *Coordinator) abortTimedOutTransactions() {
+	now := c.now().UnixMilli()
+
+	type timedOut struct {
+		target           *TransactionMetadata
+		coordinatorEpoch int32
+	}
+	aborted := []timedOut{}
+
+	c.mutex.Lock()
+	for _, transactionalId := range slices.Sorted(maps.Keys(c.transactions)) {
+		transaction := c.transactions[transactionalId]
+		if transaction.State != ONGOING || transaction.pending || now-transaction.StartTimestamp <= int64(transaction.TimeoutMs) {
+			continue
+		}
+		coordinatorEpoch := c.partitions[c.partitionFor(transactionalId)]
+		aborted = append(aborted, timedOut{target: c.fencedAbort(transaction), coordinatorEpoch: coordinatorEpoch})
+	}
+	c.mutex.Unlock()
+
+	for _, transaction := range aborted {
+		c.logger.Info("Aborting a timed out transaction", "transactionalId", transaction.target.TransactionalId, "producerId", transaction.target.ProducerId)
+		c.abort(transaction.target, transaction.coordinatorEpoch)
+	}
+}
@@ -0,0 +1,398 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/config"
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+	"github.com/codecrafters-io/kafka-starter-go/app/metadata"
+	"github.com/codecrafters-io/kafka-starter-go/app/replica"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+var foo = storage.TopicPartition{Topic: "foo", Partition: 0}
+
+// testReplicaTransport is the transport of a single broker, it has no follower to fetch for and no ISR to change
+type testReplicaTransport struct{}
+
+func (t *testReplicaTransport) Fetch(leaderId int32, request replica.FetchRequest) (map[storage.TopicPartition]replica.FetchResult, error) {
+	return nil, errors.New("no other broker")
+}
+
+func (t *testReplicaTransport) AlterPartition(change controller.IsrChange) (int32, error) {
+	return 0, errors.New("no controller")
+}
+
+// testMarkerTransport writes the markers to the replica manager of the broker, which leads every partition
+type testMarkerTransport struct {
+	replicas *replica.Manager
+	mutex    sync.Mutex
+	markers  []Marker
+}
+
+func (t *testMarkerTransport) WriteTxnMarkers(leaderId int32, marker Marker) (map[storage.TopicPartition]error, error) {
+	t.mutex.Lock()
+	t.markers = append(t.markers, marker)
+	t.mutex.Unlock()
+
+	kind := storage.ABORT_MARKER
+	if marker.Commit {
+		kind = storage.COMMIT_MARKER
+	}
+	batches := make(map[storage.TopicPartition][]byte)
+	for _, partition := range marker.Partitions {
+		batches[partition] = storage.NewControlBatch(marker.ProducerId, marker.ProducerEpoch, marker.CoordinatorEpoch, kind, 0)
+	}
+
+	results := make(chan map[storage.TopicPartition]replica.AppendResult, 1)
+	t.replicas.AppendAsCoordinator(time.Second, batches, func(appended map[storage.TopicPartition]replica.AppendResult) { results <- appended })
+
+	errs := make(map[storage.TopicPartition]error)
+	for partition, result := range <-results {
+		errs[partition] = result.Err
+	}
+	return errs, nil
+}
+
+// testProducerIds allocates the blocks of producer ids like the controller
+type testProducerIds struct {
+	mutex sync.Mutex
+	next  int64
+}
+
+func (s *testProducerIds) AllocateProducerIds(brokerId int32, brokerEpoch int64) (controller.ProducerIdsBlock, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	block := controller.ProducerIdsBlock{FirstProducerId: s.next, Size: 10}
+	s.next += 10
+	return block, nil
+}
+
+// newTestImage is a cluster of broker 1, which leads the single partition of the state log and foo-0
+func newTestImage(t *testing.T) *metadata.Image {
+	image := metadata.NewImage()
+	records := []metadata.Record{
+		&metadata.RegisterBrokerRecord{BrokerId: 1, BrokerEpoch: 5, Endpoints: []metadata.BrokerEndpoint{}, LogDirs: []string{}},
+		&metadata.TopicRecord{Name: TRANSACTION_STATE_TOPIC, TopicId: "00000000-0000-0000-0000-000000000001"},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: "00000000-0000-0000-0000-000000000001", Replicas: []int32{1}, Isr: []int32{1}, Leader: 1, LeaderEpoch: 3},
+		&metadata.TopicRecord{Name: "foo", TopicId: "00000000-0000-0000-0000-000000000002"},
+		&metadata.PartitionRecord{PartitionId: 0, TopicId: "00000000-0000-0000-0000-000000000002", Replicas: []int32{1}, Isr: []int32{1}, Leader: 1},
+	}
+	for _, record := range records {
+		if err := image.Apply(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return image
+}
+
+type testBroker struct {
+	logs     *storage.LogManager
+	replicas *replica.Manager
+	markers  *testMarkerTransport
+	image    *metadata.Image
+	source   *testProducerIds
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	logs, err := storage.LoadLogManager([]string{filepath.Join(t.TempDir(), "logs")}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logs.Close() })
+
+	replicas := replica.NewManager(replica.Config{NodeId: 1, MaxLag: 30 * time.Second, QuotaSamples: 11, QuotaWindow: time.Second}, logs, config.NewStore(1, map[string]string{}), &testReplicaTransport{}, slog.New(slog.DiscardHandler))
+	t.Cleanup(replicas.Shutdown)
+
+	image := newTestImage(t)
+	replicas.ApplyImage(image)
+	return &testBroker{logs: logs, replicas: replicas, markers: &testMarkerTransport{replicas: replicas}, image: image, source: &testProducerIds{}}
+}
+
+func (b *testBroker) startCoordinator() *Coordinator {
+	coordinator := NewCoordinator(Config{
+		NodeId:                1,
+		NumPartitions:         1,
+		MaxTimeout:            time.Minute,
+		AbortTimedOutInterval: time.Hour,
+		RequestTimeout:        time.Second,
+		RetryBackoff:          10 * time.Millisecond,
+	}, b.logs, b.replicas, b.markers, b.source, func() int64 { return 5 }, slog.New(slog.DiscardHandler))
+	coordinator.ApplyImage(b.image)
+	return coordinator
+}
+
+func initProducerId(c *Coordinator, transactionalId string, timeoutMs int32) (int64, int16, error) {
+	type initialized struct {
+		producerId    int64
+		producerEpoch int16
+		err           error
+	}
+	result := make(chan initialized, 1)
+	c.InitProducerId(&transactionalId, timeoutMs, -1, -1, func(producerId int64, producerEpoch int16, err error) {
+		result <- initialized{producerId, producerEpoch, err}
+	})
+	r := <-result
+	return r.producerId, r.producerEpoch, r.err
+}
+
+func addPartitions(c *Coordinator, transactionalId string, producerId int64, producerEpoch int16, partitions ...storage.TopicPartition) error {
+	result := make(chan error, 1)
+	c.AddPartitions(transactionalId, producerId, producerEpoch, partitions, func(err error) { result <- err })
+	return <-result
+}
+
+func endTransaction(c *Coordinator, transactionalId string, producerId int64, producerEpoch int16, commit bool) error {
+	result := make(chan error, 1)
+	c.EndTransaction(transactionalId, producerId, producerEpoch, commit, func(err error) { result <- err })
+	return <-result
+}
+
+// waitForState waits until the transaction of a transactional id reaches state, and returns it
+func waitForState(t *testing.T, c *Coordinator, transactionalId string, state TransactionState) TransactionMetadata {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mutex.Lock()
+		transaction, ok := c.transactions[transactionalId]
+		var copied TransactionMetadata
+		if ok {
+			copied = *transaction
+		}
+		c.mutex.Unlock()
+
+		if ok && copied.State == state && !copied.pending {
+			return copied
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to reach %s, got %+v", transactionalId, state, copied)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// produceTransactional appends a transactional batch of a producer to foo-0
+func produceTransactional(t *testing.T, replicas *replica.Manager, producerId int64, producerEpoch int16, sequence int32) {
+	t.Helper()
+
+	batch := storage.NewRecordBatch(0, []storage.Record{{Value: []byte("a")}})
+	binary.BigEndian.PutUint16(batch[21:], 0x10) // Transactional
+	binary.BigEndian.PutUint64(batch[43:], uint64(producerId))
+	binary.BigEndian.PutUint16(batch[51:], uint16(producerEpoch))
+	binary.BigEndian.PutUint32(batch[53:], uint32(sequence))
+	binary.BigEndian.PutUint32(batch[17:], crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli)))
+
+	results := make(chan map[storage.TopicPartition]replica.AppendResult, 1)
+	replicas.AppendRecords(time.Second, replica.ACKS_ALL, map[storage.TopicPartition][]byte{foo: batch}, func(appended map[storage.TopicPartition]replica.AppendResult) { results <- appended })
+	if err := (<-results)[foo].Err; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoordinatorCommitsTransactions(t *testing.T) {
+	broker := newTestBroker(t)
+	coordinator := broker.startCoordinator()
+
+	producerId, producerEpoch, err := initProducerId(coordinator, "txn", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if producerId != 0 || producerEpoch != 0 {
+		t.Errorf("expected producer id 0 with epoch 0, got %d with epoch %d", producerId, producerEpoch)
+	}
+
+	// A new instance of the producer fences the previous one
+	if _, producerEpoch, err = initProducerId(coordinator, "txn", 10000); err != nil || producerEpoch != 1 {
+		t.Fatalf("expected epoch 1, got %d, %v", producerEpoch, err)
+	}
+	if err := addPartitions(coordinator, "txn", producerId, 0, foo); !errors.Is(err, ErrProducerFenced) {
+		t.Errorf("expected ErrProducerFenced, got %v", err)
+	}
+	if err := endTransaction(coordinator, "txn", producerId, 1, true); !errors.Is(err, ErrInvalidTxnState) {
+		t.Errorf("expected ErrInvalidTxnState without transaction, got %v", err)
+	}
+
+	// The leaders verify that their partition is part of the transaction before appending to it
+	bar := storage.TopicPartition{Topic: "bar", Partition: 0}
+	if errs, err := coordinator.VerifyPartitions("txn", producerId, 1, []storage.TopicPartition{foo}); err != nil || !errors.Is(errs[foo], ErrInvalidTxnState) {
+		t.Errorf("expected ErrInvalidTxnState before the partition is added, got %v, %v", errs, err)
+	}
+	if err := addPartitions(coordinator, "txn", producerId, 1, foo); err != nil {
+		t.Fatal(err)
+	}
+	if errs, err := coordinator.VerifyPartitions("txn", producerId, 1, []storage.TopicPartition{foo, bar}); err != nil || errs[foo] != nil || !errors.Is(errs[bar], ErrInvalidTxnState) {
+		t.Errorf("expected only foo-0 to be verified, got %v, %v", errs, err)
+	}
+	if _, err := coordinator.VerifyPartitions("txn", producerId, 0, []storage.TopicPartition{foo}); !errors.Is(err, ErrProducerFenced) {
+		t.Errorf("expected ErrProducerFenced, got %v", err)
+	}
+	produceTransactional(t, broker.replicas, producerId, 1, 0)
+	if lso, _ := broker.logs.LastStableOffset(foo, 1); lso != 0 {
+		t.Errorf("expected the ongoing transaction to hold the last stable offset at 0, got %d", lso)
+	}
+
+	if err := endTransaction(coordinator, "txn", producerId, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, coordinator, "txn", COMPLETE_COMMIT)
+
+	if lso, _ := broker.logs.LastStableOffset(foo, 2); lso != 2 {
+		t.Errorf("expected the committed transaction to be stable, got a last stable offset of %d", lso)
+	}
+	if producers, _ := broker.logs.ActiveProducers(foo); len(producers) != 1 || producers[0].CoordinatorEpoch != 3 {
+		t.Errorf("expected the marker of coordinator epoch 3, got %v", producers)
+	}
+	// The retry of the request succeeds, the other decision does not
+	if err := endTransaction(coordinator, "txn", producerId, 1, true); err != nil {
+		t.Errorf("expected the retry to succeed, got %v", err)
+	}
+	if err := endTransaction(coordinator, "txn", producerId, 1, false); !errors.Is(err, ErrInvalidTxnState) {
+		t.Errorf("expected ErrInvalidTxnState, got %v", err)
+	}
+
+	// Another coordinator loads the state from the log
+	coordinator.Shutdown()
+	loaded := broker.startCoordinator()
+	defer loaded.Shutdown()
+	if got := waitForState(t, loaded, "txn", COMPLETE_COMMIT); got.ProducerId != producerId || got.ProducerEpoch != 1 || got.TimeoutMs != 10000 {
+		t.Errorf("expected the loaded transaction of producer %d with epoch 1, got %+v", producerId, got)
+	}
+
+	if _, _, err := initProducerId(loaded, "txn", int32(2*time.Minute/time.Millisecond)); !errors.Is(err, ErrInvalidTransactionTimeout) {
+		t.Errorf("expected ErrInvalidTransactionTimeout, got %v", err)
+	}
+	if err := addPartitions(loaded, "other", producerId, 1, foo); !errors.Is(err, ErrInvalidProducerIdMapping) {
+		t.Errorf("expected ErrInvalidProducerIdMapping, got %v", err)
+	}
+}
+
+func TestCoordinatorAbortsTimedOutTransactions(t *testing.T) {
+	broker := newTestBroker(t)
+	coordinator := broker.startCoordinator()
+	defer coordinator.Shutdown()
+
+	producerId, producerEpoch, err := initProducerId(coordinator, "txn", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := addPartitions(coordinator, "txn", producerId, producerEpoch, foo); err != nil {
+		t.Fatal(err)
+	}
+	produceTransactional(t, broker.replicas, producerId, producerEpoch, 0)
+
+	// The transaction is aborted once its timeout elapsed, with the next epoch of the producer
+	coordinator.abortTimedOutTransactions()
+	waitForState(t, coordinator, "txn", ONGOING)
+	coordinator.now = func() time.Time { return time.Now().Add(2 * time.Second) }
+	coordinator.abortTimedOutTransactions()
+
+	aborted := waitForState(t, coordinator, "txn", COMPLETE_ABORT)
+	if aborted.ProducerEpoch != producerEpoch+1 {
+		t.Errorf("expected the abort to bump the epoch to %d, got %d", producerEpoch+1, aborted.ProducerEpoch)
+	}
+	if transactions, _ := broker.logs.AbortedTransactions(foo, 0, 2); len(transactions) != 1 || transactions[0].ProducerId != producerId {
+		t.Errorf("expected the aborted transaction of producer %d, got %v", producerId, transactions)
+	}
+	if err := addPartitions(coordinator, "txn", producerId, producerEpoch, foo); !errors.Is(err, ErrProducerFenced) {
+		t.Errorf("expected the producer to be fenced, got %v", err)
+	}
+}
+
+func TestInitProducerIdAbortsOngoingTransaction(t *testing.T) {
+	broker := newTestBroker(t)
+	coordinator := broker.startCoordinator()
+	defer coordinator.Shutdown()
+
+	producerId, _, err := initProducerId(coordinator, "txn", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := addPartitions(coordinator, "txn", producerId, 0, foo); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := initProducerId(coordinator, "txn", 10000); !errors.Is(err, ErrConcurrentTransactions) {
+		t.Errorf("expected ErrConcurrentTransactions while the transaction aborts, got %v", err)
+	}
+	waitForState(t, coordinator, "txn", COMPLETE_ABORT)
+
+	// The fencing abort bumped the epoch once, the new instance gets the next one
+	if got, epoch, err := initProducerId(coordinator, "txn", 10000); err != nil || got != producerId || epoch != 2 {
+		t.Errorf("expected producer %d with epoch 2, got %d with epoch %d, %v", producerId, got, epoch, err)
+	}
+
+	// The idempotent producers get their own producer id
+	result := make(chan int64, 1)
+	coordinator.InitProducerId(nil, 0, -1, -1, func(producerId int64, producerEpoch int16, err error) { result <- producerId })
+	if idempotent := <-result; idempotent != producerId+1 {
+		t.Errorf("expected producer id %d, got %d", producerId+1, idempotent)
+	}
+}
+
+func TestCoordinatorDescribesTransactions(t *testing.T) {
+	broker := newTestBroker(t)
+	coordinator := broker.startCoordinator()
+	defer coordinator.Shutdown()
+
+	ongoing, ongoingEpoch, err := initProducerId(coordinator, "ongoing", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := addPartitions(coordinator, "ongoing", ongoing, ongoingEpoch, foo); err != nil {
+		t.Fatal(err)
+	}
+	empty, _, err := initProducerId(coordinator, "empty", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	described, err := coordinator.DescribeTransaction("ongoing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if described.State != ONGOING || described.ProducerId != ongoing || len(described.Partitions) != 1 || described.Partitions[0] != foo {
+		t.Errorf("expected the ongoing transaction of producer %d on foo-0, got %+v", ongoing, described)
+	}
+	if _, err := coordinator.DescribeTransaction("unknown"); !errors.Is(err, ErrTransactionalIdNotFound) {
+		t.Errorf("expected ErrTransactionalIdNotFound, got %v", err)
+	}
+
+	coordinator.now = func() time.Time { return time.UnixMilli(described.StartTimestamp).Add(time.Minute) }
+	tests := []struct {
+		name   string
+		filter TransactionFilter
+		want   []string
+	}{
+		{"Any transaction", TransactionFilter{DurationFilter: -1}, []string{"empty", "ongoing"}},
+		{"State", TransactionFilter{States: []TransactionState{ONGOING, COMPLETE_ABORT}, DurationFilter: -1}, []string{"ongoing"}},
+		{"Producer id", TransactionFilter{ProducerIds: []int64{empty}, DurationFilter: -1}, []string{"empty"}},
+		{"Running longer", TransactionFilter{States: []TransactionState{ONGOING}, DurationFilter: 30 * time.Second}, []string{"ongoing"}},
+		{"Not running long enough", TransactionFilter{States: []TransactionState{ONGOING}, DurationFilter: time.Minute}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions, err := coordinator.ListTransactions(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, transaction := range transactions {
+				got = append(got, transaction.TransactionalId)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected the transactions %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
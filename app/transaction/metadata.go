@@ -0,0 +1,261 @@
+package transaction
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf16"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/parser"
+	"github.com/codecrafters-io/kafka-starter-go/app/serializer"
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+// TRANSACTION_STATE_TOPIC is the internal topic holding the state of the transactions, each transactional id is
+// owned by the coordinator leading one of its partitions
+const TRANSACTION_STATE_TOPIC = "__transaction_state"
+
+var ErrInvalidStateRecord = errors.New("invalid transaction state record")
+
+// TransactionState is the state of a transaction, with the ids Kafka writes to its transaction state log
+type TransactionState int8
+
+const (
+	// The producer has no transaction in progress
+	EMPTY TransactionState = 0
+	// The producer added partitions to its transaction
+	ONGOING TransactionState = 1
+	// The producer ended its transaction, the coordinator writes the markers to its partitions
+	PREPARE_COMMIT TransactionState = 2
+	PREPARE_ABORT  TransactionState = 3
+	// Every partition of the transaction has its marker
+	COMPLETE_COMMIT TransactionState = 4
+	COMPLETE_ABORT  TransactionState = 5
+)
+
+var transactionStateNames = map[TransactionState]string{
+	EMPTY:           "Empty",
+	ONGOING:         "Ongoing",
+	PREPARE_COMMIT:  "PrepareCommit",
+	PREPARE_ABORT:   "PrepareAbort",
+	COMPLETE_COMMIT: "CompleteCommit",
+	COMPLETE_ABORT:  "CompleteAbort",
+}
+
+// String is the name Kafka gives to the state, such as in the DescribeTransactions API
+func (s TransactionState) String() string {
+	if name, ok := transactionStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", s)
+}
+
+// ParseTransactionState returns the state Kafka names name, false for an unknown name
+func ParseTransactionState(name string) (TransactionState, bool) {
+	for state, stateName := range transactionStateNames {
+		if stateName == name {
+			return state, true
+		}
+	}
+	return 0, false
+}
+
+// TransactionMetadata is the state of a transactional id on its coordinator
+type TransactionMetadata struct {
+	TransactionalId string
+	ProducerId      int64
+	ProducerEpoch   int16
+	TimeoutMs       int32
+	State           TransactionState
+	// The partitions of the ongoing transaction, sorted
+	Partitions []storage.TopicPartition
+	// When the state last changed and when the ongoing transaction started, -1 without transaction, in milliseconds
+	LastUpdateTimestamp int64
+	StartTimestamp      int64
+	// A change of the state is being written to the state log, the transaction takes no other change meanwhile
+	pending bool
+}
+
+// PartitionFor returns the partition of the internal topic of partitions partitions that owns key, a transactional id
+// or a group id. Like Kafka, it is the Java hash code of key modulo partitions, so that Kafka tools agree with it
+func PartitionFor(key string, partitions int) int32 {
+	hash := int32(0)
+	for _, unit := range utf16.Encode([]rune(key)) {
+		hash = 31*hash + int32(unit)
+	}
+	return int32(int(hash&0x7fffffff) % partitions)
+}
+
+// The version of the keys and values this coordinator writes, the ones of Kafka's TransactionLogKey and
+// TransactionLogValue
+const (
+	stateKeyVersion   = 0
+	stateValueVersion = 0
+)
+
+// encodeStateKey writes the key of the state records of a transactional id
+func encodeStateKey(transactionalId string) ([]byte, error) {
+	buffer := make([]byte, 4+len(transactionalId))
+	index, err := serializer.SerializeInt16(buffer, 0, stateKeyVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeString(buffer, index, transactionalId)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer[:index], nil
+}
+
+// encodeStateValue writes the state of a transaction, its partitions grouped by topic
+func encodeStateValue(transaction *TransactionMetadata) ([]byte, error) {
+	topics := make(map[string][]int32)
+	for _, partition := range transaction.Partitions {
+		topics[partition.Topic] = append(topics[partition.Topic], partition.Partition)
+	}
+
+	bufferSize := 48
+	for topic, partitions := range topics {
+		bufferSize += 6 + len(topic) + 4*len(partitions)
+	}
+
+	buffer := make([]byte, bufferSize)
+	index, err := serializer.SerializeInt16(buffer, 0, stateValueVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, transaction.ProducerId)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt16(buffer, index, transaction.ProducerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, transaction.TimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt8(buffer, index, int8(transaction.State))
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt32(buffer, index, int32(len(topics)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topic := range slices.Sorted(maps.Keys(topics)) {
+		index, err = serializer.SerializeString(buffer, index, topic)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err = serializer.SerializeInt32(buffer, index, int32(len(topics[topic])))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, partition := range topics[topic] {
+			index, err = serializer.SerializeInt32(buffer, index, partition)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, transaction.LastUpdateTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err = serializer.SerializeInt64(buffer, index, transaction.StartTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer[:index], nil
+}
+
+// decodeStateRecord reads a record of the state log, a nil value is the tombstone of its transactional id
+func decodeStateRecord(key []byte, value []byte) (string, *TransactionMetadata, error) {
+	version, index, err := parser.ExtractInt16(key, 0)
+	if err != nil || version != stateKeyVersion {
+		return "", nil, fmt.Errorf("%w: unsupported key version", ErrInvalidStateRecord)
+	}
+
+	transactionalId, _, err := parser.ExtractNullableString(key, index)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: transactional id: %w", ErrInvalidStateRecord, err)
+	}
+
+	if value == nil {
+		return transactionalId, nil, nil
+	}
+
+	transaction := &TransactionMetadata{TransactionalId: transactionalId}
+	version, index, err = parser.ExtractInt16(value, 0)
+	if err != nil || version != stateValueVersion {
+		return "", nil, fmt.Errorf("%w: unsupported value version of %s", ErrInvalidStateRecord, transactionalId)
+	}
+
+	transaction.ProducerId, index, err = parser.ExtractInt64(value, index)
+	if err == nil {
+		transaction.ProducerEpoch, index, err = parser.ExtractInt16(value, index)
+	}
+	if err == nil {
+		transaction.TimeoutMs, index, err = parser.ExtractInt32(value, index)
+	}
+	var state int8
+	if err == nil {
+		state, index, err = parser.ExtractInt8(value, index)
+		transaction.State = TransactionState(state)
+	}
+	var topicsLength int32
+	if err == nil {
+		topicsLength, index, err = parser.ExtractInt32(value, index)
+	}
+	for i := int32(0); err == nil && i < topicsLength; i++ {
+		var topic string
+		var partitionsLength int32
+		topic, index, err = parser.ExtractNullableString(value, index)
+		if err == nil {
+			partitionsLength, index, err = parser.ExtractInt32(value, index)
+		}
+		for j := int32(0); err == nil && j < partitionsLength; j++ {
+			var partition int32
+			partition, index, err = parser.ExtractInt32(value, index)
+			transaction.Partitions = append(transaction.Partitions, storage.TopicPartition{Topic: topic, Partition: partition})
+		}
+	}
+	if err == nil {
+		transaction.LastUpdateTimestamp, index, err = parser.ExtractInt64(value, index)
+	}
+	if err == nil {
+		transaction.StartTimestamp, _, err = parser.ExtractInt64(value, index)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: state of %s: %w", ErrInvalidStateRecord, transactionalId, err)
+	}
+	if _, ok := transactionStateNames[transaction.State]; !ok {
+		return "", nil, fmt.Errorf("%w: unknown state %d of %s", ErrInvalidStateRecord, state, transactionalId)
+	}
+
+	sortPartitions(transaction.Partitions)
+	return transactionalId, transaction, nil
+}
+
+func sortPartitions(partitions []storage.TopicPartition) {
+	slices.SortFunc(partitions, func(a, b storage.TopicPartition) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+}
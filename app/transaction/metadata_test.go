@@ -0,0 +1,77 @@
+package transaction
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/storage"
+)
+
+func TestPartitionFor(t *testing.T) {
+	tests := []struct {
+		key        string
+		partitions int
+		want       int32
+	}{
+		{"txn", 50, 6},
+		{"txn", 1, 0},
+		// The Java hash code of the key is math.MinInt32, the sign bit is dropped
+		{"polygenelubricants", 50, 0},
+	}
+
+	for _, test := range tests {
+		if got := PartitionFor(test.key, test.partitions); got != test.want {
+			t.Errorf("PartitionFor(%q, %d) = %d, expected %d", test.key, test.partitions, got, test.want)
+		}
+	}
+}
+
+func TestStateRecord(t *testing.T) {
+	transaction := &TransactionMetadata{
+		TransactionalId:     "txn",
+		ProducerId:          1000,
+		ProducerEpoch:       3,
+		TimeoutMs:           60000,
+		State:               ONGOING,
+		Partitions:          []storage.TopicPartition{{Topic: "foo", Partition: 1}, {Topic: "bar", Partition: 0}, {Topic: "foo", Partition: 0}},
+		LastUpdateTimestamp: 1700000000100,
+		StartTimestamp:      1700000000000,
+	}
+
+	key, err := encodeStateKey(transaction.TransactionalId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := encodeStateValue(transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, decoded, err := decodeStateRecord(key, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortPartitions(transaction.Partitions)
+	if id != "txn" || !reflect.DeepEqual(decoded, transaction) {
+		t.Errorf("expected %+v, got %s: %+v", transaction, id, decoded)
+	}
+
+	// A tombstone removes the transactional id
+	if id, decoded, err := decodeStateRecord(key, nil); err != nil || id != "txn" || decoded != nil {
+		t.Errorf("expected the tombstone of txn, got %s: %+v, %v", id, decoded, err)
+	}
+	if _, _, err := decodeStateRecord(key, value[:len(value)-1]); err == nil {
+		t.Error("expected the truncated value to be rejected")
+	}
+}
+
+func TestParseTransactionState(t *testing.T) {
+	for state, name := range transactionStateNames {
+		if got, ok := ParseTransactionState(name); !ok || got != state {
+			t.Errorf("expected %s to be %d, got %d", name, state, got)
+		}
+	}
+	if _, ok := ParseTransactionState("Dead"); ok {
+		t.Errorf("expected Dead to be an unknown state")
+	}
+}
@@ -0,0 +1,43 @@
+package transaction
+
+import (
+	"sync"
+
+	"github.com/codecrafters-io/kafka-starter-go/app/controller"
+)
+
+// ProducerIdSource allocates the blocks of producer ids of a broker, it is the controller channel of the broker. The
+// broker epoch is the one of its current registration
+type ProducerIdSource interface {
+	AllocateProducerIds(brokerId int32, brokerEpoch int64) (controller.ProducerIdsBlock, error)
+}
+
+// producerIdManager hands out the producer ids of the block the controller allocated to the broker, and asks for the
+// next block once it is exhausted
+type producerIdManager struct {
+	brokerId    int32
+	brokerEpoch func() int64
+	source      ProducerIdSource
+
+	mutex sync.Mutex
+	next  int64
+	end   int64
+}
+
+// nextProducerId returns a producer id that was never handed out in the cluster
+func (m *producerIdManager) nextProducerId() (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.next >= m.end {
+		block, err := m.source.AllocateProducerIds(m.brokerId, m.brokerEpoch())
+		if err != nil {
+			return -1, err
+		}
+		m.next, m.end = block.FirstProducerId, block.FirstProducerId+int64(block.Size)
+	}
+
+	producerId := m.next
+	m.next++
+	return producerId, nil
+}